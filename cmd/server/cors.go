@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/handlers"
+)
+
+// corsPolicy is one CORS rule set. Zero fields in a group inherit the default policy.
+type corsPolicy struct {
+	Prefix         string   `json:"prefix"` // Path prefix the policy applies to (groups only)
+	Origins        []string `json:"origins"`
+	Methods        []string `json:"methods"`
+	Headers        []string `json:"headers"`
+	ExposedHeaders []string `json:"exposedHeaders"`
+	MaxAge         int      `json:"maxAge"` // Preflight cache in seconds (gorilla caps it at 600)
+	Credentials    *bool    `json:"credentials"`
+}
+
+// corsConfig is the CORS_CONFIG file layout.
+type corsConfig struct {
+	Default corsPolicy   `json:"default"`
+	Groups  []corsPolicy `json:"groups"`
+}
+
+// loadCORSConfig reads the CORS_CONFIG file if set. Without one, every route gets
+// the default policy built from CORS_ORIGINS.
+func loadCORSConfig(path, origins string) (*corsConfig, error) {
+	cfg := &corsConfig{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+
+	def := &cfg.Default
+	if len(def.Origins) == 0 {
+		for _, o := range strings.Split(origins, ",") {
+			if o = strings.TrimSpace(o); o != "" {
+				def.Origins = append(def.Origins, o)
+			}
+		}
+	}
+	if len(def.Methods) == 0 {
+		def.Methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	}
+	if len(def.Headers) == 0 {
+		def.Headers = []string{"Authorization", "Content-Type", "Accept", "X-Device-ID", "X-App-Version", "Idempotency-Key", "Content-Encoding", "X-Sync-Protocol"}
+	}
+	if len(def.ExposedHeaders) == 0 {
+		def.ExposedHeaders = []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Deprecation", "Sunset", "Link", "Idempotent-Replayed", "X-Sync-Protocol", "X-Sync-Protocol-Min", "X-Sync-Protocol-Max"}
+	}
+	if def.Credentials == nil {
+		off := false
+		def.Credentials = &off
+	}
+
+	for i := range cfg.Groups {
+		g := &cfg.Groups[i]
+		if !strings.HasPrefix(g.Prefix, "/") {
+			return nil, fmt.Errorf("CORS group %d: prefix must start with /", i)
+		}
+		if len(g.Origins) == 0 {
+			g.Origins = def.Origins
+		}
+		if len(g.Methods) == 0 {
+			g.Methods = def.Methods
+		}
+		if len(g.Headers) == 0 {
+			g.Headers = def.Headers
+		}
+		if len(g.ExposedHeaders) == 0 {
+			g.ExposedHeaders = def.ExposedHeaders
+		}
+		if g.MaxAge == 0 {
+			g.MaxAge = def.MaxAge
+		}
+		if g.Credentials == nil {
+			g.Credentials = def.Credentials
+		}
+	}
+
+	for _, p := range append([]corsPolicy{*def}, cfg.Groups...) {
+		if *p.Credentials && containsString(p.Origins, "*") {
+			return nil, fmt.Errorf("CORS policy %q: credentials cannot be allowed for origin *", p.Prefix)
+		}
+	}
+	return cfg, nil
+}
+
+// corsHandler wraps h so each request is handled by the policy with the longest
+// matching prefix, falling back to the default policy.
+func (cfg *corsConfig) corsHandler(h http.Handler) http.Handler {
+	type group struct {
+		prefix  string
+		handler http.Handler
+	}
+
+	groups := make([]group, 0, len(cfg.Groups))
+	for _, p := range cfg.Groups {
+		groups = append(groups, group{prefix: p.Prefix, handler: p.wrap(h)})
+	}
+	sort.Slice(groups, func(i, j int) bool { return len(groups[i].prefix) > len(groups[j].prefix) })
+	fallback := cfg.Default.wrap(h)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, g := range groups {
+			if strings.HasPrefix(r.URL.Path, g.prefix) {
+				g.handler.ServeHTTP(w, r)
+				return
+			}
+		}
+		fallback.ServeHTTP(w, r)
+	})
+}
+
+func (p *corsPolicy) wrap(h http.Handler) http.Handler {
+	opts := []handlers.CORSOption{
+		handlers.AllowedOrigins(p.Origins),
+		handlers.AllowedMethods(p.Methods),
+		handlers.AllowedHeaders(p.Headers),
+		handlers.ExposedHeaders(p.ExposedHeaders),
+	}
+	if p.MaxAge > 0 {
+		opts = append(opts, handlers.MaxAge(p.MaxAge))
+	}
+	if *p.Credentials {
+		opts = append(opts, handlers.AllowCredentials())
+	}
+	return handlers.CORS(opts...)(h)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -2,23 +2,44 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/abuse"
 	"github.com/scalecode-solutions/tracker2api/internal/api"
 	"github.com/scalecode-solutions/tracker2api/internal/auth"
+	"github.com/scalecode-solutions/tracker2api/internal/caption"
+	"github.com/scalecode-solutions/tracker2api/internal/community"
 	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/integrations/nutrition"
+	"github.com/scalecode-solutions/tracker2api/internal/moderation"
+	"github.com/scalecode-solutions/tracker2api/internal/mvchat"
+	"github.com/scalecode-solutions/tracker2api/internal/notify"
+	"github.com/scalecode-solutions/tracker2api/internal/preview"
+	"github.com/scalecode-solutions/tracker2api/internal/privacy"
+	"github.com/scalecode-solutions/tracker2api/internal/ratelimit"
+	"github.com/scalecode-solutions/tracker2api/internal/storage"
+	"github.com/scalecode-solutions/tracker2api/internal/summarize"
+	"github.com/scalecode-solutions/tracker2api/internal/trackerv1"
+	"github.com/scalecode-solutions/tracker2api/internal/webhook"
 )
 
 func main() {
+	// Offline tooling subcommands
+	if len(os.Args) > 1 && os.Args[1] == "verify-timeline" {
+		os.Exit(runVerifyTimeline(os.Args[2:]))
+	}
+
 	// Load configuration from environment
 	port := getEnv("PORT", "8080")
 	databaseURL := getEnv("DATABASE_URL", "postgres://mvchat:@localhost:5432/mvchat?sslmode=disable")
@@ -38,7 +59,7 @@ func main() {
 	}
 
 	// Initialize database connection
-	database, err := db.New(databaseURL)
+	database, err := db.New(databaseURL, getEnv("DATABASE_STANDBY_URL", ""))
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
@@ -61,14 +82,281 @@ func main() {
 		log.Printf("Applied %d migration(s), new schema version: %d", applied, newVersion)
 	}
 
+	// Background jobs don't survive a restart
+	if failed, err := database.FailInterruptedJobs(context.Background()); err != nil {
+		log.Printf("Warning: Could not clean up interrupted jobs: %v", err)
+	} else if failed > 0 {
+		log.Printf("Marked %d interrupted job(s) as failed", failed)
+	}
+
 	// Initialize authenticator (validates mvchat2 JWT tokens)
 	authenticator := auth.New(authKeyBytes)
 
+	// Timeline export signing key: base64 Ed25519 seed, or derived from the auth key
+	var timelineSeed []byte
+	if timelineKey := getEnv("TIMELINE_SIGNING_KEY", ""); timelineKey != "" {
+		timelineSeed, err = base64.StdEncoding.DecodeString(timelineKey)
+		if err != nil || len(timelineSeed) != ed25519.SeedSize {
+			log.Fatalf("TIMELINE_SIGNING_KEY must be a base64 %d-byte Ed25519 seed", ed25519.SeedSize)
+		}
+	} else {
+		sum := sha256.Sum256(append([]byte("tracker2api timeline signing\x00"), authKeyBytes...))
+		timelineSeed = sum[:]
+	}
+
+	// Operators allowed to view admin reports
+	var adminUserIDs []string
+	for _, id := range strings.Split(getEnv("ADMIN_USER_IDS", ""), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			adminUserIDs = append(adminUserIDs, id)
+		}
+	}
+
+	// Sync v2 soft launch: user IDs allowed to use it, or "*" for everyone
+	var syncV2Users []string
+	for _, id := range strings.Split(getEnv("SYNC_V2_USERS", ""), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			syncV2Users = append(syncV2Users, id)
+		}
+	}
+
+	// Entry trigger soft launch: user IDs allowed to set up smart home webhooks,
+	// or "*" for everyone
+	var triggerUsers []string
+	for _, id := range strings.Split(getEnv("ENTRY_TRIGGER_USERS", ""), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			triggerUsers = append(triggerUsers, id)
+		}
+	}
+	var webhooks webhook.Sender
+	if len(triggerUsers) > 0 {
+		webhooks = webhook.NewHTTP()
+	}
+
+	// Relay that sends batch invite codes by email and SMS; unset, the codes
+	// are returned for the owner to share
+	var invites notify.Sender
+	if notifyURL := getEnv("NOTIFY_RELAY_URL", ""); notifyURL != "" {
+		invites = notify.NewHTTP(notifyURL, getEnv("NOTIFY_RELAY_TOKEN", ""))
+	}
+
+	// Rate limit counters: Redis if configured, else Postgres, shared by every
+	// instance ("memory" keeps them per instance)
+	var limits ratelimit.Store = ratelimit.Postgres{DB: database}
+	if redisURL := getEnv("REDIS_URL", ""); redisURL != "" {
+		redis, err := ratelimit.NewRedis(redisURL)
+		if err != nil {
+			log.Fatalf("Invalid REDIS_URL: %v", err)
+		}
+		limits = redis
+	}
+	if getEnv("RATE_LIMIT_STORE", "") == "memory" {
+		limits = ratelimit.NewMemory()
+	}
+
+	// Deprecation and removal dates announced on deprecated legacy routes
+	legacyDeprecated, err := time.Parse("2006-01-02", getEnv("LEGACY_DEPRECATED", "2026-10-16"))
+	if err != nil {
+		log.Fatalf("LEGACY_DEPRECATED must be a YYYY-MM-DD date: %v", err)
+	}
+	var legacySunset *time.Time
+	if sunset := getEnv("LEGACY_SUNSET", ""); sunset != "" {
+		t, err := time.Parse("2006-01-02", sunset)
+		if err != nil {
+			log.Fatalf("LEGACY_SUNSET must be a YYYY-MM-DD date: %v", err)
+		}
+		if !t.After(legacyDeprecated) {
+			log.Fatalf("LEGACY_SUNSET must be after LEGACY_DEPRECATED (%s)", legacyDeprecated.Format("2006-01-02"))
+		}
+		legacySunset = &t
+	}
+
+	// Shared image moderation (external API or a local model behind the same protocol)
+	var moderator moderation.Moderator
+	if moderationURL := getEnv("MODERATION_URL", ""); moderationURL != "" {
+		moderator = moderation.NewHTTP(moderationURL, getEnv("MODERATION_TOKEN", ""))
+	}
+
+	// Alt text suggestions for images (external captioning API or a local model
+	// behind the same protocol); suggestions are only saved when a user accepts them
+	var captioner caption.Captioner
+	if captionURL := getEnv("CAPTION_URL", ""); captionURL != "" {
+		captioner = caption.NewHTTP(captionURL, getEnv("CAPTION_TOKEN", ""))
+	}
+
+	// Journal post summaries (external LLM service or a local model behind the
+	// same protocol); off unless configured, and then only for consenting owners
+	var summarizer summarize.Summarizer
+	if summarizerURL := getEnv("SUMMARIZER_URL", ""); summarizerURL != "" {
+		summarizer = summarize.NewHTTP(summarizerURL, getEnv("SUMMARIZER_TOKEN", ""))
+	}
+
+	// Food database for meal entries (external API or a self-hosted copy behind
+	// the same protocol), cached in memory
+	var foods nutrition.Provider
+	if nutritionURL := getEnv("NUTRITION_API_URL", ""); nutritionURL != "" {
+		foods = nutrition.NewCached(nutrition.NewHTTP(nutritionURL, getEnv("NUTRITION_API_KEY", "")), time.Duration(getEnvInt("NUTRITION_CACHE_HOURS", 24))*time.Hour)
+	}
+
+	// Legacy v1 tracker to pull exports from when users migrate; without it
+	// users upload their v1 export themselves
+	var v1Source trackerv1.Source
+	if v1URL := getEnv("V1_TRACKER_URL", ""); v1URL != "" {
+		v1Source = trackerv1.NewHTTP(v1URL)
+	}
+
+	// Community service whose topics are linked to weekly facts, e.g. mvchat2 rooms
+	var communityLinker community.Linker
+	if linkURL := getEnv("COMMUNITY_LINK_URL", ""); linkURL != "" {
+		t, err := community.NewTemplate(getEnv("COMMUNITY_SERVICE", "mvchat2"), linkURL)
+		if err != nil {
+			log.Fatalf("Failed to configure community links: %v", err)
+		}
+		communityLinker = t
+	}
+
+	// Optional surfaces this deployment doesn't serve (signed-files, widget, events)
+	disabledSurfaces, err := api.ParseSurfaces(getEnv("DISABLED_SURFACES", ""))
+	if err != nil {
+		log.Fatalf("Invalid DISABLED_SURFACES: %v", err)
+	}
+
+	// Pairing request spam screening; CAPTCHAs are asked for only with a verify URL
+	pairingScreen := &abuse.Heuristics{
+		DailyCap:     getEnvInt("PAIRING_DAILY_CAP", 10),
+		CaptchaAfter: getEnvInt("PAIRING_CAPTCHA_AFTER", 3),
+	}
+	if captchaURL := getEnv("CAPTCHA_VERIFY_URL", ""); captchaURL != "" {
+		pairingScreen.Captcha = abuse.NewSiteVerifier(captchaURL, getEnv("CAPTCHA_SECRET", ""))
+	}
+
+	// Weekly progress posts into mvchat2 conversations, sent as the tracker bot
+	var chat mvchat.Poster
+	if botURL := getEnv("MVCHAT_BOT_URL", ""); botURL != "" {
+		chat = mvchat.NewHTTP(botURL, getEnv("MVCHAT_BOT_TOKEN", ""))
+	}
+
+	// PDF and video previews, rendered with poppler and ffmpeg when their paths are set
+	var previewer preview.Runner
+	pdftoppmPath, ffmpegPath := getEnv("PREVIEW_PDFTOPPM", ""), getEnv("PREVIEW_FFMPEG", "")
+	if pdftoppmPath != "" || ffmpegPath != "" {
+		previewer = preview.NewExec(pdftoppmPath, ffmpegPath)
+	}
+
+	// Profile photo URL signing key: base64, or derived from the auth key.
+	// Changing it invalidates every outstanding signed URL.
+	var fileURLKey []byte
+	if key := getEnv("FILE_URL_KEY", ""); key != "" {
+		fileURLKey, err = base64.StdEncoding.DecodeString(key)
+		if err != nil || len(fileURLKey) < 32 {
+			log.Fatal("FILE_URL_KEY must be at least 32 base64-encoded bytes")
+		}
+	} else {
+		sum := sha256.Sum256(append([]byte("tracker2api file urls\x00"), authKeyBytes...))
+		fileURLKey = sum[:]
+	}
+
+	// Cross-user benchmarks: privacy budget per bucket and minimum cohort. The noise
+	// key is derived from the auth key so restarts reuse the same noise.
+	benchmarkPrivacy := privacy.Params{
+		Epsilon:   getEnvFloat("BENCHMARK_EPSILON", 1.0),
+		MinCohort: getEnvInt("BENCHMARK_MIN_COHORT", 20),
+	}
+	if err := benchmarkPrivacy.Validate(); err != nil {
+		log.Fatalf("Invalid benchmark privacy parameters: %v", err)
+	}
+	benchmarkNoiseKey := sha256.Sum256(append([]byte("tracker2api benchmark noise\x00"), authKeyBytes...))
+
+	// Shared secret for mvchat2 profile webhooks (unset: webhook disabled)
+	webhookSecret := []byte(getEnv("MVCHAT_WEBHOOK_SECRET", ""))
+
+	// Data residency: per-region upload roots, UPLOAD_PATH for the default region
+	uploads, err := storage.Parse(getEnv("STORAGE_REGIONS", ""), uploadPath)
+	if err != nil {
+		log.Fatalf("Failed to parse STORAGE_REGIONS: %v", err)
+	}
+	serverRegion := strings.ToLower(getEnv("SERVER_REGION", ""))
+
+	// Cold storage for archived pregnancies' files, per region like STORAGE_REGIONS
+	if err := uploads.SetCold(getEnv("COLD_STORAGE_REGIONS", ""), getEnv("COLD_STORAGE_PATH", "")); err != nil {
+		log.Fatalf("Failed to parse COLD_STORAGE_REGIONS: %v", err)
+	}
+	coldAfterDays := getEnvInt("COLD_STORAGE_AFTER_DAYS", 30)
+
+	// Per-user failure injection for client testing; never on production
+	chaos := os.Getenv("CHAOS_ENABLED") == "true"
+	if chaos {
+		log.Println("Failure injection enabled (CHAOS_ENABLED): staging only")
+	}
+
 	// Create API handler
-	apiHandler := api.New(database, authenticator, uploadPath, dataPath)
+	apiHandler := api.New(database, authenticator, uploads, serverRegion, dataPath, ed25519.NewKeyFromSeed(timelineSeed), adminUserIDs, legacyDeprecated, legacySunset, moderator, fileURLKey, getEnvInt("HEAVY_CONCURRENCY_PER_USER", 2), webhookSecret, int64(getEnvInt("STORAGE_QUOTA_MB", 0))<<20, previewer, syncV2Users, getEnvInt("SYNC_MIN_PROTOCOL", 1), chat, getEnvInt("BIRTH_ARCHIVE_DAYS", 90), pairingScreen, summarizer, getEnvInt("SUMMARY_MIN_LENGTH", 1000), foods, triggerUsers, webhooks, invites, limits, captioner, v1Source, communityLinker, disabledSurfaces, chaos)
+
+	// Weekly facts and baby sizes live in the database; the data files seed them
+	if err := apiHandler.SeedContent(context.Background()); err != nil {
+		log.Printf("Warning: Could not seed content: %v", err)
+	}
+
+	// Move files of long-archived pregnancies to cold storage
+	if len(uploads.ColdRegions()) > 0 {
+		go apiHandler.RunTiering(time.Duration(coldAfterDays) * 24 * time.Hour)
+	}
+
+	// Render queued file previews
+	if previewer != nil {
+		go apiHandler.RunPreviews()
+	}
+
+	// Announce each new gestational week in opted-in family chats
+	if chat != nil {
+		go apiHandler.RunProgressPosts()
+	}
+
+	// Release cross-user benchmarks with differential privacy
+	go apiHandler.RunBenchmarks(benchmarkPrivacy, benchmarkNoiseKey[:], time.Duration(getEnvInt("BENCHMARK_INTERVAL_HOURS", 24))*time.Hour)
+
+	// Archive pregnancies once the period after the recorded birth has passed
+	go apiHandler.RunBirthArchive()
+
+	// Complete pairing removals once their undo window has passed
+	go apiHandler.RunPairingCleanup()
+
+	// Keep cached partner, supporter and provider names in line with mvchat2
+	go apiHandler.RunProfileReconciliation(time.Duration(getEnvInt("PROFILE_RECONCILE_MINUTES", 60)) * time.Minute)
+
+	// Keep audit log partitions ahead of time and drop expired months
+	go apiHandler.RunAuditPartitions(getEnvInt("AUDIT_RETENTION_MONTHS", 24))
+
+	// Summarize long journal posts of consenting owners
+	if summarizer != nil {
+		go apiHandler.RunEntrySummaries()
+	}
+
+	// Call entry trigger webhooks for newly created entries
+	if webhooks != nil {
+		go apiHandler.RunEntryTriggers()
+	}
+
+	// Nudge users about entry types they track that went unlogged
+	go apiHandler.RunEntryReminders()
+
+	// Delete rate limit windows that no longer count
+	go apiHandler.RunRateLimitCleanup()
+
+	// Delete idempotency keys past their replay window
+	go apiHandler.RunIdempotencyCleanup()
+
+	// Tell owners about admin impersonation sessions once they end
+	go apiHandler.RunImpersonationNotices()
+
+	// Hard-delete entry tombstones past their retention (0 keeps them)
+	if days := getEnvInt("TOMBSTONE_RETENTION_DAYS", 180); days > 0 {
+		go apiHandler.RunTombstoneCompaction(time.Duration(days) * 24 * time.Hour)
+	}
 
 	// Set up router
 	r := mux.NewRouter()
+	r.Use(apiHandler.SLOMiddleware)
 
 	// Health check
 	r.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -76,86 +364,106 @@ func main() {
 		w.Write([]byte("OK"))
 	}).Methods("GET")
 
-	// Static data endpoints (no auth required)
-	r.HandleFunc("/api/data/baby-sizes", apiHandler.GetBabySizes).Methods("GET")
-	r.HandleFunc("/api/data/weekly-facts", apiHandler.GetWeeklyFacts).Methods("GET")
+	// Readiness: database reachable and circuit breaker not open
+	r.HandleFunc("/readyz", apiHandler.Readyz).Methods("GET")
+
+	// Unauthenticated routes, on a listener of their own when PUBLIC_PORT is set
+	publicPort := getEnv("PUBLIC_PORT", "")
+	public := r
+	if publicPort != "" {
+		public = mux.NewRouter()
+		public.Use(apiHandler.SLOMiddleware)
+	}
 
 	// API routes (all require authentication)
 	apiRouter := r.PathPrefix("/api").Subrouter()
+	apiRouter.Use(apiHandler.BreakerMiddleware)
 	apiRouter.Use(apiHandler.AuthMiddleware)
+	apiRouter.Use(apiHandler.FingerprintMiddleware)
+	apiRouter.Use(apiHandler.RateLimitMiddleware)
+	apiRouter.Use(apiHandler.BackpressureMiddleware)
+
+	// Static data endpoints (no auth required unless DATA_REQUIRE_AUTH)
+	dataRouter := public.PathPrefix("/api").Subrouter()
+	if os.Getenv("DATA_REQUIRE_AUTH") == "true" {
+		dataRouter = apiRouter
+	}
+	dataRouter.HandleFunc("/data/baby-sizes", apiHandler.GetBabySizes).Methods("GET")
+	dataRouter.HandleFunc("/data/weekly-facts", apiHandler.GetWeeklyFacts).Methods("GET")
+	dataRouter.HandleFunc("/data/timeline-key", apiHandler.GetTimelinePublicKey).Methods("GET")
 
-	// Pregnancy endpoints (legacy - single pregnancy)
-	apiRouter.HandleFunc("/pregnancy", apiHandler.GetPregnancy).Methods("GET")
-	apiRouter.HandleFunc("/pregnancy", apiHandler.CreatePregnancy).Methods("POST")
-	apiRouter.HandleFunc("/pregnancy", apiHandler.UpdatePregnancy).Methods("PUT")
-
-	// Multi-pregnancy endpoints
-	apiRouter.HandleFunc("/pregnancies", apiHandler.ListPregnancies).Methods("GET")
-	apiRouter.HandleFunc("/pregnancies/{id}", apiHandler.GetPregnancyByID).Methods("GET")
-	apiRouter.HandleFunc("/pregnancies/{id}", apiHandler.UpdatePregnancyByID).Methods("PUT")
-	apiRouter.HandleFunc("/pregnancies/{id}/entries", apiHandler.GetPregnancyEntries).Methods("GET")
-	apiRouter.HandleFunc("/pregnancies/{id}/outcome", apiHandler.SetPregnancyOutcome).Methods("PUT")
-	apiRouter.HandleFunc("/pregnancies/{id}/archive", apiHandler.SetPregnancyArchive).Methods("PUT")
-
-	// Entry endpoints
-	apiRouter.HandleFunc("/entries", apiHandler.GetEntries).Methods("GET")
-	apiRouter.HandleFunc("/entries", apiHandler.CreateEntry).Methods("POST")
-	apiRouter.HandleFunc("/entries/batch", apiHandler.BatchCreateEntries).Methods("POST")
-	apiRouter.HandleFunc("/entries/{clientId}", apiHandler.DeleteEntry).Methods("DELETE")
-
-	// Settings endpoints
-	apiRouter.HandleFunc("/settings", apiHandler.GetSettings).Methods("GET")
-	apiRouter.HandleFunc("/settings/{type}", apiHandler.UpdateSetting).Methods("PUT")
-
-	// Sync endpoints
-	apiRouter.HandleFunc("/sync", apiHandler.GetSync).Methods("GET")
-	apiRouter.HandleFunc("/sync", apiHandler.PostSync).Methods("POST")
-
-	// Pairing endpoints
-	apiRouter.HandleFunc("/pairing/request", apiHandler.CreatePairingRequest).Methods("POST")
-	apiRouter.HandleFunc("/pairing/pending", apiHandler.GetPendingPairingRequests).Methods("GET")
-	apiRouter.HandleFunc("/pairing/approve/{requestId}", apiHandler.ApprovePairingRequest).Methods("POST")
-	apiRouter.HandleFunc("/pairing/deny/{requestId}", apiHandler.DenyPairingRequest).Methods("POST")
-	apiRouter.HandleFunc("/pairing/permission", apiHandler.UpdatePartnerPermission).Methods("PUT")
-	apiRouter.HandleFunc("/pairing", apiHandler.RemovePairing).Methods("DELETE")
-	apiRouter.HandleFunc("/pairing/status", apiHandler.GetPairingStatus).Methods("GET")
-
-	// Sharing / Invite code endpoints
-	apiRouter.HandleFunc("/sharing/status", apiHandler.GetSharingStatus).Methods("GET")
-	apiRouter.HandleFunc("/sharing/generate", apiHandler.GenerateInviteCode).Methods("POST")
-	apiRouter.HandleFunc("/sharing/redeem", apiHandler.RedeemInviteCode).Methods("POST")
-	apiRouter.HandleFunc("/sharing/codes/{codeId}/revoke", apiHandler.RevokeInviteCode).Methods("POST")
-	apiRouter.HandleFunc("/sharing/supporters/{supporterId}", apiHandler.RemoveSupporter).Methods("DELETE")
-	apiRouter.HandleFunc("/me/role", apiHandler.GetMyRole).Methods("GET")
-
-	// File endpoints
-	apiRouter.HandleFunc("/files/upload", apiHandler.UploadFile).Methods("POST")
-	apiRouter.HandleFunc("/files/{fileId}", apiHandler.GetFile).Methods("GET")
-	apiRouter.HandleFunc("/files/{fileId}", apiHandler.DeleteFile).Methods("DELETE")
-
-	// Set up CORS
-	corsHandler := handlers.CORS(
-		handlers.AllowedOrigins([]string{corsOrigins}),
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"Authorization", "Content-Type"}),
-	)
+	// OpenAPI document of the /api routes, generated from the route registry
+	dataRouter.HandleFunc("/openapi.json", apiHandler.GetOpenAPI).Methods("GET")
+
+	// Signed file URLs (the signature is the credential)
+	if apiHandler.SurfaceEnabled(api.SurfaceSignedFiles) {
+		public.Handle("/api/signed/files/{fileId}", apiHandler.BreakerMiddleware(http.HandlerFunc(apiHandler.GetSignedFile))).Methods("GET")
+	}
+
+	// mvchat2 profile webhook (HMAC-signed with MVCHAT_WEBHOOK_SECRET)
+	if len(webhookSecret) > 0 {
+		public.Handle("/api/webhooks/mvchat2", apiHandler.BreakerMiddleware(http.HandlerFunc(apiHandler.ProfileWebhook))).Methods("POST")
+	}
+
+	// Supporter web widget (widget tokens only, rate-limited per token)
+	if apiHandler.SurfaceEnabled(api.SurfaceWidget) {
+		widgetRouter := public.PathPrefix("/api/widget").Subrouter()
+		widgetRouter.Use(apiHandler.BreakerMiddleware)
+		widgetRouter.Use(apiHandler.WidgetAuthMiddleware)
+		widgetRouter.HandleFunc("/sync", apiHandler.GetWidgetSync).Methods("GET")
+		widgetRouter.HandleFunc("/photos/{fileId}", apiHandler.GetWidgetPhoto).Methods("GET")
+	}
+
+	// Calendar feed URLs (the token in the path is the credential, rate-limited per feed)
+	if apiHandler.SurfaceEnabled(api.SurfaceCalendarFeeds) {
+		public.Handle("/api/calendar/{token}.ics", apiHandler.BreakerMiddleware(http.HandlerFunc(apiHandler.GetSubscribedCalendar))).Methods("GET")
+	}
+
+	// Routes and their per-route policies come from the registry in internal/api/routes.go
+	apiHandler.RegisterRoutes(apiRouter)
+
+	// Set up CORS (per route group when CORS_CONFIG is set)
+	corsConfig, err := loadCORSConfig(getEnv("CORS_CONFIG", ""), corsOrigins)
+	if err != nil {
+		log.Fatalf("Failed to load CORS config: %v", err)
+	}
 
 	// Create server
 	srv := &http.Server{
 		Addr:         ":" + port,
-		Handler:      corsHandler(r),
+		Handler:      corsConfig.corsHandler(r),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
-	// Start server in goroutine
+	// Server of the unauthenticated routes, if on a port of their own
+	var publicSrv *http.Server
+	if publicPort != "" {
+		publicSrv = &http.Server{
+			Addr:         ":" + publicPort,
+			Handler:      corsConfig.corsHandler(public),
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 15 * time.Second,
+			IdleTimeout:  60 * time.Second,
+		}
+	}
+
+	// Start servers in goroutines
 	go func() {
 		log.Printf("Tracker2API server starting on port %s", port)
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server error: %v", err)
 		}
 	}()
+	if publicSrv != nil {
+		go func() {
+			log.Printf("Public routes listening on port %s", publicPort)
+			if err := publicSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Public server error: %v", err)
+			}
+		}()
+	}
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
@@ -168,6 +476,11 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if publicSrv != nil {
+		if err := publicSrv.Shutdown(ctx); err != nil {
+			log.Printf("Public server forced to shutdown: %v", err)
+		}
+	}
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
@@ -192,3 +505,14 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		var result float64
+		_, err := fmt.Sscanf(value, "%g", &result)
+		if err == nil {
+			return result
+		}
+	}
+	return defaultValue
+}
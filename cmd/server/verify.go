@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/scalecode-solutions/tracker2api/internal/timeline"
+)
+
+// runVerifyTimeline checks a timeline export offline.
+//
+//	tracker2api verify-timeline [-pubkey <base64>] timeline.jsonl
+//
+// Without -pubkey the key embedded in the export is trusted; pass the key from
+// GET /api/data/timeline-key to prove the export came from this server.
+func runVerifyTimeline(args []string) int {
+	fs := flag.NewFlagSet("verify-timeline", flag.ContinueOnError)
+	pubKeyFlag := fs.String("pubkey", "", "base64 Ed25519 public key to verify against")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: tracker2api verify-timeline [-pubkey <base64>] <file.jsonl>")
+		return 2
+	}
+
+	var pub ed25519.PublicKey
+	if *pubKeyFlag != "" {
+		key, err := base64.StdEncoding.DecodeString(*pubKeyFlag)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			fmt.Fprintln(os.Stderr, "invalid -pubkey")
+			return 2
+		}
+		pub = key
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer f.Close()
+
+	res, err := timeline.Verify(f, pub)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "INVALID: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("OK: pregnancy %d, %d revisions, generated %s\n", res.Header.PregnancyID, res.Revisions, res.Header.GeneratedAt)
+	fmt.Printf("final hash %s\n", res.LastHash)
+	if pub == nil {
+		fmt.Println("warning: verified against the key embedded in the export; pass -pubkey to pin the server key")
+	}
+	return 0
+}
@@ -0,0 +1,138 @@
+// Package abuse screens partner pairing requests for spam before they reach
+// the target's inbox.
+//
+// A Detector is pluggable. The built-in Heuristics cap requests per requester
+// per day, refuse repeated requests to the same address and, when a
+// CaptchaVerifier is set, ask busy requesters to solve a CAPTCHA. SiteVerifier
+// speaks the siteverify protocol shared by reCAPTCHA, hCaptcha and Turnstile.
+package abuse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Reasons a request is blocked.
+const (
+	ReasonDailyCap        = "daily_cap"
+	ReasonDuplicateTarget = "duplicate_target"
+	ReasonCaptchaRequired = "captcha_required"
+	ReasonCaptchaFailed   = "captcha_failed"
+)
+
+// Attempt is a pairing request about to be created, with the requester's
+// recent history.
+type Attempt struct {
+	RequesterID  string
+	TargetEmail  string
+	CaptchaToken string // Sent by the client; "" if it showed no CAPTCHA
+	RemoteIP     string
+	SentToday    int // Requests the requester made in the last 24 hours
+	SameTarget   int // Requests to the same address still pending or made in the last 24 hours
+}
+
+// Verdict is the outcome of screening an attempt.
+type Verdict struct {
+	Allowed bool
+	Reason  string // Why it was blocked
+}
+
+// Detector screens pairing requests.
+type Detector interface {
+	Screen(ctx context.Context, a *Attempt) (*Verdict, error)
+}
+
+// CaptchaVerifier checks a CAPTCHA token the client obtained.
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token, remoteIP string) (bool, error)
+}
+
+// Heuristics blocks requesters over DailyCap requests a day and repeated
+// requests to one address. With Captcha set, requesters who already sent
+// CaptchaAfter requests that day must pass a CAPTCHA, and any token sent is
+// verified.
+type Heuristics struct {
+	DailyCap     int
+	CaptchaAfter int
+	Captcha      CaptchaVerifier // nil skips CAPTCHA checks
+}
+
+// Screen applies the heuristics. It returns an error only when the CAPTCHA
+// service can't be reached.
+func (h *Heuristics) Screen(ctx context.Context, a *Attempt) (*Verdict, error) {
+	if a.SameTarget > 0 {
+		return &Verdict{Reason: ReasonDuplicateTarget}, nil
+	}
+	if h.DailyCap > 0 && a.SentToday >= h.DailyCap {
+		return &Verdict{Reason: ReasonDailyCap}, nil
+	}
+	if h.Captcha == nil {
+		return &Verdict{Allowed: true}, nil
+	}
+
+	if a.CaptchaToken != "" {
+		ok, err := h.Captcha.Verify(ctx, a.CaptchaToken, a.RemoteIP)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return &Verdict{Reason: ReasonCaptchaFailed}, nil
+		}
+		return &Verdict{Allowed: true}, nil
+	}
+	if a.SentToday >= h.CaptchaAfter {
+		return &Verdict{Reason: ReasonCaptchaRequired}, nil
+	}
+	return &Verdict{Allowed: true}, nil
+}
+
+// SiteVerifier posts the token and secret as a form to URL, such as
+// https://challenges.cloudflare.com/turnstile/v0/siteverify, and reads
+// {"success": bool} back.
+type SiteVerifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// NewSiteVerifier creates a SiteVerifier with a request timeout.
+func NewSiteVerifier(url, secret string) *SiteVerifier {
+	return &SiteVerifier{URL: url, Secret: secret, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Verify asks the CAPTCHA service whether token is valid.
+func (v *SiteVerifier) Verify(ctx context.Context, token, remoteIP string) (bool, error) {
+	form := url.Values{"secret": {v.Secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("captcha service returned %s", resp.Status)
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("decode captcha result: %w", err)
+	}
+	return result.Success, nil
+}
@@ -0,0 +1,106 @@
+// Package api provides pregnancy access resolution and capability discovery.
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/scalecode-solutions/tracker2api/internal/auth"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// Entry types the apps write. Capabilities report a write flag for each.
+var knownEntryTypes = []string{
+	"weight", "symptom", "appointment", "journal", "water", "photo", "medical", "intimacy",
+	"baby_name", "kick_session", "contraction_session", "blood_pressure", "glucose", "milestone",
+	"meal",
+}
+
+// pregnancyAccess is how a user reaches a pregnancy.
+type pregnancyAccess struct {
+	pregnancy  *models.Pregnancy
+	role       string // owner, coowner, father, support
+	permission string // read or write
+}
+
+// resolveAccess finds the pregnancy the user can reach, checking roles in order
+// of precedence: owner, coowner, partner, supporter. It is a single query.
+func (h *Handler) resolveAccess(ctx context.Context, userID string) (*pregnancyAccess, error) {
+	pregnancy, role, permission, err := h.db.ResolvePregnancyAccess(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &pregnancyAccess{pregnancy, role, permission}, nil
+}
+
+// capabilities computes what the user may do, mirroring the checks the
+// handlers enforce. a is nil without pregnancy access; careRole is the user's
+// care notes role ("" when none).
+func capabilities(user *auth.UserInfo, a *pregnancyAccess, careRole string) models.CapabilitiesResponse {
+	// Read-only personal access tokens can't write anything
+	canWrite := user.TokenID == 0 || user.Scope == models.TokenScopeWrite
+	session := user.TokenID == 0
+
+	resp := models.CapabilitiesResponse{
+		Capabilities: map[string]bool{},
+		EntryTypes:   make(map[string]bool, len(knownEntryTypes)),
+	}
+	var role, permission string
+	archived := false
+	if a != nil {
+		role, permission = a.role, a.permission
+		archived = a.pregnancy.Archived
+		id := a.pregnancy.ID
+		resp.PregnancyID = &id
+		resp.Role, resp.Permission = role, permission
+	} else if careRole == "provider" {
+		resp.Role = careRole
+	}
+
+	write := canWrite && permission == "write"
+	owner := role == "owner"
+	ownerLevel := owner || role == "coowner"
+
+	resp.Capabilities["canCreatePregnancy"] = canWrite && a == nil && careRole == ""
+	resp.Capabilities["canEditPregnancy"] = write
+	resp.Capabilities["canWriteEntries"] = write
+	resp.Capabilities["canUploadFiles"] = write
+	resp.Capabilities["canExport"] = write
+	resp.Capabilities["canCreateMemoryBook"] = write
+	resp.Capabilities["canManageSharing"] = canWrite && owner
+	resp.Capabilities["canSetOutcome"] = canWrite && owner && !archived
+	resp.Capabilities["canArchive"] = canWrite && owner
+	resp.Capabilities["canEditDashboards"] = canWrite && ownerLevel
+	resp.Capabilities["canViewCareNotes"] = careRole != ""
+	resp.Capabilities["canWriteCareNotes"] = canWrite && careRole != ""
+	resp.Capabilities["canManageTokens"] = session
+
+	for _, t := range knownEntryTypes {
+		resp.EntryTypes[t] = write
+	}
+	return resp
+}
+
+// GetCapabilities reports which actions the caller may take, so clients can show
+// the right controls without duplicating the permission rules.
+func (h *Handler) GetCapabilities(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	a, err := h.resolveAccess(ctx, user.UserID)
+	if err != nil && err != db.ErrNotFound {
+		writeDomainError(w, err)
+		return
+	}
+
+	_, careRole, err := h.getCareNotePregnancy(ctx, user.UserID)
+	if err != nil && err != db.ErrNotFound {
+		writeDomainError(w, err)
+		return
+	}
+
+	resp := capabilities(user, a, careRole)
+	resp.Routes = h.allowedRoutes(user)
+	writeJSON(w, http.StatusOK, resp)
+}
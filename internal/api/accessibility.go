@@ -0,0 +1,158 @@
+// Package api provides accessibility metadata and simplified text for weekly content.
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// renderContent returns a version's data as clients see it, with its
+// accessibility metadata under "accessibility" and its sources and review.
+// With simplified set, fields that have a plain-language rewrite are replaced by it.
+func renderContent(ck contentKind, c *models.ContentVersion, simplified bool) (json.RawMessage, error) {
+	data := c.Data
+	meta := parseAccessibility(c.Accessibility)
+	if simplified && hasFields(c.Simplified) {
+		var err error
+		if data, err = overlayContent(data, c.Simplified); err != nil {
+			return nil, err
+		}
+		plain := parseAccessibility(c.SimplifiedAccessibility)
+		plain.Simplified = true
+		if plain.AltText == "" {
+			plain.AltText = meta.AltText
+		}
+		meta = plain
+	}
+	if meta.AltText == "" {
+		meta.AltText = defaultAltText(ck, data)
+	}
+	if meta.ReadingLevel == 0 {
+		meta.ReadingLevel = readingLevel(contentText(data))
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["accessibility"], _ = json.Marshal(meta)
+	addContentReview(ck, c, fields)
+	return json.Marshal(fields)
+}
+
+// prepareAccessibility fills in the reading level of text that has none set.
+func prepareAccessibility(a *models.ContentAccessibility, data json.RawMessage) json.RawMessage {
+	var meta models.ContentAccessibility
+	if a != nil {
+		meta = *a
+	}
+	meta.Simplified = false
+	if meta.ReadingLevel == 0 {
+		meta.ReadingLevel = readingLevel(contentText(data))
+	}
+	out, _ := json.Marshal(meta)
+	return out
+}
+
+func parseAccessibility(raw json.RawMessage) models.ContentAccessibility {
+	var meta models.ContentAccessibility
+	if len(raw) > 0 {
+		json.Unmarshal(raw, &meta)
+	}
+	return meta
+}
+
+// hasFields reports whether raw is a non-empty JSON object.
+func hasFields(raw json.RawMessage) bool {
+	var fields map[string]json.RawMessage
+	return json.Unmarshal(raw, &fields) == nil && len(fields) > 0
+}
+
+// defaultAltText describes a baby size's emoji when no alt text was written.
+func defaultAltText(ck contentKind, data json.RawMessage) string {
+	if ck.kind != models.ContentBabySize {
+		return ""
+	}
+	var item struct {
+		Size string `json:"size"`
+	}
+	if json.Unmarshal(data, &item) != nil || item.Size == "" {
+		return ""
+	}
+	article := "a "
+	if strings.ContainsAny(item.Size[:1], "aeiouAEIOU") {
+		article = "an "
+	}
+	return "Illustration of " + article + item.Size + ", about the size of your baby this week"
+}
+
+// contentText joins the prose of a content object: its strings and string lists.
+func contentText(data json.RawMessage) string {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return ""
+	}
+	var parts []string
+	for name, value := range fields {
+		if name == "emoji" || name == "measurementType" {
+			continue
+		}
+		var s string
+		var list []string
+		if json.Unmarshal(value, &s) == nil {
+			parts = append(parts, s)
+		} else if json.Unmarshal(value, &list) == nil {
+			parts = append(parts, list...)
+		}
+	}
+	return strings.Join(parts, ". ")
+}
+
+// readingLevel estimates the US school grade needed to read text, using the
+// Flesch-Kincaid grade formula. Empty text scores 0.
+func readingLevel(text string) float64 {
+	words, sentences, syllables := 0, 0, 0
+	for _, word := range strings.Fields(text) {
+		letters := strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) })
+		if letters != "" {
+			words++
+			syllables += countSyllables(strings.ToLower(letters))
+		}
+		if strings.ContainsAny(word, ".!?") {
+			sentences++
+		}
+	}
+	if words == 0 {
+		return 0
+	}
+	if sentences == 0 {
+		sentences = 1
+	}
+
+	grade := 0.39*float64(words)/float64(sentences) + 11.8*float64(syllables)/float64(words) - 15.59
+	return math.Max(0, math.Round(grade*10)/10)
+}
+
+// countSyllables approximates syllables as vowel groups, not counting a silent final e.
+func countSyllables(word string) int {
+	count := 0
+	inVowel := false
+	for _, r := range word {
+		vowel := strings.ContainsRune("aeiouy", r)
+		if vowel && !inVowel {
+			count++
+		}
+		inVowel = vowel
+	}
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && count > 1 {
+		count--
+	}
+	if count == 0 {
+		count = 1
+	}
+	return count
+}
@@ -0,0 +1,197 @@
+// Package api provides alt text on files: editing it, warning about images
+// uploaded without it, an audit of the ones still missing it, and suggestions
+// from a captioning provider.
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+const (
+	// maxAltTextLength caps alt text, in characters. Screen readers read it
+	// in one go, so anything longer belongs in a caption.
+	maxAltTextLength = 1000
+	// captionTimeout bounds a captioning request.
+	captionTimeout = 30 * time.Second
+)
+
+// cleanAltText trims alt text and turns line breaks and other control
+// characters into spaces. ok is false when it is too long.
+func cleanAltText(s string) (string, bool) {
+	s = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s))
+	return s, utf8.RuneCountInString(s) <= maxAltTextLength
+}
+
+// isImage reports whether a file of this MIME type is shown as an image.
+func isImage(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "image/")
+}
+
+// altTextWarnings flags an image uploaded without alt text. field names the
+// request field to set.
+func altTextWarnings(file *models.File, field string) []models.Warning {
+	if !isImage(file.MimeType.String) || file.AltText != "" {
+		return nil
+	}
+	return []models.Warning{{
+		Code:    "ALT_TEXT_MISSING",
+		Message: "Images need alt text for people using screen readers; add it with PATCH /api/files/" + strconv.FormatInt(file.ID, 10),
+		Field:   field,
+	}}
+}
+
+// getWritableFile loads the {fileId} file of the caller's pregnancy when they
+// may write to it. It writes the error response and returns nil otherwise.
+func (h *Handler) getWritableFile(w http.ResponseWriter, r *http.Request) *models.File {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	fileID, err := strconv.ParseInt(mux.Vars(r)["fileId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "File not found")
+		return nil
+	}
+	file, err := h.db.GetFile(ctx, fileID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "File not found")
+		return nil
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return nil
+	}
+
+	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err != nil && err != db.ErrNotFound {
+		writeDomainError(w, err)
+		return nil
+	}
+	if err == db.ErrNotFound || pregnancy.ID != file.PregnancyID || !canAccessFiles(pregnancy, user.UserID) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Access denied")
+		return nil
+	}
+	if permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return nil
+	}
+	return file
+}
+
+// UpdateFile sets a file's alt text.
+func (h *Handler) UpdateFile(w http.ResponseWriter, r *http.Request) {
+	file := h.getWritableFile(w, r)
+	if file == nil {
+		return
+	}
+
+	var req models.FileAltTextRequest
+	if err := decodeBody(r, &req); err != nil || req.AltText == nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "altText is required")
+		return
+	}
+	altText, ok := cleanAltText(*req.AltText)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("altText must be at most %d characters", maxAltTextLength))
+		return
+	}
+
+	updated, err := h.db.SetFileAltText(r.Context(), file.ID, altText)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "File not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// GetAltTextAudit lists the pregnancy's images that still lack alt text.
+func (h *Handler) GetAltTextAudit(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if !canAccessFiles(pregnancy, user.UserID) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Access denied")
+		return
+	}
+
+	missing, images, err := h.db.GetImagesMissingAltText(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, models.AltTextAuditResponse{
+		Images:    images,
+		Described: images - len(missing),
+		Missing:   missing,
+	})
+}
+
+// SuggestAltText asks the captioning provider to describe an image. The
+// suggestion is returned for the user to review and is not saved.
+func (h *Handler) SuggestAltText(w http.ResponseWriter, r *http.Request) {
+	if h.captioner == nil {
+		writeError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "Alt text suggestions are not configured")
+		return
+	}
+	file := h.getWritableFile(w, r)
+	if file == nil {
+		return
+	}
+	if !isImage(file.MimeType.String) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Alt text can only be suggested for images")
+		return
+	}
+
+	path, err := h.filePath(file)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to read file")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), captionTimeout)
+	defer cancel()
+	text, err := h.captioner.Caption(ctx, data, file.MimeType.String)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "SERVICE_UNAVAILABLE", fmt.Sprintf("Alt text suggestion failed: %v", err))
+		return
+	}
+	text, _ = cleanAltText(text)
+	if runes := []rune(text); len(runes) > maxAltTextLength {
+		text = string(runes[:maxAltTextLength])
+	}
+	writeJSON(w, http.StatusOK, models.AltTextSuggestion{FileID: file.ID, AltText: text})
+}
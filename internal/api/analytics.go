@@ -0,0 +1,279 @@
+// Package api provides analytics aggregation handlers.
+package api
+
+import (
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// Numeric payload fields must be plain keys.
+var aggregateFieldPattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,50}$`)
+
+// GetAggregate aggregates entries server-side for heatmaps and charts.
+// Query: type (required), groupBy=hourOfDay|dayOfWeek|week (required),
+// field (numeric payload key for avg/min/max), tz (IANA, default UTC).
+func (h *Handler) GetAggregate(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	entryType := q.Get("type")
+	if entryType == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "type is required")
+		return
+	}
+
+	groupBy := q.Get("groupBy")
+	if groupBy != db.GroupByHourOfDay && groupBy != db.GroupByDayOfWeek && groupBy != db.GroupByWeek {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "groupBy must be hourOfDay, dayOfWeek or week")
+		return
+	}
+
+	field := q.Get("field")
+	if field != "" && !aggregateFieldPattern.MatchString(field) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid field")
+		return
+	}
+
+	timezone := q.Get("tz")
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid timezone")
+		return
+	}
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if _, until, snoozed := activeSnooze(pregnancy, user.UserID, time.Now()); snoozed {
+		writeError(w, http.StatusForbidden, "SNOOZED", "Sharing is paused until "+until.Format(time.RFC3339))
+		return
+	}
+
+	var weekStart time.Time
+	if groupBy == db.GroupByWeek {
+		var ok bool
+		if weekStart, ok = pregnancyStart(pregnancy); !ok {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Pregnancy needs a due date or start date to group by week")
+			return
+		}
+	}
+
+	buckets, err := h.db.AggregateEntries(ctx, pregnancy.ID, entryType, groupBy, field, timezone, weekStart, entryAudience(pregnancy, user.UserID))
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if buckets == nil {
+		buckets = []models.AggregateBucket{}
+	}
+
+	writeNegotiated(w, r, http.StatusOK, models.AggregateResponse{
+		Type:     entryType,
+		GroupBy:  groupBy,
+		Field:    field,
+		Timezone: timezone,
+		Buckets:  buckets,
+	})
+}
+
+// Weight units accepted in entries and for output, as kilograms per unit.
+var weightUnits = map[string]float64{
+	"":       1,
+	"kg":     1,
+	"kgs":    1,
+	"lb":     0.45359237,
+	"lbs":    0.45359237,
+	"pound":  0.45359237,
+	"pounds": 0.45359237,
+}
+
+// weightStableKgPerWeek is the slope below which weight counts as stable.
+const weightStableKgPerWeek = 0.05
+
+// GetWeightAnalytics resamples weight entries per pregnancy week and reports
+// the trend and total gain. Pre-pregnancy weight is the last reading before the
+// pregnancy started unless given. Query: unit (kg or lb, default kg),
+// prePregnancyWeight (in unit).
+func (h *Handler) GetWeightAnalytics(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	unit := q.Get("unit")
+	if unit == "" {
+		unit = "kg"
+	}
+	if unit != "kg" && unit != "lb" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "unit must be kg or lb")
+		return
+	}
+	perUnit := weightUnits[unit]
+
+	var prePregnancyKg *float64
+	if v := q.Get("prePregnancyWeight"); v != "" {
+		weight, err := strconv.ParseFloat(v, 64)
+		if err != nil || weight <= 0 || math.IsInf(weight, 0) {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "prePregnancyWeight must be a positive number")
+			return
+		}
+		kg := weight * perUnit
+		prePregnancyKg = &kg
+	}
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if _, until, snoozed := activeSnooze(pregnancy, user.UserID, time.Now()); snoozed {
+		writeError(w, http.StatusForbidden, "SNOOZED", "Sharing is paused until "+until.Format(time.RFC3339))
+		return
+	}
+
+	start, ok := pregnancyStart(pregnancy)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Pregnancy needs a due date or start date for weight analytics")
+		return
+	}
+
+	readings, err := h.db.GetWeightReadings(ctx, pregnancy.ID, entryAudience(pregnancy, user.UserID))
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	resp := models.WeightAnalyticsResponse{
+		Unit:      unit,
+		StartDate: start.Format("2006-01-02"),
+		Weeks:     []models.WeightWeek{},
+	}
+	toUnit := func(kg float64) float64 { return math.Round(kg/perUnit*100) / 100 }
+
+	// Readings are oldest first: the last one before the start is the
+	// pre-pregnancy weight, the last one after it the current weight
+	var lastBefore, latest *models.WeightPoint
+	var weeks []int
+	byWeek := make(map[int]*models.WeightWeek)
+	var xs, ys []float64
+	for _, reading := range readings {
+		factor, ok := weightUnits[strings.ToLower(strings.TrimSpace(reading.Unit))]
+		if !ok {
+			resp.Skipped++
+			continue
+		}
+		point := &models.WeightPoint{At: reading.At, Weight: reading.Weight * factor}
+		if reading.At.Before(start) {
+			lastBefore = point
+			continue
+		}
+		latest = point
+
+		weeksIn := reading.At.Sub(start).Hours() / 24 / 7
+		week := int(weeksIn)
+		b, ok := byWeek[week]
+		if !ok {
+			b = &models.WeightWeek{Week: week, Min: point.Weight, Max: point.Weight}
+			byWeek[week] = b
+			weeks = append(weeks, week)
+		}
+		b.Count++
+		b.Avg += point.Weight // Summed here, averaged below
+		b.Min = math.Min(b.Min, point.Weight)
+		b.Max = math.Max(b.Max, point.Weight)
+		xs = append(xs, weeksIn)
+		ys = append(ys, point.Weight)
+	}
+
+	sort.Ints(weeks)
+	for _, week := range weeks {
+		b := byWeek[week]
+		resp.Weeks = append(resp.Weeks, models.WeightWeek{
+			Week:  week,
+			Count: b.Count,
+			Avg:   toUnit(b.Avg / float64(b.Count)),
+			Min:   toUnit(b.Min),
+			Max:   toUnit(b.Max),
+		})
+	}
+
+	if slope, ok := leastSquaresSlope(xs, ys); ok {
+		direction := "stable"
+		if slope >= weightStableKgPerWeek {
+			direction = "gaining"
+		} else if slope <= -weightStableKgPerWeek {
+			direction = "losing"
+		}
+		resp.Trend = &models.WeightTrend{
+			SlopePerWeek: math.Round(slope/perUnit*1000) / 1000,
+			Direction:    direction,
+			Readings:     len(xs),
+		}
+	}
+
+	if latest != nil {
+		resp.Latest = &models.WeightPoint{At: latest.At, Weight: toUnit(latest.Weight)}
+	}
+	if prePregnancyKg != nil {
+		resp.PrePregnancySource = "query"
+	} else if lastBefore != nil {
+		prePregnancyKg = &lastBefore.Weight
+		resp.PrePregnancySource = "entry"
+	}
+	if prePregnancyKg != nil {
+		pre := toUnit(*prePregnancyKg)
+		resp.PrePregnancyWeight = &pre
+		if latest != nil {
+			gain := toUnit(latest.Weight - *prePregnancyKg)
+			resp.TotalGain = &gain
+		}
+	}
+
+	writeNegotiated(w, r, http.StatusOK, resp)
+}
+
+// leastSquaresSlope fits y = a + bx and returns b. It needs at least two
+// distinct x values.
+func leastSquaresSlope(xs, ys []float64) (float64, bool) {
+	n := float64(len(xs))
+	if len(xs) < 2 {
+		return 0, false
+	}
+	var sumX, sumY float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+	var sxx, sxy float64
+	for i := range xs {
+		sxx += (xs[i] - meanX) * (xs[i] - meanX)
+		sxy += (xs[i] - meanX) * (ys[i] - meanY)
+	}
+	if sxx < 1e-9 {
+		return 0, false
+	}
+	return sxy / sxx, true
+}
@@ -3,21 +3,41 @@ package api
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/abuse"
 	"github.com/scalecode-solutions/tracker2api/internal/auth"
+	"github.com/scalecode-solutions/tracker2api/internal/caption"
+	"github.com/scalecode-solutions/tracker2api/internal/community"
 	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/integrations/nutrition"
 	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/pagination"
+	"github.com/scalecode-solutions/tracker2api/internal/moderation"
+	"github.com/scalecode-solutions/tracker2api/internal/preview"
+	"github.com/scalecode-solutions/tracker2api/internal/ratelimit"
+	"github.com/scalecode-solutions/tracker2api/internal/storage"
+	"github.com/scalecode-solutions/tracker2api/internal/summarize"
+	"github.com/scalecode-solutions/tracker2api/internal/trackerv1"
+	"github.com/scalecode-solutions/tracker2api/internal/msgpack"
+	"github.com/scalecode-solutions/tracker2api/internal/mvchat"
+	"github.com/scalecode-solutions/tracker2api/internal/notify"
+	"github.com/scalecode-solutions/tracker2api/internal/webhook"
 )
 
 type contextKey string
@@ -27,23 +47,144 @@ const userContextKey contextKey = "user"
 
 // Handler provides HTTP handlers for the API.
 type Handler struct {
-	db         *db.DB
-	auth       *auth.Authenticator
-	uploadPath string
-	dataPath   string
+	db          *db.DB
+	auth        *auth.Authenticator
+	storage     *storage.Regions
+	dataPath    string
+	timelineKey ed25519.PrivateKey
+	limiter     *ratelimit.Limiter
+	heavy       *heavyQueue
+	shedder     *loadShedder
+	slo         *sloRecorder
+	streams     *streamCounter
+
+	adminUserIDs     []string
+	legacyDeprecated time.Time
+	legacySunset     *time.Time
+	moderator        moderation.Moderator
+	fileURLKey       []byte
+	serverRegion     string
+
+	webhookSecret []byte // Verifies mvchat2 profile webhooks
+	storageQuota  int64  // Bytes per pregnancy at which uploads warn and batches are refused; 0 disables
+
+	previewer   preview.Runner // Renders PDF and video previews; nil disables them
+	syncV2Users []string       // Users in the sync v2 soft launch; "*" for everyone
+	chat        mvchat.Poster  // Posts progress messages to mvchat2; nil disables them
+
+	minSyncProtocol int // Oldest X-Sync-Protocol still served; older clients must upgrade
+
+	pairingScreen abuse.Detector // Screens pairing requests for spam
+
+	summarizer    summarize.Summarizer // Summarizes long journal posts; nil disables summaries
+	summaryMinLen int                  // Characters from which a journal post is summarized
+
+	foods nutrition.Provider // Food database for meal entries; nil disables nutrition lookups
+
+	triggerUsers []string       // Users in the entry trigger soft launch; "*" for everyone
+	webhooks     webhook.Sender // Calls entry trigger webhooks; nil disables triggers
+
+	invites notify.Sender // Sends batch invite codes by email and SMS; nil returns them unsent
+
+	captioner caption.Captioner // Suggests alt text for images; nil disables suggestions
+
+	v1Source trackerv1.Source // Pulls exports from the v1 tracker; nil accepts uploaded exports only
+
+	community community.Linker // Links weekly facts to community topics; nil serves none
+
+	disabledSurfaces map[string]bool // Surfaces (Surface*) this deployment doesn't serve
+
+	chaos *chaosFaults // Per-user failure injection for staging; nil disables it
+
+	birthArchiveDays int // Default days after birth before auto-archive; 0 never
+
+	snapshotsInFlight sync.Map // Pregnancy IDs whose sync snapshot is being regenerated
 }
 
 // New creates a new API handler.
-func New(database *db.DB, authenticator *auth.Authenticator, uploadPath string, dataPath string) *Handler {
+// uploads resolves file storage per data residency region and serverRegion is the
+// region this server runs in ("" disables cross-region checks). timelineKey signs timeline exports. adminUserIDs may view operator reports.
+// legacyDeprecated is announced on deprecated routes, and legacySunset too if set. moderator reviews shared
+// images and may be nil to skip moderation. fileURLKey signs profile photo URLs.
+// heavyPerUser caps how many exports, imports and jobs one user runs at once.
+// webhookSecret verifies profile webhooks from mvchat2. storageQuota is the
+// per-pregnancy file size, in bytes, past which uploads warn (0: never).
+// previewer renders file previews and may be nil to skip them. syncV2Users
+// may use sync v2 ("*": everyone). minSyncProtocol is the oldest sync protocol
+// served, clamped to those the server speaks. chat posts weekly progress
+// messages into mvchat2 and may be nil to skip them. birthArchiveDays is how long after the
+// birth a pregnancy is auto-archived unless its birth details say otherwise.
+// pairingScreen screens pairing requests for spam. summarizer summarizes
+// journal posts of at least summaryMinLen characters for owners who consented
+// and may be nil to disable summaries. foods looks up the foods meal entries
+// reference and may be nil to disable nutrition lookups. triggerUsers may set
+// up entry triggers ("*": everyone), whose webhooks are called through
+// webhooks, which may be nil to disable them. invites sends batch invite
+// codes and may be nil to leave sharing them to the owner. limits stores rate
+// limit counters. captioner suggests alt text for images and may be nil to
+// disable suggestions. v1Source pulls exports from the legacy v1 tracker
+// and may be nil to only accept exports in the request. community links
+// weekly facts to community service topics and may be nil to serve none.
+// disabledSurfaces lists optional surfaces (Surface*) not to serve. chaos enables per-user failure
+// injection and must only be set on staging.
+func New(database *db.DB, authenticator *auth.Authenticator, uploads *storage.Regions, serverRegion string, dataPath string, timelineKey ed25519.PrivateKey, adminUserIDs []string, legacyDeprecated time.Time, legacySunset *time.Time, moderator moderation.Moderator, fileURLKey []byte, heavyPerUser int, webhookSecret []byte, storageQuota int64, previewer preview.Runner, syncV2Users []string, minSyncProtocol int, chat mvchat.Poster, birthArchiveDays int, pairingScreen abuse.Detector, summarizer summarize.Summarizer, summaryMinLen int, foods nutrition.Provider, triggerUsers []string, webhooks webhook.Sender, invites notify.Sender, limits ratelimit.Store, captioner caption.Captioner, v1Source trackerv1.Source, communityLinker community.Linker, disabledSurfaces []string, chaos bool) *Handler {
+	var faults *chaosFaults
+	if chaos {
+		faults = newChaosFaults()
+	}
+	disabled := make(map[string]bool, len(disabledSurfaces))
+	for _, name := range disabledSurfaces {
+		disabled[name] = true
+	}
 	return &Handler{
-		db:         database,
-		auth:       authenticator,
-		uploadPath: uploadPath,
-		dataPath:   dataPath,
+		db:               database,
+		auth:             authenticator,
+		storage:          uploads,
+		serverRegion:     serverRegion,
+		dataPath:         dataPath,
+		timelineKey:      timelineKey,
+		limiter:          ratelimit.New(limits),
+		heavy:            newHeavyQueue(heavyPerUser),
+		shedder:          newLoadShedder(),
+		slo:              newSLORecorder(),
+		streams:          newStreamCounter(),
+		adminUserIDs:     adminUserIDs,
+		legacyDeprecated: legacyDeprecated,
+		legacySunset:     legacySunset,
+		moderator:        moderator,
+		fileURLKey:       fileURLKey,
+
+		webhookSecret: webhookSecret,
+		storageQuota:  storageQuota,
+
+		previewer:   previewer,
+		syncV2Users: syncV2Users,
+		chat:        chat,
+
+		minSyncProtocol: min(max(minSyncProtocol, syncProtocolLegacy), syncProtocolCurrent),
+
+		birthArchiveDays: birthArchiveDays,
+		pairingScreen:    pairingScreen,
+
+		summarizer:    summarizer,
+		summaryMinLen: summaryMinLen,
+
+		foods: foods,
+		chaos: faults,
+
+		triggerUsers: triggerUsers,
+		webhooks:     webhooks,
+
+		invites:   invites,
+		captioner: captioner,
+		v1Source:  v1Source,
+		community: communityLinker,
+
+		disabledSurfaces: disabled,
 	}
 }
 
-// AuthMiddleware validates JWT tokens.
+// AuthMiddleware validates mvchat2 JWTs and personal access tokens.
 func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
@@ -61,6 +202,28 @@ func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
 		// JWT tokens are passed as-is, no base64 decoding needed
 		tokenString := parts[1]
 
+		if strings.HasPrefix(tokenString, personalTokenPrefix) || strings.HasPrefix(tokenString, impersonationTokenPrefix) {
+			authenticate := h.authenticatePersonalToken
+			if strings.HasPrefix(tokenString, impersonationTokenPrefix) {
+				authenticate = h.authenticateImpersonation
+			}
+			userInfo, status, msg := authenticate(r, tokenString)
+			if userInfo == nil {
+				code := "UNAUTHORIZED"
+				switch status {
+				case http.StatusForbidden:
+					code = "FORBIDDEN"
+				case http.StatusInternalServerError:
+					code = "INTERNAL_ERROR"
+				}
+				writeError(w, status, code, msg)
+				return
+			}
+			ctx := context.WithValue(r.Context(), userContextKey, userInfo)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
 		userInfo, err := h.auth.ValidateToken(tokenString)
 		if err != nil {
 			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", err.Error())
@@ -87,7 +250,7 @@ func (h *Handler) GetPregnancy(w http.ResponseWriter, r *http.Request) {
 	pregnancy, err := h.db.GetPregnancyByOwner(ctx, user.UserID)
 	if err == nil {
 		resp := models.PregnancyResponse{
-			Pregnancy:  toPregnancyDTO(pregnancy),
+			Pregnancy:  h.toPregnancyDTO(pregnancy),
 			Role:       "owner",
 			Permission: "write",
 		}
@@ -96,7 +259,7 @@ func (h *Handler) GetPregnancy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != db.ErrNotFound {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -107,7 +270,7 @@ func (h *Handler) GetPregnancy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -117,7 +280,7 @@ func (h *Handler) GetPregnancy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := models.PregnancyResponse{
-		Pregnancy:  toPregnancyDTO(pregnancy),
+		Pregnancy:  h.toPregnancyDTO(pregnancy),
 		Role:       "partner",
 		Permission: permission,
 	}
@@ -136,18 +299,28 @@ func (h *Handler) CreatePregnancy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Region is fixed at creation; it decides where uploads are stored
+	if req.Region != nil {
+		region := strings.ToLower(strings.TrimSpace(*req.Region))
+		if !h.storage.Has(region) {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Unknown region: "+region)
+			return
+		}
+		req.Region = &region
+	}
+
 	pregnancy, err := h.db.CreatePregnancy(ctx, user.UserID, &req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
 	resp := models.PregnancyResponse{
-		Pregnancy:  toPregnancyDTO(pregnancy),
+		Pregnancy:  h.toPregnancyDTO(pregnancy),
 		Role:       "owner",
 		Permission: "write",
 	}
-	writeJSON(w, http.StatusCreated, resp)
+	writeJSONWarnings(w, http.StatusCreated, resp, dueDateWarnings(&req, time.Now()))
 }
 
 // UpdatePregnancy updates the pregnancy record.
@@ -161,7 +334,7 @@ func (h *Handler) UpdatePregnancy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -178,9 +351,10 @@ func (h *Handler) UpdatePregnancy(w http.ResponseWriter, r *http.Request) {
 
 	updated, err := h.db.UpdatePregnancy(ctx, pregnancy.ID, &req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
+	h.notifyPregnancyChanged(ctx, updated, user.UserID)
 
 	role := "owner"
 	if pregnancy.OwnerID != user.UserID {
@@ -188,11 +362,11 @@ func (h *Handler) UpdatePregnancy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := models.PregnancyResponse{
-		Pregnancy:  toPregnancyDTO(updated),
+		Pregnancy:  h.toPregnancyDTO(updated),
 		Role:       role,
 		Permission: permission,
 	}
-	writeJSON(w, http.StatusOK, resp)
+	writeJSONWarnings(w, http.StatusOK, resp, dueDateWarnings(&req, time.Now()))
 }
 
 // ListPregnancies lists all pregnancies the user has access to.
@@ -202,7 +376,7 @@ func (h *Handler) ListPregnancies(w http.ResponseWriter, r *http.Request) {
 
 	pregnancies, err := h.db.ListPregnanciesByUser(ctx, user.UserID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -220,7 +394,7 @@ func (h *Handler) ListPregnancies(w http.ResponseWriter, r *http.Request) {
 		}
 		pCopy := p // avoid closure issue
 		result = append(result, models.PregnancyWithRole{
-			Pregnancy:  toPregnancyDTO(&pCopy),
+			Pregnancy:  h.toPregnancyDTO(&pCopy),
 			Role:       role,
 			Permission: permission,
 		})
@@ -246,7 +420,7 @@ func (h *Handler) GetPregnancyByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -269,7 +443,7 @@ func (h *Handler) GetPregnancyByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := models.PregnancyResponse{
-		Pregnancy:  toPregnancyDTO(pregnancy),
+		Pregnancy:  h.toPregnancyDTO(pregnancy),
 		Role:       role,
 		Permission: permission,
 	}
@@ -293,7 +467,7 @@ func (h *Handler) UpdatePregnancyByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -328,16 +502,17 @@ func (h *Handler) UpdatePregnancyByID(w http.ResponseWriter, r *http.Request) {
 
 	updated, err := h.db.UpdatePregnancy(ctx, pregnancyID, &req)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
+	h.notifyPregnancyChanged(ctx, updated, user.UserID)
 
 	resp := models.PregnancyResponse{
-		Pregnancy:  toPregnancyDTO(updated),
+		Pregnancy:  h.toPregnancyDTO(updated),
 		Role:       role,
 		Permission: permission,
 	}
-	writeJSON(w, http.StatusOK, resp)
+	writeJSONWarnings(w, http.StatusOK, resp, dueDateWarnings(&req, time.Now()))
 }
 
 // GetPregnancyEntries gets all entries for a specific pregnancy.
@@ -357,7 +532,7 @@ func (h *Handler) GetPregnancyEntries(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -369,21 +544,32 @@ func (h *Handler) GetPregnancyEntries(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	entries, err := h.db.GetEntries(ctx, pregnancyID, "", nil, false)
+	// Nothing is synced while snoozed, so the sync version starts over
+	if _, until, snoozed := activeSnooze(pregnancy, user.UserID, time.Now()); snoozed {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"entries":      map[string][]models.Entry{},
+			"syncVersion":  0,
+			"snoozed":      true,
+			"snoozedUntil": until.Format(time.RFC3339),
+		})
+		return
+	}
+
+	entries, err := h.db.GetEntries(ctx, pregnancyID, "", nil, nil, false)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
 	// Group by type
 	entriesByType := make(map[string][]models.Entry)
-	for _, e := range entries {
+	for _, e := range visibleEntries(entries, entryAudience(pregnancy, user.UserID), nil) {
 		entriesByType[e.EntryType] = append(entriesByType[e.EntryType], e)
 	}
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"entries":     entriesByType,
-		"syncVersion": time.Now().UnixMilli(),
+		"syncVersion": pregnancy.SyncVersion,
 	})
 }
 
@@ -404,7 +590,7 @@ func (h *Handler) SetPregnancyOutcome(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -435,15 +621,25 @@ func (h *Handler) SetPregnancyOutcome(w http.ResponseWriter, r *http.Request) {
 
 	updated, err := h.db.SetPregnancyOutcome(ctx, pregnancyID, req.Outcome, req.OutcomeDate)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
 	resp := models.PregnancyResponse{
-		Pregnancy:  toPregnancyDTO(updated),
+		Pregnancy:  h.toPregnancyDTO(updated),
 		Role:       "owner",
 		Permission: "write",
 	}
+
+	// Ask for birth details until they are recorded
+	if req.Outcome == "birth" {
+		if _, err := h.db.GetBirthDetails(ctx, pregnancyID); err == db.ErrNotFound {
+			resp.FollowUp = birthFollowUp(pregnancyID)
+		} else if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+	}
 	writeJSON(w, http.StatusOK, resp)
 }
 
@@ -464,7 +660,7 @@ func (h *Handler) SetPregnancyArchive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -482,18 +678,64 @@ func (h *Handler) SetPregnancyArchive(w http.ResponseWriter, r *http.Request) {
 
 	updated, err := h.db.SetPregnancyArchive(ctx, pregnancyID, req.Archived)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
 	resp := models.PregnancyResponse{
-		Pregnancy:  toPregnancyDTO(updated),
+		Pregnancy:  h.toPregnancyDTO(updated),
 		Role:       "owner",
 		Permission: "write",
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// DeletePregnancy deletes a pregnancy with its entries, settings, files and
+// sharing. Owner only.
+func (h *Handler) DeletePregnancy(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	pregnancyID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid pregnancy ID")
+		return
+	}
+
+	pregnancy, err := h.db.GetPregnancyByID(ctx, pregnancyID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Pregnancy not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if pregnancy.OwnerID != user.UserID {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only owner can delete")
+		return
+	}
+
+	if isDryRun(r) {
+		preview, err := h.db.DryRunDeletePregnancy(ctx, pregnancyID, user.UserID)
+		writeDryRun(w, preview, err, "Pregnancy not found")
+		return
+	}
+
+	files, err := h.db.DeletePregnancy(ctx, pregnancyID, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Pregnancy not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	h.removeStoredFiles(files)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // Entry endpoints
 
 // GetEntries gets entries for the pregnancy.
@@ -507,7 +749,40 @@ func (h *Handler) GetEntries(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
+		return
+	}
+
+	// Owner paused sharing; sync version 0 so nothing is missed once it lifts
+	if _, until, snoozed := activeSnooze(pregnancy, user.UserID, time.Now()); snoozed && pagination.Requested(r) {
+		writeJSON(w, http.StatusOK, models.EntriesPage{
+			Page:         pagination.Page[models.Entry]{Items: []models.Entry{}},
+			Snoozed:      true,
+			SnoozedUntil: until.Format(time.RFC3339),
+		})
+		return
+	} else if snoozed {
+		writeJSON(w, http.StatusOK, models.EntriesResponse{
+			Entries:      []models.Entry{},
+			Snoozed:      true,
+			SnoozedUntil: until.Format(time.RFC3339),
+		})
+		return
+	}
+
+	audience := entryAudience(pregnancy, user.UserID)
+
+	// Upcoming scheduled items (planned appointments, tests) in date order
+	if r.URL.Query().Get("upcoming") == "true" {
+		entries, err := h.db.GetScheduledEntries(ctx, pregnancy.ID, "upcoming")
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, models.EntriesResponse{
+			Entries:     visibleEntries(entries, audience, nil),
+			SyncVersion: pregnancy.SyncVersion,
+		})
 		return
 	}
 
@@ -523,19 +798,123 @@ func (h *Handler) GetEntries(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	entries, err := h.db.GetEntries(ctx, pregnancy.ID, entryType, since, includeDeleted)
+	// Entries that happened at or after a time, however late they synced
+	var occurredSince *time.Time
+	if s := r.URL.Query().Get("occurredSince"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "occurredSince must be an RFC3339 timestamp")
+			return
+		}
+		occurredSince = &t
+	}
+
+	// Entries that happened in a period, by occurredAt or the payload date
+	var from, to *time.Time
+	var ok bool
+	if s := r.URL.Query().Get("from"); s != "" {
+		if from, ok = parseAuditTime(s, false); !ok {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "from must be an RFC3339 timestamp or YYYY-MM-DD date")
+			return
+		}
+	}
+	if s := r.URL.Query().Get("to"); s != "" {
+		if to, ok = parseAuditTime(s, true); !ok {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "to must be an RFC3339 timestamp or YYYY-MM-DD date")
+			return
+		}
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "from must be before to")
+		return
+	}
+
+	filters, msg := parseEntryFilters(r, entryType)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+
+	// Entries hidden since the last sync are only withdrawn where tombstones are asked for
+	withdrawSince := since
+	if !includeDeleted {
+		withdrawSince = nil
+	}
+
+	// Paged listing for history screens; sync clients keep getting everything
+	if pagination.Requested(r) {
+		params, ok := readPage(w, r)
+		if !ok {
+			return
+		}
+		entries, err := h.db.GetFilteredEntries(ctx, pregnancy.ID, entryType, since, occurredSince, from, to, includeDeleted, filters, &params)
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		page := pagination.NewPage(entries, params, entryCursor)
+		page.Items = visibleEntries(page.Items, audience, withdrawSince)
+		writeJSON(w, http.StatusOK, models.EntriesPage{
+			Page:        page,
+			SyncVersion: pregnancy.SyncVersion,
+		})
+		return
+	}
+
+	entries, err := h.db.GetFilteredEntries(ctx, pregnancy.ID, entryType, since, occurredSince, from, to, includeDeleted, filters, nil)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
 	resp := models.EntriesResponse{
-		Entries:     entries,
-		SyncVersion: time.Now().UnixMilli(),
+		Entries:     visibleEntries(entries, audience, withdrawSince),
+		SyncVersion: pregnancy.SyncVersion,
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// GetEntry returns one entry by clientId, deleted or not, for resolving a
+// conflict or opening a deep link. A clientId used by entries of several
+// types needs the type query parameter.
+func (h *Handler) GetEntry(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	clientID := mux.Vars(r)["clientId"]
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if _, until, snoozed := activeSnooze(pregnancy, user.UserID, time.Now()); snoozed {
+		writeError(w, http.StatusForbidden, "SNOOZED", "Sharing is paused until "+until.Format(time.RFC3339))
+		return
+	}
+
+	entries, err := h.db.GetEntriesByClientID(ctx, pregnancy.ID, clientID, r.URL.Query().Get("type"))
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	// Entries the viewer may not read are reported as missing, not forbidden
+	entries = visibleEntries(entries, entryAudience(pregnancy, user.UserID), nil)
+	switch len(entries) {
+	case 0:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Entry not found")
+	case 1:
+		writeJSON(w, http.StatusOK, entries[0])
+	default:
+		writeError(w, http.StatusConflict, "CONFLICT", "Entries of several types use this clientId; pass type")
+	}
+}
+
 // CreateEntry creates a new entry.
 func (h *Handler) CreateEntry(w http.ResponseWriter, r *http.Request) {
 	user := getUserInfo(r)
@@ -547,7 +926,7 @@ func (h *Handler) CreateEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -562,13 +941,42 @@ func (h *Handler) CreateEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	entry, err := h.db.UpsertEntry(ctx, pregnancy.ID, &req)
+	if msg := validateScheduledEntry(&req); msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+	now := time.Now()
+	if msg := validateOccurredAt(&req, now); msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+	if msg := validateDataVersion(&req); msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+	audience := entryAudience(pregnancy, user.UserID)
+	if msg := validateVisibility(&req, audience); msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+	h.noteUnknownDataVersions(r, []models.EntryRequest{req})
+	warnings := clampOccurredAt(&req, now, "occurredAt")
+
+	entry, err := h.db.UpsertEntry(ctx, pregnancy.ID, &req, audience)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Entry not found")
+		return
+	}
+	if err == db.ErrConflict {
+		writeError(w, http.StatusConflict, "CONFLICT", "The merge policy for "+req.EntryType+" keeps the stored entry")
+		return
+	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, entry)
+	writeJSONWarnings(w, http.StatusCreated, entry, warnings)
 }
 
 // BatchCreateEntries creates multiple entries.
@@ -582,7 +990,7 @@ func (h *Handler) BatchCreateEntries(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -597,21 +1005,160 @@ func (h *Handler) BatchCreateEntries(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var entries []models.Entry
-	for _, e := range req.Entries {
-		entry, err := h.db.UpsertEntry(ctx, pregnancy.ID, &e)
+	// Validate everything up front so a bad item never leaves the batch half-saved
+	audience := entryAudience(pregnancy, user.UserID)
+	results := make([]models.BatchEntryResult, len(req.Entries))
+	seen := make(map[string]int, len(req.Entries))
+	var valid []int
+	for i := range req.Entries {
+		e := &req.Entries[i]
+		results[i] = models.BatchEntryResult{Index: i, ClientID: e.ClientID, EntryType: e.EntryType}
+
+		msg := validateBatchEntry(e)
+		if msg == "" {
+			msg = validateVisibility(e, audience)
+		}
+		if msg == "" {
+			key := e.EntryType + "/" + e.ClientID
+			if first, dup := seen[key]; dup {
+				msg = fmt.Sprintf("duplicate of entry %d in this batch", first)
+			} else {
+				seen[key] = i
+			}
+		}
+		if msg != "" {
+			results[i].Status = models.BatchItemFailed
+			results[i].Reason = msg
+			continue
+		}
+		valid = append(valid, i)
+	}
+
+	h.noteUnknownDataVersions(r, req.Entries)
+
+	var warnings []models.Warning
+	now := time.Now()
+	for _, i := range valid {
+		warnings = append(warnings, clampOccurredAt(&req.Entries[i], now, fmt.Sprintf("entries[%d].occurredAt", i))...)
+	}
+
+	resp := models.BatchEntriesResponse{
+		Entries: []models.Entry{},
+		Results: results,
+	}
+
+	if !req.ContinueOnError {
+		if len(valid) < len(req.Entries) {
+			for _, i := range valid {
+				results[i].Status = models.BatchItemSkipped
+			}
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error":   models.ErrorDetail{Code: "VALIDATION_ERROR", Message: "Some entries are invalid; nothing was saved"},
+				"results": results,
+			})
+			return
+		}
+
+		entries, created, failed, err := h.db.BatchUpsertEntries(ctx, pregnancy.ID, req.Entries, audience)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			for i := range results {
+				results[i].Status = models.BatchItemSkipped
+			}
+			status, code := http.StatusInternalServerError, "INTERNAL_ERROR"
+			if failed >= 0 {
+				results[failed].Status = models.BatchItemFailed
+				results[failed].Reason = err.Error()
+				switch err {
+				case db.ErrConflict:
+					status, code = http.StatusConflict, "CONFLICT"
+					results[failed].Reason = mergePolicyKeptReason
+				case db.ErrNotFound:
+					status, code = http.StatusNotFound, "NOT_FOUND"
+				}
+			}
+			writeJSON(w, status, map[string]interface{}{
+				"error":   models.ErrorDetail{Code: code, Message: "Batch rolled back; nothing was saved"},
+				"results": results,
+			})
 			return
 		}
-		entries = append(entries, *entry)
+
+		for i := range entries {
+			setBatchResult(&results[i], &entries[i], created[i])
+		}
+		resp.Entries = entries
+		resp.SyncVersion = h.syncVersionAfter(ctx, pregnancy)
+		writeJSONWarnings(w, http.StatusCreated, resp, warnings)
+		return
 	}
 
-	resp := models.EntriesResponse{
-		Entries:     entries,
-		SyncVersion: time.Now().UnixMilli(),
+	// Best effort: valid entries are saved in one transaction, and one that
+	// fails is rolled back without taking the others with it
+	reqs := make([]*models.EntryRequest, len(valid))
+	for j, i := range valid {
+		reqs[j] = &req.Entries[i]
 	}
-	writeJSON(w, http.StatusCreated, resp)
+	entries, created, errs, err := h.db.BatchUpsertEachEntry(ctx, pregnancy.ID, reqs, audience)
+	if err != nil {
+		for _, i := range valid {
+			results[i].Status = models.BatchItemSkipped
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"error":   models.ErrorDetail{Code: "INTERNAL_ERROR", Message: "Batch rolled back; nothing was saved"},
+			"results": results,
+		})
+		return
+	}
+
+	failures := len(req.Entries) - len(valid)
+	for j, i := range valid {
+		if err := errs[j]; err != nil {
+			results[i].Status = models.BatchItemFailed
+			results[i].Reason = err.Error()
+			if err == db.ErrConflict {
+				results[i].Reason = mergePolicyKeptReason
+			}
+			failures++
+			continue
+		}
+		setBatchResult(&results[i], entries[j], created[j])
+		resp.Entries = append(resp.Entries, *entries[j])
+	}
+
+	status := http.StatusCreated
+	if failures > 0 {
+		status = http.StatusMultiStatus
+	}
+	resp.SyncVersion = h.syncVersionAfter(ctx, pregnancy)
+	writeJSONWarnings(w, status, resp, warnings)
+}
+
+// validateBatchEntry checks the fields a batch item needs before it is saved.
+func validateBatchEntry(e *models.EntryRequest) string {
+	if e.ClientID == "" || e.EntryType == "" {
+		return "clientId and entryType are required"
+	}
+	if len(e.ClientID) > 50 || len(e.EntryType) > 50 {
+		return "clientId and entryType must be at most 50 characters"
+	}
+	if len(e.Data) == 0 || !json.Valid(e.Data) {
+		return "data must be valid JSON"
+	}
+	if msg := validateDataVersion(e); msg != "" {
+		return msg
+	}
+	if msg := validateOccurredAt(e, time.Now()); msg != "" {
+		return msg
+	}
+	return validateScheduledEntry(e)
+}
+
+func setBatchResult(result *models.BatchEntryResult, entry *models.Entry, created bool) {
+	result.Status = models.BatchItemUpdated
+	if created {
+		result.Status = models.BatchItemCreated
+	}
+	result.Entry = entry
 }
 
 // DeleteEntry soft deletes an entry.
@@ -627,7 +1174,7 @@ func (h *Handler) DeleteEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -636,13 +1183,21 @@ func (h *Handler) DeleteEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.db.DeleteEntry(ctx, pregnancy.ID, clientID)
+	// Entries hidden from the caller are not found, for dry runs too
+	audience := entryAudience(pregnancy, user.UserID)
+	if isDryRun(r) {
+		preview, err := h.db.DryRunDeleteEntry(ctx, pregnancy.ID, clientID, audience)
+		writeDryRun(w, preview, err, "Entry not found")
+		return
+	}
+
+	err = h.db.DeleteEntry(ctx, pregnancy.ID, clientID, audience)
 	if err == db.ErrNotFound {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "Entry not found")
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -652,6 +1207,56 @@ func (h *Handler) DeleteEntry(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// DeleteEntries soft deletes many entries at once: those named in clientIds, or
+// every entry of entryType. Entries hidden from the caller are skipped.
+func (h *Handler) DeleteEntries(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return
+	}
+
+	var req models.EntryDeleteRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if (req.EntryType == "") == (len(req.ClientIDs) == 0) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Give either clientIds or entryType")
+		return
+	}
+	if len(req.ClientIDs) > maxVisibilityClientIDs {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("clientIds may hold at most %d entries", maxVisibilityClientIDs))
+		return
+	}
+
+	audience := entryAudience(pregnancy, user.UserID)
+	if isDryRun(r) {
+		preview, err := h.db.DryRunDeleteEntries(ctx, pregnancy.ID, req.ClientIDs, req.EntryType, audience)
+		writeDryRun(w, preview, err, "Entry not found")
+		return
+	}
+
+	deleted, err := h.db.DeleteEntries(ctx, pregnancy.ID, req.ClientIDs, req.EntryType, audience)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, models.EntryDeleteResponse{Deleted: deleted, DeletedAt: time.Now().Format(time.RFC3339)})
+}
+
 // Settings endpoints
 
 // GetSettings gets all settings.
@@ -665,13 +1270,13 @@ func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
 	settings, err := h.db.GetSettings(ctx, pregnancy.ID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -691,7 +1296,7 @@ func (h *Handler) UpdateSetting(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -705,10 +1310,14 @@ func (h *Handler) UpdateSetting(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Failed to read body")
 		return
 	}
+	if status, code, msg := checkSettingWrite(pregnancy, user.UserID, settingType, body); status != 0 {
+		writeError(w, status, code, msg)
+		return
+	}
 
 	err = h.db.UpsertSetting(ctx, pregnancy.ID, settingType, json.RawMessage(body))
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -725,14 +1334,33 @@ func (h *Handler) GetSync(w http.ResponseWriter, r *http.Request) {
 	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
 	if err == db.ErrNotFound {
 		// No pregnancy yet - return empty sync
-		writeJSON(w, http.StatusOK, models.SyncResponse{
-			SyncVersion: time.Now().UnixMilli(),
-			ServerTime:  time.Now().Format(time.RFC3339),
+		writeNegotiated(w, r, http.StatusOK, models.SyncResponse{
+			ServerTime: time.Now().Format(time.RFC3339),
 		})
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
+		return
+	}
+
+	sinceVersion, bySinceVersion, ok := sinceVersionParam(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "sinceVersion must be a sync version")
+		return
+	}
+
+	// Owner paused sharing; report the snooze start as server time and the
+	// client's own sync version, so the next incremental sync after it lifts
+	// picks up everything changed meanwhile
+	if start, until, snoozed := activeSnooze(pregnancy, user.UserID, time.Now()); snoozed {
+		writeNegotiated(w, r, http.StatusOK, models.SyncResponse{
+			Pregnancy:    h.toPregnancyDTO(pregnancy),
+			SyncVersion:  sinceVersion,
+			ServerTime:   start.Format(time.RFC3339),
+			Snoozed:      true,
+			SnoozedUntil: until.Format(time.RFC3339),
+		})
 		return
 	}
 
@@ -745,32 +1373,60 @@ func (h *Handler) GetSync(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Get all entries grouped by type
-	entries, err := h.db.GetEntries(ctx, pregnancy.ID, "", since, true)
+	// Get all entries the caller may see, grouped by type. sinceVersion wins
+	// over since; every entry changed after it counts as changed since.
+	var entries []models.Entry
+	if bySinceVersion {
+		entries, err = h.db.GetEntriesSinceVersion(ctx, pregnancy.ID, sinceVersion)
+		since = &time.Time{}
+	} else {
+		entries, err = h.db.GetEntries(ctx, pregnancy.ID, "", since, nil, true)
+	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
+	audience := entryAudience(pregnancy, user.UserID)
 
 	entriesByType := make(map[string][]models.Entry)
-	for _, e := range entries {
+	for _, e := range visibleEntries(entries, audience, since) {
 		entriesByType[e.EntryType] = append(entriesByType[e.EntryType], e)
 	}
 
 	settings, err := h.db.GetSettings(ctx, pregnancy.ID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
-	resp := models.SyncResponse{
-		Pregnancy:   toPregnancyDTO(pregnancy),
-		Entries:     entriesByType,
-		Settings:    settings,
-		SyncVersion: time.Now().UnixMilli(),
-		ServerTime:  time.Now().Format(time.RFC3339),
+	settingVersions, err := h.db.GetSettingVersions(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
 	}
-	writeJSON(w, http.StatusOK, resp)
+	settingRevisions, err := h.db.GetSettingRevisionIDs(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	summaries, err := h.entrySummaries(ctx, pregnancy.ID, audience, since)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	resp := models.SyncResponse{
+		Pregnancy:        h.toPregnancyDTO(pregnancy),
+		Entries:          entriesByType,
+		Settings:         settings,
+		SettingVersions:  settingVersions,
+		SettingRevisions: settingRevisions,
+		EntrySummaries:   summaries,
+		SyncVersion:      pregnancy.SyncVersion,
+		ServerTime:       time.Now().Format(time.RFC3339),
+		CompactedBefore:  compactedBefore(pregnancy),
+	}
+	writeNegotiated(w, r, http.StatusOK, resp)
 }
 
 // PostSync pushes local changes to server.
@@ -779,18 +1435,42 @@ func (h *Handler) PostSync(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	var req models.SyncRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeBody(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
 		return
 	}
+	now := time.Now()
+	for i := range req.Entries {
+		msg := validateDataVersion(&req.Entries[i])
+		if msg == "" {
+			msg = validateOccurredAt(&req.Entries[i], now)
+		}
+		if msg == "" && req.Entries[i].UpdatedAt != nil {
+			if _, err := time.Parse(time.RFC3339Nano, *req.Entries[i].UpdatedAt); err != nil {
+				msg = "updatedAt must be an RFC3339 timestamp"
+			}
+		}
+		if msg != "" {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Entry %d: %s", i, msg))
+			return
+		}
+	}
+	if msg := validateLastSyncVersion(ctx, req.LastSyncVersion); msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+	h.noteUnknownDataVersions(r, req.Entries)
 
 	// Get or create pregnancy
 	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
-	if err == db.ErrNotFound && req.Pregnancy != nil {
+	if err == db.ErrNotFound && req.Pregnancy != nil && req.DryRun {
+		// The dry run creates it in its own transaction
+		pregnancy, permission, err = &models.Pregnancy{OwnerID: user.UserID}, "write", nil
+	} else if err == db.ErrNotFound && req.Pregnancy != nil {
 		// Create new pregnancy
 		pregnancy, err = h.db.CreatePregnancy(ctx, user.UserID, req.Pregnancy)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			writeDomainError(w, err)
 			return
 		}
 		permission = "write"
@@ -798,7 +1478,7 @@ func (h *Handler) PostSync(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
 		return
 	} else if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -806,53 +1486,178 @@ func (h *Handler) PostSync(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
 		return
 	}
+	audience := entryAudience(pregnancy, user.UserID)
+	for i := range req.Entries {
+		if msg := validateVisibility(&req.Entries[i], audience); msg != "" {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Entry %d: %s", i, msg))
+			return
+		}
+	}
+	if _, ok := req.SettingsPatch[db.MergePolicySetting]; ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "merge_policy can't be patched; send the whole setting")
+		return
+	}
+	if data, ok := req.Settings[db.MergePolicySetting]; ok {
+		if status, code, msg := checkSettingWrite(pregnancy, user.UserID, db.MergePolicySetting, data); status != 0 {
+			writeError(w, status, code, msg)
+			return
+		}
+	}
+
+	if req.DryRun {
+		h.dryRunSync(w, r, pregnancy, audience, &req)
+		return
+	}
 
 	// Update pregnancy if provided
 	if req.Pregnancy != nil && pregnancy != nil {
 		pregnancy, err = h.db.UpdatePregnancy(ctx, pregnancy.ID, req.Pregnancy)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+			writeDomainError(w, err)
 			return
 		}
 	}
 
-	// Upsert entries
-	for _, e := range req.Entries {
-		_, err := h.db.UpsertEntry(ctx, pregnancy.ID, &e)
+	entryConflicts, err := h.syncEntries(ctx, pregnancy.ID, audience, &req)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	conflicts, settingVersions, err := h.syncSettings(ctx, pregnancy.ID, req.Settings, req.SettingsPatch)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	conflicts = append(entryConflicts, conflicts...)
+
+	// Update sync state
+	syncVersion := h.syncVersionAfter(ctx, pregnancy)
+	h.db.UpdateSyncState(ctx, user.UserID, req.DeviceID, syncVersion)
+
+	writeNegotiated(w, r, http.StatusOK, map[string]interface{}{
+		"success":         true,
+		"conflicts":       shapeConflicts(ctx, conflicts),
+		"settingVersions": settingVersions,
+		"syncVersion":     syncVersion,
+	})
+}
+
+// syncEntries upserts and deletes pushed entries. A change is checked against
+// the server state it is based on, the entry's own updatedAt or else the
+// request's lastSyncVersion. Entries changed on the server since then are not
+// written and are reported back with the server's copy, so the client can merge.
+// Without either the push is applied as is, as it was before conflict checks.
+// The owner's merge policy for an entry type can change both: stale pushes
+// may be overwritten or merged, and pushes without a base may be refused.
+func (h *Handler) syncEntries(ctx context.Context, pregnancyID int64, audience []string, req *models.SyncRequest) ([]models.SyncConflict, error) {
+	lastSync := syncBase(req.LastSyncVersion)
+
+	conflicts := []models.SyncConflict{}
+	failAt := chaosSyncFailAt(ctx, len(req.Entries))
+	for i := range req.Entries {
+		if i == failAt {
+			return nil, errChaosSync
+		}
+		e := &req.Entries[i]
+		// A zero base applies the push as is, unless the merge policy says otherwise
+		current, err := h.db.UpsertEntryUnlessChanged(ctx, pregnancyID, e, entryBase(e, lastSync), audience)
+		if err == db.ErrConflict {
+			conflicts = append(conflicts, entryConflict(current, audience))
+			continue
+		}
+		if err == db.ErrNotFound {
+			// Hidden from the pusher; skipped like deletions of unknown entries
+			continue
+		}
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-			return
+			return nil, err
 		}
 	}
+	if failAt == len(req.Entries) {
+		// Pushes without entries fail before their deletions
+		return nil, errChaosSync
+	}
 
-	// Delete entries
 	for _, clientID := range req.DeletedEntries {
-		h.db.DeleteEntry(ctx, pregnancy.ID, clientID)
+		if lastSync.IsZero() {
+			h.db.DeleteEntry(ctx, pregnancyID, clientID, audience)
+			continue
+		}
+		changed, err := h.db.DeleteEntryUnlessChanged(ctx, pregnancyID, clientID, lastSync, audience)
+		if err == db.ErrConflict {
+			for j := range changed {
+				conflicts = append(conflicts, entryConflict(&changed[j], audience))
+			}
+			continue
+		}
+		if err != nil && err != db.ErrNotFound {
+			return nil, err
+		}
 	}
+	return conflicts, nil
+}
 
-	// Update settings
-	for settingType, data := range req.Settings {
-		err := h.db.UpsertSetting(ctx, pregnancy.ID, settingType, data)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-			return
-		}
+// entryBase is the base of a pushed entry: its own updatedAt, validated by
+// PostSync, or else the push's lastSyncVersion.
+func entryBase(e *models.EntryRequest, lastSync db.SyncBase) db.SyncBase {
+	if e.UpdatedAt == nil {
+		return lastSync
 	}
+	at, _ := time.Parse(time.RFC3339Nano, *e.UpdatedAt)
+	return db.SyncBase{At: at}
+}
 
-	// Update sync state
-	syncVersion := time.Now().UnixMilli()
-	h.db.UpdateSyncState(ctx, user.UserID, req.DeviceID, syncVersion)
+// entryConflict reports a pushed entry the server kept. Entries hidden from the
+// pusher come back as their tombstone, without the server's data.
+func entryConflict(e *models.Entry, audience []string) models.SyncConflict {
+	if !canSeeEntry(audience, e.Visibility) {
+		withdrawn := withdrawnEntry(e)
+		e = &withdrawn
+	}
+	return models.SyncConflict{
+		Kind:          "entry",
+		Key:           e.ClientID,
+		EntryType:     e.EntryType,
+		ServerVersion: e.SyncVersion,
+		ServerData:    e.Data,
+		ServerEntry:   e,
+	}
+}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{
-		"success":     true,
-		"conflicts":   []interface{}{},
-		"syncVersion": syncVersion,
-	})
+// syncSettings stores pushed settings, then applies settings deltas. Deltas
+// against stale base versions are reported back instead of applied.
+func (h *Handler) syncSettings(ctx context.Context, pregnancyID int64, settings map[string]json.RawMessage, patches map[string]models.SettingPatch) ([]models.SyncConflict, map[string]int64, error) {
+	for settingType, data := range settings {
+		if err := h.db.UpsertSetting(ctx, pregnancyID, settingType, data); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	conflicts := []models.SyncConflict{}
+	settingVersions := make(map[string]int64)
+	for settingType, p := range patches {
+		setting, err := h.db.PatchSetting(ctx, pregnancyID, settingType, p.BaseVersion, p.Patch)
+		if err == db.ErrConflict {
+			conflicts = append(conflicts, models.SyncConflict{
+				Kind:          "setting",
+				Key:           settingType,
+				ServerVersion: setting.Version,
+				ServerData:    setting.Data,
+			})
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		settingVersions[settingType] = setting.Version
+	}
+	return conflicts, settingVersions, nil
 }
 
 // Pairing endpoints
 
-// CreatePairingRequest creates a new pairing request.
+// CreatePairingRequest creates a new pairing request once it passes the spam checks.
 func (h *Handler) CreatePairingRequest(w http.ResponseWriter, r *http.Request) {
 	user := getUserInfo(r)
 	ctx := r.Context()
@@ -868,9 +1673,30 @@ func (h *Handler) CreatePairingRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	targetID, err := h.db.FindUserIDByEmail(ctx, req.TargetEmail)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if targetID.Valid {
+		blocked, err := h.db.IsUserBlocked(ctx, targetID.String, user.UserID)
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		if blocked {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "This user is not accepting pairing requests from you")
+			return
+		}
+	}
+
+	if !h.screenPairingRequest(w, r, &req) {
+		return
+	}
+
 	pr, err := h.db.CreatePairingRequest(ctx, user.UserID, req.RequesterName, req.TargetEmail)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -888,7 +1714,7 @@ func (h *Handler) GetPendingPairingRequests(w http.ResponseWriter, r *http.Reque
 
 	requests, err := h.db.GetPendingPairingRequests(ctx, user.UserID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -919,7 +1745,7 @@ func (h *Handler) ApprovePairingRequest(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -939,7 +1765,7 @@ func (h *Handler) DenyPairingRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -968,29 +1794,45 @@ func (h *Handler) UpdatePartnerPermission(w http.ResponseWriter, r *http.Request
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
-// RemovePairing removes a pairing.
+// RemovePairing removes a pairing. Approved pairings are removed after a
+// cooling-off period during which the requester can undo; see pairing.go.
 func (h *Handler) RemovePairing(w http.ResponseWriter, r *http.Request) {
 	user := getUserInfo(r)
 	ctx := r.Context()
 
-	err := h.db.RemovePairing(ctx, user.UserID)
+	pregnancy, pending, err := h.db.RequestPairingRemoval(ctx, user.UserID)
 	if err == db.ErrNotFound {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pairing found")
 		return
 	}
+	if err == db.ErrConflict {
+		writeError(w, http.StatusConflict, "CONFLICT", "Pairing removal already pending")
+		return
+	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+	if !pending {
+		writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+		return
+	}
+
+	removeAt := pregnancy.PartnerRemovalRequestedAt.Time.Add(pairingRemovalWindow)
+	h.notifyPairing(ctx, pregnancy, "pairing_removal_pending", user.UserID, removeAt)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"success":  true,
+		"pending":  true,
+		"removeAt": removeAt.UTC().Format(time.RFC3339),
+	})
 }
 
 // GetPairingStatus gets current pairing status.
@@ -1008,16 +1850,21 @@ func (h *Handler) GetPairingStatus(w http.ResponseWriter, r *http.Request) {
 		if pregnancy.PartnerID.Valid {
 			resp.Partner = &models.PartnerInfo{
 				ID:         pregnancy.PartnerID.String,
+				Name:       pregnancy.PartnerName.String,
 				Permission: pregnancy.PartnerPermission.String,
 				PairedAt:   pregnancy.UpdatedAt.Format(time.RFC3339),
 			}
 		}
+		setPairingRemoval(&resp, pregnancy, user.UserID)
 		writeJSON(w, http.StatusOK, resp)
 		return
 	}
 
-	// Check as partner
+	// Check as partner; a pending removal still shows the pairing
 	pregnancy, err = h.db.GetPregnancyByPartner(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		pregnancy, err = h.db.GetPairingRemoval(ctx, user.UserID)
+	}
 	if err == db.ErrNotFound {
 		writeJSON(w, http.StatusOK, models.PairingStatusResponse{
 			Paired: false,
@@ -1026,7 +1873,7 @@ func (h *Handler) GetPairingStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1039,6 +1886,7 @@ func (h *Handler) GetPairingStatus(w http.ResponseWriter, r *http.Request) {
 			PairedAt:   pregnancy.UpdatedAt.Format(time.RFC3339),
 		},
 	}
+	setPairingRemoval(&resp, pregnancy, user.UserID)
 	writeJSON(w, http.StatusOK, resp)
 }
 
@@ -1056,7 +1904,14 @@ func (h *Handler) GetSharingStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
+		return
+	}
+
+	// Cached mvchat2 profiles for avatars
+	profiles, err := h.db.GetPregnancyProfiles(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1069,6 +1924,8 @@ func (h *Handler) GetSharingStatus(w http.ResponseWriter, r *http.Request) {
 		}
 		partner = &models.PartnerInfo{
 			ID:                 pregnancy.PartnerID.String,
+			Name:               pregnancy.PartnerName.String,
+			AvatarURL:          profileAvatar(profiles, pregnancy.PartnerID.String),
 			Permission:         pregnancy.PartnerPermission.String,
 			PairedAt:           pregnancy.UpdatedAt.Format(time.RFC3339),
 			DisplayPartnerCard: displayCard,
@@ -1078,7 +1935,14 @@ func (h *Handler) GetSharingStatus(w http.ResponseWriter, r *http.Request) {
 	// Get supporters
 	supporters, err := h.db.GetSupporters(ctx, pregnancy.ID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
+		return
+	}
+
+	// Engagement of supporters who share it, from the access log
+	engagement, err := h.db.GetSupporterEngagement(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1092,19 +1956,46 @@ func (h *Handler) GetSharingStatus(w http.ResponseWriter, r *http.Request) {
 		if s.DisplayPartnerCard.Valid {
 			displayCard = s.DisplayPartnerCard.Bool
 		}
-		supporterInfos = append(supporterInfos, models.SupporterInfo{
+		info := models.SupporterInfo{
 			ID:                 s.ID,
 			UserID:             s.UserID,
 			DisplayName:        displayName,
+			AvatarURL:          profileAvatar(profiles, s.UserID),
 			JoinedAt:           s.JoinedAt.Format(time.RFC3339),
 			DisplayPartnerCard: displayCard,
+		}
+		if e, ok := engagement[s.UserID]; ok {
+			info.ActivityShared = true
+			info.ViewCount = &e.Visits
+			if e.LastViewedAt.Valid {
+				lastViewed := e.LastViewedAt.Time.Format(time.RFC3339)
+				info.LastViewedAt = &lastViewed
+			}
+		}
+		supporterInfos = append(supporterInfos, info)
+	}
+
+	// Get care providers
+	providers, err := h.db.GetCareProviders(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	providerInfos := make([]models.ProviderInfo, 0, len(providers))
+	for _, p := range providers {
+		providerInfos = append(providerInfos, models.ProviderInfo{
+			ID:          p.ID,
+			UserID:      p.UserID,
+			DisplayName: p.DisplayName.String,
+			JoinedAt:    p.JoinedAt.Format(time.RFC3339),
 		})
 	}
 
 	// Get active codes
 	codes, err := h.db.GetActiveInviteCodes(ctx, pregnancy.ID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1120,9 +2011,15 @@ func (h *Handler) GetSharingStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := models.SharingStatus{
-		Partner:     partner,
-		Supporters:  supporterInfos,
-		ActiveCodes: activeCodeInfos,
+		Partner:      partner,
+		Supporters:   supporterInfos,
+		Providers:    providerInfos,
+		ActiveCodes:  activeCodeInfos,
+		ActivityNote: engagementNote,
+	}
+	if pregnancy.SharingSnoozedUntil.Valid && pregnancy.SharingSnoozedUntil.Time.After(time.Now()) {
+		until := pregnancy.SharingSnoozedUntil.Time.Format(time.RFC3339)
+		resp.SnoozedUntil = &until
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
@@ -1139,7 +2036,7 @@ func (h *Handler) GenerateInviteCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1150,8 +2047,8 @@ func (h *Handler) GenerateInviteCode(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate role
-	if req.Role != "father" && req.Role != "support" {
-		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Role must be 'father' or 'support'")
+	if req.Role != "father" && req.Role != "support" && req.Role != "provider" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Role must be 'father', 'support' or 'provider'")
 		return
 	}
 
@@ -1171,25 +2068,31 @@ func (h *Handler) GenerateInviteCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	message, msg := SanitizeInviteMessage(req.Message)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+
 	// Generate code
 	code, err := GenerateInviteCode()
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
 	// Hash code for storage
 	codeHash, err := HashCode(code)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
 	// Save code
 	expiresAt := time.Now().Add(CodeExpiration)
-	codeRecord, err := h.db.CreateInviteCode(ctx, pregnancy.ID, codeHash, GetCodePrefix(code), req.Role, permission, expiresAt)
+	codeRecord, err := h.db.CreateInviteCode(ctx, pregnancy.ID, codeHash, GetCodePrefix(code), req.Role, permission, expiresAt, message)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1197,71 +2100,129 @@ func (h *Handler) GenerateInviteCode(w http.ResponseWriter, r *http.Request) {
 		Code:      code,
 		ExpiresAt: codeRecord.ExpiresAt,
 		Role:      req.Role,
+		Message:   codeRecord.Message.String,
 	}
 	writeJSON(w, http.StatusCreated, resp)
 }
 
-// RedeemInviteCode redeems an invite code.
-func (h *Handler) RedeemInviteCode(w http.ResponseWriter, r *http.Request) {
+// matchInviteCode finds the active invite code the user entered. Failed
+// attempts count against the user's limit of 5 per hour. It writes the error
+// response and returns nil when there is no match.
+func (h *Handler) matchInviteCode(w http.ResponseWriter, r *http.Request, code string) *models.InviteCode {
 	user := getUserInfo(r)
 	ctx := r.Context()
 
-	var req models.RedeemCodeRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
-		return
-	}
-
-	// Rate limit check (5 attempts per hour)
-	attempts, err := h.db.CountRecentCodeAttempts(ctx, user.UserID)
-	if err == nil && attempts >= 5 {
+	// Rate limit check (5 failed attempts per hour)
+	attempts, err := h.limiter.Peek(ctx, codeAttemptKey(user.UserID), codeAttemptLimit)
+	if err == nil && attempts.Remaining == 0 {
 		writeError(w, http.StatusTooManyRequests, "RATE_LIMITED", "Too many attempts. Try again later.")
-		return
+		return nil
 	}
 
 	// Validate code format
-	if !IsValidCodeFormat(req.Code) {
-		h.db.RecordCodeAttempt(ctx, user.UserID, false, r.RemoteAddr)
+	if !IsValidCodeFormat(code) {
+		h.recordFailedCodeAttempt(ctx, user.UserID)
 		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid code format")
-		return
+		return nil
 	}
 
 	// Find matching code by iterating through active codes
 	activeCodes, err := h.db.FindActiveInviteCodes(ctx)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-		return
+		writeDomainError(w, err)
+		return nil
 	}
 
-	var matchedCode *models.InviteCode
 	for _, c := range activeCodes {
-		if VerifyCode(req.Code, c.CodeHash) {
-			matchedCode = &c
-			break
+		if VerifyCode(code, c.CodeHash) {
+			return &c
 		}
 	}
 
+	h.recordFailedCodeAttempt(ctx, user.UserID)
+	writeError(w, http.StatusNotFound, "NOT_FOUND", "Invalid or expired code")
+	return nil
+}
+
+// PreviewInviteCode shows what a code connects to, with the owner's welcome
+// message, without redeeming it.
+func (h *Handler) PreviewInviteCode(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req models.PreviewCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	matchedCode := h.matchInviteCode(w, r, req.Code)
 	if matchedCode == nil {
-		h.db.RecordCodeAttempt(ctx, user.UserID, false, r.RemoteAddr)
+		return
+	}
+
+	pregnancy, err := h.db.GetPregnancyByID(ctx, matchedCode.PregnancyID)
+	if err == db.ErrNotFound {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "Invalid or expired code")
 		return
 	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.PreviewCodeResponse{
+		Role:       matchedCode.Role,
+		Permission: matchedCode.Permission,
+		MomName:    pregnancy.MomName.String,
+		BabyName:   pregnancy.BabyName.String,
+		ExpiresAt:  matchedCode.ExpiresAt,
+		Message:    matchedCode.Message.String,
+	})
+}
+
+// RedeemInviteCode redeems an invite code.
+func (h *Handler) RedeemInviteCode(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	var req models.RedeemCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	matchedCode := h.matchInviteCode(w, r, req.Code)
+	if matchedCode == nil {
+		return
+	}
+
+	blocked, err := h.db.IsBlockedByPregnancy(ctx, matchedCode.PregnancyID, user.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if blocked {
+		h.recordFailedCodeAttempt(ctx, user.UserID)
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "You cannot redeem this code")
+		return
+	}
 
 	// Redeem the code (email is used to check for admin access)
 	pregnancy, actualPermission, err := h.db.RedeemInviteCode(ctx, matchedCode.ID, user.UserID, req.DisplayName, req.Email)
 	if err == db.ErrNotFound {
-		h.db.RecordCodeAttempt(ctx, user.UserID, false, r.RemoteAddr)
+		h.recordFailedCodeAttempt(ctx, user.UserID)
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "Code already redeemed or expired")
 		return
 	}
 	if err != nil {
-		h.db.RecordCodeAttempt(ctx, user.UserID, false, r.RemoteAddr)
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		h.recordFailedCodeAttempt(ctx, user.UserID)
+		writeDomainError(w, err)
 		return
 	}
 
-	// Record successful attempt
-	h.db.RecordCodeAttempt(ctx, user.UserID, true, r.RemoteAddr)
+	if pregnancy.CoownerID.Valid && pregnancy.CoownerID.String == user.UserID {
+		h.notifyCoowner(ctx, pregnancy.OwnerID, pregnancy.ID, "coowner_linked", user.UserID)
+	}
 
 	// Build response
 	dueDate := ""
@@ -1281,10 +2242,11 @@ func (h *Handler) RedeemInviteCode(w http.ResponseWriter, r *http.Request) {
 		Success:    true,
 		Role:       matchedCode.Role,
 		Permission: actualPermission,
-		Pregnancy:  toPregnancyDTO(pregnancy),
+		Pregnancy:  h.toPregnancyDTO(pregnancy),
 		MomName:    momName,
 		BabyName:   babyName,
 		DueDate:    dueDate,
+		Message:    matchedCode.Message.String,
 	}
 	writeJSON(w, http.StatusOK, resp)
 }
@@ -1306,7 +2268,7 @@ func (h *Handler) RevokeInviteCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1324,13 +2286,19 @@ func (h *Handler) RemoveSupporter(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isDryRun(r) {
+		preview, err := h.db.DryRunRemoveSupporter(ctx, supporterID, user.UserID)
+		writeDryRun(w, preview, err, "Supporter not found")
+		return
+	}
+
 	err = h.db.RemoveSupporter(ctx, supporterID, user.UserID)
 	if err == db.ErrNotFound {
 		writeError(w, http.StatusNotFound, "NOT_FOUND", "Supporter not found")
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1348,13 +2316,13 @@ func (h *Handler) GetMyRole(w http.ResponseWriter, r *http.Request) {
 		resp := models.MyRoleResponse{
 			Role:       "owner",
 			Permission: "write",
-			Pregnancy:  toPregnancyDTO(pregnancy),
+			Pregnancy:  h.toPregnancyDTO(pregnancy),
 		}
 		writeJSON(w, http.StatusOK, resp)
 		return
 	}
 	if err != nil && err != db.ErrNotFound {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1364,13 +2332,13 @@ func (h *Handler) GetMyRole(w http.ResponseWriter, r *http.Request) {
 		resp := models.MyRoleResponse{
 			Role:       "coowner",
 			Permission: "write",
-			Pregnancy:  toPregnancyDTO(pregnancy),
+			Pregnancy:  h.toPregnancyDTO(pregnancy),
 		}
 		writeJSON(w, http.StatusOK, resp)
 		return
 	}
 	if err != nil && err != db.ErrNotFound {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1384,13 +2352,13 @@ func (h *Handler) GetMyRole(w http.ResponseWriter, r *http.Request) {
 		resp := models.MyRoleResponse{
 			Role:       "father",
 			Permission: permission,
-			Pregnancy:  toPregnancyDTO(pregnancy),
+			Pregnancy:  h.toPregnancyDTO(pregnancy),
 		}
 		writeJSON(w, http.StatusOK, resp)
 		return
 	}
 	if err != nil && err != db.ErrNotFound {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1406,13 +2374,13 @@ func (h *Handler) GetMyRole(w http.ResponseWriter, r *http.Request) {
 		resp := models.MyRoleResponse{
 			Role:       "support",
 			Permission: permission,
-			Pregnancy:  toPregnancyDTO(pregnancy),
+			Pregnancy:  h.toPregnancyDTO(pregnancy),
 		}
 		writeJSON(w, http.StatusOK, resp)
 		return
 	}
 	if err != nil && err != db.ErrNotFound {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1438,7 +2406,7 @@ func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1454,17 +2422,59 @@ func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	file, header, err := r.FormFile("file")
+	_, header, err := r.FormFile("file")
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "No file uploaded")
 		return
 	}
-	defer file.Close()
+
+	altText, ok := cleanAltText(r.FormValue("altText"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("altText must be at most %d characters", maxAltTextLength))
+		return
+	}
 
 	fileType := r.FormValue("fileType")
-	clientID := r.FormValue("clientId")
-	metadataStr := r.FormValue("metadata")
+	fileRecord, err := h.saveUpload(ctx, pregnancy, header, fileType, r.FormValue("clientId"), r.FormValue("metadata"), r.FormValue("shared"), altText)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"fileId": fileRecord.ID,
+		"url":    fmt.Sprintf("/files/%s", fileRecord.StoragePath),
+	}
+	if fileRecord.ModerationStatus.Valid {
+		resp["moderationStatus"] = fileRecord.ModerationStatus.String
+	}
+
+	// Profile photos are only handed out as signed URLs
+	if fileType == "profile_photo" {
+		if err := h.db.SetProfilePhotoFile(ctx, pregnancy.ID, fileRecord.ID); err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		resp["url"] = h.signedFileURL(fileRecord.ID, time.Now())
+	}
+	warnings := append(altTextWarnings(fileRecord, "altText"), h.storageWarnings(ctx, pregnancy.ID)...)
+	writeJSONWarnings(w, http.StatusCreated, resp, warnings)
+}
+
+// saveUpload writes an uploaded file to the pregnancy's region and records it,
+// queueing moderation and a preview as needed.
+func (h *Handler) saveUpload(ctx context.Context, pregnancy *models.Pregnancy, header *multipart.FileHeader, fileType, clientID, metadataStr, shared, altText string) (*models.File, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload")
+	}
+	defer file.Close()
+
+	return h.storeFile(ctx, pregnancy, file, header.Filename, header.Header.Get("Content-Type"), fileType, clientID, metadataStr, shared, altText)
+}
 
+// storeFile is saveUpload for content that didn't come from a multipart form.
+func (h *Handler) storeFile(ctx context.Context, pregnancy *models.Pregnancy, file io.Reader, filename, contentType, fileType, clientID, metadataStr, shared, altText string) (*models.File, error) {
 	// Create storage path
 	now := time.Now()
 	storagePath := filepath.Join(
@@ -1472,29 +2482,32 @@ func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		fileType,
 		fmt.Sprintf("%d", now.Year()),
 		fmt.Sprintf("%02d", now.Month()),
-		fmt.Sprintf("%d_%s", now.UnixNano(), header.Filename),
+		fmt.Sprintf("%d_%s", now.UnixNano(), filename),
 	)
 
-	fullPath := filepath.Join(h.uploadPath, storagePath)
+	// Store in the pregnancy's residency region
+	fullPath, err := h.storage.Path(pregnancy.Region, storagePath)
+	if err != nil {
+		return nil, err
+	}
 
 	// Ensure directory exists
 	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create directory")
-		return
+		return nil, fmt.Errorf("failed to create directory")
 	}
 
 	// Save file
 	dst, err := os.Create(fullPath)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to create file")
-		return
+		return nil, fmt.Errorf("failed to create file")
 	}
 	defer dst.Close()
 
-	size, err := io.Copy(dst, file)
+	// Hash while saving so content URLs can name the exact bytes
+	sum := sha256.New()
+	size, err := io.Copy(io.MultiWriter(dst, sum), file)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to save file")
-		return
+		return nil, fmt.Errorf("failed to save file")
 	}
 
 	// Create file record
@@ -1502,6 +2515,9 @@ func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
 		FileType:    fileType,
 		StoragePath: storagePath,
 		SizeBytes:   sql.NullInt64{Int64: size, Valid: true},
+		Region:      pregnancy.Region,
+		ContentHash: sql.NullString{String: hex.EncodeToString(sum.Sum(nil)), Valid: true},
+		AltText:     altText,
 	}
 	if clientID != "" {
 		f.ClientID = sql.NullString{String: clientID, Valid: true}
@@ -1511,21 +2527,26 @@ func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Detect mime type from header
-	contentType := header.Header.Get("Content-Type")
 	if contentType != "" {
 		f.MimeType = sql.NullString{String: contentType, Valid: true}
 	}
 
+	// Shared images are hidden from supporters until moderation approves them
+	moderate := h.needsModeration(contentType, shared, metadataStr)
+	if moderate {
+		f.ModerationStatus = sql.NullString{String: moderation.StatusPending, Valid: true}
+	}
+
 	fileRecord, err := h.db.CreateFile(ctx, pregnancy.ID, f)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
-		return
+		return nil, err
 	}
 
-	writeJSON(w, http.StatusCreated, map[string]interface{}{
-		"fileId": fileRecord.ID,
-		"url":    fmt.Sprintf("/files/%s", storagePath),
-	})
+	if moderate {
+		go h.moderateFile(fileRecord, pregnancy, fullPath)
+	}
+	h.queuePreview(ctx, fileRecord)
+	return fileRecord, nil
 }
 
 // GetFile gets file metadata.
@@ -1541,19 +2562,18 @@ func (h *Handler) GetFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
 	// Verify access
 	pregnancy, err := h.db.GetPregnancyByID(ctx, file.PregnancyID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
-	if pregnancy.OwnerID != user.UserID &&
-		(!pregnancy.PartnerID.Valid || pregnancy.PartnerID.String != user.UserID) {
+	if !canAccessFiles(pregnancy, user.UserID) {
 		writeError(w, http.StatusForbidden, "FORBIDDEN", "Access denied")
 		return
 	}
@@ -1574,14 +2594,14 @@ func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
 	// Verify access
 	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1595,9 +2615,15 @@ func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isDryRun(r) {
+		preview, err := h.db.DryRunDeleteFile(ctx, fileID)
+		writeDryRun(w, preview, err, "File not found")
+		return
+	}
+
 	err = h.db.DeleteFile(ctx, fileID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		writeDomainError(w, err)
 		return
 	}
 
@@ -1606,54 +2632,18 @@ func (h *Handler) DeleteFile(w http.ResponseWriter, r *http.Request) {
 
 // Helper functions
 
+// getAccessiblePregnancy returns the pregnancy the user can reach and their permission on it.
 func (h *Handler) getAccessiblePregnancy(ctx context.Context, userID string) (*models.Pregnancy, string, error) {
-	// Try as owner first
-	pregnancy, err := h.db.GetPregnancyByOwner(ctx, userID)
-	if err == nil {
-		return pregnancy, "write", nil
-	}
-	if err != db.ErrNotFound {
-		return nil, "", err
-	}
-
-	// Try as coowner (admin with owner-level access)
-	pregnancy, err = h.db.GetPregnancyByCoowner(ctx, userID)
-	if err == nil {
-		return pregnancy, "write", nil
-	}
-	if err != db.ErrNotFound {
-		return nil, "", err
-	}
-
-	// Try as partner
-	pregnancy, err = h.db.GetPregnancyByPartner(ctx, userID)
-	if err == nil {
-		permission := "read"
-		if pregnancy.PartnerPermission.Valid {
-			permission = pregnancy.PartnerPermission.String
-		}
-		return pregnancy, permission, nil
-	}
-	if err != db.ErrNotFound {
+	a, err := h.resolveAccess(ctx, userID)
+	if err != nil {
 		return nil, "", err
 	}
-
-	// Try as supporter
-	pregnancy, err = h.db.GetPregnancyBySupporter(ctx, userID)
-	if err == nil {
-		// Get supporter record to check permission
-		supporter, sErr := h.db.GetSupporterByUserID(ctx, userID)
-		permission := "read"
-		if sErr == nil && supporter.Permission.Valid {
-			permission = supporter.Permission.String
-		}
-		return pregnancy, permission, nil
-	}
-
-	return nil, "", err
+	return a.pregnancy, a.permission, nil
 }
 
-func toPregnancyDTO(p *models.Pregnancy) *models.PregnancyDTO {
+// toPregnancyDTO converts a pregnancy for API responses. Profile photos uploaded
+// through the files API are returned as short-lived signed URLs.
+func (h *Handler) toPregnancyDTO(p *models.Pregnancy) *models.PregnancyDTO {
 	dto := &models.PregnancyDTO{
 		ID:          p.ID,
 		OwnerID:     p.OwnerID,
@@ -1694,7 +2684,13 @@ func toPregnancyDTO(p *models.Pregnancy) *models.PregnancyDTO {
 	if p.ParentRole.Valid {
 		dto.ParentRole = &p.ParentRole.String
 	}
-	if p.ProfilePhoto.Valid {
+	dto.Region = p.Region
+	dto.Demo = p.Demo
+	dto.Version = pregnancyVersion(p)
+	if p.ProfilePhotoFileID.Valid {
+		url := h.signedFileURL(p.ProfilePhotoFileID.Int64, time.Now())
+		dto.ProfilePhoto = &url
+	} else if p.ProfilePhoto.Valid {
 		dto.ProfilePhoto = &p.ProfilePhoto.String
 	}
 	if p.Outcome.Valid {
@@ -1714,16 +2710,14 @@ func toPregnancyDTO(p *models.Pregnancy) *models.PregnancyDTO {
 
 // ============ Static Data Endpoints ============
 
-// GetBabySizes returns the baby sizes JSON data.
+// GetBabySizes returns the published baby sizes.
 func (h *Handler) GetBabySizes(w http.ResponseWriter, r *http.Request) {
-	filePath := filepath.Join(h.dataPath, "BabySizes.json")
-	http.ServeFile(w, r, filePath)
+	h.serveContent(w, r, "baby-sizes")
 }
 
-// GetWeeklyFacts returns the weekly facts JSON data.
+// GetWeeklyFacts returns the published weekly facts.
 func (h *Handler) GetWeeklyFacts(w http.ResponseWriter, r *http.Request) {
-	filePath := filepath.Join(h.dataPath, "WeeklyFacts.json")
-	http.ServeFile(w, r, filePath)
+	h.serveContent(w, r, "weekly-facts")
 }
 
 // Helper functions
@@ -1734,6 +2728,37 @@ func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+// writeNegotiated writes data as MessagePack when the client asks for it, JSON otherwise.
+func writeNegotiated(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	if !strings.Contains(r.Header.Get("Accept"), msgpack.ContentType) {
+		writeJSON(w, status, data)
+		return
+	}
+
+	body, err := msgpack.Marshal(data)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", msgpack.ContentType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// decodeBody decodes a MessagePack or JSON request body based on its Content-Type.
+func decodeBody(r *http.Request, v interface{}) error {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), msgpack.ContentType) {
+		return json.NewDecoder(r.Body).Decode(v)
+	}
+
+	// Read whole, so capped like a decoded gzip body
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxDecodedBody))
+	if err != nil {
+		return err
+	}
+	return msgpack.Unmarshal(body, v)
+}
+
 func writeError(w http.ResponseWriter, status int, code, message string) {
 	resp := models.ErrorResponse{
 		Error: models.ErrorDetail{
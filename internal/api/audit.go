@@ -0,0 +1,364 @@
+// Package api provides the owner's audit log of coowner actions, its CSV
+// export and monthly partition retention.
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/pagination"
+)
+
+const (
+	// auditPartitionInterval is how often partitions are created and expired.
+	auditPartitionInterval = 24 * time.Hour
+	// auditPartitionsAhead is how many months of partitions exist ahead of now.
+	auditPartitionsAhead = 2
+	// auditExportBatch is how many rows an export reads per query.
+	auditExportBatch = 1000
+	// Upper bound for one export job.
+	auditExportTimeout = 30 * time.Minute
+)
+
+// auditEntity is the resource a write request targets: the first segment after
+// /api/, or after /api/pregnancies/{id}/. Migration 047 backfills it the same way.
+func auditEntity(path string) string {
+	parts := strings.Split(strings.TrimPrefix(path, "/api/"), "/")
+	entity := parts[0]
+	if entity == "pregnancies" && len(parts) > 2 && parts[2] != "" {
+		entity = parts[2]
+	}
+	if len(entity) > 50 {
+		entity = entity[:50]
+	}
+	return entity
+}
+
+// parseAuditTime reads an RFC3339 timestamp or a YYYY-MM-DD date. With
+// endOfDay a date means the start of the next day, so a "to" date is inclusive.
+func parseAuditTime(s string, endOfDay bool) (*time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, true
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return nil, false
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, true
+}
+
+// parseAuditFilter validates the audit log filters. The message is suitable
+// for a 400 response.
+func parseAuditFilter(entity, actor, from, to string) (models.AuditFilter, string) {
+	filter := models.AuditFilter{Entity: strings.TrimSpace(entity), Actor: strings.TrimSpace(actor)}
+	var ok bool
+	if from != "" {
+		if filter.From, ok = parseAuditTime(from, false); !ok {
+			return filter, "from must be an RFC3339 timestamp or YYYY-MM-DD date"
+		}
+	}
+	if to != "" {
+		if filter.To, ok = parseAuditTime(to, true); !ok {
+			return filter, "to must be an RFC3339 timestamp or YYYY-MM-DD date"
+		}
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return filter, "from must be before to"
+	}
+	return filter, ""
+}
+
+// getAuditPregnancy returns the caller's own pregnancy. Only owners read the
+// audit log; the coowner is who it audits.
+func (h *Handler) getAuditPregnancy(w http.ResponseWriter, r *http.Request) (*models.Pregnancy, bool) {
+	user := getUserInfo(r)
+	pregnancy, err := h.db.GetPregnancyByOwner(r.Context(), user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only the owner can view the audit log")
+		return nil, false
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return nil, false
+	}
+	return pregnancy, true
+}
+
+// GetAuditLog lists audited coowner actions on the owner's pregnancy, newest
+// first, always as a page (query: limit, cursor, entity, actor, from, to).
+func (h *Handler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	pregnancy, ok := h.getAuditPregnancy(w, r)
+	if !ok {
+		return
+	}
+	params, ok := readPage(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	filter, msg := parseAuditFilter(q.Get("entity"), q.Get("actor"), q.Get("from"), q.Get("to"))
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+
+	actions, err := h.db.GetCoownerActions(r.Context(), pregnancy.ID, filter, params)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pagination.NewPage(actions, params, coownerActionCursor))
+}
+
+// CreateAuditExport starts writing the whole filtered audit log to a CSV file
+// in the background. Poll GET /api/audit/export/{jobId} for progress.
+func (h *Handler) CreateAuditExport(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	pregnancy, ok := h.getAuditPregnancy(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.AuditExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	filter, msg := parseAuditFilter(req.Entity, req.Actor, req.From, req.To)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+
+	job, err := h.db.CreateJob(ctx, pregnancy.ID, user.UserID, "audit_export")
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	ticket := h.heavy.enqueue(user.UserID, job.ID)
+	go func() {
+		<-ticket.ready
+		defer h.heavy.release(user.UserID)
+		h.runAuditExportJob(job.ID, pregnancy, filter)
+	}()
+
+	writeJSON(w, http.StatusAccepted, models.AuditExportResponse{
+		JobID:         job.ID,
+		Status:        job.Status,
+		Progress:      job.Progress,
+		QueuePosition: h.heavy.jobPosition(user.UserID, job.ID),
+	})
+}
+
+// GetAuditExport reports export progress and, once completed, the row count.
+func (h *Handler) GetAuditExport(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.getAuditExportJob(w, r)
+	if !ok {
+		return
+	}
+
+	resp := models.AuditExportResponse{
+		JobID:         job.ID,
+		Status:        job.Status,
+		Progress:      job.Progress,
+		QueuePosition: h.heavy.jobPosition(job.UserID, job.ID),
+		Error:         job.Error.String,
+	}
+	if job.Status == models.JobStatusCompleted {
+		var result struct {
+			Rows int `json:"rows"`
+		}
+		if err := json.Unmarshal(job.Result, &result); err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		resp.Rows = result.Rows
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DownloadAuditExport serves a completed export as CSV.
+func (h *Handler) DownloadAuditExport(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.getAuditExportJob(w, r)
+	if !ok {
+		return
+	}
+	if job.Status != models.JobStatusCompleted {
+		writeError(w, http.StatusConflict, "CONFLICT", "Audit export is not ready")
+		return
+	}
+
+	pregnancy, err := h.db.GetPregnancyByID(r.Context(), job.PregnancyID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	path, err := h.auditExportPath(pregnancy.Region, job.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Audit export file is gone")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-log-%d.csv"`, job.ID))
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, f)
+}
+
+func (h *Handler) getAuditExportJob(w http.ResponseWriter, r *http.Request) (*models.Job, bool) {
+	user := getUserInfo(r)
+	jobID, err := strconv.ParseInt(mux.Vars(r)["jobId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid job ID")
+		return nil, false
+	}
+
+	job, err := h.db.GetJob(r.Context(), jobID, user.UserID)
+	if err == db.ErrNotFound || (err == nil && job.Kind != "audit_export") {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Audit export not found")
+		return nil, false
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return nil, false
+	}
+	return job, true
+}
+
+// auditExportPath stores exports with the pregnancy's other files.
+func (h *Handler) auditExportPath(region string, jobID int64) (string, error) {
+	return h.storage.Path(region, filepath.Join("audit-exports", fmt.Sprintf("%d.csv", jobID)))
+}
+
+// runAuditExportJob writes the filtered audit log to CSV a batch at a time,
+// recording progress on the job.
+func (h *Handler) runAuditExportJob(jobID int64, pregnancy *models.Pregnancy, filter models.AuditFilter) {
+	ctx, cancel := context.WithTimeout(context.Background(), auditExportTimeout)
+	defer cancel()
+
+	fail := func(err error) {
+		log.Printf("Audit export job %d failed: %v", jobID, err)
+		// Fresh context so a timeout can still be recorded
+		if err := h.db.FailJob(context.Background(), jobID, err.Error()); err != nil {
+			log.Printf("Failed to record audit export job failure: %v", err)
+		}
+	}
+
+	total, err := h.db.CountCoownerActions(ctx, pregnancy.ID, filter)
+	if err != nil {
+		fail(err)
+		return
+	}
+	path, err := h.auditExportPath(pregnancy.Region, jobID)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fail(err)
+		return
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer f.Close()
+
+	out := csv.NewWriter(f)
+	out.Write([]string{"id", "created_at", "coowner_id", "method", "path", "entity", "status"})
+	page := pagination.Params{Limit: auditExportBatch}
+	rows := 0
+	for {
+		actions, err := h.db.GetCoownerActions(ctx, pregnancy.ID, filter, page)
+		if err != nil {
+			fail(err)
+			return
+		}
+		batch := pagination.NewPage(actions, page, coownerActionCursor)
+		for _, a := range batch.Items {
+			out.Write([]string{
+				strconv.FormatInt(a.ID, 10),
+				a.CreatedAt.UTC().Format(time.RFC3339),
+				a.CoownerID,
+				a.Method,
+				a.Path,
+				a.Entity,
+				strconv.Itoa(a.Status),
+			})
+		}
+		rows += len(batch.Items)
+		if !batch.HasMore {
+			break
+		}
+		cursor := coownerActionCursor(batch.Items[len(batch.Items)-1])
+		page.After = &cursor
+		if total > 0 {
+			if err := h.db.UpdateJobProgress(ctx, jobID, min(99, rows*100/total)); err != nil {
+				log.Printf("Failed to update audit export job progress: %v", err)
+			}
+		}
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		fail(err)
+		return
+	}
+
+	result, _ := json.Marshal(map[string]int{"rows": rows})
+	if err := h.db.CompleteJob(ctx, jobID, result); err != nil {
+		log.Printf("Failed to complete audit export job %d: %v", jobID, err)
+	}
+}
+
+// RunAuditPartitions keeps monthly audit log partitions created ahead of time
+// and drops those older than retentionMonths (0 keeps them all), once a day.
+// It never returns; start it in a goroutine.
+func (h *Handler) RunAuditPartitions(retentionMonths int) {
+	for {
+		ctx := context.Background()
+		now := time.Now()
+		if err := h.db.EnsureAuditPartitions(ctx, now, auditPartitionsAhead); err != nil {
+			log.Printf("Audit partitions: %v", err)
+		}
+		if retentionMonths > 0 {
+			month := time.Date(now.UTC().Year(), now.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+			dropped, err := h.db.DropAuditPartitionsBefore(ctx, month.AddDate(0, -retentionMonths, 0))
+			if err != nil {
+				log.Printf("Audit partitions: %v", err)
+			}
+			if len(dropped) > 0 {
+				log.Printf("Audit partitions: dropped %s", strings.Join(dropped, ", "))
+			}
+		}
+		time.Sleep(auditPartitionInterval)
+	}
+}
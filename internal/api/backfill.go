@@ -0,0 +1,159 @@
+// Package api provides bulk import of historical entries.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// maxBackfillEntries bounds one backfill request.
+const maxBackfillEntries = 1000
+
+// backfillClockSkew tolerates client clocks running slightly ahead.
+const backfillClockSkew = 5 * time.Minute
+
+// BackfillEntries imports past-dated entries for users switching from another app.
+// Unlike batch create, invalid items never block the rest and existing entries are
+// never overwritten; the response summarizes what was imported.
+func (h *Handler) BackfillEntries(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return
+	}
+
+	var req models.BackfillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if len(req.Entries) == 0 || len(req.Entries) > maxBackfillEntries {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("entries must contain 1 to %d items", maxBackfillEntries))
+		return
+	}
+
+	summary := models.BackfillSummary{
+		Received: len(req.Entries),
+		ByType:   map[string]int{},
+		Results:  []models.BatchEntryResult{},
+	}
+
+	now := time.Now()
+	audience := entryAudience(pregnancy, user.UserID)
+	seen := make(map[string]int, len(req.Entries))
+	var valid []int
+	var entries []models.EntryRequest
+	var createdAt []time.Time
+	for i := range req.Entries {
+		e := &req.Entries[i]
+
+		at, msg := validateBackfillEntry(e, now)
+		if msg == "" {
+			msg = validateVisibility(&e.EntryRequest, audience)
+		}
+		if msg == "" {
+			key := e.EntryType + "/" + e.ClientID
+			if first, dup := seen[key]; dup {
+				msg = fmt.Sprintf("duplicate of entry %d in this request", first)
+			} else {
+				seen[key] = i
+			}
+		}
+		if msg != "" {
+			summary.Failed++
+			summary.Results = append(summary.Results, models.BatchEntryResult{
+				Index: i, ClientID: e.ClientID, EntryType: e.EntryType,
+				Status: models.BatchItemFailed, Reason: msg,
+			})
+			continue
+		}
+
+		valid = append(valid, i)
+		entries = append(entries, e.EntryRequest)
+		createdAt = append(createdAt, at)
+	}
+
+	h.noteUnknownDataVersions(r, entries)
+
+	if len(entries) > 0 {
+		inserted, err := h.db.BackfillEntries(ctx, pregnancy.ID, entries, createdAt)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Backfill rolled back: "+err.Error())
+			return
+		}
+
+		for j, i := range valid {
+			e := &req.Entries[i]
+			if !inserted[j] {
+				summary.Duplicates++
+				summary.Results = append(summary.Results, models.BatchEntryResult{
+					Index: i, ClientID: e.ClientID, EntryType: e.EntryType,
+					Status: models.BatchItemDuplicate, Reason: "entry already exists",
+				})
+				continue
+			}
+			summary.Created++
+			summary.ByType[e.EntryType]++
+			at := createdAt[j]
+			if summary.Earliest == nil || at.Before(*summary.Earliest) {
+				summary.Earliest = &at
+			}
+			if summary.Latest == nil || at.After(*summary.Latest) {
+				summary.Latest = &at
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// validateBackfillEntry checks a historical entry and returns its original time.
+func validateBackfillEntry(e *models.BackfillEntry, now time.Time) (time.Time, string) {
+	if e.ClientID == "" || e.EntryType == "" {
+		return time.Time{}, "clientId and entryType are required"
+	}
+	if len(e.ClientID) > 50 || len(e.EntryType) > 50 {
+		return time.Time{}, "clientId and entryType must be at most 50 characters"
+	}
+	if len(e.Data) == 0 || !json.Valid(e.Data) {
+		return time.Time{}, "data must be valid JSON"
+	}
+	if msg := validateDataVersion(&e.EntryRequest); msg != "" {
+		return time.Time{}, msg
+	}
+	if e.ScheduledFor != nil || e.Status != nil || e.Unschedule {
+		return time.Time{}, "scheduled entries cannot be backfilled"
+	}
+	if e.OccurredAt != nil {
+		return time.Time{}, "backfilled entries take their time from createdAt, not occurredAt"
+	}
+
+	at, err := time.Parse(time.RFC3339, e.CreatedAt)
+	if err != nil {
+		at, err = time.Parse("2006-01-02", e.CreatedAt)
+	}
+	if err != nil {
+		return time.Time{}, "createdAt must be an RFC3339 timestamp or YYYY-MM-DD date"
+	}
+	if at.After(now.Add(backfillClockSkew)) {
+		return time.Time{}, "createdAt must be in the past"
+	}
+	return at, ""
+}
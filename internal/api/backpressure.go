@@ -0,0 +1,127 @@
+// Package api provides adaptive load shedding on sync routes.
+package api
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// Sync is shed once any load signal crosses its threshold. Pressure is the
+// highest signal relative to its threshold; at pressure p a request is shed
+// with probability 1 - 1/p, so roughly the excess load is turned away.
+const (
+	syncLatencyThreshold = 250 * time.Millisecond // Average database call latency
+	syncInFlightLimit    = 100                    // Concurrent sync requests
+	syncPoolThreshold    = 0.9                    // Share of database connections in use
+)
+
+// Shed requests are told to retry after syncRetryBase scaled by the pressure,
+// capped at syncRetryMax, plus up to as much again in jitter so clients that
+// were shed together don't come back together.
+const (
+	syncRetryBase = 2 * time.Second
+	syncRetryMax  = 30 * time.Second
+)
+
+// loadShedder tracks sync load and shed requests. State is per process.
+type loadShedder struct {
+	inFlight atomic.Int64
+
+	mu         sync.Mutex
+	shed       int64
+	shedBy     map[string]int64
+	lastShedAt time.Time
+}
+
+func newLoadShedder() *loadShedder {
+	return &loadShedder{shedBy: make(map[string]int64)}
+}
+
+// pressure returns the load relative to the thresholds and the signal that
+// dominates it.
+func (s *loadShedder) pressure(load models.DatabaseLoad) (float64, string) {
+	pressure, reason := float64(s.inFlight.Load())/syncInFlightLimit, "inflight"
+	if p := load.LatencyMs / float64(syncLatencyThreshold.Milliseconds()); p > pressure {
+		pressure, reason = p, "latency"
+	}
+	if load.PoolMax > 0 {
+		if p := float64(load.PoolInUse) / (syncPoolThreshold * float64(load.PoolMax)); p > pressure {
+			pressure, reason = p, "pool"
+		}
+	}
+	return pressure, reason
+}
+
+// record counts a shed request.
+func (s *loadShedder) record(reason string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shed++
+	s.shedBy[reason]++
+	s.lastShedAt = now
+}
+
+// status reports the current pressure and shed counts.
+func (s *loadShedder) status(load models.DatabaseLoad) models.BackpressureStatus {
+	pressure, _ := s.pressure(load)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := models.BackpressureStatus{
+		Pressure: pressure,
+		InFlight: s.inFlight.Load(),
+		Database: load,
+		Shed:     s.shed,
+		ShedBy:   make(map[string]int64, len(s.shedBy)),
+	}
+	for reason, n := range s.shedBy {
+		status.ShedBy[reason] = n
+	}
+	if !s.lastShedAt.IsZero() {
+		lastShedAt := s.lastShedAt.UTC()
+		status.LastShedAt = &lastShedAt
+	}
+	return status
+}
+
+// syncRetryAfter returns a jittered retry delay in whole seconds for a pressure.
+func syncRetryAfter(pressure float64) int {
+	base := time.Duration(float64(syncRetryBase) * pressure)
+	if base > syncRetryMax {
+		base = syncRetryMax
+	}
+	delay := base + time.Duration(rand.Int63n(int64(base)+1))
+	return int(delay.Seconds() + 0.999)
+}
+
+// BackpressureMiddleware sheds sync requests while the database is slow or
+// busy, answering 429 with a jittered Retry-After. Other routes pass through.
+// It must run after AuthMiddleware and RateLimitMiddleware, so shed requests
+// still count against the sync budget.
+func (h *Handler) BackpressureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if routeBudget(r).name != "sync" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		pressure, reason := h.shedder.pressure(h.db.Load())
+		if pressure >= 1 && rand.Float64() >= 1/pressure {
+			h.shedder.record(reason, time.Now())
+			w.Header().Set("Retry-After", strconv.Itoa(syncRetryAfter(pressure)))
+			writeError(w, http.StatusTooManyRequests, "RATE_LIMITED", "Server is busy, retry later")
+			return
+		}
+
+		h.shedder.inFlight.Add(1)
+		defer h.shedder.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
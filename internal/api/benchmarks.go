@@ -0,0 +1,118 @@
+// Package api provides cross-user benchmarks released with differential privacy.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/privacy"
+)
+
+// Gestational weeks covered by benchmarks.
+const (
+	benchmarkFirstWeek = 4
+	benchmarkLastWeek  = 42
+)
+
+// benchmarkMetric is a numeric entry field benchmarked across pregnancies.
+// Bounds clip each pregnancy's weekly mean and so bound its influence.
+type benchmarkMetric struct {
+	entryType string
+	field     string
+	unit      string // Only payloads in this unit count; "" for any
+	bounds    privacy.Bounds
+}
+
+var benchmarkMetrics = map[string]benchmarkMetric{
+	"weight":    {entryType: "weight", field: "weight", unit: "kg", bounds: privacy.Bounds{Lo: 35, Hi: 200}},
+	"systolic":  {entryType: "blood_pressure", field: "systolic", bounds: privacy.Bounds{Lo: 70, Hi: 200}},
+	"diastolic": {entryType: "blood_pressure", field: "diastolic", bounds: privacy.Bounds{Lo: 40, Hi: 130}},
+	"glucose":   {entryType: "glucose", field: "glucose", unit: "mg/dL", bounds: privacy.Bounds{Lo: 40, Hi: 400}},
+	"water":     {entryType: "water", field: "amount", unit: "ml", bounds: privacy.Bounds{Lo: 0, Hi: 6000}},
+}
+
+// RunBenchmarks recomputes every benchmark with the given privacy parameters
+// and stores the noisy release. noiseKey seeds the noise so an unchanged bucket
+// is released with the same noise every run. It never returns; start it in a
+// goroutine.
+func (h *Handler) RunBenchmarks(params privacy.Params, noiseKey []byte, interval time.Duration) {
+	for {
+		for name, m := range benchmarkMetrics {
+			if err := h.releaseBenchmark(context.Background(), name, m, params, noiseKey); err != nil {
+				log.Printf("Benchmarks: %s: %v", name, err)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// releaseBenchmark aggregates one metric and stores its noisy, thresholded release.
+func (h *Handler) releaseBenchmark(ctx context.Context, name string, m benchmarkMetric, params privacy.Params, noiseKey []byte) error {
+	cohorts, err := h.db.GetCohortAggregates(ctx, m.entryType, m.field, m.unit, m.bounds.Lo, m.bounds.Hi, benchmarkFirstWeek, benchmarkLastWeek)
+	if err != nil {
+		return err
+	}
+	byWeek := make(map[int]models.CohortAggregate, len(cohorts))
+	for _, c := range cohorts {
+		byWeek[c.Week] = c
+	}
+
+	// Every week is released, empty ones as suppressed, so which weeks have
+	// data doesn't show either
+	buckets := make([]models.BenchmarkBucket, 0, benchmarkLastWeek-benchmarkFirstWeek+1)
+	for week := benchmarkFirstWeek; week <= benchmarkLastWeek; week++ {
+		c := byWeek[week]
+		exact := privacy.Bucket{Users: c.Users, Sum: c.Sum}
+		rng := rand.New(rand.NewSource(privacy.Seed(noiseKey, fmt.Sprintf("%s/%d", name, week), exact)))
+		release := params.Release(exact, m.bounds, rng)
+
+		b := models.BenchmarkBucket{Week: week, Suppressed: release.Suppressed, Epsilon: params.Epsilon, MinCohort: params.MinCohort}
+		if !release.Suppressed {
+			b.Users = sql.NullInt64{Int64: int64(release.Users), Valid: true}
+			b.Mean = sql.NullFloat64{Float64: release.Mean, Valid: true}
+		}
+		buckets = append(buckets, b)
+	}
+	return h.db.ReplaceBenchmarks(ctx, name, buckets)
+}
+
+// GetBenchmarks returns a metric's population benchmark by gestational week,
+// with the privacy parameters it was released under.
+// Query: metric (weight, systolic, diastolic, glucose, water).
+func (h *Handler) GetBenchmarks(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("metric")
+	m, ok := benchmarkMetrics[name]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "metric must be weight, systolic, diastolic, glucose or water")
+		return
+	}
+
+	buckets, err := h.db.GetBenchmarks(r.Context(), name)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if buckets == nil {
+		buckets = []models.BenchmarkBucket{}
+	}
+
+	resp := models.BenchmarkResponse{Metric: name, Unit: m.unit, Buckets: buckets}
+	if len(buckets) > 0 {
+		resp.Privacy = &models.BenchmarkPrivacy{
+			Mechanism:         privacy.Mechanism,
+			EpsilonPerBucket:  buckets[0].Epsilon,
+			MinCohort:         buckets[0].MinCohort,
+			ClipLow:           m.bounds.Lo,
+			ClipHigh:          m.bounds.Hi,
+			MaxBucketsPerUser: len(buckets),
+			GeneratedAt:       buckets[0].GeneratedAt,
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
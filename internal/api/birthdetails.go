@@ -0,0 +1,209 @@
+// Package api provides the birth details follow-up after outcome=birth.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// birthArchiveInterval is how often pregnancies due for auto-archive are checked.
+const birthArchiveInterval = time.Hour
+
+// Limits on birth details
+const (
+	maxBirthArchiveDays = 365
+	minBirthWeightGrams = 200
+	maxBirthWeightGrams = 7000
+	minBirthLengthCm    = 20
+	maxBirthLengthCm    = 70
+)
+
+var deliveryTypes = map[string]bool{"vaginal": true, "assisted": true, "cesarean": true, "other": true}
+
+// birthFollowUp is returned with outcome=birth until birth details are recorded.
+func birthFollowUp(pregnancyID int64) *models.OutcomeFollowUp {
+	return &models.OutcomeFollowUp{
+		Kind:   "birth_details",
+		Path:   fmt.Sprintf("/api/pregnancies/%d/birth-details", pregnancyID),
+		Fields: []string{"bornAt", "weightGrams", "lengthCm", "deliveryType"},
+	}
+}
+
+// GetBirthDetails returns a pregnancy's birth details to anyone with access to it.
+func (h *Handler) GetBirthDetails(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	pregnancyID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid pregnancy ID")
+		return
+	}
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound || (err == nil && pregnancy.ID != pregnancyID) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Pregnancy not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	details, err := h.db.GetBirthDetails(ctx, pregnancyID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Birth details not recorded")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, details)
+}
+
+// SaveBirthDetails records or corrects the birth details of a pregnancy whose
+// outcome is birth. It schedules the auto-archive and seeds the baby profile
+// setting the first time.
+func (h *Handler) SaveBirthDetails(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	pregnancyID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid pregnancy ID")
+		return
+	}
+
+	pregnancy, err := h.db.GetPregnancyByID(ctx, pregnancyID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Pregnancy not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if pregnancy.OwnerID != user.UserID && !(pregnancy.CoownerID.Valid && pregnancy.CoownerID.String == user.UserID) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only the owner or coowner can record birth details")
+		return
+	}
+	if pregnancy.Archived {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Cannot modify archived pregnancy")
+		return
+	}
+	if pregnancy.Outcome.String != "birth" {
+		writeError(w, http.StatusConflict, "CONFLICT", "Set the outcome to birth first")
+		return
+	}
+
+	var req models.BirthDetailsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	details, msg := h.parseBirthDetails(&req, time.Now())
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+	details.PregnancyID = pregnancy.ID
+	details.UpdatedBy = user.UserID
+
+	profile := map[string]interface{}{"bornAt": details.BornAt.Format(time.RFC3339)}
+	if pregnancy.BabyName.Valid {
+		profile["name"] = pregnancy.BabyName.String
+	}
+	if details.WeightGrams.Valid {
+		profile["birthWeightGrams"] = details.WeightGrams.Int64
+	}
+	if details.LengthCm.Valid {
+		profile["birthLengthCm"] = details.LengthCm.Float64
+	}
+	if details.DeliveryType.Valid {
+		profile["deliveryType"] = details.DeliveryType.String
+	}
+	profileJSON, err := json.Marshal(profile)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	saved, seeded, err := h.db.SaveBirthDetails(ctx, details, profileJSON)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"birthDetails":      saved,
+		"babyProfileSeeded": seeded,
+	})
+}
+
+// parseBirthDetails validates a birth details request. The auto-archive date
+// counts from the birth.
+func (h *Handler) parseBirthDetails(req *models.BirthDetailsRequest, now time.Time) (*models.BirthDetails, string) {
+	bornAt, err := time.Parse(time.RFC3339, req.BornAt)
+	if err != nil {
+		return nil, "bornAt must be an RFC3339 date and time"
+	}
+	if bornAt.After(now.Add(time.Hour)) {
+		return nil, "bornAt cannot be in the future"
+	}
+	details := &models.BirthDetails{BornAt: bornAt}
+
+	if req.WeightGrams != nil {
+		if *req.WeightGrams < minBirthWeightGrams || *req.WeightGrams > maxBirthWeightGrams {
+			return nil, fmt.Sprintf("weightGrams must be between %d and %d", minBirthWeightGrams, maxBirthWeightGrams)
+		}
+		details.WeightGrams = sql.NullInt64{Int64: int64(*req.WeightGrams), Valid: true}
+	}
+	if req.LengthCm != nil {
+		if *req.LengthCm < minBirthLengthCm || *req.LengthCm > maxBirthLengthCm {
+			return nil, fmt.Sprintf("lengthCm must be between %d and %d", minBirthLengthCm, maxBirthLengthCm)
+		}
+		details.LengthCm = sql.NullFloat64{Float64: *req.LengthCm, Valid: true}
+	}
+	if req.DeliveryType != "" {
+		if !deliveryTypes[req.DeliveryType] {
+			return nil, "deliveryType must be vaginal, assisted, cesarean or other"
+		}
+		details.DeliveryType = sql.NullString{String: req.DeliveryType, Valid: true}
+	}
+
+	days := h.birthArchiveDays
+	if req.ArchiveAfterDays != nil {
+		if *req.ArchiveAfterDays < 0 || *req.ArchiveAfterDays > maxBirthArchiveDays {
+			return nil, fmt.Sprintf("archiveAfterDays must be between 0 and %d", maxBirthArchiveDays)
+		}
+		days = *req.ArchiveAfterDays
+	}
+	if days > 0 {
+		details.ArchiveAt = sql.NullTime{Time: bornAt.AddDate(0, 0, days), Valid: true}
+	}
+	return details, ""
+}
+
+// RunBirthArchive archives pregnancies whose birth follow-up period has ended.
+// It never returns; start it in a goroutine.
+func (h *Handler) RunBirthArchive() {
+	for {
+		ids, err := h.db.ArchiveDueBirths(context.Background())
+		if err != nil {
+			log.Printf("Birth archive: %v", err)
+		} else if len(ids) > 0 {
+			log.Printf("Birth archive: archived %d pregnancies", len(ids))
+		}
+		time.Sleep(birthArchiveInterval)
+	}
+}
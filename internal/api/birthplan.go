@@ -0,0 +1,240 @@
+// Package api provides the birth plan, which the owner and partner fill in
+// together: a section is locked while someone types in it, and edits made
+// against an older version are merged line by line.
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// birthPlanLockTTL is how long a section lock lasts. Editors renew it while
+// typing; a lock left by a closed app runs out on its own.
+const birthPlanLockTTL = 2 * time.Minute
+
+// maxBirthPlanSectionLen limits the text of one section.
+const maxBirthPlanSectionLen = 10000
+
+// birthPlanSections are the sections of a birth plan, in display order.
+var birthPlanSections = []string{
+	"labor_environment",
+	"support_people",
+	"pain_relief",
+	"delivery",
+	"after_birth",
+	"feeding",
+	"special_considerations",
+}
+
+func isBirthPlanSection(section string) bool {
+	for _, s := range birthPlanSections {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+// canAccessBirthPlan reports whether a role sees the birth plan. Supporters
+// don't; it is written for the birth team.
+func canAccessBirthPlan(role string) bool {
+	return role == "owner" || role == "coowner" || role == "father"
+}
+
+// getBirthPlanAccess resolves the caller's pregnancy for the birth plan and
+// answers the request itself on failure.
+func (h *Handler) getBirthPlanAccess(w http.ResponseWriter, r *http.Request, write bool) (*pregnancyAccess, bool) {
+	user := getUserInfo(r)
+	a, err := h.resolveAccess(r.Context(), user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return nil, false
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return nil, false
+	}
+	if !canAccessBirthPlan(a.role) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only the owner and partner can see the birth plan")
+		return nil, false
+	}
+	if write && a.permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return nil, false
+	}
+	return a, true
+}
+
+// clearExpiredLock hides a lock that ran out, so clients only see live ones.
+func clearExpiredLock(s *models.BirthPlanSection, now time.Time) {
+	if s.LockExpiresAt.Valid && !s.LockExpiresAt.Time.After(now) {
+		s.LockedBy.Valid, s.LockExpiresAt.Valid = false, false
+	}
+}
+
+// birthPlan returns every section of the pregnancy's birth plan, empty ones
+// at version 0.
+func (h *Handler) birthPlan(ctx context.Context, pregnancyID int64) ([]models.BirthPlanSection, error) {
+	saved, err := h.db.GetBirthPlan(ctx, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	bySection := make(map[string]models.BirthPlanSection, len(saved))
+	for _, s := range saved {
+		bySection[s.Section] = s
+	}
+
+	now := time.Now()
+	sections := make([]models.BirthPlanSection, 0, len(birthPlanSections))
+	for _, name := range birthPlanSections {
+		s, ok := bySection[name]
+		if !ok {
+			s = models.BirthPlanSection{PregnancyID: pregnancyID, Section: name}
+		}
+		clearExpiredLock(&s, now)
+		sections = append(sections, s)
+	}
+	return sections, nil
+}
+
+// GetBirthPlan returns the birth plan with each section's version and lock.
+func (h *Handler) GetBirthPlan(w http.ResponseWriter, r *http.Request) {
+	a, ok := h.getBirthPlanAccess(w, r, false)
+	if !ok {
+		return
+	}
+
+	sections, err := h.birthPlan(r.Context(), a.pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, models.BirthPlanResponse{Sections: sections})
+}
+
+// PatchBirthPlan saves the given sections, each written against the version
+// in baseVersion. Sections changed since are merged when the edits touch
+// different lines. Sections someone else has locked, and overlapping edits,
+// are left as they are and reported as conflicts with the server's version;
+// the other sections are still saved.
+func (h *Handler) PatchBirthPlan(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	a, ok := h.getBirthPlanAccess(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req models.BirthPlanPatchRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if len(req.Sections) == 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "sections is required")
+		return
+	}
+	names := make([]string, 0, len(req.Sections))
+	for name, edit := range req.Sections {
+		if !isBirthPlanSection(name) {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Unknown birth plan section %q", name))
+			return
+		}
+		if edit.Content == nil || edit.BaseVersion == nil || *edit.BaseVersion < 0 {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("%s needs content and baseVersion", name))
+			return
+		}
+		if len(*edit.Content) > maxBirthPlanSectionLen {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("%s must be at most %d characters", name, maxBirthPlanSectionLen))
+			return
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	resp := models.BirthPlanResponse{Sections: []models.BirthPlanSection{}}
+	for _, name := range names {
+		edit := req.Sections[name]
+		s, merged, err := h.db.SaveBirthPlanSection(ctx, a.pregnancy.ID, name, user.UserID, *edit.Content, *edit.BaseVersion)
+		switch err {
+		case nil:
+			clearExpiredLock(s, now)
+			resp.Sections = append(resp.Sections, *s)
+			if merged {
+				resp.Merged = append(resp.Merged, name)
+			}
+		case db.ErrLocked, db.ErrConflict:
+			reason := "edited"
+			if err == db.ErrLocked {
+				reason = "locked"
+			}
+			clearExpiredLock(s, now)
+			resp.Conflicts = append(resp.Conflicts, models.BirthPlanConflict{Section: name, Reason: reason, Server: s})
+		default:
+			writeDomainError(w, err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// LockBirthPlanSection locks a section for the caller while they edit it, or
+// renews their lock. It fails with 409 while someone else holds the lock.
+func (h *Handler) LockBirthPlanSection(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	a, ok := h.getBirthPlanAccess(w, r, true)
+	if !ok {
+		return
+	}
+	section := mux.Vars(r)["section"]
+	if !isBirthPlanSection(section) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Unknown birth plan section")
+		return
+	}
+
+	s, err := h.db.LockBirthPlanSection(r.Context(), a.pregnancy.ID, section, user.UserID, time.Now().Add(birthPlanLockTTL))
+	if err == db.ErrLocked {
+		writeError(w, http.StatusConflict, "CONFLICT", fmt.Sprintf("Section is being edited until %s", s.LockExpiresAt.Time.UTC().Format(time.RFC3339)))
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// UnlockBirthPlanSection releases the caller's lock on a section.
+func (h *Handler) UnlockBirthPlanSection(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	a, ok := h.getBirthPlanAccess(w, r, true)
+	if !ok {
+		return
+	}
+	section := mux.Vars(r)["section"]
+	if !isBirthPlanSection(section) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Unknown birth plan section")
+		return
+	}
+
+	if err := h.db.UnlockBirthPlanSection(r.Context(), a.pregnancy.ID, section, user.UserID); err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "You don't hold this section's lock")
+		return
+	} else if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
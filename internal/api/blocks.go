@@ -0,0 +1,90 @@
+// Package api provides the blocklist that keeps users from pairing with or
+// joining the pregnancies of people who blocked them.
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// maxBlockReasonLen limits the private note kept with a block.
+const maxBlockReasonLen = 200
+
+// GetUserBlocks lists the users the caller blocked.
+func (h *Handler) GetUserBlocks(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	blocks, err := h.db.GetUserBlocks(r.Context(), user.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if blocks == nil {
+		blocks = []models.UserBlock{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"blocks": blocks})
+}
+
+// CreateUserBlock blocks an mvchat2 user. Blocking again updates the reason.
+func (h *Handler) CreateUserBlock(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	var req models.UserBlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	req.UserID = strings.TrimSpace(req.UserID)
+	if req.UserID == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "userId is required")
+		return
+	}
+	if req.UserID == user.UserID {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "You cannot block yourself")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len(req.Reason) > maxBlockReasonLen {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "reason must be at most 200 characters")
+		return
+	}
+
+	if _, err := h.db.GetUserEmail(ctx, req.UserID); err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "User not found")
+		return
+	} else if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	block, err := h.db.CreateUserBlock(ctx, user.UserID, req.UserID, sql.NullString{String: req.Reason, Valid: req.Reason != ""})
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, block)
+}
+
+// DeleteUserBlock unblocks a user.
+func (h *Handler) DeleteUserBlock(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	err := h.db.DeleteUserBlock(r.Context(), user.UserID, mux.Vars(r)["userId"])
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "User is not blocked")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
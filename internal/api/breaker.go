@@ -0,0 +1,57 @@
+// Package api provides fail-fast handling while the database is degraded, and readiness checks.
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// readinessTimeout bounds the database ping behind /readyz.
+const readinessTimeout = 2 * time.Second
+
+// BreakerMiddleware answers 503 with Retry-After while the database circuit
+// breaker is open, instead of letting requests wait for database timeouts.
+// It must run before anything that queries the database.
+func (h *Handler) BreakerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		release, retryAfter, ok := h.db.AdmitRequest()
+		if !ok {
+			writeUnavailable(w, retryAfter)
+			return
+		}
+		defer release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Readyz reports whether the server can take traffic: the breaker is not open
+// and the database answers a ping.
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	err := h.db.Ping(ctx)
+	status := h.db.BreakerStatus()
+	if err != nil {
+		if err == db.ErrCircuitOpen {
+			w.Header().Set("Retry-After", strconv.Itoa(status.RetryAfter))
+		}
+		writeJSON(w, http.StatusServiceUnavailable, models.ReadinessResponse{Status: "unavailable", Database: status})
+		return
+	}
+	writeJSON(w, http.StatusOK, models.ReadinessResponse{Status: "ready", Database: status})
+}
+
+func writeUnavailable(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(retryAfter.Seconds() + 0.999)
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "Database unavailable, retry later")
+}
@@ -0,0 +1,64 @@
+// Package api provides Cache-Control policies per resource type, so mobile
+// HTTP caches behave the same on every route.
+package api
+
+import "net/http"
+
+// Cache-Control policies. Routes pick one in the registry; the default is
+// CacheNoStore.
+const (
+	// CacheNoStore keeps pregnancy data (entries, sync, settings, exports)
+	// out of caches: it changes with every write and may be sensitive.
+	CacheNoStore = "no-store"
+	// CacheRevalidate lets the client keep a copy but check it (ETag) first.
+	CacheRevalidate = "private, no-cache"
+	// CacheShort serves the client's copy for 5 minutes.
+	CacheShort = "private, max-age=300"
+	// CachePreview serves rendered previews for an hour; re-rendering replaces them.
+	CachePreview = "private, max-age=3600"
+	// CacheImmutable is for URLs that name their exact content by hash.
+	CacheImmutable = "private, max-age=31536000, immutable"
+	// CacheStatic is for public reference data, revalidated in the background.
+	CacheStatic = "public, max-age=3600, stale-while-revalidate=86400"
+)
+
+// cacheControlWriter sets the route's Cache-Control when the response starts,
+// unless the handler chose one. Error responses are never cached.
+type cacheControlWriter struct {
+	http.ResponseWriter
+	policy  string
+	started bool
+}
+
+func (c *cacheControlWriter) WriteHeader(status int) {
+	if !c.started {
+		c.started = true
+		if c.Header().Get("Cache-Control") == "" {
+			policy := c.policy
+			if status >= http.StatusBadRequest {
+				policy = CacheNoStore
+			}
+			c.Header().Set("Cache-Control", policy)
+		}
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *cacheControlWriter) Write(b []byte) (int, error) {
+	if !c.started {
+		c.WriteHeader(http.StatusOK)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer to flush streams.
+func (c *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// cacheMiddleware applies a Cache-Control policy to a route's responses.
+func cacheMiddleware(policy string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&cacheControlWriter{ResponseWriter: w, policy: policy}, r)
+	})
+}
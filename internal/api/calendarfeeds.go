@@ -0,0 +1,149 @@
+// Package api provides calendar feed URLs that calendar apps can subscribe to.
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// calendarFeedPrefix marks calendar feed tokens. They are only accepted in a
+// feed's own URL, since calendar apps subscribe by URL and cannot send headers.
+const calendarFeedPrefix = "t2c_"
+
+// Calendar feed limits
+const maxCalendarFeeds = 5
+
+// calendarFeedBudget covers feed URLs. It is counted per feed, apart from user
+// budgets, and is enforced since the URL is the only credential.
+var calendarFeedBudget = rateBudget{name: "calendar_feed", limit: 30, window: time.Hour}
+
+// calendarFeedPath is the unauthenticated URL of a calendar feed.
+func calendarFeedPath(token string) string {
+	return fmt.Sprintf("/api/calendar/%s.ics", token)
+}
+
+// GetCalendarFeeds lists the user's calendar feeds.
+func (h *Handler) GetCalendarFeeds(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	feeds, err := h.db.GetCalendarFeeds(r.Context(), user.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if feeds == nil {
+		feeds = []models.CalendarFeed{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"feeds": feeds})
+}
+
+// CreateCalendarFeed issues a calendar feed URL. It is returned once and only
+// its token's hash is kept.
+func (h *Handler) CreateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	var req models.CalendarFeedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxTokenNameLen {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name is required (max 100 characters)")
+		return
+	}
+
+	count, err := h.db.CountActiveCalendarFeeds(ctx, user.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if count >= maxCalendarFeeds {
+		writeError(w, http.StatusConflict, "CONFLICT", "Too many calendar feeds; revoke one first")
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to generate token")
+		return
+	}
+	token := calendarFeedPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	created, err := h.db.CreateCalendarFeed(ctx, user.UserID, req.Name, sha256Hex(token), token[:len(calendarFeedPrefix)+6])
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.CalendarFeedResponse{CalendarFeed: *created, URL: calendarFeedPath(token)})
+}
+
+// RevokeCalendarFeed revokes one of the user's calendar feeds immediately.
+func (h *Handler) RevokeCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	feedID, err := strconv.ParseInt(mux.Vars(r)["feedId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid feed ID")
+		return
+	}
+
+	err = h.db.RevokeCalendarFeed(r.Context(), feedID, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Calendar feed not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSubscribedCalendar serves a calendar feed to whoever holds its URL. It shows
+// what the feed's user can see now, so losing access empties it like revoking it.
+func (h *Handler) GetSubscribedCalendar(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+	if !strings.HasPrefix(token, calendarFeedPrefix) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Calendar feed not found")
+		return
+	}
+
+	feed, err := h.db.GetActiveCalendarFeed(r.Context(), sha256Hex(token))
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Calendar feed not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	res, err := h.limiter.Hit(r.Context(), fmt.Sprintf("calendar_feed:%d", feed.ID), calendarFeedBudget.rateLimit())
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	setRateLimitHeaders(w, &calendarFeedBudget, res)
+	if res.Exceeded(calendarFeedBudget.rateLimit()) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(res.Reset).Seconds())+1))
+		writeError(w, http.StatusTooManyRequests, "RATE_LIMITED", "Too many calendar feed requests, retry later")
+		return
+	}
+
+	h.writeCalendar(w, r, feed.UserID)
+}
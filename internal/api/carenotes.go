@@ -0,0 +1,233 @@
+// Package api provides care team note handlers.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// getCareNotePregnancy resolves the pregnancy whose care notes the user may see.
+// Only the owner, the coowner and linked providers qualify; partners and
+// supporters are deliberately excluded even with write permission.
+func (h *Handler) getCareNotePregnancy(ctx context.Context, userID string) (*models.Pregnancy, string, error) {
+	pregnancy, err := h.db.GetPregnancyByOwner(ctx, userID)
+	if err == nil {
+		return pregnancy, "owner", nil
+	}
+	if err != db.ErrNotFound {
+		return nil, "", err
+	}
+
+	pregnancy, err = h.db.GetPregnancyByCoowner(ctx, userID)
+	if err == nil {
+		return pregnancy, "coowner", nil
+	}
+	if err != db.ErrNotFound {
+		return nil, "", err
+	}
+
+	pregnancy, err = h.db.GetPregnancyByProvider(ctx, userID)
+	if err == nil {
+		return pregnancy, "provider", nil
+	}
+	return nil, "", err
+}
+
+// GetCareNotes lists care notes for the pregnancy.
+func (h *Handler) GetCareNotes(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, _, err := h.getCareNotePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Care notes are only visible to the owner and care providers")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	notes, err := h.db.GetCareNotes(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if notes == nil {
+		notes = []models.CareNote{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"notes": notes})
+}
+
+// CreateCareNote adds a care note and notifies the other side of the conversation.
+func (h *Handler) CreateCareNote(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, role, err := h.getCareNotePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Care notes are only visible to the owner and care providers")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	var req models.CareNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Note body required")
+		return
+	}
+
+	note, err := h.db.CreateCareNote(ctx, pregnancy.ID, user.UserID, role, req.Body)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	h.notifyCareNote(ctx, pregnancy, role, note)
+
+	writeJSON(w, http.StatusCreated, note)
+}
+
+// UpdateCareNote edits a care note. Only the author can edit.
+func (h *Handler) UpdateCareNote(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	noteID, err := strconv.ParseInt(mux.Vars(r)["noteId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid note ID")
+		return
+	}
+
+	pregnancy, _, err := h.getCareNotePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Care notes are only visible to the owner and care providers")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	var req models.CareNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Note body required")
+		return
+	}
+
+	note, err := h.db.UpdateCareNote(ctx, pregnancy.ID, noteID, user.UserID, req.Body)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Note not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, note)
+}
+
+// DeleteCareNote soft deletes a care note. Only the author can delete.
+func (h *Handler) DeleteCareNote(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	noteID, err := strconv.ParseInt(mux.Vars(r)["noteId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid note ID")
+		return
+	}
+
+	pregnancy, _, err := h.getCareNotePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Care notes are only visible to the owner and care providers")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	err = h.db.DeleteCareNote(ctx, pregnancy.ID, noteID, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Note not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// RemoveCareProvider unlinks a care provider.
+func (h *Handler) RemoveCareProvider(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	providerID, err := strconv.ParseInt(mux.Vars(r)["providerId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid provider ID")
+		return
+	}
+
+	err = h.db.RemoveCareProvider(ctx, providerID, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Provider not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// notifyCareNote tells providers about owner notes and the owner about provider notes.
+// Failures are logged; the note itself is already saved.
+func (h *Handler) notifyCareNote(ctx context.Context, pregnancy *models.Pregnancy, authorRole string, note *models.CareNote) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"noteId":     note.ID,
+		"authorRole": authorRole,
+	})
+
+	var recipients []string
+	if authorRole == "provider" {
+		recipients = append(recipients, pregnancy.OwnerID)
+	} else {
+		providers, err := h.db.GetCareProviders(ctx, pregnancy.ID)
+		if err != nil {
+			log.Printf("Failed to load care providers for notification: %v", err)
+			return
+		}
+		for _, p := range providers {
+			recipients = append(recipients, p.UserID)
+		}
+	}
+
+	for _, userID := range recipients {
+		if err := h.db.CreateNotification(ctx, userID, pregnancy.ID, "care_note", payload); err != nil {
+			log.Printf("Failed to create care note notification: %v", err)
+		}
+	}
+}
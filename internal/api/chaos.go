@@ -0,0 +1,209 @@
+// Package api provides per-user failure injection for staging, so client
+// teams can test retries and conflict handling against a misbehaving server.
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+const (
+	// maxChaosLatency bounds injected latency, under the server's write timeout.
+	maxChaosLatency = 20 * time.Second
+	// defaultChaosMinutes and maxChaosMinutes bound how long faults stay on.
+	defaultChaosMinutes = 60
+	maxChaosMinutes     = 1440
+)
+
+// chaosSyncKey marks a sync push chosen to fail part way.
+const chaosSyncKey contextKey = "chaosSync"
+
+// errChaosSync is the injected partial sync failure.
+var errChaosSync = errors.New("chaos: injected failure part way through the push")
+
+// chaosFaults holds each user's faults in memory. State is per process.
+type chaosFaults struct {
+	mu    sync.Mutex
+	users map[string]models.ChaosConfig
+}
+
+func newChaosFaults() *chaosFaults {
+	return &chaosFaults{users: make(map[string]models.ChaosConfig)}
+}
+
+// get returns the user's unexpired faults.
+func (c *chaosFaults) get(userID string, now time.Time) (models.ChaosConfig, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cfg, ok := c.users[userID]
+	if ok && !now.Before(cfg.ExpiresAt) {
+		delete(c.users, userID)
+		return models.ChaosConfig{}, false
+	}
+	return cfg, ok
+}
+
+func (c *chaosFaults) set(userID string, cfg models.ChaosConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.users[userID] = cfg
+}
+
+func (c *chaosFaults) clear(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.users, userID)
+}
+
+// chaosMiddleware injects the caller's faults into a route: latency first,
+// then a failed request, or for sync pushes a failure after some entries were
+// written. Injected faults are named in X-Chaos-Injected. It must run after
+// AuthMiddleware.
+func (h *Handler) chaosMiddleware(rt *Route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg, ok := h.chaos.get(getUserInfo(r).UserID, time.Now())
+		if !ok || !chaosCovers(&cfg, rt) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		delay := time.Duration(cfg.LatencyMs) * time.Millisecond
+		if cfg.JitterMs > 0 {
+			delay += time.Duration(rand.Int63n(int64(cfg.JitterMs)+1)) * time.Millisecond
+		}
+		if delay > 0 {
+			w.Header().Add("X-Chaos-Injected", "latency")
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		if cfg.ErrorRate > 0 && rand.Float64() < cfg.ErrorRate {
+			w.Header().Add("X-Chaos-Injected", "error")
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "chaos: injected database error")
+			return
+		}
+		if rt.Key() == "POST /api/sync" && cfg.SyncFailRate > 0 && rand.Float64() < cfg.SyncFailRate {
+			w.Header().Add("X-Chaos-Injected", "sync-partial")
+			r = r.WithContext(context.WithValue(r.Context(), chaosSyncKey, true))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// chaosCovers reports whether the faults apply to the route.
+func chaosCovers(cfg *models.ChaosConfig, rt *Route) bool {
+	if len(cfg.Routes) == 0 {
+		return true
+	}
+	for _, key := range cfg.Routes {
+		if key == rt.Key() {
+			return true
+		}
+	}
+	return false
+}
+
+// chaosSyncFailAt returns the index of the pushed entry at which an injected
+// partial sync failure stops the push, or -1 when none was injected.
+func chaosSyncFailAt(ctx context.Context, entries int) int {
+	if fail, _ := ctx.Value(chaosSyncKey).(bool); !fail {
+		return -1
+	}
+	return entries / 2
+}
+
+// chaosRoute reports whether a registry route manages failure injection and
+// must keep working while faults are on.
+func chaosRoute(rt *Route) bool {
+	return strings.HasPrefix(rt.Path, "/me/chaos")
+}
+
+// GetChaos returns the caller's active faults.
+func (h *Handler) GetChaos(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	if h.chaos == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Failure injection is disabled")
+		return
+	}
+
+	cfg, ok := h.chaos.get(user.UserID, time.Now())
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No faults are injected")
+		return
+	}
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+// UpdateChaos replaces the caller's faults. They expire on their own.
+func (h *Handler) UpdateChaos(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	if h.chaos == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Failure injection is disabled")
+		return
+	}
+
+	var req models.ChaosRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	// Each is bounded before they are added, so the sum can't overflow
+	maxMs := maxChaosLatency.Milliseconds()
+	if req.LatencyMs < 0 || req.JitterMs < 0 || int64(req.LatencyMs) > maxMs || int64(req.JitterMs) > maxMs || int64(req.LatencyMs)+int64(req.JitterMs) > maxMs {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("latencyMs + jitterMs must be 0-%d", maxMs))
+		return
+	}
+	if req.ErrorRate < 0 || req.ErrorRate > 1 || req.SyncFailRate < 0 || req.SyncFailRate > 1 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "errorRate and syncFailRate must be 0-1")
+		return
+	}
+	for _, key := range req.Routes {
+		if rt := routeIndex[key]; rt == nil || chaosRoute(rt) {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Unknown route %q", key))
+			return
+		}
+	}
+	if req.ExpiresInMinutes == 0 {
+		req.ExpiresInMinutes = defaultChaosMinutes
+	}
+	if req.ExpiresInMinutes < 1 || req.ExpiresInMinutes > maxChaosMinutes {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("expiresInMinutes must be 1-%d", maxChaosMinutes))
+		return
+	}
+
+	cfg := models.ChaosConfig{
+		LatencyMs:    req.LatencyMs,
+		JitterMs:     req.JitterMs,
+		ErrorRate:    req.ErrorRate,
+		SyncFailRate: req.SyncFailRate,
+		Routes:       req.Routes,
+		ExpiresAt:    time.Now().Add(time.Duration(req.ExpiresInMinutes) * time.Minute).UTC(),
+	}
+	h.chaos.set(user.UserID, cfg)
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+// DeleteChaos turns the caller's faults off.
+func (h *Handler) DeleteChaos(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	if h.chaos == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Failure injection is disabled")
+		return
+	}
+
+	h.chaos.clear(user.UserID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,148 @@
+// Package api provides community topic links served alongside weekly facts.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// Community topic limits
+const (
+	maxCommunityTopics   = 10
+	maxCommunityTopicLen = 200
+	maxCommunityTitleLen = 200
+)
+
+// communityWeek reads the week of a community route. Only weekly facts carry
+// community topics.
+func (h *Handler) communityWeek(w http.ResponseWriter, r *http.Request) (int, bool) {
+	ck, ok := h.adminContentKind(w, r)
+	if !ok {
+		return 0, false
+	}
+	if ck.kind != models.ContentWeeklyFact {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Community topics are only linked to weekly-facts")
+		return 0, false
+	}
+	week, err := strconv.Atoi(mux.Vars(r)["week"])
+	if err != nil || week < minContentWeek || week > maxContentWeek {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid week")
+		return 0, false
+	}
+	return week, true
+}
+
+// linkCommunityTopics fills in topic links. With publicOnly it keeps only
+// topics of the configured service, without admin details.
+func (h *Handler) linkCommunityTopics(topics []models.CommunityTopic, publicOnly bool) []models.CommunityTopic {
+	linked := make([]models.CommunityTopic, 0, len(topics))
+	for _, t := range topics {
+		if h.community != nil && t.Service == h.community.Service() {
+			t.URL = h.community.Link(t.TopicID)
+		} else if publicOnly {
+			continue
+		}
+		if publicOnly {
+			t.UpdatedBy = ""
+		}
+		linked = append(linked, t)
+	}
+	return linked
+}
+
+// weekCommunityTopics returns the linked topics served with a week of weekly
+// facts, or nil without a community service.
+func (h *Handler) weekCommunityTopics(ctx context.Context, week int) ([]models.CommunityTopic, error) {
+	if h.community == nil {
+		return nil, nil
+	}
+	topics, err := h.db.GetCommunityTopics(ctx, week)
+	if err != nil {
+		return nil, err
+	}
+	return h.linkCommunityTopics(topics, true), nil
+}
+
+// GetCommunityTopics lists the community topics linked to a week of weekly
+// facts, with links for the configured service.
+func (h *Handler) GetCommunityTopics(w http.ResponseWriter, r *http.Request) {
+	week, ok := h.communityWeek(w, r)
+	if !ok {
+		return
+	}
+	topics, err := h.db.GetCommunityTopics(r.Context(), week)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.linkCommunityTopics(topics, false))
+}
+
+// PutCommunityTopics replaces the community topics linked to a week of weekly
+// facts, in the order given. An empty list unlinks them all.
+func (h *Handler) PutCommunityTopics(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	week, ok := h.communityWeek(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CommunityTopicsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if len(req.Topics) > maxCommunityTopics {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("At most %d topics per week", maxCommunityTopics))
+		return
+	}
+
+	topics := make([]models.CommunityTopic, 0, len(req.Topics))
+	seen := make(map[string]bool)
+	for i, in := range req.Topics {
+		service := strings.TrimSpace(in.Service)
+		if service == "" && h.community != nil {
+			service = h.community.Service()
+		}
+		topicID := strings.TrimSpace(in.TopicID)
+		title := strings.TrimSpace(in.Title)
+		switch {
+		case service == "":
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("topics[%d]: service is required when no community service is configured", i))
+			return
+		case len(service) > 50:
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("topics[%d]: service is too long", i))
+			return
+		case topicID == "" || len(topicID) > maxCommunityTopicLen:
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("topics[%d]: topicId is required (max %d characters)", i, maxCommunityTopicLen))
+			return
+		case len(title) > maxCommunityTitleLen:
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("topics[%d]: title is too long (max %d characters)", i, maxCommunityTitleLen))
+			return
+		case seen[service+"/"+topicID]:
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("topics[%d]: duplicate topic", i))
+			return
+		}
+		seen[service+"/"+topicID] = true
+
+		t := models.CommunityTopic{Service: service, TopicID: topicID}
+		if title != "" {
+			t.Title = &title
+		}
+		topics = append(topics, t)
+	}
+
+	saved, err := h.db.ReplaceCommunityTopics(r.Context(), week, topics, user.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.linkCommunityTopics(saved, false))
+}
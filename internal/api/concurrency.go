@@ -0,0 +1,152 @@
+// Package api provides per-user concurrency caps for heavy requests and jobs.
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// heavyQueueWait is how long a heavy request may wait for a slot. It stays under
+// the server's write timeout.
+const heavyQueueWait = 10 * time.Second
+
+// heavyQueue caps how many heavy operations each user runs at once. Excess work
+// waits in FIFO order. State is per process.
+type heavyQueue struct {
+	mu    sync.Mutex
+	limit int
+	users map[string]*heavyUser
+}
+
+type heavyUser struct {
+	running int
+	waiting []*heavyTicket
+}
+
+// heavyTicket is one operation's place in line. ready closes when it may run.
+type heavyTicket struct {
+	jobID int64 // 0 for synchronous requests
+	ready chan struct{}
+}
+
+func newHeavyQueue(limit int) *heavyQueue {
+	if limit < 1 {
+		limit = 1
+	}
+	return &heavyQueue{limit: limit, users: make(map[string]*heavyUser)}
+}
+
+// enqueue gets in line for a slot. Callers wait on ready and must call release
+// once done, or cancel if they give up before ready.
+func (q *heavyQueue) enqueue(userID string, jobID int64) *heavyTicket {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	u := q.users[userID]
+	if u == nil {
+		u = &heavyUser{}
+		q.users[userID] = u
+	}
+	t := &heavyTicket{jobID: jobID, ready: make(chan struct{})}
+	if u.running < q.limit && len(u.waiting) == 0 {
+		u.running++
+		close(t.ready)
+	} else {
+		u.waiting = append(u.waiting, t)
+	}
+	return t
+}
+
+// release frees a slot, handing it to the next waiting operation.
+func (q *heavyQueue) release(userID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	u := q.users[userID]
+	if u == nil {
+		return
+	}
+	if len(u.waiting) > 0 {
+		next := u.waiting[0]
+		u.waiting = u.waiting[1:]
+		close(next.ready)
+		return
+	}
+	u.running--
+	if u.running <= 0 {
+		delete(q.users, userID)
+	}
+}
+
+// cancel takes a waiting ticket out of line and returns the position it had.
+// It returns 0 if the ticket was already granted a slot, which the caller then owns.
+func (q *heavyQueue) cancel(userID string, t *heavyTicket) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	u := q.users[userID]
+	if u == nil {
+		return 0
+	}
+	for i, w := range u.waiting {
+		if w == t {
+			u.waiting = append(u.waiting[:i], u.waiting[i+1:]...)
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// jobPosition returns a queued job's 1-based place in its user's line, or 0
+// when it is running or unknown to this process.
+func (q *heavyQueue) jobPosition(userID string, jobID int64) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	u := q.users[userID]
+	if u == nil {
+		return 0
+	}
+	for i, w := range u.waiting {
+		if w.jobID == jobID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// heavyMiddleware queues a user's heavy requests beyond the concurrency cap. A
+// request that doesn't get a slot within heavyQueueWait is answered 429 with its
+// place in line in X-Queue-Position. Heavy routes run synchronously and are
+// expensive enough to share the cap with background jobs (memory books, file
+// restores). It must run after AuthMiddleware.
+func (h *Handler) heavyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserInfo(r)
+		ticket := h.heavy.enqueue(user.UserID, 0)
+		timer := time.NewTimer(heavyQueueWait)
+		defer timer.Stop()
+
+		select {
+		case <-ticket.ready:
+		case <-timer.C:
+			if pos := h.heavy.cancel(user.UserID, ticket); pos > 0 {
+				w.Header().Set("X-Queue-Position", strconv.Itoa(pos))
+				w.Header().Set("Retry-After", strconv.Itoa(int(heavyQueueWait.Seconds())))
+				writeError(w, http.StatusTooManyRequests, "RATE_LIMITED",
+					fmt.Sprintf("Too many heavy requests running; %d ahead of this one", pos-1+h.heavy.limit))
+				return
+			}
+		case <-r.Context().Done():
+			if h.heavy.cancel(user.UserID, ticket) > 0 {
+				return
+			}
+		}
+		defer h.heavy.release(user.UserID)
+
+		next.ServeHTTP(w, r)
+	})
+}
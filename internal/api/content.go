@@ -0,0 +1,425 @@
+// Package api provides versioned management of weekly facts and baby sizes.
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// contentKind maps a URL segment to stored content and the data file that seeds it.
+type contentKind struct {
+	kind     string
+	file     string
+	required []string // Fields every version must have
+	medical  bool     // Served with the medical disclaimer
+}
+
+var contentKinds = map[string]contentKind{
+	"weekly-facts": {models.ContentWeeklyFact, "WeeklyFacts.json", []string{"babyDevelopment", "motherChanges"}, true},
+	"baby-sizes":   {models.ContentBabySize, "BabySizes.json", []string{"size"}, false},
+}
+
+// Content covers these gestational weeks.
+const (
+	minContentWeek = 1
+	maxContentWeek = 42
+)
+
+// SeedContent loads the data files into the database for kinds that have no
+// content yet, so the files only matter on first start.
+func (h *Handler) SeedContent(ctx context.Context) error {
+	for _, ck := range contentKinds {
+		items, err := h.contentFile(ck)
+		if err != nil {
+			return err
+		}
+		weeks := make(map[int]json.RawMessage, len(items))
+		for _, item := range items {
+			var head struct {
+				Week int `json:"week"`
+			}
+			if err := json.Unmarshal(item, &head); err != nil || head.Week == 0 {
+				return fmt.Errorf("%s: every item needs a week", ck.file)
+			}
+			weeks[head.Week] = item
+		}
+
+		seeded, err := h.db.SeedContent(ctx, ck.kind, weeks)
+		if err != nil {
+			return err
+		}
+		if seeded > 0 {
+			log.Printf("Seeded %d week(s) of %s from %s", seeded, ck.kind, ck.file)
+		}
+	}
+	return nil
+}
+
+// contentFile reads a data file as a list of items.
+func (h *Handler) contentFile(ck contentKind) ([]json.RawMessage, error) {
+	data, err := os.ReadFile(filepath.Join(h.dataPath, ck.file))
+	if err != nil {
+		return nil, err
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("%s: %w", ck.file, err)
+	}
+	return items, nil
+}
+
+// publishedContent returns the published items of a kind in week order, rendered
+// for clients. When the database can't be read it falls back to the data file.
+func (h *Handler) publishedContent(ctx context.Context, ck contentKind, simplified bool) ([]json.RawMessage, time.Time, error) {
+	content, err := h.db.GetPublishedContent(ctx, ck.kind)
+	if err != nil {
+		log.Printf("Content: falling back to %s: %v", ck.file, err)
+		items, err := h.contentFile(ck)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		content = make([]models.ContentVersion, len(items))
+		for i := range items {
+			content[i].Data = items[i]
+		}
+	}
+
+	items := make([]json.RawMessage, len(content))
+	var modified time.Time
+	for i := range content {
+		c := &content[i]
+		if items[i], err = renderContent(ck, c, simplified); err != nil {
+			return nil, time.Time{}, err
+		}
+		if c.PublishedAt.Valid && c.PublishedAt.Time.After(modified) {
+			modified = c.PublishedAt.Time
+		}
+	}
+	return items, modified, nil
+}
+
+// serveContent writes the published items of a kind as a JSON array. Responses
+// are public and long-lived, revalidated by ETag, so CDNs and apps can cache them. The
+// simplified=true query serves plain-language text where it exists.
+func (h *Handler) serveContent(w http.ResponseWriter, r *http.Request, name string) {
+	simplified := r.URL.Query().Get("simplified") == "true"
+	items, modified, err := h.publishedContent(r.Context(), contentKinds[name], simplified)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	body, err := json.Marshal(items)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", CacheStatic)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// GetContentVersions lists versions of weekly facts or baby sizes for admins.
+// Optional query: week, status (draft, published, retired).
+func (h *Handler) GetContentVersions(w http.ResponseWriter, r *http.Request) {
+	ck, ok := h.adminContentKind(w, r)
+	if !ok {
+		return
+	}
+
+	week := 0
+	if s := r.URL.Query().Get("week"); s != "" {
+		var err error
+		if week, err = strconv.Atoi(s); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid week")
+			return
+		}
+	}
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.ContentDraft, models.ContentPublished, models.ContentRetired:
+	default:
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "status must be draft, published or retired")
+		return
+	}
+
+	content, err := h.db.GetContentVersions(r.Context(), ck.kind, week, status)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if content == nil {
+		content = []models.ContentVersion{}
+	}
+	writeJSON(w, http.StatusOK, content)
+}
+
+// CreateContentDraft adds a draft as the week's next version.
+func (h *Handler) CreateContentDraft(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ck, ok := h.adminContentKind(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ContentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if req.Week == nil || *req.Week < minContentWeek || *req.Week > maxContentWeek {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("week must be between %d and %d", minContentWeek, maxContentWeek))
+		return
+	}
+	draft, msg := contentDraft(ck, *req.Week, &req)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+	draft.CreatedBy = sql.NullString{String: user.UserID, Valid: true}
+
+	c, err := h.db.CreateContentDraft(r.Context(), draft)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+// UpdateContentDraft replaces a draft's data, simplified text, accessibility
+// metadata, sources and review. Published and retired versions are immutable;
+// use ReviewContent to record a new review of them.
+func (h *Handler) UpdateContentDraft(w http.ResponseWriter, r *http.Request) {
+	ck, ok := h.adminContentKind(w, r)
+	if !ok {
+		return
+	}
+	week, version, ok := contentVersionVars(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ContentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	draft, msg := contentDraft(ck, week, &req)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+	draft.Version = version
+
+	c, err := h.db.UpdateContentDraft(r.Context(), draft)
+	if !writeContentError(w, err) {
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// DeleteContentDraft deletes a draft.
+func (h *Handler) DeleteContentDraft(w http.ResponseWriter, r *http.Request) {
+	ck, ok := h.adminContentKind(w, r)
+	if !ok {
+		return
+	}
+	week, version, ok := contentVersionVars(w, r)
+	if !ok {
+		return
+	}
+
+	if !writeContentError(w, h.db.DeleteContentDraft(r.Context(), ck.kind, week, version)) {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PublishContent makes a version live for its week. Publishing a retired version rolls back.
+func (h *Handler) PublishContent(w http.ResponseWriter, r *http.Request) {
+	ck, ok := h.adminContentKind(w, r)
+	if !ok {
+		return
+	}
+	week, version, ok := contentVersionVars(w, r)
+	if !ok {
+		return
+	}
+
+	c, err := h.db.PublishContent(r.Context(), ck.kind, week, version)
+	if !writeContentError(w, err) {
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// UnpublishContent removes a week from the public data, keeping its versions.
+func (h *Handler) UnpublishContent(w http.ResponseWriter, r *http.Request) {
+	ck, ok := h.adminContentKind(w, r)
+	if !ok {
+		return
+	}
+	week, err := strconv.Atoi(mux.Vars(r)["week"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid week")
+		return
+	}
+
+	err = h.db.UnpublishContent(r.Context(), ck.kind, week)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Week has no published version")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminContentKind resolves the {kind} route variable of an admin route.
+func (h *Handler) adminContentKind(w http.ResponseWriter, r *http.Request) (contentKind, bool) {
+	ck, ok := contentKinds[mux.Vars(r)["kind"]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Unknown content kind")
+		return contentKind{}, false
+	}
+	return ck, true
+}
+
+// contentVersionVars parses the {week} and {version} route variables.
+func contentVersionVars(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	vars := mux.Vars(r)
+	week, err := strconv.Atoi(vars["week"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid week")
+		return 0, 0, false
+	}
+	version, err := strconv.Atoi(vars["version"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid version")
+		return 0, 0, false
+	}
+	return week, version, true
+}
+
+// writeContentError answers a failed version lookup or change. It returns true when err is nil.
+func writeContentError(w http.ResponseWriter, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case db.ErrNotFound:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Content version not found")
+	case db.ErrConflict:
+		writeError(w, http.StatusConflict, "CONFLICT", "Only drafts can be changed")
+	default:
+		writeDomainError(w, err)
+	}
+	return false
+}
+
+// contentDraft validates a draft request into a version.
+func contentDraft(ck contentKind, week int, req *models.ContentRequest) (*models.ContentVersion, string) {
+	data, msg := normalizeContent(ck, week, req.Data)
+	if msg != "" {
+		return nil, msg
+	}
+
+	simplified := json.RawMessage(`{}`)
+	if len(req.Simplified) > 0 && string(req.Simplified) != "null" {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(req.Simplified, &fields) != nil || fields == nil {
+			return nil, "simplified must be a JSON object"
+		}
+		if _, ok := fields["week"]; ok {
+			return nil, "simplified cannot override week"
+		}
+		simplified = req.Simplified
+	}
+	for _, a := range []*models.ContentAccessibility{req.Accessibility, req.SimplifiedAccessibility} {
+		if a != nil && a.AudioURL != "" && !strings.HasPrefix(a.AudioURL, "https://") {
+			return nil, "audioUrl must be an https URL"
+		}
+	}
+
+	plain, err := overlayContent(data, simplified)
+	if err != nil {
+		return nil, err.Error()
+	}
+	simplifiedMeta := json.RawMessage(`{}`)
+	if hasFields(simplified) {
+		simplifiedMeta = prepareAccessibility(req.SimplifiedAccessibility, plain)
+	}
+
+	sources, msg := contentSources(req.Sources)
+	if msg != "" {
+		return nil, msg
+	}
+	reviewedBy, reviewedAt, msg := contentReview(req.ReviewedBy, req.ReviewedAt, time.Now())
+	if msg != "" {
+		return nil, msg
+	}
+
+	return &models.ContentVersion{
+		Kind:                    ck.kind,
+		Week:                    week,
+		Data:                    data,
+		Accessibility:           prepareAccessibility(req.Accessibility, data),
+		Simplified:              simplified,
+		SimplifiedAccessibility: simplifiedMeta,
+		Sources:                 sources,
+		ReviewedBy:              reviewedBy,
+		ReviewedAt:              reviewedAt,
+	}, ""
+}
+
+// normalizeContent checks a version's data and stamps it with its week, keeping
+// the shape of the data files (id and week both equal the week).
+func normalizeContent(ck contentKind, week int, data json.RawMessage) (json.RawMessage, string) {
+	var fields map[string]json.RawMessage
+	if len(data) == 0 || json.Unmarshal(data, &fields) != nil || fields == nil {
+		return nil, "data must be a JSON object"
+	}
+	for _, name := range ck.required {
+		var s string
+		if json.Unmarshal(fields[name], &s) != nil || s == "" {
+			return nil, fmt.Sprintf("data.%s is required", name)
+		}
+	}
+
+	stamp, _ := json.Marshal(week)
+	fields["id"] = stamp
+	fields["week"] = stamp
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err.Error()
+	}
+	return out, ""
+}
@@ -0,0 +1,182 @@
+// Package api provides source citations and medical review tracking for weekly content.
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// contentDisclaimer is served with every weekly fact and printed in memory books.
+const contentDisclaimer = "General information for education only, not medical advice. Every pregnancy is different; talk to your doctor or midwife about yours."
+
+// Limits on content sources and reviews
+const (
+	maxContentSources     = 20
+	maxSourceTitleLen     = 300
+	maxSourcePublisherLen = 200
+	maxReviewerLen        = 200
+)
+
+// Content is due for review this many days after its last one.
+const (
+	defaultReviewDays = 365
+	minReviewDays     = 30
+	maxReviewDays     = 3 * 365
+)
+
+// contentSources validates a version's citations into their stored form.
+func contentSources(sources []models.ContentSource) (json.RawMessage, string) {
+	if len(sources) > maxContentSources {
+		return nil, fmt.Sprintf("at most %d sources", maxContentSources)
+	}
+	for i := range sources {
+		s := &sources[i]
+		s.Title = strings.TrimSpace(s.Title)
+		s.Publisher = strings.TrimSpace(s.Publisher)
+		if s.Title == "" || len(s.Title) > maxSourceTitleLen {
+			return nil, fmt.Sprintf("sources[%d].title is required (max %d characters)", i, maxSourceTitleLen)
+		}
+		if len(s.Publisher) > maxSourcePublisherLen {
+			return nil, fmt.Sprintf("sources[%d].publisher must be at most %d characters", i, maxSourcePublisherLen)
+		}
+		if s.URL != "" && !strings.HasPrefix(s.URL, "https://") {
+			return nil, fmt.Sprintf("sources[%d].url must be an https URL", i)
+		}
+	}
+	if sources == nil {
+		sources = []models.ContentSource{}
+	}
+	out, err := json.Marshal(sources)
+	if err != nil {
+		return nil, err.Error()
+	}
+	return out, ""
+}
+
+// contentReview validates who reviewed content and when. reviewedAt defaults
+// to now and can't be set without a reviewer.
+func contentReview(reviewedBy string, reviewedAt *string, now time.Time) (sql.NullString, sql.NullTime, string) {
+	reviewedBy = strings.TrimSpace(reviewedBy)
+	if reviewedBy == "" {
+		if reviewedAt != nil {
+			return sql.NullString{}, sql.NullTime{}, "reviewedAt needs reviewedBy"
+		}
+		return sql.NullString{}, sql.NullTime{}, ""
+	}
+	if len(reviewedBy) > maxReviewerLen {
+		return sql.NullString{}, sql.NullTime{}, fmt.Sprintf("reviewedBy must be at most %d characters", maxReviewerLen)
+	}
+
+	at := now
+	if reviewedAt != nil {
+		t, err := time.Parse(time.RFC3339, *reviewedAt)
+		if err != nil {
+			return sql.NullString{}, sql.NullTime{}, "reviewedAt must be an RFC3339 timestamp"
+		}
+		if t.After(now.Add(time.Hour)) {
+			return sql.NullString{}, sql.NullTime{}, "reviewedAt cannot be in the future"
+		}
+		at = t
+	}
+	return sql.NullString{String: reviewedBy, Valid: true}, sql.NullTime{Time: at, Valid: true}, ""
+}
+
+// addContentReview adds a version's sources, review and, for medical content,
+// the disclaimer to its rendered fields.
+func addContentReview(ck contentKind, c *models.ContentVersion, fields map[string]json.RawMessage) {
+	if hasItems(c.Sources) {
+		fields["sources"] = c.Sources
+	}
+	if c.ReviewedBy.Valid {
+		fields["reviewedBy"], _ = json.Marshal(c.ReviewedBy.String)
+	}
+	if c.ReviewedAt.Valid {
+		fields["reviewedAt"], _ = json.Marshal(c.ReviewedAt.Time.UTC().Format(time.RFC3339))
+	}
+	if ck.medical {
+		fields["disclaimer"], _ = json.Marshal(contentDisclaimer)
+	}
+}
+
+// hasItems reports whether raw is a non-empty JSON array.
+func hasItems(raw json.RawMessage) bool {
+	var items []json.RawMessage
+	return json.Unmarshal(raw, &items) == nil && len(items) > 0
+}
+
+// ReviewContent records a medical review of a version (body: reviewedBy,
+// reviewedAt, sources). Unlike edits it works on published versions, which stay live.
+func (h *Handler) ReviewContent(w http.ResponseWriter, r *http.Request) {
+	ck, ok := h.adminContentKind(w, r)
+	if !ok {
+		return
+	}
+	week, version, ok := contentVersionVars(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ContentReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.ReviewedBy) == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "reviewedBy is required")
+		return
+	}
+	reviewedBy, reviewedAt, msg := contentReview(req.ReviewedBy, req.ReviewedAt, time.Now())
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+	var sources json.RawMessage
+	if req.Sources != nil {
+		if sources, msg = contentSources(*req.Sources); msg != "" {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+			return
+		}
+	}
+
+	c, err := h.db.ReviewContent(r.Context(), ck.kind, week, version, reviewedBy.String, reviewedAt.Time, sources)
+	if !writeContentError(w, err) {
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+// GetStaleContent lists published versions due for review: never reviewed, or
+// last reviewed longer ago than the interval (query: days, default 365).
+func (h *Handler) GetStaleContent(w http.ResponseWriter, r *http.Request) {
+	ck, ok := h.adminContentKind(w, r)
+	if !ok {
+		return
+	}
+
+	days := defaultReviewDays
+	if s := r.URL.Query().Get("days"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < minReviewDays || n > maxReviewDays {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("days must be between %d and %d", minReviewDays, maxReviewDays))
+			return
+		}
+		days = n
+	}
+
+	stale, err := h.db.GetStaleContent(r.Context(), ck.kind, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if stale == nil {
+		stale = []models.ContentVersion{}
+	}
+	writeJSON(w, http.StatusOK, models.StaleContentReport{ReviewIntervalDays: days, Stale: stale})
+}
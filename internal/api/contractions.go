@@ -0,0 +1,250 @@
+// Package api provides contraction timer analytics with 5-1-1 detection.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// contractionEntryTypes are the entry types contractions are logged as.
+var contractionEntryTypes = []string{"contraction", "contraction_session"}
+
+const (
+	// defaultContractionRule is contractions at most 5 minutes apart, lasting
+	// at least 1 minute, for 1 hour.
+	defaultContractionRule = "5-1-1"
+	// defaultContractionWindow is the rolling window averages cover.
+	defaultContractionWindow = 60
+	// maxContractionWindow caps windowMinutes and the rule's hours.
+	maxContractionWindow = 24 * 60
+)
+
+// contraction is one timed contraction.
+type contraction struct {
+	start    time.Time
+	duration time.Duration // 0 when only the start was logged
+}
+
+// contractionPayload is the fields read from a contraction or one element of
+// a contraction_session's contractions.
+type contractionPayload struct {
+	StartTime       string            `json:"startTime"`
+	EndTime         string            `json:"endTime"`
+	DurationSeconds *float64          `json:"durationSeconds"`
+	Contractions    []json.RawMessage `json:"contractions"` // contraction_session only
+}
+
+// parseContraction reads one contraction, starting at fallback unless the
+// payload has a startTime.
+func parseContraction(p contractionPayload, fallback time.Time) (contraction, bool) {
+	c := contraction{start: fallback}
+	if t, err := time.Parse(time.RFC3339, p.StartTime); err == nil {
+		c.start = t
+	}
+	if c.start.IsZero() {
+		return c, false
+	}
+	if p.DurationSeconds != nil && *p.DurationSeconds > 0 {
+		c.duration = time.Duration(*p.DurationSeconds * float64(time.Second))
+	} else if t, err := time.Parse(time.RFC3339, p.EndTime); err == nil && t.After(c.start) {
+		c.duration = t.Sub(c.start)
+	}
+	return c, true
+}
+
+// parseContractions reads an entry's contractions: each element of a
+// contraction_session's contractions, or the entry itself.
+func parseContractions(e models.TimedEntry) []contraction {
+	var p contractionPayload
+	if json.Unmarshal(e.Data, &p) != nil {
+		return []contraction{{start: e.At}}
+	}
+	if len(p.Contractions) == 0 {
+		c, _ := parseContraction(p, e.At)
+		return []contraction{c}
+	}
+
+	var out []contraction
+	for _, raw := range p.Contractions {
+		var cp contractionPayload
+		if json.Unmarshal(raw, &cp) != nil {
+			continue
+		}
+		if c, ok := parseContraction(cp, time.Time{}); ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// parseContractionRule reads a rule like 5-1-1: interval minutes, duration
+// minutes and hours sustained.
+func parseContractionRule(s string) (models.ContractionRule, bool) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 3 {
+		return models.ContractionRule{}, false
+	}
+	var v [3]float64
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n <= 0 {
+			return models.ContractionRule{}, false
+		}
+		v[i] = n
+	}
+	if v[2]*60 > maxContractionWindow {
+		return models.ContractionRule{}, false
+	}
+	return models.ContractionRule{
+		Name:               s,
+		MaxIntervalMinutes: v[0],
+		MinDurationSeconds: v[1] * 60,
+		SustainMinutes:     v[2] * 60,
+	}, true
+}
+
+// contractionAverages averages the duration of contractions and the intervals
+// between their starts. Contractions without a duration are left out of it.
+func contractionAverages(cs []contraction) (duration, interval *float64) {
+	var durSum float64
+	var durCount int
+	for _, c := range cs {
+		if c.duration > 0 {
+			durSum += c.duration.Seconds()
+			durCount++
+		}
+	}
+	if durCount > 0 {
+		v := round2(durSum / float64(durCount))
+		duration = &v
+	}
+	if len(cs) > 1 {
+		v := round2(cs[len(cs)-1].start.Sub(cs[0].start).Minutes() / float64(len(cs)-1))
+		interval = &v
+	}
+	return duration, interval
+}
+
+// contractionsSince returns the contractions, oldest first, that started at
+// or after since.
+func contractionsSince(cs []contraction, since time.Time) []contraction {
+	i := sort.Search(len(cs), func(i int) bool { return !cs[i].start.Before(since) })
+	return cs[i:]
+}
+
+// GetContractionAnalytics averages contraction duration and interval over a
+// rolling window ending now and checks the 5-1-1 rule, or another given as
+// rule, over the rule's period. Query: windowMinutes (default 60, max 1440),
+// rule (interval minutes-duration minutes-hours, default 5-1-1).
+func (h *Handler) GetContractionAnalytics(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	window := defaultContractionWindow
+	if s := q.Get("windowMinutes"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxContractionWindow {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("windowMinutes must be between 1 and %d", maxContractionWindow))
+			return
+		}
+		window = n
+	}
+	ruleName := q.Get("rule")
+	if ruleName == "" {
+		ruleName = defaultContractionRule
+	}
+	rule, ok := parseContractionRule(ruleName)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "rule must be interval minutes-duration minutes-hours, e.g. 5-1-1, over at most 24 hours")
+		return
+	}
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if _, until, snoozed := activeSnooze(pregnancy, user.UserID, time.Now()); snoozed {
+		writeError(w, http.StatusForbidden, "SNOOZED", "Sharing is paused until "+until.Format(time.RFC3339))
+		return
+	}
+
+	now := time.Now()
+	windowStart := now.Add(-time.Duration(window) * time.Minute)
+	ruleStart := now.Add(-time.Duration(rule.SustainMinutes * float64(time.Minute)))
+	since := windowStart
+	if ruleStart.Before(since) {
+		since = ruleStart
+	}
+
+	// A session entry is dated by its first contraction, so read back far
+	// enough to catch one that started before the window
+	entries, err := h.db.GetTimedEntries(ctx, pregnancy.ID, contractionEntryTypes, since.Add(-time.Duration(maxContractionWindow)*time.Minute), now.Add(time.Minute), entryAudience(pregnancy, user.UserID))
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	var all []contraction
+	for _, e := range entries {
+		all = append(all, parseContractions(e)...)
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].start.Before(all[j].start) })
+	all = contractionsSince(all, since)
+
+	resp := models.ContractionAnalyticsResponse{
+		WindowMinutes: window,
+		WindowStart:   windowStart,
+		Contractions:  []models.ContractionPoint{},
+		Rule:          rule,
+	}
+
+	inWindow := contractionsSince(all, windowStart)
+	for i, c := range inWindow {
+		p := models.ContractionPoint{Start: c.start}
+		if c.duration > 0 {
+			seconds := round2(c.duration.Seconds())
+			p.DurationSeconds = &seconds
+		}
+		if i > 0 {
+			minutes := round2(c.start.Sub(inWindow[i-1].start).Minutes())
+			p.IntervalMinutes = &minutes
+		}
+		resp.Contractions = append(resp.Contractions, p)
+	}
+	resp.Count = len(inWindow)
+	resp.AvgDurationSeconds, resp.AvgIntervalMinutes = contractionAverages(inWindow)
+	if n := len(all); n > 0 {
+		last := all[n-1].start
+		resp.LastContractionAt = &last
+	}
+
+	// The rule holds when contractions over its whole period are close
+	// enough and long enough on average, and still going on
+	inRule := contractionsSince(all, ruleStart)
+	maxGap := time.Duration(rule.MaxIntervalMinutes * float64(time.Minute))
+	checks := &resp.Checks
+	if duration, interval := contractionAverages(inRule); interval != nil {
+		checks.AvgDurationSeconds = duration
+		checks.AvgIntervalMinutes = interval
+		checks.Interval = *interval <= rule.MaxIntervalMinutes
+		checks.Duration = duration != nil && *duration >= rule.MinDurationSeconds
+		checks.Sustained = inRule[0].start.Sub(ruleStart) <= maxGap && now.Sub(inRule[len(inRule)-1].start) <= maxGap
+	}
+	resp.RuleMet = checks.Interval && checks.Duration && checks.Sustained
+
+	writeJSON(w, http.StatusOK, resp)
+}
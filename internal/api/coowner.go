@@ -0,0 +1,221 @@
+// Package api provides coowner status, history and auditing.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/pagination"
+)
+
+// getCoownerPregnancy loads the pregnancy from the {id} route variable if the
+// user is its owner or coowner. It writes the error response and returns nil otherwise.
+func (h *Handler) getCoownerPregnancy(w http.ResponseWriter, r *http.Request) *models.Pregnancy {
+	user := getUserInfo(r)
+	pregnancyID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid pregnancy ID")
+		return nil
+	}
+
+	pregnancy, err := h.db.GetPregnancyByID(r.Context(), pregnancyID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Pregnancy not found")
+		return nil
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return nil
+	}
+	if pregnancy.OwnerID != user.UserID && !(pregnancy.CoownerID.Valid && pregnancy.CoownerID.String == user.UserID) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only the owner or coowner can manage the coowner")
+		return nil
+	}
+	return pregnancy
+}
+
+// GetCoownerStatus returns the pregnancy's coowner, the history of coowner
+// changes and recent actions taken as coowner.
+func (h *Handler) GetCoownerStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	pregnancy := h.getCoownerPregnancy(w, r)
+	if pregnancy == nil {
+		return
+	}
+
+	history, err := h.db.GetCoownerHistory(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	actions, err := h.db.GetCoownerActions(ctx, pregnancy.ID, models.AuditFilter{}, legacyPage)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if history == nil {
+		history = []models.CoownerEvent{}
+	}
+
+	resp := models.CoownerStatusResponse{
+		Status:  "none",
+		History: history,
+		Actions: pagination.NewPage(actions, legacyPage, coownerActionCursor).Items,
+	}
+	if pregnancy.CoownerID.Valid {
+		resp.Status = "linked"
+		resp.Coowner = &models.CoownerInfo{
+			ID:   pregnancy.CoownerID.String,
+			Name: pregnancy.CoownerName.String,
+		}
+		// History is newest first, so the first link of the current coowner is the latest
+		for _, e := range history {
+			if e.CoownerID == pregnancy.CoownerID.String && e.Action == "linked" {
+				linkedAt := e.CreatedAt.Format(time.RFC3339)
+				resp.Coowner.LinkedAt = &linkedAt
+				break
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GetCoownerActions pages through the full audit of requests taken as coowner.
+// The status endpoint only shows the most recent ones.
+func (h *Handler) GetCoownerActions(w http.ResponseWriter, r *http.Request) {
+	pregnancy := h.getCoownerPregnancy(w, r)
+	if pregnancy == nil {
+		return
+	}
+	params, ok := readPage(w, r)
+	if !ok {
+		return
+	}
+
+	actions, err := h.db.GetCoownerActions(r.Context(), pregnancy.ID, models.AuditFilter{}, params)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pagination.NewPage(actions, params, coownerActionCursor))
+}
+
+// RemoveCoowner unlinks the coowner. The owner can remove them, and the
+// coowner can leave.
+func (h *Handler) RemoveCoowner(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	pregnancy := h.getCoownerPregnancy(w, r)
+	if pregnancy == nil {
+		return
+	}
+
+	coownerID, err := h.db.RemoveCoowner(ctx, pregnancy.ID, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No coowner linked")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	// Tell whoever didn't make the change
+	recipient, action := coownerID, "removed"
+	if user.UserID == coownerID {
+		recipient, action = pregnancy.OwnerID, "left"
+	}
+	h.notifyCoowner(ctx, recipient, pregnancy.ID, "coowner_"+action, coownerID)
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// notifyCoowner sends a coowner change notification.
+func (h *Handler) notifyCoowner(ctx context.Context, userID string, pregnancyID int64, kind, coownerID string) {
+	payload, _ := json.Marshal(map[string]interface{}{"coownerId": coownerID})
+	if err := h.db.CreateNotification(ctx, userID, pregnancyID, kind, payload); err != nil {
+		log.Printf("Failed to create %s notification: %v", kind, err)
+	}
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer to flush streams.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// CoownerAuditMiddleware records every write request a coowner makes against
+// the pregnancy they coown, with its response status.
+func (h *Handler) CoownerAuditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user := getUserInfo(r)
+		pregnancy, err := h.db.GetPregnancyByCoowner(r.Context(), user.UserID)
+		if err != nil {
+			if err != db.ErrNotFound {
+				log.Printf("Coowner audit: %v", err)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if !h.actsAsCoowner(r, pregnancy, user.UserID) {
+			return
+		}
+		action := &models.CoownerAction{
+			PregnancyID: pregnancy.ID,
+			CoownerID:   user.UserID,
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			Entity:      auditEntity(r.URL.Path),
+			Status:      rec.status,
+		}
+		// Auditing is best effort and must not slow down the response
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := h.db.CreateCoownerAction(ctx, action); err != nil {
+				log.Printf("Failed to record coowner action: %v", err)
+			}
+		}()
+	})
+}
+
+// actsAsCoowner reports whether a request by a coowner targeted the pregnancy
+// they coown: by ID on /api/pregnancies/{id} routes, otherwise through the
+// same access resolution the handlers use.
+func (h *Handler) actsAsCoowner(r *http.Request, coowned *models.Pregnancy, userID string) bool {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil && strings.HasPrefix(tpl, "/api/pregnancies/{id}") {
+			return mux.Vars(r)["id"] == strconv.FormatInt(coowned.ID, 10)
+		}
+	}
+	a, err := h.resolveAccess(r.Context(), userID)
+	return err == nil && a.role == "coowner"
+}
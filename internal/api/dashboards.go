@@ -0,0 +1,269 @@
+// Package api provides saved analytics dashboard handlers.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// Widget kinds accepted in dashboard definitions.
+var dashboardWidgetKinds = map[string]bool{
+	models.WidgetWeightChart:      true,
+	models.WidgetKickHeatmap:      true,
+	models.WidgetSymptomFrequency: true,
+	models.WidgetWaterIntake:      true,
+	models.WidgetContractionChart: true,
+}
+
+// Dashboard limits
+const (
+	maxDashboardWidgets = 24
+	dashboardGridWidth  = 12
+)
+
+// getDashboardPregnancy returns the pregnancy and whether the user may edit its
+// dashboards. Everyone with access can view; only the owner and coowner edit.
+func (h *Handler) getDashboardPregnancy(ctx context.Context, userID string) (*models.Pregnancy, bool, error) {
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	canEdit := pregnancy.OwnerID == userID || (pregnancy.CoownerID.Valid && pregnancy.CoownerID.String == userID)
+	return pregnancy, canEdit, nil
+}
+
+// GetDashboards lists the pregnancy's dashboards.
+func (h *Handler) GetDashboards(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, canEdit, err := h.getDashboardPregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	dashboards, err := h.db.GetDashboards(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if dashboards == nil {
+		dashboards = []models.Dashboard{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"dashboards": dashboards,
+		"canEdit":    canEdit,
+	})
+}
+
+// GetDashboard gets a single dashboard.
+func (h *Handler) GetDashboard(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	dashboardID, err := strconv.ParseInt(mux.Vars(r)["dashboardId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid dashboard ID")
+		return
+	}
+
+	pregnancy, _, err := h.getDashboardPregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	dashboard, err := h.db.GetDashboard(ctx, pregnancy.ID, dashboardID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Dashboard not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, dashboard)
+}
+
+// CreateDashboard saves a new dashboard.
+func (h *Handler) CreateDashboard(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, canEdit, err := h.getDashboardPregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if !canEdit {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Dashboards are read-only for partners and supporters")
+		return
+	}
+
+	var req models.DashboardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	widgets, msg := validateDashboard(&req)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+
+	dashboard, err := h.db.CreateDashboard(ctx, pregnancy.ID, user.UserID, strings.TrimSpace(req.Name), req.Position, widgets)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, dashboard)
+}
+
+// UpdateDashboard replaces a dashboard's name, widgets and layout.
+func (h *Handler) UpdateDashboard(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	dashboardID, err := strconv.ParseInt(mux.Vars(r)["dashboardId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid dashboard ID")
+		return
+	}
+
+	pregnancy, canEdit, err := h.getDashboardPregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if !canEdit {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Dashboards are read-only for partners and supporters")
+		return
+	}
+
+	var req models.DashboardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	widgets, msg := validateDashboard(&req)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+
+	dashboard, err := h.db.UpdateDashboard(ctx, pregnancy.ID, dashboardID, strings.TrimSpace(req.Name), req.Position, widgets)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Dashboard not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, dashboard)
+}
+
+// DeleteDashboard deletes a dashboard.
+func (h *Handler) DeleteDashboard(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	dashboardID, err := strconv.ParseInt(mux.Vars(r)["dashboardId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid dashboard ID")
+		return
+	}
+
+	pregnancy, canEdit, err := h.getDashboardPregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if !canEdit {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Dashboards are read-only for partners and supporters")
+		return
+	}
+
+	err = h.db.DeleteDashboard(ctx, pregnancy.ID, dashboardID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Dashboard not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// validateDashboard checks the definition and returns the widgets as JSON, or a
+// validation message.
+func validateDashboard(req *models.DashboardRequest) (json.RawMessage, string) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 100 {
+		return nil, "name is required (max 100 characters)"
+	}
+	if len(req.Widgets) > maxDashboardWidgets {
+		return nil, fmt.Sprintf("At most %d widgets per dashboard", maxDashboardWidgets)
+	}
+
+	ids := make(map[string]bool, len(req.Widgets))
+	for _, wd := range req.Widgets {
+		if wd.ID == "" || ids[wd.ID] {
+			return nil, "Each widget needs a unique id"
+		}
+		ids[wd.ID] = true
+		if !dashboardWidgetKinds[wd.Kind] {
+			return nil, "Unknown widget kind: " + wd.Kind
+		}
+		if wd.X < 0 || wd.Y < 0 || wd.W < 1 || wd.H < 1 || wd.X+wd.W > dashboardGridWidth {
+			return nil, fmt.Sprintf("Widget %s does not fit the %d-column grid", wd.ID, dashboardGridWidth)
+		}
+	}
+
+	widgets := req.Widgets
+	if widgets == nil {
+		widgets = []models.DashboardWidget{}
+	}
+	data, err := json.Marshal(widgets)
+	if err != nil {
+		return nil, "Invalid widgets"
+	}
+	return data, ""
+}
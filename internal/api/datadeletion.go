@@ -0,0 +1,51 @@
+// Package api provides deletion of everything stored about a user.
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// DeleteUserData deletes the caller's pregnancies with all their data, their
+// supporter and care provider memberships and their per-user records. Partners
+// and coowners of someone else's pregnancy must leave it first (409).
+func (h *Handler) DeleteUserData(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	if isDryRun(r) {
+		preview, err := h.db.DryRunDeleteUserData(ctx, user.UserID)
+		if err == db.ErrConflict {
+			writeError(w, http.StatusConflict, "CONFLICT", "Leave pregnancies shared with you as partner or coowner first")
+			return
+		}
+		writeDryRun(w, preview, err, "No data found")
+		return
+	}
+
+	files, err := h.db.DeleteUserData(ctx, user.UserID)
+	if err == db.ErrConflict {
+		writeError(w, http.StatusConflict, "CONFLICT", "Leave pregnancies shared with you as partner or coowner first")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	h.removeStoredFiles(files)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeStoredFiles removes the content of deleted files from storage. Their
+// rows are already gone, so failures are only logged.
+func (h *Handler) removeStoredFiles(files []models.File) {
+	for _, f := range files {
+		if err := h.storage.Remove(f.Region, f.StorageTier, f.StoragePath); err != nil {
+			log.Printf("Failed to remove file %d: %v", f.ID, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Package api provides entry data version checks and reporting.
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/entrydata"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// validateDataVersion checks the payload version of an entry request.
+func validateDataVersion(req *models.EntryRequest) string {
+	if req.DataVersion != nil && *req.DataVersion < 1 {
+		return "dataVersion must be at least 1"
+	}
+	return ""
+}
+
+// noteUnknownDataVersions records entries written in a payload version newer than
+// this server knows. They are still stored as sent so newer servers can read them.
+func (h *Handler) noteUnknownDataVersions(r *http.Request, entries []models.EntryRequest) {
+	appVersion := requestAppVersion(r)
+	for _, e := range entries {
+		if e.DataVersion == nil || *e.DataVersion <= entrydata.Current(e.EntryType) {
+			continue
+		}
+		go func(entryType string, version int) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := h.db.RecordUnknownDataVersion(ctx, entryType, version, appVersion); err != nil {
+				log.Printf("Failed to record unknown data version: %v", err)
+			}
+		}(e.EntryType, *e.DataVersion)
+	}
+}
+
+// GetDataVersionReport lists current payload versions and unknown versions clients sent.
+func (h *Handler) GetDataVersionReport(w http.ResponseWriter, r *http.Request) {
+	unknown, err := h.db.GetUnknownDataVersions(r.Context())
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if unknown == nil {
+		unknown = []models.UnknownDataVersion{}
+	}
+
+	writeJSON(w, http.StatusOK, models.DataVersionsResponse{
+		Current: entrydata.Versions(),
+		Unknown: unknown,
+	})
+}
@@ -0,0 +1,265 @@
+// Package api provides demo pregnancies populated with generated data.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/pkg/gestation"
+)
+
+// Demo week bounds and default.
+const (
+	minDemoWeek     = 4
+	maxDemoWeek     = 41
+	defaultDemoWeek = 24
+)
+
+// demoMilestones are added once the simulated pregnancy reaches their week.
+var demoMilestones = []struct {
+	week  int
+	title string
+}{
+	{6, "First heartbeat"},
+	{12, "End of the first trimester"},
+	{20, "Anatomy scan"},
+	{24, "Viability milestone"},
+	{28, "Third trimester"},
+	{37, "Full term"},
+}
+
+// Symptoms generated for each trimester.
+var demoSymptoms = [][]string{
+	{"nausea", "fatigue", "breast_tenderness", "food_aversion"},
+	{"heartburn", "round_ligament_pain", "nasal_congestion", "leg_cramps"},
+	{"back_pain", "swelling", "insomnia", "braxton_hicks", "heartburn"},
+}
+
+// StartDemo creates a demo pregnancy at the requested week, filled with realistic
+// entries. An existing demo is replaced; a real pregnancy blocks the demo.
+func (h *Handler) StartDemo(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	var req models.DemoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	week := defaultDemoWeek
+	if req.Week != nil {
+		week = *req.Week
+	}
+	if week < minDemoWeek || week > maxDemoWeek {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("week must be between %d and %d", minDemoWeek, maxDemoWeek))
+		return
+	}
+
+	existing, err := h.db.GetPregnancyByOwner(ctx, user.UserID)
+	if err != nil && err != db.ErrNotFound {
+		writeDomainError(w, err)
+		return
+	}
+	if existing != nil {
+		if !existing.Demo {
+			writeError(w, http.StatusConflict, "CONFLICT", "Account already has a pregnancy")
+			return
+		}
+		if err := h.deleteDemo(r, user.UserID); err != nil && err != db.ErrNotFound {
+			writeDomainError(w, err)
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	dueDate := gestation.DueDate(gestation.Day(now).AddDate(0, 0, -week*7))
+	entries := demoEntries(week, now, rand.New(rand.NewSource(now.UnixNano())))
+
+	pregnancy, err := h.db.CreateDemoPregnancy(ctx, user.UserID, dueDate, "Demo Baby", "Demo Mom", entries)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.DemoResponse{
+		Pregnancy: h.toPregnancyDTO(pregnancy),
+		Week:      week,
+		Entries:   len(entries),
+	})
+}
+
+// StopDemo deletes the user's demo pregnancy with all of its data.
+func (h *Handler) StopDemo(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	if isDryRun(r) {
+		preview, err := h.db.DryRunDeleteDemoPregnancy(r.Context(), user.UserID)
+		writeDryRun(w, preview, err, "No demo pregnancy found")
+		return
+	}
+
+	err := h.deleteDemo(r, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No demo pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteDemo removes the demo pregnancy and any files uploaded while demoing.
+func (h *Handler) deleteDemo(r *http.Request, userID string) error {
+	files, err := h.db.DeleteDemoPregnancy(r.Context(), userID)
+	if err != nil {
+		return err
+	}
+	h.removeStoredFiles(files)
+	return nil
+}
+
+// demoEntries generates the history of a pregnancy that is now at the given week,
+// plus upcoming appointments.
+func demoEntries(week int, now time.Time, rng *rand.Rand) []models.EntryRequest {
+	start := now.AddDate(0, 0, -week*7)
+	var entries []models.EntryRequest
+	add := func(entryType string, n int, data map[string]interface{}) {
+		payload, _ := json.Marshal(data)
+		entries = append(entries, models.EntryRequest{
+			ClientID:  fmt.Sprintf("demo-%s-%d", entryType, n),
+			EntryType: entryType,
+			Data:      payload,
+		})
+	}
+	// at returns a time on the given day since the start, at a random hour in [fromHour, toHour)
+	at := func(day, fromHour, toHour int) time.Time {
+		t := start.AddDate(0, 0, day)
+		t = time.Date(t.Year(), t.Month(), t.Day(), fromHour+rng.Intn(toHour-fromHour), rng.Intn(60), 0, 0, time.UTC)
+		if t.After(now) {
+			t = now.Add(-time.Duration(rng.Intn(60)+1) * time.Minute)
+		}
+		return t
+	}
+
+	weight := 62.0 + rng.Float64()*8
+	for wk := 1; wk <= week; wk++ {
+		trimester := gestation.Trimester(wk) - 1
+
+		if wk >= 8 {
+			if wk < 13 {
+				weight += 0.15 + rng.Float64()*0.15
+			} else {
+				weight += 0.35 + rng.Float64()*0.2
+			}
+			add("weight", wk, map[string]interface{}{
+				"timestamp": at(wk*7-7, 7, 9).Format(time.RFC3339),
+				"weight":    float64(int(weight*10)) / 10,
+				"unit":      "kg",
+			})
+		}
+
+		if wk >= 8 && wk%2 == 0 {
+			add("blood_pressure", wk, map[string]interface{}{
+				"timestamp": at(wk*7-5, 8, 20).Format(time.RFC3339),
+				"systolic":  105 + rng.Intn(18),
+				"diastolic": 65 + rng.Intn(15),
+				"pulse":     72 + rng.Intn(18),
+				"unit":      "mmHg",
+			})
+		}
+
+		if wk >= 5 {
+			for i := 0; i < 3; i++ {
+				options := demoSymptoms[trimester]
+				add("symptom", wk*10+i, map[string]interface{}{
+					"timestamp": at(wk*7-7+i*2, 7, 22).Format(time.RFC3339),
+					"symptom":   options[rng.Intn(len(options))],
+					"severity":  1 + rng.Intn(4),
+				})
+			}
+		}
+
+		if wk%4 == 0 {
+			add("journal", wk, map[string]interface{}{
+				"date":    at(wk*7-1, 19, 22).Format("2006-01-02"),
+				"week":    wk,
+				"title":   fmt.Sprintf("Week %d", wk),
+				"content": fmt.Sprintf("Week %d check-in: feeling good and counting down the days.", wk),
+			})
+		}
+	}
+
+	for _, m := range demoMilestones {
+		if m.week > week {
+			break
+		}
+		add("milestone", m.week, map[string]interface{}{
+			"date":   at(m.week*7-4, 9, 17).Format("2006-01-02"),
+			"week":   m.week,
+			"title":  m.title,
+			"shared": true,
+		})
+	}
+
+	// Daily habits for the last two weeks
+	today := week * 7
+	for day := today - 13; day <= today; day++ {
+		if day < 0 {
+			continue
+		}
+		add("water", day, map[string]interface{}{
+			"timestamp": at(day, 18, 22).Format(time.RFC3339),
+			"amount":    1500 + rng.Intn(11)*100,
+			"unit":      "ml",
+		})
+		if week >= 28 {
+			add("kick_count", day, map[string]interface{}{
+				"timestamp":       at(day, 8, 23).Format(time.RFC3339),
+				"count":           10,
+				"durationMinutes": 15 + rng.Intn(46),
+			})
+		}
+	}
+
+	// Practice contractions close to term
+	if week >= 37 {
+		for i := 0; i < 6; i++ {
+			add("contraction", i, map[string]interface{}{
+				"startTime":       now.Add(-time.Duration(26-i*4) * time.Hour).Format(time.RFC3339),
+				"durationSeconds": 30 + rng.Intn(31),
+				"intervalMinutes": 12 + rng.Intn(10),
+			})
+		}
+	}
+
+	// Upcoming appointments, every two weeks (weekly near term)
+	interval := 14
+	if week >= 36 {
+		interval = 7
+	}
+	for i := 1; i <= 3; i++ {
+		when := time.Date(now.Year(), now.Month(), now.Day(), 10, 0, 0, 0, time.UTC).AddDate(0, 0, i*interval)
+		scheduledFor := when.Format(time.RFC3339)
+		payload, _ := json.Marshal(map[string]interface{}{
+			"title":    "Prenatal checkup",
+			"provider": "Demo Clinic",
+		})
+		entries = append(entries, models.EntryRequest{
+			ClientID:     fmt.Sprintf("demo-appointment-%d", i),
+			EntryType:    "appointment",
+			Data:         payload,
+			ScheduledFor: &scheduledFor,
+		})
+	}
+
+	return entries
+}
@@ -0,0 +1,86 @@
+// Package api provides deprecation headers and usage reporting for legacy endpoints.
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// maxAppVersionLen bounds the X-App-Version value stored per client.
+const maxAppVersionLen = 100
+
+// deprecationMiddleware adds Deprecation, Sunset and Link headers to a deprecated
+// route and counts its use per user and app version. It must run after AuthMiddleware.
+func (h *Handler) deprecationMiddleware(rt *Route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(h.legacyDeprecated.Unix(), 10))
+		w.Header().Add("Link", "<"+rt.Deprecated.Successor+`>; rel="successor-version"`)
+		if h.legacySunset != nil {
+			w.Header().Set("Sunset", h.legacySunset.UTC().Format(http.TimeFormat))
+		}
+
+		user := getUserInfo(r)
+		appVersion := requestAppVersion(r)
+		// Counting is best effort and must not slow down or fail the request
+		go func(route, userAgent string) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := h.db.RecordDeprecatedUsage(ctx, route, appVersion, user.UserID, userAgent); err != nil {
+				log.Printf("Failed to record deprecated route usage: %v", err)
+			}
+		}(rt.Key(), r.Header.Get("User-Agent"))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestAppVersion returns the client's X-App-Version header, bounded for storage.
+func requestAppVersion(r *http.Request) string {
+	appVersion := strings.TrimSpace(r.Header.Get("X-App-Version"))
+	if len(appVersion) > maxAppVersionLen {
+		appVersion = appVersion[:maxAppVersionLen]
+	}
+	return appVersion
+}
+
+// isAdmin reports whether the user may see operator reports.
+func (h *Handler) isAdmin(userID string) bool {
+	for _, id := range h.adminUserIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDeprecationReport lists deprecated routes and which app versions still call them.
+func (h *Handler) GetDeprecationReport(w http.ResponseWriter, r *http.Request) {
+	usage, err := h.db.GetDeprecatedUsage(r.Context())
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if usage == nil {
+		usage = []models.DeprecatedUsage{}
+	}
+
+	var sunset *string
+	if h.legacySunset != nil {
+		s := h.legacySunset.UTC().Format(time.RFC3339)
+		sunset = &s
+	}
+	deprecated := []models.DeprecatedRoute{}
+	for i := range routes {
+		if rt := &routes[i]; rt.Deprecated != nil {
+			deprecated = append(deprecated, models.DeprecatedRoute{Route: rt.Key(), Successor: rt.Deprecated.Successor, Sunset: sunset})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, models.DeprecationsResponse{Routes: deprecated, Usage: usage})
+}
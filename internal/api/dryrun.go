@@ -0,0 +1,74 @@
+// Package api provides dry runs of destructive endpoints and sync pushes.
+package api
+
+import (
+	"net/http"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// isDryRun reports whether a destructive request only asks what it would remove.
+func isDryRun(r *http.Request) bool {
+	return r.URL.Query().Get("dryRun") == "true"
+}
+
+// writeDryRun writes a deletion preview, mapping ErrNotFound like the real
+// request would.
+func writeDryRun(w http.ResponseWriter, preview *models.DeletionPreview, err error, notFound string) {
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", notFound)
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, preview)
+}
+
+// dryRunSync answers a push sent with dryRun: true. Validation and permission
+// checks already ran; the writes run in a transaction that is rolled back,
+// and the response lists what each item would do and the conflicts the real
+// push would report.
+func (h *Handler) dryRunSync(w http.ResponseWriter, r *http.Request, pregnancy *models.Pregnancy, audience []string, req *models.SyncRequest) {
+	lastSync := syncBase(req.LastSyncVersion)
+	push := &db.SyncPush{
+		Pregnancy:      req.Pregnancy,
+		Entries:        req.Entries,
+		Bases:          make([]db.SyncBase, len(req.Entries)),
+		DeletedEntries: req.DeletedEntries,
+		DeleteBase:     lastSync,
+		Visibility:     audience,
+		Settings:       req.Settings,
+		SettingsPatch:  req.SettingsPatch,
+	}
+	for i := range req.Entries {
+		push.Bases[i] = entryBase(&req.Entries[i], lastSync)
+	}
+
+	preview, err := h.db.DryRunSync(r.Context(), pregnancy.OwnerID, pregnancy.ID, push)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	preview.Conflicts = []models.SyncConflict{}
+	for _, c := range preview.Changes {
+		if c.Outcome != models.SyncOutcomeConflict {
+			continue
+		}
+		for i := range c.Entries {
+			preview.Conflicts = append(preview.Conflicts, entryConflict(&c.Entries[i], audience))
+		}
+		if c.Setting != nil {
+			preview.Conflicts = append(preview.Conflicts, models.SyncConflict{
+				Kind:          "setting",
+				Key:           c.Key,
+				ServerVersion: c.Setting.Version,
+				ServerData:    c.Setting.Data,
+			})
+		}
+	}
+	preview.Conflicts = shapeConflicts(r.Context(), preview.Conflicts)
+	writeNegotiated(w, r, http.StatusOK, preview)
+}
@@ -0,0 +1,239 @@
+// Package api provides duplicate entry detection and merge handlers.
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// Payload keys the clients use for the logical time of an entry, in priority order.
+var entryTimestampKeys = []string{"timestamp", "date", "dateTime", "time", "startTime"}
+
+// Payload keys that are client bookkeeping and ignored when comparing payloads.
+var entryIgnoredKeys = map[string]bool{"id": true, "clientId": true, "createdAt": true, "updatedAt": true}
+
+// duplicateTolerance is how far apart, relative to the larger, two numbers in
+// otherwise equal payloads may be and still count as the same reading.
+const duplicateTolerance = 0.005
+
+// GetDuplicateEntries lists groups of entries that look like duplicates of each other.
+func (h *Handler) GetDuplicateEntries(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	entryType := r.URL.Query().Get("type")
+	entries, err := h.db.GetEntries(ctx, pregnancy.ID, entryType, nil, nil, false)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	entries = visibleEntries(entries, entryAudience(pregnancy, user.UserID), nil)
+	writeJSON(w, http.StatusOK, models.DuplicatesResponse{Groups: findDuplicateGroups(entries)})
+}
+
+// MergeDuplicateEntries keeps one entry and soft deletes its duplicates.
+func (h *Handler) MergeDuplicateEntries(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return
+	}
+
+	var req models.MergeDuplicatesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	if req.EntryType == "" || req.KeepClientID == "" || len(req.MergeClientIDs) == 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "entryType, keepClientId and mergeClientIds are required")
+		return
+	}
+
+	// Entries hidden from the caller can be neither kept nor merged away
+	removed, err := h.db.MergeDuplicateEntries(ctx, pregnancy.ID, req.EntryType, req.KeepClientID, req.MergeClientIDs, entryAudience(pregnancy, user.UserID))
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Entry to keep not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"removed": removed,
+	})
+}
+
+// findDuplicateGroups groups entries of the same type that share a payload timestamp
+// and have a near-identical payload once client bookkeeping keys are ignored.
+func findDuplicateGroups(entries []models.Entry) []models.DuplicateGroup {
+	type candidate struct {
+		entry   models.Entry
+		stamp   string
+		payload map[string]interface{}
+	}
+
+	byType := make(map[string][]candidate)
+	for _, e := range entries {
+		var payload map[string]interface{}
+		if err := json.Unmarshal(e.Data, &payload); err != nil {
+			continue // Non-object payloads can't be compared
+		}
+		for k := range entryIgnoredKeys {
+			delete(payload, k)
+		}
+		byType[e.EntryType] = append(byType[e.EntryType], candidate{
+			entry:   e,
+			stamp:   payloadTimestamp(payload),
+			payload: payload,
+		})
+	}
+
+	types := make([]string, 0, len(byType))
+	for t := range byType {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	groups := []models.DuplicateGroup{}
+	for _, t := range types {
+		candidates := byType[t]
+		grouped := make([]bool, len(candidates))
+
+		for i := range candidates {
+			if grouped[i] {
+				continue
+			}
+			group := models.DuplicateGroup{EntryType: t, Entries: []models.Entry{candidates[i].entry}}
+			for j := i + 1; j < len(candidates); j++ {
+				if grouped[j] {
+					continue
+				}
+				reason := duplicateReason(candidates[i].stamp, candidates[j].stamp, candidates[i].payload, candidates[j].payload)
+				if reason == "" {
+					continue
+				}
+				// The group is only identical if every entry matched exactly
+				if group.Reason == "" || reason == "near_identical_payload" {
+					group.Reason = reason
+				}
+				group.Entries = append(group.Entries, candidates[j].entry)
+				grouped[j] = true
+			}
+			if len(group.Entries) > 1 {
+				grouped[i] = true
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups
+}
+
+// duplicateReason returns why two payloads look like the same entry, or "" if they don't.
+// Both need the same timestamp: readings taken at the same time can differ, and
+// identical payloads without one (two glasses of water) are separate entries.
+func duplicateReason(stampA, stampB string, a, b map[string]interface{}) string {
+	if stampA == "" || stampA != stampB {
+		return ""
+	}
+	if reflect.DeepEqual(a, b) {
+		return "identical_payload"
+	}
+	if nearlyEqualPayloads(a, b) {
+		return "near_identical_payload"
+	}
+	return ""
+}
+
+// nearlyEqualPayloads reports whether two payloads have the same keys and values,
+// allowing numbers to differ by rounding (e.g. after a unit conversion), strings
+// by case and surrounding space, and times by offset.
+func nearlyEqualPayloads(a, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, va := range a {
+		vb, ok := b[k]
+		if !ok {
+			return false
+		}
+		switch x := va.(type) {
+		case float64:
+			y, ok := vb.(float64)
+			if !ok || math.Abs(x-y) > duplicateTolerance*math.Max(math.Abs(x), math.Abs(y)) {
+				return false
+			}
+		case string:
+			y, ok := vb.(string)
+			if !ok || !(strings.EqualFold(strings.TrimSpace(x), strings.TrimSpace(y)) || sameInstant(x, y)) {
+				return false
+			}
+		default:
+			if !reflect.DeepEqual(va, vb) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// sameInstant reports whether two RFC3339 strings are the same time in different offsets.
+func sameInstant(a, b string) bool {
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	return errA == nil && errB == nil && ta.Equal(tb)
+}
+
+// payloadTimestamp returns the entry's logical timestamp from its payload, if any.
+// RFC3339 times are normalized to UTC so one instant written in two offsets matches.
+func payloadTimestamp(payload map[string]interface{}) string {
+	for _, key := range entryTimestampKeys {
+		switch v := payload[key].(type) {
+		case string:
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				return t.UTC().Format(time.RFC3339Nano)
+			}
+			if v != "" {
+				return v
+			}
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+func entry(clientID, entryType, data string) models.Entry {
+	return models.Entry{ClientID: clientID, EntryType: entryType, Data: json.RawMessage(data)}
+}
+
+func TestFindDuplicateGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []models.Entry
+		want    string // Reason of the single expected group, "" for none
+	}{
+		{"same timestamp, identical payload", []models.Entry{
+			entry("a", "weight", `{"timestamp":"2024-03-01T08:00:00Z","kg":64.2,"id":"a"}`),
+			entry("b", "weight", `{"timestamp":"2024-03-01T08:00:00Z","kg":64.2,"id":"b"}`),
+		}, "identical_payload"},
+		{"same instant in another offset", []models.Entry{
+			entry("a", "weight", `{"timestamp":"2024-03-01T08:00:00Z","kg":64.2}`),
+			entry("b", "weight", `{"timestamp":"2024-03-01T09:00:00+01:00","kg":64.2}`),
+		}, "near_identical_payload"},
+		{"same timestamp, rounded reading", []models.Entry{
+			entry("a", "weight", `{"timestamp":"2024-03-01T08:00:00Z","kg":64.0,"note":"Morning"}`),
+			entry("b", "weight", `{"timestamp":"2024-03-01T08:00:00Z","kg":64.2,"note":"morning "}`),
+		}, "near_identical_payload"},
+		{"same timestamp, different readings", []models.Entry{
+			entry("a", "bloodPressure", `{"timestamp":"2024-03-01T08:00:00Z","systolic":120,"diastolic":80}`),
+			entry("b", "bloodPressure", `{"timestamp":"2024-03-01T08:00:00Z","systolic":135,"diastolic":88}`),
+		}, ""},
+		{"identical payloads without a timestamp", []models.Entry{
+			entry("a", "water", `{"amount":250,"unit":"ml"}`),
+			entry("b", "water", `{"amount":250,"unit":"ml"}`),
+		}, ""},
+		{"same payload, different types", []models.Entry{
+			entry("a", "water", `{"timestamp":"2024-03-01T08:00:00Z","amount":250}`),
+			entry("b", "juice", `{"timestamp":"2024-03-01T08:00:00Z","amount":250}`),
+		}, ""},
+		{"extra key", []models.Entry{
+			entry("a", "meal", `{"timestamp":"2024-03-01T08:00:00Z","food":"toast"}`),
+			entry("b", "meal", `{"timestamp":"2024-03-01T08:00:00Z","food":"toast","notes":"with jam"}`),
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := findDuplicateGroups(tt.entries)
+			if tt.want == "" {
+				if len(groups) != 0 {
+					t.Fatalf("got %d groups, want none: %+v", len(groups), groups)
+				}
+				return
+			}
+			if len(groups) != 1 {
+				t.Fatalf("got %d groups, want 1", len(groups))
+			}
+			if groups[0].Reason != tt.want {
+				t.Errorf("reason = %q, want %q", groups[0].Reason, tt.want)
+			}
+			if len(groups[0].Entries) != len(tt.entries) {
+				t.Errorf("group has %d entries, want %d", len(groups[0].Entries), len(tt.entries))
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Package api provides supporter engagement and the supporter's opt-out.
+package api
+
+import (
+	"net/http"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// engagementNote tells owners and supporters what engagement shows.
+const engagementNote = "Last viewed and visit counts come from app access logs: a visit is any use of the app after 30 minutes away. " +
+	"They are approximate and only count time since joining. Supporters can hide them in their sharing settings."
+
+// GetActivitySharing reports whether the owner sees the supporter's engagement.
+func (h *Handler) GetActivitySharing(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	share, err := h.db.GetSupporterActivitySharing(r.Context(), user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Not a supporter")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.ActivitySharingResponse{ShareActivity: share, Note: engagementNote})
+}
+
+// UpdateActivitySharing lets a supporter show or hide their last-viewed time
+// and visit count from the owner.
+func (h *Handler) UpdateActivitySharing(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	var req models.ActivitySharingRequest
+	if err := decodeBody(r, &req); err != nil || req.ShareActivity == nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "shareActivity is required")
+		return
+	}
+
+	if err := h.db.SetSupporterActivitySharing(r.Context(), user.UserID, *req.ShareActivity); err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Not a supporter")
+		return
+	} else if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.ActivitySharingResponse{ShareActivity: *req.ShareActivity, Note: engagementNote})
+}
@@ -0,0 +1,58 @@
+// Package api provides payload field filters on entry listings.
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// maxEntryFilters caps the filters one entry listing may combine.
+const maxEntryFilters = 8
+
+// parseEntryFilters reads repeated filter=field:op:value query parameters, which
+// all apply to entryType. It returns a validation message on bad input.
+func parseEntryFilters(r *http.Request, entryType string) ([]models.EntryFilter, string) {
+	raw := r.URL.Query()["filter"]
+	if len(raw) == 0 {
+		return nil, ""
+	}
+	if entryType == "" {
+		return nil, "filter requires type"
+	}
+	if len(raw) > maxEntryFilters {
+		return nil, fmt.Sprintf("At most %d filters", maxEntryFilters)
+	}
+
+	filters := make([]models.EntryFilter, 0, len(raw))
+	for _, s := range raw {
+		parts := strings.SplitN(s, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Sprintf("filter %q must be field:op:value", s)
+		}
+		f := models.EntryFilter{Field: parts[0], Op: parts[1], Value: parts[2]}
+		if msg := db.ValidateEntryFilter(entryType, f); msg != "" {
+			return nil, msg
+		}
+		filters = append(filters, f)
+	}
+	return filters, ""
+}
+
+// GetEntryFilterReport times each filterable field's query with and without its
+// expression index on production data.
+func (h *Handler) GetEntryFilterReport(w http.ResponseWriter, r *http.Request) {
+	benchmarks, err := h.db.BenchmarkEntryFilters(r.Context())
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if benchmarks == nil {
+		benchmarks = []models.EntryFilterBenchmark{}
+	}
+
+	writeJSON(w, http.StatusOK, models.EntryFilterReport{Benchmarks: benchmarks})
+}
@@ -0,0 +1,55 @@
+// Package api provides the mapping of internal/db errors to API responses.
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// domainError is how an internal/db error is answered.
+type domainError struct {
+	err     error
+	status  int
+	code    string
+	message string
+}
+
+// domainErrors maps internal/db errors to HTTP statuses and error codes,
+// checked in order with errors.Is. Errors not listed are 500 INTERNAL_ERROR.
+var domainErrors = []domainError{
+	{db.ErrNotFound, http.StatusNotFound, "NOT_FOUND", "Not found"},
+	{db.ErrConflict, http.StatusConflict, "CONFLICT", "The record changed or is in a conflicting state"},
+	{db.ErrLocked, http.StatusConflict, "LOCKED", "Someone else is editing this"},
+	{db.ErrDuplicate, http.StatusConflict, "DUPLICATE", "A record with these values already exists"},
+	{db.ErrForeignKey, http.StatusConflict, "FOREIGN_KEY_VIOLATION", "The record refers to, or is referred to by, another record"},
+	{db.ErrPermission, http.StatusForbidden, "FORBIDDEN", "Not allowed"},
+	{db.ErrInvalid, http.StatusBadRequest, "VALIDATION_ERROR", "A value is missing, malformed or out of range"},
+	{db.ErrCircuitOpen, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "Database unavailable, retry later"},
+}
+
+// writeDomainError answers an error from internal/db, or any other error a
+// handler can't handle itself, through domainErrors. Postgres errors are
+// classified first, so those of transactions map too. The violated constraint
+// is reported for duplicate, foreign key and invalid value errors.
+func writeDomainError(w http.ResponseWriter, err error) {
+	err = db.Classify(err)
+	for _, de := range domainErrors {
+		if !errors.Is(err, de.err) {
+			continue
+		}
+		detail := models.ErrorDetail{Code: de.code, Message: de.message}
+		var dbErr *db.Error
+		if errors.As(err, &dbErr) {
+			detail.Constraint = dbErr.Constraint
+		}
+		if de.status == http.StatusServiceUnavailable {
+			w.Header().Set("Retry-After", "1")
+		}
+		writeJSON(w, de.status, models.ErrorResponse{Error: detail})
+		return
+	}
+	writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+}
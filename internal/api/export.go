@@ -0,0 +1,133 @@
+// Package api provides pregnancy export handlers.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/export"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ExportPregnancy generates a ZIP archive of the pregnancy, entries and settings.
+// An optional password encrypts the archive; it is used once and never stored.
+func (h *Handler) ExportPregnancy(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return
+	}
+
+	var req models.ExportRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+			return
+		}
+	}
+
+	// Care notes are private to the owner and providers, so only the owner can consent
+	if req.IncludeCareNotes && pregnancy.OwnerID != user.UserID {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only owner can export care notes")
+		return
+	}
+
+	// Data stays in its residency region unless the export is explicitly flagged
+	if crossRegion(pregnancy.Region, h.serverRegion) {
+		if !req.AllowCrossRegion {
+			writeError(w, http.StatusForbidden, "REGION_RESTRICTED",
+				fmt.Sprintf("Pregnancy data is held in region %q; set allowCrossRegion to export it from %q", pregnancy.Region, h.serverRegion))
+			return
+		}
+		log.Printf("Cross-region export of pregnancy %d (%s) from %s by %s", pregnancy.ID, pregnancy.Region, h.serverRegion, user.UserID)
+	}
+
+	files, err := h.exportFiles(r, pregnancy, req.IncludeCareNotes)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	archive, err := export.Build(pregnancy.ID, files, req.Password)
+	if err == export.ErrPasswordTooShort {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR",
+			fmt.Sprintf("Password must be at least %d characters", export.MinPasswordLength))
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	filename := fmt.Sprintf("clingy-export-%d-%s.zip", pregnancy.ID, time.Now().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+// exportFiles collects the JSON documents that make up an export.
+// Care notes are only included with the owner's explicit consent.
+func (h *Handler) exportFiles(r *http.Request, pregnancy *models.Pregnancy, includeCareNotes bool) ([]export.File, error) {
+	ctx := r.Context()
+
+	entries, err := h.db.GetEntries(ctx, pregnancy.ID, "", nil, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	entriesByType := make(map[string][]models.Entry)
+	for _, e := range visibleEntries(entries, entryAudience(pregnancy, getUserInfo(r).UserID), nil) {
+		entriesByType[e.EntryType] = append(entriesByType[e.EntryType], e)
+	}
+
+	settings, err := h.db.GetSettings(ctx, pregnancy.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := []struct {
+		name string
+		data interface{}
+	}{
+		{"pregnancy.json", h.toPregnancyDTO(pregnancy)},
+		{"entries.json", entriesByType},
+		{"settings.json", settings},
+	}
+
+	if includeCareNotes {
+		notes, err := h.db.GetCareNotes(ctx, pregnancy.ID)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, struct {
+			name string
+			data interface{}
+		}{"care_notes.json", notes})
+	}
+
+	files := make([]export.File, 0, len(docs))
+	for _, doc := range docs {
+		data, err := json.MarshalIndent(doc.data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, export.File{Name: doc.name, Data: data})
+	}
+	return files, nil
+}
@@ -0,0 +1,23 @@
+// Package api provides pregnancy dating shared by week progress and analytics.
+package api
+
+import (
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/pkg/gestation"
+)
+
+// pregnancyStart dates the pregnancy (see gestation.Start). ok is false when
+// neither its due date nor its start date is set. The benchmark job dates
+// weeks the same way in SQL (db.GetCohortAggregates).
+func pregnancyStart(p *models.Pregnancy) (time.Time, bool) {
+	var due, start *time.Time
+	if p.DueDate.Valid {
+		due = &p.DueDate.Time
+	}
+	if p.StartDate.Valid {
+		start = &p.StartDate.Time
+	}
+	return gestation.Start(due, start, gestation.Method(p.CalculationMethod.String), p.CycleLength)
+}
@@ -0,0 +1,153 @@
+// Package api provides Idempotency-Key handling for sync and entry writes, so
+// a retried request replays the first response instead of applying twice.
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+const (
+	// idempotencyKeyTTL is how long a key's response is replayed.
+	idempotencyKeyTTL = 24 * time.Hour
+	// idempotencyAbandonAfter frees keys whose first request never finished,
+	// well past the server's write timeout.
+	idempotencyAbandonAfter = time.Minute
+	// idempotencyCleanupInterval is how often expired keys are deleted.
+	idempotencyCleanupInterval = time.Hour
+	// maxIdempotencyKeyLen limits the Idempotency-Key header.
+	maxIdempotencyKeyLen = 255
+)
+
+// validIdempotencyKey accepts 1-255 printable ASCII characters.
+func validIdempotencyKey(key string) bool {
+	if key == "" || len(key) > maxIdempotencyKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// responseRecorder copies what a handler writes so it can be replayed.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// idempotencyMiddleware makes sync pushes and entry creation safe to retry.
+// The first request with an Idempotency-Key runs and its response is kept for
+// 24 hours; repeats get that response with Idempotent-Replayed: true. Reusing
+// a key for a different request is rejected, as is a repeat while the first
+// is still running. Failed (5xx) requests are not kept, so retries run again.
+func (h *Handler) idempotencyMiddleware(rt *Route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !validIdempotencyKey(key) {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Idempotency-Key must be 1-255 printable ASCII characters")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.New()
+		io.WriteString(sum, r.Method+" "+r.URL.Path+"\n")
+		sum.Write(body)
+		fingerprint := hex.EncodeToString(sum.Sum(nil))
+
+		user := getUserInfo(r)
+		now := time.Now()
+		rec, claimed, err := h.db.ClaimIdempotencyKey(r.Context(), &models.IdempotencyRecord{
+			UserID:      user.UserID,
+			Key:         key,
+			Route:       rt.Key(),
+			Fingerprint: fingerprint,
+		}, now.Add(-idempotencyKeyTTL), now.Add(-idempotencyAbandonAfter))
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+
+		if !claimed {
+			switch {
+			case rec.Fingerprint != fingerprint:
+				writeError(w, http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED", "Idempotency-Key was already used for a different request")
+			case !rec.Status.Valid:
+				w.Header().Set("Retry-After", "1")
+				writeError(w, http.StatusConflict, "CONFLICT", "A request with this Idempotency-Key is still in progress")
+			default:
+				if rec.ContentType.Valid {
+					w.Header().Set("Content-Type", rec.ContentType.String)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(int(rec.Status.Int32))
+				w.Write(rec.Response)
+			}
+			return
+		}
+
+		out := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(out, r)
+
+		// The response is already sent; keeping it must not depend on the client
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if out.status >= http.StatusInternalServerError {
+			err = h.db.ReleaseIdempotencyKey(ctx, user.UserID, key)
+		} else {
+			err = h.db.CompleteIdempotencyKey(ctx, user.UserID, key, out.status, out.Header().Get("Content-Type"), out.body.Bytes())
+		}
+		if err != nil {
+			log.Printf("Failed to record idempotency key (status %d): %v", out.status, err)
+		}
+	})
+}
+
+// RunIdempotencyCleanup deletes expired idempotency keys. It never returns;
+// start it in a goroutine.
+func (h *Handler) RunIdempotencyCleanup() {
+	for {
+		deleted, err := h.db.DeleteExpiredIdempotencyKeys(context.Background(), time.Now().Add(-idempotencyKeyTTL))
+		if err != nil {
+			log.Printf("Idempotency cleanup: %v", err)
+		} else if deleted > 0 {
+			log.Printf("Idempotency cleanup: deleted %d expired keys", deleted)
+		}
+		time.Sleep(idempotencyCleanupInterval)
+	}
+}
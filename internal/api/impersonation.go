@@ -0,0 +1,278 @@
+// Package api provides admin impersonation for debugging user-specific issues.
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/auth"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/pagination"
+)
+
+// impersonationTokenPrefix marks impersonation tokens so AuthMiddleware can
+// tell them from personal access tokens and JWTs.
+const impersonationTokenPrefix = "t2i_"
+
+// Impersonation limits
+const (
+	defaultImpersonationMinutes = 30
+	maxImpersonationMinutes     = 120
+	maxImpersonationReasonLen   = 500
+)
+
+const (
+	// impersonationNoticeInterval is how often ended sessions are reported.
+	impersonationNoticeInterval = time.Minute
+	// impersonationNoticeBatch caps the sessions reported per pass.
+	impersonationNoticeBatch = 100
+)
+
+// authenticateImpersonation resolves an impersonation token to the user it
+// acts as. The route registry keeps sessions off admin and session-only routes
+// and limits read sessions to read routes.
+func (h *Handler) authenticateImpersonation(r *http.Request, token string) (*auth.UserInfo, int, string) {
+	imp, err := h.db.GetActiveImpersonation(r.Context(), sha256Hex(token))
+	if err == db.ErrNotFound {
+		return nil, http.StatusUnauthorized, "Invalid or ended impersonation session"
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err.Error()
+	}
+
+	return &auth.UserInfo{
+		UserID:          imp.UserID,
+		ExpiresAt:       imp.ExpiresAt,
+		Scope:           imp.Scope,
+		ImpersonationID: imp.ID,
+		ImpersonatorID:  imp.AdminID,
+	}, 0, ""
+}
+
+// impersonationMiddleware flags every response of an impersonation session
+// with banner headers and records the request, with its status, in the
+// session's audit trail.
+func (h *Handler) impersonationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserInfo(r)
+		if user.ImpersonationID == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		access := "read-only"
+		if user.Scope == models.TokenScopeWrite {
+			access = "read-write"
+		}
+		w.Header().Set("X-Impersonation-Id", strconv.FormatInt(user.ImpersonationID, 10))
+		w.Header().Set("X-Impersonated-By", user.ImpersonatorID)
+		w.Header().Set("X-Impersonation-Expires", user.ExpiresAt.UTC().Format(time.RFC3339))
+		w.Header().Set("X-Impersonation-Banner", fmt.Sprintf("Admin %s is acting as user %s (%s) until %s",
+			user.ImpersonatorID, user.UserID, access, user.ExpiresAt.UTC().Format(time.RFC3339)))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		req := &models.ImpersonatedRequest{
+			ImpersonationID: user.ImpersonationID,
+			Method:          r.Method,
+			Path:            r.URL.Path,
+			Status:          rec.status,
+		}
+		// Auditing must not slow down the response
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := h.db.CreateImpersonatedRequest(ctx, req); err != nil {
+				log.Printf("Failed to record impersonated request: %v", err)
+			}
+		}()
+	})
+}
+
+// StartImpersonation issues an admin a token that acts as a user, read-only
+// unless write is asked for. The token is returned once and expires with the
+// session.
+func (h *Handler) StartImpersonation(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	if user.TokenID != 0 {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Impersonation must be started from a signed-in session")
+		return
+	}
+
+	var req models.ImpersonationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	req.UserID = strings.TrimSpace(req.UserID)
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.UserID == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "userId is required")
+		return
+	}
+	if req.UserID == user.UserID || h.isAdmin(req.UserID) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Admins cannot be impersonated")
+		return
+	}
+	if req.Reason == "" || len(req.Reason) > maxImpersonationReasonLen {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("reason is required (max %d characters)", maxImpersonationReasonLen))
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = models.TokenScopeRead
+	}
+	if req.Scope != models.TokenScopeRead && req.Scope != models.TokenScopeWrite {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "scope must be read or write")
+		return
+	}
+	minutes := defaultImpersonationMinutes
+	if req.Minutes != nil {
+		if *req.Minutes < 1 || *req.Minutes > maxImpersonationMinutes {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("minutes must be between 1 and %d", maxImpersonationMinutes))
+			return
+		}
+		minutes = *req.Minutes
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to generate token")
+		return
+	}
+	token := impersonationTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	imp, err := h.db.CreateImpersonation(ctx, user.UserID, req.UserID, req.Reason, req.Scope, sha256Hex(token), time.Now().Add(time.Duration(minutes)*time.Minute))
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	log.Printf("Impersonation %d: admin %s started acting as %s (%s): %s", imp.ID, imp.AdminID, imp.UserID, imp.Scope, imp.Reason)
+
+	writeJSON(w, http.StatusCreated, models.ImpersonationResponse{Impersonation: *imp, Token: token})
+}
+
+// GetImpersonations lists impersonation sessions of all admins, newest first,
+// always paginated.
+func (h *Handler) GetImpersonations(w http.ResponseWriter, r *http.Request) {
+	params, ok := readPage(w, r)
+	if !ok {
+		return
+	}
+	sessions, err := h.db.GetImpersonations(r.Context(), params)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pagination.NewPage(sessions, params, impersonationCursor))
+}
+
+// GetImpersonatedRequests lists the requests made in a session, newest first,
+// always paginated.
+func (h *Handler) GetImpersonatedRequests(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["impersonationId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid impersonation ID")
+		return
+	}
+	params, ok := readPage(w, r)
+	if !ok {
+		return
+	}
+	requests, err := h.db.GetImpersonatedRequests(r.Context(), id, params)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pagination.NewPage(requests, params, impersonatedRequestCursor))
+}
+
+// EndImpersonation ends a session before it expires. Its token stops working
+// at once and the owner is told shortly after.
+func (h *Handler) EndImpersonation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["impersonationId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid impersonation ID")
+		return
+	}
+
+	err = h.db.EndImpersonation(r.Context(), id)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No active impersonation session with this ID")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RunImpersonationNotices tells users, and the owner of the pregnancy they
+// were reached through, about impersonation sessions once they end or expire.
+func (h *Handler) RunImpersonationNotices() {
+	for {
+		ctx := context.Background()
+		sessions, err := h.db.GetFinishedImpersonations(ctx, impersonationNoticeBatch)
+		if err != nil {
+			log.Printf("Impersonation notices: %v", err)
+		}
+		for i := range sessions {
+			if err := h.notifyImpersonationEnded(ctx, &sessions[i]); err != nil {
+				log.Printf("Impersonation notices: session %d: %v", sessions[i].ID, err)
+			}
+		}
+		time.Sleep(impersonationNoticeInterval)
+	}
+}
+
+// notifyImpersonationEnded sends the impersonation_ended notification and
+// marks the session as reported.
+func (h *Handler) notifyImpersonationEnded(ctx context.Context, imp *models.Impersonation) error {
+	ended := imp.ExpiresAt
+	if imp.EndedAt.Valid {
+		ended = imp.EndedAt.Time
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"impersonationId": imp.ID,
+		"reason":          imp.Reason,
+		"scope":           imp.Scope,
+		"startedAt":       imp.CreatedAt.UTC().Format(time.RFC3339),
+		"endedAt":         ended.UTC().Format(time.RFC3339),
+		"requests":        imp.Requests,
+	})
+
+	a, err := h.resolveAccess(ctx, imp.UserID)
+	switch {
+	case err == db.ErrNotFound:
+		if _, err := h.db.CreateTestNotification(ctx, imp.UserID, "impersonation_ended", payload); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		recipients := []string{imp.UserID}
+		if a.pregnancy.OwnerID != imp.UserID {
+			recipients = append(recipients, a.pregnancy.OwnerID)
+		}
+		for _, userID := range recipients {
+			if err := h.db.CreateNotification(ctx, userID, a.pregnancy.ID, "impersonation_ended", payload); err != nil {
+				return err
+			}
+		}
+	}
+	return h.db.MarkImpersonationNotified(ctx, imp.ID)
+}
@@ -0,0 +1,59 @@
+// Package api provides cache invalidation hints, so clients refetch just the
+// resource that changed instead of running a full sync.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// invalidatePregnancy is the hint resource for a pregnancy profile.
+const invalidatePregnancy = "pregnancy"
+
+// pregnancyVersion is the version of a pregnancy profile: its last write.
+func pregnancyVersion(p *models.Pregnancy) string {
+	return p.UpdatedAt.UTC().Format(time.RFC3339Nano)
+}
+
+// pregnancyHint tells clients that cached the pregnancy before its last write
+// to fetch it again.
+func pregnancyHint(p *models.Pregnancy) models.InvalidationHint {
+	return models.InvalidationHint{Resource: invalidatePregnancy, ID: p.ID, Version: pregnancyVersion(p)}
+}
+
+// notifyPregnancyChanged tells the owner, coowner and partner, except whoever
+// made the change, that the pregnancy profile changed. The payload carries the
+// hint for the new version.
+func (h *Handler) notifyPregnancyChanged(ctx context.Context, p *models.Pregnancy, actorID string) {
+	updatedBy := "owner"
+	switch {
+	case p.CoownerID.Valid && p.CoownerID.String == actorID:
+		updatedBy = "coowner"
+	case p.PartnerID.Valid && p.PartnerID.String == actorID:
+		updatedBy = "partner"
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"updatedBy":  updatedBy,
+		"invalidate": []models.InvalidationHint{pregnancyHint(p)},
+	})
+
+	recipients := []string{p.OwnerID}
+	if p.CoownerID.Valid {
+		recipients = append(recipients, p.CoownerID.String)
+	}
+	if p.PartnerID.Valid && p.PartnerStatus.String == "approved" {
+		recipients = append(recipients, p.PartnerID.String)
+	}
+	for _, userID := range recipients {
+		if userID == actorID {
+			continue
+		}
+		if err := h.db.CreateNotification(ctx, userID, p.ID, "pregnancy_updated", payload); err != nil {
+			log.Printf("Failed to create pregnancy_updated notification: %v", err)
+		}
+	}
+}
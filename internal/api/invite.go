@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -16,6 +18,9 @@ const codeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
 // CodeExpiration is the default expiration time for invite codes (48 hours)
 const CodeExpiration = 48 * time.Hour
 
+// MaxInviteMessage is the longest welcome message, in characters, an invite code may carry.
+const MaxInviteMessage = 500
+
 // GenerateInviteCode generates a 10-character code formatted as XXXX-XXXX-XX.
 func GenerateInviteCode() (string, error) {
 	code := make([]byte, 10)
@@ -100,3 +105,45 @@ func IsValidCodeFormat(code string) bool {
 	}
 	return true
 }
+
+// SanitizeInviteMessage cleans an owner's welcome message for display to the
+// person redeeming the code. Control and invisible formatting characters (such as
+// bidi overrides) are dropped, line endings normalized, runs of blank lines
+// collapsed and the result trimmed. It returns an error message when the text is
+// not valid UTF-8 or is longer than MaxInviteMessage after cleaning.
+func SanitizeInviteMessage(message string) (string, string) {
+	if !utf8.ValidString(message) {
+		return "", "message must be valid UTF-8"
+	}
+	message = strings.ReplaceAll(message, "\r\n", "\n")
+
+	var b strings.Builder
+	newlines := 0
+	for _, r := range message {
+		switch r {
+		case '\r':
+			r = '\n'
+		case '\t':
+			r = ' '
+		}
+		if r == '\n' {
+			newlines++
+			if newlines > 2 {
+				continue
+			}
+			b.WriteRune(r)
+			continue
+		}
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		newlines = 0
+		b.WriteRune(r)
+	}
+
+	cleaned := strings.TrimSpace(b.String())
+	if utf8.RuneCountInString(cleaned) > MaxInviteMessage {
+		return "", fmt.Sprintf("message must be at most %d characters", MaxInviteMessage)
+	}
+	return cleaned, ""
+}
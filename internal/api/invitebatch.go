@@ -0,0 +1,191 @@
+// Package api provides invite batches: an owner invites a whole family at
+// once, each person getting their own code by email or SMS.
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/notify"
+)
+
+const (
+	// maxInviteBatch is the most people one batch may invite.
+	maxInviteBatch = 20
+	// maxInviteeName is the longest invitee name, in characters.
+	maxInviteeName = 100
+	// inviteSendTimeout bounds handing one invite to the relay.
+	inviteSendTimeout = 10 * time.Second
+)
+
+// InviteBatch creates an invite code for each invitee and sends it over their
+// channel. Invitees are handled independently: one that is invalid or can't
+// be reached doesn't stop the others, and each result says what happened.
+// Codes that weren't delivered are returned so the owner can share them.
+func (h *Handler) InviteBatch(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, err := h.db.GetPregnancyByOwner(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only pregnancy owner can generate codes")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	var req models.InviteBatchRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if len(req.Invitees) == 0 || len(req.Invitees) > maxInviteBatch {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("invitees must list 1 to %d people", maxInviteBatch))
+		return
+	}
+	message, msg := SanitizeInviteMessage(req.Message)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+
+	results := make([]models.InviteBatchResult, len(req.Invitees))
+	messages := make([]*notify.Message, len(req.Invitees))
+	partnerInvited := pregnancy.PartnerID.Valid
+	seen := make(map[string]bool)
+	for i, inv := range req.Invitees {
+		res := &results[i]
+		res.Name, res.Channel, res.To = strings.TrimSpace(inv.Name), inv.Channel, inv.To
+
+		to, problem := validateInvitee(&inv, res.Name)
+		switch {
+		case problem != "":
+		case seen[inv.Channel+":"+to]:
+			problem = "Already invited in this batch"
+		case inv.Role == "father" && partnerInvited:
+			problem = "Already has a partner"
+		}
+		if problem != "" {
+			res.Status, res.Error = models.InviteInvalid, problem
+			continue
+		}
+		res.To = to
+		seen[inv.Channel+":"+to] = true
+		if inv.Role == "father" {
+			partnerInvited = true
+		}
+
+		permission := inv.Permission
+		if permission == "" {
+			permission = "read"
+		}
+		code, err := GenerateInviteCode()
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		codeHash, err := HashCode(code)
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		record, err := h.db.CreateInviteCode(ctx, pregnancy.ID, codeHash, GetCodePrefix(code), inv.Role, permission, time.Now().Add(CodeExpiration), message)
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		res.CodeID, res.Code, res.ExpiresAt = record.ID, code, &record.ExpiresAt
+		res.Status = models.InviteNotSent
+		messages[i] = inviteMessage(pregnancy, res, to, message)
+	}
+
+	if h.invites != nil {
+		h.sendInvites(ctx, messages, results)
+	}
+
+	resp := models.InviteBatchResponse{Results: results}
+	for _, res := range results {
+		if res.CodeID != 0 {
+			resp.Created++
+		}
+		if res.Status == models.InviteSent {
+			resp.Sent++
+		}
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// validateInvitee checks an invitee and returns their normalized address, or
+// the problem with them.
+func validateInvitee(inv *models.Invitee, name string) (string, string) {
+	if name == "" || utf8.RuneCountInString(name) > maxInviteeName {
+		return "", fmt.Sprintf("name is required and at most %d characters", maxInviteeName)
+	}
+	if inv.Role != "father" && inv.Role != "support" && inv.Role != "provider" {
+		return "", "Role must be 'father', 'support' or 'provider'"
+	}
+	if inv.Permission != "" && inv.Permission != "read" && inv.Permission != "write" {
+		return "", "Permission must be 'read' or 'write'"
+	}
+	to, err := notify.NormalizeAddress(inv.Channel, inv.To)
+	if err != nil {
+		return "", err.Error()
+	}
+	return to, ""
+}
+
+// inviteMessage writes the invite a person receives. Texts are kept short
+// enough for a single SMS where names allow.
+func inviteMessage(pregnancy *models.Pregnancy, res *models.InviteBatchResult, to, welcome string) *notify.Message {
+	from := "Someone"
+	if pregnancy.MomName.Valid && strings.TrimSpace(pregnancy.MomName.String) != "" {
+		from = strings.TrimSpace(pregnancy.MomName.String)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi %s, %s invited you to follow their pregnancy. Install the app and enter code %s before %s.",
+		res.Name, from, res.Code, res.ExpiresAt.UTC().Format("Jan 2 15:04 MST"))
+	if welcome != "" && res.Channel == notify.ChannelEmail {
+		b.WriteString("\n\n")
+		b.WriteString(welcome)
+	}
+	return &notify.Message{
+		Channel: res.Channel,
+		To:      to,
+		Subject: from + " invited you to follow their pregnancy",
+		Text:    b.String(),
+	}
+}
+
+// sendInvites hands the messages to the relay in parallel and records each
+// outcome. Messages are nil for invitees that got no code.
+func (h *Handler) sendInvites(ctx context.Context, messages []*notify.Message, results []models.InviteBatchResult) {
+	var wg sync.WaitGroup
+	for i, m := range messages {
+		if m == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sendCtx, cancel := context.WithTimeout(ctx, inviteSendTimeout)
+			defer cancel()
+			if err := h.invites.Send(sendCtx, m); err != nil {
+				log.Printf("Invite batch: code %d by %s: %v", results[i].CodeID, m.Channel, err)
+				results[i].Status, results[i].Error = models.InviteFailed, "Could not send the invite; share the code another way"
+				return
+			}
+			results[i].Status = models.InviteSent
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,357 @@
+// Package api provides kick counter session analytics.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+const (
+	// maxKickDays caps the range of a kick analytics request.
+	maxKickDays = 92
+	// defaultKickDays is the range without from.
+	defaultKickDays = 14
+	// kickSessionGap joins kick entries into one session when one starts
+	// within this long of the previous one's end.
+	kickSessionGap = 20 * time.Minute
+	// kickBaselineDays is how many days before a day make up its baseline;
+	// kickBaselineMinDays of them need sessions for a day to be flagged.
+	kickBaselineDays    = 7
+	kickBaselineMinDays = 3
+	// A day is flagged when kicks per hour fall to kickDropRatio of the
+	// baseline or less, or time to ten grows to kickSlowRatio times it or more.
+	kickDropRatio = 0.5
+	kickSlowRatio = 2.0
+)
+
+// kickEntryTypes are the entry types kicks are logged as.
+var kickEntryTypes = []string{"kick_session", "kick_count"}
+
+// Decrease reasons
+const (
+	kickDecreaseRate  = "kicks_per_hour"
+	kickDecreaseToTen = "time_to_ten"
+)
+
+// kickPayload is the fields read from kick_session and kick_count entries.
+type kickPayload struct {
+	Count           *float64          `json:"count"`
+	DurationMinutes *float64          `json:"durationMinutes"`
+	EndTime         string            `json:"endTime"`
+	Kicks           []json.RawMessage `json:"kicks"` // RFC3339 strings or objects with timestamp or at
+}
+
+// kickRecord is one entry's kicks, or several entries joined into a session.
+type kickRecord struct {
+	start, end time.Time
+	entries    int
+	kicks      int
+	times      []time.Time // Only complete when len(times) == kicks
+}
+
+// parseKickEntry reads an entry's kicks. An entry without count or kicks is a
+// single kick at its time.
+func parseKickEntry(e models.TimedEntry) kickRecord {
+	rec := kickRecord{start: e.At, end: e.At, entries: 1, kicks: 1}
+
+	var p kickPayload
+	if json.Unmarshal(e.Data, &p) != nil {
+		return rec
+	}
+	for _, raw := range p.Kicks {
+		if t, ok := kickTime(raw); ok {
+			rec.times = append(rec.times, t)
+		}
+	}
+	sort.Slice(rec.times, func(i, j int) bool { return rec.times[i].Before(rec.times[j]) })
+
+	switch {
+	case p.Count != nil && *p.Count >= 0:
+		rec.kicks = int(*p.Count)
+	case len(p.Kicks) > 0:
+		rec.kicks = len(p.Kicks)
+	}
+
+	if n := len(rec.times); n > 0 {
+		if rec.times[0].Before(rec.start) {
+			rec.start = rec.times[0]
+		}
+		rec.end = rec.times[n-1]
+	}
+	if t, err := time.Parse(time.RFC3339, p.EndTime); err == nil && t.After(rec.start) {
+		rec.end = t
+	} else if p.DurationMinutes != nil && *p.DurationMinutes > 0 {
+		rec.end = rec.start.Add(time.Duration(*p.DurationMinutes * float64(time.Minute)))
+	}
+	return rec
+}
+
+// kickTime reads one element of a kicks array.
+func kickTime(raw json.RawMessage) (time.Time, bool) {
+	var s string
+	if json.Unmarshal(raw, &s) != nil {
+		var obj struct {
+			Timestamp string `json:"timestamp"`
+			At        string `json:"at"`
+		}
+		if json.Unmarshal(raw, &obj) != nil {
+			return time.Time{}, false
+		}
+		s = obj.Timestamp
+		if s == "" {
+			s = obj.At
+		}
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, err == nil
+}
+
+// groupKickSessions joins records, oldest first, that start within
+// kickSessionGap of the previous one's end.
+func groupKickSessions(records []kickRecord) []kickRecord {
+	sort.SliceStable(records, func(i, j int) bool { return records[i].start.Before(records[j].start) })
+
+	var sessions []kickRecord
+	for _, rec := range records {
+		if n := len(sessions); n > 0 && !rec.start.After(sessions[n-1].end.Add(kickSessionGap)) {
+			s := &sessions[n-1]
+			s.entries += rec.entries
+			s.kicks += rec.kicks
+			s.times = append(s.times, rec.times...)
+			if rec.end.After(s.end) {
+				s.end = rec.end
+			}
+			continue
+		}
+		sessions = append(sessions, rec)
+	}
+	return sessions
+}
+
+// kickSession computes a session's rates.
+func kickSession(rec kickRecord) models.KickSession {
+	minutes := rec.end.Sub(rec.start).Minutes()
+	s := models.KickSession{
+		Start:           rec.start,
+		End:             rec.end,
+		Entries:         rec.entries,
+		Kicks:           rec.kicks,
+		DurationMinutes: round2(minutes),
+	}
+	if minutes >= 1 {
+		perHour := round2(float64(rec.kicks) / (minutes / 60))
+		s.KicksPerHour = &perHour
+	}
+	if rec.kicks >= 10 {
+		if len(rec.times) == rec.kicks {
+			sort.Slice(rec.times, func(i, j int) bool { return rec.times[i].Before(rec.times[j]) })
+			toTen := round2(rec.times[9].Sub(rec.start).Minutes())
+			s.MinutesToTen = &toTen
+		} else if minutes > 0 {
+			toTen := round2(minutes * 10 / float64(rec.kicks))
+			s.MinutesToTen = &toTen
+			s.ToTenEstimated = true
+		}
+	}
+	return s
+}
+
+// kickTotals accumulates kicks per hour and time to ten over sessions.
+type kickTotals struct {
+	sessions, kicks int
+	kicksTimed      int // Kicks of sessions of a minute or more
+	hours           float64
+	toTenSum        float64
+	toTenCount      int
+}
+
+func (t *kickTotals) add(s models.KickSession) {
+	t.sessions++
+	t.kicks += s.Kicks
+	if s.KicksPerHour != nil {
+		t.kicksTimed += s.Kicks
+		t.hours += s.DurationMinutes / 60
+	}
+	if s.MinutesToTen != nil {
+		t.toTenSum += *s.MinutesToTen
+		t.toTenCount++
+	}
+}
+
+func (t *kickTotals) perHour() *float64 {
+	if t.hours <= 0 {
+		return nil
+	}
+	v := round2(float64(t.kicksTimed) / t.hours)
+	return &v
+}
+
+func (t *kickTotals) toTen() *float64 {
+	if t.toTenCount == 0 {
+		return nil
+	}
+	v := round2(t.toTenSum / float64(t.toTenCount))
+	return &v
+}
+
+// kickBaseline averages the per-day values of the kickBaselineDays before
+// date, or returns nil with fewer than kickBaselineMinDays days of data.
+func kickBaseline(byDay map[string]*kickTotals, date time.Time, value func(*kickTotals) *float64) *float64 {
+	var sum float64
+	var days int
+	for i := 1; i <= kickBaselineDays; i++ {
+		t, ok := byDay[date.AddDate(0, 0, -i).Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		if v := value(t); v != nil {
+			sum += *v
+			days++
+		}
+	}
+	if days < kickBaselineMinDays {
+		return nil
+	}
+	avg := round2(sum / float64(days))
+	return &avg
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// GetKickAnalytics groups kick_session and kick_count entries into sessions and
+// reports kicks per hour and time to ten per session and local day. Days whose
+// rate drops well below, or time to ten grows well above, the previous week's
+// are flagged. Query: from, to (YYYY-MM-DD, default the last 14 days), tz
+// (IANA, default UTC).
+func (h *Handler) GetKickAnalytics(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	timezone := q.Get("tz")
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid timezone")
+		return
+	}
+
+	today := time.Now().In(loc)
+	to := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, loc)
+	if s := q.Get("to"); s != "" {
+		if to, err = time.ParseInLocation("2006-01-02", s, loc); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "to must be YYYY-MM-DD")
+			return
+		}
+	}
+	from := to.AddDate(0, 0, 1-defaultKickDays)
+	if s := q.Get("from"); s != "" {
+		if from, err = time.ParseInLocation("2006-01-02", s, loc); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "from must be YYYY-MM-DD")
+			return
+		}
+	}
+	if to.Before(from) || !from.AddDate(0, 0, maxKickDays).After(to) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("from..to must span 1-%d days", maxKickDays))
+		return
+	}
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if _, until, snoozed := activeSnooze(pregnancy, user.UserID, time.Now()); snoozed {
+		writeError(w, http.StatusForbidden, "SNOOZED", "Sharing is paused until "+until.Format(time.RFC3339))
+		return
+	}
+
+	// The week before from is read too, as the baseline of the first days
+	entries, err := h.db.GetTimedEntries(ctx, pregnancy.ID, kickEntryTypes, from.AddDate(0, 0, -kickBaselineDays), to.AddDate(0, 0, 1), entryAudience(pregnancy, user.UserID))
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	records := make([]kickRecord, 0, len(entries))
+	for _, e := range entries {
+		records = append(records, parseKickEntry(e))
+	}
+
+	resp := models.KickAnalyticsResponse{
+		From:          from.Format("2006-01-02"),
+		To:            to.Format("2006-01-02"),
+		Timezone:      timezone,
+		Sessions:      []models.KickSession{},
+		Days:          []models.KickDay{},
+		DecreasedDays: []string{},
+	}
+	byDay := make(map[string]*kickTotals)
+	var days []string
+	var overall kickTotals
+	for _, rec := range groupKickSessions(records) {
+		s := kickSession(rec)
+		date := s.Start.In(loc).Format("2006-01-02")
+		t, ok := byDay[date]
+		if !ok {
+			t = &kickTotals{}
+			byDay[date] = t
+			days = append(days, date)
+		}
+		t.add(s)
+
+		if !s.Start.Before(from) {
+			resp.Sessions = append(resp.Sessions, s)
+			overall.add(s)
+		}
+	}
+
+	for _, date := range days {
+		if date < resp.From {
+			continue
+		}
+		t := byDay[date]
+		day := models.KickDay{
+			Date:         date,
+			Sessions:     t.sessions,
+			Kicks:        t.kicks,
+			KicksPerHour: t.perHour(),
+			MinutesToTen: t.toTen(),
+		}
+		at, _ := time.ParseInLocation("2006-01-02", date, loc)
+		day.BaselineKicksPerHour = kickBaseline(byDay, at, (*kickTotals).perHour)
+		day.BaselineMinutesToTen = kickBaseline(byDay, at, (*kickTotals).toTen)
+
+		if day.KicksPerHour != nil && day.BaselineKicksPerHour != nil && *day.KicksPerHour <= *day.BaselineKicksPerHour*kickDropRatio {
+			day.DecreaseReasons = append(day.DecreaseReasons, kickDecreaseRate)
+		}
+		if day.MinutesToTen != nil && day.BaselineMinutesToTen != nil && *day.MinutesToTen >= *day.BaselineMinutesToTen*kickSlowRatio {
+			day.DecreaseReasons = append(day.DecreaseReasons, kickDecreaseToTen)
+		}
+		if len(day.DecreaseReasons) > 0 {
+			day.Decreased = true
+			resp.DecreasedDays = append(resp.DecreasedDays, date)
+		}
+		resp.Days = append(resp.Days, day)
+	}
+	resp.KicksPerHour = overall.perHour()
+	resp.AvgMinutesToTen = overall.toTen()
+
+	writeNegotiated(w, r, http.StatusOK, resp)
+}
@@ -0,0 +1,506 @@
+// Package api provides memory book compilation handlers.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/report"
+)
+
+// Entry types that can go into a memory book.
+var memoryBookTypes = []string{"journal", "photo", "milestone"}
+
+// Payload keys holding an item's body text, in priority order.
+var memoryBookTextKeys = []string{"content", "text", "body", "description", "caption", "message", "note"}
+
+// Rendered formats and their content types.
+var memoryBookFormats = map[string]string{
+	"pdf":  "application/pdf",
+	"epub": "application/epub+zip",
+}
+
+// Upper bound for one memory book job.
+const memoryBookTimeout = 10 * time.Minute
+
+// CreateMemoryBook starts compiling a memory book in the background.
+// Poll GET /api/memory-book/{jobId} for progress.
+func (h *Handler) CreateMemoryBook(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return
+	}
+
+	var req models.MemoryBookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	for _, f := range req.Formats {
+		if _, ok := memoryBookFormats[f]; !ok {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Unsupported format: "+f)
+			return
+		}
+	}
+
+	job, err := h.db.CreateJob(ctx, pregnancy.ID, user.UserID, "memory_book")
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	ticket := h.heavy.enqueue(user.UserID, job.ID)
+	go func() {
+		<-ticket.ready
+		defer h.heavy.release(user.UserID)
+		h.runMemoryBookJob(job.ID, pregnancy, entryAudience(pregnancy, user.UserID), req)
+	}()
+
+	writeJSON(w, http.StatusAccepted, models.MemoryBookJobResponse{
+		JobID:         job.ID,
+		Status:        job.Status,
+		Progress:      job.Progress,
+		QueuePosition: h.heavy.jobPosition(user.UserID, job.ID),
+	})
+}
+
+// GetMemoryBook reports job progress and, once completed, the book structure.
+func (h *Handler) GetMemoryBook(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.getMemoryBookJob(w, r)
+	if !ok {
+		return
+	}
+
+	resp := models.MemoryBookJobResponse{
+		JobID:         job.ID,
+		Status:        job.Status,
+		Progress:      job.Progress,
+		QueuePosition: h.heavy.jobPosition(job.UserID, job.ID),
+		Error:         job.Error.String,
+	}
+	if job.Status == models.JobStatusCompleted {
+		var result models.MemoryBookResult
+		if err := json.Unmarshal(job.Result, &result); err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		resp.Book = result.Book
+		resp.Formats = append([]string{"json"}, result.Formats...)
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DownloadMemoryBook serves a rendered memory book (query: format=pdf|epub|json).
+func (h *Handler) DownloadMemoryBook(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.getMemoryBookJob(w, r)
+	if !ok {
+		return
+	}
+
+	if job.Status != models.JobStatusCompleted {
+		writeError(w, http.StatusConflict, "CONFLICT", "Memory book is not ready")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" || format == "json" {
+		var result models.MemoryBookResult
+		if err := json.Unmarshal(job.Result, &result); err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result.Book)
+		return
+	}
+
+	contentType, ok := memoryBookFormats[format]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Unsupported format: "+format)
+		return
+	}
+
+	pregnancy, err := h.db.GetPregnancyByID(r.Context(), job.PregnancyID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	path, err := h.memoryBookPath(pregnancy.Region, job.ID, format)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Format was not generated for this memory book")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="memory-book.%s"`, format))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+func (h *Handler) getMemoryBookJob(w http.ResponseWriter, r *http.Request) (*models.Job, bool) {
+	user := getUserInfo(r)
+	jobID, err := strconv.ParseInt(mux.Vars(r)["jobId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid job ID")
+		return nil, false
+	}
+
+	job, err := h.db.GetJob(r.Context(), jobID, user.UserID)
+	if err == db.ErrNotFound || (err == nil && job.Kind != "memory_book") {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Memory book not found")
+		return nil, false
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return nil, false
+	}
+	return job, true
+}
+
+// memoryBookPath stores rendered books with the pregnancy's other files.
+func (h *Handler) memoryBookPath(region string, jobID int64, format string) (string, error) {
+	return h.storage.Path(region, filepath.Join("memory-books", fmt.Sprintf("%d.%s", jobID, format)))
+}
+
+// runMemoryBookJob compiles and renders the book, recording progress on the job.
+// The book only holds entries the requester's audience may read.
+func (h *Handler) runMemoryBookJob(jobID int64, pregnancy *models.Pregnancy, audience []string, req models.MemoryBookRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), memoryBookTimeout)
+	defer cancel()
+
+	fail := func(err error) {
+		log.Printf("Memory book job %d failed: %v", jobID, err)
+		// Fresh context so a timeout can still be recorded
+		if err := h.db.FailJob(context.Background(), jobID, err.Error()); err != nil {
+			log.Printf("Failed to record memory book job failure: %v", err)
+		}
+	}
+	progress := func(p int) {
+		if err := h.db.UpdateJobProgress(ctx, jobID, p); err != nil {
+			log.Printf("Failed to update memory book job progress: %v", err)
+		}
+	}
+
+	progress(5)
+	book, err := h.compileMemoryBook(ctx, pregnancy, audience, req)
+	if err != nil {
+		fail(err)
+		return
+	}
+	progress(30)
+
+	var formats []string
+	if len(req.Formats) > 0 {
+		doc, err := h.memoryBookDocument(ctx, pregnancy.ID, book)
+		if err != nil {
+			fail(err)
+			return
+		}
+		progress(50)
+
+		dir, err := h.memoryBookPath(pregnancy.Region, jobID, "")
+		if err != nil {
+			fail(err)
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			fail(err)
+			return
+		}
+		for i, format := range req.Formats {
+			var data []byte
+			switch format {
+			case "pdf":
+				data, err = report.RenderPDF(doc)
+			case "epub":
+				data, err = report.RenderEPUB(doc)
+			}
+			if err != nil {
+				fail(err)
+				return
+			}
+			path, err := h.memoryBookPath(pregnancy.Region, jobID, format)
+			if err != nil {
+				fail(err)
+				return
+			}
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				fail(err)
+				return
+			}
+			formats = append(formats, format)
+			progress(50 + 45*(i+1)/len(req.Formats))
+		}
+	}
+
+	result, err := json.Marshal(models.MemoryBookResult{Book: book, Formats: formats})
+	if err != nil {
+		fail(err)
+		return
+	}
+	if err := h.db.CompleteJob(ctx, jobID, result); err != nil {
+		log.Printf("Failed to complete memory book job %d: %v", jobID, err)
+	}
+}
+
+// compileMemoryBook collects the selected entries into week chapters.
+func (h *Handler) compileMemoryBook(ctx context.Context, pregnancy *models.Pregnancy, audience []string, req models.MemoryBookRequest) (*models.MemoryBook, error) {
+	selected := make(map[string]bool, len(req.EntryClientIDs))
+	for _, id := range req.EntryClientIDs {
+		selected[id] = true
+	}
+
+	type dated struct {
+		item models.MemoryBookItem
+		week int
+		at   time.Time
+	}
+	var items []dated
+
+	for _, entryType := range memoryBookTypes {
+		entries, err := h.db.GetEntries(ctx, pregnancy.ID, entryType, nil, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range visibleEntries(entries, audience, nil) {
+			if len(selected) > 0 && !selected[e.ClientID] {
+				continue
+			}
+			var payload map[string]interface{}
+			if err := json.Unmarshal(e.Data, &payload); err != nil {
+				continue
+			}
+
+			item := models.MemoryBookItem{
+				ClientID: e.ClientID,
+				Kind:     entryType,
+				Date:     payloadTimestamp(payload),
+			}
+			item.Title, _ = payload["title"].(string)
+			for _, key := range memoryBookTextKeys {
+				if s, ok := payload[key].(string); ok && s != "" {
+					item.Text = s
+					break
+				}
+			}
+			if id, ok := payload["fileId"].(float64); ok {
+				fileID := int64(id)
+				item.FileID = &fileID
+			}
+
+			at := e.CreatedAt
+			if t, err := time.Parse(time.RFC3339, item.Date); err == nil {
+				at = t
+			} else if t, err := time.Parse("2006-01-02", item.Date); err == nil {
+				at = t
+			}
+
+			week := 0
+			if wk, ok := payload["week"].(float64); ok {
+				week = int(wk)
+			} else if p := weekProgress(pregnancy, at); p != nil {
+				week = p.Week
+			}
+
+			items = append(items, dated{item: item, week: week, at: at})
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].week != items[j].week {
+			return items[i].week < items[j].week
+		}
+		return items[i].at.Before(items[j].at)
+	})
+
+	var facts map[int]*models.WeeklyFact
+	if req.IncludeWeeklyFacts {
+		var err error
+		facts, err = h.loadWeeklyFacts(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	book := &models.MemoryBook{
+		Title:       req.Title,
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Chapters:    []models.MemoryBookChapter{},
+	}
+	if pregnancy.BabyName.Valid {
+		book.BabyName = pregnancy.BabyName.String
+	}
+	if book.Title == "" {
+		book.Title = "Our Memory Book"
+		if book.BabyName != "" {
+			book.Title = "Waiting for " + book.BabyName
+		}
+	}
+
+	for _, it := range items {
+		n := len(book.Chapters)
+		if n == 0 || book.Chapters[n-1].Week != it.week {
+			book.Chapters = append(book.Chapters, models.MemoryBookChapter{Week: it.week, Fact: facts[it.week]})
+			n++
+		}
+		book.Chapters[n-1].Items = append(book.Chapters[n-1].Items, it.item)
+	}
+
+	return book, nil
+}
+
+// loadWeeklyFacts returns the published weekly facts keyed by week.
+func (h *Handler) loadWeeklyFacts(ctx context.Context) (map[int]*models.WeeklyFact, error) {
+	items, _, err := h.publishedContent(ctx, contentKinds["weekly-facts"], false)
+	if err != nil {
+		return nil, err
+	}
+	facts := make(map[int]*models.WeeklyFact, len(items))
+	for _, item := range items {
+		var fact models.WeeklyFact
+		if err := json.Unmarshal(item, &fact); err != nil {
+			return nil, err
+		}
+		facts[fact.Week] = &fact
+	}
+	return facts, nil
+}
+
+// memoryBookDocument converts the book into a report document, loading photos
+// from upload storage. Missing or unreadable photos are left out.
+func (h *Handler) memoryBookDocument(ctx context.Context, pregnancyID int64, book *models.MemoryBook) (*report.Document, error) {
+	doc := &report.Document{Title: book.Title}
+	if book.BabyName != "" {
+		doc.Subtitle = "A keepsake for " + book.BabyName
+	}
+
+	disclaimer := ""
+	for _, ch := range book.Chapters {
+		section := report.Section{Heading: "Moments"}
+		if ch.Week > 0 {
+			section.Heading = fmt.Sprintf("Week %d", ch.Week)
+		}
+		if ch.Fact != nil {
+			text := ch.Fact.BabyDevelopment
+			if len(ch.Fact.Milestones) > 0 {
+				text += "\nMilestones: " + strings.Join(ch.Fact.Milestones, ", ")
+			}
+			if cite := factCitation(ch.Fact); cite != "" {
+				text += "\n" + cite
+			}
+			section.Blocks = append(section.Blocks, report.Block{Heading: "This week", Text: text})
+			disclaimer = ch.Fact.Disclaimer
+		}
+
+		for _, item := range ch.Items {
+			block := report.Block{Heading: item.Title, Text: item.Text}
+			if item.Kind == "photo" {
+				block.Caption, block.Text = item.Text, ""
+				if item.FileID != nil {
+					img, err := h.loadBookImage(ctx, pregnancyID, *item.FileID)
+					if err != nil {
+						return nil, err
+					}
+					block.Image = img
+				}
+			}
+			if block.Heading == "" && item.Kind == "milestone" {
+				block.Heading = "Milestone"
+			}
+			section.Blocks = append(section.Blocks, block)
+		}
+		doc.Sections = append(doc.Sections, section)
+	}
+	if disclaimer != "" {
+		doc.Sections = append(doc.Sections, report.Section{
+			Heading: "About the weekly facts",
+			Blocks:  []report.Block{{Text: disclaimer}},
+		})
+	}
+	return doc, nil
+}
+
+// factCitation is the sources line printed under a weekly fact, or "".
+func factCitation(fact *models.WeeklyFact) string {
+	var cites []string
+	for _, s := range fact.Sources {
+		if s.Publisher != "" {
+			cites = append(cites, s.Title+" ("+s.Publisher+")")
+		} else {
+			cites = append(cites, s.Title)
+		}
+	}
+	var line string
+	if len(cites) > 0 {
+		line = "Sources: " + strings.Join(cites, "; ")
+	}
+	if t, err := time.Parse(time.RFC3339, fact.ReviewedAt); err == nil && fact.ReviewedBy != "" {
+		if line != "" {
+			line += ". "
+		}
+		line += "Reviewed by " + fact.ReviewedBy + " on " + t.Format("January 2, 2006")
+	}
+	return line
+}
+
+// loadBookImage reads an uploaded photo. It returns nil without error when the
+// file is missing, belongs to another pregnancy or is not an image.
+func (h *Handler) loadBookImage(ctx context.Context, pregnancyID, fileID int64) (*report.Image, error) {
+	file, err := h.db.GetFile(ctx, fileID)
+	if err == db.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if file.PregnancyID != pregnancyID || file.DeletedAt.Valid || !strings.HasPrefix(file.MimeType.String, "image/") {
+		return nil, nil
+	}
+
+	path, err := h.filePath(file)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Memory book photo %d unreadable: %v", fileID, err)
+		return nil, nil
+	}
+	return &report.Image{Data: data, MimeType: file.MimeType.String}, nil
+}
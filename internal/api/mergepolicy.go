@@ -0,0 +1,31 @@
+// Package api provides the owner's per entry type merge policy setting.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// mergePolicyKeptReason explains a write the merge policy refused.
+const mergePolicyKeptReason = "entry exists and its merge policy keeps the stored copy"
+
+// checkSettingWrite returns the error status, code and message for a setting
+// write the user may not make, or 0 when they may. Only the owner changes the
+// merge policy, and it must be valid. data is nil for deletes and reverts.
+func checkSettingWrite(p *models.Pregnancy, userID, settingType string, data json.RawMessage) (int, string, string) {
+	if settingType != db.MergePolicySetting {
+		return 0, "", ""
+	}
+	if p.OwnerID != userID {
+		return http.StatusForbidden, "FORBIDDEN", "Only the owner can change the merge policy"
+	}
+	if data != nil {
+		if _, err := db.ParseMergePolicy(data); err != nil {
+			return http.StatusBadRequest, "VALIDATION_ERROR", err.Error()
+		}
+	}
+	return 0, "", ""
+}
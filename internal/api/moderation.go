@@ -0,0 +1,94 @@
+// Package api provides asynchronous moderation of shared images.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/moderation"
+)
+
+// Delays between moderation attempts when the moderator is unreachable.
+var moderationRetryDelays = []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute}
+
+// needsModeration reports whether an upload is a shared image that must be reviewed
+// before supporters can see it. Uploads are shared via the "shared" form field or
+// a "shared": true metadata key.
+func (h *Handler) needsModeration(mimeType, sharedField, metadata string) bool {
+	if h.moderator == nil || !strings.HasPrefix(mimeType, "image/") {
+		return false
+	}
+	if shared, _ := strconv.ParseBool(sharedField); shared {
+		return true
+	}
+	var meta struct {
+		Shared bool `json:"shared"`
+	}
+	json.Unmarshal([]byte(metadata), &meta)
+	return meta.Shared
+}
+
+// moderateFile runs in the background after upload. The file stays pending, and
+// hidden from supporters, until the moderator answers.
+func (h *Handler) moderateFile(file *models.File, pregnancy *models.Pregnancy, fullPath string) {
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		log.Printf("Moderation of file %d: %v", file.ID, err)
+		return
+	}
+
+	var verdict *moderation.Verdict
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		verdict, err = h.moderator.Moderate(ctx, data, file.MimeType.String)
+		cancel()
+		if err == nil {
+			break
+		}
+		if attempt == len(moderationRetryDelays) {
+			log.Printf("Moderation of file %d failed, leaving it pending: %v", file.ID, err)
+			return
+		}
+		time.Sleep(moderationRetryDelays[attempt])
+	}
+
+	ctx := context.Background()
+	status := moderation.StatusApproved
+	if !verdict.Allowed {
+		status = moderation.StatusBlocked
+	}
+	if err := h.db.SetFileModeration(ctx, file.ID, status, verdict.Reason); err != nil {
+		log.Printf("Failed to save moderation result for file %d: %v", file.ID, err)
+		return
+	}
+	if verdict.Allowed {
+		return
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"fileId":   file.ID,
+		"clientId": file.ClientID.String,
+		"reason":   verdict.Reason,
+	})
+	if err := h.db.CreateNotification(ctx, pregnancy.OwnerID, pregnancy.ID, "media_blocked", payload); err != nil {
+		log.Printf("Failed to create moderation notification: %v", err)
+	}
+}
+
+// payloadFileID returns the fileId referenced by an entry payload, if any.
+func payloadFileID(payload map[string]interface{}) (int64, bool) {
+	switch v := payload["fileId"].(type) {
+	case float64:
+		return int64(v), true
+	case string:
+		id, err := strconv.ParseInt(v, 10, 64)
+		return id, err == nil
+	}
+	return 0, false
+}
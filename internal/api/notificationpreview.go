@@ -0,0 +1,180 @@
+// Package api provides admin previews of notification templates.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// notificationTemplates holds the push and email copy of each notification
+// kind. Samples match the payloads the handlers write.
+var notificationTemplates = map[string]models.NotificationTemplate{
+	"care_note": {
+		Title:  "New care team note",
+		Body:   "There is a new note from the {authorRole} in your care team.",
+		Sample: map[string]interface{}{"noteId": 1, "authorRole": "provider"},
+	},
+	"coowner_linked": {
+		Title:  "Co-owner linked",
+		Body:   "A co-owner joined your pregnancy and can now edit everything you can.",
+		Sample: map[string]interface{}{"coownerId": "00000000-0000-0000-0000-000000000000"},
+	},
+	"coowner_removed": {
+		Title:  "Co-owner access removed",
+		Body:   "You are no longer a co-owner of this pregnancy.",
+		Sample: map[string]interface{}{"coownerId": "00000000-0000-0000-0000-000000000000"},
+	},
+	"coowner_left": {
+		Title:  "Co-owner left",
+		Body:   "Your co-owner left the pregnancy.",
+		Sample: map[string]interface{}{"coownerId": "00000000-0000-0000-0000-000000000000"},
+	},
+	"pairing_removal_pending": {
+		Title:  "Pairing ending",
+		Body:   "The {requestedBy} ended your pairing. It will be removed at {removeAt} unless undone.",
+		Sample: map[string]interface{}{"requestedBy": "owner", "removeAt": "2026-01-02T15:04:05Z"},
+	},
+	"pairing_removal_undone": {
+		Title:  "Pairing kept",
+		Body:   "The {requestedBy} undid the pairing removal. Nothing changes.",
+		Sample: map[string]interface{}{"requestedBy": "partner"},
+	},
+	"pregnancy_updated": {
+		Title:  "Pregnancy details updated",
+		Body:   "The {updatedBy} updated the pregnancy details.",
+		Sample: map[string]interface{}{"updatedBy": "partner", "invalidate": []map[string]interface{}{{"resource": "pregnancy", "id": 1, "version": "2026-01-02T15:04:05.123456Z"}}},
+	},
+	"entry_gap": {
+		Title:  "Time to log {label}?",
+		Body:   "It's been {days} days since the last {label} entry. Log one when you have a moment, or snooze this reminder.",
+		Sample: map[string]interface{}{"entryType": "weight", "label": "weight", "days": 15, "lastEntryAt": "2026-01-02T15:04:05Z"},
+	},
+	"media_blocked": {
+		Title:  "Photo hidden",
+		Body:   "A photo you shared was hidden by moderation ({reason}).",
+		Sample: map[string]interface{}{"fileId": 1, "clientId": "sample-photo", "reason": "nudity"},
+	},
+	"impersonation_ended": {
+		Title:  "Support session ended",
+		Body:   "Our support team accessed your pregnancy ({scope} access) from {startedAt} to {endedAt} to look into: {reason}. {requests} requests were made.",
+		Sample: map[string]interface{}{"impersonationId": 1, "reason": "Sync stuck on one device", "scope": "read", "startedAt": "2026-01-02T15:04:05Z", "endedAt": "2026-01-02T15:34:05Z", "requests": 12},
+	},
+	"security_event": {
+		Title:  "New access to your pregnancy",
+		Body:   "Your pregnancy was opened from a new device or location. Action taken: {action}.",
+		Sample: map[string]interface{}{"eventId": 1, "kind": "new_device", "userId": "00000000-0000-0000-0000-000000000000", "action": "notified"},
+	},
+}
+
+var templatePlaceholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// renderNotificationTemplate fills a template's placeholders from payload. It
+// also returns the placeholders the payload has no value for, which render empty.
+func renderNotificationTemplate(t models.NotificationTemplate, payload map[string]interface{}) (title, body string, missing []string) {
+	seen := make(map[string]bool)
+	fill := func(text string) string {
+		return templatePlaceholder.ReplaceAllStringFunc(text, func(m string) string {
+			key := m[1 : len(m)-1]
+			v, ok := payload[key]
+			if !ok || v == nil {
+				if !seen[key] {
+					seen[key] = true
+					missing = append(missing, key)
+				}
+				return ""
+			}
+			return fmt.Sprint(v)
+		})
+	}
+	return fill(t.Title), fill(t.Body), missing
+}
+
+// GetNotificationTemplates lists every notification template with its sample payload.
+func (h *Handler) GetNotificationTemplates(w http.ResponseWriter, r *http.Request) {
+	templates := make([]models.NotificationTemplate, 0, len(notificationTemplates))
+	for kind, t := range notificationTemplates {
+		t.Kind = kind
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Kind < templates[j].Kind })
+	writeJSON(w, http.StatusOK, map[string]interface{}{"templates": templates})
+}
+
+// PreviewNotification renders a template with its sample, a given payload or
+// one of the admin's own notifications. Other users' notifications can't be
+// used, as they never agreed to have their data shown. With send, the result
+// is also delivered to the admin alone as a test notification.
+func (h *Handler) PreviewNotification(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	var req models.NotificationPreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if req.NotificationID != nil && len(req.Payload) > 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Send payload or notificationId, not both")
+		return
+	}
+
+	preview := models.NotificationPreview{Kind: req.Kind, Source: "sample"}
+	switch {
+	case req.NotificationID != nil:
+		n, err := h.db.GetNotification(ctx, *req.NotificationID, user.UserID)
+		if err == db.ErrNotFound {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Notification not found among your own")
+			return
+		}
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		if req.Kind != "" && req.Kind != n.Kind {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Notification is a %s, not a %s", n.Kind, req.Kind))
+			return
+		}
+		preview.Kind, preview.Payload, preview.Source = n.Kind, n.Payload, "notification"
+	case len(req.Payload) > 0:
+		preview.Payload, preview.Source = req.Payload, "payload"
+	}
+
+	t, ok := notificationTemplates[preview.Kind]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Unknown notification kind")
+		return
+	}
+	if preview.Source == "sample" {
+		preview.Payload, _ = json.Marshal(t.Sample)
+	}
+
+	var payload map[string]interface{}
+	if len(preview.Payload) > 0 && string(preview.Payload) != "null" {
+		if err := json.Unmarshal(preview.Payload, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "payload must be a JSON object")
+			return
+		}
+	}
+	preview.Title, preview.Body, preview.Missing = renderNotificationTemplate(t, payload)
+
+	if req.Send {
+		if payload == nil {
+			payload = make(map[string]interface{})
+		}
+		payload["test"] = true
+		testPayload, _ := json.Marshal(payload)
+		n, err := h.db.CreateTestNotification(ctx, user.UserID, preview.Kind, testPayload)
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		preview.SentNotificationID = n.ID
+	}
+
+	writeJSON(w, http.StatusOK, preview)
+}
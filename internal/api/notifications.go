@@ -0,0 +1,62 @@
+// Package api provides in-app notification handlers.
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/pagination"
+)
+
+// GetNotifications lists the user's notifications (query: unread=true). With
+// limit or cursor it returns a page envelope; otherwise the newest 100.
+func (h *Handler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	paged := pagination.Requested(r)
+	params := legacyPage
+	if paged {
+		var ok bool
+		if params, ok = readPage(w, r); !ok {
+			return
+		}
+	}
+
+	notifications, err := h.db.GetNotifications(ctx, user.UserID, r.URL.Query().Get("unread") == "true", params)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	page := pagination.NewPage(notifications, params, notificationCursor)
+
+	if paged {
+		writeJSON(w, http.StatusOK, page)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"notifications": page.Items})
+}
+
+// MarkNotificationRead marks a notification as read.
+func (h *Handler) MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	notificationID, err := strconv.ParseInt(mux.Vars(r)["notificationId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid notification ID")
+		return
+	}
+
+	err = h.db.MarkNotificationRead(ctx, notificationID, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Notification not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
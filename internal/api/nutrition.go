@@ -0,0 +1,185 @@
+// Package api provides food lookups for meal entries and rough nutrient
+// totals of logged meals.
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/integrations/nutrition"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+const (
+	// defaultFoodResults and maxFoodResults bound a food search.
+	defaultFoodResults = 20
+	maxFoodResults     = 50
+	// maxNutritionDays caps the range of a nutrient totals request.
+	maxNutritionDays = 92
+	// maxNutritionFoods caps the distinct foods looked up per request.
+	maxNutritionFoods = 200
+)
+
+// SearchFoods searches the food database so a meal entry can reference a
+// food by ID. Query: q (2-100 characters), limit (default 20, max 50).
+func (h *Handler) SearchFoods(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if h.foods == nil {
+		writeError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "Nutrition lookup is not configured")
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if n := utf8.RuneCountInString(query); n < 2 || n > 100 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "q must be 2-100 characters")
+		return
+	}
+
+	limit := defaultFoodResults
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxFoodResults {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("limit must be 1-%d", maxFoodResults))
+			return
+		}
+		limit = n
+	}
+
+	foods, err := h.foods.Search(ctx, query, limit)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "SERVICE_UNAVAILABLE", fmt.Sprintf("Food search failed: %v", err))
+		return
+	}
+	if foods == nil {
+		foods = []nutrition.Food{}
+	}
+
+	writeJSON(w, http.StatusOK, models.NutritionSearchResponse{Query: query, Foods: foods})
+}
+
+// GetNutritionTotals adds up the nutrients of meal entries that reference a
+// food, per local day. Query: from, to (YYYY-MM-DD, default the last 7 days),
+// tz (IANA, default UTC).
+func (h *Handler) GetNutritionTotals(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	if h.foods == nil {
+		writeError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "Nutrition lookup is not configured")
+		return
+	}
+
+	timezone := q.Get("tz")
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid timezone")
+		return
+	}
+
+	today := time.Now().In(loc)
+	to := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	if s := q.Get("to"); s != "" {
+		if to, err = time.Parse("2006-01-02", s); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "to must be YYYY-MM-DD")
+			return
+		}
+	}
+	from := to.AddDate(0, 0, -6)
+	if s := q.Get("from"); s != "" {
+		if from, err = time.Parse("2006-01-02", s); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "from must be YYYY-MM-DD")
+			return
+		}
+	}
+	if to.Before(from) || to.Sub(from) >= maxNutritionDays*24*time.Hour {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("from..to must span 1-%d days", maxNutritionDays))
+		return
+	}
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if _, until, snoozed := activeSnooze(pregnancy, user.UserID, time.Now()); snoozed {
+		writeError(w, http.StatusForbidden, "SNOOZED", "Sharing is paused until "+until.Format(time.RFC3339))
+		return
+	}
+
+	rows, err := h.db.GetMealServings(ctx, pregnancy.ID, timezone, from.Format("2006-01-02"), to.Format("2006-01-02"), entryAudience(pregnancy, user.UserID))
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	foods, unknown := h.lookupFoods(ctx, rows)
+
+	resp := models.NutritionTotalsResponse{
+		From:         from.Format("2006-01-02"),
+		To:           to.Format("2006-01-02"),
+		Timezone:     timezone,
+		Days:         []models.NutritionDay{},
+		UnknownFoods: unknown,
+	}
+	for _, row := range rows {
+		if len(resp.Days) == 0 || resp.Days[len(resp.Days)-1].Date != row.Day {
+			resp.Days = append(resp.Days, models.NutritionDay{Date: row.Day})
+		}
+		day := &resp.Days[len(resp.Days)-1]
+		day.Meals += row.Meals
+
+		food, ok := foods[row.FoodID]
+		if !ok {
+			day.UnknownMeals += row.Meals
+			continue
+		}
+		day.Nutrients.AddScaled(&food.Nutrients, row.Servings)
+		resp.Totals.AddScaled(&food.Nutrients, row.Servings)
+	}
+
+	writeNegotiated(w, r, http.StatusOK, resp)
+}
+
+// lookupFoods resolves the foods meals reference through the cached provider.
+// It returns the foods found by ID and the IDs that could not be resolved.
+func (h *Handler) lookupFoods(ctx context.Context, rows []models.MealServings) (map[string]*nutrition.Food, []string) {
+	foods := make(map[string]*nutrition.Food)
+	unknown := []string{}
+	seen := make(map[string]bool)
+	down := false
+	for _, row := range rows {
+		if row.FoodID == "" || seen[row.FoodID] {
+			continue
+		}
+		seen[row.FoodID] = true
+		if down || len(seen) > maxNutritionFoods {
+			unknown = append(unknown, row.FoodID)
+			continue
+		}
+		f, err := h.foods.Food(ctx, row.FoodID)
+		if err != nil {
+			// Errors other than an unknown ID mean the service is likely down; skip the rest
+			down = err != nutrition.ErrNotFound
+			unknown = append(unknown, row.FoodID)
+			continue
+		}
+		foods[row.FoodID] = f
+	}
+	return foods, unknown
+}
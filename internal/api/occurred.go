@@ -0,0 +1,25 @@
+// Package api provides validation of client-reported entry times.
+package api
+
+import (
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// validateOccurredAt checks an entry's occurredAt: an RFC3339 timestamp with its
+// timezone offset, not in the future. Returns an error message, or "" if valid.
+func validateOccurredAt(req *models.EntryRequest, now time.Time) string {
+	if req.OccurredAt == nil {
+		return ""
+	}
+	at, err := time.Parse(time.RFC3339, *req.OccurredAt)
+	if err != nil {
+		return "occurredAt must be an RFC3339 timestamp with a timezone offset"
+	}
+	// Future plans belong in scheduledFor
+	if at.After(now.Add(backfillClockSkew)) {
+		return "occurredAt must not be in the future"
+	}
+	return ""
+}
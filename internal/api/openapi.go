@@ -0,0 +1,119 @@
+// Package api provides an OpenAPI document generated from the route registry.
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// openAPIPathParam matches a {name} path template variable.
+var openAPIPathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  map[string]interface{}
+)
+
+// openAPIDocument describes every registry route as an OpenAPI 3 operation,
+// except those of disabled surfaces.
+// Policies the spec has no field for are x- extensions: x-access (admin or
+// session), x-token-scope, x-rate-limit-budget, x-heavy, x-idempotent,
+// x-timeout-seconds, x-cache-control, x-gzip and x-sync-protocol.
+func openAPIDocument(disabled map[string]bool) map[string]interface{} {
+	paths := map[string]map[string]interface{}{}
+	for i := range routes {
+		rt := &routes[i]
+		if disabled[rt.Surface] {
+			continue
+		}
+		path := "/api" + rt.Path
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+
+		op := map[string]interface{}{
+			"operationId": handlerName(rt),
+			"summary":     rt.Summary,
+			"responses": map[string]interface{}{
+				"default": map[string]interface{}{
+					"description": `JSON response; errors are {"error": {"code", "message"}}`,
+				},
+			},
+			"x-token-scope":   rt.scope(),
+			"x-cache-control": rt.cacheControl(),
+		}
+		var params []interface{}
+		for _, m := range openAPIPathParam.FindAllStringSubmatch(rt.Path, -1) {
+			params = append(params, map[string]interface{}{
+				"name":     m[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]string{"type": "string"},
+			})
+		}
+		if params != nil {
+			op["parameters"] = params
+		}
+		if rt.Deprecated != nil {
+			op["deprecated"] = true
+			op["x-successor"] = rt.Deprecated.Successor
+		}
+		if rt.Access != "" {
+			op["x-access"] = rt.Access
+		}
+		if rt.Budget != "" {
+			op["x-rate-limit-budget"] = rt.Budget
+		}
+		if rt.Heavy {
+			op["x-heavy"] = true
+		}
+		if rt.Idempotent {
+			op["x-idempotent"] = true
+		}
+		if rt.Gzip {
+			op["x-gzip"] = true
+		}
+		if rt.Protocol {
+			op["x-sync-protocol"] = true
+		}
+		if rt.Timeout > 0 {
+			op["x-timeout-seconds"] = int(rt.Timeout.Seconds())
+		}
+		paths[path][strings.ToLower(rt.Method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]string{"title": "tracker2api", "version": "1"},
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]string{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "mvchat2 JWT or personal access token (" + personalTokenPrefix + "...)",
+				},
+			},
+		},
+		"security": []map[string][]string{{"bearer": {}}},
+		"paths":    paths,
+	}
+}
+
+// handlerName returns the name of the route's handler method, e.g. "GetEntries".
+func handlerName(rt *Route) string {
+	name := runtime.FuncForPC(reflect.ValueOf(rt.Handle).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// GetOpenAPI serves the OpenAPI document of the authenticated /api routes. It
+// needs no authentication.
+func (h *Handler) GetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() { openAPIDoc = openAPIDocument(h.disabledSurfaces) })
+	w.Header().Set("Cache-Control", CacheStatic)
+	writeJSON(w, http.StatusOK, openAPIDoc)
+}
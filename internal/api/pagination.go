@@ -0,0 +1,50 @@
+// Package api provides the pagination glue shared by list handlers.
+package api
+
+import (
+	"net/http"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/pagination"
+)
+
+// legacyPage is the single page lists return to clients that don't paginate,
+// matching the cap they had before pagination.
+var legacyPage = pagination.Params{Limit: pagination.MaxLimit}
+
+// readPage reads the limit and cursor query parameters. It writes a 400 and
+// returns false if they are invalid.
+func readPage(w http.ResponseWriter, r *http.Request) (pagination.Params, bool) {
+	p, err := pagination.FromRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return p, false
+	}
+	return p, true
+}
+
+// Cursor keys of the paginated lists.
+
+func entryCursor(e models.Entry) pagination.Cursor {
+	return pagination.Cursor{Time: e.CreatedAt, ID: e.ID}
+}
+
+func notificationCursor(n models.Notification) pagination.Cursor {
+	return pagination.Cursor{Time: n.CreatedAt, ID: n.ID}
+}
+
+func securityEventCursor(e models.SecurityEvent) pagination.Cursor {
+	return pagination.Cursor{Time: e.CreatedAt, ID: e.ID}
+}
+
+func coownerActionCursor(a models.CoownerAction) pagination.Cursor {
+	return pagination.Cursor{Time: a.CreatedAt, ID: a.ID}
+}
+
+func impersonationCursor(i models.Impersonation) pagination.Cursor {
+	return pagination.Cursor{Time: i.CreatedAt, ID: i.ID}
+}
+
+func impersonatedRequestCursor(r models.ImpersonatedRequest) pagination.Cursor {
+	return pagination.Cursor{Time: r.CreatedAt, ID: r.ID}
+}
@@ -0,0 +1,91 @@
+// Package api provides the cooling-off period for pairing removal.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+const (
+	// pairingRemovalWindow is how long the requester has to undo a removal.
+	// Partner access is suspended meanwhile.
+	pairingRemovalWindow = 24 * time.Hour
+
+	// pairingCleanupInterval is how often expired removals are completed.
+	pairingCleanupInterval = 10 * time.Minute
+)
+
+// UndoPairingRemoval restores a pairing the caller asked to remove, while the
+// cooling-off period lasts.
+func (h *Handler) UndoPairingRemoval(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, err := h.db.UndoPairingRemoval(ctx, user.UserID, time.Now().Add(-pairingRemovalWindow))
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pairing removal to undo")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	h.notifyPairing(ctx, pregnancy, "pairing_removal_undone", user.UserID, time.Time{})
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// RunPairingCleanup completes pairing removals whose cooling-off period has
+// passed. It never returns; start it in a goroutine.
+func (h *Handler) RunPairingCleanup() {
+	for {
+		removed, err := h.db.CompletePairingRemovals(context.Background(), time.Now().Add(-pairingRemovalWindow))
+		if err != nil {
+			log.Printf("Pairing cleanup: %v", err)
+		} else if removed > 0 {
+			log.Printf("Pairing cleanup: removed %d pairings", removed)
+		}
+		time.Sleep(pairingCleanupInterval)
+	}
+}
+
+// notifyPairing tells both the owner and the partner about a change to their
+// pairing. removeAt is included when set.
+func (h *Handler) notifyPairing(ctx context.Context, p *models.Pregnancy, kind, actorID string, removeAt time.Time) {
+	requestedBy := "owner"
+	if actorID != p.OwnerID {
+		requestedBy = "partner"
+	}
+	fields := map[string]interface{}{"requestedBy": requestedBy}
+	if !removeAt.IsZero() {
+		fields["removeAt"] = removeAt.UTC().Format(time.RFC3339)
+	}
+	payload, _ := json.Marshal(fields)
+
+	recipients := []string{p.OwnerID}
+	if p.PartnerID.Valid {
+		recipients = append(recipients, p.PartnerID.String)
+	}
+	for _, userID := range recipients {
+		if err := h.db.CreateNotification(ctx, userID, p.ID, kind, payload); err != nil {
+			log.Printf("Failed to create %s notification: %v", kind, err)
+		}
+	}
+}
+
+// setPairingRemoval reports a pending removal on a pairing status response.
+func setPairingRemoval(resp *models.PairingStatusResponse, p *models.Pregnancy, userID string) {
+	if p.PartnerStatus.String != "removing" || !p.PartnerRemovalRequestedAt.Valid {
+		return
+	}
+	removeAt := p.PartnerRemovalRequestedAt.Time.Add(pairingRemovalWindow).UTC().Format(time.RFC3339)
+	resp.RemovalPending = true
+	resp.RemovalAt = &removeAt
+	resp.CanUndoRemoval = p.PartnerRemovalRequestedBy.String == userID
+}
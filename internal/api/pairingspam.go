@@ -0,0 +1,102 @@
+// Package api provides spam screening of partner pairing requests.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/abuse"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// screenPairingRequest runs the pairing request through the spam detector. A
+// blocked attempt is written to the target owner's security events and
+// answered here; it returns false then. If the detector fails the request is
+// let through, so an outage of the CAPTCHA service doesn't stop pairing.
+func (h *Handler) screenPairingRequest(w http.ResponseWriter, r *http.Request, req *models.PairingRequestBody) bool {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	sentToday, sameTarget, err := h.db.GetPairingRequestHistory(ctx, user.UserID, req.TargetEmail)
+	if err != nil {
+		writeDomainError(w, err)
+		return false
+	}
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteIP = r.RemoteAddr
+	}
+
+	verdict, err := h.pairingScreen.Screen(ctx, &abuse.Attempt{
+		RequesterID:  user.UserID,
+		TargetEmail:  req.TargetEmail,
+		CaptchaToken: req.CaptchaToken,
+		RemoteIP:     remoteIP,
+		SentToday:    sentToday,
+		SameTarget:   sameTarget,
+	})
+	if err != nil {
+		log.Printf("Pairing spam check failed, allowing request: %v", err)
+		return true
+	}
+	if verdict.Allowed {
+		return true
+	}
+
+	// Logging is best effort and must not slow down the response
+	go h.logBlockedPairing(user.UserID, req.TargetEmail, r.Header.Get("User-Agent"), verdict.Reason)
+
+	switch verdict.Reason {
+	case abuse.ReasonDuplicateTarget:
+		writeError(w, http.StatusConflict, "CONFLICT", "You already sent a pairing request to this address")
+	case abuse.ReasonDailyCap:
+		w.Header().Set("Retry-After", "86400")
+		writeError(w, http.StatusTooManyRequests, "RATE_LIMITED", "Too many pairing requests today. Try again tomorrow.")
+	case abuse.ReasonCaptchaFailed:
+		writeError(w, http.StatusForbidden, "CAPTCHA_REQUIRED", "CAPTCHA verification failed, please try again")
+	default:
+		writeError(w, http.StatusForbidden, "CAPTCHA_REQUIRED", "Complete the CAPTCHA and send its captchaToken")
+	}
+	return false
+}
+
+// logBlockedPairing writes a pairing_blocked security event to the pregnancy
+// the target owns, once a day per requester and reason. Targets without an
+// account or a pregnancy have no feed, so the attempt only goes to the server log.
+func (h *Handler) logBlockedPairing(requesterID, targetEmail, userAgent, reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	log.Printf("Blocked pairing request from %s: %s", requesterID, reason)
+	targetID, err := h.db.FindUserIDByEmail(ctx, targetEmail)
+	if err != nil || !targetID.Valid {
+		if err != nil {
+			log.Printf("Failed to resolve pairing target: %v", err)
+		}
+		return
+	}
+	pregnancy, err := h.db.GetPregnancyByOwner(ctx, targetID.String)
+	if err != nil {
+		if err != db.ErrNotFound {
+			log.Printf("Failed to load pregnancy for blocked pairing: %v", err)
+		}
+		return
+	}
+
+	_, err = h.db.CreateSecurityEventOnce(ctx, &models.SecurityEvent{
+		PregnancyID: pregnancy.ID,
+		UserID:      requesterID,
+		Kind:        models.SecurityEventPairingBlocked,
+		UserAgent:   sql.NullString{String: userAgent, Valid: userAgent != ""},
+		Action:      "blocked",
+		Reason:      sql.NullString{String: reason, Valid: true},
+	}, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		log.Printf("Failed to create security event: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+// Package api provides short-lived signed URLs for profile photos.
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+)
+
+// signedURLTTL is how long a signed photo URL stays valid. Expiry is rounded to
+// the window so clients and caches see the same URL for a while.
+const signedURLTTL = time.Hour
+
+// signedFileURL returns a URL for the file that expires one to two TTLs from now.
+// Without signed file links it is the authenticated content URL.
+func (h *Handler) signedFileURL(fileID int64, now time.Time) string {
+	if !h.SurfaceEnabled(SurfaceSignedFiles) {
+		return fmt.Sprintf("/api/files/%d/content", fileID)
+	}
+	expires := now.Truncate(signedURLTTL).Add(2 * signedURLTTL).Unix()
+	return fmt.Sprintf("/api/signed/files/%d?expires=%d&sig=%s", fileID, expires, h.fileSignature(fileID, expires))
+}
+
+func (h *Handler) fileSignature(fileID, expires int64) string {
+	mac := hmac.New(sha256.New, h.fileURLKey)
+	fmt.Fprintf(mac, "%d|%d", fileID, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// GetSignedFile serves a profile photo to anyone holding an unexpired signed URL.
+func (h *Handler) GetSignedFile(w http.ResponseWriter, r *http.Request) {
+	fileID, err := strconv.ParseInt(mux.Vars(r)["fileId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "File not found")
+		return
+	}
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Invalid signature")
+		return
+	}
+
+	expected := h.fileSignature(fileID, expires)
+	if !hmac.Equal([]byte(expected), []byte(r.URL.Query().Get("sig"))) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Invalid signature")
+		return
+	}
+	remaining := time.Until(time.Unix(expires, 0))
+	if remaining <= 0 {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Link expired")
+		return
+	}
+
+	file, err := h.db.GetFile(r.Context(), fileID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "File not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	path, err := h.filePath(file)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if file.MimeType.Valid {
+		w.Header().Set("Content-Type", file.MimeType.String)
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(remaining.Seconds())))
+	http.ServeFile(w, r, path)
+}
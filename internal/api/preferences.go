@@ -0,0 +1,115 @@
+// Package api provides per-user locale preferences and the formatting hints
+// clients render dates, numbers and units with.
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/locale"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+var validMeasurementSystems = map[string]bool{
+	locale.MeasurementMetric: true,
+	locale.MeasurementUS:     true,
+	locale.MeasurementUK:     true,
+}
+
+var validFirstDaysOfWeek = map[string]bool{
+	"sunday":   true,
+	"monday":   true,
+	"saturday": true,
+}
+
+// preferencesResponse resolves the caller's locale (saved preference, then
+// Accept-Language, then the default) and derives the formatting hints.
+func preferencesResponse(r *http.Request, p *models.UserPreferences) models.PreferencesResponse {
+	resp := models.PreferencesResponse{Locale: locale.Default, LocaleSource: "default"}
+	if p != nil {
+		resp.MeasurementSystem = p.MeasurementSystem.String
+		resp.FirstDayOfWeek = p.FirstDayOfWeek.String
+	}
+	if p != nil && p.Locale.Valid {
+		resp.Locale, resp.LocaleSource = p.Locale.String, "preference"
+	} else if l := locale.FromRequest(r); l != "" {
+		resp.Locale, resp.LocaleSource = l, "header"
+	}
+
+	resp.Formatting = locale.For(resp.Locale, locale.Overrides{
+		MeasurementSystem: resp.MeasurementSystem,
+		FirstDayOfWeek:    resp.FirstDayOfWeek,
+	})
+	return resp
+}
+
+// GetPreferences returns the caller's locale and formatting hints. Users who
+// never saved preferences get hints for their Accept-Language header.
+func (h *Handler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	p, err := h.db.GetUserPreferences(r.Context(), user.UserID)
+	if err != nil && err != db.ErrNotFound {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, preferencesResponse(r, p))
+}
+
+// UpdatePreferences saves the caller's locale and formatting overrides.
+func (h *Handler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	var req models.PreferencesRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	p, err := h.db.GetUserPreferences(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		p = &models.UserPreferences{UserID: user.UserID}
+	} else if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if req.Locale != nil {
+		p.Locale.String, p.Locale.Valid = "", false
+		if s := strings.TrimSpace(*req.Locale); s != "" {
+			l, err := locale.Parse(s)
+			if err != nil || len(l) > 35 {
+				writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "locale must be a BCP 47 language tag such as en-GB")
+				return
+			}
+			p.Locale.String, p.Locale.Valid = l, true
+		}
+	}
+	if req.MeasurementSystem != nil {
+		s := strings.ToLower(strings.TrimSpace(*req.MeasurementSystem))
+		if s != "" && !validMeasurementSystems[s] {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "measurementSystem must be metric, us or uk")
+			return
+		}
+		p.MeasurementSystem.String, p.MeasurementSystem.Valid = s, s != ""
+	}
+	if req.FirstDayOfWeek != nil {
+		s := strings.ToLower(strings.TrimSpace(*req.FirstDayOfWeek))
+		if s != "" && !validFirstDaysOfWeek[s] {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "firstDayOfWeek must be sunday, monday or saturday")
+			return
+		}
+		p.FirstDayOfWeek.String, p.FirstDayOfWeek.Valid = s, s != ""
+	}
+
+	saved, err := h.db.UpsertUserPreferences(ctx, p)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, preferencesResponse(r, saved))
+}
@@ -0,0 +1,131 @@
+// Package api provides the owner's preview of what a supporter or partner sees.
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// previewTTL is how long a preview stands for what the role sees. It is not
+// updated after that, so clients fetch a new one.
+const previewTTL = 10 * time.Minute
+
+// previewAudiences are the roles an owner can preview as, with the entry
+// visibility levels each reads.
+var previewAudiences = map[string][]string{
+	"support": {models.VisibilityShared},
+	"partner": {models.VisibilityShared, models.VisibilityPartner},
+}
+
+// previewLabels name the roles in the preview notice.
+var previewLabels = map[string]string{"support": "supporters", "partner": "partner"}
+
+// GetPreviewAs renders the pregnancy as a supporter or partner would see it,
+// through the same visibility, snooze and moderation rules, so the owner can
+// check before sharing. Only the owner and coowner may preview.
+func (h *Handler) GetPreviewAs(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	role := r.URL.Query().Get("role")
+	audience, ok := previewAudiences[role]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "role must be support or partner")
+		return
+	}
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if entryAudience(pregnancy, user.UserID) != nil {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only the owner can preview what others see")
+		return
+	}
+
+	now := time.Now()
+	view, err := h.previewSync(ctx, pregnancy, audience, now)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	resp := models.PreviewAsResponse{
+		Preview: models.PreviewAs{
+			Role:        role,
+			ReadOnly:    true,
+			Notice:      "This is what your " + previewLabels[role] + " sees right now. Previewing shares and changes nothing.",
+			GeneratedAt: now.Format(time.RFC3339),
+			ExpiresAt:   now.Add(previewTTL).Format(time.RFC3339),
+		},
+		Sync: view,
+	}
+	if role == "support" {
+		// A viewer that is neither owner nor partner gets the supporter view
+		if resp.Lite, err = h.liteSync(ctx, pregnancy, ""); err != nil {
+			writeDomainError(w, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// previewSync builds the full GET /api/sync response for an audience other
+// than the caller's, as of now.
+func (h *Handler) previewSync(ctx context.Context, pregnancy *models.Pregnancy, audience []string, now time.Time) (*models.SyncResponse, error) {
+	if start, until, snoozed := activeSnooze(pregnancy, "", now); snoozed {
+		return &models.SyncResponse{
+			Pregnancy:    h.toPregnancyDTO(pregnancy),
+			ServerTime:   start.Format(time.RFC3339),
+			Snoozed:      true,
+			SnoozedUntil: until.Format(time.RFC3339),
+		}, nil
+	}
+
+	entries, err := h.db.GetEntries(ctx, pregnancy.ID, "", nil, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	entriesByType := make(map[string][]models.Entry)
+	for _, e := range visibleEntries(entries, audience, nil) {
+		entriesByType[e.EntryType] = append(entriesByType[e.EntryType], e)
+	}
+
+	settings, err := h.db.GetSettings(ctx, pregnancy.ID)
+	if err != nil {
+		return nil, err
+	}
+	settingVersions, err := h.db.GetSettingVersions(ctx, pregnancy.ID)
+	if err != nil {
+		return nil, err
+	}
+	settingRevisions, err := h.db.GetSettingRevisionIDs(ctx, pregnancy.ID)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := h.entrySummaries(ctx, pregnancy.ID, audience, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SyncResponse{
+		Pregnancy:        h.toPregnancyDTO(pregnancy),
+		Entries:          entriesByType,
+		Settings:         settings,
+		SettingVersions:  settingVersions,
+		SettingRevisions: settingRevisions,
+		EntrySummaries:   summaries,
+		SyncVersion:      pregnancy.SyncVersion,
+		ServerTime:       now.Format(time.RFC3339),
+		CompactedBefore:  compactedBefore(pregnancy),
+	}, nil
+}
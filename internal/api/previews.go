@@ -0,0 +1,298 @@
+// Package api provides server-rendered previews of uploaded PDFs and videos.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/moderation"
+	"github.com/scalecode-solutions/tracker2api/internal/preview"
+)
+
+const (
+	// previewInterval is how often the queue is polled for previews to render.
+	previewInterval = 30 * time.Second
+
+	// previewBatchSize is how many previews one poll renders, one at a time.
+	previewBatchSize = 2
+
+	// previewStaleAfter is how long a preview may stay running before it is
+	// considered abandoned by a stopped server.
+	previewStaleAfter = 30 * time.Minute
+)
+
+// RunPreviews renders queued previews. It never returns; start it in a goroutine.
+func (h *Handler) RunPreviews() {
+	ctx := context.Background()
+	for {
+		if n, err := h.db.RequeueStalePreviews(ctx, time.Now().Add(-previewStaleAfter)); err != nil {
+			log.Printf("Previews: failed to requeue stale previews: %v", err)
+		} else if n > 0 {
+			log.Printf("Previews: requeued %d interrupted preview(s)", n)
+		}
+
+		previews, err := h.db.ClaimFilePreviews(ctx, previewBatchSize)
+		if err != nil {
+			log.Printf("Previews: failed to claim previews: %v", err)
+		}
+		for i := range previews {
+			h.renderPreview(ctx, &previews[i])
+		}
+		if len(previews) < previewBatchSize {
+			time.Sleep(previewInterval)
+		}
+	}
+}
+
+// renderPreview renders a claimed preview into previews/<fileID> under the
+// region's hot root, replacing earlier output.
+func (h *Handler) renderPreview(ctx context.Context, p *models.FilePreview) {
+	fail := func(err error) {
+		log.Printf("Previews: file %d: %v", p.FileID, err)
+		if err := h.db.FailFilePreview(ctx, p.FileID, err.Error()); err != nil {
+			log.Printf("Previews: file %d: failed to record failure: %v", p.FileID, err)
+		}
+	}
+
+	if !h.previewer.Supports(p.Kind) {
+		fail(preview.ErrUnsupported)
+		return
+	}
+	file, err := h.db.GetFile(ctx, p.FileID)
+	if err != nil {
+		fail(err)
+		return
+	}
+	src, err := h.filePath(file)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	rel := filepath.Join("previews", strconv.FormatInt(file.ID, 10))
+	dir, err := h.storage.Path(file.Region, rel)
+	if err != nil {
+		fail(err)
+		return
+	}
+	os.RemoveAll(dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fail(err)
+		return
+	}
+
+	if err := h.previewer.Render(ctx, p.Kind, src, dir); err != nil {
+		os.RemoveAll(dir)
+		fail(err)
+		return
+	}
+	if err := h.db.CompleteFilePreview(ctx, file.ID, rel, p.Kind == preview.KindVideo); err != nil {
+		log.Printf("Previews: file %d: failed to save result: %v", file.ID, err)
+	}
+}
+
+// queuePreview queues a preview for a newly uploaded file when one applies.
+func (h *Handler) queuePreview(ctx context.Context, file *models.File) {
+	kind := preview.Kind(file.MimeType.String)
+	if h.previewer == nil || !h.previewer.Supports(kind) {
+		return
+	}
+	if _, err := h.db.QueueFilePreview(ctx, file.ID, kind); err != nil {
+		log.Printf("Failed to queue preview for file %d: %v", file.ID, err)
+	}
+}
+
+// getPreviewFile loads the {fileId} file for a user who may view its preview:
+// anyone who can read the pregnancy's files, and supporters for shared files
+// that passed moderation. It writes the error response and returns nil otherwise.
+func (h *Handler) getPreviewFile(w http.ResponseWriter, r *http.Request) *models.File {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	fileID, err := strconv.ParseInt(mux.Vars(r)["fileId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "File not found")
+		return nil
+	}
+	file, err := h.db.GetFile(ctx, fileID)
+	if err == db.ErrNotFound || (err == nil && file.DeletedAt.Valid) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "File not found")
+		return nil
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return nil
+	}
+
+	pregnancy, err := h.db.GetPregnancyByID(ctx, file.PregnancyID)
+	if err != nil {
+		writeDomainError(w, err)
+		return nil
+	}
+	if canAccessFiles(pregnancy, user.UserID) {
+		return file
+	}
+
+	supported, err := h.db.GetPregnancyBySupporter(ctx, user.UserID)
+	if err != nil && err != db.ErrNotFound {
+		writeDomainError(w, err)
+		return nil
+	}
+	if err == nil && supported.ID == pregnancy.ID && supporterVisibleFile(file) {
+		return file
+	}
+	writeError(w, http.StatusForbidden, "FORBIDDEN", "Access denied")
+	return nil
+}
+
+// supporterVisibleFile reports whether a file is shared with supporters: marked
+// shared in its metadata or approved by moderation, and not pending or blocked.
+func supporterVisibleFile(file *models.File) bool {
+	switch file.ModerationStatus.String {
+	case moderation.StatusApproved:
+		return true
+	case moderation.StatusPending, moderation.StatusBlocked:
+		return false
+	}
+	var meta struct {
+		Shared bool `json:"shared"`
+	}
+	json.Unmarshal(file.Metadata, &meta)
+	return meta.Shared
+}
+
+// filePreviewResponse describes a preview with URLs to its outputs once ready.
+func filePreviewResponse(p *models.FilePreview) models.FilePreviewResponse {
+	resp := models.FilePreviewResponse{
+		FileID: p.FileID,
+		Kind:   p.Kind,
+		Status: p.Status,
+		Error:  p.Error.String,
+	}
+	if p.Status == "ready" {
+		resp.PosterURL = fmt.Sprintf("/api/files/%d/preview/poster", p.FileID)
+		if p.HasStream {
+			resp.StreamURL = fmt.Sprintf("/api/files/%d/preview/hls/%s", p.FileID, preview.PlaylistName)
+		}
+	}
+	return resp
+}
+
+// GetFilePreview reports a file's preview status and, once ready, where to load it.
+func (h *Handler) GetFilePreview(w http.ResponseWriter, r *http.Request) {
+	file := h.getPreviewFile(w, r)
+	if file == nil {
+		return
+	}
+
+	p, err := h.db.GetFilePreview(r.Context(), file.ID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No preview for this file")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, filePreviewResponse(p))
+}
+
+// RequestFilePreview queues (re)rendering of a file's preview, e.g. after a
+// failure or for files uploaded before previews were enabled.
+func (h *Handler) RequestFilePreview(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	file := h.getPreviewFile(w, r)
+	if file == nil {
+		return
+	}
+	a, err := h.resolveAccess(ctx, user.UserID)
+	if err != nil && err != db.ErrNotFound {
+		writeDomainError(w, err)
+		return
+	}
+	if a == nil || a.pregnancy.ID != file.PregnancyID || a.permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return
+	}
+
+	kind := preview.Kind(file.MimeType.String)
+	if h.previewer == nil || !h.previewer.Supports(kind) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Previews are not available for this file type")
+		return
+	}
+
+	p, err := h.db.QueueFilePreview(ctx, file.ID, kind)
+	if err == db.ErrConflict {
+		writeError(w, http.StatusConflict, "CONFLICT", "Preview is already rendering")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, filePreviewResponse(p))
+}
+
+// GetFilePreviewPoster serves a preview's poster image.
+func (h *Handler) GetFilePreviewPoster(w http.ResponseWriter, r *http.Request) {
+	h.serveFilePreview(w, r, preview.PosterName, "image/jpeg")
+}
+
+// GetFilePreviewStream serves a video preview's HLS playlist and segments.
+func (h *Handler) GetFilePreviewStream(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if !preview.ValidSegmentName(name) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Preview not found")
+		return
+	}
+	contentType := "video/mp2t"
+	if name == preview.PlaylistName {
+		contentType = "application/vnd.apple.mpegurl"
+	}
+	h.serveFilePreview(w, r, name, contentType)
+}
+
+// serveFilePreview serves one output of a ready preview. Only rendered output
+// is ever served here, never the uploaded bytes.
+func (h *Handler) serveFilePreview(w http.ResponseWriter, r *http.Request, name, contentType string) {
+	file := h.getPreviewFile(w, r)
+	if file == nil {
+		return
+	}
+
+	p, err := h.db.GetFilePreview(r.Context(), file.ID)
+	if err == db.ErrNotFound || (err == nil && (p.Status != "ready" || (name != preview.PosterName && !p.HasStream))) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Preview not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	path, err := h.storage.Path(file.Region, filepath.Join(p.PreviewDir.String, name))
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Preview not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeFile(w, r, path)
+}
@@ -0,0 +1,119 @@
+// Package api provides the mvchat2 profile webhook that keeps cached names fresh.
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+const (
+	// profileWebhookSkew is how far a webhook timestamp may be from our clock.
+	// Older deliveries are rejected so a captured request can't be replayed.
+	profileWebhookSkew = 5 * time.Minute
+
+	// maxProfileWebhookBody caps the webhook body.
+	maxProfileWebhookBody = 64 << 10
+)
+
+// ProfileWebhook receives profile changes from mvchat2. Requests are signed with
+// the shared secret: X-Mvchat-Signature is "sha256=" and the hex HMAC-SHA256 of
+// the X-Mvchat-Timestamp value (Unix seconds), a ".", and the raw body.
+func (h *Handler) ProfileWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxProfileWebhookBody))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, "VALIDATION_ERROR", "Body too large")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Could not read body")
+		return
+	}
+	if !h.validWebhookSignature(r.Header.Get("X-Mvchat-Timestamp"), r.Header.Get("X-Mvchat-Signature"), body, time.Now()) {
+		writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid signature")
+		return
+	}
+
+	var event models.ProfileEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid JSON")
+		return
+	}
+	if event.Type != "profile.updated" {
+		// Acknowledge so mvchat2 doesn't retry events we don't consume
+		writeJSON(w, http.StatusOK, map[string]bool{"success": true, "applied": false})
+		return
+	}
+	updatedAt, err := time.Parse(time.RFC3339, event.UpdatedAt)
+	if event.UserID == "" || err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "userId and an RFC3339 updatedAt are required")
+		return
+	}
+
+	name := strings.TrimSpace(event.DisplayName)
+	applied, err := h.db.ApplyProfileEvent(r.Context(), &models.UserProfile{
+		UserID:           event.UserID,
+		DisplayName:      sql.NullString{String: name, Valid: name != ""},
+		AvatarURL:        sql.NullString{String: event.AvatarURL, Valid: event.AvatarURL != ""},
+		ProfileUpdatedAt: updatedAt,
+	})
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true, "applied": applied})
+}
+
+// validWebhookSignature checks a webhook signature and that its timestamp is recent.
+func (h *Handler) validWebhookSignature(timestamp, signature string, body []byte, now time.Time) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := now.Sub(time.Unix(ts, 0)); skew > profileWebhookSkew || skew < -profileWebhookSkew {
+		return false
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, h.webhookSecret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+// RunProfileReconciliation refreshes cached profiles from the mvchat2 users table
+// every interval, fixing names the webhook missed. It never returns; start it
+// in a goroutine.
+func (h *Handler) RunProfileReconciliation(interval time.Duration) {
+	for {
+		profiles, names, err := h.db.ReconcileUserProfiles(context.Background())
+		if err != nil {
+			log.Printf("Profile reconciliation: %v", err)
+		} else if profiles > 0 || names > 0 {
+			log.Printf("Profile reconciliation: refreshed %d profiles, updated %d names", profiles, names)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// profileAvatar returns a user's cached avatar URL, or "".
+func profileAvatar(profiles map[string]models.UserProfile, userID string) string {
+	return profiles[userID].AvatarURL.String
+}
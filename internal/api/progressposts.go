@@ -0,0 +1,285 @@
+// Package api provides weekly pregnancy progress posts into mvchat2 conversations.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+const (
+	// progressPostInterval is how often the runner checks for a new week.
+	progressPostInterval = time.Hour
+	// Weeks outside this range are not announced.
+	progressPostFirstWeek = 4
+	progressPostLastWeek  = 42
+	// maxProgressTemplate caps a message template in bytes.
+	maxProgressTemplate = 1000
+)
+
+// defaultProgressTemplate is posted when the family set no template.
+const defaultProgressTemplate = "Week {week}! {daysRemaining} days to go."
+
+// getProgressPostPregnancy loads the pregnancy from the {id} route variable if
+// the user is its owner or coowner. It writes the error response and returns
+// nil otherwise.
+func (h *Handler) getProgressPostPregnancy(w http.ResponseWriter, r *http.Request) *models.Pregnancy {
+	user := getUserInfo(r)
+	pregnancyID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid pregnancy ID")
+		return nil
+	}
+
+	pregnancy, err := h.db.GetPregnancyByID(r.Context(), pregnancyID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Pregnancy not found")
+		return nil
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return nil
+	}
+	if pregnancy.OwnerID != user.UserID && !(pregnancy.CoownerID.Valid && pregnancy.CoownerID.String == user.UserID) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only the owner or coowner can manage progress posts")
+		return nil
+	}
+	return pregnancy
+}
+
+// renderProgressPost fills a progress message for the pregnancy at a week. The
+// week's own template wins over the general one.
+func renderProgressPost(post *models.ProgressPost, p *models.Pregnancy, progress *models.WeekProgress) (string, error) {
+	template := post.Template
+	var weekTemplates map[string]string
+	if len(post.WeekTemplates) > 0 {
+		if err := json.Unmarshal(post.WeekTemplates, &weekTemplates); err != nil {
+			return "", fmt.Errorf("week templates: %w", err)
+		}
+	}
+	if t := weekTemplates[strconv.Itoa(progress.Week)]; t != "" {
+		template = t
+	}
+	if template == "" {
+		template = defaultProgressTemplate
+	}
+
+	return strings.NewReplacer(
+		"{week}", strconv.Itoa(progress.Week),
+		"{day}", strconv.Itoa(progress.Day),
+		"{daysRemaining}", strconv.Itoa(progress.DaysRemaining),
+		"{trimester}", strconv.Itoa(progress.Trimester),
+		"{babyName}", p.BabyName.String,
+		"{momName}", p.MomName.String,
+	).Replace(template), nil
+}
+
+// validateProgressPost checks a progress post request.
+func validateProgressPost(req *models.ProgressPostRequest) string {
+	if req.ConversationID == "" || len(req.ConversationID) > 100 {
+		return "conversationId is required (at most 100 characters)"
+	}
+	if len(req.Template) > maxProgressTemplate {
+		return fmt.Sprintf("template must be at most %d bytes", maxProgressTemplate)
+	}
+	for week, t := range req.WeekTemplates {
+		n, err := strconv.Atoi(week)
+		if err != nil || n < progressPostFirstWeek || n > progressPostLastWeek {
+			return fmt.Sprintf("weekTemplates keys must be weeks %d-%d", progressPostFirstWeek, progressPostLastWeek)
+		}
+		if len(t) > maxProgressTemplate {
+			return fmt.Sprintf("template for week %s must be at most %d bytes", week, maxProgressTemplate)
+		}
+	}
+	return ""
+}
+
+// GetProgressPost returns the pregnancy's progress post settings.
+func (h *Handler) GetProgressPost(w http.ResponseWriter, r *http.Request) {
+	pregnancy := h.getProgressPostPregnancy(w, r)
+	if pregnancy == nil {
+		return
+	}
+
+	post, err := h.db.GetProgressPost(r.Context(), pregnancy.ID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Progress posts are not set up")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, post)
+}
+
+// UpdateProgressPost opts the pregnancy into weekly progress posts in an mvchat2
+// conversation, or changes their settings. The first post goes out when the
+// next gestational week starts.
+func (h *Handler) UpdateProgressPost(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	pregnancy := h.getProgressPostPregnancy(w, r)
+	if pregnancy == nil {
+		return
+	}
+	if h.chat == nil {
+		writeError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "mvchat2 posting is not configured")
+		return
+	}
+
+	var req models.ProgressPostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if msg := validateProgressPost(&req); msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+
+	enabled := req.Enabled == nil || *req.Enabled
+	weekTemplates, err := json.Marshal(req.WeekTemplates)
+	if err != nil || req.WeekTemplates == nil {
+		weekTemplates = json.RawMessage(`{}`)
+	}
+	var currentWeek *int
+	if progress := weekProgress(pregnancy, time.Now()); progress != nil {
+		currentWeek = &progress.Week
+	}
+
+	post, err := h.db.UpsertProgressPost(r.Context(), pregnancy.ID, req.ConversationID, enabled, req.Template, weekTemplates, currentWeek, user.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, post)
+}
+
+// DeleteProgressPost turns progress posts off and forgets their settings.
+func (h *Handler) DeleteProgressPost(w http.ResponseWriter, r *http.Request) {
+	pregnancy := h.getProgressPostPregnancy(w, r)
+	if pregnancy == nil {
+		return
+	}
+
+	if err := h.db.DeleteProgressPost(r.Context(), pregnancy.ID); err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Progress posts are not set up")
+		return
+	} else if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// TestProgressPost posts this week's message now, so the family can check the
+// conversation and template. It doesn't count as the week's post.
+func (h *Handler) TestProgressPost(w http.ResponseWriter, r *http.Request) {
+	pregnancy := h.getProgressPostPregnancy(w, r)
+	if pregnancy == nil {
+		return
+	}
+	if h.chat == nil {
+		writeError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "mvchat2 posting is not configured")
+		return
+	}
+
+	post, err := h.db.GetProgressPost(r.Context(), pregnancy.ID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Progress posts are not set up")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	progress := weekProgress(pregnancy, time.Now())
+	if progress == nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Set a due date or start date first")
+		return
+	}
+
+	text, err := renderProgressPost(post, pregnancy, progress)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if err := h.chat.Post(r.Context(), post.ConversationID, text); err != nil {
+		writeError(w, http.StatusBadGateway, "SERVICE_UNAVAILABLE", fmt.Sprintf("Posting to mvchat2 failed: %v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.ProgressPostPreview{Week: progress.Week, Text: text})
+}
+
+// RunProgressPosts posts each opted-in pregnancy's message once when a new
+// gestational week starts. Failed posts are retried at the next check. Nothing
+// is posted while sharing is snoozed. It never returns; start it in a goroutine.
+func (h *Handler) RunProgressPosts() {
+	for {
+		ctx := context.Background()
+		posts, err := h.db.GetEnabledProgressPosts(ctx)
+		if err != nil {
+			log.Printf("Progress posts: %v", err)
+		}
+		for i := range posts {
+			h.sendProgressPost(ctx, &posts[i], time.Now())
+		}
+		time.Sleep(progressPostInterval)
+	}
+}
+
+// sendProgressPost posts the current week's message if it is due.
+func (h *Handler) sendProgressPost(ctx context.Context, post *models.ProgressPost, now time.Time) {
+	pregnancy, err := h.db.GetPregnancyByID(ctx, post.PregnancyID)
+	if err != nil {
+		log.Printf("Progress posts: pregnancy %d: %v", post.PregnancyID, err)
+		return
+	}
+	progress := weekProgress(pregnancy, now)
+	if progress == nil || progress.Week < progressPostFirstWeek || progress.Week > progressPostLastWeek {
+		return
+	}
+	if post.LastWeekPosted.Valid && int(post.LastWeekPosted.Int64) >= progress.Week {
+		return
+	}
+	if pregnancy.SharingSnoozedUntil.Valid && pregnancy.SharingSnoozedUntil.Time.After(now) {
+		return
+	}
+
+	text, err := renderProgressPost(post, pregnancy, progress)
+	if err != nil {
+		log.Printf("Progress posts: pregnancy %d: %v", post.PregnancyID, err)
+		return
+	}
+	claimed, err := h.db.ClaimProgressPostWeek(ctx, post.PregnancyID, progress.Week)
+	if err != nil || !claimed {
+		if err != nil {
+			log.Printf("Progress posts: pregnancy %d: %v", post.PregnancyID, err)
+		}
+		return
+	}
+
+	if err := h.chat.Post(ctx, post.ConversationID, text); err != nil {
+		log.Printf("Progress posts: pregnancy %d week %d: %v", post.PregnancyID, progress.Week, err)
+		if err := h.db.FailProgressPostWeek(ctx, post.PregnancyID, progress.Week, post.LastWeekPosted, err.Error()); err != nil {
+			log.Printf("Progress posts: pregnancy %d: %v", post.PregnancyID, err)
+		}
+		return
+	}
+	if err := h.db.CompleteProgressPostWeek(ctx, post.PregnancyID); err != nil {
+		log.Printf("Progress posts: pregnancy %d: %v", post.PregnancyID, err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package api provides per-user request budgets, rate limit headers and the
+// cleanup of expired rate limit windows.
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/ratelimit"
+)
+
+// rateBudget is a sliding-window request allowance shared by the routes that
+// name it in the registry.
+type rateBudget struct {
+	name   string
+	limit  int
+	window time.Duration
+}
+
+// rateBudgets are the named budgets; routes without one use defaultBudget.
+var rateBudgets = []rateBudget{
+	{name: "sync", limit: 120, window: time.Minute},
+	{name: "backfill", limit: 120, window: time.Minute},
+	{name: "uploads", limit: 60, window: time.Hour},
+	{name: "exports", limit: 10, window: time.Hour},
+	{name: "invites", limit: 5, window: time.Hour},
+	{name: "invite_batches", limit: 5, window: time.Hour},
+	{name: "captions", limit: 30, window: time.Hour},
+}
+
+var defaultBudget = rateBudget{name: "default", limit: 600, window: time.Minute}
+
+// codeAttemptLimit caps failed invite code previews and redemptions per user.
+// Unlike the budgets it is enforced.
+var codeAttemptLimit = ratelimit.Limit{Count: 5, Window: time.Hour}
+
+// rateLimit returns the budget as a limit.
+func (b *rateBudget) rateLimit() ratelimit.Limit {
+	return ratelimit.Limit{Count: b.limit, Window: b.window}
+}
+
+// budgetKey is the limiter key of a user's budget.
+func budgetKey(userID string, b *rateBudget) string {
+	return "budget:" + b.name + ":" + userID
+}
+
+// setRateLimitHeaders reports a budget's window in X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset.
+func setRateLimitHeaders(w http.ResponseWriter, b *rateBudget, res ratelimit.Result) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(b.limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(res.Reset.Unix(), 10))
+}
+
+// codeAttemptKey is the limiter key of a user's failed code attempts.
+func codeAttemptKey(userID string) string {
+	return "code_attempts:" + userID
+}
+
+// recordFailedCodeAttempt counts a failed preview or redemption against the
+// user's limit.
+func (h *Handler) recordFailedCodeAttempt(ctx context.Context, userID string) {
+	if _, err := h.limiter.Hit(ctx, codeAttemptKey(userID), codeAttemptLimit); err != nil {
+		log.Printf("Failed to record code attempt: %v", err)
+	}
+}
+
+// routeBudget returns the budget covering the matched route.
+func routeBudget(r *http.Request) *rateBudget {
+	rt := currentRoute(r)
+	if rt == nil || rt.Budget == "" {
+		return &defaultBudget
+	}
+	for i := range rateBudgets {
+		if rateBudgets[i].name == rt.Budget {
+			return &rateBudgets[i]
+		}
+	}
+	return &defaultBudget
+}
+
+// RateLimitMiddleware counts each request against the caller's budget for the route
+// and reports it in X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset.
+// Limits are soft: requests over budget are still served, and so are requests
+// the limiter's store can't count. It must run after AuthMiddleware.
+func (h *Handler) RateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserInfo(r)
+		budget := routeBudget(r)
+		res, err := h.limiter.Hit(r.Context(), budgetKey(user.UserID, budget), budget.rateLimit())
+		if err != nil {
+			log.Printf("Rate limit: %v", err)
+		} else {
+			setRateLimitHeaders(w, budget, res)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetLimits lists the request budgets and the caller's remaining allowance in each.
+func (h *Handler) GetLimits(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	all := append([]rateBudget{}, rateBudgets...)
+	all = append(all, defaultBudget)
+
+	budgets := make([]models.RateLimitBudget, 0, len(all))
+	for i := range all {
+		b := &all[i]
+		res, err := h.limiter.Peek(ctx, budgetKey(user.UserID, b), b.rateLimit())
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		var budgetRoutes []string
+		for j := range routes {
+			if routes[j].Budget == b.name {
+				budgetRoutes = append(budgetRoutes, routes[j].Key())
+			}
+		}
+		budgets = append(budgets, models.RateLimitBudget{
+			Name:          b.name,
+			Limit:         b.limit,
+			WindowSeconds: int(b.window / time.Second),
+			Routes:        budgetRoutes,
+			Remaining:     res.Remaining,
+			Reset:         res.Reset.Unix(),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, models.LimitsResponse{Budgets: budgets})
+}
+
+// RunRateLimitCleanup deletes expired rate limit windows from the database
+// every ten minutes. Nothing is written there unless Postgres is the store.
+func (h *Handler) RunRateLimitCleanup() {
+	for {
+		if _, err := h.db.DeleteExpiredRateLimits(context.Background()); err != nil {
+			log.Printf("Rate limit cleanup: %v", err)
+		}
+		time.Sleep(10 * time.Minute)
+	}
+}
@@ -0,0 +1,40 @@
+// Package api provides data residency checks and server diagnostics.
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// crossRegion reports whether data held in dataRegion would leave it when served
+// from serverRegion. Default-region data and servers without a region never are.
+func crossRegion(dataRegion, serverRegion string) bool {
+	return dataRegion != "" && serverRegion != "" && dataRegion != serverRegion
+}
+
+// GetDiagnostics reports the server's region, its storage regions, how much
+// data each region holds and the database circuit breaker.
+func (h *Handler) GetDiagnostics(w http.ResponseWriter, r *http.Request) {
+	regions, err := h.db.GetRegionCounts(r.Context())
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if regions == nil {
+		regions = []models.RegionDiagnostics{}
+	}
+	for i := range regions {
+		regions[i].Configured = h.storage.Has(regions[i].Region)
+	}
+
+	writeJSON(w, http.StatusOK, models.DiagnosticsResponse{
+		ServerRegion:   h.serverRegion,
+		StorageRegions: h.storage.Codes(),
+		Regions:        regions,
+		ServerTime:     time.Now().UTC().Format(time.RFC3339),
+		Database:       h.db.BreakerStatus(),
+		Backpressure:   h.shedder.status(h.db.Load()),
+	})
+}
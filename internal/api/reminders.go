@@ -0,0 +1,261 @@
+// Package api provides gap reminders: gentle nudges when an entry type a user
+// tracks hasn't been logged for their chosen cadence.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+const (
+	// reminderInterval is how often the runner looks for gaps.
+	reminderInterval = time.Hour
+	// reminderBatch caps the nudges sent per run.
+	reminderBatch = 500
+	// Cadences and snoozes are whole days in this range.
+	minReminderDays = 1
+	maxReminderDays = 90
+)
+
+// getReminderPregnancy loads the pregnancy the user may write to. It writes
+// the error response and returns nil otherwise.
+func (h *Handler) getReminderPregnancy(w http.ResponseWriter, r *http.Request) *models.Pregnancy {
+	user := getUserInfo(r)
+	pregnancy, permission, err := h.getAccessiblePregnancy(r.Context(), user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return nil
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return nil
+	}
+	if permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Reminders need write permission")
+		return nil
+	}
+	return pregnancy
+}
+
+// reminderEntryType reads the {entryType} route variable. It writes the error
+// response and returns "" for types the apps don't write.
+func reminderEntryType(w http.ResponseWriter, r *http.Request) string {
+	entryType := mux.Vars(r)["entryType"]
+	if !slices.Contains(knownEntryTypes, entryType) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Unknown entry type")
+		return ""
+	}
+	return entryType
+}
+
+// GetEntryReminders lists the user's reminders with how long each type has
+// gone without an entry.
+func (h *Handler) GetEntryReminders(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	pregnancy := h.getReminderPregnancy(w, r)
+	if pregnancy == nil {
+		return
+	}
+
+	reminders, err := h.db.GetEntryReminders(r.Context(), pregnancy.ID, user.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	now := time.Now()
+	resp := models.EntryRemindersResponse{
+		Reminders:  make([]models.EntryReminderDTO, 0, len(reminders)),
+		Suppressed: pregnancy.Outcome.Valid || pregnancy.Archived,
+	}
+	for i := range reminders {
+		resp.Reminders = append(resp.Reminders, toEntryReminderDTO(&reminders[i], now))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// toEntryReminderDTO reports a reminder and its gap at now.
+func toEntryReminderDTO(rem *models.EntryReminder, now time.Time) models.EntryReminderDTO {
+	dto := models.EntryReminderDTO{EntryType: rem.EntryType, CadenceDays: rem.CadenceDays}
+	gapStart := rem.UpdatedAt
+	if rem.LastEntryAt.Valid {
+		s := rem.LastEntryAt.Time.Format(time.RFC3339)
+		days := int(now.Sub(rem.LastEntryAt.Time).Hours() / 24)
+		dto.LastEntryAt, dto.DaysSince = &s, &days
+		if rem.LastEntryAt.Time.After(gapStart) {
+			gapStart = rem.LastEntryAt.Time
+		}
+	}
+	dto.Due = !now.Before(gapStart.AddDate(0, 0, rem.CadenceDays))
+	if rem.SnoozedUntil.Valid && rem.SnoozedUntil.Time.After(now) {
+		s := rem.SnoozedUntil.Time.Format(time.RFC3339)
+		dto.SnoozedUntil = &s
+	}
+	if rem.LastNudgedAt.Valid {
+		s := rem.LastNudgedAt.Time.Format(time.RFC3339)
+		dto.LastNudgedAt = &s
+	}
+	return dto
+}
+
+// SetEntryReminder creates or changes the user's reminder for an entry type.
+func (h *Handler) SetEntryReminder(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	pregnancy := h.getReminderPregnancy(w, r)
+	if pregnancy == nil {
+		return
+	}
+	entryType := reminderEntryType(w, r)
+	if entryType == "" {
+		return
+	}
+
+	var req models.EntryReminderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if req.CadenceDays < minReminderDays || req.CadenceDays > maxReminderDays {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "cadenceDays must be between 1 and 90")
+		return
+	}
+
+	if err := h.db.SetEntryReminder(r.Context(), pregnancy.ID, user.UserID, entryType, req.CadenceDays); err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	h.GetEntryReminders(w, r)
+}
+
+// DeleteEntryReminder stops the user's reminder for an entry type.
+func (h *Handler) DeleteEntryReminder(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	pregnancy := h.getReminderPregnancy(w, r)
+	if pregnancy == nil {
+		return
+	}
+	entryType := reminderEntryType(w, r)
+	if entryType == "" {
+		return
+	}
+
+	err := h.db.DeleteEntryReminder(r.Context(), pregnancy.ID, user.UserID, entryType)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Reminder not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SnoozeEntryReminder pauses the user's reminder for an entry type for a
+// number of days.
+func (h *Handler) SnoozeEntryReminder(w http.ResponseWriter, r *http.Request) {
+	var req models.EntryReminderSnoozeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if req.Days < minReminderDays || req.Days > maxReminderDays {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "days must be between 1 and 90")
+		return
+	}
+	until := time.Now().AddDate(0, 0, req.Days)
+	h.snoozeEntryReminder(w, r, &until)
+}
+
+// LiftEntryReminderSnooze ends a reminder's snooze early.
+func (h *Handler) LiftEntryReminderSnooze(w http.ResponseWriter, r *http.Request) {
+	h.snoozeEntryReminder(w, r, nil)
+}
+
+func (h *Handler) snoozeEntryReminder(w http.ResponseWriter, r *http.Request, until *time.Time) {
+	user := getUserInfo(r)
+	pregnancy := h.getReminderPregnancy(w, r)
+	if pregnancy == nil {
+		return
+	}
+	entryType := reminderEntryType(w, r)
+	if entryType == "" {
+		return
+	}
+
+	err := h.db.SnoozeEntryReminder(r.Context(), pregnancy.ID, user.UserID, entryType, until)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Reminder not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	h.GetEntryReminders(w, r)
+}
+
+// RunEntryReminders nudges users about entry types that have gone a cadence
+// without an entry, once per cadence while the gap lasts. Pregnancies with an
+// outcome or archived get none, and reminders of users who lost write access
+// are deleted.
+func (h *Handler) RunEntryReminders() {
+	for {
+		ctx := context.Background()
+		reminders, err := h.db.GetDueEntryReminders(ctx, reminderBatch)
+		if err != nil {
+			log.Printf("Entry reminders: %v", err)
+		}
+		for i := range reminders {
+			h.sendEntryReminder(ctx, &reminders[i], time.Now())
+		}
+		time.Sleep(reminderInterval)
+	}
+}
+
+// sendEntryReminder writes the nudge notification for a due reminder.
+func (h *Handler) sendEntryReminder(ctx context.Context, rem *models.EntryReminder, now time.Time) {
+	access, err := h.resolveAccess(ctx, rem.UserID)
+	if err != nil && err != db.ErrNotFound {
+		log.Printf("Entry reminders: reminder %d: %v", rem.ID, err)
+		return
+	}
+	if err == db.ErrNotFound || access.pregnancy.ID != rem.PregnancyID || access.permission != "write" {
+		if err := h.db.DeleteEntryReminderByID(ctx, rem.ID); err != nil {
+			log.Printf("Entry reminders: reminder %d: %v", rem.ID, err)
+		}
+		return
+	}
+
+	claimed, err := h.db.ClaimEntryReminderNudge(ctx, rem.ID, rem.LastNudgedAt)
+	if err != nil || !claimed {
+		if err != nil {
+			log.Printf("Entry reminders: reminder %d: %v", rem.ID, err)
+		}
+		return
+	}
+
+	payload := map[string]interface{}{
+		"entryType": rem.EntryType,
+		"label":     strings.ReplaceAll(rem.EntryType, "_", " "),
+	}
+	if rem.LastEntryAt.Valid {
+		payload["days"] = int(now.Sub(rem.LastEntryAt.Time).Hours() / 24)
+		payload["lastEntryAt"] = rem.LastEntryAt.Time.Format(time.RFC3339)
+	} else {
+		payload["days"] = int(now.Sub(rem.UpdatedAt).Hours() / 24)
+	}
+	body, _ := json.Marshal(payload)
+	if err := h.db.CreateNotification(ctx, rem.UserID, rem.PregnancyID, "entry_gap", body); err != nil {
+		log.Printf("Entry reminders: reminder %d: %v", rem.ID, err)
+	}
+}
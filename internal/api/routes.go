@@ -0,0 +1,442 @@
+// Package api provides the route registry: every authenticated /api route with
+// the policies that apply to it. RegisterRoutes installs the routes, and the
+// OpenAPI document and capabilities are derived from the same table.
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/auth"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// Route access beyond a signed-in user.
+const (
+	// AccessAdmin limits a route to ADMIN_USER_IDS signed in with a session;
+	// an admin's personal access tokens don't carry the privilege.
+	AccessAdmin = "admin"
+	// AccessSession refuses personal access tokens, so a leaked token cannot
+	// be used to mint or revoke other tokens or to delete a user's data.
+	AccessSession = "session"
+)
+
+// analyticsTimeout bounds the analytics queries, which scan a whole pregnancy.
+const analyticsTimeout = 10 * time.Second
+
+// Deprecation marks a route as deprecated in favour of Successor. When it was
+// deprecated and its removal date are configured (LEGACY_DEPRECATED, LEGACY_SUNSET).
+type Deprecation struct {
+	Successor string
+}
+
+// Route is one authenticated /api endpoint and its policies.
+type Route struct {
+	Method  string
+	Path    string // Template under /api, e.g. "/entries/{clientId}"
+	Handle  func(*Handler, http.ResponseWriter, *http.Request)
+	Summary string
+
+	Access     string        // "", AccessAdmin or AccessSession
+	Scope      string        // Token scope required; default read for GET/HEAD, write otherwise
+	Budget     string        // Rate limit budget; "" for the default budget
+	Heavy      bool          // Shares the per-user concurrency cap with background jobs
+	Idempotent bool          // Honors the Idempotency-Key header
+	Timeout    time.Duration // Cancels the request context after this long; 0 for none
+	Cache      string        // Cache-Control policy of successful responses; default CacheNoStore
+	Gzip       bool          // Accepts gzipped bodies and gzips responses when the client accepts it
+	Protocol   bool          // Negotiates X-Sync-Protocol and refuses clients below the minimum
+	Surface    string        // Optional surface (Surface*) the route belongs to; not installed when disabled
+	Deprecated *Deprecation
+}
+
+// cacheControl returns the route's Cache-Control policy.
+func (rt *Route) cacheControl() string {
+	if rt.Cache != "" {
+		return rt.Cache
+	}
+	return CacheNoStore
+}
+
+// Key returns the route as "METHOD /api/path-template".
+func (rt *Route) Key() string {
+	return rt.Method + " /api" + rt.Path
+}
+
+// scope returns the token scope the route requires.
+func (rt *Route) scope() string {
+	if rt.Scope != "" {
+		return rt.Scope
+	}
+	if rt.Method == http.MethodGet || rt.Method == http.MethodHead {
+		return models.TokenScopeRead
+	}
+	return models.TokenScopeWrite
+}
+
+// routes lists every authenticated /api route in registration order.
+var routes []Route
+
+// routeIndex finds a registry entry by Key.
+var routeIndex map[string]*Route
+
+// The registry is filled here rather than in its declaration because the
+// handlers that report on it (limits, deprecations, capabilities) would
+// otherwise form an initialization cycle.
+func init() {
+	routes = []Route{
+
+		// Request budgets
+		{Method: "GET", Path: "/limits", Handle: (*Handler).GetLimits, Summary: "Request budgets with the caller's remaining count and reset time"},
+
+		// Admin reports
+		{Method: "GET", Path: "/admin/deprecations", Handle: (*Handler).GetDeprecationReport, Access: AccessAdmin, Summary: "Deprecated routes and hits/users per app version"},
+		{Method: "GET", Path: "/admin/diagnostics", Handle: (*Handler).GetDiagnostics, Access: AccessAdmin, Summary: "Server region, storage regions, pregnancies/files per region, database breaker, sync backpressure"},
+		{Method: "GET", Path: "/admin/slo", Handle: (*Handler).GetSLO, Access: AccessAdmin, Summary: "Success rate, error budget and p50/p95/p99 latency per route (query: window, target)"},
+		{Method: "GET", Path: "/admin/notifications/templates", Handle: (*Handler).GetNotificationTemplates, Access: AccessAdmin, Summary: "Push/email copy of every notification kind with a sample payload"},
+		{Method: "POST", Path: "/admin/notifications/preview", Handle: (*Handler).PreviewNotification, Access: AccessAdmin, Summary: "Render a template ({\"kind\", \"payload\", \"notificationId\", \"send\"})"},
+		{Method: "POST", Path: "/admin/impersonations", Handle: (*Handler).StartImpersonation, Access: AccessAdmin, Summary: "Act as a user for debugging ({\"userId\", \"reason\", \"scope\", \"minutes\"}), returns a t2i_ token once"},
+		{Method: "GET", Path: "/admin/impersonations", Handle: (*Handler).GetImpersonations, Access: AccessAdmin, Summary: "Impersonation sessions of all admins with request counts, always paginated"},
+		{Method: "GET", Path: "/admin/impersonations/{impersonationId}/requests", Handle: (*Handler).GetImpersonatedRequests, Access: AccessAdmin, Summary: "Requests made in a session with their status, always paginated"},
+		{Method: "DELETE", Path: "/admin/impersonations/{impersonationId}", Handle: (*Handler).EndImpersonation, Access: AccessAdmin, Summary: "End a session early; the owner is notified"},
+		{Method: "GET", Path: "/admin/data-versions", Handle: (*Handler).GetDataVersionReport, Access: AccessAdmin, Summary: "Current entry payload versions and unknown versions clients sent"},
+		{Method: "GET", Path: "/admin/entry-filters", Handle: (*Handler).GetEntryFilterReport, Access: AccessAdmin, Heavy: true, Summary: "EXPLAIN ANALYZE timings of each entry filter with and without its index"},
+		{Method: "GET", Path: "/admin/content/{kind}", Handle: (*Handler).GetContentVersions, Access: AccessAdmin, Summary: "Versions of weekly-facts or baby-sizes (query: week, status)"},
+		{Method: "POST", Path: "/admin/content/{kind}", Handle: (*Handler).CreateContentDraft, Access: AccessAdmin, Summary: "New draft (week, data, accessibility, simplified, simplifiedAccessibility, sources, reviewedBy, reviewedAt), numbered as the week's next version"},
+		// Before {version}, which would otherwise take "community"
+		{Method: "GET", Path: "/admin/content/{kind}/{week}/community", Handle: (*Handler).GetCommunityTopics, Access: AccessAdmin, Summary: "Community topics linked to a week of weekly-facts, with links"},
+		{Method: "PUT", Path: "/admin/content/{kind}/{week}/community", Handle: (*Handler).PutCommunityTopics, Access: AccessAdmin, Summary: "Replace a week's community topics ({\"topics\": [{\"service\", \"topicId\", \"title\"}]})"},
+		{Method: "PUT", Path: "/admin/content/{kind}/{week}/{version}", Handle: (*Handler).UpdateContentDraft, Access: AccessAdmin, Summary: "Edit a draft (same fields except week)"},
+		{Method: "DELETE", Path: "/admin/content/{kind}/{week}/{version}", Handle: (*Handler).DeleteContentDraft, Access: AccessAdmin, Summary: "Delete a draft"},
+		{Method: "POST", Path: "/admin/content/{kind}/{week}/{version}/publish", Handle: (*Handler).PublishContent, Access: AccessAdmin, Summary: "Make a version live, retiring the previous one"},
+		{Method: "POST", Path: "/admin/content/{kind}/{week}/{version}/review", Handle: (*Handler).ReviewContent, Access: AccessAdmin, Summary: "Record a medical review (reviewedBy, reviewedAt, sources) of any version"},
+		{Method: "GET", Path: "/admin/content/{kind}/stale", Handle: (*Handler).GetStaleContent, Access: AccessAdmin, Summary: "Published versions never reviewed or reviewed over days ago (default 365)"},
+		{Method: "POST", Path: "/admin/content/{kind}/{week}/unpublish", Handle: (*Handler).UnpublishContent, Access: AccessAdmin, Summary: "Remove a week from the public data"},
+		{Method: "GET", Path: "/admin/content/{kind}/{week}/variants", Handle: (*Handler).GetContentVariants, Access: AccessAdmin, Summary: "The week's variants with exposure users / views"},
+		{Method: "POST", Path: "/admin/content/{kind}/{week}/variants", Handle: (*Handler).CreateContentVariant, Access: AccessAdmin, Summary: "Add a variant (name, weight, data, startsAt, endsAt)"},
+		{Method: "PUT", Path: "/admin/content/{kind}/{week}/variants/{variantId}", Handle: (*Handler).UpdateContentVariant, Access: AccessAdmin, Summary: "Replace a variant"},
+		{Method: "DELETE", Path: "/admin/content/{kind}/{week}/variants/{variantId}", Handle: (*Handler).DeleteContentVariant, Access: AccessAdmin, Summary: "Delete a variant and its exposures"},
+		{Method: "GET", Path: "/content/{kind}/{week}", Handle: (*Handler).GetContentItem, Cache: CacheRevalidate, Summary: "The week's published content with the caller's variant applied (week, variant, data; query: simplified=true)"},
+
+		// Pregnancy endpoints (legacy - single pregnancy; GET and PUT are deprecated,
+		// POST stays until /api/pregnancies can create pregnancies)
+		{Method: "GET", Path: "/pregnancy", Handle: (*Handler).GetPregnancy, Deprecated: &Deprecation{Successor: "/api/pregnancies"}, Summary: "Get user's pregnancy (legacy, deprecated: use /api/pregnancies)"},
+		{Method: "POST", Path: "/pregnancy", Handle: (*Handler).CreatePregnancy, Summary: "Create new pregnancy"},
+		{Method: "PUT", Path: "/pregnancy", Handle: (*Handler).UpdatePregnancy, Deprecated: &Deprecation{Successor: "/api/pregnancies/{id}"}, Summary: "Update pregnancy (deprecated: use /api/pregnancies/{id})"},
+
+		// Multi-pregnancy endpoints
+		{Method: "GET", Path: "/pregnancies", Handle: (*Handler).ListPregnancies, Summary: "List all accessible pregnancies"},
+		{Method: "GET", Path: "/pregnancies/{id}", Handle: (*Handler).GetPregnancyByID, Summary: "Get pregnancy by ID"},
+		{Method: "PUT", Path: "/pregnancies/{id}", Handle: (*Handler).UpdatePregnancyByID, Summary: "Update pregnancy by ID"},
+		{Method: "GET", Path: "/pregnancies/{id}/entries", Handle: (*Handler).GetPregnancyEntries, Gzip: true, Summary: "Get all entries for pregnancy"},
+		{Method: "PUT", Path: "/pregnancies/{id}/outcome", Handle: (*Handler).SetPregnancyOutcome, Summary: "Set pregnancy outcome (birth returns a followUp until birth details exist)"},
+		{Method: "GET", Path: "/pregnancies/{id}/birth-details", Handle: (*Handler).GetBirthDetails, Summary: "Recorded birth details"},
+		{Method: "POST", Path: "/pregnancies/{id}/birth-details", Handle: (*Handler).SaveBirthDetails, Summary: "Record birth details (owner/coowner, outcome birth)"},
+		{Method: "PUT", Path: "/pregnancies/{id}/archive", Handle: (*Handler).SetPregnancyArchive, Summary: "Archive/unarchive pregnancy"},
+		{Method: "DELETE", Path: "/pregnancies/{id}", Handle: (*Handler).DeletePregnancy, Access: AccessSession, Summary: "Owner: delete the pregnancy and all its data (dryRun=true previews)"},
+		{Method: "GET", Path: "/pregnancies/{id}/timeline-export", Handle: (*Handler).GetTimelineExport, Budget: "exports", Heavy: true, Summary: "Owner: hash-chained, signed JSON lines of every entry revision"},
+		{Method: "POST", Path: "/pregnancies/{id}/restore-files", Handle: (*Handler).RestorePregnancyFiles, Summary: "Start moving cold files back to hot storage, returns 202 + jobId"},
+		{Method: "GET", Path: "/pregnancies/{id}/restore-files/{jobId}", Handle: (*Handler).GetRestoreFilesJob, Summary: "Poll restore status / progress / queuePosition; restored and failed once completed"},
+		{Method: "GET", Path: "/pregnancies/{id}/coowner", Handle: (*Handler).GetCoownerStatus, Summary: "Coowner status, change history and recent coowner actions (owner/coowner)"},
+		{Method: "DELETE", Path: "/pregnancies/{id}/coowner", Handle: (*Handler).RemoveCoowner, Summary: "Remove the coowner (owner) or leave (coowner)"},
+		{Method: "GET", Path: "/pregnancies/{id}/coowner/actions", Handle: (*Handler).GetCoownerActions, Summary: "Page through all coowner actions (owner/coowner; query: limit, cursor)"},
+		{Method: "GET", Path: "/pregnancies/{id}/progress-posts", Handle: (*Handler).GetProgressPost, Summary: "Weekly mvchat2 progress post settings (owner/coowner)"},
+		{Method: "PUT", Path: "/pregnancies/{id}/progress-posts", Handle: (*Handler).UpdateProgressPost, Summary: "Opt in or update (conversationId, enabled, template, weekTemplates)"},
+		{Method: "DELETE", Path: "/pregnancies/{id}/progress-posts", Handle: (*Handler).DeleteProgressPost, Summary: "Opt out"},
+		{Method: "POST", Path: "/pregnancies/{id}/progress-posts/test", Handle: (*Handler).TestProgressPost, Summary: "Post this week's message now"},
+
+		// Demo pregnancy (generated data, excluded from stats)
+		{Method: "POST", Path: "/demo/start", Handle: (*Handler).StartDemo, Summary: "Create a demo pregnancy at week (4-41, default 24) with generated data"},
+		{Method: "DELETE", Path: "/demo", Handle: (*Handler).StopDemo, Summary: "Delete the demo pregnancy and all its data"},
+
+		// Entry endpoints
+		{Method: "GET", Path: "/entries", Handle: (*Handler).GetEntries, Gzip: true, Summary: "Get entries (query: type, since, occurredSince, includeDeleted, upcoming, filter, limit, cursor)"},
+		{Method: "POST", Path: "/entries", Handle: (*Handler).CreateEntry, Idempotent: true, Gzip: true, Summary: "Create single entry"},
+		{Method: "POST", Path: "/entries/batch", Handle: (*Handler).BatchCreateEntries, Idempotent: true, Gzip: true, Summary: "Create multiple entries with per-item results (body: entries, continueOnError)"},
+		{Method: "POST", Path: "/entries/backfill", Handle: (*Handler).BackfillEntries, Budget: "backfill", Gzip: true, Summary: "Import up to 1000 past-dated entries (each with createdAt), returns a summary"},
+		{Method: "POST", Path: "/migrate/v1", Handle: (*Handler).MigrateV1, Budget: "backfill", Heavy: true, Gzip: true, Summary: "Migrate from the v1 tracker (body: export or token), returns per-record results"},
+		{Method: "POST", Path: "/entries/visibility", Handle: (*Handler).SetEntriesVisibility, Summary: "Owner: change visibility of entries by clientIds or entryType"},
+		{Method: "GET", Path: "/entries/duplicates", Handle: (*Handler).GetDuplicateEntries, Summary: "List suspected duplicate entries (query: type)"},
+		{Method: "POST", Path: "/entries/duplicates/merge", Handle: (*Handler).MergeDuplicateEntries, Summary: "Keep one entry, soft delete its duplicates"},
+		{Method: "GET", Path: "/entries/scheduled/due", Handle: (*Handler).GetDueScheduledEntries, Summary: "Planned entries whose date has passed (prompt completed/missed)"},
+		{Method: "GET", Path: "/entries/{clientId}", Handle: (*Handler).GetEntry, Summary: "Get one entry by clientId, including deletedAt (query: type when the clientId is used by several types)"},
+		{Method: "PUT", Path: "/entries/{clientId}/status", Handle: (*Handler).SetEntryStatus, Summary: "Set scheduled entry status (planned/completed/missed)"},
+		{Method: "DELETE", Path: "/entries/{clientId}", Handle: (*Handler).DeleteEntry, Summary: "Soft delete entry"},
+		{Method: "POST", Path: "/entries/delete", Handle: (*Handler).DeleteEntries, Summary: "Soft delete entries by clientIds or entryType (dryRun=true previews)"},
+
+		// Security events
+		{Method: "GET", Path: "/security/events", Handle: (*Handler).GetSecurityEvents, Summary: "Owner: recent new-device/new-country access and blocked pairing request events, and requireRepair (query: limit, cursor)"},
+		{Method: "PUT", Path: "/security/settings", Handle: (*Handler).UpdateSecuritySettings, Summary: "Owner: {\"requireRepair\": true} unpairs non-owners seen on a new device"},
+
+		// Analytics
+		{Method: "GET", Path: "/analytics/aggregate", Handle: (*Handler).GetAggregate, Timeout: analyticsTimeout, Summary: "SQL-side buckets (query: type, groupBy, field, tz)"},
+		{Method: "GET", Path: "/analytics/benchmarks", Handle: (*Handler).GetBenchmarks, Timeout: analyticsTimeout, Summary: "Cross-user weekly benchmark (query: metric = weight, systolic, diastolic, glucose, water)"},
+		{Method: "GET", Path: "/analytics/contractions", Handle: (*Handler).GetContractionAnalytics, Timeout: analyticsTimeout, Summary: "Rolling contraction averages and 5-1-1 rule check (query: windowMinutes, rule)"},
+		{Method: "GET", Path: "/analytics/kicks", Handle: (*Handler).GetKickAnalytics, Timeout: analyticsTimeout, Summary: "Kick sessions, kicks per hour, time to ten and flagged days (query: from, to, tz)"},
+		{Method: "GET", Path: "/analytics/weight", Handle: (*Handler).GetWeightAnalytics, Timeout: analyticsTimeout, Summary: "Weekly weight, trend and total gain (query: unit = kg or lb, prePregnancyWeight)"},
+		{Method: "GET", Path: "/analytics/nutrition", Handle: (*Handler).GetNutritionTotals, Timeout: analyticsTimeout, Summary: "Rough nutrient totals of meals per day (query: from, to, tz)"},
+
+		// Food lookups for meal entries (only with NUTRITION_API_URL)
+		{Method: "GET", Path: "/nutrition/search", Handle: (*Handler).SearchFoods, Summary: "Search the food database (query: q 2-100 characters, limit default 20, max 50)"},
+
+		// Saved dashboards (shared read-only with partner/supporters)
+		{Method: "GET", Path: "/dashboards", Handle: (*Handler).GetDashboards, Summary: "List dashboards (canEdit is false for partner/supporters)"},
+		{Method: "POST", Path: "/dashboards", Handle: (*Handler).CreateDashboard, Summary: "Create (name, position, widgets) - owner/coowner only"},
+		{Method: "GET", Path: "/dashboards/{dashboardId}", Handle: (*Handler).GetDashboard, Summary: "Get dashboard"},
+		{Method: "PUT", Path: "/dashboards/{dashboardId}", Handle: (*Handler).UpdateDashboard, Summary: "Replace name, widgets, layout - owner/coowner only"},
+		{Method: "DELETE", Path: "/dashboards/{dashboardId}", Handle: (*Handler).DeleteDashboard, Summary: "Delete - owner/coowner only"},
+
+		// Memory book (background job)
+		{Method: "POST", Path: "/memory-book", Handle: (*Handler).CreateMemoryBook, Budget: "exports", Summary: "Start compiling (title, entryClientIds, includeWeeklyFacts, formats: [\"pdf\",\"epub\"]), returns 202 + jobId"},
+		{Method: "GET", Path: "/memory-book/{jobId}", Handle: (*Handler).GetMemoryBook, Summary: "Poll status / progress / queuePosition; includes the book JSON once completed"},
+		{Method: "GET", Path: "/memory-book/{jobId}/download", Handle: (*Handler).DownloadMemoryBook, Summary: "Download (query: format = pdf, epub or json)"},
+
+		// Vitals device imports
+		{Method: "POST", Path: "/vitals/import", Handle: (*Handler).ImportVitals, Budget: "uploads", Heavy: true, Summary: "Import BP/glucose device CSV (form: file, vendor, deviceSerial, timezone)"},
+
+		// Calendar feed (scheduled entries)
+		{Method: "GET", Path: "/calendar.ics", Handle: (*Handler).GetCalendarFeed, Summary: "iCalendar feed of scheduled entries"},
+		{Method: "GET", Path: "/me/calendar-feeds", Handle: (*Handler).GetCalendarFeeds, Access: AccessSession, Surface: SurfaceCalendarFeeds, Summary: "List unrevoked calendar feed URLs (never the token)"},
+		{Method: "POST", Path: "/me/calendar-feeds", Handle: (*Handler).CreateCalendarFeed, Access: AccessSession, Surface: SurfaceCalendarFeeds, Summary: "Issue a calendar feed URL for calendar apps ({\"name\"})"},
+		{Method: "DELETE", Path: "/me/calendar-feeds/{feedId}", Handle: (*Handler).RevokeCalendarFeed, Access: AccessSession, Surface: SurfaceCalendarFeeds, Summary: "Revoke a calendar feed URL immediately"},
+
+		// Settings endpoints
+		{Method: "GET", Path: "/settings", Handle: (*Handler).GetSettings, Summary: "Get all settings"},
+		{Method: "PUT", Path: "/settings/{type}", Handle: (*Handler).UpdateSetting, Summary: "Update setting"},
+		{Method: "DELETE", Path: "/settings/{type}", Handle: (*Handler).DeleteSetting, Summary: "Soft-delete setting (history kept)"},
+		{Method: "GET", Path: "/settings/{type}/history", Handle: (*Handler).GetSettingHistory, Summary: "Last 50 revisions of a setting, newest first"},
+		{Method: "POST", Path: "/settings/{type}/revert", Handle: (*Handler).RevertSetting, Summary: "Restore a revision as a new version ({\"revisionId\": N})"},
+
+		// Birth plan (owner, coowner and partner; co-edited with section locks)
+		{Method: "GET", Path: "/birth-plan", Handle: (*Handler).GetBirthPlan, Summary: "Every section with content, version and live lock (lockedBy, lockExpiresAt)"},
+		{Method: "PATCH", Path: "/birth-plan", Handle: (*Handler).PatchBirthPlan, Summary: "Save sections: {\"sections\": {\"<section>\": {\"content\", \"baseVersion\"}}}"},
+		{Method: "POST", Path: "/birth-plan/sections/{section}/lock", Handle: (*Handler).LockBirthPlanSection, Summary: "Lock a section for 2 minutes, or renew the caller's lock (409 if someone else holds it)"},
+		{Method: "DELETE", Path: "/birth-plan/sections/{section}/lock", Handle: (*Handler).UnlockBirthPlanSection, Summary: "Release the caller's lock"},
+
+		// Journal post summaries (owner opt-in; only with SUMMARIZER_URL)
+		{Method: "GET", Path: "/summaries/consent", Handle: (*Handler).GetSummaryConsent, Summary: "available (service configured), consent, consentedAt, minLength"},
+		{Method: "PUT", Path: "/summaries/consent", Handle: (*Handler).UpdateSummaryConsent, Summary: "Owner: {\"consent\": true} opts in; false opts out and deletes every summary"},
+
+		// Entry triggers: smart home webhooks (owner; soft launch, ENTRY_TRIGGER_USERS)
+		{Method: "GET", Path: "/triggers", Handle: (*Handler).GetEntryTriggers, Summary: "Owner: list entry triggers (never the secret)"},
+		{Method: "POST", Path: "/triggers", Handle: (*Handler).CreateEntryTrigger, Summary: "Owner: create trigger ({\"name\", \"entryType\", \"condition\", \"url\", \"enabled\"}); secret returned once"},
+		{Method: "PUT", Path: "/triggers/{triggerId}", Handle: (*Handler).UpdateEntryTrigger, Summary: "Owner: replace a trigger's settings (secret kept)"},
+		{Method: "DELETE", Path: "/triggers/{triggerId}", Handle: (*Handler).DeleteEntryTrigger, Summary: "Owner: delete a trigger and its history"},
+		{Method: "POST", Path: "/triggers/{triggerId}/test", Handle: (*Handler).TestEntryTrigger, Summary: "Owner: call the webhook now with a test payload ({\"data\"} optional sample)"},
+		{Method: "GET", Path: "/triggers/{triggerId}/deliveries", Handle: (*Handler).GetTriggerDeliveries, Summary: "Owner: last 50 deliveries, newest first"},
+
+		// Sync endpoints
+		{Method: "GET", Path: "/sync", Handle: (*Handler).GetSync, Budget: "sync", Gzip: true, Protocol: true, Summary: "Pull all data since last sync"},
+		{Method: "GET", Path: "/sync/snapshot", Handle: (*Handler).GetSyncSnapshot, Cache: CacheShort, Budget: "sync", Protocol: true, Summary: "Full dataset as one pre-generated, gzipped, cacheable GetSync response"},
+		{Method: "POST", Path: "/sync", Handle: (*Handler).PostSync, Budget: "sync", Idempotent: true, Gzip: true, Protocol: true, Summary: "Push local changes"},
+		{Method: "POST", Path: "/sync/diff", Handle: (*Handler).PostSyncDiff, Scope: models.TokenScopeRead, Budget: "sync", Gzip: true, Protocol: true, Summary: "Reconcile a clientId→updatedAt manifest, returns newer and missing entries"},
+		{Method: "GET", Path: "/sync/lite", Handle: (*Handler).GetSyncLite, Budget: "sync", Gzip: true, Summary: "Compact supporter payload: week progress, shared photos/milestones, announcements"},
+		{Method: "GET", Path: "/sync/v2", Handle: (*Handler).GetSyncV2, Budget: "sync", Gzip: true, Protocol: true, Summary: "Sync v2 pull: entries since since with vector clocks, deleted ones as tombstones"},
+		{Method: "POST", Path: "/sync/v2", Handle: (*Handler).PostSyncV2, Budget: "sync", Gzip: true, Protocol: true, Summary: "Sync v2 push: entries with clocks, per-entry outcome in results"},
+		{Method: "GET", Path: "/sync/events", Handle: (*Handler).GetSyncEvents, Protocol: true, Surface: SurfaceEvents, Summary: "Server-Sent Events stream of entry and setting changes (query: since)"},
+
+		// Pairing endpoints
+		{Method: "POST", Path: "/pairing/request", Handle: (*Handler).CreatePairingRequest, Summary: "Create pairing request (targetEmail, requesterName, captchaToken) after spam checks"},
+		{Method: "GET", Path: "/pairing/pending", Handle: (*Handler).GetPendingPairingRequests, Summary: "Get pending requests"},
+		{Method: "POST", Path: "/pairing/approve/{requestId}", Handle: (*Handler).ApprovePairingRequest, Summary: "Approve request"},
+		{Method: "POST", Path: "/pairing/deny/{requestId}", Handle: (*Handler).DenyPairingRequest, Summary: "Deny request"},
+		{Method: "PUT", Path: "/pairing/permission", Handle: (*Handler).UpdatePartnerPermission, Summary: "Update partner permission"},
+		{Method: "DELETE", Path: "/pairing", Handle: (*Handler).RemovePairing, Summary: "Remove pairing (approved pairings after a 24-hour undo window)"},
+		{Method: "POST", Path: "/pairing/undo-removal", Handle: (*Handler).UndoPairingRemoval, Summary: "Undo a pending removal (requester only)"},
+		{Method: "GET", Path: "/pairing/status", Handle: (*Handler).GetPairingStatus, Summary: "Get pairing status"},
+
+		// Sharing / Invite code endpoints
+		{Method: "GET", Path: "/sharing/status", Handle: (*Handler).GetSharingStatus, Summary: "Get partner, supporters (with engagement), active codes"},
+		{Method: "GET", Path: "/sharing/graph", Handle: (*Handler).GetSharingGraph, Summary: "Owner: everyone and every code or token with access, with scope and expiry"},
+		{Method: "POST", Path: "/sharing/generate", Handle: (*Handler).GenerateInviteCode, Summary: "Generate invite code (optional welcome message)"},
+		{Method: "POST", Path: "/sharing/invite-batch", Handle: (*Handler).InviteBatch, Budget: "invite_batches", Summary: "Owner: invite up to 20 people ({name, role, permission, channel: email/sms, to}), returns per-invitee status and codeId"},
+		{Method: "POST", Path: "/sharing/preview", Handle: (*Handler).PreviewInviteCode, Budget: "invites", Summary: "Show role, names and welcome message of a code without redeeming it"},
+		{Method: "POST", Path: "/sharing/redeem", Handle: (*Handler).RedeemInviteCode, Budget: "invites", Summary: "Redeem invite code"},
+		{Method: "POST", Path: "/sharing/codes/{codeId}/revoke", Handle: (*Handler).RevokeInviteCode, Summary: "Revoke code"},
+		{Method: "DELETE", Path: "/sharing/supporters/{supporterId}", Handle: (*Handler).RemoveSupporter, Summary: "Remove supporter"},
+		{Method: "DELETE", Path: "/sharing/providers/{providerId}", Handle: (*Handler).RemoveCareProvider, Summary: "Remove care provider"},
+		{Method: "POST", Path: "/sharing/snooze", Handle: (*Handler).SnoozeSharing, Summary: "Pause partner/supporter visibility ({\"hours\": 1-720})"},
+		{Method: "DELETE", Path: "/sharing/snooze", Handle: (*Handler).LiftSharingSnooze, Summary: "Lift the snooze early"},
+		{Method: "POST", Path: "/sharing/widget-token", Handle: (*Handler).CreateWidgetToken, Access: AccessSession, Surface: SurfaceWidget, Summary: "Owner: issue a widget token ({\"name\", \"expiresInDays\"})"},
+		{Method: "GET", Path: "/sharing/widget-tokens", Handle: (*Handler).GetWidgetTokens, Access: AccessSession, Surface: SurfaceWidget, Summary: "Owner: list unrevoked widget tokens (never the secret)"},
+		{Method: "DELETE", Path: "/sharing/widget-tokens/{tokenId}", Handle: (*Handler).RevokeWidgetToken, Access: AccessSession, Surface: SurfaceWidget, Summary: "Owner: revoke a widget token immediately"},
+		{Method: "GET", Path: "/preview-as", Handle: (*Handler).GetPreviewAs, Budget: "sync", Gzip: true, Summary: "Owner: the data a supporter or partner sees (query: role=support|partner), read-only and marked by preview"},
+		{Method: "GET", Path: "/audit", Handle: (*Handler).GetAuditLog, Summary: "Owner: audited coowner actions, always paginated (query: limit, cursor, entity, actor, from, to)"},
+		{Method: "POST", Path: "/audit/export", Handle: (*Handler).CreateAuditExport, Budget: "exports", Summary: "Owner: start a CSV export ({\"entity\", \"actor\", \"from\", \"to\"}), returns 202 + jobId"},
+		{Method: "GET", Path: "/audit/export/{jobId}", Handle: (*Handler).GetAuditExport, Summary: "Poll status / progress / queuePosition; rows once completed"},
+		{Method: "GET", Path: "/audit/export/{jobId}/download", Handle: (*Handler).DownloadAuditExport, Summary: "Download the CSV"},
+		{Method: "GET", Path: "/me/role", Handle: (*Handler).GetMyRole, Summary: "Get user's role and permission"},
+		{Method: "GET", Path: "/me/capabilities", Handle: (*Handler).GetCapabilities, Summary: "Allowed actions: capabilities map and per-type entryTypes write flags"},
+		{Method: "GET", Path: "/me/preferences", Handle: (*Handler).GetPreferences, Summary: "Locale and formatting hints (dateFormat, timeFormat, firstDayOfWeek, measurementSystem, temperatureUnit, separators)"},
+		{Method: "PUT", Path: "/me/preferences", Handle: (*Handler).UpdatePreferences, Summary: "Save locale (BCP 47), measurementSystem (metric, us, uk), firstDayOfWeek (sunday, monday, saturday)"},
+		{Method: "GET", Path: "/me/activity-sharing", Handle: (*Handler).GetActivitySharing, Summary: "Supporter: whether the owner sees their engagement"},
+		{Method: "PUT", Path: "/me/activity-sharing", Handle: (*Handler).UpdateActivitySharing, Summary: "Supporter: show or hide engagement ({\"shareActivity\": false})"},
+
+		// Personal access tokens (session auth only)
+		{Method: "GET", Path: "/me/tokens", Handle: (*Handler).GetPersonalTokens, Access: AccessSession, Summary: "List unrevoked tokens (never the secret)"},
+		{Method: "POST", Path: "/me/tokens", Handle: (*Handler).CreatePersonalToken, Access: AccessSession, Summary: "Create token ({\"name\", \"scope\": \"read\" or \"write\", \"expiresInDays\"})"},
+		{Method: "DELETE", Path: "/me/tokens/{tokenId}", Handle: (*Handler).RevokePersonalToken, Access: AccessSession, Summary: "Revoke token immediately"},
+
+		// Data deletion (session auth only)
+		{Method: "DELETE", Path: "/me/data", Handle: (*Handler).DeleteUserData, Access: AccessSession, Summary: "Delete everything stored about the caller (dryRun=true previews)"},
+
+		// Failure injection (only with CHAOS_ENABLED, staging)
+		{Method: "GET", Path: "/me/chaos", Handle: (*Handler).GetChaos, Summary: "Faults injected into the caller's requests"},
+		{Method: "PUT", Path: "/me/chaos", Handle: (*Handler).UpdateChaos, Summary: "Inject latency, errors or partial sync failures into the caller's requests"},
+		{Method: "DELETE", Path: "/me/chaos", Handle: (*Handler).DeleteChaos, Summary: "Stop injecting faults"},
+
+		// User blocklist
+		{Method: "GET", Path: "/me/blocks", Handle: (*Handler).GetUserBlocks, Summary: "List blocked users, newest first"},
+		{Method: "POST", Path: "/me/blocks", Handle: (*Handler).CreateUserBlock, Summary: "Block an mvchat2 user ({\"userId\", \"reason\"}); blocking again updates the reason"},
+		{Method: "DELETE", Path: "/me/blocks/{userId}", Handle: (*Handler).DeleteUserBlock, Summary: "Unblock"},
+
+		// Care team notes (owner and linked providers only)
+		{Method: "GET", Path: "/care-notes", Handle: (*Handler).GetCareNotes, Summary: "List care notes (owner, coowner, providers only)"},
+		{Method: "POST", Path: "/care-notes", Handle: (*Handler).CreateCareNote, Summary: "Add note; notifies providers (or owner, if a provider wrote it)"},
+		{Method: "PUT", Path: "/care-notes/{noteId}", Handle: (*Handler).UpdateCareNote, Summary: "Edit own note"},
+		{Method: "DELETE", Path: "/care-notes/{noteId}", Handle: (*Handler).DeleteCareNote, Summary: "Delete own note"},
+
+		// Gap reminders
+		{Method: "GET", Path: "/reminders", Handle: (*Handler).GetEntryReminders, Summary: "Your gap reminders with the last entry of each type and whether it is due"},
+		{Method: "PUT", Path: "/reminders/{entryType}", Handle: (*Handler).SetEntryReminder, Summary: "Nudge me when no entry of the type was logged for {\"cadenceDays\": 1-90}"},
+		{Method: "DELETE", Path: "/reminders/{entryType}", Handle: (*Handler).DeleteEntryReminder, Summary: "Stop a gap reminder"},
+		{Method: "POST", Path: "/reminders/{entryType}/snooze", Handle: (*Handler).SnoozeEntryReminder, Summary: "Pause a gap reminder ({\"days\": 1-90})"},
+		{Method: "DELETE", Path: "/reminders/{entryType}/snooze", Handle: (*Handler).LiftEntryReminderSnooze, Summary: "Lift a gap reminder's snooze early"},
+
+		// Notification endpoints
+		{Method: "GET", Path: "/notifications", Handle: (*Handler).GetNotifications, Summary: "List notifications (query: unread, limit, cursor)"},
+		{Method: "POST", Path: "/notifications/{notificationId}/read", Handle: (*Handler).MarkNotificationRead, Summary: "Mark notification read"},
+
+		// Export endpoints
+		{Method: "POST", Path: "/export", Handle: (*Handler).ExportPregnancy, Budget: "exports", Heavy: true, Summary: "Download a ZIP of pregnancy, entries, settings (optional password, includeCareNotes, allowCrossRegion)"},
+
+		// File endpoints
+		{Method: "POST", Path: "/files/upload", Handle: (*Handler).UploadFile, Budget: "uploads", Summary: "Upload file (max 10MB)"},
+		{Method: "POST", Path: "/files/upload-batch", Handle: (*Handler).UploadFileBatch, Budget: "uploads", Summary: "Upload up to 25 files (10MB each) with per-file results"},
+		{Method: "GET", Path: "/files/alt-text-audit", Handle: (*Handler).GetAltTextAudit, Summary: "Images without alt text, newest first, with images / described counts (owner/partner)"},
+		{Method: "GET", Path: "/files/{fileId}", Handle: (*Handler).GetFile, Summary: "Get file metadata"},
+		{Method: "PATCH", Path: "/files/{fileId}", Handle: (*Handler).UpdateFile, Summary: "Set the file's altText (\"\" clears it; write permission)"},
+		{Method: "POST", Path: "/files/{fileId}/alt-text/suggest", Handle: (*Handler).SuggestAltText, Budget: "captions", Summary: "Suggest alt text for an image from the captioning provider (not saved)"},
+		{Method: "GET", Path: "/files/{fileId}/content", Handle: (*Handler).GetFileContent, Cache: CacheRevalidate, Summary: "Serve file content from hot or cold storage (owner/partner)"},
+		{Method: "DELETE", Path: "/files/{fileId}", Handle: (*Handler).DeleteFile, Summary: "Soft delete file"},
+		{Method: "GET", Path: "/files/{fileId}/preview", Handle: (*Handler).GetFilePreview, Summary: "Preview kind / status; posterUrl and, for videos, streamUrl once ready"},
+		{Method: "POST", Path: "/files/{fileId}/preview", Handle: (*Handler).RequestFilePreview, Summary: "Queue (re)rendering the preview (write permission), returns 202"},
+		{Method: "GET", Path: "/files/{fileId}/preview/poster", Handle: (*Handler).GetFilePreviewPoster, Cache: CachePreview, Summary: "Serve the PDF first page or video poster frame (JPEG)"},
+		{Method: "GET", Path: "/files/{fileId}/preview/hls/{name}", Handle: (*Handler).GetFilePreviewStream, Cache: CachePreview, Summary: "Serve the video's HLS playlist (index.m3u8) and segments"},
+	}
+
+	routeIndex = make(map[string]*Route, len(routes))
+	for i := range routes {
+		routeIndex[routes[i].Key()] = &routes[i]
+	}
+}
+
+// currentRoute returns the registry entry of the matched route, or nil.
+func currentRoute(r *http.Request) *Route {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return nil
+	}
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return nil
+	}
+	return routeIndex[r.Method+" "+tmpl]
+}
+
+// routeDenied returns why the user's credentials may not call the route, or ""
+// when they may. Pregnancy roles are checked by the handlers.
+func (h *Handler) routeDenied(rt *Route, user *auth.UserInfo) string {
+	switch {
+	case user.ImpersonationID != 0 && rt.Access != "":
+		return "Not available while impersonating"
+	case rt.Access == AccessAdmin && !h.isAdmin(user.UserID):
+		return "Admin access required"
+	case rt.Access == AccessAdmin && user.TokenID != 0:
+		return "Personal access tokens cannot call admin routes"
+	case rt.Access == AccessSession && user.TokenID != 0:
+		return "Personal access tokens cannot manage tokens or delete data"
+	case (user.TokenID != 0 || user.ImpersonationID != 0) && user.Scope != models.TokenScopeWrite && rt.scope() == models.TokenScopeWrite:
+		return "Token is read-only"
+	}
+	return ""
+}
+
+// RegisterRoutes installs the registry on the /api subrouter. Each route gets
+// only the middlewares its policies call for, outermost first: impersonation
+// audit, access, cache policy, sync protocol, gzip, injected faults (staging),
+// timeout, heavy queue, deprecation headers, idempotency, then the coowner
+// audit. Routes of disabled surfaces are left out. The subrouter's own
+// middlewares (breaker, auth, rate limits, backpressure) run before all of
+// them.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	for i := range routes {
+		rt := &routes[i]
+		if rt.Surface != "" && !h.SurfaceEnabled(rt.Surface) {
+			continue
+		}
+		var next http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rt.Handle(h, w, r)
+		})
+		next = h.CoownerAuditMiddleware(next)
+		if rt.Idempotent {
+			next = h.idempotencyMiddleware(rt, next)
+		}
+		if rt.Deprecated != nil {
+			next = h.deprecationMiddleware(rt, next)
+		}
+		if rt.Heavy {
+			next = h.heavyMiddleware(next)
+		}
+		if rt.Timeout > 0 {
+			next = timeoutMiddleware(rt.Timeout, next)
+		}
+		if h.chaos != nil && !chaosRoute(rt) {
+			next = h.chaosMiddleware(rt, next)
+		}
+		if rt.Gzip {
+			next = gzipMiddleware(next)
+		}
+		if rt.Protocol {
+			next = h.syncProtocolMiddleware(next)
+		}
+		next = cacheMiddleware(rt.cacheControl(), next)
+		next = h.accessMiddleware(rt, next)
+		next = h.impersonationMiddleware(next)
+		router.Handle(rt.Path, next).Methods(rt.Method)
+	}
+}
+
+// accessMiddleware rejects callers whose credentials may not use the route.
+func (h *Handler) accessMiddleware(rt *Route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if msg := h.routeDenied(rt, getUserInfo(r)); msg != "" {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", msg)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// timeoutMiddleware cancels the request context after d, so slow queries give
+// up instead of holding a connection until the server's write timeout.
+func timeoutMiddleware(d time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// allowedRoutes lists the routes the user's credentials may call.
+func (h *Handler) allowedRoutes(user *auth.UserInfo) []string {
+	allowed := make([]string, 0, len(routes))
+	for i := range routes {
+		if h.routeDenied(&routes[i], user) == "" {
+			allowed = append(allowed, routes[i].Key())
+		}
+	}
+	return allowed
+}
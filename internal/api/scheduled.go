@@ -0,0 +1,206 @@
+// Package api provides scheduled entry and calendar feed handlers.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+var validEntryStatuses = map[string]bool{
+	models.EntryStatusPlanned:   true,
+	models.EntryStatusCompleted: true,
+	models.EntryStatusMissed:    true,
+}
+
+// validateScheduledEntry checks the scheduling fields of an entry request.
+// Returns an error message, or "" if the request is valid.
+func validateScheduledEntry(req *models.EntryRequest) string {
+	if req.Unschedule && (req.ScheduledFor != nil || req.Status != nil) {
+		return "unschedule cannot be combined with scheduledFor or status"
+	}
+	if req.ScheduledFor != nil {
+		if _, err := time.Parse(time.RFC3339, *req.ScheduledFor); err != nil {
+			return "scheduledFor must be an RFC3339 timestamp"
+		}
+	}
+	if req.Status != nil {
+		if req.ScheduledFor == nil {
+			return "status is only valid for scheduled entries"
+		}
+		if !validEntryStatuses[*req.Status] {
+			return "status must be 'planned', 'completed' or 'missed'"
+		}
+	}
+	return ""
+}
+
+// GetDueScheduledEntries lists planned entries whose date has passed, so the client
+// can prompt the user to mark them completed or missed.
+func (h *Handler) GetDueScheduledEntries(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	entries, err := h.db.GetScheduledEntries(ctx, pregnancy.ID, "due")
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.EntriesResponse{
+		Entries:     visibleEntries(entries, entryAudience(pregnancy, user.UserID), nil),
+		SyncVersion: pregnancy.SyncVersion,
+	})
+}
+
+// SetEntryStatus transitions a scheduled entry between planned, completed and missed.
+func (h *Handler) SetEntryStatus(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	clientID := mux.Vars(r)["clientId"]
+
+	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return
+	}
+
+	var req models.EntryStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	if req.EntryType == "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "entryType is required")
+		return
+	}
+	if !validEntryStatuses[req.Status] {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "status must be 'planned', 'completed' or 'missed'")
+		return
+	}
+
+	entry, err := h.db.SetEntryStatus(ctx, pregnancy.ID, req.EntryType, clientID, req.Status, entryAudience(pregnancy, user.UserID))
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Scheduled entry not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// GetCalendarFeed returns scheduled entries as an iCalendar (ICS) feed.
+func (h *Handler) GetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	h.writeCalendar(w, r, getUserInfo(r).UserID)
+}
+
+// writeCalendar writes the ICS feed of the pregnancy userID can access, with the
+// entries they may see. While sharing is snoozed, non-owners get an empty
+// calendar so subscribed apps drop the events rather than keep stale copies.
+func (h *Handler) writeCalendar(w http.ResponseWriter, r *http.Request, userID string) {
+	ctx := r.Context()
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, userID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	var entries []models.Entry
+	if _, _, snoozed := activeSnooze(pregnancy, userID, time.Now()); !snoozed {
+		entries, err = h.db.GetScheduledEntries(ctx, pregnancy.ID, "")
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(buildICS(pregnancy.ID, visibleEntries(entries, entryAudience(pregnancy, userID), nil), time.Now())))
+}
+
+func buildICS(pregnancyID int64, entries []models.Entry, now time.Time) string {
+	const icsTime = "20060102T150405Z"
+
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\n")
+	b.WriteString("VERSION:2.0\r\n")
+	b.WriteString("PRODID:-//Clingy//Tracker2API//EN\r\n")
+	b.WriteString("CALSCALE:GREGORIAN\r\n")
+
+	for _, e := range entries {
+		var payload map[string]interface{}
+		json.Unmarshal(e.Data, &payload)
+
+		summary := e.EntryType
+		for _, key := range []string{"title", "name", "type"} {
+			if v, ok := payload[key].(string); ok && v != "" {
+				summary = v
+				break
+			}
+		}
+
+		start := e.ScheduledFor.Time.UTC()
+		b.WriteString("BEGIN:VEVENT\r\n")
+		fmt.Fprintf(&b, "UID:%s-%s-%d@clingy.me\r\n", e.EntryType, e.ClientID, pregnancyID)
+		fmt.Fprintf(&b, "DTSTAMP:%s\r\n", now.UTC().Format(icsTime))
+		fmt.Fprintf(&b, "DTSTART:%s\r\n", start.Format(icsTime))
+		fmt.Fprintf(&b, "DTEND:%s\r\n", start.Add(time.Hour).Format(icsTime))
+		fmt.Fprintf(&b, "SUMMARY:%s\r\n", icsEscape(summary))
+		if notes, ok := payload["notes"].(string); ok && notes != "" {
+			fmt.Fprintf(&b, "DESCRIPTION:%s\r\n", icsEscape(notes))
+		}
+		if location, ok := payload["location"].(string); ok && location != "" {
+			fmt.Fprintf(&b, "LOCATION:%s\r\n", icsEscape(location))
+		}
+		switch e.Status.String {
+		case models.EntryStatusMissed:
+			b.WriteString("STATUS:CANCELLED\r\n")
+		default:
+			b.WriteString("STATUS:CONFIRMED\r\n")
+		}
+		b.WriteString("END:VEVENT\r\n")
+	}
+
+	b.WriteString("END:VCALENDAR\r\n")
+	return b.String()
+}
+
+func icsEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
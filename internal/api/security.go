@@ -0,0 +1,224 @@
+// Package api provides access fingerprinting and security event handlers.
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/pagination"
+)
+
+// Headers set by the edge proxy with the client's ISO country, in priority order.
+var countryHeaders = []string{"CF-IPCountry", "X-Country-Code"}
+
+// FingerprintMiddleware records a device fingerprint for every authenticated request
+// and raises a security event when a pregnancy is reached from a new device or country.
+// It must run after AuthMiddleware. Tracking failures never block the request.
+func (h *Handler) FingerprintMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := getUserInfo(r)
+		// Impersonation sessions are audited and reported to the owner on their own
+		if user.ImpersonationID != 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fp := requestFingerprint(r, user.UserID)
+
+		newDevice, newCountry, err := h.db.RecordFingerprint(r.Context(), fp)
+		if err != nil {
+			log.Printf("Failed to record access fingerprint: %v", err)
+		} else if newDevice || newCountry {
+			h.handleNewAccess(r.Context(), fp, newDevice, newCountry)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestFingerprint hashes the token and device identifiers of a request.
+func requestFingerprint(r *http.Request, userID string) *models.AccessFingerprint {
+	token := strings.TrimSpace(r.Header.Get("Authorization"))
+	if i := strings.IndexByte(token, ' '); i >= 0 {
+		token = token[i+1:]
+	}
+	userAgent := r.Header.Get("User-Agent")
+
+	var country string
+	for _, header := range countryHeaders {
+		if c := strings.ToUpper(strings.TrimSpace(r.Header.Get(header))); len(c) == 2 && c != "XX" {
+			country = c
+			break
+		}
+	}
+
+	return &models.AccessFingerprint{
+		UserID:     userID,
+		TokenHash:  sha256Hex(token),
+		DeviceHash: sha256Hex(userAgent + "|" + r.Header.Get("X-Device-ID")),
+		UserAgent:  userAgent,
+		Country:    country,
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// handleNewAccess writes a security event for the user's pregnancy, notifies the owner
+// and, if the owner asked for it, unpairs non-owners so they must re-pair.
+func (h *Handler) handleNewAccess(ctx context.Context, fp *models.AccessFingerprint, newDevice, newCountry bool) {
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, fp.UserID)
+	if err == db.ErrNotFound {
+		pregnancy, err = h.db.GetPregnancyByProvider(ctx, fp.UserID)
+	}
+	if err == db.ErrNotFound {
+		return // Not linked to a pregnancy, nothing to protect
+	}
+	if err != nil {
+		log.Printf("Failed to load pregnancy for security event: %v", err)
+		return
+	}
+
+	kind := models.SecurityEventNewDevice
+	if !newDevice && newCountry {
+		kind = models.SecurityEventNewCountry
+	}
+
+	action := "notified"
+	isOwner := pregnancy.OwnerID == fp.UserID || (pregnancy.CoownerID.Valid && pregnancy.CoownerID.String == fp.UserID)
+	if !isOwner && newDevice {
+		required, err := h.db.GetRequireRepair(ctx, pregnancy.ID)
+		if err != nil {
+			log.Printf("Failed to load security settings: %v", err)
+		}
+		if required {
+			if err := h.db.RevokeViewerAccess(ctx, pregnancy.ID, fp.UserID); err != nil {
+				log.Printf("Failed to revoke access after new device: %v", err)
+			} else {
+				action = "unpaired"
+			}
+		}
+	}
+
+	event, err := h.db.CreateSecurityEvent(ctx, &models.SecurityEvent{
+		PregnancyID: pregnancy.ID,
+		UserID:      fp.UserID,
+		Kind:        kind,
+		UserAgent:   sql.NullString{String: fp.UserAgent, Valid: fp.UserAgent != ""},
+		Country:     sql.NullString{String: fp.Country, Valid: fp.Country != ""},
+		Action:      action,
+	})
+	if err != nil {
+		log.Printf("Failed to create security event: %v", err)
+		return
+	}
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"eventId": event.ID,
+		"kind":    event.Kind,
+		"userId":  event.UserID,
+		"action":  event.Action,
+	})
+	if err := h.db.CreateNotification(ctx, pregnancy.OwnerID, pregnancy.ID, "security_event", payload); err != nil {
+		log.Printf("Failed to create security notification: %v", err)
+	}
+}
+
+// getSecurityPregnancy returns the pregnancy if the user is its owner or coowner.
+func (h *Handler) getSecurityPregnancy(ctx context.Context, userID string) (*models.Pregnancy, error) {
+	pregnancy, err := h.db.GetPregnancyByOwner(ctx, userID)
+	if err == db.ErrNotFound {
+		pregnancy, err = h.db.GetPregnancyByCoowner(ctx, userID)
+	}
+	return pregnancy, err
+}
+
+// GetSecurityEvents lists recent security events for the owner's pregnancy.
+// With limit or cursor the events come as a page envelope next to requireRepair.
+func (h *Handler) GetSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, err := h.getSecurityPregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only the owner can view security events")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	paged := pagination.Requested(r)
+	params := legacyPage
+	if paged {
+		var ok bool
+		if params, ok = readPage(w, r); !ok {
+			return
+		}
+	}
+
+	events, err := h.db.GetSecurityEvents(ctx, pregnancy.ID, params)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	page := pagination.NewPage(events, params, securityEventCursor)
+
+	requireRepair, err := h.db.GetRequireRepair(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if paged {
+		writeJSON(w, http.StatusOK, models.SecurityEventsPage{Page: page, RequireRepair: requireRepair})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"events":        page.Items,
+		"requireRepair": requireRepair,
+	})
+}
+
+// UpdateSecuritySettings sets whether partners and supporters seen on a new device
+// are unpaired and must redeem a new invite code.
+func (h *Handler) UpdateSecuritySettings(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, err := h.getSecurityPregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only the owner can change security settings")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	var req models.SecuritySettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	if err := h.db.SetRequireRepair(ctx, pregnancy.ID, req.RequireRepair); err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":       true,
+		"requireRepair": req.RequireRepair,
+	})
+}
@@ -0,0 +1,115 @@
+// Package api provides setting history, revert and delete.
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// GetSettingHistory returns a setting's recent revisions, newest first.
+func (h *Handler) GetSettingHistory(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	settingType := mux.Vars(r)["type"]
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	revisions, err := h.db.GetSettingRevisions(ctx, pregnancy.ID, settingType)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"revisions": revisions})
+}
+
+// RevertSetting restores a setting to one of its revisions. The revert is
+// itself recorded as a new revision, so it can be undone the same way.
+func (h *Handler) RevertSetting(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	settingType := mux.Vars(r)["type"]
+
+	var req models.RevertSettingRequest
+	if err := decodeBody(r, &req); err != nil || req.RevisionID == 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "revisionId is required")
+		return
+	}
+
+	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return
+	}
+	if status, code, msg := checkSettingWrite(pregnancy, user.UserID, settingType, nil); status != 0 {
+		writeError(w, status, code, msg)
+		return
+	}
+
+	setting, err := h.db.RevertSetting(ctx, pregnancy.ID, settingType, req.RevisionID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Revision not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, setting)
+}
+
+// DeleteSetting soft-deletes a setting. It drops out of settings and sync but
+// keeps its history, and can be brought back with RevertSetting.
+func (h *Handler) DeleteSetting(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	settingType := mux.Vars(r)["type"]
+
+	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return
+	}
+	if status, code, msg := checkSettingWrite(pregnancy, user.UserID, settingType, nil); status != 0 {
+		writeError(w, status, code, msg)
+		return
+	}
+
+	if _, err := h.db.DeleteSetting(ctx, pregnancy.ID, settingType); err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Setting not found")
+		return
+	} else if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
@@ -0,0 +1,221 @@
+// Package api provides the owner's consolidated view of who can see the
+// pregnancy.
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// GetSharingGraph lists everyone and everything with access to the owner's
+// pregnancy: coowner, partner, supporters, care providers, unredeemed invite
+// codes, widget tokens and the owner's personal tokens and calendar feeds, each
+// with its scope and expiry. Expired codes and tokens are left out since they
+// grant nothing.
+func (h *Handler) GetSharingGraph(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, err := h.db.GetPregnancyByOwner(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	profiles, err := h.db.GetPregnancyProfiles(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	now := time.Now()
+	paused := pregnancy.SharingSnoozedUntil.Valid && pregnancy.SharingSnoozedUntil.Time.After(now)
+	resp := models.SharingGraph{
+		Grants:      []models.SharingGrant{},
+		GeneratedAt: now.UTC().Format(time.RFC3339),
+	}
+	if paused {
+		until := pregnancy.SharingSnoozedUntil.Time.Format(time.RFC3339)
+		resp.SnoozedUntil = &until
+	}
+
+	if pregnancy.CoownerID.Valid {
+		grant := models.SharingGrant{
+			Kind:      "coowner",
+			ID:        pregnancy.CoownerID.String,
+			UserID:    pregnancy.CoownerID.String,
+			Name:      pregnancy.CoownerName.String,
+			AvatarURL: profileAvatar(profiles, pregnancy.CoownerID.String),
+			Scope:     "full",
+		}
+		history, err := h.db.GetCoownerHistory(ctx, pregnancy.ID)
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		// History is newest first, so the first link of the current coowner is the latest
+		for _, e := range history {
+			if e.CoownerID == pregnancy.CoownerID.String && e.Action == "linked" {
+				grant.GrantedAt = e.CreatedAt.Format(time.RFC3339)
+				break
+			}
+		}
+		resp.Grants = append(resp.Grants, grant)
+	}
+
+	if pregnancy.PartnerID.Valid && (pregnancy.PartnerStatus.String == "approved" || pregnancy.PartnerStatus.String == "removing") {
+		grant := models.SharingGrant{
+			Kind:      "partner",
+			ID:        pregnancy.PartnerID.String,
+			UserID:    pregnancy.PartnerID.String,
+			Name:      pregnancy.PartnerName.String,
+			AvatarURL: profileAvatar(profiles, pregnancy.PartnerID.String),
+			Scope:     pregnancy.PartnerPermission.String,
+			GrantedAt: pregnancy.UpdatedAt.Format(time.RFC3339),
+			Paused:    paused,
+			Suspended: pregnancy.PartnerStatus.String == "removing",
+		}
+		if grant.Suspended && pregnancy.PartnerRemovalRequestedAt.Valid {
+			// Access ends for good once the undo window passes
+			removeAt := pregnancy.PartnerRemovalRequestedAt.Time.Add(pairingRemovalWindow).UTC().Format(time.RFC3339)
+			grant.ExpiresAt = &removeAt
+		}
+		resp.Grants = append(resp.Grants, grant)
+	}
+
+	supporters, err := h.db.GetSupporters(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	for _, s := range supporters {
+		resp.Grants = append(resp.Grants, models.SharingGrant{
+			Kind:      "supporter",
+			ID:        strconv.FormatInt(s.ID, 10),
+			UserID:    s.UserID,
+			Name:      s.DisplayName.String,
+			AvatarURL: profileAvatar(profiles, s.UserID),
+			Scope:     "read",
+			GrantedAt: s.JoinedAt.Format(time.RFC3339),
+			Paused:    paused,
+		})
+	}
+
+	providers, err := h.db.GetCareProviders(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	for _, p := range providers {
+		resp.Grants = append(resp.Grants, models.SharingGrant{
+			Kind:      "provider",
+			ID:        strconv.FormatInt(p.ID, 10),
+			UserID:    p.UserID,
+			Name:      p.DisplayName.String,
+			AvatarURL: profileAvatar(profiles, p.UserID),
+			Scope:     "care_notes",
+			GrantedAt: p.JoinedAt.Format(time.RFC3339),
+		})
+	}
+
+	codes, err := h.db.GetActiveInviteCodes(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	for _, c := range codes {
+		scope := c.Permission
+		if c.Role == "provider" {
+			scope = "care_notes"
+		}
+		expires := c.ExpiresAt.Format(time.RFC3339)
+		resp.Grants = append(resp.Grants, models.SharingGrant{
+			Kind:      "invite_code",
+			ID:        strconv.FormatInt(c.ID, 10),
+			Name:      c.CodePrefix,
+			Role:      c.Role,
+			Scope:     scope,
+			GrantedAt: c.CreatedAt.Format(time.RFC3339),
+			ExpiresAt: &expires,
+		})
+	}
+
+	widgets, err := h.db.GetWidgetTokens(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	for _, t := range widgets {
+		if t.ExpiresAt.Valid && !t.ExpiresAt.Time.After(now) {
+			continue
+		}
+		resp.Grants = append(resp.Grants, models.SharingGrant{
+			Kind:       "widget_token",
+			ID:         strconv.FormatInt(t.ID, 10),
+			Name:       t.Name,
+			Scope:      "read",
+			GrantedAt:  t.CreatedAt.Format(time.RFC3339),
+			ExpiresAt:  grantTime(t.ExpiresAt),
+			LastUsedAt: grantTime(t.LastUsedAt),
+			Paused:     paused,
+		})
+	}
+
+	tokens, err := h.db.GetPersonalTokens(ctx, user.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	for _, t := range tokens {
+		if t.ExpiresAt.Valid && !t.ExpiresAt.Time.After(now) {
+			continue
+		}
+		resp.Grants = append(resp.Grants, models.SharingGrant{
+			Kind:       "personal_token",
+			ID:         strconv.FormatInt(t.ID, 10),
+			Name:       t.Name,
+			Scope:      t.Scope,
+			GrantedAt:  t.CreatedAt.Format(time.RFC3339),
+			ExpiresAt:  grantTime(t.ExpiresAt),
+			LastUsedAt: grantTime(t.LastUsedAt),
+		})
+	}
+
+	if h.SurfaceEnabled(SurfaceCalendarFeeds) {
+		feeds, err := h.db.GetCalendarFeeds(ctx, user.UserID)
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		for _, f := range feeds {
+			resp.Grants = append(resp.Grants, models.SharingGrant{
+				Kind:       "calendar_feed",
+				ID:         strconv.FormatInt(f.ID, 10),
+				Name:       f.Name,
+				Scope:      "read",
+				GrantedAt:  f.CreatedAt.Format(time.RFC3339),
+				LastUsedAt: grantTime(f.LastUsedAt),
+			})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// grantTime formats an optional grant time, nil when unset.
+func grantTime(t sql.NullTime) *string {
+	if !t.Valid {
+		return nil
+	}
+	s := t.Time.Format(time.RFC3339)
+	return &s
+}
@@ -0,0 +1,216 @@
+// Package api provides per-route success rates and latencies for on-call.
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+const (
+	// sloRouteSamples is how many recent requests each route keeps. Busier
+	// routes are reported from their most recent requests only.
+	sloRouteSamples = 1024
+	// defaultSLOTarget is the success rate objective, in percent.
+	defaultSLOTarget = 99.5
+	// SLO report windows
+	defaultSLOWindow = time.Hour
+	minSLOWindow     = time.Minute
+	maxSLOWindow     = 24 * time.Hour
+)
+
+// sloSample is one finished request. 5xx responses count as failures; 4xx are
+// the client's fault and don't spend the error budget.
+type sloSample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// sloRing holds a route's most recent samples.
+type sloRing struct {
+	samples []sloSample
+	next    int
+}
+
+// sloRecorder keeps recent requests per route in memory. State is per process
+// and starts empty on every restart.
+type sloRecorder struct {
+	mu      sync.Mutex
+	started time.Time
+	routes  map[string]*sloRing
+}
+
+func newSLORecorder() *sloRecorder {
+	return &sloRecorder{started: time.Now(), routes: make(map[string]*sloRing)}
+}
+
+func (s *sloRecorder) record(route string, sample sloSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ring := s.routes[route]
+	if ring == nil {
+		ring = &sloRing{samples: make([]sloSample, 0, sloRouteSamples)}
+		s.routes[route] = ring
+	}
+	if len(ring.samples) < sloRouteSamples {
+		ring.samples = append(ring.samples, sample)
+		return
+	}
+	ring.samples[ring.next] = sample
+	ring.next = (ring.next + 1) % sloRouteSamples
+}
+
+// window copies the samples of every route recorded since from. partial
+// reports the routes whose ring no longer reaches back to from.
+func (s *sloRecorder) window(from time.Time) (samples map[string][]sloSample, partial map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples = make(map[string][]sloSample, len(s.routes))
+	partial = make(map[string]bool)
+	for route, ring := range s.routes {
+		var kept []sloSample
+		for _, sample := range ring.samples {
+			if !sample.at.Before(from) {
+				kept = append(kept, sample)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		samples[route] = kept
+		if len(ring.samples) == sloRouteSamples && len(kept) == sloRouteSamples {
+			partial[route] = true
+		}
+	}
+	return samples, partial
+}
+
+// summarizeSLO computes the success rate, error budget and latency percentiles
+// of samples against a success rate target in percent.
+func summarizeSLO(samples []sloSample, target float64) models.RouteSLO {
+	summary := models.RouteSLO{Requests: len(samples), SuccessRate: 100, ErrorBudgetRemaining: 100}
+	if len(samples) == 0 {
+		return summary
+	}
+
+	latencies := make([]time.Duration, len(samples))
+	for i, sample := range samples {
+		latencies[i] = sample.latency
+		if sample.failed {
+			summary.Failures++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(latencies)))) - 1
+		return roundTo(float64(latencies[max(i, 0)].Microseconds())/1000, 1)
+	}
+	summary.P50Ms = percentile(0.50)
+	summary.P95Ms = percentile(0.95)
+	summary.P99Ms = percentile(0.99)
+
+	summary.SuccessRate = roundTo(100*float64(len(samples)-summary.Failures)/float64(len(samples)), 3)
+	allowed := (100 - target) / 100 * float64(len(samples))
+	summary.ErrorBudgetRemaining = roundTo(100*(1-float64(summary.Failures)/allowed), 1)
+	return summary
+}
+
+func roundTo(v float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(v*scale) / scale
+}
+
+// SLOMiddleware times every matched request and records it under its route
+// template. It must be installed on the root router so it also sees requests
+// answered by the breaker, auth and rate limit middlewares.
+func (h *Handler) SLOMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		// Event streams stay open as long as the client does; their duration isn't latency
+		if rec.Header().Get("Content-Type") == "text/event-stream" {
+			return
+		}
+		h.slo.record(r.Method+" "+tmpl, sloSample{at: start, latency: time.Since(start), failed: rec.status >= 500})
+	})
+}
+
+// GetSLO reports rolling success rates, error budgets and latency percentiles
+// per route (query: window, a Go duration from 1m to 24h; target, the success
+// rate objective in percent).
+func (h *Handler) GetSLO(w http.ResponseWriter, r *http.Request) {
+	window := defaultSLOWindow
+	if s := r.URL.Query().Get("window"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < minSLOWindow || d > maxSLOWindow {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "window must be a duration from 1m to 24h, like 15m or 6h")
+			return
+		}
+		window = d
+	}
+	target := defaultSLOTarget
+	if s := r.URL.Query().Get("target"); s != "" {
+		t, err := strconv.ParseFloat(s, 64)
+		if err != nil || t < 50 || t >= 100 {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "target must be a percentage from 50 to below 100")
+			return
+		}
+		target = t
+	}
+
+	now := time.Now()
+	samples, partial := h.slo.window(now.Add(-window))
+
+	var all []sloSample
+	routes := make([]models.RouteSLO, 0, len(samples))
+	for route, s := range samples {
+		summary := summarizeSLO(s, target)
+		summary.Route = route
+		summary.Partial = partial[route]
+		routes = append(routes, summary)
+		all = append(all, s...)
+	}
+	// Least error budget left first, then the busiest
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].ErrorBudgetRemaining != routes[j].ErrorBudgetRemaining {
+			return routes[i].ErrorBudgetRemaining < routes[j].ErrorBudgetRemaining
+		}
+		if routes[i].Requests != routes[j].Requests {
+			return routes[i].Requests > routes[j].Requests
+		}
+		return routes[i].Route < routes[j].Route
+	})
+
+	overall := summarizeSLO(all, target)
+	overall.Partial = len(partial) > 0
+	writeJSON(w, http.StatusOK, models.SLOReport{
+		Window:     fmt.Sprint(window),
+		Target:     target,
+		Since:      h.slo.started.UTC().Format(time.RFC3339),
+		ServerTime: now.UTC().Format(time.RFC3339),
+		Overall:    overall,
+		Routes:     routes,
+	})
+}
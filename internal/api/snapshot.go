@@ -0,0 +1,253 @@
+// Package api provides pre-generated sync snapshots for offline bootstrap.
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// A snapshot is regenerated in the background once this many entries and
+// settings changed since it was taken, or once it is a day old and anything changed.
+const (
+	snapshotChangeThreshold = 100
+	snapshotMaxAge          = 24 * time.Hour
+	snapshotTimeout         = 5 * time.Minute
+)
+
+// GetSyncSnapshot serves the caller's full dataset as one gzipped, cacheable
+// GetSync response. Clients apply it, then call GET /api/sync?since=<serverTime>.
+func (h *Handler) GetSyncSnapshot(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	// Snoozed viewers get the same empty response as GetSync, from sync version 0
+	if start, until, snoozed := activeSnooze(pregnancy, user.UserID, time.Now()); snoozed {
+		writeJSON(w, http.StatusOK, models.SyncResponse{
+			Pregnancy:    h.toPregnancyDTO(pregnancy),
+			ServerTime:   start.Format(time.RFC3339),
+			Snoozed:      true,
+			SnoozedUntil: until.Format(time.RFC3339),
+		})
+		return
+	}
+
+	snap, err := h.db.GetSyncSnapshot(ctx, pregnancy.ID)
+	if err == db.ErrNotFound {
+		// First request: build it now, later ones are refreshed in the background
+		snap, err = h.generateSyncSnapshot(ctx, pregnancy)
+	} else if err == nil {
+		h.refreshSyncSnapshot(ctx, pregnancy, snap)
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	// Viewers who can't read every entry get the snapshot filtered, under their own ETag
+	audience := entryAudience(pregnancy, user.UserID)
+	etag := `"` + snap.ETag + `"`
+	if audience != nil {
+		etag = `"` + snap.ETag + "-" + strings.Join(audience, "-") + `"`
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", snap.GeneratedAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("Vary", "Accept-Encoding")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	path, err := h.storage.Path(pregnancy.Region, snap.StoragePath)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	defer f.Close()
+
+	if audience != nil {
+		writeVisibleSnapshot(w, f, audience)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Length", fmt.Sprint(snap.SizeBytes))
+		w.WriteHeader(http.StatusOK)
+		io.Copy(w, f)
+		return
+	}
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, zr)
+}
+
+// writeVisibleSnapshot serves a stored snapshot without the entries the
+// audience may not read.
+func writeVisibleSnapshot(w http.ResponseWriter, f io.Reader, audience []string) {
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	var resp models.SyncResponse
+	if err := json.NewDecoder(zr).Decode(&resp); err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	for entryType, entries := range resp.Entries {
+		if visible := visibleEntries(entries, audience, nil); len(visible) > 0 {
+			resp.Entries[entryType] = visible
+		} else {
+			delete(resp.Entries, entryType)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// refreshSyncSnapshot regenerates a snapshot in the background after significant
+// changes. The current snapshot keeps being served meanwhile.
+func (h *Handler) refreshSyncSnapshot(ctx context.Context, pregnancy *models.Pregnancy, snap *models.SyncSnapshot) {
+	changes, err := h.db.CountChangesSince(ctx, pregnancy.ID, snap.SourceTime)
+	if err != nil {
+		log.Printf("Snapshot %d: failed to count changes: %v", pregnancy.ID, err)
+		return
+	}
+	if changes < snapshotChangeThreshold && (changes == 0 || time.Since(snap.GeneratedAt) < snapshotMaxAge) {
+		return
+	}
+	if _, running := h.snapshotsInFlight.LoadOrStore(pregnancy.ID, true); running {
+		return
+	}
+
+	go func() {
+		defer h.snapshotsInFlight.Delete(pregnancy.ID)
+		ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+		defer cancel()
+		if _, err := h.generateSyncSnapshot(ctx, pregnancy); err != nil {
+			log.Printf("Snapshot %d: regeneration failed: %v", pregnancy.ID, err)
+		}
+	}()
+}
+
+// generateSyncSnapshot writes the pregnancy's full GetSync response to storage.
+func (h *Handler) generateSyncSnapshot(ctx context.Context, pregnancy *models.Pregnancy) (*models.SyncSnapshot, error) {
+	// Taken before reading so changes made while building are picked up by the next incremental sync
+	sourceTime := time.Now()
+	syncVersion, err := h.db.GetSyncVersion(ctx, pregnancy.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := h.db.GetEntries(ctx, pregnancy.ID, "", nil, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	entriesByType := make(map[string][]models.Entry)
+	for _, e := range entries {
+		entriesByType[e.EntryType] = append(entriesByType[e.EntryType], e)
+	}
+	settings, err := h.db.GetSettings(ctx, pregnancy.ID)
+	if err != nil {
+		return nil, err
+	}
+	settingVersions, err := h.db.GetSettingVersions(ctx, pregnancy.ID)
+	if err != nil {
+		return nil, err
+	}
+	settingRevisions, err := h.db.GetSettingRevisionIDs(ctx, pregnancy.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	err = json.NewEncoder(zw).Encode(models.SyncResponse{
+		Pregnancy:        h.toPregnancyDTO(pregnancy),
+		Entries:          entriesByType,
+		Settings:         settings,
+		SettingVersions:  settingVersions,
+		SettingRevisions: settingRevisions,
+		SyncVersion:      syncVersion,
+		ServerTime:       sourceTime.Format(time.RFC3339),
+		CompactedBefore:  compactedBefore(pregnancy),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	snap := &models.SyncSnapshot{
+		PregnancyID: pregnancy.ID,
+		StoragePath: filepath.Join("snapshots", fmt.Sprintf("%d-%s.json.gz", pregnancy.ID, hex.EncodeToString(sum[:8]))),
+		ETag:        hex.EncodeToString(sum[:]),
+		SizeBytes:   int64(buf.Len()),
+		Entries:     len(entries),
+		SourceTime:  sourceTime,
+		GeneratedAt: time.Now(),
+	}
+
+	path, err := h.storage.Path(pregnancy.Region, snap.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return nil, err
+	}
+
+	previous, _ := h.db.GetSyncSnapshot(ctx, pregnancy.ID)
+	if err := h.db.SaveSyncSnapshot(ctx, snap); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	// Readers that already looked up the old snapshot may still be opening it
+	if previous != nil && previous.StoragePath != snap.StoragePath {
+		time.AfterFunc(time.Minute, func() {
+			if old, err := h.storage.Path(pregnancy.Region, previous.StoragePath); err == nil {
+				os.Remove(old)
+			}
+		})
+	}
+	return snap, nil
+}
@@ -0,0 +1,92 @@
+// Package api provides the owner-controlled sharing snooze handlers.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// Longest snooze an owner can set in one request.
+const maxSnoozeHours = 30 * 24
+
+// activeSnooze reports whether sharing is snoozed for the user. The owner and
+// coowner always see their data. It returns when the snooze started and ends.
+func activeSnooze(pregnancy *models.Pregnancy, userID string, now time.Time) (start, until time.Time, snoozed bool) {
+	if pregnancy.OwnerID == userID || (pregnancy.CoownerID.Valid && pregnancy.CoownerID.String == userID) {
+		return time.Time{}, time.Time{}, false
+	}
+	if !pregnancy.SharingSnoozedUntil.Valid || !pregnancy.SharingSnoozedUntil.Time.After(now) {
+		return time.Time{}, time.Time{}, false
+	}
+	start = now
+	if pregnancy.SharingSnoozedAt.Valid {
+		start = pregnancy.SharingSnoozedAt.Time
+	}
+	return start, pregnancy.SharingSnoozedUntil.Time, true
+}
+
+// SnoozeSharing hides entries and settings from partners and supporters for a while
+// without unpairing anyone.
+func (h *Handler) SnoozeSharing(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, err := h.db.GetPregnancyByOwner(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	var req models.SharingSnoozeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	if req.Hours < 1 || req.Hours > maxSnoozeHours {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "hours must be between 1 and 720")
+		return
+	}
+
+	until := time.Now().Add(time.Duration(req.Hours) * time.Hour)
+	if err := h.db.SetSharingSnooze(ctx, pregnancy.ID, &until); err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"snoozedUntil": until.Format(time.RFC3339),
+	})
+}
+
+// LiftSharingSnooze ends a snooze early.
+func (h *Handler) LiftSharingSnooze(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, err := h.db.GetPregnancyByOwner(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if err := h.db.SetSharingSnooze(ctx, pregnancy.ID, nil); err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"success": true})
+}
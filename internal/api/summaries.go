@@ -0,0 +1,164 @@
+// Package api provides opt-in summaries and suggested tags for long journal
+// posts, written by a pluggable summarization service.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/summarize"
+)
+
+const (
+	// summaryInterval is how often new and edited posts are looked for.
+	summaryInterval = time.Minute
+	// summaryBatchSize caps the posts summarized per pass.
+	summaryBatchSize = 20
+	// maxSummaryInput caps the characters of a post sent to the service.
+	maxSummaryInput = 20000
+)
+
+// entrySummaries returns the current summaries of the pregnancy's journal
+// posts written after since that the audience may read, by entry clientId. It
+// is nil while summaries are disabled.
+func (h *Handler) entrySummaries(ctx context.Context, pregnancyID int64, audience []string, since *time.Time) (map[string]models.EntrySummary, error) {
+	if h.summarizer == nil {
+		return nil, nil
+	}
+	summaries, err := h.db.GetEntrySummaries(ctx, pregnancyID, audience, since)
+	if err != nil || len(summaries) == 0 {
+		return nil, err
+	}
+	byClientID := make(map[string]models.EntrySummary, len(summaries))
+	for _, s := range summaries {
+		byClientID[s.ClientID] = s
+	}
+	return byClientID, nil
+}
+
+// summaryConsentResponse reports the consent of a pregnancy.
+func (h *Handler) summaryConsentResponse(c *models.SummaryConsent) models.SummaryConsentResponse {
+	resp := models.SummaryConsentResponse{Available: h.summarizer != nil}
+	if resp.Available {
+		resp.MinLength = h.summaryMinLen
+	}
+	if c != nil {
+		resp.Consent = true
+		resp.ConsentedAt = &c.ConsentedAt
+	}
+	return resp
+}
+
+// GetSummaryConsent reports whether the caller's journal posts are summarized.
+func (h *Handler) GetSummaryConsent(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	c, err := h.db.GetSummaryConsent(ctx, pregnancy.ID)
+	if err != nil && err != db.ErrNotFound {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.summaryConsentResponse(c))
+}
+
+// UpdateSummaryConsent lets the owner agree to send long journal posts to the
+// summarization service, or withdraw, which deletes every summary made.
+func (h *Handler) UpdateSummaryConsent(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	var req models.SummaryConsentRequest
+	if err := decodeBody(r, &req); err != nil || req.Consent == nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "consent is required")
+		return
+	}
+
+	pregnancy, err := h.db.GetPregnancyByOwner(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only the owner can change summary consent")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if !*req.Consent {
+		if err := h.db.RevokeSummaryConsent(ctx, pregnancy.ID); err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, h.summaryConsentResponse(nil))
+		return
+	}
+
+	if h.summarizer == nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Journal summaries are not available")
+		return
+	}
+	c, err := h.db.SetSummaryConsent(ctx, pregnancy.ID, user.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.summaryConsentResponse(c))
+}
+
+// RunEntrySummaries summarizes new and edited journal posts of consenting
+// pregnancies. It never returns; start it in a goroutine.
+func (h *Handler) RunEntrySummaries() {
+	ctx := context.Background()
+	for {
+		entries, err := h.db.GetEntriesToSummarize(ctx, h.summaryMinLen, summaryBatchSize)
+		if err != nil {
+			log.Printf("Summaries: failed to list posts: %v", err)
+		}
+		for i := range entries {
+			if err = h.summarizeEntry(ctx, &entries[i]); err != nil {
+				// The service is likely down; try again next pass
+				log.Printf("Summaries: entry %d: %v", entries[i].ID, err)
+				break
+			}
+		}
+		if err != nil || len(entries) < summaryBatchSize {
+			time.Sleep(summaryInterval)
+		}
+	}
+}
+
+// summarizeEntry sends one post to the service and stores the result.
+func (h *Handler) summarizeEntry(ctx context.Context, e *models.Entry) error {
+	var data map[string]interface{}
+	json.Unmarshal(e.Data, &data)
+	title, _ := data["title"].(string)
+	content, _ := data["content"].(string)
+	if r := []rune(content); len(r) > maxSummaryInput {
+		content = string(r[:maxSummaryInput])
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	sum, err := h.summarizer.Summarize(ctx, &summarize.Post{Title: title, Text: content})
+	if err != nil {
+		return err
+	}
+	sum = summarize.Clean(sum)
+	_, err = h.db.SaveEntrySummary(ctx, e, sum.Summary, sum.Tags)
+	return err
+}
@@ -0,0 +1,46 @@
+// Package api provides optional surfaces a deployment can turn off.
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Optional surfaces, listed in DISABLED_SURFACES to turn them off.
+const (
+	// SurfaceSignedFiles is /api/signed/files: profile photos served to anyone
+	// holding a signed URL. Off, photo URLs point at the authenticated
+	// /api/files/{id}/content instead.
+	SurfaceSignedFiles = "signed-files"
+	// SurfaceWidget is the supporter web widget (/api/widget/*) and the
+	// routes that issue its tokens.
+	SurfaceWidget = "widget"
+	// SurfaceEvents is the Server-Sent Events stream (/api/sync/events).
+	SurfaceEvents = "events"
+	// SurfaceCalendarFeeds is calendar feed URLs (/api/calendar/{token}.ics)
+	// and the routes that issue them.
+	SurfaceCalendarFeeds = "calendar-feeds"
+)
+
+var surfaces = map[string]bool{SurfaceSignedFiles: true, SurfaceWidget: true, SurfaceEvents: true, SurfaceCalendarFeeds: true}
+
+// ParseSurfaces reads a comma-separated list of surfaces, rejecting unknown ones.
+func ParseSurfaces(s string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !surfaces[name] {
+			return nil, fmt.Errorf("unknown surface %q (known: %s, %s, %s, %s)", name, SurfaceSignedFiles, SurfaceWidget, SurfaceEvents, SurfaceCalendarFeeds)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// SurfaceEnabled reports whether a surface is served.
+func (h *Handler) SurfaceEnabled(name string) bool {
+	return !h.disabledSurfaces[name]
+}
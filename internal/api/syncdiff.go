@@ -0,0 +1,103 @@
+// Package api provides manifest-based sync reconciliation.
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// PostSyncDiff compares a client manifest of clientId -> updatedAt against the server
+// and returns only the entries the client is behind on, plus the clientIds the server lacks.
+func (h *Handler) PostSyncDiff(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	var req models.SyncDiffRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	manifest := make(map[string]time.Time, len(req.Manifest))
+	for clientID, updatedAt := range req.Manifest {
+		t, err := time.Parse(time.RFC3339, updatedAt)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid updatedAt for clientId "+clientID)
+			return
+		}
+		manifest[clientID] = t
+	}
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		// No pregnancy yet - everything the client has is missing on the server
+		writeNegotiated(w, r, http.StatusOK, models.SyncDiffResponse{
+			Entries:    map[string][]models.Entry{},
+			Missing:    sortedKeys(req.Manifest),
+			ServerTime: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	// Include deleted entries so tombstones reach the client
+	entries, err := h.db.GetEntries(ctx, pregnancy.ID, "", nil, nil, true)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	audience := entryAudience(pregnancy, user.UserID)
+	seen := make(map[string]bool, len(entries))
+	entriesByType := make(map[string][]models.Entry)
+	for _, e := range entries {
+		seen[e.ClientID] = true
+		clientUpdatedAt, known := manifest[e.ClientID]
+		if !canSeeEntry(audience, e.Visibility) {
+			// A copy of an entry hidden from the caller is withdrawn
+			if known {
+				entriesByType[e.EntryType] = append(entriesByType[e.EntryType], withdrawnEntry(&e))
+			}
+			continue
+		}
+		if !known {
+			// Client has never seen it; a tombstone for an unknown entry is noise
+			if e.DeletedAt.Valid {
+				continue
+			}
+		} else if !e.UpdatedAt.Truncate(time.Second).After(clientUpdatedAt) {
+			continue // RFC3339 manifest timestamps have second precision
+		}
+		entriesByType[e.EntryType] = append(entriesByType[e.EntryType], e)
+	}
+
+	missing := []string{}
+	for _, clientID := range sortedKeys(req.Manifest) {
+		if !seen[clientID] {
+			missing = append(missing, clientID)
+		}
+	}
+
+	writeNegotiated(w, r, http.StatusOK, models.SyncDiffResponse{
+		Entries:     entriesByType,
+		Missing:     missing,
+		SyncVersion: pregnancy.SyncVersion,
+		ServerTime:  time.Now().Format(time.RFC3339),
+	})
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,290 @@
+// Package api provides a Server-Sent Events feed of sync changes.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+)
+
+const (
+	// syncEventsPoll is how often a stream looks for new changes. Changes are
+	// read from the database, so writes made through any server are seen.
+	syncEventsPoll = 2 * time.Second
+	// syncEventsHeartbeat keeps idle streams alive through proxies.
+	syncEventsHeartbeat = 25 * time.Second
+	// syncEventsMaxAge ends streams so access and snooze changes apply on
+	// reconnect; EventSource reconnects with Last-Event-ID on its own.
+	syncEventsMaxAge = 10 * time.Minute
+	// syncEventsRetry is the reconnect delay sent to clients.
+	syncEventsRetry = 3 * time.Second
+	// maxSyncEventStreams caps open streams per user.
+	maxSyncEventStreams = 5
+)
+
+// Sync event names
+const (
+	syncEventEntryUpserted  = "entry.upserted"
+	syncEventEntryDeleted   = "entry.deleted"
+	syncEventSettingChanged = "setting.changed"
+	syncEventSnoozed        = "snoozed"
+	syncEventBirthPlan      = "birth_plan.changed"
+	syncEventInvalidate     = "invalidate"
+)
+
+// streamCounter counts open event streams per user. State is per process.
+type streamCounter struct {
+	mu    sync.Mutex
+	users map[string]int
+}
+
+func newStreamCounter() *streamCounter {
+	return &streamCounter{users: make(map[string]int)}
+}
+
+// acquire opens a stream for the user unless they are at the cap.
+func (c *streamCounter) acquire(userID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.users[userID] >= maxSyncEventStreams {
+		return false
+	}
+	c.users[userID]++
+	return true
+}
+
+func (c *streamCounter) release(userID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.users[userID]--; c.users[userID] <= 0 {
+		delete(c.users, userID)
+	}
+}
+
+// writeSSE writes one event. An empty id leaves the client's last event ID as is.
+func writeSSE(w io.Writer, id, event string, data interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+	return err
+}
+
+// GetSyncEvents streams entry and setting changes of the caller's pregnancy
+// as Server-Sent Events, for clients that can't use WebSockets. Birth plan
+// edits and locks are streamed to those who can see the plan. Event IDs are
+// pregnancy sync versions, which follow commit order, so a change committed
+// after a poll is never behind its cursor. Changes after the Last-Event-ID
+// header, the sinceVersion query parameter or since (RFC3339, also accepted
+// as a Last-Event-ID from older clients) are sent; without any the stream
+// starts now. Each batch's last event carries the cursor to resume from.
+func (h *Handler) GetSyncEvents(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	var cursor int64 = -1
+	var cursorAt time.Time
+	sinceVersion, set, ok := sinceVersionParam(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "sinceVersion must be a sync version")
+		return
+	}
+	if set {
+		cursor = sinceVersion
+	}
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		if v, err := strconv.ParseInt(id, 10, 64); err == nil && v >= 0 && v < legacySyncVersion {
+			cursor = v
+		} else if t, err := time.Parse(time.RFC3339Nano, id); err == nil {
+			cursor, cursorAt = -1, t
+		} else {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Last-Event-ID must be a sync version or RFC3339 timestamp")
+			return
+		}
+	}
+	if s := r.URL.Query().Get("since"); s != "" && cursor < 0 && cursorAt.IsZero() {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "since must be an RFC3339 timestamp")
+			return
+		}
+		cursorAt = t
+	}
+
+	a, err := h.resolveAccess(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	pregnancy := a.pregnancy
+
+	switch {
+	case cursor >= 0:
+	case !cursorAt.IsZero():
+		cursor, err = h.db.SyncVersionAt(ctx, pregnancy.ID, cursorAt)
+	default:
+		cursor, err = h.db.GetSyncVersion(ctx, pregnancy.ID)
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if !h.streams.acquire(user.UserID) {
+		writeError(w, http.StatusTooManyRequests, "RATE_LIMITED", fmt.Sprintf("At most %d open event streams", maxSyncEventStreams))
+		return
+	}
+	defer h.streams.release(user.UserID)
+
+	// The server's write timeout would cut the stream short
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Streaming not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Nothing is shared while the owner snoozes sharing; come back when it ends
+	if _, until, snoozed := activeSnooze(pregnancy, user.UserID, time.Now()); snoozed {
+		retry := min(time.Until(until), time.Hour)
+		fmt.Fprintf(w, "retry: %d\n", retry.Milliseconds())
+		writeSSE(w, "", syncEventSnoozed, map[string]string{"snoozedUntil": until.Format(time.RFC3339)})
+		rc.Flush()
+		return
+	}
+
+	fmt.Fprintf(w, "retry: %d\n\n", syncEventsRetry.Milliseconds())
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	poll := time.NewTicker(syncEventsPoll)
+	defer poll.Stop()
+	maxAge := time.NewTimer(syncEventsMaxAge)
+	defer maxAge.Stop()
+	lastWrite := time.Now()
+
+	for {
+		sent, next, err := h.writeSyncChanges(ctx, w, pregnancy.ID, entryAudience(pregnancy, user.UserID), canAccessBirthPlan(a.role), cursor)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Sync event stream for pregnancy %d ended: %v", pregnancy.ID, err)
+			}
+			return
+		}
+		cursor = next
+		now := time.Now()
+		if sent > 0 {
+			lastWrite = now
+		} else if now.Sub(lastWrite) >= syncEventsHeartbeat {
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return
+			}
+			lastWrite = now
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-maxAge.C:
+			return
+		case <-poll.C:
+		}
+	}
+}
+
+// writeSyncChanges writes the entries the audience may read and settings
+// changed after the sync version since, in the order they changed, along with
+// birth plan edits and locks if birthPlan is set and an invalidate hint if the
+// pregnancy profile changed. The current version is read first and changes up
+// to it are written, so a write committing meanwhile is left for the next poll
+// instead of being skipped. The last event carries that version as its ID,
+// which is returned as the next cursor.
+func (h *Handler) writeSyncChanges(ctx context.Context, w io.Writer, pregnancyID int64, audience []string, birthPlan bool, since int64) (int, int64, error) {
+	upTo, err := h.db.GetSyncVersion(ctx, pregnancyID)
+	if err != nil {
+		return 0, since, err
+	}
+	if upTo <= since {
+		return 0, since, nil
+	}
+	c, err := h.db.GetChangesBetweenVersions(ctx, pregnancyID, since, upTo, birthPlan)
+	if err != nil {
+		return 0, since, err
+	}
+	// Like sync v2 reads by version, entries hidden after a visibility change
+	// come back as tombstones
+	entries := visibleEntries(c.Entries, audience, &time.Time{})
+
+	type change struct {
+		version int64
+		event   string
+		data    interface{}
+	}
+	changes := make([]change, 0, len(entries)+len(c.Settings)+len(c.Sections)+1)
+	for i := range entries {
+		e := &entries[i]
+		event := syncEventEntryUpserted
+		if e.DeletedAt.Valid {
+			event = syncEventEntryDeleted
+		}
+		changes = append(changes, change{e.SyncVersion, event, e})
+	}
+	for i := range c.Settings {
+		s := &c.Settings[i]
+		changes = append(changes, change{s.SyncVersion, syncEventSettingChanged, s})
+	}
+	now := time.Now()
+	for i := range c.Sections {
+		s := &c.Sections[i]
+		clearExpiredLock(s, now)
+		changes = append(changes, change{s.SyncVersion, syncEventBirthPlan, s})
+	}
+	if c.Pregnancy != nil {
+		changes = append(changes, change{c.Pregnancy.ProfileVersion, syncEventInvalidate, pregnancyHint(c.Pregnancy)})
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].version < changes[j].version })
+
+	// Changes hidden from the audience still move the cursor; a batch without
+	// events ends in a bare cursor update
+	if len(changes) == 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n\n", upTo); err != nil {
+			return 0, since, err
+		}
+		return 0, upTo, nil
+	}
+	for i, c := range changes {
+		id := ""
+		if i == len(changes)-1 {
+			id = strconv.FormatInt(upTo, 10)
+		}
+		if err := writeSSE(w, id, c.event, c.data); err != nil {
+			return i, since, err
+		}
+	}
+	return len(changes), upTo, nil
+}
@@ -0,0 +1,164 @@
+// Package api provides the supporter-facing lightweight sync endpoint.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/pkg/gestation"
+)
+
+// Payload keys that survive redaction, per entry type shown to supporters.
+// Anything not listed (notes, symptoms, medical details) never leaves the server.
+var liteEntryKeys = map[string][]string{
+	"photo":        {"uri", "url", "fileId", "caption", "week", "date"},
+	"milestone":    {"title", "description", "week", "date"},
+	"announcement": {"title", "message", "date"},
+}
+
+// GetSyncLite returns the redacted subset of pregnancy data relevant to supporters.
+// Photos and milestones are included only when the owner marked them shared;
+// announcements are shared by nature.
+func (h *Handler) GetSyncLite(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	resp, err := h.liteSync(ctx, pregnancy, user.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeNegotiated(w, r, http.StatusOK, resp)
+}
+
+// liteSync builds the supporter view of a pregnancy for viewerID, which is
+// empty for widgets.
+func (h *Handler) liteSync(ctx context.Context, pregnancy *models.Pregnancy, viewerID string) (*models.LiteSyncResponse, error) {
+	resp := &models.LiteSyncResponse{
+		Progress:      weekProgress(pregnancy, time.Now()),
+		Photos:        []models.LiteEntry{},
+		Milestones:    []models.LiteEntry{},
+		Announcements: []models.LiteEntry{},
+		ServerTime:    time.Now().Format(time.RFC3339),
+	}
+	if pregnancy.BabyName.Valid {
+		resp.BabyName = pregnancy.BabyName.String
+	}
+	if pregnancy.MomName.Valid {
+		resp.MomName = pregnancy.MomName.String
+	}
+	if pregnancy.DueDate.Valid {
+		resp.DueDate = pregnancy.DueDate.Time.Format("2006-01-02")
+	}
+	if pregnancy.Outcome.Valid {
+		resp.Outcome = pregnancy.Outcome.String
+	}
+
+	if _, until, snoozed := activeSnooze(pregnancy, viewerID, time.Now()); snoozed {
+		resp.Snoozed = true
+		resp.SnoozedUntil = until.Format(time.RFC3339)
+		return resp, nil
+	}
+
+	// Photos whose file is awaiting moderation or was blocked stay hidden
+	unapproved, err := h.db.GetUnapprovedFileIDs(ctx, pregnancy.ID)
+	if err != nil {
+		return nil, err
+	}
+	altTexts, err := h.db.GetFileAltTexts(ctx, pregnancy.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	audience := entryAudience(pregnancy, viewerID)
+	for entryType := range liteEntryKeys {
+		entries, err := h.db.GetEntries(ctx, pregnancy.ID, entryType, nil, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range visibleEntries(entries, audience, nil) {
+			lite, ok := redactLiteEntry(e, unapproved, altTexts)
+			if !ok {
+				continue
+			}
+			switch entryType {
+			case "photo":
+				resp.Photos = append(resp.Photos, lite)
+			case "milestone":
+				resp.Milestones = append(resp.Milestones, lite)
+			case "announcement":
+				resp.Announcements = append(resp.Announcements, lite)
+			}
+		}
+	}
+	return resp, nil
+}
+
+// redactLiteEntry strips an entry down to its supporter-visible keys, adding
+// the alt text of the file it references. Returns false if the entry is not
+// shared with supporters or references an unapproved file.
+func redactLiteEntry(e models.Entry, unapproved map[int64]bool, altTexts map[int64]string) (models.LiteEntry, bool) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(e.Data, &payload); err != nil {
+		return models.LiteEntry{}, false
+	}
+
+	fileID, hasFile := payloadFileID(payload)
+	if hasFile && unapproved[fileID] {
+		return models.LiteEntry{}, false
+	}
+
+	if e.EntryType != "announcement" {
+		if shared, _ := payload["shared"].(bool); !shared {
+			return models.LiteEntry{}, false
+		}
+	}
+
+	data := make(map[string]interface{})
+	for _, key := range liteEntryKeys[e.EntryType] {
+		if v, ok := payload[key]; ok {
+			data[key] = v
+		}
+	}
+
+	lite := models.LiteEntry{
+		ClientID:  e.ClientID,
+		Data:      data,
+		UpdatedAt: e.UpdatedAt.Format(time.RFC3339),
+	}
+	if hasFile {
+		lite.AltText = altTexts[fileID]
+	}
+	return lite, true
+}
+
+// weekProgress computes gestational week, day and trimester from the due date
+// (or start date). Returns nil when neither date is set.
+func weekProgress(p *models.Pregnancy, now time.Time) *models.WeekProgress {
+	start, ok := pregnancyStart(p)
+	if !ok {
+		return nil
+	}
+	progress := gestation.ProgressOn(start, now)
+	return &models.WeekProgress{
+		Week:          progress.Week,
+		Day:           progress.Day,
+		DaysRemaining: progress.DaysRemaining,
+		Trimester:     progress.Trimester,
+	}
+}
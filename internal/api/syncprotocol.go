@@ -0,0 +1,87 @@
+// Package api provides sync protocol negotiation: clients name the payload
+// shapes they speak in X-Sync-Protocol, so old and new apps sync side by side
+// and apps too old to sync are told to upgrade.
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+const (
+	// syncProtocolLegacy is spoken by clients that send no X-Sync-Protocol.
+	syncProtocolLegacy = 1
+	// syncProtocolCurrent is the newest protocol the server speaks. Protocol 2
+	// leaves serverData out of entry conflicts (serverEntry has it) and refuses
+	// lastSyncVersion values in Unix milliseconds.
+	syncProtocolCurrent = 2
+)
+
+// syncProtocolKey holds the negotiated sync protocol of a request.
+const syncProtocolKey contextKey = "syncProtocol"
+
+// syncProtocolMiddleware negotiates the sync protocol of a request: the
+// client's X-Sync-Protocol, or the newest the server speaks if the client
+// speaks a newer one. Clients below the minimum get 426 UPGRADE_REQUIRED.
+// Every response names the protocol used and the supported range.
+func (h *Handler) syncProtocolMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Sync-Protocol-Min", strconv.Itoa(h.minSyncProtocol))
+		w.Header().Set("X-Sync-Protocol-Max", strconv.Itoa(syncProtocolCurrent))
+		w.Header().Add("Vary", "X-Sync-Protocol")
+
+		protocol := syncProtocolLegacy
+		if s := strings.TrimSpace(r.Header.Get("X-Sync-Protocol")); s != "" {
+			p, err := strconv.Atoi(s)
+			if err != nil || p < 1 {
+				writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "X-Sync-Protocol must be a positive integer")
+				return
+			}
+			protocol = min(p, syncProtocolCurrent)
+		}
+		if protocol < h.minSyncProtocol {
+			writeError(w, http.StatusUpgradeRequired, "UPGRADE_REQUIRED", fmt.Sprintf("Sync protocol %d is no longer supported; update the app (protocol %d or later)", protocol, h.minSyncProtocol))
+			return
+		}
+
+		w.Header().Set("X-Sync-Protocol", strconv.Itoa(protocol))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), syncProtocolKey, protocol)))
+	})
+}
+
+// syncProtocol returns the request's negotiated sync protocol, or the legacy
+// protocol outside the negotiating routes.
+func syncProtocol(ctx context.Context) int {
+	if p, ok := ctx.Value(syncProtocolKey).(int); ok {
+		return p
+	}
+	return syncProtocolLegacy
+}
+
+// validateLastSyncVersion checks a push's lastSyncVersion. Protocol 2 and
+// later only take sync versions, not the Unix milliseconds of older apps.
+func validateLastSyncVersion(ctx context.Context, lastSyncVersion int64) string {
+	if syncProtocol(ctx) >= 2 && lastSyncVersion >= legacySyncVersion {
+		return "lastSyncVersion must be a sync version"
+	}
+	return ""
+}
+
+// shapeConflicts adapts conflicts to the request's protocol: from protocol 2
+// on, entry conflicts carry the server's copy only in serverEntry.
+func shapeConflicts(ctx context.Context, conflicts []models.SyncConflict) []models.SyncConflict {
+	if syncProtocol(ctx) < 2 {
+		return conflicts
+	}
+	for i := range conflicts {
+		if conflicts[i].Kind == "entry" {
+			conflicts[i].ServerData = nil
+		}
+	}
+	return conflicts
+}
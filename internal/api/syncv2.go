@@ -0,0 +1,284 @@
+// Package api provides sync v2, which orders entry edits with per-entry vector clocks.
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/vclock"
+)
+
+// maxClockDevices caps the devices one entry clock may name.
+const maxClockDevices = 64
+
+// syncV2Enabled reports whether the user is in the sync v2 soft launch.
+func (h *Handler) syncV2Enabled(userID string) bool {
+	return softLaunched(h.syncV2Users, userID)
+}
+
+// softLaunched reports whether the user is among the users of a soft launch,
+// which may be "*" for everyone.
+func softLaunched(users []string, userID string) bool {
+	for _, id := range users {
+		if id == "*" || id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// toSyncV2Entry attaches an entry's effective clock.
+func toSyncV2Entry(e *models.Entry) (*models.SyncV2EntryDTO, error) {
+	clock, err := vclock.Parse(e.Clock)
+	if err != nil {
+		return nil, fmt.Errorf("entry %d: %w", e.ID, err)
+	}
+	return &models.SyncV2EntryDTO{Entry: *e, Clock: clock, Deleted: e.DeletedAt.Valid}, nil
+}
+
+// validateSyncV2Entry checks a pushed entry's clock. The pushing device must
+// count its own edit.
+func validateSyncV2Entry(e *models.SyncV2Entry, deviceID string) string {
+	if e.ClientID == "" || e.EntryType == "" {
+		return "clientId and entryType are required"
+	}
+	if len(e.Clock) == 0 || len(e.Clock) > maxClockDevices {
+		return fmt.Sprintf("clock must name between 1 and %d devices", maxClockDevices)
+	}
+	for device, n := range e.Clock {
+		if device == "" || n < 1 {
+			return "clock counters must be positive"
+		}
+	}
+	if e.Clock[deviceID] < 1 {
+		return "clock must include this device's edit"
+	}
+	if !e.Deleted && len(e.Data) == 0 {
+		return "data is required"
+	}
+	return ""
+}
+
+// GetSyncV2 returns entries changed since the given time with their vector
+// clocks, including deleted ones as tombstones, plus settings as in v1.
+func (h *Handler) GetSyncV2(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	if !h.syncV2Enabled(user.UserID) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Sync v2 is not enabled for this account")
+		return
+	}
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeNegotiated(w, r, http.StatusOK, models.SyncV2Response{
+			Entries:    []models.SyncV2EntryDTO{},
+			ServerTime: time.Now().Format(time.RFC3339),
+		})
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	sinceVersion, bySinceVersion, ok := sinceVersionParam(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "sinceVersion must be a sync version")
+		return
+	}
+
+	// Withhold everything while sharing is snoozed, as in v1
+	if start, until, snoozed := activeSnooze(pregnancy, user.UserID, time.Now()); snoozed {
+		writeNegotiated(w, r, http.StatusOK, models.SyncV2Response{
+			Pregnancy:    h.toPregnancyDTO(pregnancy),
+			Entries:      []models.SyncV2EntryDTO{},
+			SyncVersion:  sinceVersion,
+			ServerTime:   start.Format(time.RFC3339),
+			Snoozed:      true,
+			SnoozedUntil: until.Format(time.RFC3339),
+		})
+		return
+	}
+
+	var since *time.Time
+	if t, err := time.Parse(time.RFC3339, r.URL.Query().Get("since")); err == nil {
+		since = &t
+	}
+
+	// Read the sync time first so nothing written during the reads is skipped
+	// next time; the sync version was read with the pregnancy
+	now := time.Now()
+	var entries []models.Entry
+	if bySinceVersion {
+		entries, err = h.db.GetEntriesSinceVersion(ctx, pregnancy.ID, sinceVersion)
+		since = &time.Time{}
+	} else {
+		entries, err = h.db.GetEntries(ctx, pregnancy.ID, "", since, nil, true)
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	entries = visibleEntries(entries, entryAudience(pregnancy, user.UserID), since)
+	dtos := make([]models.SyncV2EntryDTO, 0, len(entries))
+	for i := range entries {
+		dto, err := toSyncV2Entry(&entries[i])
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		dtos = append(dtos, *dto)
+	}
+
+	settings, err := h.db.GetSettings(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	settingVersions, err := h.db.GetSettingVersions(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	settingRevisions, err := h.db.GetSettingRevisionIDs(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeNegotiated(w, r, http.StatusOK, models.SyncV2Response{
+		Pregnancy:        h.toPregnancyDTO(pregnancy),
+		Entries:          dtos,
+		Settings:         settings,
+		SettingVersions:  settingVersions,
+		SettingRevisions: settingRevisions,
+		SyncVersion:      pregnancy.SyncVersion,
+		ServerTime:       now.Format(time.RFC3339),
+	})
+}
+
+// PostSyncV2 pushes entry edits with their vector clocks. Each entry is
+// ordered against the server's version on its own: newer edits are applied,
+// stale ones ignored, and concurrent ones merged when the entry type allows it
+// or reported as conflicts. Entries that end up different from what the client
+// sent come back in the results. The pregnancy must already exist.
+func (h *Handler) PostSyncV2(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	if !h.syncV2Enabled(user.UserID) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Sync v2 is not enabled for this account")
+		return
+	}
+
+	var req models.SyncV2Request
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if req.DeviceID == "" || req.DeviceID == vclock.V1Device {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "deviceId is required and may not be v1")
+		return
+	}
+	now := time.Now()
+	pushed := make([]models.EntryRequest, 0, len(req.Entries))
+	for i := range req.Entries {
+		e := &req.Entries[i]
+		msg := validateSyncV2Entry(e, req.DeviceID)
+		if msg == "" && !e.Deleted {
+			msg = validateDataVersion(&e.EntryRequest)
+			if msg == "" {
+				msg = validateOccurredAt(&e.EntryRequest, now)
+			}
+			if msg == "" {
+				msg = validateScheduledEntry(&e.EntryRequest)
+			}
+		}
+		if msg != "" {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Entry %d: %s", i, msg))
+			return
+		}
+		pushed = append(pushed, e.EntryRequest)
+	}
+	h.noteUnknownDataVersions(r, pushed)
+
+	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return
+	}
+	audience := entryAudience(pregnancy, user.UserID)
+	for i := range req.Entries {
+		if msg := validateVisibility(&req.Entries[i].EntryRequest, audience); msg != "" {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Entry %d: %s", i, msg))
+			return
+		}
+	}
+
+	if req.Pregnancy != nil {
+		if _, err := h.db.UpdatePregnancy(ctx, pregnancy.ID, req.Pregnancy); err != nil {
+			writeDomainError(w, err)
+			return
+		}
+	}
+
+	results := make([]models.SyncV2EntryResult, 0, len(req.Entries))
+	for i := range req.Entries {
+		e := &req.Entries[i]
+		entry, outcome, fields, err := h.db.SyncEntryV2(ctx, pregnancy.ID, e, audience)
+		if err == db.ErrNotFound {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Entry %d: not found", i))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", fmt.Sprintf("Entry %d: %v", i, err))
+			return
+		}
+		result := models.SyncV2EntryResult{
+			ClientID:  e.ClientID,
+			EntryType: e.EntryType,
+			Outcome:   outcome,
+			Fields:    fields,
+		}
+		if outcome != models.SyncV2Applied {
+			if !canSeeEntry(audience, entry.Visibility) {
+				withdrawn := withdrawnEntry(entry)
+				entry = &withdrawn
+			}
+			if result.Entry, err = toSyncV2Entry(entry); err != nil {
+				writeDomainError(w, err)
+				return
+			}
+		}
+		results = append(results, result)
+	}
+
+	conflicts, settingVersions, err := h.syncSettings(ctx, pregnancy.ID, req.Settings, req.SettingsPatch)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	syncVersion := h.syncVersionAfter(ctx, pregnancy)
+	h.db.UpdateSyncState(ctx, user.UserID, req.DeviceID, syncVersion)
+
+	writeNegotiated(w, r, http.StatusOK, models.SyncV2PushResponse{
+		Results:         results,
+		Conflicts:       conflicts,
+		SettingVersions: settingVersions,
+		SyncVersion:     syncVersion,
+	})
+}
@@ -0,0 +1,56 @@
+// Package api provides the monotonic per-pregnancy sync versions returned by
+// every sync and entries endpoint.
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// legacySyncVersion is the smallest lastSyncVersion read as Unix milliseconds:
+// clients from before sync versions send the time of their last sync.
+const legacySyncVersion = 1e12
+
+// syncBase is the base of a push made by a client that synced up to
+// lastSyncVersion.
+func syncBase(lastSyncVersion int64) db.SyncBase {
+	switch {
+	case lastSyncVersion >= legacySyncVersion:
+		return db.SyncBase{At: time.UnixMilli(lastSyncVersion)}
+	case lastSyncVersion > 0:
+		return db.SyncBase{Version: lastSyncVersion}
+	}
+	return db.SyncBase{}
+}
+
+// sinceVersionParam reads the sinceVersion query parameter, the sync version a
+// client last synced up to. ok is false when it is set but invalid.
+func sinceVersionParam(r *http.Request) (version int64, set, ok bool) {
+	s := r.URL.Query().Get("sinceVersion")
+	if s == "" {
+		return 0, false, true
+	}
+	version, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || version < 0 || version >= legacySyncVersion {
+		return 0, true, false
+	}
+	return version, true, true
+}
+
+// syncVersionAfter returns the pregnancy's sync version after a write. If it
+// can't be read, the version loaded with the pregnancy is returned instead:
+// an older version only makes the client fetch some changes again.
+func (h *Handler) syncVersionAfter(ctx context.Context, pregnancy *models.Pregnancy) int64 {
+	version, err := h.db.GetSyncVersion(ctx, pregnancy.ID)
+	if err != nil {
+		log.Printf("Failed to read sync version of pregnancy %d: %v", pregnancy.ID, err)
+		return pregnancy.SyncVersion
+	}
+	return version
+}
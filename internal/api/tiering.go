@@ -0,0 +1,272 @@
+// Package api provides cold storage tiering for archived pregnancies' files.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/storage"
+)
+
+// Tiering runs hourly and moves at most this many files per query.
+const (
+	tieringInterval  = time.Hour
+	tieringBatchSize = 100
+)
+
+// restoreFilesResult is stored as the restore job's result.
+type restoreFilesResult struct {
+	Restored int `json:"restored"`
+	Failed   int `json:"failed"`
+}
+
+// filePath resolves where a stored file currently lives, in either tier.
+func (h *Handler) filePath(file *models.File) (string, error) {
+	return h.storage.TierPath(file.Region, file.StorageTier, file.StoragePath)
+}
+
+// RunTiering moves files of pregnancies archived longer than after to cold
+// storage, once per interval. It never returns.
+func (h *Handler) RunTiering(after time.Duration) {
+	for {
+		h.tierArchivedFiles(after)
+		time.Sleep(tieringInterval)
+	}
+}
+
+func (h *Handler) tierArchivedFiles(after time.Duration) {
+	ctx := context.Background()
+	regions := h.storage.ColdRegions()
+	moved := 0
+	for {
+		files, err := h.db.GetFilesToTier(ctx, time.Now().Add(-after), regions, tieringBatchSize)
+		if err != nil {
+			log.Printf("Tiering: failed to list files: %v", err)
+			return
+		}
+
+		progress := false
+		for i := range files {
+			if err := h.moveFile(ctx, &files[i], storage.TierHot, storage.TierCold); err != nil {
+				log.Printf("Tiering: file %d: %v", files[i].ID, err)
+				continue
+			}
+			moved++
+			progress = true
+		}
+		// Stop when done, or when a whole batch keeps failing
+		if len(files) < tieringBatchSize || !progress {
+			break
+		}
+	}
+	if moved > 0 {
+		log.Printf("Tiering: moved %d file(s) to cold storage", moved)
+	}
+}
+
+// moveFile copies a file to the other tier, records the move, then removes the
+// old copy. A crash in between leaves a stray copy, never a missing file.
+func (h *Handler) moveFile(ctx context.Context, file *models.File, from, to string) error {
+	if err := h.storage.Copy(file.Region, file.StoragePath, from, to); err != nil {
+		return err
+	}
+	if err := h.db.SetFileTier(ctx, file.ID, to); err != nil {
+		h.storage.Remove(file.Region, to, file.StoragePath)
+		return err
+	}
+	if err := h.storage.Remove(file.Region, from, file.StoragePath); err != nil {
+		log.Printf("Tiering: file %d moved to %s but the %s copy remains: %v", file.ID, to, from, err)
+	}
+	return nil
+}
+
+// RestorePregnancyFiles starts moving a pregnancy's cold files back to hot
+// storage, so a big download doesn't read everything from the slow tier.
+func (h *Handler) RestorePregnancyFiles(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, ok := h.getFilesPregnancy(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.db.CreateJob(ctx, pregnancy.ID, user.UserID, "restore_files")
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	ticket := h.heavy.enqueue(user.UserID, job.ID)
+	go func() {
+		<-ticket.ready
+		defer h.heavy.release(user.UserID)
+		h.runRestoreFilesJob(job.ID, pregnancy.ID)
+	}()
+
+	writeJSON(w, http.StatusAccepted, models.RestoreFilesResponse{
+		JobID:         job.ID,
+		Status:        job.Status,
+		Progress:      job.Progress,
+		QueuePosition: h.heavy.jobPosition(user.UserID, job.ID),
+	})
+}
+
+// GetRestoreFilesJob reports restore progress.
+func (h *Handler) GetRestoreFilesJob(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	pregnancy, ok := h.getFilesPregnancy(w, r)
+	if !ok {
+		return
+	}
+	jobID, err := strconv.ParseInt(mux.Vars(r)["jobId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid job ID")
+		return
+	}
+
+	job, err := h.db.GetJob(r.Context(), jobID, user.UserID)
+	if err == db.ErrNotFound || (err == nil && (job.Kind != "restore_files" || job.PregnancyID != pregnancy.ID)) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Restore job not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	resp := models.RestoreFilesResponse{
+		JobID:         job.ID,
+		Status:        job.Status,
+		Progress:      job.Progress,
+		QueuePosition: h.heavy.jobPosition(user.UserID, job.ID),
+		Error:         job.Error.String,
+	}
+	if job.Status == models.JobStatusCompleted {
+		var result restoreFilesResult
+		if err := json.Unmarshal(job.Result, &result); err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		resp.Restored = result.Restored
+		resp.Failed = result.Failed
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) runRestoreFilesJob(jobID, pregnancyID int64) {
+	ctx := context.Background()
+
+	files, err := h.db.GetColdFiles(ctx, pregnancyID)
+	if err != nil {
+		log.Printf("Restore job %d failed: %v", jobID, err)
+		h.db.FailJob(ctx, jobID, err.Error())
+		return
+	}
+
+	var result restoreFilesResult
+	for i := range files {
+		if err := h.moveFile(ctx, &files[i], storage.TierCold, storage.TierHot); err != nil {
+			log.Printf("Restore job %d: file %d: %v", jobID, files[i].ID, err)
+			result.Failed++
+		} else {
+			result.Restored++
+		}
+		h.db.UpdateJobProgress(ctx, jobID, (i+1)*100/len(files))
+	}
+
+	data, _ := json.Marshal(result)
+	if err := h.db.CompleteJob(ctx, jobID, data); err != nil {
+		log.Printf("Restore job %d: failed to save result: %v", jobID, err)
+	}
+}
+
+// GetFileContent serves a file from whichever tier holds it. Cold files are
+// slower to read but need no restore first.
+func (h *Handler) GetFileContent(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	fileID, err := strconv.ParseInt(mux.Vars(r)["fileId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "File not found")
+		return
+	}
+
+	file, err := h.db.GetFile(ctx, fileID)
+	if err == db.ErrNotFound || (err == nil && file.DeletedAt.Valid) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "File not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	pregnancy, err := h.db.GetPregnancyByID(ctx, file.PregnancyID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if !canAccessFiles(pregnancy, user.UserID) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Access denied")
+		return
+	}
+
+	path, err := h.filePath(file)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if file.MimeType.Valid {
+		w.Header().Set("Content-Type", file.MimeType.String)
+	}
+	if file.ContentHash.Valid {
+		w.Header().Set("ETag", `"`+file.ContentHash.String+`"`)
+		// ?v=<contentHash> names these exact bytes, so that URL never changes
+		if r.URL.Query().Get("v") == file.ContentHash.String {
+			w.Header().Set("Cache-Control", CacheImmutable)
+		}
+	}
+	http.ServeFile(w, r, path)
+}
+
+// getFilesPregnancy loads the {id} pregnancy for the owner or approved partner.
+func (h *Handler) getFilesPregnancy(w http.ResponseWriter, r *http.Request) (*models.Pregnancy, bool) {
+	user := getUserInfo(r)
+	pregnancyID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid pregnancy ID")
+		return nil, false
+	}
+
+	pregnancy, err := h.db.GetPregnancyByID(r.Context(), pregnancyID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Pregnancy not found")
+		return nil, false
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return nil, false
+	}
+	if !canAccessFiles(pregnancy, user.UserID) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Access denied")
+		return nil, false
+	}
+	return pregnancy, true
+}
+
+// canAccessFiles reports whether the user may read the pregnancy's files: the
+// owner and the approved partner.
+func canAccessFiles(p *models.Pregnancy, userID string) bool {
+	return p.OwnerID == userID || (p.PartnerID.Valid && p.PartnerID.String == userID && p.PartnerStatus.String == "approved")
+}
@@ -0,0 +1,90 @@
+// Package api provides the tamper-evident timeline export handlers.
+package api
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/timeline"
+)
+
+// GetTimelineExport streams every entry revision of a pregnancy as hash-chained,
+// signed JSON lines. Only the owner or coowner can export.
+func (h *Handler) GetTimelineExport(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	pregnancyID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid pregnancy ID")
+		return
+	}
+
+	pregnancy, err := h.db.GetPregnancyByID(ctx, pregnancyID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Pregnancy not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if pregnancy.OwnerID != user.UserID && !(pregnancy.CoownerID.Valid && pregnancy.CoownerID.String == user.UserID) {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only owner can export the timeline")
+		return
+	}
+
+	revisions, err := h.db.GetEntryRevisions(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="timeline-%d-%s.jsonl"`, pregnancy.ID, now.Format("20060102")))
+	w.WriteHeader(http.StatusOK)
+
+	// Headers are sent, so failures past this point can only be logged; the
+	// missing footer makes a cut-off export fail verification.
+	tw := timeline.NewWriter(w, h.timelineKey)
+	err = tw.Write(timeline.KindHeader, timeline.Header{
+		Format:      timeline.Format,
+		PregnancyID: pregnancy.ID,
+		GeneratedAt: now.Format(time.RFC3339),
+		PublicKey:   h.timelinePublicKey(),
+	})
+	for i := 0; err == nil && i < len(revisions); i++ {
+		err = tw.Write(timeline.KindRevision, revisions[i])
+	}
+	if err == nil {
+		err = tw.Write(timeline.KindFooter, timeline.Footer{Revisions: int64(len(revisions))})
+	}
+	if err == nil {
+		err = tw.Flush()
+	}
+	if err != nil {
+		log.Printf("Failed to write timeline export: %v", err)
+	}
+}
+
+// GetTimelinePublicKey returns the key that verifies timeline export signatures.
+func (h *Handler) GetTimelinePublicKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", CacheStatic)
+	writeJSON(w, http.StatusOK, map[string]string{
+		"format":    timeline.Format,
+		"publicKey": h.timelinePublicKey(),
+	})
+}
+
+func (h *Handler) timelinePublicKey() string {
+	return base64.StdEncoding.EncodeToString(h.timelineKey.Public().(ed25519.PublicKey))
+}
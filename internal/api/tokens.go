@@ -0,0 +1,143 @@
+// Package api provides personal access tokens for user automation.
+package api
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/auth"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// personalTokenPrefix marks personal access tokens so AuthMiddleware can tell them from JWTs.
+const personalTokenPrefix = "t2p_"
+
+// Personal access token limits
+const (
+	maxPersonalTokens    = 20
+	maxTokenNameLen      = 100
+	maxTokenLifetimeDays = 3650
+)
+
+// authenticatePersonalToken resolves a personal access token to the user it acts for.
+// The route registry limits read-only tokens to read routes.
+func (h *Handler) authenticatePersonalToken(r *http.Request, token string) (*auth.UserInfo, int, string) {
+	t, err := h.db.GetActivePersonalToken(r.Context(), sha256Hex(token))
+	if err == db.ErrNotFound {
+		return nil, http.StatusUnauthorized, "Invalid or revoked token"
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err.Error()
+	}
+
+	info := &auth.UserInfo{UserID: t.UserID, TokenID: t.ID, Scope: t.Scope}
+	if t.ExpiresAt.Valid {
+		info.ExpiresAt = t.ExpiresAt.Time
+	}
+	return info, 0, ""
+}
+
+// GetPersonalTokens lists the user's personal access tokens.
+func (h *Handler) GetPersonalTokens(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	tokens, err := h.db.GetPersonalTokens(r.Context(), user.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if tokens == nil {
+		tokens = []models.PersonalToken{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tokens": tokens})
+}
+
+// CreatePersonalToken creates a token. The secret is returned once and only its hash is kept.
+func (h *Handler) CreatePersonalToken(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	var req models.PersonalTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxTokenNameLen {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name is required (max 100 characters)")
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = models.TokenScopeRead
+	}
+	if req.Scope != models.TokenScopeRead && req.Scope != models.TokenScopeWrite {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "scope must be read or write")
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays != nil {
+		if *req.ExpiresInDays < 1 || *req.ExpiresInDays > maxTokenLifetimeDays {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "expiresInDays must be between 1 and 3650")
+			return
+		}
+		t := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	count, err := h.db.CountActivePersonalTokens(ctx, user.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if count >= maxPersonalTokens {
+		writeError(w, http.StatusConflict, "CONFLICT", "Too many active tokens; revoke one first")
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to generate token")
+		return
+	}
+	token := personalTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	created, err := h.db.CreatePersonalToken(ctx, user.UserID, req.Name, sha256Hex(token), token[:len(personalTokenPrefix)+6], req.Scope, expiresAt)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.PersonalTokenResponse{PersonalToken: *created, Token: token})
+}
+
+// RevokePersonalToken revokes one of the user's tokens immediately.
+func (h *Handler) RevokePersonalToken(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	tokenID, err := strconv.ParseInt(mux.Vars(r)["tokenId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid token ID")
+		return
+	}
+
+	err = h.db.RevokePersonalToken(r.Context(), tokenID, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Token not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
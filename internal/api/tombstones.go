@@ -0,0 +1,52 @@
+// Package api provides compaction of old entry tombstones.
+package api
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+const (
+	// tombstoneCompactionInterval is how often old tombstones are removed.
+	tombstoneCompactionInterval = 6 * time.Hour
+	// tombstoneBatch bounds each delete so it doesn't hold row locks for long.
+	tombstoneBatch = 1000
+)
+
+// RunTombstoneCompaction hard-deletes entries soft-deleted more than retention
+// ago, so full syncs stop shipping them. Pregnancies that lost tombstones
+// report the cutoff as compactedBefore in sync responses. It never returns;
+// start it in a goroutine.
+func (h *Handler) RunTombstoneCompaction(retention time.Duration) {
+	for {
+		before := time.Now().Add(-retention)
+		var total int64
+		for {
+			deleted, err := h.db.CompactTombstones(context.Background(), before, tombstoneBatch)
+			if err != nil {
+				log.Printf("Tombstone compaction: %v", err)
+				break
+			}
+			total += deleted
+			if deleted < tombstoneBatch {
+				break
+			}
+		}
+		if total > 0 {
+			log.Printf("Tombstone compaction: deleted %d entries deleted before %s", total, before.Format(time.RFC3339))
+		}
+		time.Sleep(tombstoneCompactionInterval)
+	}
+}
+
+// compactedBefore returns the pregnancy's tombstone watermark for sync
+// responses, or "" when nothing was compacted.
+func compactedBefore(p *models.Pregnancy) string {
+	if !p.TombstonesCompactedBefore.Valid {
+		return ""
+	}
+	return p.TombstonesCompactedBefore.Time.UTC().Format(time.RFC3339)
+}
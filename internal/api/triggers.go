@@ -0,0 +1,474 @@
+// Package api provides entry triggers: webhooks (smart home lights,
+// IFTTT-style applets) called when an entry of a type is created and its data
+// matches a condition.
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/webhook"
+)
+
+const (
+	// maxEntryTriggers caps the triggers of one pregnancy.
+	maxEntryTriggers = 10
+	// maxTriggerNameLen caps a trigger's name.
+	maxTriggerNameLen = 100
+	// triggerInterval is how often queued deliveries are looked for.
+	triggerInterval = 10 * time.Second
+	// triggerBatchSize caps the deliveries sent per pass.
+	triggerBatchSize = 50
+	// maxTriggerAttempts is how often a delivery is tried before it fails.
+	maxTriggerAttempts = 3
+	// triggerRetryDelay is the wait before a second attempt; it doubles after.
+	triggerRetryDelay = time.Minute
+	// triggerHistoryLimit caps the deliveries listed per trigger.
+	triggerHistoryLimit = 50
+	// triggerHistoryRetention is how long finished deliveries are kept.
+	triggerHistoryRetention = 30 * 24 * time.Hour
+)
+
+// triggerOps are the condition operators.
+var triggerOps = map[string]bool{"eq": true, "ne": true, "gt": true, "lt": true, "contains": true, "exists": true}
+
+// entryTriggersEnabled reports whether the user is in the entry trigger soft
+// launch.
+func (h *Handler) entryTriggersEnabled(userID string) bool {
+	return h.webhooks != nil && softLaunched(h.triggerUsers, userID)
+}
+
+// getTriggerPregnancy returns the pregnancy whose triggers the caller manages,
+// writing the error response if there is none. Owner and coowner only, since
+// deliveries carry entries of every visibility.
+func (h *Handler) getTriggerPregnancy(w http.ResponseWriter, r *http.Request) (*models.Pregnancy, bool) {
+	user := getUserInfo(r)
+	if !h.entryTriggersEnabled(user.UserID) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Entry triggers are not enabled for this account")
+		return nil, false
+	}
+	pregnancy, _, err := h.getAccessiblePregnancy(r.Context(), user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return nil, false
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return nil, false
+	}
+	if entryAudience(pregnancy, user.UserID) != nil {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only the owner can manage entry triggers")
+		return nil, false
+	}
+	return pregnancy, true
+}
+
+// getTrigger loads the trigger named in the path, writing the error response
+// if it isn't one of the pregnancy's.
+func (h *Handler) getTrigger(w http.ResponseWriter, r *http.Request, pregnancyID int64) (*models.EntryTrigger, bool) {
+	triggerID, err := strconv.ParseInt(mux.Vars(r)["triggerId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid trigger ID")
+		return nil, false
+	}
+	t, err := h.db.GetEntryTrigger(r.Context(), pregnancyID, triggerID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Trigger not found")
+		return nil, false
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return nil, false
+	}
+	return t, true
+}
+
+// validateTrigger checks a trigger request and fills in t from it.
+func validateTrigger(req *models.EntryTriggerRequest, t *models.EntryTrigger) string {
+	t.Name = strings.TrimSpace(req.Name)
+	if t.Name == "" || len(t.Name) > maxTriggerNameLen {
+		return "name is required (max 100 characters)"
+	}
+	if req.EntryType == "" {
+		return "entryType is required"
+	}
+	t.EntryType = req.EntryType
+	if err := webhook.ValidateURL(req.URL); err != nil {
+		if err == webhook.ErrPrivateAddress {
+			return "url must point to a public host"
+		}
+		return err.Error()
+	}
+	t.URL = req.URL
+
+	t.Condition = nil
+	if c := req.Condition; c != nil {
+		if c.Field == "" || len(c.Field) > 100 {
+			return "condition.field is required (max 100 characters)"
+		}
+		if !triggerOps[c.Op] {
+			return "condition.op must be eq, ne, gt, lt, contains or exists"
+		}
+		var value interface{}
+		if c.Op != "exists" && (len(c.Value) == 0 || json.Unmarshal(c.Value, &value) != nil || value == nil) {
+			return "condition.value is required"
+		}
+		if _, ok := value.(float64); (c.Op == "gt" || c.Op == "lt") && !ok {
+			return "condition.value must be a number for gt and lt"
+		}
+		if c.Op == "exists" {
+			c.Value = nil
+		}
+		t.Condition, _ = json.Marshal(c)
+	}
+
+	t.Enabled = req.Enabled == nil || *req.Enabled
+	return ""
+}
+
+// conditionMatches reports whether entry data meets a trigger's condition.
+// Every entry meets the null condition.
+func conditionMatches(condition, data json.RawMessage) bool {
+	if len(condition) == 0 || string(condition) == "null" {
+		return true
+	}
+	var c models.TriggerCondition
+	if json.Unmarshal(condition, &c) != nil {
+		return false
+	}
+	var got interface{}
+	json.Unmarshal(data, &got)
+	for _, key := range strings.Split(c.Field, ".") {
+		obj, ok := got.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		got = obj[key]
+	}
+	if c.Op == "exists" {
+		return got != nil
+	}
+	var want interface{}
+	json.Unmarshal(c.Value, &want)
+
+	switch c.Op {
+	case "eq":
+		return jsonEqual(got, want)
+	case "ne":
+		return got != nil && !jsonEqual(got, want)
+	case "gt", "lt":
+		g, ok1 := got.(float64)
+		v, ok2 := want.(float64)
+		return ok1 && ok2 && (c.Op == "gt" && g > v || c.Op == "lt" && g < v)
+	case "contains":
+		switch g := got.(type) {
+		case string:
+			s, ok := want.(string)
+			return ok && strings.Contains(strings.ToLower(g), strings.ToLower(s))
+		case []interface{}:
+			for _, item := range g {
+				if jsonEqual(item, want) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// jsonEqual compares decoded JSON values, strings case-insensitively.
+func jsonEqual(a, b interface{}) bool {
+	if s, ok := a.(string); ok {
+		t, ok := b.(string)
+		return ok && strings.EqualFold(s, t)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toTriggerDeliveryDTO formats a delivery for its trigger's history.
+func toTriggerDeliveryDTO(d *models.TriggerDelivery) models.TriggerDeliveryDTO {
+	dto := models.TriggerDeliveryDTO{
+		ID:        d.ID,
+		ClientID:  d.ClientID.String,
+		Test:      d.Test,
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+		Error:     d.Error.String,
+		CreatedAt: d.CreatedAt.Format(time.RFC3339),
+	}
+	if d.ResponseCode.Valid {
+		code := int(d.ResponseCode.Int32)
+		dto.ResponseCode = &code
+	}
+	if d.DeliveredAt.Valid {
+		at := d.DeliveredAt.Time.Format(time.RFC3339)
+		dto.DeliveredAt = &at
+	}
+	return dto
+}
+
+// GetEntryTriggers lists the pregnancy's entry triggers (never the secret).
+func (h *Handler) GetEntryTriggers(w http.ResponseWriter, r *http.Request) {
+	pregnancy, ok := h.getTriggerPregnancy(w, r)
+	if !ok {
+		return
+	}
+	triggers, err := h.db.GetEntryTriggers(r.Context(), pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if triggers == nil {
+		triggers = []models.EntryTrigger{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"triggers": triggers})
+}
+
+// CreateEntryTrigger creates a trigger. Its signing secret is returned once.
+func (h *Handler) CreateEntryTrigger(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+	pregnancy, ok := h.getTriggerPregnancy(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.EntryTriggerRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	t := models.EntryTrigger{PregnancyID: pregnancy.ID, CreatedBy: user.UserID}
+	if msg := validateTrigger(&req, &t); msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+
+	count, err := h.db.CountEntryTriggers(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if count >= maxEntryTriggers {
+		writeError(w, http.StatusConflict, "CONFLICT", fmt.Sprintf("At most %d triggers; delete one first", maxEntryTriggers))
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to generate secret")
+		return
+	}
+	t.Secret = base64.RawURLEncoding.EncodeToString(secret)
+
+	created, err := h.db.CreateEntryTrigger(ctx, &t)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, models.EntryTriggerResponse{EntryTrigger: *created, Secret: created.Secret})
+}
+
+// UpdateEntryTrigger replaces a trigger's settings; its secret is kept.
+func (h *Handler) UpdateEntryTrigger(w http.ResponseWriter, r *http.Request) {
+	pregnancy, ok := h.getTriggerPregnancy(w, r)
+	if !ok {
+		return
+	}
+	t, ok := h.getTrigger(w, r, pregnancy.ID)
+	if !ok {
+		return
+	}
+
+	var req models.EntryTriggerRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if msg := validateTrigger(&req, t); msg != "" {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", msg)
+		return
+	}
+
+	updated, err := h.db.UpdateEntryTrigger(r.Context(), t)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Trigger not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// DeleteEntryTrigger deletes a trigger, its history and queued deliveries.
+func (h *Handler) DeleteEntryTrigger(w http.ResponseWriter, r *http.Request) {
+	pregnancy, ok := h.getTriggerPregnancy(w, r)
+	if !ok {
+		return
+	}
+	triggerID, err := strconv.ParseInt(mux.Vars(r)["triggerId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid trigger ID")
+		return
+	}
+
+	err = h.db.DeleteEntryTrigger(r.Context(), pregnancy.ID, triggerID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Trigger not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestEntryTrigger calls the trigger's webhook right away with a test payload,
+// even if the trigger is disabled, and records the outcome in its history.
+// Sample data in the body is sent along and checked against the condition.
+func (h *Handler) TestEntryTrigger(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	pregnancy, ok := h.getTriggerPregnancy(w, r)
+	if !ok {
+		return
+	}
+	t, ok := h.getTrigger(w, r, pregnancy.ID)
+	if !ok {
+		return
+	}
+
+	var req models.TriggerTestRequest
+	if r.ContentLength != 0 {
+		if err := decodeBody(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+			return
+		}
+	}
+
+	var resp models.TriggerTestResponse
+	if len(req.Data) > 0 {
+		matches := conditionMatches(t.Condition, req.Data)
+		resp.Matches = &matches
+	}
+	code, sendErr := h.sendTrigger(ctx, t, &models.TriggerPayload{EntryType: t.EntryType, Data: req.Data, Test: true})
+	errMsg := ""
+	if sendErr != nil {
+		errMsg = sendErr.Error()
+	}
+
+	delivery, err := h.db.RecordTestDelivery(ctx, t.ID, code, errMsg)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	resp.Delivery = toTriggerDeliveryDTO(delivery)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GetTriggerDeliveries lists a trigger's latest deliveries, newest first.
+func (h *Handler) GetTriggerDeliveries(w http.ResponseWriter, r *http.Request) {
+	pregnancy, ok := h.getTriggerPregnancy(w, r)
+	if !ok {
+		return
+	}
+	t, ok := h.getTrigger(w, r, pregnancy.ID)
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.db.GetTriggerDeliveries(r.Context(), t.ID, triggerHistoryLimit)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	dtos := make([]models.TriggerDeliveryDTO, len(deliveries))
+	for i := range deliveries {
+		dtos[i] = toTriggerDeliveryDTO(&deliveries[i])
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deliveries": dtos})
+}
+
+// sendTrigger posts a payload to the trigger's webhook.
+func (h *Handler) sendTrigger(ctx context.Context, t *models.EntryTrigger, payload *models.TriggerPayload) (int, error) {
+	payload.TriggerID = t.ID
+	payload.TriggerName = t.Name
+	payload.FiredAt = time.Now().UTC().Format(time.RFC3339)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	return h.webhooks.Send(ctx, t.URL, t.Secret, body)
+}
+
+// RunEntryTriggers sends the deliveries queued when entries are created,
+// retrying failed ones, and prunes old history. It never returns; start it in
+// a goroutine.
+func (h *Handler) RunEntryTriggers() {
+	ctx := context.Background()
+	lastPrune := time.Time{}
+	for {
+		if time.Since(lastPrune) > 24*time.Hour {
+			if _, err := h.db.PruneTriggerDeliveries(ctx, time.Now().Add(-triggerHistoryRetention)); err != nil {
+				log.Printf("Entry triggers: failed to prune deliveries: %v", err)
+			}
+			lastPrune = time.Now()
+		}
+
+		deliveries, err := h.db.GetDueTriggerDeliveries(ctx, triggerBatchSize)
+		if err != nil {
+			log.Printf("Entry triggers: failed to list deliveries: %v", err)
+		}
+		for i := range deliveries {
+			if err := h.deliverTrigger(ctx, &deliveries[i]); err != nil {
+				log.Printf("Entry triggers: delivery %d: %v", deliveries[i].ID, err)
+			}
+		}
+		if err != nil || len(deliveries) < triggerBatchSize {
+			time.Sleep(triggerInterval)
+		}
+	}
+}
+
+// deliverTrigger sends one queued delivery, or drops it if the trigger was
+// disabled, its creator left the soft launch or the entry doesn't match.
+func (h *Handler) deliverTrigger(ctx context.Context, d *models.TriggerDelivery) error {
+	t, err := h.db.GetEntryTriggerByID(ctx, d.TriggerID)
+	if err != nil {
+		return err
+	}
+	e, err := h.db.GetEntryByID(ctx, d.EntryID.Int64)
+	if err == db.ErrNotFound {
+		return h.db.DropTriggerDelivery(ctx, d.ID)
+	}
+	if err != nil {
+		return err
+	}
+	if !t.Enabled || !h.entryTriggersEnabled(t.CreatedBy) || e.DeletedAt.Valid || !conditionMatches(t.Condition, e.Data) {
+		return h.db.DropTriggerDelivery(ctx, d.ID)
+	}
+
+	code, sendErr := h.sendTrigger(ctx, t, &models.TriggerPayload{EntryType: e.EntryType, ClientID: e.ClientID, Data: e.Data})
+	if sendErr == nil {
+		return h.db.FinishTriggerDelivery(ctx, d.ID, models.TriggerDeliveryDelivered, code, "", nil)
+	}
+	if d.Attempts+1 >= maxTriggerAttempts {
+		return h.db.FinishTriggerDelivery(ctx, d.ID, models.TriggerDeliveryFailed, code, sendErr.Error(), nil)
+	}
+	retryAt := time.Now().Add(triggerRetryDelay << d.Attempts)
+	return h.db.FinishTriggerDelivery(ctx, d.ID, models.TriggerDeliveryPending, code, sendErr.Error(), &retryAt)
+}
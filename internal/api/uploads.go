@@ -0,0 +1,158 @@
+// Package api provides multi-file uploads.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+const (
+	// maxBatchFiles caps the files in one batch upload.
+	maxBatchFiles = 25
+	// maxUploadBytes is the largest file a batch accepts.
+	maxUploadBytes = 10 << 20
+)
+
+// UploadFileBatch uploads several files in one multipart request. Files are
+// sent as repeated "files" parts; an optional "manifest" field holds a JSON
+// array of per-file metadata in the same order, and the form-level fileType
+// and shared fields apply to files without one. The batch is checked against
+// the storage quota as a whole before anything is written; after that each
+// file succeeds or fails on its own.
+func (h *Handler) UploadFileBatch(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchFiles*maxUploadBytes+1<<20)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Failed to parse form")
+		return
+	}
+
+	headers := r.MultipartForm.File["files"]
+	if len(headers) == 0 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "No files uploaded")
+		return
+	}
+	if len(headers) > maxBatchFiles {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("At most %d files per batch", maxBatchFiles))
+		return
+	}
+
+	items := make([]models.UploadBatchItem, len(headers))
+	if manifest := r.FormValue("manifest"); manifest != "" {
+		var parsed []models.UploadBatchItem
+		if err := json.Unmarshal([]byte(manifest), &parsed); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "manifest must be a JSON array")
+			return
+		}
+		if len(parsed) != len(headers) {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("manifest has %d items for %d files", len(parsed), len(headers)))
+			return
+		}
+		items = parsed
+	}
+	sharedDefault, _ := strconv.ParseBool(r.FormValue("shared"))
+	for i := range items {
+		if items[i].FileType == "" {
+			items[i].FileType = r.FormValue("fileType")
+		}
+		items[i].Shared = items[i].Shared || sharedDefault
+		items[i].AltText, _ = cleanAltText(items[i].AltText)
+	}
+
+	// Validate every file first, so the quota check covers only what is written
+	results := make([]models.UploadBatchResult, len(headers))
+	var total int64
+	for i, header := range headers {
+		results[i] = models.UploadBatchResult{Index: i, Filename: header.Filename, ClientID: items[i].ClientID}
+		if reason := validateBatchUpload(header.Size, &items[i]); reason != "" {
+			results[i].Status = models.BatchItemFailed
+			results[i].Reason = reason
+			continue
+		}
+		total += header.Size
+	}
+
+	if h.storageQuota > 0 && total > 0 {
+		used, err := h.db.GetStorageUsage(ctx, pregnancy.ID)
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		if used+total > h.storageQuota {
+			writeError(w, http.StatusRequestEntityTooLarge, "STORAGE_QUOTA_EXCEEDED",
+				fmt.Sprintf("These files need %d MB but only %d MB of the %d MB storage quota is left; nothing was uploaded",
+					(total+1<<20-1)>>20, max(h.storageQuota-used, 0)>>20, h.storageQuota>>20))
+			return
+		}
+	}
+
+	resp := models.UploadBatchResponse{Results: results}
+	for i, header := range headers {
+		if results[i].Status == models.BatchItemFailed {
+			resp.Failed++
+			continue
+		}
+		file, err := h.saveUpload(ctx, pregnancy, header, items[i].FileType, items[i].ClientID, string(items[i].Metadata), strconv.FormatBool(items[i].Shared), items[i].AltText)
+		if err != nil {
+			results[i].Status = models.BatchItemFailed
+			results[i].Reason = err.Error()
+			resp.Failed++
+			continue
+		}
+		results[i].Status = models.BatchItemCreated
+		results[i].FileID = file.ID
+		results[i].URL = fmt.Sprintf("/files/%s", file.StoragePath)
+		results[i].ModerationStatus = file.ModerationStatus.String
+		results[i].Warnings = altTextWarnings(file, fmt.Sprintf("manifest[%d].altText", i))
+		resp.Uploaded++
+	}
+
+	status := http.StatusCreated
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	writeJSONWarnings(w, status, resp, h.storageWarnings(ctx, pregnancy.ID))
+}
+
+// validateBatchUpload reports why a file of a batch can't be uploaded, or "".
+func validateBatchUpload(size int64, item *models.UploadBatchItem) string {
+	if size > maxUploadBytes {
+		return fmt.Sprintf("file is larger than %d MB", maxUploadBytes>>20)
+	}
+	if item.FileType == "profile_photo" {
+		return "upload profile photos on their own with /api/files/upload"
+	}
+	if len(item.FileType) > 50 || len(item.ClientID) > 50 {
+		return "fileType and clientId must be at most 50 characters"
+	}
+	if utf8.RuneCountInString(item.AltText) > maxAltTextLength {
+		return fmt.Sprintf("altText must be at most %d characters", maxAltTextLength)
+	}
+	if len(item.Metadata) > 0 && !json.Valid(item.Metadata) {
+		return "metadata must be valid JSON"
+	}
+	return ""
+}
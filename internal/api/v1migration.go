@@ -0,0 +1,384 @@
+// Package api provides the migration from the legacy v1 tracker backend.
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/trackerv1"
+)
+
+// maxV1Records bounds the logs, preferences and photos of one export.
+const maxV1Records = 20000
+
+// MigrateV1 brings a user's data over from the v1 tracker: the profile,
+// logs as backfilled entries, preferences as settings and photos as files
+// with photo entries. The export is in the request or, with a v1 token,
+// pulled from the v1 server. A ledger of migrated records makes re-running
+// safe; only failed records are tried again. Existing profile fields and
+// settings are never overwritten.
+func (h *Handler) MigrateV1(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	var req models.V1MigrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if (len(req.Export) == 0) == (req.Token == "") {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Send either export or token")
+		return
+	}
+
+	a, err := h.resolveAccess(ctx, user.UserID)
+	if err != nil && err != db.ErrNotFound {
+		writeDomainError(w, err)
+		return
+	}
+	var pregnancy *models.Pregnancy
+	if err == nil {
+		if a.role != "owner" && a.role != "coowner" {
+			writeError(w, http.StatusForbidden, "FORBIDDEN", "Only the owner can migrate v1 data into this pregnancy")
+			return
+		}
+		pregnancy = a.pregnancy
+	}
+
+	var export *trackerv1.Export
+	if req.Token != "" {
+		if h.v1Source == nil {
+			writeError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", "Pulling from the v1 tracker is not configured; send the export instead")
+			return
+		}
+		export, err = h.v1Source.Export(ctx, req.Token)
+		if errors.Is(err, trackerv1.ErrUnauthorized) {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "The v1 tracker rejected the token")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, "SERVICE_UNAVAILABLE", fmt.Sprintf("Fetching the v1 export failed: %v", err))
+			return
+		}
+	} else {
+		export = &trackerv1.Export{}
+		if err := json.Unmarshal(req.Export, export); err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "export is not a v1 export")
+			return
+		}
+	}
+	if export.FormatVersion != trackerv1.FormatVersion {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Unsupported v1 export formatVersion %d", export.FormatVersion))
+		return
+	}
+	if len(export.Logs)+len(export.Preferences)+len(export.Photos) > maxV1Records {
+		writeError(w, http.StatusRequestEntityTooLarge, "VALIDATION_ERROR", fmt.Sprintf("At most %d v1 records per migration", maxV1Records))
+		return
+	}
+
+	ledger, err := h.db.GetV1Ledger(ctx, user.UserID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	report := &models.V1MigrationReport{Results: []models.V1MigrationResult{}}
+	pregnancy, err = h.migrateV1Profile(ctx, user.UserID, pregnancy, export.Profile, ledger, report)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	report.PregnancyID = pregnancy.ID
+
+	if err := h.migrateV1Preferences(ctx, user.UserID, pregnancy, export.Preferences, ledger, report); err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	var imports []models.V1EntryImport
+	for i := range export.Logs {
+		if im := v1LogImport(&export.Logs[i], ledger, report); im != nil {
+			imports = append(imports, *im)
+		}
+	}
+	for i := range export.Photos {
+		if im := h.v1PhotoImport(ctx, pregnancy, &export.Photos[i], req.Token, ledger, report); im != nil {
+			imports = append(imports, *im)
+		}
+	}
+
+	if len(imports) > 0 {
+		inserted, err := h.db.ImportV1Entries(ctx, user.UserID, pregnancy.ID, imports)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Migration of entries rolled back: "+err.Error())
+			return
+		}
+		for i, im := range imports {
+			status := models.V1Migrated
+			if !inserted[i] {
+				status = models.V1AlreadyMigrated
+			}
+			addV1Result(report, models.V1MigrationResult{
+				Kind: im.RecordKind, V1ID: im.V1ID, Status: status,
+				Target: fmt.Sprintf("entry:%s/%s", im.Entry.EntryType, im.Entry.ClientID),
+			})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// addV1Result appends a result and counts it.
+func addV1Result(report *models.V1MigrationReport, res models.V1MigrationResult) {
+	switch res.Status {
+	case models.V1Migrated:
+		report.Migrated++
+	case models.V1AlreadyMigrated:
+		report.AlreadyMigrated++
+	case models.V1Skipped:
+		report.Skipped++
+	case models.V1Failed:
+		report.Failed++
+	}
+	report.Results = append(report.Results, res)
+}
+
+// migrateV1Profile returns the pregnancy to migrate into, creating it from
+// the v1 profile when the user has none. An existing pregnancy only gets the
+// profile fields it is missing.
+func (h *Handler) migrateV1Profile(ctx context.Context, userID string, pregnancy *models.Pregnancy, profile *trackerv1.Profile, ledger map[string]models.V1LedgerEntry, report *models.V1MigrationReport) (*models.Pregnancy, error) {
+	if profile == nil {
+		if pregnancy != nil {
+			return pregnancy, nil
+		}
+		p, err := h.db.CreatePregnancy(ctx, userID, &models.PregnancyRequest{})
+		report.PregnancyCreated = err == nil
+		return p, err
+	}
+
+	res := models.V1MigrationResult{Kind: models.V1RecordProfile, V1ID: profile.ID}
+	if prev, ok := ledger[db.V1LedgerKey(res.Kind, res.V1ID)]; ok && pregnancy != nil {
+		res.Status, res.Target = models.V1AlreadyMigrated, prev.Target
+		addV1Result(report, res)
+		return pregnancy, nil
+	}
+
+	req, msg := v1ProfileRequest(profile, pregnancy)
+	if msg != "" {
+		res.Status, res.Reason = models.V1Failed, msg
+		addV1Result(report, res)
+		if pregnancy != nil {
+			return pregnancy, nil
+		}
+		req = &models.PregnancyRequest{}
+	}
+
+	var err error
+	if pregnancy == nil {
+		if pregnancy, err = h.db.CreatePregnancy(ctx, userID, req); err != nil {
+			return nil, err
+		}
+		report.PregnancyCreated = true
+	} else if pregnancy, err = h.db.UpdatePregnancy(ctx, pregnancy.ID, req); err != nil {
+		return nil, err
+	}
+	if msg != "" {
+		return pregnancy, nil
+	}
+
+	res.Status, res.Target = models.V1Migrated, "pregnancy:"+strconv.FormatInt(pregnancy.ID, 10)
+	if err := h.db.RecordV1Migration(ctx, userID, res.Kind, res.V1ID, pregnancy.ID, res.Target); err != nil {
+		return nil, err
+	}
+	addV1Result(report, res)
+	return pregnancy, nil
+}
+
+// v1ProfileRequest maps a v1 profile onto the fields existing (nil for a new
+// pregnancy) doesn't have yet.
+func v1ProfileRequest(profile *trackerv1.Profile, existing *models.Pregnancy) (*models.PregnancyRequest, string) {
+	for _, d := range []string{profile.DueDate, profile.LastPeriod, profile.MomBirthday} {
+		if _, err := time.Parse("2006-01-02", d); d != "" && err != nil {
+			return nil, "profile dates must be YYYY-MM-DD"
+		}
+	}
+	if profile.CycleLength != 0 && (profile.CycleLength < 20 || profile.CycleLength > 45) {
+		return nil, "cycleLength must be between 20 and 45"
+	}
+
+	req := &models.PregnancyRequest{}
+	set := func(dst **string, v string, have bool) {
+		if v != "" && !have {
+			*dst = &v
+		}
+	}
+	var e models.Pregnancy
+	if existing != nil {
+		e = *existing
+	}
+	set(&req.DueDate, profile.DueDate, e.DueDate.Valid)
+	set(&req.StartDate, profile.LastPeriod, e.StartDate.Valid)
+	if req.StartDate != nil && !e.CalculationMethod.Valid {
+		method := "lmp"
+		req.CalculationMethod = &method
+	}
+	set(&req.BabyName, profile.BabyName, e.BabyName.Valid)
+	set(&req.MomName, profile.MomName, e.MomName.Valid)
+	set(&req.MomBirthday, profile.MomBirthday, e.MomBirthday.Valid)
+	set(&req.Gender, profile.Gender, e.Gender.Valid)
+	if profile.CycleLength != 0 && existing == nil {
+		req.CycleLength = &profile.CycleLength
+	}
+	return req, ""
+}
+
+// migrateV1Preferences stores v1 preferences as settings of the same name,
+// leaving settings the pregnancy already has alone.
+func (h *Handler) migrateV1Preferences(ctx context.Context, userID string, pregnancy *models.Pregnancy, prefs map[string]json.RawMessage, ledger map[string]models.V1LedgerEntry, report *models.V1MigrationReport) error {
+	if len(prefs) == 0 {
+		return nil
+	}
+	settings, err := h.db.GetSettings(ctx, pregnancy.ID)
+	if err != nil {
+		return err
+	}
+
+	for key, data := range prefs {
+		res := models.V1MigrationResult{Kind: models.V1RecordPreference, V1ID: key, Target: "setting:" + key}
+		if prev, ok := ledger[db.V1LedgerKey(res.Kind, key)]; ok {
+			res.Status, res.Target = models.V1AlreadyMigrated, prev.Target
+			addV1Result(report, res)
+			continue
+		}
+		if key == "" || len(key) > 50 {
+			res.Status, res.Target, res.Reason = models.V1Failed, "", "preference names must be 1 to 50 characters"
+			addV1Result(report, res)
+			continue
+		}
+		if _, ok := settings[key]; ok {
+			res.Status, res.Reason = models.V1Skipped, "setting already set"
+			addV1Result(report, res)
+			continue
+		}
+		if status, _, msg := checkSettingWrite(pregnancy, userID, key, data); status != 0 {
+			res.Status, res.Reason = models.V1Failed, msg
+			addV1Result(report, res)
+			continue
+		}
+
+		if err := h.db.UpsertSetting(ctx, pregnancy.ID, key, data); err != nil {
+			return err
+		}
+		if err := h.db.RecordV1Migration(ctx, userID, res.Kind, key, pregnancy.ID, res.Target); err != nil {
+			return err
+		}
+		res.Status = models.V1Migrated
+		addV1Result(report, res)
+	}
+	return nil
+}
+
+// v1LogImport maps a v1 log to an entry, or records why it won't be imported.
+func v1LogImport(l *trackerv1.Log, ledger map[string]models.V1LedgerEntry, report *models.V1MigrationReport) *models.V1EntryImport {
+	res := models.V1MigrationResult{Kind: models.V1RecordLog, V1ID: l.ID}
+	if prev, ok := ledger[db.V1LedgerKey(res.Kind, l.ID)]; ok {
+		res.Status, res.Target = models.V1AlreadyMigrated, prev.Target
+		addV1Result(report, res)
+		return nil
+	}
+	if l.Deleted {
+		res.Status, res.Reason = models.V1Skipped, "deleted in v1"
+		addV1Result(report, res)
+		return nil
+	}
+
+	clientID := "v1-" + l.ID
+	entryType, data, at, err := trackerv1.MapLog(l)
+	switch {
+	case l.ID == "" || len(clientID) > 50:
+		err = errors.New("v1 log IDs must be 1 to 47 characters")
+	case err == nil && at.After(time.Now().Add(backfillClockSkew)):
+		err = errors.New("loggedAt must be in the past")
+	}
+	if err != nil {
+		res.Status, res.Reason = models.V1Failed, err.Error()
+		addV1Result(report, res)
+		return nil
+	}
+	return &models.V1EntryImport{
+		Entry:      models.EntryRequest{ClientID: clientID, EntryType: entryType, Data: data},
+		At:         at,
+		RecordKind: res.Kind,
+		V1ID:       l.ID,
+	}
+}
+
+// v1PhotoImport stores a v1 photo as a file and maps it to a photo entry, or
+// records why it won't be imported.
+func (h *Handler) v1PhotoImport(ctx context.Context, pregnancy *models.Pregnancy, photo *trackerv1.Photo, token string, ledger map[string]models.V1LedgerEntry, report *models.V1MigrationReport) *models.V1EntryImport {
+	res := models.V1MigrationResult{Kind: models.V1RecordPhoto, V1ID: photo.ID}
+	if prev, ok := ledger[db.V1LedgerKey(res.Kind, photo.ID)]; ok {
+		res.Status, res.Target = models.V1AlreadyMigrated, prev.Target
+		addV1Result(report, res)
+		return nil
+	}
+	fail := func(reason string) *models.V1EntryImport {
+		res.Status, res.Reason = models.V1Failed, reason
+		addV1Result(report, res)
+		return nil
+	}
+
+	clientID := "v1-photo-" + photo.ID
+	if photo.ID == "" || len(clientID) > 50 {
+		return fail("v1 photo IDs must be 1 to 41 characters")
+	}
+	at := time.Now()
+	if photo.TakenAt != "" {
+		t, err := time.Parse(time.RFC3339, photo.TakenAt)
+		if err != nil {
+			return fail("takenAt must be an RFC3339 timestamp")
+		}
+		at = t
+	}
+
+	content, mimeType := photo.Content, photo.MimeType
+	switch {
+	case len(content) > trackerv1.MaxPhotoBytes:
+		return fail(fmt.Sprintf("photo is larger than %d MB", trackerv1.MaxPhotoBytes>>20))
+	case len(content) == 0 && photo.URL == "":
+		return fail("photo has neither content nor url")
+	case len(content) == 0 && (h.v1Source == nil || token == ""):
+		return fail("photos given by url need a token and the v1 tracker configured")
+	case len(content) == 0:
+		var err error
+		content, mimeType, err = h.v1Source.Photo(ctx, token, photo.URL)
+		if err != nil {
+			return fail(fmt.Sprintf("download failed: %v", err))
+		}
+	}
+
+	file, err := h.storeFile(ctx, pregnancy, bytes.NewReader(content), "v1-"+photo.ID, mimeType, "photo", clientID, "", "", "")
+	if err != nil {
+		return fail(err.Error())
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"fileId":  file.ID,
+		"url":     "/files/" + file.StoragePath,
+		"caption": photo.Caption,
+		"date":    at.Format("2006-01-02"),
+	})
+	return &models.V1EntryImport{
+		Entry:      models.EntryRequest{ClientID: clientID, EntryType: "photo", Data: data},
+		At:         at,
+		RecordKind: res.Kind,
+		V1ID:       photo.ID,
+	}
+}
@@ -0,0 +1,333 @@
+// Package api provides A/B variants of weekly content with exposure logging.
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// maxVariantWeight bounds a variant's weight.
+const maxVariantWeight = 1000
+
+// GetContentItem returns a week's published content for the user, with their
+// variant applied when the week has variants running. Each call is logged as an
+// exposure of that variant. The simplified=true query serves plain-language text.
+// Weekly facts come with the community topics linked to the week.
+func (h *Handler) GetContentItem(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	ck, ok := contentKinds[mux.Vars(r)["kind"]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Unknown content kind")
+		return
+	}
+	week, err := strconv.Atoi(mux.Vars(r)["week"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid week")
+		return
+	}
+
+	base, err := h.db.GetPublishedContentWeek(ctx, ck.kind, week)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No content for this week")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	item := models.ContentItem{Week: week}
+
+	// Simplified text is served as written, outside of experiments
+	simplified := r.URL.Query().Get("simplified") == "true"
+	var variants []models.ContentVariant
+	if !simplified {
+		variants, err = h.db.GetActiveContentVariants(ctx, ck.kind, week, time.Now())
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+	}
+	if v := assignVariant(user.UserID, ck.kind, week, variants); v != nil {
+		data, err := overlayContent(base.Data, v.Data)
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		// The variant's text gets its own reading level
+		meta := parseAccessibility(base.Accessibility)
+		meta.ReadingLevel = 0
+		base.Data = data
+		base.Accessibility, _ = json.Marshal(meta)
+		item.Variant = v.Name
+
+		go func(variantID int64) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := h.db.RecordContentExposure(ctx, variantID, user.UserID); err != nil {
+				log.Printf("Failed to record content exposure: %v", err)
+			}
+		}(v.ID)
+	}
+
+	item.Data, err = renderContent(ck, base, simplified)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if ck.kind == models.ContentWeeklyFact {
+		if item.Community, err = h.weekCommunityTopics(ctx, week); err != nil {
+			writeDomainError(w, err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, item)
+}
+
+// assignVariant picks the user's variant by weight. The choice is a hash of the
+// user and week, so it stays the same while the variants and weights do.
+func assignVariant(userID, kind string, week int, variants []models.ContentVariant) *models.ContentVariant {
+	total := 0
+	for _, v := range variants {
+		total += v.Weight
+	}
+	if total == 0 {
+		return nil
+	}
+
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d/%s", kind, week, userID)))
+	n := int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+	for i := range variants {
+		n -= variants[i].Weight
+		if n < 0 {
+			return &variants[i]
+		}
+	}
+	return nil
+}
+
+// overlayContent replaces the base object's fields with the variant's.
+func overlayContent(base, overrides json.RawMessage) (json.RawMessage, error) {
+	var fields, changes map[string]json.RawMessage
+	if err := json.Unmarshal(base, &fields); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(overrides, &changes); err != nil {
+		return nil, err
+	}
+	for name, value := range changes {
+		fields[name] = value
+	}
+	return json.Marshal(fields)
+}
+
+// GetContentVariants lists a week's variants with exposure counts.
+func (h *Handler) GetContentVariants(w http.ResponseWriter, r *http.Request) {
+	ck, ok := h.adminContentKind(w, r)
+	if !ok {
+		return
+	}
+	week, err := strconv.Atoi(mux.Vars(r)["week"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid week")
+		return
+	}
+
+	variants, err := h.db.GetContentVariants(r.Context(), ck.kind, week)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if variants == nil {
+		variants = []models.ContentVariant{}
+	}
+	writeJSON(w, http.StatusOK, variants)
+}
+
+// CreateContentVariant adds a variant to a week.
+func (h *Handler) CreateContentVariant(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	ck, ok := h.adminContentKind(w, r)
+	if !ok {
+		return
+	}
+	week, err := strconv.Atoi(mux.Vars(r)["week"])
+	if err != nil || week < minContentWeek || week > maxContentWeek {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid week")
+		return
+	}
+
+	v, ok := decodeContentVariant(w, r)
+	if !ok {
+		return
+	}
+	v.Kind, v.Week, v.CreatedBy = ck.kind, week, user.UserID
+	if !h.variantNameFree(w, r, v) {
+		return
+	}
+
+	created, err := h.db.CreateContentVariant(ctx, v)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// UpdateContentVariant replaces a variant. Changing weights reassigns some users.
+func (h *Handler) UpdateContentVariant(w http.ResponseWriter, r *http.Request) {
+	ck, ok := h.adminContentKind(w, r)
+	if !ok {
+		return
+	}
+	week, id, ok := contentVariantVars(w, r)
+	if !ok {
+		return
+	}
+
+	v, ok := decodeContentVariant(w, r)
+	if !ok {
+		return
+	}
+	v.ID, v.Kind, v.Week = id, ck.kind, week
+	if !h.variantNameFree(w, r, v) {
+		return
+	}
+
+	updated, err := h.db.UpdateContentVariant(r.Context(), v)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Variant not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// DeleteContentVariant deletes a variant and its exposure log.
+func (h *Handler) DeleteContentVariant(w http.ResponseWriter, r *http.Request) {
+	ck, ok := h.adminContentKind(w, r)
+	if !ok {
+		return
+	}
+	week, id, ok := contentVariantVars(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.db.DeleteContentVariant(r.Context(), ck.kind, week, id)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Variant not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeContentVariant reads and validates a variant request.
+func decodeContentVariant(w http.ResponseWriter, r *http.Request) (*models.ContentVariant, bool) {
+	var req models.ContentVariantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return nil, false
+	}
+
+	if req.Name == "" || len(req.Name) > 50 {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name must be 1 to 50 characters")
+		return nil, false
+	}
+	v := &models.ContentVariant{Name: req.Name, Weight: 1}
+	if req.Weight != nil {
+		v.Weight = *req.Weight
+	}
+	if v.Weight < 0 || v.Weight > maxVariantWeight {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("weight must be between 0 and %d", maxVariantWeight))
+		return nil, false
+	}
+
+	var fields map[string]json.RawMessage
+	if len(req.Data) == 0 || json.Unmarshal(req.Data, &fields) != nil || fields == nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "data must be a JSON object")
+		return nil, false
+	}
+	if _, ok := fields["week"]; ok {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "data cannot override week")
+		return nil, false
+	}
+	v.Data = req.Data
+
+	for _, t := range []struct {
+		value *string
+		dest  *sql.NullTime
+		name  string
+	}{{req.StartsAt, &v.StartsAt, "startsAt"}, {req.EndsAt, &v.EndsAt, "endsAt"}} {
+		if t.value == nil {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, *t.value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", t.name+" must be an RFC3339 timestamp")
+			return nil, false
+		}
+		*t.dest = sql.NullTime{Time: at, Valid: true}
+	}
+	if v.StartsAt.Valid && v.EndsAt.Valid && !v.EndsAt.Time.After(v.StartsAt.Time) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "endsAt must be after startsAt")
+		return nil, false
+	}
+	return v, true
+}
+
+// variantNameFree rejects a name another variant of the week already uses.
+func (h *Handler) variantNameFree(w http.ResponseWriter, r *http.Request, v *models.ContentVariant) bool {
+	existing, err := h.db.GetContentVariants(r.Context(), v.Kind, v.Week)
+	if err != nil {
+		writeDomainError(w, err)
+		return false
+	}
+	for _, e := range existing {
+		if e.Name == v.Name && e.ID != v.ID {
+			writeError(w, http.StatusConflict, "CONFLICT", "A variant with this name already exists")
+			return false
+		}
+	}
+	return true
+}
+
+// contentVariantVars parses the {week} and {variantId} route variables.
+func contentVariantVars(w http.ResponseWriter, r *http.Request) (int, int64, bool) {
+	vars := mux.Vars(r)
+	week, err := strconv.Atoi(vars["week"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid week")
+		return 0, 0, false
+	}
+	id, err := strconv.ParseInt(vars["variantId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid variant ID")
+		return 0, 0, false
+	}
+	return week, id, true
+}
@@ -0,0 +1,152 @@
+// Package api provides per-entry visibility: entries shared with everyone,
+// kept between the owners and the partner, or private notes to self.
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// maxVisibilityClientIDs caps the entries named in one bulk visibility change.
+const maxVisibilityClientIDs = 500
+
+// entryAudience returns the visibility levels of the pregnancy's entries the
+// user may read, or nil for all of them. The owner and coowner read every
+// entry, the partner shared and partner-only ones, everyone else (supporters,
+// widgets) shared ones.
+func entryAudience(p *models.Pregnancy, userID string) []string {
+	switch {
+	case p.OwnerID == userID || (p.CoownerID.Valid && p.CoownerID.String == userID):
+		return nil
+	case p.PartnerID.Valid && p.PartnerID.String == userID:
+		return []string{models.VisibilityShared, models.VisibilityPartner}
+	}
+	return []string{models.VisibilityShared}
+}
+
+// canSeeEntry reports whether an audience may read entries of a visibility.
+func canSeeEntry(audience []string, visibility string) bool {
+	if audience == nil {
+		return true
+	}
+	for _, v := range audience {
+		if v == visibility {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleEntries drops the entries the audience may not read. For incremental
+// reads (since set), entries whose visibility changed after since come back as
+// bare tombstones instead, so devices drop copies they synced while they could
+// still see them.
+func visibleEntries(entries []models.Entry, audience []string, since *time.Time) []models.Entry {
+	if audience == nil {
+		return entries
+	}
+	visible := make([]models.Entry, 0, len(entries))
+	for _, e := range entries {
+		if canSeeEntry(audience, e.Visibility) {
+			visible = append(visible, e)
+			continue
+		}
+		if since != nil && e.VisibilityChangedAt.Valid && e.VisibilityChangedAt.Time.After(*since) {
+			visible = append(visible, withdrawnEntry(&e))
+		}
+	}
+	return visible
+}
+
+// withdrawnEntry is the tombstone of an entry hidden from the viewer: no
+// payload, deleted as of the change.
+func withdrawnEntry(e *models.Entry) models.Entry {
+	deletedAt := e.DeletedAt
+	if !deletedAt.Valid {
+		deletedAt = sql.NullTime{Time: e.UpdatedAt, Valid: true}
+	}
+	return models.Entry{
+		ID:          e.ID,
+		ClientID:    e.ClientID,
+		EntryType:   e.EntryType,
+		CreatedAt:   e.CreatedAt,
+		UpdatedAt:   e.UpdatedAt,
+		DeletedAt:   deletedAt,
+		DataVersion: e.DataVersion,
+		Clock:       e.Clock,
+		Visibility:  e.Visibility,
+		SyncVersion: e.SyncVersion,
+	}
+}
+
+// validVisibility reports whether v is a visibility level.
+func validVisibility(v string) bool {
+	return v == models.VisibilityShared || v == models.VisibilityPartner || v == models.VisibilityPrivate
+}
+
+// validateVisibility checks a written entry's visibility. Writers can't hide
+// an entry from themselves, so the partner can't make entries private.
+func validateVisibility(req *models.EntryRequest, audience []string) string {
+	if req.Visibility == nil {
+		return ""
+	}
+	if !validVisibility(*req.Visibility) {
+		return "visibility must be shared, partner or private"
+	}
+	if !canSeeEntry(audience, *req.Visibility) {
+		return "only the owner can make entries " + *req.Visibility
+	}
+	return ""
+}
+
+// SetEntriesVisibility changes the visibility of many entries at once: those
+// named in clientIds, or every entry of entryType. Owner and coowner only,
+// since they are the ones who see every entry.
+func (h *Handler) SetEntriesVisibility(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, _, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if entryAudience(pregnancy, user.UserID) != nil {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "Only the owner can change entry visibility in bulk")
+		return
+	}
+
+	var req models.EntryVisibilityRequest
+	if err := decodeBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+	if !validVisibility(req.Visibility) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "visibility must be shared, partner or private")
+		return
+	}
+	if (req.EntryType == "") == (len(req.ClientIDs) == 0) {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Give either clientIds or entryType")
+		return
+	}
+	if len(req.ClientIDs) > maxVisibilityClientIDs {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("clientIds may hold at most %d entries", maxVisibilityClientIDs))
+		return
+	}
+
+	updated, err := h.db.SetEntriesVisibility(ctx, pregnancy.ID, req.Visibility, req.ClientIDs, req.EntryType)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, models.EntryVisibilityResponse{Visibility: req.Visibility, Updated: updated})
+}
@@ -0,0 +1,122 @@
+// Package api provides home device vitals import handlers.
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/vitals"
+)
+
+// Maximum size of an uploaded device export
+const maxVitalsImportSize = 5 << 20
+
+// ImportVitals imports a blood pressure or glucose CSV export from a home device.
+// Form fields: file, vendor, optional deviceSerial (when the export has none) and
+// optional timezone (IANA name for exports without zone info, default UTC).
+func (h *Handler) ImportVitals(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, permission, err := h.getAccessiblePregnancy(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if permission != "write" {
+		writeError(w, http.StatusForbidden, "FORBIDDEN", "No write permission")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxVitalsImportSize)
+	if err := r.ParseMultipartForm(maxVitalsImportSize); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Failed to parse form")
+		return
+	}
+
+	vendor := r.FormValue("vendor")
+	parser, err := vitals.Lookup(vendor)
+	if err == vitals.ErrUnknownVendor {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR",
+			"Unknown vendor. Supported: "+strings.Join(vitals.Vendors(), ", "))
+		return
+	}
+
+	loc := time.UTC
+	if tz := r.FormValue("timezone"); tz != "" {
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid timezone")
+			return
+		}
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "No file uploaded")
+		return
+	}
+	defer file.Close()
+
+	readings, rowErrors, err := parser.Parse(file, loc)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error())
+		return
+	}
+
+	resp := models.VitalsImportResponse{
+		Vendor: strings.ToLower(vendor),
+		Failed: make([]models.VitalsImportError, 0, len(rowErrors)),
+	}
+	for _, e := range rowErrors {
+		resp.Failed = append(resp.Failed, models.VitalsImportError{Row: e.Row, Reason: e.Reason})
+	}
+
+	fallbackSerial := r.FormValue("deviceSerial")
+	for _, reading := range readings {
+		if reading.DeviceSerial == "" {
+			reading.DeviceSerial = fallbackSerial
+		}
+
+		data, err := json.Marshal(reading)
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+
+		// Same device + timestamp always maps to the same clientId, so re-imports dedupe
+		created, err := h.db.InsertEntryIfAbsent(ctx, pregnancy.ID, &models.EntryRequest{
+			ClientID:  vitalsClientID(reading),
+			EntryType: reading.Kind,
+			Data:      data,
+		})
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		if created {
+			resp.Imported++
+		} else {
+			resp.Duplicates++
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// vitalsClientID derives a stable clientId from the device serial and timestamp.
+func vitalsClientID(r vitals.Reading) string {
+	sum := sha256.Sum256([]byte(r.Vendor + "|" + r.DeviceSerial + "|" + r.Timestamp.UTC().Format(time.RFC3339)))
+	return "vitals-" + hex.EncodeToString(sum[:16])
+}
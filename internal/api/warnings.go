@@ -0,0 +1,96 @@
+// Package api provides non-fatal warnings on mutating responses.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// storageWarnRatio is the share of the storage quota at which uploads warn.
+const storageWarnRatio = 0.8
+
+// writeJSONWarnings writes data like writeJSON and, when there are warnings,
+// adds them to the response object as "warnings". Responses without warnings
+// are unchanged.
+func writeJSONWarnings(w http.ResponseWriter, status int, data interface{}, warnings []models.Warning) {
+	if len(warnings) == 0 {
+		writeJSON(w, status, data)
+		return
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		// Only objects have room for warnings
+		writeJSON(w, status, data)
+		return
+	}
+	fields["warnings"], _ = json.Marshal(warnings)
+	writeJSON(w, status, fields)
+}
+
+// dueDateWarnings flags a due date more than 10 months away, which is usually a
+// typo in the year.
+func dueDateWarnings(req *models.PregnancyRequest, now time.Time) []models.Warning {
+	if req.DueDate == nil {
+		return nil
+	}
+	due, err := time.Parse("2006-01-02", *req.DueDate)
+	if err != nil || !due.After(now.AddDate(0, 10, 0)) {
+		return nil
+	}
+	return []models.Warning{{
+		Code:    "DUE_DATE_FAR",
+		Message: "Due date is more than 10 months away",
+		Field:   "dueDate",
+	}}
+}
+
+// clampOccurredAt moves an occurredAt slightly ahead of the server clock back to
+// now. validateOccurredAt has already rejected anything further ahead.
+func clampOccurredAt(req *models.EntryRequest, now time.Time, field string) []models.Warning {
+	if req.OccurredAt == nil {
+		return nil
+	}
+	at, err := time.Parse(time.RFC3339, *req.OccurredAt)
+	if err != nil || !at.After(now) {
+		return nil
+	}
+	clamped := now.In(at.Location()).Format(time.RFC3339)
+	req.OccurredAt = &clamped
+	return []models.Warning{{
+		Code:    "OCCURRED_AT_CLAMPED",
+		Message: "Entry timestamp was in the future and was clamped to " + clamped,
+		Field:   field,
+	}}
+}
+
+// storageWarnings flags a pregnancy whose files use most of the storage quota.
+// The quota is not enforced; 0 disables the check.
+func (h *Handler) storageWarnings(ctx context.Context, pregnancyID int64) []models.Warning {
+	if h.storageQuota <= 0 {
+		return nil
+	}
+	used, err := h.db.GetStorageUsage(ctx, pregnancyID)
+	if err != nil {
+		log.Printf("Failed to get storage usage for pregnancy %d: %v", pregnancyID, err)
+		return nil
+	}
+	if float64(used) < storageWarnRatio*float64(h.storageQuota) {
+		return nil
+	}
+	return []models.Warning{{
+		Code:    "STORAGE_QUOTA_NEAR",
+		Message: fmt.Sprintf("Files use %d%% of the %d MB storage quota", used*100/h.storageQuota, h.storageQuota>>20),
+	}}
+}
@@ -0,0 +1,304 @@
+// Package api provides widget tokens for the supporter web widget.
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// widgetTokenPrefix marks widget tokens. They are only accepted under /api/widget.
+const widgetTokenPrefix = "t2w_"
+
+const widgetContextKey contextKey = "widget"
+
+// Widget token limits
+const maxWidgetTokens = 10
+
+// widgetBudget covers the /api/widget routes. It is counted per widget token,
+// apart from user budgets, and is enforced rather than advisory.
+var widgetBudget = rateBudget{name: "widget", limit: 60, window: time.Minute}
+
+// Widget responses may be served from the widget's cache while offline. Photos
+// are revalidated after a minute (Last-Modified makes that a 304) and never
+// served stale while revalidating, so one made private or moderated, or a
+// revoked token, stops showing it once the widget is back online.
+const (
+	widgetSyncCache  = "private, max-age=60, stale-while-revalidate=86400, stale-if-error=604800"
+	widgetPhotoCache = "private, max-age=60, stale-if-error=604800"
+)
+
+func getWidgetToken(r *http.Request) *models.WidgetToken {
+	return r.Context().Value(widgetContextKey).(*models.WidgetToken)
+}
+
+// WidgetAuthMiddleware accepts only widget tokens and counts each request against
+// the token's own budget, answering 429 once it is spent.
+func (h *Handler) WidgetAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" || !strings.HasPrefix(parts[1], widgetTokenPrefix) {
+			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Widget token required")
+			return
+		}
+
+		t, err := h.db.GetActiveWidgetToken(r.Context(), sha256Hex(parts[1]))
+		if err == db.ErrNotFound {
+			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "Invalid or revoked token")
+			return
+		}
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+
+		res, err := h.limiter.Hit(r.Context(), fmt.Sprintf("widget:%d", t.ID), widgetBudget.rateLimit())
+		if err != nil {
+			writeDomainError(w, err)
+			return
+		}
+		setRateLimitHeaders(w, &widgetBudget, res)
+		if res.Exceeded(widgetBudget.rateLimit()) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(res.Reset).Seconds())+1))
+			writeError(w, http.StatusTooManyRequests, "RATE_LIMITED", "Too many widget requests, retry later")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), widgetContextKey, t)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetWidgetTokens lists the owner's widget tokens.
+func (h *Handler) GetWidgetTokens(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	pregnancy, err := h.db.GetPregnancyByOwner(r.Context(), user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	tokens, err := h.db.GetWidgetTokens(r.Context(), pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if tokens == nil {
+		tokens = []models.WidgetToken{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tokens": tokens})
+}
+
+// CreateWidgetToken issues a widget token for the owner's pregnancy. The secret
+// is returned once and only its hash is kept.
+func (h *Handler) CreateWidgetToken(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+	ctx := r.Context()
+
+	pregnancy, err := h.db.GetPregnancyByOwner(ctx, user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	var req models.WidgetTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid request body")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxTokenNameLen {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "name is required (max 100 characters)")
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays != nil {
+		if *req.ExpiresInDays < 1 || *req.ExpiresInDays > maxTokenLifetimeDays {
+			writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "expiresInDays must be between 1 and 3650")
+			return
+		}
+		t := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	count, err := h.db.CountActiveWidgetTokens(ctx, pregnancy.ID)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	if count >= maxWidgetTokens {
+		writeError(w, http.StatusConflict, "CONFLICT", "Too many active widget tokens; revoke one first")
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "Failed to generate token")
+		return
+	}
+	token := widgetTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	created, err := h.db.CreateWidgetToken(ctx, pregnancy.ID, user.UserID, req.Name, sha256Hex(token), token[:len(widgetTokenPrefix)+6], expiresAt)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, models.WidgetTokenResponse{WidgetToken: *created, Token: token})
+}
+
+// RevokeWidgetToken revokes one of the owner's widget tokens immediately.
+func (h *Handler) RevokeWidgetToken(w http.ResponseWriter, r *http.Request) {
+	user := getUserInfo(r)
+
+	tokenID, err := strconv.ParseInt(mux.Vars(r)["tokenId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid token ID")
+		return
+	}
+
+	pregnancy, err := h.db.GetPregnancyByOwner(r.Context(), user.UserID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	err = h.db.RevokeWidgetToken(r.Context(), tokenID, pregnancy.ID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "Token not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWidgetSync returns the lite sync of the token's pregnancy. Responses carry
+// an ETag that ignores serverTime, so an unchanged view revalidates with 304.
+func (h *Handler) GetWidgetSync(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := getWidgetToken(r)
+
+	pregnancy, err := h.db.GetPregnancyByID(ctx, token.PregnancyID)
+	if err == db.ErrNotFound {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "No pregnancy found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	resp, err := h.liteSync(ctx, pregnancy, "")
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	stable := *resp
+	stable.ServerTime = ""
+	body, err := json.Marshal(stable)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", widgetSyncCache)
+	w.Header().Set("Vary", "Authorization")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GetWidgetPhoto serves the file of a photo the widget's lite sync shows: shared,
+// not held by moderation, and only while sharing isn't snoozed.
+func (h *Handler) GetWidgetPhoto(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := getWidgetToken(r)
+
+	fileID, err := strconv.ParseInt(mux.Vars(r)["fileId"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "File not found")
+		return
+	}
+
+	pregnancy, err := h.db.GetPregnancyByID(ctx, token.PregnancyID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "File not found")
+		return
+	}
+	view, err := h.liteSync(ctx, pregnancy, "")
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+	shared := false
+	for _, p := range view.Photos {
+		if id, ok := payloadFileID(p.Data); ok && id == fileID {
+			shared = true
+			break
+		}
+	}
+	if !shared {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "File not found")
+		return
+	}
+
+	file, err := h.db.GetFile(ctx, fileID)
+	if err == db.ErrNotFound || (err == nil && file.PregnancyID != pregnancy.ID) {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "File not found")
+		return
+	}
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	path, err := h.filePath(file)
+	if err != nil {
+		writeDomainError(w, err)
+		return
+	}
+
+	if file.MimeType.Valid {
+		w.Header().Set("Content-Type", file.MimeType.String)
+	}
+	w.Header().Set("Cache-Control", widgetPhotoCache)
+	w.Header().Set("Vary", "Authorization")
+	http.ServeFile(w, r, path)
+}
@@ -25,6 +25,11 @@ type Claims struct {
 type UserInfo struct {
 	UserID    string    // UUID string (e.g., "fa497802-ba40-4447-bc48-6da2bf726926")
 	ExpiresAt time.Time
+	TokenID   int64  // Personal access token ID; 0 for mvchat2 JWTs
+	Scope     string // Personal access token scope ("read" or "write"); empty for JWTs
+
+	ImpersonationID int64  // Admin impersonation session ID; 0 unless an admin acts as the user
+	ImpersonatorID  string // Admin acting as the user during an impersonation session
 }
 
 // Authenticator validates mvchat2 JWT tokens.
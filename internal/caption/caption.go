@@ -0,0 +1,70 @@
+// Package caption suggests alt text for images so people using screen readers
+// get a description even when nobody wrote one.
+//
+// A Captioner is pluggable: the HTTP implementation posts the image to an
+// external captioning API or a locally hosted model that speaks the same protocol.
+package caption
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Captioner describes an image in a sentence or two.
+type Captioner interface {
+	Caption(ctx context.Context, data []byte, mimeType string) (string, error)
+}
+
+// HTTPCaptioner posts the raw image to URL with its MIME type as Content-Type
+// and expects {"altText": "..."} back. A bearer token is sent when Token is set.
+type HTTPCaptioner struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// NewHTTP creates an HTTPCaptioner with a request timeout.
+func NewHTTP(url, token string) *HTTPCaptioner {
+	return &HTTPCaptioner{URL: url, Token: token, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Caption sends the image to the captioning endpoint.
+func (c *HTTPCaptioner) Caption(ctx context.Context, data []byte, mimeType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mimeType)
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("captioning service returned %s", resp.Status)
+	}
+
+	var out struct {
+		AltText string `json:"altText"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("decode caption: %w", err)
+	}
+	text := strings.TrimSpace(out.AltText)
+	if text == "" {
+		return "", errors.New("captioning service returned no alt text")
+	}
+	return text, nil
+}
@@ -0,0 +1,52 @@
+// Package community links weekly content to topics of an external community
+// service, such as mvchat2 community rooms, so apps can deep-link into them.
+//
+// A Linker is pluggable: Template builds links from a URL template. Topic IDs
+// are stored per service, so links follow the template when it changes.
+package community
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// TopicPlaceholder is replaced by the topic ID in a Template URL.
+const TopicPlaceholder = "{topicId}"
+
+// Linker turns topic IDs of one community service into links apps open.
+type Linker interface {
+	// Service names the community service topics belong to.
+	Service() string
+	// Link returns the link to a topic.
+	Link(topicID string) string
+}
+
+// Template links topics by substituting the path-escaped topic ID into URL,
+// e.g. mvchat2://community/rooms/{topicId}.
+type Template struct {
+	Name string
+	URL  string
+}
+
+// NewTemplate creates a Template for a service. The URL must contain
+// TopicPlaceholder.
+func NewTemplate(service, urlTemplate string) (*Template, error) {
+	if service == "" {
+		return nil, errors.New("community service name is required")
+	}
+	if !strings.Contains(urlTemplate, TopicPlaceholder) {
+		return nil, errors.New("community link URL must contain " + TopicPlaceholder)
+	}
+	return &Template{Name: service, URL: urlTemplate}, nil
+}
+
+// Service returns the service name.
+func (t *Template) Service() string {
+	return t.Name
+}
+
+// Link returns the topic's link.
+func (t *Template) Link(topicID string) string {
+	return strings.ReplaceAll(t.URL, TopicPlaceholder, url.PathEscape(topicID))
+}
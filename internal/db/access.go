@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Access Operations ============
+
+// ResolvePregnancyAccess finds the pregnancy the user can reach with their role
+// and permission in one round trip. Roles take precedence in order: owner,
+// coowner, partner (role "father", approved only), supporter (role "support").
+// Each branch is served by an index on its user column.
+func (d *DB) ResolvePregnancyAccess(ctx context.Context, userID string) (*models.Pregnancy, string, string, error) {
+	var row struct {
+		models.Pregnancy
+		Role       string `db:"access_role"`
+		Permission string `db:"access_permission"`
+	}
+	err := d.db.GetContext(ctx, &row, `
+		SELECT p.*, a.access_role, a.access_permission
+		FROM (
+			SELECT id, 'owner' AS access_role, 'write' AS access_permission, 1 AS rank
+			FROM clingy_pregnancies WHERE owner_id = $1
+			UNION ALL
+			SELECT id, 'coowner', 'write', 2
+			FROM clingy_pregnancies WHERE coowner_id = $1
+			UNION ALL
+			SELECT id, 'father', COALESCE(partner_permission, 'read'), 3
+			FROM clingy_pregnancies WHERE partner_id = $1 AND partner_status = 'approved'
+			UNION ALL
+			SELECT pregnancy_id, 'support', COALESCE(permission, 'read'), 4
+			FROM clingy_supporters WHERE user_id = $1 AND removed_at IS NULL
+		) a
+		JOIN clingy_pregnancies p ON p.id = a.id
+		ORDER BY a.rank
+		LIMIT 1
+	`, userID)
+	if err == sql.ErrNoRows {
+		return nil, "", "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", "", err
+	}
+	return &row.Pregnancy, row.Role, row.Permission, nil
+}
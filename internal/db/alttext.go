@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Alt Text Operations ============
+
+// SetFileAltText replaces a file's alt text; "" clears it.
+func (d *DB) SetFileAltText(ctx context.Context, fileID int64, altText string) (*models.File, error) {
+	var f models.File
+	err := d.db.GetContext(ctx, &f, `
+		UPDATE clingy_files SET alt_text = $2
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING *
+	`, fileID, altText)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// GetImagesMissingAltText returns the pregnancy's images without alt text,
+// newest first, and how many images it has in all.
+func (d *DB) GetImagesMissingAltText(ctx context.Context, pregnancyID int64) ([]models.File, int, error) {
+	var total int
+	err := d.db.GetContext(ctx, &total, `
+		SELECT COUNT(*) FROM clingy_files
+		WHERE pregnancy_id = $1 AND deleted_at IS NULL AND mime_type LIKE 'image/%'
+	`, pregnancyID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	files := []models.File{}
+	err = d.db.SelectContext(ctx, &files, `
+		SELECT * FROM clingy_files
+		WHERE pregnancy_id = $1 AND deleted_at IS NULL AND alt_text = '' AND mime_type LIKE 'image/%'
+		ORDER BY created_at DESC
+	`, pregnancyID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return files, total, nil
+}
+
+// GetFileAltTexts returns the alt text of the pregnancy's files that have one.
+func (d *DB) GetFileAltTexts(ctx context.Context, pregnancyID int64) (map[int64]string, error) {
+	var rows []struct {
+		ID      int64  `db:"id"`
+		AltText string `db:"alt_text"`
+	}
+	err := d.db.SelectContext(ctx, &rows, `
+		SELECT id, alt_text FROM clingy_files
+		WHERE pregnancy_id = $1 AND deleted_at IS NULL AND alt_text <> ''
+	`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+
+	altTexts := make(map[int64]string, len(rows))
+	for _, row := range rows {
+		altTexts[row.ID] = row.AltText
+	}
+	return altTexts, nil
+}
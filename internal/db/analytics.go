@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Analytics Operations ============
+
+// Supported groupBy values for AggregateEntries.
+const (
+	GroupByHourOfDay = "hourOfDay"
+	GroupByDayOfWeek = "dayOfWeek"
+	GroupByWeek      = "week"
+)
+
+// entryTimeSQL is the expression for an entry's logical time: occurred_at,
+// else the first payload time key (timestamp, date, dateTime, time,
+// startTime), else created_at. It is the entry_at column, which a trigger
+// fills with clingy_entry_time (migration 061): a payload date that doesn't
+// cast, like "2024-02-30", is skipped there rather than failing the query.
+func entryTimeSQL() string {
+	return "entry_at"
+}
+
+// AggregateEntries groups a pregnancy's entries of one type into time buckets in
+// the given IANA timezone and aggregates an optional numeric payload field.
+// weekStart is required for GroupByWeek (pregnancy day 0). Only entries of the
+// given visibility levels count (nil for all).
+func (d *DB) AggregateEntries(ctx context.Context, pregnancyID int64, entryType, groupBy, field, timezone string, weekStart time.Time, visibility []string) ([]models.AggregateBucket, error) {
+	var bucket string
+	switch groupBy {
+	case GroupByHourOfDay:
+		bucket = "EXTRACT(HOUR FROM at AT TIME ZONE $4)::int"
+	case GroupByDayOfWeek:
+		bucket = "EXTRACT(DOW FROM at AT TIME ZONE $4)::int"
+	case GroupByWeek:
+		bucket = "FLOOR(((at AT TIME ZONE $4)::date - $6::date) / 7.0)::int"
+	default:
+		return nil, fmt.Errorf("unsupported groupBy %q", groupBy)
+	}
+
+	query := fmt.Sprintf(`
+		WITH src AS (
+			SELECT
+				%s AS at,
+				CASE WHEN data->>$3 ~ '^-?[0-9]+(\.[0-9]+)?$' THEN (data->>$3)::double precision END AS value,
+				backfilled
+			FROM clingy_entries
+			WHERE pregnancy_id = $1 AND entry_type = $2 AND deleted_at IS NULL
+			  AND ($5::varchar[] IS NULL OR visibility = ANY($5))
+		)
+		SELECT %s AS bucket, COUNT(*) AS count, COUNT(*) FILTER (WHERE backfilled) AS backfilled, AVG(value) AS avg, MIN(value) AS min, MAX(value) AS max
+		FROM src
+		GROUP BY 1
+		ORDER BY 1
+	`, entryTimeSQL(), bucket)
+
+	// Postgres rejects parameters it can't type, so $6 is only sent when used
+	args := []interface{}{pregnancyID, entryType, field, timezone, visibility}
+	if groupBy == GroupByWeek {
+		args = append(args, weekStart.Format("2006-01-02"))
+	}
+
+	var buckets []models.AggregateBucket
+	err := d.db.SelectContext(ctx, &buckets, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// GetMealServings sums the servings of a pregnancy's meal entries per local
+// day in timezone and food, for days from..to (YYYY-MM-DD, inclusive). Meals
+// without a foodId come back with an empty FoodID; a missing or non-numeric
+// servings counts as one. Only meals of the given visibility levels count (nil
+// for all).
+func (d *DB) GetMealServings(ctx context.Context, pregnancyID int64, timezone, from, to string, visibility []string) ([]models.MealServings, error) {
+	query := fmt.Sprintf(`
+		WITH src AS (
+			SELECT
+				(%s AT TIME ZONE $2)::date AS day,
+				COALESCE(data->>'foodId', '') AS food_id,
+				CASE WHEN data->>'servings' ~ '^[0-9]+(\.[0-9]+)?$' THEN (data->>'servings')::double precision ELSE 1 END AS servings
+			FROM clingy_entries
+			WHERE pregnancy_id = $1 AND entry_type = 'meal' AND deleted_at IS NULL
+			  AND ($5::varchar[] IS NULL OR visibility = ANY($5))
+		)
+		SELECT to_char(day, 'YYYY-MM-DD') AS day, food_id, COUNT(*) AS meals, SUM(servings) AS servings
+		FROM src
+		WHERE day BETWEEN $3::date AND $4::date
+		GROUP BY day, food_id
+		ORDER BY day, food_id
+	`, entryTimeSQL())
+
+	var rows []models.MealServings
+	err := d.db.SelectContext(ctx, &rows, query, pregnancyID, timezone, from, to, visibility)
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// GetWeightReadings gets a pregnancy's weight entries with a numeric weight,
+// oldest first, at their logical time. Only entries of the given visibility
+// levels count (nil for all).
+func (d *DB) GetWeightReadings(ctx context.Context, pregnancyID int64, visibility []string) ([]models.WeightReading, error) {
+	var readings []models.WeightReading
+	err := d.db.SelectContext(ctx, &readings, `
+		SELECT entry_at AS at, clingy_try_number(data->>'weight') AS weight, COALESCE(data->>'unit', '') AS unit
+		FROM clingy_entries
+		WHERE pregnancy_id = $1 AND entry_type = 'weight' AND deleted_at IS NULL
+		  AND ($2::varchar[] IS NULL OR visibility = ANY($2))
+		  AND clingy_try_number(data->>'weight') IS NOT NULL
+		ORDER BY entry_at, id
+	`, pregnancyID, visibility)
+	if err != nil {
+		return nil, err
+	}
+	return readings, nil
+}
+
+// GetTimedEntries gets a pregnancy's entries of the given types that happened
+// in [from, to), oldest first, for analytics done in Go. Only entries of the
+// given visibility levels count (nil for all).
+func (d *DB) GetTimedEntries(ctx context.Context, pregnancyID int64, entryTypes []string, from, to time.Time, visibility []string) ([]models.TimedEntry, error) {
+	var entries []models.TimedEntry
+	err := d.db.SelectContext(ctx, &entries, `
+		SELECT entry_type, entry_at AS at, data
+		FROM clingy_entries
+		WHERE pregnancy_id = $1 AND entry_type = ANY($2) AND deleted_at IS NULL
+		  AND ($5::varchar[] IS NULL OR visibility = ANY($5))
+		  AND entry_at >= $3 AND entry_at < $4
+		ORDER BY entry_at, id
+	`, pregnancyID, entryTypes, from, to, visibility)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
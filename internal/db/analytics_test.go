@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+func TestEntryTimeSkipsInvalidPayloadDates(t *testing.T) {
+	d := testDB(t)
+	ctx := context.Background()
+	p := testPregnancy(t, d)
+
+	payloads := []string{
+		`{"timestamp": "2024-02-30T10:00:00Z", "servings": 1}`, // no such day
+		`{"date": "2024-01-01garbage", "servings": 1}`,
+		`{"date": "2024-13-01", "servings": 1}`,
+		`{"timestamp": "2024-03-01T10:00:00Z", "servings": 2}`,
+	}
+	for i, payload := range payloads {
+		_, err := d.UpsertEntry(ctx, p.ID, &models.EntryRequest{
+			ClientID:  "meal-" + string(rune('a'+i)),
+			EntryType: "meal",
+			Data:      json.RawMessage(payload),
+		}, nil)
+		if err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+	}
+
+	buckets, err := d.AggregateEntries(ctx, p.ID, "meal", GroupByDayOfWeek, "servings", "UTC", time.Time{}, nil)
+	if err != nil {
+		t.Fatalf("AggregateEntries: %v", err)
+	}
+	count := 0
+	for _, b := range buckets {
+		count += b.Count
+	}
+	if count != len(payloads) {
+		t.Errorf("AggregateEntries counted %d entries, want %d", count, len(payloads))
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	rows, err := d.GetMealServings(ctx, p.ID, "UTC", "2024-01-01", today, nil)
+	if err != nil {
+		t.Fatalf("GetMealServings: %v", err)
+	}
+	byDay := map[string]int{}
+	for _, r := range rows {
+		byDay[r.Day] += r.Meals
+	}
+	// The valid timestamp keeps its day; the invalid ones fall back to createdAt
+	if byDay["2024-03-01"] != 1 || byDay[today] != 3 {
+		t.Errorf("GetMealServings days = %v, want 1 on 2024-03-01 and 3 on %s", byDay, today)
+	}
+}
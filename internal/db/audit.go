@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ============ Audit Log Partition Operations ============
+
+// auditPartitionPrefix names the monthly partitions of clingy_coowner_audit,
+// followed by the month as YYYYMM.
+const auditPartitionPrefix = "clingy_coowner_audit_p"
+
+// EnsureAuditPartitions creates the audit log partitions for the month of now
+// and the ahead months after it, if missing. Months are UTC.
+func (d *DB) EnsureAuditPartitions(ctx context.Context, now time.Time, ahead int) error {
+	month := time.Date(now.UTC().Year(), now.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= ahead; i++ {
+		from := month.AddDate(0, i, 0)
+		if err := d.ensureAuditPartition(ctx, from); err != nil {
+			return fmt.Errorf("audit partition %s: %w", from.Format("2006-01"), err)
+		}
+	}
+	return nil
+}
+
+// ensureAuditPartition creates the partition for the month starting at from.
+// Postgres refuses to create it while the default partition holds rows of that
+// month, so those are moved into it: the default is detached, the month
+// created, its rows reinserted and the default attached again, in one transaction.
+func (d *DB) ensureAuditPartition(ctx context.Context, from time.Time) error {
+	name := auditPartitionPrefix + from.Format("200601")
+	to := from.AddDate(0, 1, 0)
+
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.GetContext(ctx, &exists, `SELECT to_regclass($1) IS NOT NULL`, name); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	var stranded bool
+	err = tx.GetContext(ctx, &stranded, `
+		SELECT EXISTS (SELECT 1 FROM clingy_coowner_audit_default WHERE created_at >= $1 AND created_at < $2)
+	`, from, to)
+	if err != nil {
+		return err
+	}
+
+	if stranded {
+		if _, err := tx.ExecContext(ctx, `ALTER TABLE clingy_coowner_audit DETACH PARTITION clingy_coowner_audit_default`); err != nil {
+			return err
+		}
+	}
+	// Partition bounds can't be query parameters; both are formatted here
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(
+		`CREATE TABLE %s PARTITION OF clingy_coowner_audit FOR VALUES FROM ('%s') TO ('%s')`,
+		name, from.Format(time.RFC3339), to.Format(time.RFC3339),
+	))
+	if err != nil {
+		return err
+	}
+	if stranded {
+		_, err = tx.ExecContext(ctx, `
+			WITH moved AS (
+				DELETE FROM clingy_coowner_audit_default WHERE created_at >= $1 AND created_at < $2
+				RETURNING *
+			)
+			INSERT INTO clingy_coowner_audit SELECT * FROM moved
+		`, from, to)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `ALTER TABLE clingy_coowner_audit ATTACH PARTITION clingy_coowner_audit_default DEFAULT`); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DropAuditPartitionsBefore drops the monthly audit log partitions that end
+// on or before cutoff, and returns their names.
+func (d *DB) DropAuditPartitionsBefore(ctx context.Context, cutoff time.Time) ([]string, error) {
+	var names []string
+	err := d.db.SelectContext(ctx, &names, `
+		SELECT c.relname FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = 'clingy_coowner_audit' AND c.relname ~ '^clingy_coowner_audit_p[0-9]{6}$'
+		ORDER BY c.relname
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+	for _, name := range names {
+		from, err := time.Parse("200601", name[len(auditPartitionPrefix):])
+		if err != nil || from.AddDate(0, 1, 0).After(cutoff) {
+			continue
+		}
+		if _, err := d.db.ExecContext(ctx, `DROP TABLE IF EXISTS `+name); err != nil {
+			return dropped, fmt.Errorf("drop %s: %w", name, err)
+		}
+		dropped = append(dropped, name)
+	}
+	return dropped, nil
+}
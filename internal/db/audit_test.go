@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEnsureAuditPartitionsMovesDefaultRows(t *testing.T) {
+	d := testDB(t)
+	p := testPregnancy(t, d)
+	ctx := context.Background()
+
+	// A month far enough ahead that the server hasn't created its partition
+	month := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	t.Cleanup(func() {
+		d.db.ExecContext(context.Background(), `DROP TABLE IF EXISTS `+auditPartitionPrefix+"209901")
+	})
+
+	// Rows written before the partition exists land in the default partition
+	for _, at := range []time.Time{month.Add(time.Hour), month.AddDate(0, 0, 20)} {
+		_, err := d.db.ExecContext(ctx, `
+			INSERT INTO clingy_coowner_audit (pregnancy_id, coowner_id, method, path, status, created_at)
+			VALUES ($1, 'coowner', 'POST', '/api/entries', 200, $2)
+		`, p.ID, at)
+		if err != nil {
+			t.Fatalf("insert audit row: %v", err)
+		}
+	}
+
+	if err := d.EnsureAuditPartitions(ctx, month, 0); err != nil {
+		t.Fatalf("EnsureAuditPartitions: %v", err)
+	}
+
+	var inDefault, inMonth int
+	if err := d.db.GetContext(ctx, &inDefault, `SELECT COUNT(*) FROM clingy_coowner_audit_default WHERE pregnancy_id = $1`, p.ID); err != nil {
+		t.Fatalf("count default: %v", err)
+	}
+	if err := d.db.GetContext(ctx, &inMonth, `SELECT COUNT(*) FROM clingy_coowner_audit_p209901 WHERE pregnancy_id = $1`, p.ID); err != nil {
+		t.Fatalf("count month: %v", err)
+	}
+	if inDefault != 0 || inMonth != 2 {
+		t.Errorf("default has %d rows and month %d, want 0 and 2", inDefault, inMonth)
+	}
+
+	// The default partition is attached again and still catches unpartitioned months
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_coowner_audit (pregnancy_id, coowner_id, method, path, status, created_at)
+		VALUES ($1, 'coowner', 'POST', '/api/entries', 200, $2)
+	`, p.ID, month.AddDate(0, 1, 0))
+	if err != nil {
+		t.Errorf("insert after partitioning: %v", err)
+	}
+
+	// Running again finds the partition and changes nothing
+	if err := d.EnsureAuditPartitions(ctx, month, 0); err != nil {
+		t.Fatalf("EnsureAuditPartitions again: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Backfill Operations ============
+
+// BackfillEntries inserts historical entries in one transaction, keeping each
+// entry's original time as created_at and occurred_at. Entries that already exist (including
+// soft-deleted ones) are left untouched; inserted reports which rows were new.
+// updated_at stays NOW() so incremental sync picks the entries up.
+func (d *DB) BackfillEntries(ctx context.Context, pregnancyID int64, entries []models.EntryRequest, createdAt []time.Time) ([]bool, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	inserted := make([]bool, len(entries))
+	for i, e := range entries {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO clingy_entries (pregnancy_id, client_id, entry_type, data, data_version, created_at, occurred_at, backfilled, visibility)
+			VALUES ($1, $2, $3, $4, COALESCE($5, 1), $6, $6, true, COALESCE($7, 'shared'))
+			ON CONFLICT (pregnancy_id, entry_type, client_id) DO NOTHING
+		`, pregnancyID, e.ClientID, e.EntryType, e.Data, e.DataVersion, createdAt[i], e.Visibility)
+		if err != nil {
+			return nil, err
+		}
+		rows, _ := result.RowsAffected()
+		inserted[i] = rows > 0
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
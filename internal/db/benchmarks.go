@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Benchmark Operations ============
+
+// pregnancyStartSQL dates a pregnancy p like gestation.Start: from its due
+// date, else from start_date read per calculation_method.
+const pregnancyStartSQL = `COALESCE(p.due_date - 280, CASE p.calculation_method
+	WHEN 'lmp' THEN p.start_date + CASE WHEN p.cycle_length BETWEEN 20 AND 45 THEN p.cycle_length - 28 ELSE 0 END
+	WHEN 'conception' THEN p.start_date - 14
+	ELSE p.start_date END)`
+
+// GetCohortAggregates aggregates a numeric payload field across pregnancies by
+// gestational week, for the benchmark job. Each pregnancy contributes its mean
+// for the week, clipped to [lo, hi], so no single pregnancy moves a week's sum
+// by more than the bounds allow. unit, if set, must match the payload's unit.
+// Weeks are dated like the API's week progress. Demo pregnancies and those
+// without a due or start date are left out.
+func (d *DB) GetCohortAggregates(ctx context.Context, entryType, field, unit string, lo, hi float64, firstWeek, lastWeek int) ([]models.CohortAggregate, error) {
+	var rows []models.CohortAggregate
+	err := d.db.SelectContext(ctx, &rows, `
+		WITH per_pregnancy AS (
+			SELECT
+				e.pregnancy_id,
+				FLOOR((COALESCE(e.occurred_at, e.created_at)::date - `+pregnancyStartSQL+`) / 7.0)::int AS week,
+				LEAST(GREATEST(AVG(clingy_try_number(e.data->>$2)), $4), $5) AS value
+			FROM clingy_entries e
+			JOIN clingy_pregnancies p ON p.id = e.pregnancy_id
+			WHERE e.entry_type = $1
+			  AND e.deleted_at IS NULL
+			  AND NOT p.demo
+			  AND COALESCE(p.due_date, p.start_date) IS NOT NULL
+			  AND clingy_try_number(e.data->>$2) IS NOT NULL
+			  AND ($3 = '' OR LOWER(COALESCE(e.data->>'unit', '')) = LOWER($3))
+			GROUP BY 1, 2
+		)
+		SELECT week, COUNT(*) AS users, SUM(value) AS sum
+		FROM per_pregnancy
+		WHERE week BETWEEN $6 AND $7
+		GROUP BY week
+		ORDER BY week
+	`, entryType, field, unit, lo, hi, firstWeek, lastWeek)
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ReplaceBenchmarks swaps a metric's released buckets for a new release.
+func (d *DB) ReplaceBenchmarks(ctx context.Context, metric string, buckets []models.BenchmarkBucket) error {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM clingy_benchmarks WHERE metric = $1`, metric); err != nil {
+		return err
+	}
+	for _, b := range buckets {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO clingy_benchmarks (metric, week, suppressed, users, mean, epsilon, min_cohort)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, metric, b.Week, b.Suppressed, b.Users, b.Mean, b.Epsilon, b.MinCohort)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetBenchmarks gets a metric's released buckets by week.
+func (d *DB) GetBenchmarks(ctx context.Context, metric string) ([]models.BenchmarkBucket, error) {
+	var buckets []models.BenchmarkBucket
+	err := d.db.SelectContext(ctx, &buckets, `
+		SELECT * FROM clingy_benchmarks WHERE metric = $1 ORDER BY week
+	`, metric)
+	if err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Birth Detail Operations ============
+
+// BabyProfileSetting is the setting type seeded from birth details.
+const BabyProfileSetting = "baby_profile"
+
+// GetBirthDetails gets a pregnancy's birth details.
+func (d *DB) GetBirthDetails(ctx context.Context, pregnancyID int64) (*models.BirthDetails, error) {
+	var b models.BirthDetails
+	err := d.db.GetContext(ctx, &b, `SELECT * FROM clingy_birth_details WHERE pregnancy_id = $1`, pregnancyID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// SaveBirthDetails records a pregnancy's birth details and seeds the baby
+// profile setting from them unless the family already has one (even a deleted
+// one). It reports whether the profile was seeded.
+func (d *DB) SaveBirthDetails(ctx context.Context, b *models.BirthDetails, profile json.RawMessage) (*models.BirthDetails, bool, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var saved models.BirthDetails
+	err = tx.GetContext(ctx, &saved, `
+		INSERT INTO clingy_birth_details (pregnancy_id, born_at, weight_grams, length_cm, delivery_type, archive_at, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (pregnancy_id) DO UPDATE SET
+			born_at = EXCLUDED.born_at,
+			weight_grams = EXCLUDED.weight_grams,
+			length_cm = EXCLUDED.length_cm,
+			delivery_type = EXCLUDED.delivery_type,
+			archive_at = EXCLUDED.archive_at,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW()
+		RETURNING *
+	`, b.PregnancyID, b.BornAt, b.WeightGrams, b.LengthCm, b.DeliveryType, b.ArchiveAt, b.UpdatedBy)
+	if err != nil {
+		return nil, false, err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO clingy_settings (pregnancy_id, setting_type, data)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (pregnancy_id, setting_type) DO NOTHING
+	`, b.PregnancyID, BabyProfileSetting, profile)
+	if err != nil {
+		return nil, false, err
+	}
+	seeded, _ := result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return &saved, seeded > 0, nil
+}
+
+// ArchiveDueBirths archives pregnancies whose birth follow-up period has ended
+// and returns their IDs. Each pregnancy is auto-archived at most once, so one
+// the owner unarchives stays unarchived.
+func (d *DB) ArchiveDueBirths(ctx context.Context) ([]int64, error) {
+	var ids []int64
+	err := d.db.SelectContext(ctx, &ids, `
+		WITH due AS (
+			UPDATE clingy_birth_details SET auto_archived_at = NOW()
+			WHERE archive_at <= NOW() AND auto_archived_at IS NULL
+			RETURNING pregnancy_id
+		)
+		UPDATE clingy_pregnancies p SET
+			archived = true,
+			archived_at = NOW(),
+			updated_at = NOW()
+		FROM due
+		WHERE p.id = due.pregnancy_id AND NOT p.archived AND p.outcome = 'birth'
+		RETURNING p.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
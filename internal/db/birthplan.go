@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/textmerge"
+)
+
+// ============ Birth Plan Operations ============
+
+// ErrLocked is returned when someone else holds a birth plan section's lock.
+var ErrLocked = errors.New("locked")
+
+// birthPlanRevisionsKept is how many past versions of a section are kept as
+// merge bases. Edits against older versions conflict.
+const birthPlanRevisionsKept = 100
+
+// GetBirthPlan lists the sections of a pregnancy's birth plan that were ever
+// edited or locked.
+func (d *DB) GetBirthPlan(ctx context.Context, pregnancyID int64) ([]models.BirthPlanSection, error) {
+	var sections []models.BirthPlanSection
+	err := d.db.SelectContext(ctx, &sections, `
+		SELECT * FROM clingy_birth_plan_sections WHERE pregnancy_id = $1 ORDER BY section
+	`, pregnancyID)
+	return sections, err
+}
+
+// lockBirthPlanSection creates the section if needed and locks its row for
+// the rest of the transaction.
+func lockBirthPlanSection(ctx context.Context, tx *sqlx.Tx, pregnancyID int64, section string) (*models.BirthPlanSection, error) {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO clingy_birth_plan_sections (pregnancy_id, section) VALUES ($1, $2)
+		ON CONFLICT (pregnancy_id, section) DO NOTHING
+	`, pregnancyID, section)
+	if err != nil {
+		return nil, err
+	}
+
+	var s models.BirthPlanSection
+	err = tx.GetContext(ctx, &s, `
+		SELECT * FROM clingy_birth_plan_sections WHERE pregnancy_id = $1 AND section = $2 FOR UPDATE
+	`, pregnancyID, section)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// lockedByOther reports whether someone other than userID holds the lock.
+func lockedByOther(s *models.BirthPlanSection, userID string, now time.Time) bool {
+	return s.LockedBy.Valid && s.LockedBy.String != userID && s.LockExpiresAt.Valid && s.LockExpiresAt.Time.After(now)
+}
+
+// LockBirthPlanSection gives userID the section's lock until the given time,
+// renewing it if they already hold it. If someone else holds it, the section
+// is returned with ErrLocked.
+func (d *DB) LockBirthPlanSection(ctx context.Context, pregnancyID int64, section, userID string, until time.Time) (*models.BirthPlanSection, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	s, err := lockBirthPlanSection(ctx, tx, pregnancyID, section)
+	if err != nil {
+		return nil, err
+	}
+	if lockedByOther(s, userID, time.Now()) {
+		return s, ErrLocked
+	}
+
+	err = tx.GetContext(ctx, s, `
+		UPDATE clingy_birth_plan_sections
+		SET locked_by = $3, lock_expires_at = $4, changed_at = NOW()
+		WHERE pregnancy_id = $1 AND section = $2
+		RETURNING *
+	`, pregnancyID, section, userID, until)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// UnlockBirthPlanSection releases userID's lock on a section, or returns
+// ErrNotFound if they don't hold it.
+func (d *DB) UnlockBirthPlanSection(ctx context.Context, pregnancyID int64, section, userID string) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_birth_plan_sections
+		SET locked_by = NULL, lock_expires_at = NULL, changed_at = NOW()
+		WHERE pregnancy_id = $1 AND section = $2 AND locked_by = $3
+	`, pregnancyID, section, userID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SaveBirthPlanSection saves content written against baseVersion. If the
+// section changed since, the edits are merged line by line with the ones made
+// in between; it reports whether that happened. The current section is
+// returned with ErrLocked if someone else holds the lock, and with
+// ErrConflict if the edits overlap or the base version is no longer kept.
+func (d *DB) SaveBirthPlanSection(ctx context.Context, pregnancyID int64, section, userID, content string, baseVersion int) (*models.BirthPlanSection, bool, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	s, err := lockBirthPlanSection(ctx, tx, pregnancyID, section)
+	if err != nil {
+		return nil, false, err
+	}
+	if lockedByOther(s, userID, time.Now()) {
+		return s, false, ErrLocked
+	}
+
+	merged := false
+	if baseVersion != s.Version {
+		if baseVersion > s.Version {
+			return s, false, ErrConflict
+		}
+		base := ""
+		if baseVersion > 0 {
+			err := tx.GetContext(ctx, &base, `
+				SELECT content FROM clingy_birth_plan_revisions
+				WHERE pregnancy_id = $1 AND section = $2 AND version = $3
+			`, pregnancyID, section, baseVersion)
+			if err == sql.ErrNoRows {
+				return s, false, ErrConflict
+			}
+			if err != nil {
+				return nil, false, err
+			}
+		}
+		var ok bool
+		if content, ok = textmerge.Merge(base, s.Content, content); !ok {
+			return s, false, ErrConflict
+		}
+		merged = true
+	}
+	if content == s.Content {
+		return s, merged, tx.Commit()
+	}
+
+	err = tx.GetContext(ctx, s, `
+		UPDATE clingy_birth_plan_sections
+		SET content = $3, version = version + 1, updated_by = $4, updated_at = NOW(), changed_at = NOW()
+		WHERE pregnancy_id = $1 AND section = $2
+		RETURNING *
+	`, pregnancyID, section, content, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO clingy_birth_plan_revisions (pregnancy_id, section, version, content, updated_by)
+		VALUES ($1, $2, $3, $4, $5)
+	`, pregnancyID, section, s.Version, content, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM clingy_birth_plan_revisions
+		WHERE pregnancy_id = $1 AND section = $2 AND version <= $3
+	`, pregnancyID, section, s.Version-birthPlanRevisionsKept)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return s, merged, nil
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ User Block Operations ============
+
+// CreateUserBlock blocks a user, updating the reason if they are already
+// blocked. Pending pairing requests from the blocked user are denied.
+func (d *DB) CreateUserBlock(ctx context.Context, blockerID, blockedID string, reason sql.NullString) (*models.UserBlock, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var b models.UserBlock
+	err = tx.QueryRowxContext(ctx, `
+		INSERT INTO clingy_user_blocks (blocker_id, blocked_id, reason)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (blocker_id, blocked_id) DO UPDATE SET reason = EXCLUDED.reason
+		RETURNING *
+	`, blockerID, blockedID, reason).StructScan(&b)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE clingy_pairing_requests SET status = 'denied', resolved_at = NOW()
+		WHERE requester_id = $1 AND target_id = $2 AND status = 'pending'
+	`, blockedID, blockerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetUserBlocks lists the users a user blocked, newest first.
+func (d *DB) GetUserBlocks(ctx context.Context, blockerID string) ([]models.UserBlock, error) {
+	var blocks []models.UserBlock
+	err := d.db.SelectContext(ctx, &blocks, `
+		SELECT * FROM clingy_user_blocks
+		WHERE blocker_id = $1
+		ORDER BY created_at DESC
+	`, blockerID)
+	return blocks, err
+}
+
+// DeleteUserBlock unblocks a user.
+func (d *DB) DeleteUserBlock(ctx context.Context, blockerID, blockedID string) error {
+	result, err := d.db.ExecContext(ctx, `
+		DELETE FROM clingy_user_blocks WHERE blocker_id = $1 AND blocked_id = $2
+	`, blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// IsUserBlocked reports whether blockerID blocked blockedID.
+func (d *DB) IsUserBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	var blocked bool
+	err := d.db.GetContext(ctx, &blocked, `
+		SELECT EXISTS (
+			SELECT 1 FROM clingy_user_blocks WHERE blocker_id = $1 AND blocked_id = $2
+		)
+	`, blockerID, blockedID)
+	return blocked, err
+}
+
+// IsBlockedByPregnancy reports whether the pregnancy's owner or co-owner
+// blocked userID.
+func (d *DB) IsBlockedByPregnancy(ctx context.Context, pregnancyID int64, userID string) (bool, error) {
+	var blocked bool
+	err := d.db.GetContext(ctx, &blocked, `
+		SELECT EXISTS (
+			SELECT 1 FROM clingy_user_blocks b
+			JOIN clingy_pregnancies p ON b.blocker_id IN (p.owner_id, p.coowner_id)
+			WHERE p.id = $1 AND b.blocked_id = $2
+		)
+	`, pregnancyID, userID)
+	return blocked, err
+}
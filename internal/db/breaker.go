@@ -0,0 +1,341 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Circuit Breaker ============
+
+// ErrCircuitOpen is returned without touching the database while the breaker is open.
+var ErrCircuitOpen = errors.New("database circuit breaker is open")
+
+// Breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// The breaker opens when at least breakerMinCalls calls in the last
+// breakerBuckets seconds failed at breakerFailureRate or more. After
+// breakerCooldown it lets breakerProbes requests through; that many successes
+// close it again, any failure reopens it.
+const (
+	breakerBuckets     = 10
+	breakerMinCalls    = 20
+	breakerFailureRate = 0.5
+	breakerCooldown    = 15 * time.Second
+	breakerProbes      = 3
+)
+
+// breakerBucket counts call outcomes for one second.
+type breakerBucket struct {
+	second int64
+	ok     int
+	failed int
+}
+
+type breaker struct {
+	mu             sync.Mutex
+	state          string
+	buckets        [breakerBuckets]breakerBucket
+	openedAt       time.Time
+	probes         int // Half-open requests in flight
+	probeSuccesses int
+	opens          int64
+	rejected       int64
+	lastFailure    string
+}
+
+func newBreaker() *breaker {
+	return &breaker{state: BreakerClosed}
+}
+
+// allow reports whether a database call may run. An open breaker turns
+// half-open once the cooldown has passed.
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		if now.Sub(b.openedAt) < breakerCooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probes = 0
+		b.probeSuccesses = 0
+	}
+	return true
+}
+
+// admit decides whether a request may start. While half-open only a few probe
+// requests run at once; release must be called when an admitted request ends.
+func (b *breaker) admit(now time.Time) (release func(), retryAfter time.Duration, ok bool) {
+	if !b.allow(now) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.rejected++
+		return nil, breakerCooldown - now.Sub(b.openedAt), false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != BreakerHalfOpen {
+		return func() {}, 0, true
+	}
+	if b.probes >= breakerProbes {
+		b.rejected++
+		return nil, time.Second, false
+	}
+	b.probes++
+	return func() {
+		b.mu.Lock()
+		if b.probes > 0 {
+			b.probes--
+		}
+		b.mu.Unlock()
+	}, 0, true
+}
+
+// record counts the outcome of a database call.
+func (b *breaker) record(err error, now time.Time) {
+	failed := breakerFailure(err)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if failed {
+		b.lastFailure = err.Error()
+	}
+
+	switch b.state {
+	case BreakerHalfOpen:
+		if failed {
+			b.trip(now)
+			return
+		}
+		b.probeSuccesses++
+		if b.probeSuccesses >= breakerProbes {
+			b.state = BreakerClosed
+			b.buckets = [breakerBuckets]breakerBucket{}
+		}
+	case BreakerClosed:
+		bucket := b.bucket(now)
+		if failed {
+			bucket.failed++
+		} else {
+			bucket.ok++
+		}
+		if calls, failures := b.window(now); calls >= breakerMinCalls && float64(failures)/float64(calls) >= breakerFailureRate {
+			b.trip(now)
+		}
+	}
+}
+
+// reset closes the breaker and forgets recent calls, for a fresh pool.
+func (b *breaker) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = BreakerClosed
+	b.buckets = [breakerBuckets]breakerBucket{}
+	b.probes = 0
+	b.probeSuccesses = 0
+}
+
+func (b *breaker) trip(now time.Time) {
+	b.state = BreakerOpen
+	b.openedAt = now
+	b.opens++
+}
+
+// bucket returns the bucket for the current second, clearing it if it is stale.
+func (b *breaker) bucket(now time.Time) *breakerBucket {
+	second := now.Unix()
+	bucket := &b.buckets[second%breakerBuckets]
+	if bucket.second != second {
+		*bucket = breakerBucket{second: second}
+	}
+	return bucket
+}
+
+// window sums calls and failures over the last breakerBuckets seconds.
+func (b *breaker) window(now time.Time) (calls, failures int) {
+	oldest := now.Unix() - breakerBuckets
+	for _, bucket := range b.buckets {
+		if bucket.second > oldest {
+			calls += bucket.ok + bucket.failed
+			failures += bucket.failed
+		}
+	}
+	return calls, failures
+}
+
+// breakerFailure reports whether err means the database itself is in trouble,
+// as opposed to a query-level error like a missing row or a constraint violation.
+func breakerFailure(err error) bool {
+	if err == nil || errors.Is(err, sql.ErrNoRows) || errors.Is(err, context.Canceled) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && len(pgErr.Code) >= 2 {
+		// Connection exceptions, insufficient resources, operator intervention
+		// (including statement timeouts) and system errors
+		switch pgErr.Code[:2] {
+		case "08", "53", "57", "58":
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// conn is *sqlx.DB with every call reported to the circuit breaker. Calls fail
+// fast while the breaker is open, and their errors are classified (Classify).
+// The pool is swapped when failover moves to the other server.
+type conn struct {
+	pool     atomic.Pointer[sqlx.DB]
+	breaker  *breaker
+	latency  latencyTracker
+	failover *failover // nil without a standby
+}
+
+// current returns the pool calls go to.
+func (c *conn) current() *sqlx.DB {
+	return c.pool.Load()
+}
+
+// done reports a finished call to the breaker and the latency tracker.
+func (c *conn) done(err error, start time.Time) {
+	now := time.Now()
+	c.breaker.record(err, now)
+	c.latency.observe(now.Sub(start), now)
+}
+
+func (c *conn) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	if !c.breaker.allow(time.Now()) {
+		return ErrCircuitOpen
+	}
+	start := time.Now()
+	err := c.current().GetContext(ctx, dest, query, args...)
+	c.done(err, start)
+	return Classify(err)
+}
+
+func (c *conn) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	if !c.breaker.allow(time.Now()) {
+		return ErrCircuitOpen
+	}
+	start := time.Now()
+	err := c.current().SelectContext(ctx, dest, query, args...)
+	c.done(err, start)
+	return Classify(err)
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if !c.breaker.allow(time.Now()) {
+		return nil, ErrCircuitOpen
+	}
+	start := time.Now()
+	result, err := c.current().ExecContext(ctx, query, args...)
+	c.done(err, start)
+	return result, Classify(err)
+}
+
+func (c *conn) QueryRowxContext(ctx context.Context, query string, args ...interface{}) *sqlx.Row {
+	if !c.breaker.allow(time.Now()) {
+		// sqlx.Row can't carry our own error; a canceled context fails before a connection is taken
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		return c.current().QueryRowxContext(canceled, query, args...)
+	}
+	start := time.Now()
+	row := c.current().QueryRowxContext(ctx, query, args...)
+	c.done(row.Err(), start)
+	return row
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if !c.breaker.allow(time.Now()) {
+		return nil, ErrCircuitOpen
+	}
+	start := time.Now()
+	rows, err := c.current().QueryContext(ctx, query, args...)
+	c.done(err, start)
+	return rows, Classify(err)
+}
+
+func (c *conn) QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error) {
+	if !c.breaker.allow(time.Now()) {
+		return nil, ErrCircuitOpen
+	}
+	start := time.Now()
+	rows, err := c.current().QueryxContext(ctx, query, args...)
+	c.done(err, start)
+	return rows, Classify(err)
+}
+
+func (c *conn) BeginTxx(ctx context.Context, opts *sql.TxOptions) (*sqlx.Tx, error) {
+	if !c.breaker.allow(time.Now()) {
+		return nil, ErrCircuitOpen
+	}
+	start := time.Now()
+	tx, err := c.current().BeginTxx(ctx, opts)
+	c.done(err, start)
+	return tx, Classify(err)
+}
+
+// AdmitRequest decides whether an API request may start. When it may not,
+// retryAfter says when to try again; otherwise release must be called when the
+// request is done.
+func (d *DB) AdmitRequest() (release func(), retryAfter time.Duration, ok bool) {
+	return d.db.breaker.admit(time.Now())
+}
+
+// Ping checks the database connection, counting the outcome in the breaker.
+func (d *DB) Ping(ctx context.Context) error {
+	if !d.db.breaker.allow(time.Now()) {
+		return ErrCircuitOpen
+	}
+	err := d.db.current().PingContext(ctx)
+	d.db.breaker.record(err, time.Now())
+	return err
+}
+
+// BreakerStatus reports the circuit breaker state and counters.
+func (d *DB) BreakerStatus() models.BreakerStatus {
+	b := d.db.breaker
+	now := time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	calls, failures := b.window(now)
+	status := models.BreakerStatus{
+		State:       b.state,
+		Calls:       calls,
+		Failures:    failures,
+		Opens:       b.opens,
+		Rejected:    b.rejected,
+		LastFailure: b.lastFailure,
+		Failover:    d.db.failover.status(),
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt.UTC()
+		status.OpenedAt = &openedAt
+	}
+	if b.state == BreakerOpen {
+		if remaining := breakerCooldown - now.Sub(b.openedAt); remaining > 0 {
+			status.RetryAfter = int(remaining.Seconds()) + 1
+		}
+	}
+	return status
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Calendar Feed Operations ============
+
+// CreateCalendarFeed stores a new calendar feed by its token's hash.
+func (d *DB) CreateCalendarFeed(ctx context.Context, userID, name, tokenHash, prefix string) (*models.CalendarFeed, error) {
+	var f models.CalendarFeed
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_calendar_feeds (user_id, name, token_hash, prefix)
+		VALUES ($1, $2, $3, $4)
+		RETURNING *
+	`, userID, name, tokenHash, prefix).StructScan(&f)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// GetCalendarFeeds lists a user's calendar feeds that are not revoked, newest first.
+func (d *DB) GetCalendarFeeds(ctx context.Context, userID string) ([]models.CalendarFeed, error) {
+	var feeds []models.CalendarFeed
+	err := d.db.SelectContext(ctx, &feeds, `
+		SELECT * FROM clingy_calendar_feeds
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`, userID)
+	return feeds, err
+}
+
+// CountActiveCalendarFeeds counts a user's calendar feeds that are not revoked.
+func (d *DB) CountActiveCalendarFeeds(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := d.db.GetContext(ctx, &count, `
+		SELECT COUNT(*) FROM clingy_calendar_feeds
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	return count, err
+}
+
+// GetActiveCalendarFeed finds an unrevoked calendar feed by hash and records that it was used.
+func (d *DB) GetActiveCalendarFeed(ctx context.Context, tokenHash string) (*models.CalendarFeed, error) {
+	var f models.CalendarFeed
+	err := d.db.GetContext(ctx, &f, `
+		SELECT * FROM clingy_calendar_feeds
+		WHERE token_hash = $1 AND revoked_at IS NULL
+	`, tokenHash)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Calendar apps poll, so last use is tracked to the minute like widget tokens
+	_, err = d.db.ExecContext(ctx, `
+		UPDATE clingy_calendar_feeds SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`, f.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// RevokeCalendarFeed revokes one of a user's calendar feeds.
+func (d *DB) RevokeCalendarFeed(ctx context.Context, feedID int64, userID string) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_calendar_feeds SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, feedID, userID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Care Provider Operations ============
+
+// GetCareProviders gets all active care providers for a pregnancy.
+func (d *DB) GetCareProviders(ctx context.Context, pregnancyID int64) ([]models.CareProvider, error) {
+	var providers []models.CareProvider
+	err := d.db.SelectContext(ctx, &providers, `
+		SELECT * FROM clingy_care_providers
+		WHERE pregnancy_id = $1 AND removed_at IS NULL
+		ORDER BY joined_at DESC
+	`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	return providers, nil
+}
+
+// GetPregnancyByProvider gets the pregnancy where user is a linked care provider.
+func (d *DB) GetPregnancyByProvider(ctx context.Context, userID string) (*models.Pregnancy, error) {
+	var p models.Pregnancy
+	err := d.db.GetContext(ctx, &p, `
+		SELECT p.* FROM clingy_pregnancies p
+		JOIN clingy_care_providers c ON c.pregnancy_id = p.id
+		WHERE c.user_id = $1 AND c.removed_at IS NULL
+		ORDER BY c.joined_at DESC
+		LIMIT 1
+	`, userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// RemoveCareProvider removes a care provider (soft delete).
+func (d *DB) RemoveCareProvider(ctx context.Context, providerID int64, ownerID string) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_care_providers SET removed_at = NOW()
+		WHERE id = $1
+		  AND pregnancy_id IN (SELECT id FROM clingy_pregnancies WHERE owner_id = $2)
+		  AND removed_at IS NULL
+	`, providerID, ownerID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ============ Care Note Operations ============
+
+// GetCareNotes gets all care notes for a pregnancy, oldest first.
+func (d *DB) GetCareNotes(ctx context.Context, pregnancyID int64) ([]models.CareNote, error) {
+	var notes []models.CareNote
+	err := d.db.SelectContext(ctx, &notes, `
+		SELECT * FROM clingy_care_notes
+		WHERE pregnancy_id = $1 AND deleted_at IS NULL
+		ORDER BY created_at ASC
+	`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// CreateCareNote creates a care note.
+func (d *DB) CreateCareNote(ctx context.Context, pregnancyID int64, authorID, authorRole, body string) (*models.CareNote, error) {
+	var n models.CareNote
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_care_notes (pregnancy_id, author_id, author_role, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING *
+	`, pregnancyID, authorID, authorRole, body).StructScan(&n)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// UpdateCareNote updates a care note. Only the author can edit their note.
+func (d *DB) UpdateCareNote(ctx context.Context, pregnancyID, noteID int64, authorID, body string) (*models.CareNote, error) {
+	var n models.CareNote
+	err := d.db.QueryRowxContext(ctx, `
+		UPDATE clingy_care_notes SET body = $4, updated_at = NOW()
+		WHERE id = $1 AND pregnancy_id = $2 AND author_id = $3 AND deleted_at IS NULL
+		RETURNING *
+	`, noteID, pregnancyID, authorID, body).StructScan(&n)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// DeleteCareNote soft deletes a care note. Only the author can delete their note.
+func (d *DB) DeleteCareNote(ctx context.Context, pregnancyID, noteID int64, authorID string) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_care_notes SET deleted_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND pregnancy_id = $2 AND author_id = $3 AND deleted_at IS NULL
+	`, noteID, pregnancyID, authorID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
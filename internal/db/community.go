@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Community Topic Operations ============
+
+// GetCommunityTopics gets the community topics linked to a week, in order.
+func (d *DB) GetCommunityTopics(ctx context.Context, week int) ([]models.CommunityTopic, error) {
+	var topics []models.CommunityTopic
+	err := d.db.SelectContext(ctx, &topics, `
+		SELECT * FROM clingy_community_topics WHERE week = $1 ORDER BY position, id
+	`, week)
+	if err != nil {
+		return nil, err
+	}
+	return topics, nil
+}
+
+// ReplaceCommunityTopics swaps a week's community topics for a new list, kept
+// in the order given.
+func (d *DB) ReplaceCommunityTopics(ctx context.Context, week int, topics []models.CommunityTopic, updatedBy string) ([]models.CommunityTopic, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM clingy_community_topics WHERE week = $1`, week); err != nil {
+		return nil, err
+	}
+	saved := make([]models.CommunityTopic, 0, len(topics))
+	for i, t := range topics {
+		var topic models.CommunityTopic
+		err := tx.QueryRowxContext(ctx, `
+			INSERT INTO clingy_community_topics (week, service, topic_id, title, position, updated_by)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING *
+		`, week, t.Service, t.TopicID, t.Title, i, updatedBy).StructScan(&topic)
+		if err != nil {
+			return nil, err
+		}
+		saved = append(saved, topic)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Content Operations ============
+
+// SeedContent publishes the given weeks as version 1 when the kind has no content
+// yet. It returns how many weeks were inserted.
+func (d *DB) SeedContent(ctx context.Context, kind string, weeks map[int]json.RawMessage) (int, error) {
+	var exists bool
+	if err := d.db.GetContext(ctx, &exists, `
+		SELECT EXISTS(SELECT 1 FROM clingy_content WHERE kind = $1)
+	`, kind); err != nil || exists {
+		return 0, err
+	}
+
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	inserted := 0
+	for week, data := range weeks {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO clingy_content (kind, week, version, status, data, published_at)
+			VALUES ($1, $2, 1, 'published', $3, NOW())
+			ON CONFLICT (kind, week, version) DO NOTHING
+		`, kind, week, data)
+		if err != nil {
+			return 0, err
+		}
+		rows, _ := result.RowsAffected()
+		inserted += int(rows)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return inserted, nil
+}
+
+// GetPublishedContent returns the published version of every week, in week order.
+func (d *DB) GetPublishedContent(ctx context.Context, kind string) ([]models.ContentVersion, error) {
+	var content []models.ContentVersion
+	err := d.db.SelectContext(ctx, &content, `
+		SELECT * FROM clingy_content WHERE kind = $1 AND status = 'published' ORDER BY week
+	`, kind)
+	return content, err
+}
+
+// GetContentVersions lists versions of a kind, newest first within each week.
+// week 0 and status "" match everything.
+func (d *DB) GetContentVersions(ctx context.Context, kind string, week int, status string) ([]models.ContentVersion, error) {
+	var content []models.ContentVersion
+	err := d.db.SelectContext(ctx, &content, `
+		SELECT * FROM clingy_content
+		WHERE kind = $1 AND ($2 = 0 OR week = $2) AND ($3 = '' OR status = $3)
+		ORDER BY week, version DESC
+	`, kind, week, status)
+	return content, err
+}
+
+// GetContentVersion returns one version of a week.
+func (d *DB) GetContentVersion(ctx context.Context, kind string, week, version int) (*models.ContentVersion, error) {
+	var c models.ContentVersion
+	err := d.db.GetContext(ctx, &c, `
+		SELECT * FROM clingy_content WHERE kind = $1 AND week = $2 AND version = $3
+	`, kind, week, version)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// CreateContentDraft adds a draft as the week's next version.
+func (d *DB) CreateContentDraft(ctx context.Context, draft *models.ContentVersion) (*models.ContentVersion, error) {
+	var c models.ContentVersion
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_content (kind, week, version, status, data, accessibility, simplified, simplified_accessibility,
+			sources, reviewed_by, reviewed_at, created_by)
+		SELECT $1, $2, COALESCE(MAX(version), 0) + 1, 'draft', $3, $4, $5, $6, $7, $8, $9, $10
+		FROM clingy_content WHERE kind = $1 AND week = $2
+		RETURNING *
+	`, draft.Kind, draft.Week, draft.Data, draft.Accessibility, draft.Simplified, draft.SimplifiedAccessibility,
+		draft.Sources, draft.ReviewedBy, draft.ReviewedAt, draft.CreatedBy).StructScan(&c)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// UpdateContentDraft replaces a draft's data, simplified text, accessibility
+// metadata, sources and review. Returns ErrConflict when the version is no
+// longer a draft.
+func (d *DB) UpdateContentDraft(ctx context.Context, draft *models.ContentVersion) (*models.ContentVersion, error) {
+	var c models.ContentVersion
+	err := d.db.QueryRowxContext(ctx, `
+		UPDATE clingy_content SET
+			data = $4, accessibility = $5, simplified = $6, simplified_accessibility = $7,
+			sources = $8, reviewed_by = $9, reviewed_at = $10, updated_at = NOW()
+		WHERE kind = $1 AND week = $2 AND version = $3 AND status = 'draft'
+		RETURNING *
+	`, draft.Kind, draft.Week, draft.Version, draft.Data, draft.Accessibility, draft.Simplified, draft.SimplifiedAccessibility,
+		draft.Sources, draft.ReviewedBy, draft.ReviewedAt).StructScan(&c)
+	if err == sql.ErrNoRows {
+		return nil, d.contentMissingOrConflict(ctx, draft.Kind, draft.Week, draft.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// DeleteContentDraft deletes a draft. Returns ErrConflict when the version is
+// no longer a draft.
+func (d *DB) DeleteContentDraft(ctx context.Context, kind string, week, version int) error {
+	result, err := d.db.ExecContext(ctx, `
+		DELETE FROM clingy_content WHERE kind = $1 AND week = $2 AND version = $3 AND status = 'draft'
+	`, kind, week, version)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return d.contentMissingOrConflict(ctx, kind, week, version)
+	}
+	return nil
+}
+
+// PublishContent makes a version the week's published one, retiring the
+// previously published version. Retired versions can be published again to roll back.
+func (d *DB) PublishContent(ctx context.Context, kind string, week, version int) (*models.ContentVersion, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE clingy_content SET status = 'retired', updated_at = NOW()
+		WHERE kind = $1 AND week = $2 AND version != $3 AND status = 'published'
+	`, kind, week, version)
+	if err != nil {
+		return nil, err
+	}
+
+	var c models.ContentVersion
+	err = tx.QueryRowxContext(ctx, `
+		UPDATE clingy_content SET
+			status = 'published',
+			published_at = CASE WHEN status = 'published' THEN published_at ELSE NOW() END,
+			updated_at = NOW()
+		WHERE kind = $1 AND week = $2 AND version = $3
+		RETURNING *
+	`, kind, week, version).StructScan(&c)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// UnpublishContent retires a week's published version, removing the week from
+// the public data.
+func (d *DB) UnpublishContent(ctx context.Context, kind string, week int) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_content SET status = 'retired', updated_at = NOW()
+		WHERE kind = $1 AND week = $2 AND status = 'published'
+	`, kind, week)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ReviewContent records a medical review of a version of any status, replacing
+// its sources when sources is not nil. Reviews don't change the content itself,
+// so published versions can be re-reviewed in place.
+func (d *DB) ReviewContent(ctx context.Context, kind string, week, version int, reviewedBy string, reviewedAt time.Time, sources json.RawMessage) (*models.ContentVersion, error) {
+	var c models.ContentVersion
+	err := d.db.QueryRowxContext(ctx, `
+		UPDATE clingy_content SET
+			reviewed_by = $4, reviewed_at = $5, sources = COALESCE($6, sources), updated_at = NOW()
+		WHERE kind = $1 AND week = $2 AND version = $3
+		RETURNING *
+	`, kind, week, version, reviewedBy, reviewedAt, sources).StructScan(&c)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetStaleContent lists the published versions of a kind that were never
+// reviewed or last reviewed before reviewedBefore, least recently reviewed first.
+func (d *DB) GetStaleContent(ctx context.Context, kind string, reviewedBefore time.Time) ([]models.ContentVersion, error) {
+	var content []models.ContentVersion
+	err := d.db.SelectContext(ctx, &content, `
+		SELECT * FROM clingy_content
+		WHERE kind = $1 AND status = 'published' AND (reviewed_at IS NULL OR reviewed_at < $2)
+		ORDER BY reviewed_at NULLS FIRST, week
+	`, kind, reviewedBefore)
+	return content, err
+}
+
+// contentMissingOrConflict tells a missing version apart from one that is not a draft.
+func (d *DB) contentMissingOrConflict(ctx context.Context, kind string, week, version int) error {
+	if _, err := d.GetContentVersion(ctx, kind, week, version); err != nil {
+		return err
+	}
+	return ErrConflict
+}
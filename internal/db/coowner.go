@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/pagination"
+)
+
+// ============ Coowner Operations ============
+
+// recordCoownerEvent adds an entry to a pregnancy's coowner history.
+func recordCoownerEvent(ctx context.Context, tx *sqlx.Tx, pregnancyID int64, coownerID, coownerName, action, actorID string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO clingy_coowner_history (pregnancy_id, coowner_id, coowner_name, action, actor_id)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+	`, pregnancyID, coownerID, coownerName, action, actorID)
+	return err
+}
+
+// linkCoowner makes the user the pregnancy's coowner, recording the change and
+// the coowner it replaces.
+func linkCoowner(ctx context.Context, tx *sqlx.Tx, pregnancyID int64, userID, displayName string) error {
+	var previous struct {
+		ID   sql.NullString `db:"coowner_id"`
+		Name sql.NullString `db:"coowner_name"`
+	}
+	err := tx.GetContext(ctx, &previous, `
+		SELECT coowner_id, coowner_name FROM clingy_pregnancies WHERE id = $1 FOR UPDATE
+	`, pregnancyID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE clingy_pregnancies SET
+			coowner_id = $1,
+			coowner_name = $2,
+			updated_at = NOW()
+		WHERE id = $3
+	`, userID, displayName, pregnancyID)
+	if err != nil {
+		return err
+	}
+
+	if previous.ID.String == userID {
+		return nil
+	}
+	if previous.ID.Valid {
+		if err := recordCoownerEvent(ctx, tx, pregnancyID, previous.ID.String, previous.Name.String, "replaced", userID); err != nil {
+			return err
+		}
+	}
+	return recordCoownerEvent(ctx, tx, pregnancyID, userID, displayName, "linked", userID)
+}
+
+// RemoveCoowner unlinks the pregnancy's coowner. The action is recorded as
+// 'left' when the coowner removes themselves and 'removed' otherwise. It returns
+// the removed coowner's ID.
+func (d *DB) RemoveCoowner(ctx context.Context, pregnancyID int64, actorID string) (string, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var coowner struct {
+		ID   sql.NullString `db:"coowner_id"`
+		Name sql.NullString `db:"coowner_name"`
+	}
+	err = tx.GetContext(ctx, &coowner, `
+		SELECT coowner_id, coowner_name FROM clingy_pregnancies WHERE id = $1 FOR UPDATE
+	`, pregnancyID)
+	if err == sql.ErrNoRows || (err == nil && !coowner.ID.Valid) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE clingy_pregnancies SET coowner_id = NULL, coowner_name = NULL, updated_at = NOW()
+		WHERE id = $1
+	`, pregnancyID)
+	if err != nil {
+		return "", err
+	}
+
+	action := "removed"
+	if actorID == coowner.ID.String {
+		action = "left"
+	}
+	if err := recordCoownerEvent(ctx, tx, pregnancyID, coowner.ID.String, coowner.Name.String, action, actorID); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return coowner.ID.String, nil
+}
+
+// GetCoownerHistory gets a pregnancy's coowner changes, most recent first.
+func (d *DB) GetCoownerHistory(ctx context.Context, pregnancyID int64) ([]models.CoownerEvent, error) {
+	var events []models.CoownerEvent
+	err := d.db.SelectContext(ctx, &events, `
+		SELECT * FROM clingy_coowner_history
+		WHERE pregnancy_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// CreateCoownerAction records a request made under the coowner role.
+func (d *DB) CreateCoownerAction(ctx context.Context, action *models.CoownerAction) error {
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_coowner_audit (pregnancy_id, coowner_id, method, path, entity, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, action.PregnancyID, action.CoownerID, action.Method, action.Path, action.Entity, action.Status)
+	return err
+}
+
+// GetCoownerActions gets a page of a pregnancy's audited coowner requests
+// matching filter, newest first.
+func (d *DB) GetCoownerActions(ctx context.Context, pregnancyID int64, filter models.AuditFilter, page pagination.Params) ([]models.CoownerAction, error) {
+	where, args := auditFilterClause(filter, []interface{}{pregnancyID})
+	clause, args := page.Clause("created_at", "id", args)
+	var actions []models.CoownerAction
+	err := d.db.SelectContext(ctx, &actions, `SELECT * FROM clingy_coowner_audit WHERE pregnancy_id = $1`+where+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// CountCoownerActions counts a pregnancy's audited coowner requests matching filter.
+func (d *DB) CountCoownerActions(ctx context.Context, pregnancyID int64, filter models.AuditFilter) (int, error) {
+	where, args := auditFilterClause(filter, []interface{}{pregnancyID})
+	var count int
+	err := d.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM clingy_coowner_audit WHERE pregnancy_id = $1`+where, args...)
+	return count, err
+}
+
+// auditFilterClause returns the conditions for filter, joined with AND.
+// Placeholders continue after args, which is returned extended with their values.
+func auditFilterClause(filter models.AuditFilter, args []interface{}) (string, []interface{}) {
+	var clause string
+	if filter.Entity != "" {
+		args = append(args, filter.Entity)
+		clause += fmt.Sprintf(" AND entity = $%d", len(args))
+	}
+	if filter.Actor != "" {
+		args = append(args, filter.Actor)
+		clause += fmt.Sprintf(" AND coowner_id = $%d", len(args))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		clause += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		clause += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+	return clause, args
+}
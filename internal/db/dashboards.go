@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Dashboard Operations ============
+
+// GetDashboards gets all dashboards for a pregnancy in display order.
+func (d *DB) GetDashboards(ctx context.Context, pregnancyID int64) ([]models.Dashboard, error) {
+	var dashboards []models.Dashboard
+	err := d.db.SelectContext(ctx, &dashboards, `
+		SELECT * FROM clingy_dashboards
+		WHERE pregnancy_id = $1
+		ORDER BY position, id
+	`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	return dashboards, nil
+}
+
+// GetDashboard gets a dashboard of the pregnancy.
+func (d *DB) GetDashboard(ctx context.Context, pregnancyID, dashboardID int64) (*models.Dashboard, error) {
+	var dash models.Dashboard
+	err := d.db.GetContext(ctx, &dash, `
+		SELECT * FROM clingy_dashboards WHERE id = $1 AND pregnancy_id = $2
+	`, dashboardID, pregnancyID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dash, nil
+}
+
+// CreateDashboard creates a dashboard. Without a position it goes last.
+func (d *DB) CreateDashboard(ctx context.Context, pregnancyID int64, userID, name string, position *int, widgets json.RawMessage) (*models.Dashboard, error) {
+	var dash models.Dashboard
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_dashboards (pregnancy_id, created_by, name, position, widgets)
+		VALUES ($1, $2, $3,
+			COALESCE($4, (SELECT COALESCE(MAX(position) + 1, 0) FROM clingy_dashboards WHERE pregnancy_id = $1)),
+			$5)
+		RETURNING *
+	`, pregnancyID, userID, name, position, widgets).StructScan(&dash)
+	if err != nil {
+		return nil, err
+	}
+	return &dash, nil
+}
+
+// UpdateDashboard replaces a dashboard's name, widgets and, if given, position.
+func (d *DB) UpdateDashboard(ctx context.Context, pregnancyID, dashboardID int64, name string, position *int, widgets json.RawMessage) (*models.Dashboard, error) {
+	var dash models.Dashboard
+	err := d.db.QueryRowxContext(ctx, `
+		UPDATE clingy_dashboards SET
+			name = $3,
+			position = COALESCE($4, position),
+			widgets = $5,
+			updated_at = NOW()
+		WHERE id = $1 AND pregnancy_id = $2
+		RETURNING *
+	`, dashboardID, pregnancyID, name, position, widgets).StructScan(&dash)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dash, nil
+}
+
+// DeleteDashboard deletes a dashboard.
+func (d *DB) DeleteDashboard(ctx context.Context, pregnancyID, dashboardID int64) error {
+	result, err := d.db.ExecContext(ctx, `
+		DELETE FROM clingy_dashboards WHERE id = $1 AND pregnancy_id = $2
+	`, dashboardID, pregnancyID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"log"
+
+	"github.com/scalecode-solutions/tracker2api/internal/entrydata"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Entry Data Version Operations ============
+
+// upgradeEntries brings payloads written in older shapes up to the current version.
+// Entries that fail to upgrade are returned unchanged at their stored version.
+func upgradeEntries(entries []models.Entry) {
+	for i := range entries {
+		e := &entries[i]
+		data, version, err := entrydata.Upgrade(e.EntryType, e.DataVersion, e.Data)
+		if err != nil {
+			log.Printf("Entry %d: %v", e.ID, err)
+			continue
+		}
+		e.Data, e.DataVersion = data, version
+	}
+}
+
+// RecordUnknownDataVersion counts a write of an entry data version the server does not know.
+func (d *DB) RecordUnknownDataVersion(ctx context.Context, entryType string, dataVersion int, appVersion string) error {
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_unknown_data_versions (entry_type, data_version, app_version)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (entry_type, data_version, app_version) DO UPDATE SET
+			hits = clingy_unknown_data_versions.hits + 1,
+			last_seen_at = NOW()
+	`, entryType, dataVersion, appVersion)
+	return err
+}
+
+// GetUnknownDataVersions lists unknown entry data versions, most recently seen first.
+func (d *DB) GetUnknownDataVersions(ctx context.Context) ([]models.UnknownDataVersion, error) {
+	var versions []models.UnknownDataVersion
+	err := d.db.SelectContext(ctx, &versions, `
+		SELECT * FROM clingy_unknown_data_versions ORDER BY last_seen_at DESC
+	`)
+	return versions, err
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/pagination"
 )
 
 //go:embed migrations/*.sql
@@ -30,14 +31,24 @@ var (
 
 // DB wraps database operations.
 type DB struct {
-	db *sqlx.DB
+	db *conn
 }
 
-// New creates a new database connection.
-func New(databaseURL string) (*DB, error) {
+// New creates a new database connection. With a standby URL the connection
+// fails over to the standby, and back, when the active server goes down.
+func New(databaseURL, standbyURL string) (*DB, error) {
+	c, err := connect(databaseURL, standbyURL)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{db: c}, nil
+}
+
+// openPool connects to a server with the connection pool configured.
+func openPool(databaseURL string) (*sqlx.DB, error) {
 	db, err := sqlx.Connect("pgx", databaseURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
 	}
 
 	// Configure connection pool
@@ -45,12 +56,13 @@ func New(databaseURL string) (*DB, error) {
 	db.SetMaxIdleConns(5)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	return &DB{db: db}, nil
+	return db, nil
 }
 
 // Close closes the database connection.
 func (d *DB) Close() error {
-	return d.db.Close()
+	d.db.failover.close()
+	return d.db.current().Close()
 }
 
 // ============ Migration Operations ============
@@ -331,12 +343,16 @@ func (d *DB) SetPregnancyArchive(ctx context.Context, id int64, archived bool) (
 
 // CreatePregnancy creates a new pregnancy record.
 func (d *DB) CreatePregnancy(ctx context.Context, ownerID string, req *models.PregnancyRequest) (*models.Pregnancy, error) {
+	return createPregnancy(ctx, d.db, ownerID, req)
+}
+
+func createPregnancy(ctx context.Context, q sqlx.QueryerContext, ownerID string, req *models.PregnancyRequest) (*models.Pregnancy, error) {
 	var p models.Pregnancy
-	err := d.db.QueryRowxContext(ctx, `
-		INSERT INTO clingy_pregnancies (owner_id, due_date, start_date, calculation_method, cycle_length, baby_name, mom_name, mom_birthday, gender, parent_role)
-		VALUES ($1, $2, $3, $4, COALESCE($5, 28), $6, $7, $8, $9, $10)
+	err := q.QueryRowxContext(ctx, `
+		INSERT INTO clingy_pregnancies (owner_id, due_date, start_date, calculation_method, cycle_length, baby_name, mom_name, mom_birthday, gender, parent_role, region)
+		VALUES ($1, $2, $3, $4, COALESCE($5, 28), $6, $7, $8, $9, $10, COALESCE($11, ''))
 		RETURNING *
-	`, ownerID, req.DueDate, req.StartDate, req.CalculationMethod, req.CycleLength, req.BabyName, req.MomName, req.MomBirthday, req.Gender, req.ParentRole).StructScan(&p)
+	`, ownerID, req.DueDate, req.StartDate, req.CalculationMethod, req.CycleLength, req.BabyName, req.MomName, req.MomBirthday, req.Gender, req.ParentRole, req.Region).StructScan(&p)
 	if err != nil {
 		return nil, err
 	}
@@ -345,8 +361,12 @@ func (d *DB) CreatePregnancy(ctx context.Context, ownerID string, req *models.Pr
 
 // UpdatePregnancy updates an existing pregnancy record.
 func (d *DB) UpdatePregnancy(ctx context.Context, id int64, req *models.PregnancyRequest) (*models.Pregnancy, error) {
+	return updatePregnancy(ctx, d.db, id, req)
+}
+
+func updatePregnancy(ctx context.Context, q sqlx.QueryerContext, id int64, req *models.PregnancyRequest) (*models.Pregnancy, error) {
 	var p models.Pregnancy
-	err := d.db.QueryRowxContext(ctx, `
+	err := q.QueryRowxContext(ctx, `
 		UPDATE clingy_pregnancies SET
 			due_date = COALESCE($2, due_date),
 			start_date = COALESCE($3, start_date),
@@ -369,8 +389,18 @@ func (d *DB) UpdatePregnancy(ctx context.Context, id int64, req *models.Pregnanc
 
 // Entry operations
 
-// GetEntries gets entries for a pregnancy.
-func (d *DB) GetEntries(ctx context.Context, pregnancyID int64, entryType string, since *time.Time, includeDeleted bool) ([]models.Entry, error) {
+// GetEntries gets entries for a pregnancy. since filters on the last change,
+// occurredSince on when the entry happened (created_at if the client sent no time).
+func (d *DB) GetEntries(ctx context.Context, pregnancyID int64, entryType string, since, occurredSince *time.Time, includeDeleted bool) ([]models.Entry, error) {
+	return d.GetFilteredEntries(ctx, pregnancyID, entryType, since, occurredSince, nil, nil, includeDeleted, nil, nil)
+}
+
+// GetFilteredEntries is GetEntries that also filters on payload fields and on
+// when entries happened: from (inclusive) and to (exclusive) compare entry_at,
+// which also reads the payload date. Filters need an entryType and must pass
+// ValidateEntryFilter. With a page, one page of the entries is returned
+// instead of all of them.
+func (d *DB) GetFilteredEntries(ctx context.Context, pregnancyID int64, entryType string, since, occurredSince, from, to *time.Time, includeDeleted bool, filters []models.EntryFilter, page *pagination.Params) ([]models.Entry, error) {
 	query := `SELECT * FROM clingy_entries WHERE pregnancy_id = $1`
 	args := []interface{}{pregnancyID}
 	argNum := 2
@@ -387,44 +417,308 @@ func (d *DB) GetEntries(ctx context.Context, pregnancyID int64, entryType string
 		argNum++
 	}
 
+	if occurredSince != nil {
+		query += fmt.Sprintf(" AND COALESCE(occurred_at, created_at) >= $%d", argNum)
+		args = append(args, occurredSince)
+		argNum++
+	}
+
+	if from != nil {
+		query += fmt.Sprintf(" AND entry_at >= $%d", argNum)
+		args = append(args, from)
+		argNum++
+	}
+
+	if to != nil {
+		query += fmt.Sprintf(" AND entry_at < $%d", argNum)
+		args = append(args, to)
+		argNum++
+	}
+
 	if !includeDeleted {
 		query += " AND deleted_at IS NULL"
 	}
 
-	query += " ORDER BY created_at DESC"
+	for _, f := range filters {
+		if msg := ValidateEntryFilter(entryType, f); msg != "" {
+			return nil, fmt.Errorf("invalid entry filter: %s", msg)
+		}
+		var clause string
+		clause, args = entryFilterClause(entryType, f, true, args)
+		query += clause
+	}
+
+	if page != nil {
+		var clause string
+		clause, args = page.Clause("created_at", "id", args)
+		query += clause
+	} else {
+		query += " ORDER BY created_at DESC"
+	}
 
 	var entries []models.Entry
 	err := d.db.SelectContext(ctx, &entries, query, args...)
 	if err != nil {
 		return nil, err
 	}
+	upgradeEntries(entries)
 	return entries, nil
 }
 
-// UpsertEntry creates or updates an entry.
-func (d *DB) UpsertEntry(ctx context.Context, pregnancyID int64, req *models.EntryRequest) (*models.Entry, error) {
-	var e models.Entry
-	err := d.db.QueryRowxContext(ctx, `
-		INSERT INTO clingy_entries (pregnancy_id, client_id, entry_type, data)
-		VALUES ($1, $2, $3, $4)
+// UpsertEntry creates or updates an entry under the merge policy of its type.
+// Scheduled entries default to the 'planned' status. When the policy keeps the
+// stored entry, it is returned with ErrConflict. A stored entry whose
+// visibility isn't one of visibility (nil for any) is not found.
+func (d *DB) UpsertEntry(ctx context.Context, pregnancyID int64, req *models.EntryRequest, visibility []string) (*models.Entry, error) {
+	e, _, err := d.UpsertEntryResult(ctx, pregnancyID, req, visibility)
+	return e, err
+}
+
+// UpsertEntryResult is UpsertEntry that also reports whether the entry was created.
+func (d *DB) UpsertEntryResult(ctx context.Context, pregnancyID int64, req *models.EntryRequest, visibility []string) (*models.Entry, bool, error) {
+	return d.writeEntryTx(ctx, pregnancyID, req, SyncBase{}, visibility)
+}
+
+// UpsertEntryUnlessChanged upserts an entry unless the stored one changed after
+// base. Then, unless the merge policy of its type merges or overwrites it,
+// nothing is written and the stored entry is returned with ErrConflict.
+func (d *DB) UpsertEntryUnlessChanged(ctx context.Context, pregnancyID int64, req *models.EntryRequest, base SyncBase, visibility []string) (*models.Entry, error) {
+	e, _, err := d.writeEntryTx(ctx, pregnancyID, req, base, visibility)
+	return e, err
+}
+
+// writeEntryTx runs writeEntry in its own transaction.
+func (d *DB) writeEntryTx(ctx context.Context, pregnancyID int64, req *models.EntryRequest, base SyncBase, visibility []string) (*models.Entry, bool, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	e, created, err := writeEntry(ctx, tx, pregnancyID, req, base, visibility)
+	if err != nil {
+		return e, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return e, created, nil
+}
+
+// BatchUpsertEntries upserts all entries in one transaction; on error nothing is
+// saved and the index of the failing entry is returned. Stored entries hidden
+// from visibility (nil for any) are not found.
+func (d *DB) BatchUpsertEntries(ctx context.Context, pregnancyID int64, reqs []models.EntryRequest, visibility []string) ([]models.Entry, []bool, int, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, nil, -1, err
+	}
+	defer tx.Rollback()
+
+	entries := make([]models.Entry, 0, len(reqs))
+	created := make([]bool, 0, len(reqs))
+	for i := range reqs {
+		e, isNew, err := writeEntry(ctx, tx, pregnancyID, &reqs[i], SyncBase{}, visibility)
+		if err != nil {
+			return nil, nil, i, err
+		}
+		entries = append(entries, *e)
+		created = append(created, isNew)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, -1, err
+	}
+	return entries, created, -1, nil
+}
+
+// BatchUpsertEachEntry upserts entries in one transaction, each under its own
+// savepoint: an entry that fails is rolled back on its own and errs holds its
+// error, while the others are committed together. An error returned beside the
+// results means nothing was saved. Stored entries hidden from visibility (nil
+// for any) are not found.
+func (d *DB) BatchUpsertEachEntry(ctx context.Context, pregnancyID int64, reqs []*models.EntryRequest, visibility []string) ([]*models.Entry, []bool, []error, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer tx.Rollback()
+
+	entries := make([]*models.Entry, len(reqs))
+	created := make([]bool, len(reqs))
+	errs := make([]error, len(reqs))
+	for i, req := range reqs {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_entry"); err != nil {
+			return nil, nil, nil, err
+		}
+		entries[i], created[i], errs[i] = writeEntry(ctx, tx, pregnancyID, req, SyncBase{}, visibility)
+		if errs[i] != nil {
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_entry"); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_entry"); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, nil, err
+	}
+	return entries, created, errs, nil
+}
+
+func upsertEntry(ctx context.Context, q sqlx.QueryerContext, pregnancyID int64, req *models.EntryRequest) (*models.Entry, bool, error) {
+	status := entryStatus(req)
+
+	// xmax is 0 only for freshly inserted rows
+	var row struct {
+		models.Entry
+		Inserted bool `db:"inserted"`
+	}
+	err := q.QueryRowxContext(ctx, `
+		INSERT INTO clingy_entries (pregnancy_id, client_id, entry_type, data, scheduled_for, status, data_version, occurred_at, visibility)
+		VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, 1), $8, COALESCE($9::varchar, 'shared'))
 		ON CONFLICT (pregnancy_id, entry_type, client_id) DO UPDATE SET
 			data = EXCLUDED.data,
+			scheduled_for = CASE WHEN $10::boolean THEN NULL ELSE COALESCE(EXCLUDED.scheduled_for, clingy_entries.scheduled_for) END,
+			status = CASE WHEN $10::boolean THEN NULL ELSE COALESCE(EXCLUDED.status, clingy_entries.status) END,
+			data_version = EXCLUDED.data_version,
+			occurred_at = COALESCE(EXCLUDED.occurred_at, clingy_entries.occurred_at),
+			visibility = COALESCE($9, clingy_entries.visibility),
+			visibility_changed_at = CASE WHEN $9 <> clingy_entries.visibility THEN NOW() ELSE clingy_entries.visibility_changed_at END,
 			updated_at = NOW(),
 			deleted_at = NULL
+		RETURNING *, (xmax = 0) AS inserted
+	`, pregnancyID, req.ClientID, req.EntryType, req.Data, req.ScheduledFor, status, req.DataVersion, req.OccurredAt, req.Visibility, req.Unschedule).StructScan(&row)
+	if err != nil {
+		return nil, false, err
+	}
+	return &row.Entry, row.Inserted, nil
+}
+
+// InsertEntryIfAbsent creates an entry unless one with the same type and clientId
+// already exists (including soft-deleted ones). Returns false for an existing entry.
+func (d *DB) InsertEntryIfAbsent(ctx context.Context, pregnancyID int64, req *models.EntryRequest) (bool, error) {
+	result, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_entries (pregnancy_id, client_id, entry_type, data)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (pregnancy_id, entry_type, client_id) DO NOTHING
+	`, pregnancyID, req.ClientID, req.EntryType, req.Data)
+	if err != nil {
+		return false, err
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// GetScheduledEntries gets non-deleted scheduled entries for a pregnancy.
+// Filter "upcoming" returns planned entries in the future; "due" returns planned
+// entries whose date has passed and need a completed/missed decision.
+func (d *DB) GetScheduledEntries(ctx context.Context, pregnancyID int64, filter string) ([]models.Entry, error) {
+	query := `SELECT * FROM clingy_entries
+		WHERE pregnancy_id = $1 AND scheduled_for IS NOT NULL AND deleted_at IS NULL`
+	switch filter {
+	case "upcoming":
+		query += " AND status = 'planned' AND scheduled_for >= NOW()"
+	case "due":
+		query += " AND status = 'planned' AND scheduled_for < NOW()"
+	}
+	query += " ORDER BY scheduled_for ASC"
+
+	var entries []models.Entry
+	err := d.db.SelectContext(ctx, &entries, query, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	upgradeEntries(entries)
+	return entries, nil
+}
+
+// SetEntryStatus transitions a scheduled entry to a new status. Entries whose
+// visibility isn't one of visibility (nil for any) are not found.
+func (d *DB) SetEntryStatus(ctx context.Context, pregnancyID int64, entryType, clientID, status string, visibility []string) (*models.Entry, error) {
+	var e models.Entry
+	err := d.db.QueryRowxContext(ctx, `
+		UPDATE clingy_entries SET status = $4, updated_at = NOW()
+		WHERE pregnancy_id = $1 AND entry_type = $2 AND client_id = $3
+		  AND scheduled_for IS NOT NULL AND deleted_at IS NULL
+		  AND ($5::varchar[] IS NULL OR visibility = ANY($5))
 		RETURNING *
-	`, pregnancyID, req.ClientID, req.EntryType, req.Data).StructScan(&e)
+	`, pregnancyID, entryType, clientID, status, visibility).StructScan(&e)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &e, nil
 }
 
-// DeleteEntry soft deletes an entry.
-func (d *DB) DeleteEntry(ctx context.Context, pregnancyID int64, clientID string) error {
-	result, err := d.db.ExecContext(ctx, `
+// GetEntriesByClientID returns the pregnancy's entries with a clientId,
+// including deleted ones, limited to entryType unless it is "".
+func (d *DB) GetEntriesByClientID(ctx context.Context, pregnancyID int64, clientID, entryType string) ([]models.Entry, error) {
+	var entries []models.Entry
+	err := d.db.SelectContext(ctx, &entries, `
+		SELECT * FROM clingy_entries
+		WHERE pregnancy_id = $1 AND client_id = $2 AND ($3 = '' OR entry_type = $3)
+		ORDER BY entry_type
+	`, pregnancyID, clientID, entryType)
+	if err != nil {
+		return nil, err
+	}
+	upgradeEntries(entries)
+	return entries, nil
+}
+
+// DeleteEntry soft deletes an entry. Entries whose visibility isn't one of
+// visibility (nil for any) are not found.
+func (d *DB) DeleteEntry(ctx context.Context, pregnancyID int64, clientID string, visibility []string) error {
+	return deleteEntry(ctx, d.db, pregnancyID, clientID, visibility)
+}
+
+// DeleteEntryUnlessChanged soft deletes an entry unless an entry with its
+// clientId changed after base. Then nothing is deleted and the changed entries
+// are returned with ErrConflict. Entries hidden from visibility (nil for any)
+// are neither deleted nor reported.
+func (d *DB) DeleteEntryUnlessChanged(ctx context.Context, pregnancyID int64, clientID string, base SyncBase, visibility []string) ([]models.Entry, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	changed, err := deleteEntryUnlessChanged(ctx, tx, pregnancyID, clientID, base, visibility)
+	if err != nil {
+		return changed, err
+	}
+	return nil, tx.Commit()
+}
+
+func deleteEntryUnlessChanged(ctx context.Context, tx *sqlx.Tx, pregnancyID int64, clientID string, base SyncBase, visibility []string) ([]models.Entry, error) {
+	var changed []models.Entry
+	err := tx.SelectContext(ctx, &changed, `
+		SELECT * FROM clingy_entries
+		WHERE pregnancy_id = $1 AND client_id = $2
+		  AND CASE WHEN $4::bigint > 0 THEN sync_version > $4 ELSE updated_at > $3 END
+		  AND ($5::varchar[] IS NULL OR visibility = ANY($5))
+		FOR UPDATE
+	`, pregnancyID, clientID, base.At, base.Version, visibility)
+	if err != nil {
+		return nil, err
+	}
+	if len(changed) > 0 {
+		return changed, ErrConflict
+	}
+	return nil, deleteEntry(ctx, tx, pregnancyID, clientID, visibility)
+}
+
+func deleteEntry(ctx context.Context, q sqlx.ExecerContext, pregnancyID int64, clientID string, visibility []string) error {
+	result, err := q.ExecContext(ctx, `
 		UPDATE clingy_entries SET deleted_at = NOW(), updated_at = NOW()
 		WHERE pregnancy_id = $1 AND client_id = $2 AND deleted_at IS NULL
-	`, pregnancyID, clientID)
+		  AND ($3::varchar[] IS NULL OR visibility = ANY($3))
+	`, pregnancyID, clientID, visibility)
 	if err != nil {
 		return err
 	}
@@ -435,13 +729,100 @@ func (d *DB) DeleteEntry(ctx context.Context, pregnancyID int64, clientID string
 	return nil
 }
 
+// DeleteEntries soft deletes the live entries named in clientIDs, or every live
+// entry of entryType. Entries whose visibility isn't one of visibility (nil for
+// any) are left alone. Returns the number deleted.
+func (d *DB) DeleteEntries(ctx context.Context, pregnancyID int64, clientIDs []string, entryType string, visibility []string) (int64, error) {
+	return deleteEntries(ctx, d.db, pregnancyID, clientIDs, entryType, visibility)
+}
+
+func deleteEntries(ctx context.Context, q sqlx.ExecerContext, pregnancyID int64, clientIDs []string, entryType string, visibility []string) (int64, error) {
+	query := `
+		UPDATE clingy_entries SET deleted_at = NOW(), updated_at = NOW()
+		WHERE pregnancy_id = $1 AND deleted_at IS NULL
+		  AND ($2::varchar[] IS NULL OR visibility = ANY($2))`
+	args := []interface{}{pregnancyID, visibility}
+	if entryType != "" {
+		query += " AND entry_type = $3"
+		args = append(args, entryType)
+	} else {
+		query += " AND client_id = ANY($3)"
+		args = append(args, clientIDs)
+	}
+
+	result, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MergeDuplicateEntries keeps one entry and soft deletes the given duplicates of the same type.
+// Returns the number of duplicates removed. Entries whose visibility isn't one of
+// visibility (nil for any) are neither kept (ErrNotFound) nor removed.
+func (d *DB) MergeDuplicateEntries(ctx context.Context, pregnancyID int64, entryType, keepClientID string, mergeClientIDs []string, visibility []string) (int64, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Make sure the entry being kept still exists
+	var exists bool
+	err = tx.GetContext(ctx, &exists, `
+		SELECT EXISTS (
+			SELECT 1 FROM clingy_entries
+			WHERE pregnancy_id = $1 AND entry_type = $2 AND client_id = $3 AND deleted_at IS NULL
+			  AND ($4::varchar[] IS NULL OR visibility = ANY($4))
+		)
+	`, pregnancyID, entryType, keepClientID, visibility)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, ErrNotFound
+	}
+
+	var removed int64
+	for _, clientID := range mergeClientIDs {
+		if clientID == keepClientID {
+			continue
+		}
+		result, err := tx.ExecContext(ctx, `
+			UPDATE clingy_entries SET deleted_at = NOW(), updated_at = NOW()
+			WHERE pregnancy_id = $1 AND entry_type = $2 AND client_id = $3 AND deleted_at IS NULL
+			  AND ($4::varchar[] IS NULL OR visibility = ANY($4))
+		`, pregnancyID, entryType, clientID, visibility)
+		if err != nil {
+			return 0, err
+		}
+		rows, _ := result.RowsAffected()
+		removed += rows
+	}
+
+	// Touch the kept entry so other devices pick up the merge on next sync
+	_, err = tx.ExecContext(ctx, `
+		UPDATE clingy_entries SET updated_at = NOW()
+		WHERE pregnancy_id = $1 AND entry_type = $2 AND client_id = $3
+		  AND ($4::varchar[] IS NULL OR visibility = ANY($4))
+	`, pregnancyID, entryType, keepClientID, visibility)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
 // Settings operations
 
-// GetSettings gets all settings for a pregnancy.
+// GetSettings gets all settings for a pregnancy, leaving out deleted ones.
 func (d *DB) GetSettings(ctx context.Context, pregnancyID int64) (map[string]json.RawMessage, error) {
 	var settings []models.Setting
 	err := d.db.SelectContext(ctx, &settings, `
-		SELECT * FROM clingy_settings WHERE pregnancy_id = $1
+		SELECT * FROM clingy_settings WHERE pregnancy_id = $1 AND deleted_at IS NULL
 	`, pregnancyID)
 	if err != nil {
 		return nil, err
@@ -454,28 +835,120 @@ func (d *DB) GetSettings(ctx context.Context, pregnancyID int64) (map[string]jso
 	return result, nil
 }
 
-// UpsertSetting creates or updates a setting.
+// UpsertSetting creates or updates a setting, restoring it if it was deleted.
 func (d *DB) UpsertSetting(ctx context.Context, pregnancyID int64, settingType string, data json.RawMessage) error {
-	_, err := d.db.ExecContext(ctx, `
+	return upsertSetting(ctx, d.db, pregnancyID, settingType, data)
+}
+
+func upsertSetting(ctx context.Context, q sqlx.ExecerContext, pregnancyID int64, settingType string, data json.RawMessage) error {
+	_, err := q.ExecContext(ctx, `
 		INSERT INTO clingy_settings (pregnancy_id, setting_type, data)
 		VALUES ($1, $2, $3)
 		ON CONFLICT (pregnancy_id, setting_type) DO UPDATE SET
 			data = EXCLUDED.data,
+			version = clingy_settings.version + 1,
+			deleted_at = NULL,
 			updated_at = NOW()
 	`, pregnancyID, settingType, data)
 	return err
 }
 
+// GetSettingVersions gets the current version of each setting for a pregnancy,
+// leaving out deleted ones.
+func (d *DB) GetSettingVersions(ctx context.Context, pregnancyID int64) (map[string]int64, error) {
+	var settings []models.Setting
+	err := d.db.SelectContext(ctx, &settings, `
+		SELECT * FROM clingy_settings WHERE pregnancy_id = $1 AND deleted_at IS NULL
+	`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int64)
+	for _, s := range settings {
+		result[s.SettingType] = s.Version
+	}
+	return result, nil
+}
+
+// PatchSetting applies a JSON merge patch to a setting if it is still at baseVersion.
+// Returns ErrConflict along with the current setting when the version has moved on.
+// A missing or deleted setting is treated as version 0 with an empty object.
+func (d *DB) PatchSetting(ctx context.Context, pregnancyID int64, settingType string, baseVersion int64, patch json.RawMessage) (*models.Setting, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	updated, err := patchSetting(ctx, tx, pregnancyID, settingType, baseVersion, patch)
+	if err != nil {
+		return updated, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func patchSetting(ctx context.Context, tx *sqlx.Tx, pregnancyID int64, settingType string, baseVersion int64, patch json.RawMessage) (*models.Setting, error) {
+	var current models.Setting
+	err := tx.GetContext(ctx, &current, `
+		SELECT * FROM clingy_settings WHERE pregnancy_id = $1 AND setting_type = $2
+		FOR UPDATE
+	`, pregnancyID, settingType)
+	if err == sql.ErrNoRows || (err == nil && current.DeletedAt.Valid) {
+		current = models.Setting{PregnancyID: pregnancyID, SettingType: settingType, Data: json.RawMessage(`{}`)}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if current.Version != baseVersion {
+		return &current, ErrConflict
+	}
+
+	merged, err := MergePatch(current.Data, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated models.Setting
+	err = tx.QueryRowxContext(ctx, `
+		INSERT INTO clingy_settings (pregnancy_id, setting_type, data)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (pregnancy_id, setting_type) DO UPDATE SET
+			data = EXCLUDED.data,
+			version = clingy_settings.version + 1,
+			deleted_at = NULL,
+			updated_at = NOW()
+		RETURNING *
+	`, pregnancyID, settingType, merged).StructScan(&updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
 // Pairing operations
 
+// FindUserIDByEmail resolves an mvchat2 user by email. The ID is invalid when
+// nobody has that address.
+func (d *DB) FindUserIDByEmail(ctx context.Context, email string) (sql.NullString, error) {
+	var userID sql.NullString
+	err := d.db.GetContext(ctx, &userID, `
+		SELECT id FROM users WHERE LOWER(tags->>'email') = LOWER($1)
+	`, email)
+	if err != nil && err != sql.ErrNoRows {
+		return sql.NullString{}, err
+	}
+	return userID, nil
+}
+
 // CreatePairingRequest creates a new pairing request.
 func (d *DB) CreatePairingRequest(ctx context.Context, requesterID string, requesterName, targetEmail string) (*models.PairingRequest, error) {
 	// First try to find the target user by email
-	var targetID sql.NullString
-	err := d.db.GetContext(ctx, &targetID, `
-		SELECT id FROM users WHERE LOWER(tags->>'email') = LOWER($1)
-	`, targetEmail)
-	if err != nil && err != sql.ErrNoRows {
+	targetID, err := d.FindUserIDByEmail(ctx, targetEmail)
+	if err != nil {
 		return nil, err
 	}
 
@@ -491,6 +964,24 @@ func (d *DB) CreatePairingRequest(ctx context.Context, requesterID string, reque
 	return &pr, nil
 }
 
+// GetPairingRequestHistory counts the requester's pairing requests in the last
+// 24 hours, and those to targetEmail that are still pending or that recent.
+func (d *DB) GetPairingRequestHistory(ctx context.Context, requesterID, targetEmail string) (sentToday, sameTarget int, err error) {
+	var counts struct {
+		SentToday  int `db:"sent_today"`
+		SameTarget int `db:"same_target"`
+	}
+	err = d.db.GetContext(ctx, &counts, `
+		SELECT
+			COUNT(*) FILTER (WHERE created_at > NOW() - INTERVAL '24 hours') AS sent_today,
+			COUNT(*) FILTER (WHERE LOWER(target_email) = LOWER($2)
+				AND (status = 'pending' OR created_at > NOW() - INTERVAL '24 hours')) AS same_target
+		FROM clingy_pairing_requests
+		WHERE requester_id = $1
+	`, requesterID, targetEmail)
+	return counts.SentToday, counts.SameTarget, err
+}
+
 // GetPendingPairingRequests gets pending requests for a user.
 func (d *DB) GetPendingPairingRequests(ctx context.Context, targetID string) ([]models.PairingRequest, error) {
 	var requests []models.PairingRequest
@@ -582,6 +1073,37 @@ func (d *DB) UpdatePartnerPermission(ctx context.Context, ownerID string, permis
 	return nil
 }
 
+// SetSharingSnooze pauses partner/supporter visibility until the given time.
+// A nil until lifts the snooze.
+func (d *DB) SetSharingSnooze(ctx context.Context, pregnancyID int64, until *time.Time) error {
+	var result sql.Result
+	var err error
+	if until == nil {
+		result, err = d.db.ExecContext(ctx, `
+			UPDATE clingy_pregnancies SET
+				sharing_snoozed_at = NULL,
+				sharing_snoozed_until = NULL
+			WHERE id = $1
+		`, pregnancyID)
+	} else {
+		// Keep the original start while extending, so paused data is resent in full
+		result, err = d.db.ExecContext(ctx, `
+			UPDATE clingy_pregnancies SET
+				sharing_snoozed_at = CASE WHEN sharing_snoozed_until > NOW() THEN sharing_snoozed_at ELSE NOW() END,
+				sharing_snoozed_until = $2
+			WHERE id = $1
+		`, pregnancyID, *until)
+	}
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // RemovePairing removes a pairing.
 func (d *DB) RemovePairing(ctx context.Context, userID string) error {
 	// Try as owner first
@@ -622,14 +1144,24 @@ func (d *DB) RemovePairing(ctx context.Context, userID string) error {
 
 // File operations
 
+// GetStorageUsage returns the total size of a pregnancy's files, in bytes.
+func (d *DB) GetStorageUsage(ctx context.Context, pregnancyID int64) (int64, error) {
+	var used int64
+	err := d.db.GetContext(ctx, &used, `
+		SELECT COALESCE(SUM(size_bytes), 0) FROM clingy_files
+		WHERE pregnancy_id = $1 AND deleted_at IS NULL
+	`, pregnancyID)
+	return used, err
+}
+
 // CreateFile creates a file record.
 func (d *DB) CreateFile(ctx context.Context, pregnancyID int64, file *models.File) (*models.File, error) {
 	var f models.File
 	err := d.db.QueryRowxContext(ctx, `
-		INSERT INTO clingy_files (pregnancy_id, client_id, file_type, storage_path, mime_type, size_bytes, metadata)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO clingy_files (pregnancy_id, client_id, file_type, storage_path, mime_type, size_bytes, metadata, moderation_status, region, content_hash, alt_text)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING *
-	`, pregnancyID, file.ClientID, file.FileType, file.StoragePath, file.MimeType, file.SizeBytes, file.Metadata).StructScan(&f)
+	`, pregnancyID, file.ClientID, file.FileType, file.StoragePath, file.MimeType, file.SizeBytes, file.Metadata, file.ModerationStatus, file.Region, file.ContentHash, file.AltText).StructScan(&f)
 	if err != nil {
 		return nil, err
 	}
@@ -651,9 +1183,13 @@ func (d *DB) GetFile(ctx context.Context, fileID int64) (*models.File, error) {
 	return &f, nil
 }
 
-// DeleteFile soft deletes a file.
+// DeleteFile soft deletes a file and unsets it as a profile photo.
 func (d *DB) DeleteFile(ctx context.Context, fileID int64) error {
-	result, err := d.db.ExecContext(ctx, `
+	return deleteFile(ctx, d.db, fileID)
+}
+
+func deleteFile(ctx context.Context, q sqlx.ExecerContext, fileID int64) error {
+	result, err := q.ExecContext(ctx, `
 		UPDATE clingy_files SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL
 	`, fileID)
 	if err != nil {
@@ -663,6 +1199,25 @@ func (d *DB) DeleteFile(ctx context.Context, fileID int64) error {
 	if rows == 0 {
 		return ErrNotFound
 	}
+
+	_, err = q.ExecContext(ctx, `
+		UPDATE clingy_pregnancies SET profile_photo_file_id = NULL WHERE profile_photo_file_id = $1
+	`, fileID)
+	return err
+}
+
+// SetProfilePhotoFile makes an uploaded file the pregnancy's profile photo.
+func (d *DB) SetProfilePhotoFile(ctx context.Context, pregnancyID, fileID int64) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_pregnancies SET profile_photo_file_id = $2, updated_at = NOW() WHERE id = $1
+	`, pregnancyID, fileID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
 	return nil
 }
 
@@ -698,13 +1253,14 @@ func (d *DB) UpdateSyncState(ctx context.Context, userID string, deviceID string
 // ============ Invite Code Operations ============
 
 // CreateInviteCode creates a new invite code record.
-func (d *DB) CreateInviteCode(ctx context.Context, pregnancyID int64, codeHash, codePrefix, role, permission string, expiresAt time.Time) (*models.InviteCode, error) {
+// An empty message stores none.
+func (d *DB) CreateInviteCode(ctx context.Context, pregnancyID int64, codeHash, codePrefix, role, permission string, expiresAt time.Time, message string) (*models.InviteCode, error) {
 	var code models.InviteCode
 	err := d.db.QueryRowxContext(ctx, `
-		INSERT INTO clingy_invite_codes (pregnancy_id, code_hash, code_prefix, role, permission, expires_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO clingy_invite_codes (pregnancy_id, code_hash, code_prefix, role, permission, expires_at, message)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
 		RETURNING *
-	`, pregnancyID, codeHash, codePrefix, role, permission, expiresAt).StructScan(&code)
+	`, pregnancyID, codeHash, codePrefix, role, permission, expiresAt, message).StructScan(&code)
 	if err != nil {
 		return nil, err
 	}
@@ -789,13 +1345,19 @@ func (d *DB) RedeemInviteCode(ctx context.Context, codeID int64, userID string,
 	// Handle based on role
 	if isAdmin {
 		// Admin becomes coowner - gets owner-level access without occupying partner slot
+		if err := linkCoowner(ctx, tx, code.PregnancyID, userID, displayName); err != nil {
+			return nil, "", err
+		}
+	} else if code.Role == "provider" {
+		// Care provider - only gains access to care notes
 		_, err = tx.ExecContext(ctx, `
-			UPDATE clingy_pregnancies SET
-				coowner_id = $1,
-				coowner_name = $2,
-				updated_at = NOW()
-			WHERE id = $3
-		`, userID, displayName, code.PregnancyID)
+			INSERT INTO clingy_care_providers (pregnancy_id, user_id, display_name, invited_via_code_id)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (pregnancy_id, user_id) DO UPDATE SET
+				display_name = EXCLUDED.display_name,
+				removed_at = NULL,
+				joined_at = NOW()
+		`, code.PregnancyID, userID, displayName, codeID)
 		if err != nil {
 			return nil, "", err
 		}
@@ -928,7 +1490,11 @@ func (d *DB) GetSupporterByUserID(ctx context.Context, userID string) (*models.S
 
 // RemoveSupporter removes a supporter (soft delete).
 func (d *DB) RemoveSupporter(ctx context.Context, supporterID int64, ownerID string) error {
-	result, err := d.db.ExecContext(ctx, `
+	return removeSupporter(ctx, d.db, supporterID, ownerID)
+}
+
+func removeSupporter(ctx context.Context, q sqlx.ExecerContext, supporterID int64, ownerID string) error {
+	result, err := q.ExecContext(ctx, `
 		UPDATE clingy_supporters SET removed_at = NOW()
 		WHERE id = $1
 		  AND pregnancy_id IN (SELECT id FROM clingy_pregnancies WHERE owner_id = $2)
@@ -943,27 +1509,3 @@ func (d *DB) RemoveSupporter(ctx context.Context, supporterID int64, ownerID str
 	}
 	return nil
 }
-
-// ============ Rate Limiting Operations ============
-
-// CountRecentCodeAttempts counts failed code attempts in the last hour.
-func (d *DB) CountRecentCodeAttempts(ctx context.Context, userID string) (int, error) {
-	var count int
-	err := d.db.GetContext(ctx, &count, `
-		SELECT COUNT(*) FROM clingy_code_attempts
-		WHERE user_id = $1 AND attempted_at > NOW() - INTERVAL '1 hour' AND success = false
-	`, userID)
-	if err != nil {
-		return 0, err
-	}
-	return count, nil
-}
-
-// RecordCodeAttempt records a code redemption attempt.
-func (d *DB) RecordCodeAttempt(ctx context.Context, userID string, success bool, ipAddress string) error {
-	_, err := d.db.ExecContext(ctx, `
-		INSERT INTO clingy_code_attempts (user_id, success, ip_address)
-		VALUES ($1, $2, $3)
-	`, userID, success, ipAddress)
-	return err
-}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// testDB connects to TEST_DATABASE_URL and applies the migrations. Tests that
+// need Postgres are skipped without it.
+func testDB(t *testing.T) *DB {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	d, err := New(url, "")
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+	if _, err := d.RunMigrations(); err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	return d
+}
+
+// testPregnancy creates a pregnancy for a fresh owner, deleted when the test ends.
+func testPregnancy(t *testing.T, d *DB) *models.Pregnancy {
+	t.Helper()
+	id := make([]byte, 16)
+	rand.Read(id)
+	due := "2024-10-07"
+	p, err := d.CreatePregnancy(context.Background(), hex.EncodeToString(id), &models.PregnancyRequest{DueDate: &due})
+	if err != nil {
+		t.Fatalf("create pregnancy: %v", err)
+	}
+	t.Cleanup(func() {
+		d.db.ExecContext(context.Background(), `DELETE FROM clingy_pregnancies WHERE id = $1`, p.ID)
+	})
+	return p
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Data Deletion Operations ============
+
+// userDataTables hold rows about a user, keyed by user_id, that data deletion
+// removes along with the pregnancies they own. Audit records (coowner history,
+// impersonations) are kept.
+var userDataTables = []string{
+	"clingy_access_fingerprints",
+	"clingy_calendar_feeds",
+	"clingy_care_providers",
+	"clingy_code_attempts",
+	"clingy_content_exposures",
+	"clingy_deprecated_usage",
+	"clingy_entry_reminders",
+	"clingy_idempotency_keys",
+	"clingy_jobs",
+	"clingy_notifications",
+	"clingy_personal_tokens",
+	"clingy_security_events",
+	"clingy_supporters",
+	"clingy_sync_state",
+	"clingy_user_preferences",
+	"clingy_user_profiles",
+	"clingy_v1_migrations",
+}
+
+// DeletePregnancy deletes one of ownerID's pregnancies with everything stored
+// for it. It returns the pregnancy's files so their content can be removed
+// from storage.
+func (d *DB) DeletePregnancy(ctx context.Context, pregnancyID int64, ownerID string) ([]models.File, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	files, err := deletePregnancy(ctx, tx, pregnancyID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func deletePregnancy(ctx context.Context, tx *sqlx.Tx, pregnancyID int64, ownerID string) ([]models.File, error) {
+	var id int64
+	err := tx.GetContext(ctx, &id, `
+		SELECT id FROM clingy_pregnancies WHERE id = $1 AND owner_id = $2 FOR UPDATE
+	`, pregnancyID, ownerID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Files are deleted first to learn where their content is stored
+	var files []models.File
+	err = tx.SelectContext(ctx, &files, `
+		DELETE FROM clingy_files WHERE pregnancy_id = $1 RETURNING *
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything else hangs off the pregnancy and cascades
+	if _, err := tx.ExecContext(ctx, `DELETE FROM clingy_pregnancies WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// DeleteUserData deletes everything stored about a user: the pregnancies they
+// own (demo included) with their data, their memberships in other pregnancies
+// as supporter or care provider, and their per-user rows. It returns the files
+// of the deleted pregnancies so their content can be removed from storage.
+// ErrConflict means the user is still partner or coowner of someone else's
+// pregnancy and must leave it first.
+func (d *DB) DeleteUserData(ctx context.Context, userID string) ([]models.File, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	files, err := deleteUserData(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func deleteUserData(ctx context.Context, tx *sqlx.Tx, userID string) ([]models.File, error) {
+	var shared bool
+	err := tx.GetContext(ctx, &shared, `
+		SELECT EXISTS (
+			SELECT 1 FROM clingy_pregnancies
+			WHERE owner_id <> $1 AND (partner_id = $1 OR coowner_id = $1)
+		)
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		return nil, ErrConflict
+	}
+
+	var ids []int64
+	err = tx.SelectContext(ctx, &ids, `
+		SELECT id FROM clingy_pregnancies WHERE owner_id = $1 ORDER BY id FOR UPDATE
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	var files []models.File
+	for _, id := range ids {
+		deleted, err := deletePregnancy(ctx, tx, id, userID)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, deleted...)
+	}
+
+	for _, table := range userDataTables {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, userID); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM clingy_pairing_requests WHERE requester_id = $1`, userID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM clingy_user_blocks WHERE blocker_id = $1`, userID); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Demo Pregnancy Operations ============
+
+// CreateDemoPregnancy creates a demo pregnancy with its generated entries in one transaction.
+func (d *DB) CreateDemoPregnancy(ctx context.Context, ownerID string, dueDate time.Time, babyName, momName string, entries []models.EntryRequest) (*models.Pregnancy, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var p models.Pregnancy
+	err = tx.QueryRowxContext(ctx, `
+		INSERT INTO clingy_pregnancies (owner_id, due_date, calculation_method, baby_name, mom_name, demo)
+		VALUES ($1, $2, 'due_date', $3, $4, true)
+		RETURNING *
+	`, ownerID, dueDate, babyName, momName).StructScan(&p)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if _, _, err := upsertEntry(ctx, tx, p.ID, &entries[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DeleteDemoPregnancy deletes the user's demo pregnancy and everything attached to it.
+// It returns the stored files so the caller can remove them from disk.
+func (d *DB) DeleteDemoPregnancy(ctx context.Context, ownerID string) ([]models.File, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	files, err := deleteDemoPregnancy(ctx, tx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func deleteDemoPregnancy(ctx context.Context, tx *sqlx.Tx, ownerID string) ([]models.File, error) {
+	var files []models.File
+	err := tx.SelectContext(ctx, &files, `
+		DELETE FROM clingy_files
+		WHERE pregnancy_id IN (SELECT id FROM clingy_pregnancies WHERE owner_id = $1 AND demo)
+		RETURNING *
+	`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM clingy_pregnancies WHERE owner_id = $1 AND demo
+	`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return nil, ErrNotFound
+	}
+	return files, nil
+}
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"context"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Deprecated Endpoint Operations ============
+
+// RecordDeprecatedUsage counts a request to a deprecated route.
+func (d *DB) RecordDeprecatedUsage(ctx context.Context, route, appVersion, userID, userAgent string) error {
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_deprecated_usage (route, app_version, user_id, user_agent)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (route, app_version, user_id) DO UPDATE SET
+			hits = clingy_deprecated_usage.hits + 1,
+			user_agent = EXCLUDED.user_agent,
+			last_seen_at = NOW()
+	`, route, appVersion, userID, userAgent)
+	return err
+}
+
+// GetDeprecatedUsage summarizes deprecated route usage per app version, most recent first.
+func (d *DB) GetDeprecatedUsage(ctx context.Context) ([]models.DeprecatedUsage, error) {
+	var usage []models.DeprecatedUsage
+	err := d.db.SelectContext(ctx, &usage, `
+		SELECT route, app_version, SUM(hits) AS hits, COUNT(*) AS users,
+			MIN(first_seen_at) AS first_seen_at, MAX(last_seen_at) AS last_seen_at
+		FROM clingy_deprecated_usage
+		GROUP BY route, app_version
+		ORDER BY MAX(last_seen_at) DESC
+	`)
+	return usage, err
+}
@@ -0,0 +1,247 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Dry Run Operations ============
+
+// Dry runs execute the real statements in a transaction that is always rolled
+// back, so the preview can't drift from what the endpoint does.
+
+// dryRun runs fn in a transaction, reports the rows it deleted or updated per
+// table from the transaction's own statistics (cascades and triggers
+// included), and rolls it back.
+func (d *DB) dryRun(ctx context.Context, fn func(tx *sqlx.Tx) (*models.DeletionPreview, error)) (*models.DeletionPreview, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	preview, err := fn(tx)
+	if err != nil {
+		return nil, err
+	}
+	preview.DryRun = true
+	err = tx.SelectContext(ctx, &preview.Tables, `
+		SELECT relname AS table_name, n_tup_del AS deleted, n_tup_upd AS updated
+		FROM pg_stat_xact_user_tables
+		WHERE relname LIKE 'clingy\_%' AND (n_tup_del > 0 OR n_tup_upd > 0)
+		ORDER BY relname
+	`)
+	if err != nil {
+		return nil, err
+	}
+	if preview.Tables == nil {
+		preview.Tables = []models.TableImpact{}
+	}
+	return preview, nil
+}
+
+// DryRunDeleteEntry previews DeleteEntry.
+func (d *DB) DryRunDeleteEntry(ctx context.Context, pregnancyID int64, clientID string, visibility []string) (*models.DeletionPreview, error) {
+	return d.dryRun(ctx, func(tx *sqlx.Tx) (*models.DeletionPreview, error) {
+		return &models.DeletionPreview{}, deleteEntry(ctx, tx, pregnancyID, clientID, visibility)
+	})
+}
+
+// DryRunDeleteFile previews DeleteFile.
+func (d *DB) DryRunDeleteFile(ctx context.Context, fileID int64) (*models.DeletionPreview, error) {
+	return d.dryRun(ctx, func(tx *sqlx.Tx) (*models.DeletionPreview, error) {
+		var size int64
+		if err := tx.GetContext(ctx, &size, `
+			SELECT COALESCE(size_bytes, 0) FROM clingy_files WHERE id = $1 AND deleted_at IS NULL
+		`, fileID); err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		} else if err != nil {
+			return nil, err
+		}
+		if err := deleteFile(ctx, tx, fileID); err != nil {
+			return nil, err
+		}
+		return &models.DeletionPreview{Files: 1, FileBytes: size}, nil
+	})
+}
+
+// DryRunRemoveSupporter previews RemoveSupporter.
+func (d *DB) DryRunRemoveSupporter(ctx context.Context, supporterID int64, ownerID string) (*models.DeletionPreview, error) {
+	return d.dryRun(ctx, func(tx *sqlx.Tx) (*models.DeletionPreview, error) {
+		return &models.DeletionPreview{}, removeSupporter(ctx, tx, supporterID, ownerID)
+	})
+}
+
+// DryRunDeleteDemoPregnancy previews DeleteDemoPregnancy.
+func (d *DB) DryRunDeleteDemoPregnancy(ctx context.Context, ownerID string) (*models.DeletionPreview, error) {
+	return d.dryRun(ctx, func(tx *sqlx.Tx) (*models.DeletionPreview, error) {
+		files, err := deleteDemoPregnancy(ctx, tx, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		return filesPreview(files), nil
+	})
+}
+
+// DryRunDeletePregnancy previews DeletePregnancy.
+func (d *DB) DryRunDeletePregnancy(ctx context.Context, pregnancyID int64, ownerID string) (*models.DeletionPreview, error) {
+	return d.dryRun(ctx, func(tx *sqlx.Tx) (*models.DeletionPreview, error) {
+		files, err := deletePregnancy(ctx, tx, pregnancyID, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		return filesPreview(files), nil
+	})
+}
+
+// DryRunDeleteEntries previews DeleteEntries.
+func (d *DB) DryRunDeleteEntries(ctx context.Context, pregnancyID int64, clientIDs []string, entryType string, visibility []string) (*models.DeletionPreview, error) {
+	return d.dryRun(ctx, func(tx *sqlx.Tx) (*models.DeletionPreview, error) {
+		_, err := deleteEntries(ctx, tx, pregnancyID, clientIDs, entryType, visibility)
+		return &models.DeletionPreview{}, err
+	})
+}
+
+// DryRunDeleteUserData previews DeleteUserData.
+func (d *DB) DryRunDeleteUserData(ctx context.Context, userID string) (*models.DeletionPreview, error) {
+	return d.dryRun(ctx, func(tx *sqlx.Tx) (*models.DeletionPreview, error) {
+		files, err := deleteUserData(ctx, tx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return filesPreview(files), nil
+	})
+}
+
+// filesPreview counts the files a deletion removes and their stored size.
+func filesPreview(files []models.File) *models.DeletionPreview {
+	preview := &models.DeletionPreview{Files: len(files)}
+	for _, f := range files {
+		preview.FileBytes += f.SizeBytes.Int64
+	}
+	return preview
+}
+
+// SyncPush is a sync push as PostSync applies it, with the base of each entry.
+type SyncPush struct {
+	Pregnancy      *models.PregnancyRequest
+	Entries        []models.EntryRequest
+	Bases          []SyncBase // One per entry
+	DeletedEntries []string
+	DeleteBase     SyncBase
+	Visibility     []string // Of the pusher; stored entries hidden from it are not found
+	Settings       map[string]json.RawMessage
+	SettingsPatch  map[string]models.SettingPatch
+}
+
+// DryRunSync previews a sync push in PostSync's order: the pregnancy update
+// (or its creation for ownerID when pregnancyID is 0), entries, deletions,
+// settings, then settings patches. Changes are reported per item and the
+// touched rows per table, inserts included.
+func (d *DB) DryRunSync(ctx context.Context, ownerID string, pregnancyID int64, push *SyncPush) (*models.SyncPreview, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	preview := &models.SyncPreview{DryRun: true, Changes: []models.SyncChange{}}
+	if pregnancyID == 0 {
+		p, err := createPregnancy(ctx, tx, ownerID, push.Pregnancy)
+		if err != nil {
+			return nil, err
+		}
+		pregnancyID = p.ID
+		preview.PregnancyCreated = true
+	} else if push.Pregnancy != nil {
+		if _, err := updatePregnancy(ctx, tx, pregnancyID, push.Pregnancy); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range push.Entries {
+		e := &push.Entries[i]
+		change := models.SyncChange{Kind: "entry", Key: e.ClientID, EntryType: e.EntryType, Outcome: models.SyncOutcomeUpdated}
+		current, created, err := writeEntry(ctx, tx, pregnancyID, e, push.Bases[i], push.Visibility)
+		switch {
+		case err == ErrConflict:
+			change.Outcome = models.SyncOutcomeConflict
+			change.Entries = []models.Entry{*current}
+		case err == ErrNotFound:
+			change.Outcome = models.SyncOutcomeNotFound
+		case err != nil:
+			return nil, err
+		case created:
+			change.Outcome = models.SyncOutcomeCreated
+		}
+		preview.Changes = append(preview.Changes, change)
+	}
+
+	for _, clientID := range push.DeletedEntries {
+		change := models.SyncChange{Kind: "deletion", Key: clientID, Outcome: models.SyncOutcomeDeleted}
+		if push.DeleteBase.IsZero() {
+			err = deleteEntry(ctx, tx, pregnancyID, clientID, push.Visibility)
+		} else {
+			change.Entries, err = deleteEntryUnlessChanged(ctx, tx, pregnancyID, clientID, push.DeleteBase, push.Visibility)
+		}
+		switch {
+		case err == ErrConflict:
+			change.Outcome = models.SyncOutcomeConflict
+			change.EntryType = change.Entries[0].EntryType
+		case err == ErrNotFound:
+			change.Outcome = models.SyncOutcomeNotFound
+		case err != nil:
+			return nil, err
+		}
+		preview.Changes = append(preview.Changes, change)
+	}
+
+	for _, settingType := range sortedSettingTypes(push.Settings) {
+		if err := upsertSetting(ctx, tx, pregnancyID, settingType, push.Settings[settingType]); err != nil {
+			return nil, err
+		}
+		preview.Changes = append(preview.Changes, models.SyncChange{Kind: "setting", Key: settingType, Outcome: models.SyncOutcomeUpdated})
+	}
+	for _, settingType := range sortedSettingTypes(push.SettingsPatch) {
+		p := push.SettingsPatch[settingType]
+		change := models.SyncChange{Kind: "setting", Key: settingType, Outcome: models.SyncOutcomeUpdated}
+		current, err := patchSetting(ctx, tx, pregnancyID, settingType, p.BaseVersion, p.Patch)
+		if err == ErrConflict {
+			change.Outcome = models.SyncOutcomeConflict
+			change.Setting = current
+		} else if err != nil {
+			return nil, err
+		}
+		preview.Changes = append(preview.Changes, change)
+	}
+
+	err = tx.SelectContext(ctx, &preview.Tables, `
+		SELECT relname AS table_name, n_tup_del AS deleted, n_tup_upd AS updated, n_tup_ins AS inserted
+		FROM pg_stat_xact_user_tables
+		WHERE relname LIKE 'clingy\_%' AND (n_tup_del > 0 OR n_tup_upd > 0 OR n_tup_ins > 0)
+		ORDER BY relname
+	`)
+	if err != nil {
+		return nil, err
+	}
+	if preview.Tables == nil {
+		preview.Tables = []models.TableImpact{}
+	}
+	return preview, nil
+}
+
+// sortedSettingTypes returns the keys of a settings map in order, so previews
+// are stable.
+func sortedSettingTypes[V any](settings map[string]V) []string {
+	types := make([]string, 0, len(settings))
+	for t := range settings {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
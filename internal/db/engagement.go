@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Supporter Engagement Operations ============
+
+// Engagement is read from clingy_access_fingerprints, which every authenticated
+// request already touches; nothing is recorded for it separately.
+
+// GetSupporterEngagement gets the last request time and visit count of each
+// active supporter of a pregnancy who shares their activity, keyed by user ID.
+// Only fingerprints seen since the supporter joined are counted.
+func (d *DB) GetSupporterEngagement(ctx context.Context, pregnancyID int64) (map[string]models.SupporterEngagement, error) {
+	var rows []models.SupporterEngagement
+	err := d.db.SelectContext(ctx, &rows, `
+		SELECT s.user_id, MAX(f.last_seen_at) AS last_viewed_at, COALESCE(SUM(f.visits), 0) AS visits
+		FROM clingy_supporters s
+		LEFT JOIN clingy_access_fingerprints f ON f.user_id = s.user_id AND f.last_seen_at >= s.joined_at
+		WHERE s.pregnancy_id = $1 AND s.removed_at IS NULL AND s.share_activity
+		GROUP BY s.user_id
+	`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]models.SupporterEngagement, len(rows))
+	for _, row := range rows {
+		result[row.UserID] = row
+	}
+	return result, nil
+}
+
+// GetSupporterActivitySharing reports whether a supporter shares their activity.
+// Returns ErrNotFound if the user isn't an active supporter.
+func (d *DB) GetSupporterActivitySharing(ctx context.Context, userID string) (bool, error) {
+	var share sql.NullBool
+	err := d.db.GetContext(ctx, &share, `
+		SELECT bool_and(share_activity) FROM clingy_supporters
+		WHERE user_id = $1 AND removed_at IS NULL
+	`, userID)
+	if err != nil {
+		return false, err
+	}
+	if !share.Valid {
+		return false, ErrNotFound
+	}
+	return share.Bool, nil
+}
+
+// SetSupporterActivitySharing shows or hides a supporter's activity from the
+// owners of every pregnancy they support. Returns ErrNotFound if the user isn't
+// an active supporter.
+func (d *DB) SetSupporterActivitySharing(ctx context.Context, userID string, share bool) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_supporters SET share_activity = $2
+		WHERE user_id = $1 AND removed_at IS NULL
+	`, userID, share)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
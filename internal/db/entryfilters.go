@@ -0,0 +1,255 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Entry Field Filter Operations ============
+
+// Kinds of filterable payload fields.
+const (
+	FieldNumber = "number" // Compared numerically; non-numeric values never match
+	FieldText   = "text"   // Compared case-insensitively
+	FieldTags   = "tags"   // Array of strings, matched by containment
+)
+
+// Filter operators and the field kinds they apply to.
+var filterOps = map[string]map[string]bool{
+	"eq":  {FieldNumber: true, FieldText: true},
+	"lt":  {FieldNumber: true},
+	"lte": {FieldNumber: true},
+	"gt":  {FieldNumber: true},
+	"gte": {FieldNumber: true},
+	"has": {FieldTags: true},
+}
+
+var filterSQLOps = map[string]string{"eq": "=", "lt": "<", "lte": "<=", "gt": ">", "gte": ">="}
+
+// numberPattern matches the values clingy_try_number converts.
+var numberPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// entryFields lists the filterable payload fields of each entry type. Each has
+// an expression index from migration 037; add the index with the field.
+var entryFields = map[string]map[string]string{
+	"weight":         {"weight": FieldNumber},
+	"blood_pressure": {"systolic": FieldNumber, "diastolic": FieldNumber},
+	"glucose":        {"glucose": FieldNumber},
+	"symptom":        {"symptom": FieldText, "severity": FieldNumber, "tags": FieldTags},
+	"water":          {"amount": FieldNumber},
+	"journal":        {"tags": FieldTags},
+	"photo":          {"tags": FieldTags},
+	"milestone":      {"tags": FieldTags},
+}
+
+// ValidateEntryFilter reports why a filter can't be applied to entries of
+// entryType, or "" when it can.
+func ValidateEntryFilter(entryType string, f models.EntryFilter) string {
+	kind, ok := entryFields[entryType][f.Field]
+	if !ok {
+		return fmt.Sprintf("%q is not a filterable field of %q entries (filterable: %s)", f.Field, entryType, entryFilterFields(entryType))
+	}
+	if !filterOps[f.Op][kind] {
+		return fmt.Sprintf("operator %q does not apply to %s field %q", f.Op, kind, f.Field)
+	}
+	if kind == FieldNumber && !numberPattern.MatchString(f.Value) {
+		return fmt.Sprintf("%q needs a numeric value", f.Field)
+	}
+	return ""
+}
+
+// entryFilterClause renders a validated filter as a condition on clingy_entries,
+// appending its arguments. Indexed conditions inline the field and entry type
+// as literals so they match the partial expression indexes even under generic
+// plans; unindexed ones bind them as parameters, which never match, the way
+// payload fields were queried before the indexes existed.
+func entryFilterClause(entryType string, f models.EntryFilter, indexed bool, args []interface{}) (string, []interface{}) {
+	kind := entryFields[entryType][f.Field]
+	field := "'" + f.Field + "'" // Registered names only; safe to inline
+	cond := fmt.Sprintf(" AND entry_type = '%s'", entryType)
+	if !indexed {
+		args = append(args, f.Field)
+		field = fmt.Sprintf("$%d", len(args))
+		cond = ""
+	}
+
+	if kind == FieldNumber {
+		n, _ := strconv.ParseFloat(f.Value, 64)
+		args = append(args, n)
+	} else {
+		args = append(args, f.Value)
+	}
+	value := fmt.Sprintf("$%d", len(args))
+	switch kind {
+	case FieldNumber:
+		return fmt.Sprintf("%s AND clingy_try_number(data->>%s) %s %s::double precision", cond, field, filterSQLOps[f.Op], value), args
+	case FieldText:
+		return fmt.Sprintf("%s AND LOWER(data->>%s) = LOWER(%s)", cond, field, value), args
+	default:
+		return fmt.Sprintf("%s AND data->%s @> jsonb_build_array(%s::text)", cond, field, value), args
+	}
+}
+
+// BenchmarkEntryFilters times each filterable field's query with and without its
+// index, on the pregnancy with the most entries of the type and a typical value.
+// Fields without any entries are skipped.
+func (d *DB) BenchmarkEntryFilters(ctx context.Context) ([]models.EntryFilterBenchmark, error) {
+	types := make([]string, 0, len(entryFields))
+	for t := range entryFields {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	var results []models.EntryFilterBenchmark
+	for _, entryType := range types {
+		fields := make([]string, 0, len(entryFields[entryType]))
+		for f := range entryFields[entryType] {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+
+		for _, field := range fields {
+			b, err := d.benchmarkEntryFilter(ctx, entryType, field)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", entryType, field, err)
+			}
+			if b != nil {
+				results = append(results, *b)
+			}
+		}
+	}
+	return results, nil
+}
+
+func (d *DB) benchmarkEntryFilter(ctx context.Context, entryType, field string) (*models.EntryFilterBenchmark, error) {
+	kind := entryFields[entryType][field]
+
+	// A typical value: the average of a number, the most common text or tag
+	var sample struct {
+		PregnancyID int64          `db:"pregnancy_id"`
+		Entries     int64          `db:"entries"`
+		Value       sql.NullString `db:"value"`
+	}
+	var valueSQL string
+	switch kind {
+	case FieldNumber:
+		valueSQL = `(SELECT AVG(clingy_try_number(data->>$2))::text FROM clingy_entries WHERE pregnancy_id = p.pregnancy_id AND entry_type = $1)`
+	case FieldText:
+		valueSQL = `(SELECT LOWER(data->>$2) FROM clingy_entries WHERE pregnancy_id = p.pregnancy_id AND entry_type = $1 AND data->>$2 IS NOT NULL
+			GROUP BY 1 ORDER BY COUNT(*) DESC LIMIT 1)`
+	default:
+		valueSQL = `(SELECT t FROM clingy_entries, jsonb_array_elements_text(CASE WHEN jsonb_typeof(data->$2) = 'array' THEN data->$2 END) t
+			WHERE pregnancy_id = p.pregnancy_id AND entry_type = $1 GROUP BY 1 ORDER BY COUNT(*) DESC LIMIT 1)`
+	}
+	err := d.db.GetContext(ctx, &sample, fmt.Sprintf(`
+		SELECT p.pregnancy_id, p.entries, %s AS value
+		FROM (
+			SELECT pregnancy_id, COUNT(*) AS entries FROM clingy_entries
+			WHERE entry_type = $1
+			GROUP BY pregnancy_id
+			ORDER BY COUNT(*) DESC
+			LIMIT 1
+		) p
+	`, valueSQL), entryType, field)
+	if err == sql.ErrNoRows || (err == nil && !sample.Value.Valid) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f := models.EntryFilter{Field: field, Op: "eq", Value: sample.Value.String}
+	switch kind {
+	case FieldNumber:
+		f.Op = "gte"
+	case FieldTags:
+		f.Op = "has"
+	}
+
+	b := &models.EntryFilterBenchmark{
+		EntryType: entryType,
+		Field:     field,
+		Kind:      kind,
+		Filter:    f.Op + ":" + f.Value,
+		Entries:   sample.Entries,
+	}
+	for _, indexed := range []bool{true, false} {
+		clause, args := entryFilterClause(entryType, f, indexed, []interface{}{sample.PregnancyID, entryType})
+		plan, err := d.explainAnalyze(ctx, `SELECT * FROM clingy_entries WHERE pregnancy_id = $1 AND entry_type = $2`+clause, args...)
+		if err != nil {
+			return nil, err
+		}
+		if indexed {
+			b.IndexedMs, b.Matched, b.Indexes = plan.ExecutionTime, plan.Rows, plan.Indexes
+		} else {
+			b.UnindexedMs = plan.ExecutionTime
+		}
+	}
+	return b, nil
+}
+
+// queryPlan is what the benchmark reads from EXPLAIN ANALYZE.
+type queryPlan struct {
+	ExecutionTime float64
+	Rows          int64
+	Indexes       []string
+}
+
+// explainAnalyze runs a query under EXPLAIN ANALYZE and returns its execution
+// time, result rows and the indexes it used.
+func (d *DB) explainAnalyze(ctx context.Context, query string, args ...interface{}) (*queryPlan, error) {
+	var raw []byte
+	if err := d.db.GetContext(ctx, &raw, "EXPLAIN (ANALYZE, FORMAT JSON) "+query, args...); err != nil {
+		return nil, err
+	}
+	var out []struct {
+		Plan          map[string]interface{} `json:"Plan"`
+		ExecutionTime float64                `json:"Execution Time"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil || len(out) == 0 {
+		return nil, fmt.Errorf("unexpected EXPLAIN output: %v", err)
+	}
+
+	plan := &queryPlan{ExecutionTime: out[0].ExecutionTime}
+	if rows, ok := out[0].Plan["Actual Rows"].(float64); ok {
+		plan.Rows = int64(rows)
+	}
+	var walk func(node map[string]interface{})
+	walk = func(node map[string]interface{}) {
+		if name, ok := node["Index Name"].(string); ok {
+			plan.Indexes = append(plan.Indexes, name)
+		}
+		children, _ := node["Plans"].([]interface{})
+		for _, c := range children {
+			if child, ok := c.(map[string]interface{}); ok {
+				walk(child)
+			}
+		}
+	}
+	walk(out[0].Plan)
+	if plan.Indexes == nil {
+		plan.Indexes = []string{}
+	}
+	return plan, nil
+}
+
+// entryFilterFields lists the filterable fields of an entry type, for errors.
+func entryFilterFields(entryType string) string {
+	fields := make([]string, 0, len(entryFields[entryType]))
+	for f := range entryFields[entryType] {
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return "none"
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ", ")
+}
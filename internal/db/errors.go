@@ -0,0 +1,77 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ============ Domain Errors ============
+
+// Errors a Postgres error is classified as. Match them with errors.Is; the
+// *Error carrying them also unwraps to the driver error.
+var (
+	ErrDuplicate  = errors.New("duplicate")
+	ErrForeignKey = errors.New("foreign key violation")
+	ErrPermission = errors.New("permission denied")
+	ErrInvalid    = errors.New("invalid value")
+)
+
+// pgErrorKinds maps SQLSTATE codes to domain errors.
+var pgErrorKinds = map[string]error{
+	"23505": ErrDuplicate,  // unique_violation
+	"23P01": ErrDuplicate,  // exclusion_violation
+	"23503": ErrForeignKey, // foreign_key_violation
+	"42501": ErrPermission, // insufficient_privilege, including row-level security
+	"23502": ErrInvalid,    // not_null_violation
+	"23514": ErrInvalid,    // check_violation
+	"22001": ErrInvalid,    // string_data_right_truncation
+	"22003": ErrInvalid,    // numeric_value_out_of_range
+	"22007": ErrInvalid,    // invalid_datetime_format
+	"22008": ErrInvalid,    // datetime_field_overflow
+	"22P02": ErrInvalid,    // invalid_text_representation
+}
+
+// Error is a Postgres error classified as a domain error.
+type Error struct {
+	Kind       error  // ErrDuplicate, ErrForeignKey, ErrPermission or ErrInvalid
+	Code       string // SQLSTATE
+	Constraint string // Violated constraint, if any
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Is matches the error's kind.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Classify wraps a Postgres error of a known class in an *Error. Other errors,
+// including sql.ErrNoRows and the sentinel errors of this package, are
+// returned as they are. Calls through DB are classified already; errors from
+// transactions are not until they pass through here.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	kind, ok := pgErrorKinds[pgErr.Code]
+	if !ok {
+		return err
+	}
+	return &Error{Kind: kind, Code: pgErr.Code, Constraint: pgErr.ConstraintName, Err: err}
+}
@@ -0,0 +1,257 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Failover ============
+
+// Failover targets
+const (
+	FailoverPrimary = "primary"
+	FailoverStandby = "standby"
+)
+
+// The active server is probed every failoverProbeInterval. After
+// failoverThreshold failed probes in a row the other server is tried, with
+// retries backing off from failoverRetryMin to failoverRetryMax until one of
+// the two takes writes again. The old pool stays open for failoverDrain so
+// calls in flight can finish.
+const (
+	failoverProbeInterval = 5 * time.Second
+	failoverProbeTimeout  = 3 * time.Second
+	failoverThreshold     = 3
+	failoverRetryMin      = time.Second
+	failoverRetryMax      = 30 * time.Second
+	failoverDrain         = 30 * time.Second
+)
+
+// errReadOnly is a server still in recovery, i.e. a standby not yet promoted
+// or a demoted primary. It can't take writes, so it isn't failed over to.
+var errReadOnly = errors.New("server is in recovery (read-only)")
+
+type failover struct {
+	mu             sync.Mutex
+	urls           map[string]string // Target name to DSN
+	active         string
+	failures       int // Failed probes in a row
+	switching      bool
+	failovers      int64
+	lastFailoverAt time.Time
+	lastProbeError string
+	stop           chan struct{}
+}
+
+// other returns the target that isn't active.
+func (f *failover) other() string {
+	if f.active == FailoverPrimary {
+		return FailoverStandby
+	}
+	return FailoverPrimary
+}
+
+// openWritable connects to a server and checks it takes writes.
+func openWritable(databaseURL string) (*sqlx.DB, error) {
+	db, err := openPool(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), failoverProbeTimeout)
+	defer cancel()
+	if err := checkWritable(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// checkWritable asks a server whether it is a primary. It doubles as a ping.
+func checkWritable(ctx context.Context, db *sqlx.DB) error {
+	var inRecovery bool
+	if err := db.GetContext(ctx, &inRecovery, `SELECT pg_is_in_recovery()`); err != nil {
+		return err
+	}
+	if inRecovery {
+		return errReadOnly
+	}
+	return nil
+}
+
+// watch probes the active server until the connection is closed, failing
+// over when it stops answering or stops taking writes.
+func (c *conn) watch() {
+	f := c.failover
+	ticker := time.NewTicker(failoverProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), failoverProbeTimeout)
+		err := checkWritable(ctx, c.current())
+		cancel()
+
+		f.mu.Lock()
+		if err == nil {
+			f.failures = 0
+			f.mu.Unlock()
+			continue
+		}
+		f.failures++
+		f.lastProbeError = err.Error()
+		active, due := f.active, f.failures >= failoverThreshold
+		f.mu.Unlock()
+
+		log.Printf("Database failover: %s probe failed: %v", active, err)
+		if due {
+			c.failOver()
+		}
+	}
+}
+
+// failOver switches to the other server, retrying with exponential backoff.
+// It gives up early if the active server recovers in the meantime.
+func (c *conn) failOver() {
+	f := c.failover
+	f.mu.Lock()
+	f.switching = true
+	from, to := f.active, f.other()
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.switching = false
+		f.failures = 0
+		f.mu.Unlock()
+	}()
+
+	delay := failoverRetryMin
+	for {
+		pool, err := openWritable(f.urls[to])
+		if err == nil {
+			c.promote(pool, from, to)
+			return
+		}
+		log.Printf("Database failover: %s unavailable: %v", to, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), failoverProbeTimeout)
+		err = checkWritable(ctx, c.current())
+		cancel()
+		if err == nil {
+			log.Printf("Database failover: %s recovered, staying", from)
+			return
+		}
+
+		f.mu.Lock()
+		f.lastProbeError = err.Error()
+		f.mu.Unlock()
+
+		select {
+		case <-f.stop:
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > failoverRetryMax {
+			delay = failoverRetryMax
+		}
+	}
+}
+
+// promote makes pool the one calls go to and closes the old one once calls in
+// flight had time to finish.
+func (c *conn) promote(pool *sqlx.DB, from, to string) {
+	f := c.failover
+	old := c.pool.Swap(pool)
+
+	f.mu.Lock()
+	f.active = to
+	f.failovers++
+	f.lastFailoverAt = time.Now()
+	f.mu.Unlock()
+
+	// Failures were the old server's; the new one starts with a closed breaker
+	c.breaker.reset()
+	log.Printf("Database failover: switched from %s to %s", from, to)
+
+	time.AfterFunc(failoverDrain, func() {
+		old.Close()
+	})
+}
+
+// close stops probing.
+func (f *failover) close() {
+	if f != nil {
+		close(f.stop)
+	}
+}
+
+// status reports the failover state, or nil without a standby.
+func (f *failover) status() *models.FailoverStatus {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	status := &models.FailoverStatus{
+		Active:         f.active,
+		Switching:      f.switching,
+		ProbeFailures:  f.failures,
+		Failovers:      f.failovers,
+		LastProbeError: f.lastProbeError,
+	}
+	if !f.lastFailoverAt.IsZero() {
+		at := f.lastFailoverAt.UTC()
+		status.LastFailoverAt = &at
+	}
+	return status
+}
+
+// connect opens the primary, or the standby if the primary can't take writes,
+// and starts probing. Without a standby it only opens the primary.
+func connect(databaseURL, standbyURL string) (*conn, error) {
+	c := &conn{breaker: newBreaker()}
+
+	if standbyURL == "" {
+		pool, err := openPool(databaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		c.pool.Store(pool)
+		return c, nil
+	}
+
+	f := &failover{
+		urls:   map[string]string{FailoverPrimary: databaseURL, FailoverStandby: standbyURL},
+		active: FailoverPrimary,
+		stop:   make(chan struct{}),
+	}
+	pool, err := openWritable(databaseURL)
+	if err != nil {
+		log.Printf("Database failover: primary unavailable at startup: %v", err)
+		f.lastProbeError = err.Error()
+		f.active = FailoverStandby
+		pool, err = openWritable(standbyURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to database or standby: %w", err)
+		}
+	}
+	c.pool.Store(pool)
+	c.failover = f
+	go c.watch()
+	return c, nil
+}
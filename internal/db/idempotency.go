@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Idempotency Operations ============
+
+// ClaimIdempotencyKey records a request made with an idempotency key. It
+// reports true if the caller should run the request: the key is new, its
+// record expired before expiredBefore, or its first request was abandoned
+// before abandonedBefore. Otherwise the existing record is returned.
+func (d *DB) ClaimIdempotencyKey(ctx context.Context, rec *models.IdempotencyRecord, expiredBefore, abandonedBefore time.Time) (*models.IdempotencyRecord, bool, error) {
+	var claimed models.IdempotencyRecord
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_idempotency_keys (user_id, idempotency_key, route, fingerprint)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, idempotency_key) DO UPDATE SET
+			route = EXCLUDED.route,
+			fingerprint = EXCLUDED.fingerprint,
+			status = NULL,
+			content_type = NULL,
+			response = NULL,
+			created_at = NOW()
+		WHERE clingy_idempotency_keys.created_at < $5
+		   OR (clingy_idempotency_keys.status IS NULL AND clingy_idempotency_keys.created_at < $6)
+		RETURNING *
+	`, rec.UserID, rec.Key, rec.Route, rec.Fingerprint, expiredBefore, abandonedBefore).StructScan(&claimed)
+	if err == nil {
+		return &claimed, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	var existing models.IdempotencyRecord
+	err = d.db.GetContext(ctx, &existing, `
+		SELECT * FROM clingy_idempotency_keys WHERE user_id = $1 AND idempotency_key = $2
+	`, rec.UserID, rec.Key)
+	if err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+// CompleteIdempotencyKey stores the response of a claimed key's request.
+func (d *DB) CompleteIdempotencyKey(ctx context.Context, userID, key string, status int, contentType string, response []byte) error {
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_idempotency_keys SET status = $3, content_type = $4, response = $5
+		WHERE user_id = $1 AND idempotency_key = $2
+	`, userID, key, status, contentType, response)
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a key whose request failed, so a retry runs
+// it again.
+func (d *DB) ReleaseIdempotencyKey(ctx context.Context, userID, key string) error {
+	_, err := d.db.ExecContext(ctx, `
+		DELETE FROM clingy_idempotency_keys WHERE user_id = $1 AND idempotency_key = $2
+	`, userID, key)
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys deletes records created before cutoff.
+func (d *DB) DeleteExpiredIdempotencyKeys(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := d.db.ExecContext(ctx, `DELETE FROM clingy_idempotency_keys WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/pagination"
+)
+
+// ============ Impersonation Operations ============
+
+// CreateImpersonation starts an impersonation session, stored by its token's hash.
+func (d *DB) CreateImpersonation(ctx context.Context, adminID, userID, reason, scope, tokenHash string, expiresAt time.Time) (*models.Impersonation, error) {
+	var imp models.Impersonation
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_impersonations (admin_id, user_id, reason, scope, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING *, 0 AS requests
+	`, adminID, userID, reason, scope, tokenHash, expiresAt).StructScan(&imp)
+	if err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// GetActiveImpersonation finds a session by token hash that has neither ended
+// nor expired.
+func (d *DB) GetActiveImpersonation(ctx context.Context, tokenHash string) (*models.Impersonation, error) {
+	var imp models.Impersonation
+	err := d.db.GetContext(ctx, &imp, `
+		SELECT *, 0 AS requests FROM clingy_impersonations
+		WHERE token_hash = $1 AND ended_at IS NULL AND expires_at > NOW()
+	`, tokenHash)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// GetImpersonations gets a page of all impersonation sessions, newest first,
+// with their request counts.
+func (d *DB) GetImpersonations(ctx context.Context, page pagination.Params) ([]models.Impersonation, error) {
+	clause, args := page.Clause("i.created_at", "i.id", nil)
+	var sessions []models.Impersonation
+	err := d.db.SelectContext(ctx, &sessions, `
+		SELECT i.*, (SELECT COUNT(*) FROM clingy_impersonation_requests r WHERE r.impersonation_id = i.id) AS requests
+		FROM clingy_impersonations i WHERE true`+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// EndImpersonation ends a session early. Sessions that already ended or
+// expired are ErrNotFound.
+func (d *DB) EndImpersonation(ctx context.Context, id int64) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_impersonations SET ended_at = NOW()
+		WHERE id = $1 AND ended_at IS NULL AND expires_at > NOW()
+	`, id)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateImpersonatedRequest records a request made during a session.
+func (d *DB) CreateImpersonatedRequest(ctx context.Context, req *models.ImpersonatedRequest) error {
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_impersonation_requests (impersonation_id, method, path, status)
+		VALUES ($1, $2, $3, $4)
+	`, req.ImpersonationID, req.Method, req.Path, req.Status)
+	return err
+}
+
+// GetImpersonatedRequests gets a page of a session's requests, newest first.
+func (d *DB) GetImpersonatedRequests(ctx context.Context, impersonationID int64, page pagination.Params) ([]models.ImpersonatedRequest, error) {
+	clause, args := page.Clause("created_at", "id", []interface{}{impersonationID})
+	var requests []models.ImpersonatedRequest
+	err := d.db.SelectContext(ctx, &requests, `SELECT * FROM clingy_impersonation_requests WHERE impersonation_id = $1`+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// GetFinishedImpersonations gets sessions that ended or expired and whose
+// owner has not been told yet, with their request counts.
+func (d *DB) GetFinishedImpersonations(ctx context.Context, limit int) ([]models.Impersonation, error) {
+	var sessions []models.Impersonation
+	err := d.db.SelectContext(ctx, &sessions, `
+		SELECT i.*, (SELECT COUNT(*) FROM clingy_impersonation_requests r WHERE r.impersonation_id = i.id) AS requests
+		FROM clingy_impersonations i
+		WHERE i.owner_notified_at IS NULL AND (i.ended_at IS NOT NULL OR i.expires_at <= NOW())
+		ORDER BY i.expires_at
+		LIMIT $1
+	`, limit)
+	return sessions, err
+}
+
+// MarkImpersonationNotified records that a session's end notice went out.
+func (d *DB) MarkImpersonationNotified(ctx context.Context, id int64) error {
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_impersonations SET owner_notified_at = NOW() WHERE id = $1
+	`, id)
+	return err
+}
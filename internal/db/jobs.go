@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Job Operations ============
+
+// CreateJob queues a background job.
+func (d *DB) CreateJob(ctx context.Context, pregnancyID int64, userID, kind string) (*models.Job, error) {
+	var j models.Job
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_jobs (pregnancy_id, user_id, kind)
+		VALUES ($1, $2, $3)
+		RETURNING *
+	`, pregnancyID, userID, kind).StructScan(&j)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// GetJob gets a job owned by the user.
+func (d *DB) GetJob(ctx context.Context, jobID int64, userID string) (*models.Job, error) {
+	var j models.Job
+	err := d.db.GetContext(ctx, &j, `
+		SELECT * FROM clingy_jobs WHERE id = $1 AND user_id = $2
+	`, jobID, userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// UpdateJobProgress marks a job running with the given progress (0-100).
+func (d *DB) UpdateJobProgress(ctx context.Context, jobID int64, progress int) error {
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_jobs SET status = 'running', progress = $2, updated_at = NOW()
+		WHERE id = $1
+	`, jobID, progress)
+	return err
+}
+
+// CompleteJob stores the job result.
+func (d *DB) CompleteJob(ctx context.Context, jobID int64, result json.RawMessage) error {
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_jobs SET
+			status = 'completed',
+			progress = 100,
+			result = $2,
+			updated_at = NOW(),
+			completed_at = NOW()
+		WHERE id = $1
+	`, jobID, result)
+	return err
+}
+
+// FailJob records why a job failed.
+func (d *DB) FailJob(ctx context.Context, jobID int64, message string) error {
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_jobs SET
+			status = 'failed',
+			error = $2,
+			updated_at = NOW(),
+			completed_at = NOW()
+		WHERE id = $1
+	`, jobID, message)
+	return err
+}
+
+// FailInterruptedJobs fails jobs left queued or running by a previous process.
+func (d *DB) FailInterruptedJobs(ctx context.Context) (int64, error) {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_jobs SET
+			status = 'failed',
+			error = 'interrupted by server restart',
+			updated_at = NOW(),
+			completed_at = NOW()
+		WHERE status IN ('queued', 'running')
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"sync"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Load Monitoring ============
+
+// Each call moves the latency average latencyWeight of the way to its own
+// latency. After latencyIdle without calls the average starts over, so a spike
+// isn't remembered through a quiet period.
+const (
+	latencyWeight = 0.1
+	latencyIdle   = 5 * time.Second
+)
+
+// latencyTracker keeps a moving average of database call latency.
+type latencyTracker struct {
+	mu   sync.Mutex
+	avg  time.Duration
+	last time.Time
+}
+
+func (t *latencyTracker) observe(d time.Duration, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.last) > latencyIdle {
+		t.avg = d
+	} else {
+		t.avg += time.Duration(latencyWeight * float64(d-t.avg))
+	}
+	t.last = now
+}
+
+func (t *latencyTracker) average(now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now.Sub(t.last) > latencyIdle {
+		return 0
+	}
+	return t.avg
+}
+
+// Load reports recent database latency and connection pool use.
+func (d *DB) Load() models.DatabaseLoad {
+	stats := d.db.current().Stats()
+	return models.DatabaseLoad{
+		LatencyMs: float64(d.db.latency.average(time.Now()).Microseconds()) / 1000,
+		PoolInUse: stats.InUse,
+		PoolMax:   stats.MaxOpenConnections,
+		Waiting:   stats.WaitCount,
+	}
+}
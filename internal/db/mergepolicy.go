@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/scalecode-solutions/tracker2api/internal/entrydata"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/vclock"
+)
+
+// ============ Merge Policy Operations ============
+
+// MergePolicySetting is the setting holding the owner's merge policy per entry
+// type, e.g. {"kick_session": "field_merge"}.
+const MergePolicySetting = "merge_policy"
+
+// Merge policies. Entry types without one keep the default: a write replaces
+// the entry, and a sync push based on an older version is reported as a
+// conflict for the client to resolve.
+const (
+	// MergeLastWriterWins lets every write replace the entry, stale or not.
+	MergeLastWriterWins = "last_writer_wins"
+	// MergeServerWins never lets a write replace an entry that changed since
+	// the version it is based on. Writes without a base only create entries
+	// or restore deleted ones.
+	MergeServerWins = "server_wins"
+	// MergeFields merges stale writes into the entry field by field.
+	MergeFields = "field_merge"
+)
+
+// ParseMergePolicy validates a merge policy setting and returns the policy of
+// each entry type.
+func ParseMergePolicy(data json.RawMessage) (map[string]string, error) {
+	var policy map[string]string
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("merge policy must map entry types to policies")
+	}
+	for entryType, p := range policy {
+		if entryType == "" || len(entryType) > 50 {
+			return nil, fmt.Errorf("invalid entry type %q", entryType)
+		}
+		if p != MergeLastWriterWins && p != MergeServerWins && p != MergeFields {
+			return nil, fmt.Errorf("%s: policy must be %s, %s or %s", entryType, MergeLastWriterWins, MergeServerWins, MergeFields)
+		}
+	}
+	return policy, nil
+}
+
+// entryMergePolicy returns the pregnancy's merge policy for an entry type, or
+// "" for the default.
+func entryMergePolicy(ctx context.Context, tx *sqlx.Tx, pregnancyID int64, entryType string) (string, error) {
+	var policy sql.NullString
+	err := tx.GetContext(ctx, &policy, `
+		SELECT data->>$3 FROM clingy_settings
+		WHERE pregnancy_id = $1 AND setting_type = $2 AND deleted_at IS NULL AND jsonb_typeof(data) = 'object'
+	`, pregnancyID, MergePolicySetting, entryType)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	switch policy.String {
+	case MergeLastWriterWins, MergeServerWins, MergeFields:
+		return policy.String, nil
+	}
+	return "", nil
+}
+
+// writeEntry upserts an entry under the merge policy of its type. since is the
+// server updatedAt the write is based on, zero when unknown. When the policy
+// keeps the stored entry it is returned with ErrConflict and nothing is written.
+// A stored entry whose visibility isn't one of visibility (nil for any) is not
+// found, so writers can't overwrite or restore entries hidden from them.
+func writeEntry(ctx context.Context, tx *sqlx.Tx, pregnancyID int64, req *models.EntryRequest, base SyncBase, visibility []string) (*models.Entry, bool, error) {
+	if visibility != nil {
+		current, err := lockEntry(ctx, tx, pregnancyID, req.EntryType, req.ClientID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, false, err
+		}
+		if err == nil && !visibleTo(visibility, current.Visibility) {
+			return nil, false, ErrNotFound
+		}
+	}
+
+	policy, err := entryMergePolicy(ctx, tx, pregnancyID, req.EntryType)
+	if err != nil {
+		return nil, false, err
+	}
+	if policy == MergeLastWriterWins || (policy == "" && base.IsZero()) {
+		return upsertEntry(ctx, tx, pregnancyID, req)
+	}
+
+	current, err := lockEntry(ctx, tx, pregnancyID, req.EntryType, req.ClientID)
+	if err == sql.ErrNoRows {
+		return upsertEntry(ctx, tx, pregnancyID, req)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// A write based on the current version, or restoring a deleted entry
+	// without a base, replaces it under every policy
+	stale := base.changed(current)
+	if !stale || (base.IsZero() && current.DeletedAt.Valid) {
+		return upsertEntry(ctx, tx, pregnancyID, req)
+	}
+
+	if policy == MergeFields && !current.DeletedAt.Valid {
+		merged, err := mergeEntryFields(ctx, tx, current, req, base)
+		if err != nil {
+			return nil, false, err
+		}
+		if merged == nil {
+			// Payload versions differ; fields can't be compared
+			if base.IsZero() {
+				return upsertEntry(ctx, tx, pregnancyID, req)
+			}
+			return current, false, ErrConflict
+		}
+		write := *req
+		write.Data = merged
+		return upsertEntry(ctx, tx, pregnancyID, &write)
+	}
+	return current, false, ErrConflict
+}
+
+// mergeEntryFields merges a stale write into the stored entry field by field,
+// against the revision current at base (none for writes without a base).
+// It returns nil when the write's payload version differs from the entry's.
+func mergeEntryFields(ctx context.Context, tx *sqlx.Tx, current *models.Entry, req *models.EntryRequest, base SyncBase) (json.RawMessage, error) {
+	server, version, err := entrydata.Upgrade(current.EntryType, current.DataVersion, current.Data)
+	if err != nil {
+		return nil, err
+	}
+	clientVersion := 1
+	if req.DataVersion != nil {
+		clientVersion = *req.DataVersion
+	}
+	if clientVersion != version {
+		return nil, nil
+	}
+
+	var baseData json.RawMessage
+	if !base.IsZero() {
+		// Revisions recorded before sync versions have none and never match a version base
+		err = tx.GetContext(ctx, &baseData, `
+			SELECT data FROM clingy_entry_revisions
+			WHERE entry_id = $1 AND deleted_at IS NULL
+			  AND CASE WHEN $3::bigint > 0 THEN sync_version <= $3 ELSE recorded_at <= $2 END
+			ORDER BY id DESC
+			LIMIT 1
+		`, current.ID, base.At, base.Version)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if baseData != nil {
+			// Revisions don't record their payload version; assume the entry's
+			if baseData, _, err = entrydata.Upgrade(current.EntryType, current.DataVersion, baseData); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return vclock.MergeFields(current.EntryType, baseData, server, req.Data)
+}
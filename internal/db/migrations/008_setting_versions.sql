@@ -0,0 +1,4 @@
+-- Per-setting version for delta-encoded settings sync
+-- Run this migration on the mvchat database
+
+ALTER TABLE clingy_settings ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,8 @@
+-- Scheduled (future-dated) entries such as planned appointments and upcoming tests
+-- Run this migration on the mvchat database
+
+ALTER TABLE clingy_entries ADD COLUMN IF NOT EXISTS scheduled_for TIMESTAMPTZ;  -- NULL for regular entries
+ALTER TABLE clingy_entries ADD COLUMN IF NOT EXISTS status VARCHAR(20);         -- 'planned', 'completed', 'missed'
+
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_scheduled ON clingy_entries(pregnancy_id, scheduled_for)
+    WHERE scheduled_for IS NOT NULL AND deleted_at IS NULL;
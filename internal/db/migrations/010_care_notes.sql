@@ -0,0 +1,49 @@
+-- Care team providers, private care notes, and in-app notifications
+-- Run this migration on the mvchat database
+
+-- Allow 'provider' invite codes
+ALTER TABLE clingy_invite_codes DROP CONSTRAINT IF EXISTS valid_invite_role;
+ALTER TABLE clingy_invite_codes ADD CONSTRAINT valid_invite_role CHECK (role IN ('father', 'support', 'provider'));
+
+-- Linked care providers (midwife, OB, doula). Providers only see care notes,
+-- never entries or settings.
+CREATE TABLE IF NOT EXISTS clingy_care_providers (
+    id BIGSERIAL PRIMARY KEY,
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,                     -- UUID format
+    display_name VARCHAR(100),
+    joined_at TIMESTAMPTZ DEFAULT NOW(),
+    invited_via_code_id BIGINT REFERENCES clingy_invite_codes(id),
+    removed_at TIMESTAMPTZ,                    -- Soft delete
+
+    UNIQUE(pregnancy_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_care_providers_user ON clingy_care_providers(user_id);
+
+-- Notes between the owner and linked providers (hidden from partner/supporters)
+CREATE TABLE IF NOT EXISTS clingy_care_notes (
+    id BIGSERIAL PRIMARY KEY,
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    author_id TEXT NOT NULL,                   -- UUID format
+    author_role VARCHAR(20) NOT NULL,          -- 'owner', 'coowner', 'provider'
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ                     -- Soft delete
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_care_notes_pregnancy ON clingy_care_notes(pregnancy_id, created_at);
+
+-- In-app notifications
+CREATE TABLE IF NOT EXISTS clingy_notifications (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,                     -- Recipient, UUID format
+    pregnancy_id BIGINT REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    kind VARCHAR(50) NOT NULL,                 -- 'care_note', ...
+    payload JSONB,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    read_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_notifications_user ON clingy_notifications(user_id, created_at DESC);
@@ -0,0 +1,39 @@
+-- Per-token access fingerprints and suspicious access events
+-- Run this migration on the mvchat database
+
+-- One row per token/device/country combination seen for a user
+CREATE TABLE IF NOT EXISTS clingy_access_fingerprints (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,                     -- UUID format
+    token_hash VARCHAR(64) NOT NULL,           -- SHA-256 of the bearer token
+    device_hash VARCHAR(64) NOT NULL,          -- SHA-256 of user agent + X-Device-ID
+    user_agent TEXT,
+    country VARCHAR(2) NOT NULL DEFAULT '',    -- ISO country from the edge proxy, '' if unknown
+    first_seen_at TIMESTAMPTZ DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ DEFAULT NOW(),
+
+    UNIQUE(user_id, token_hash, device_hash, country)
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_access_fingerprints_user ON clingy_access_fingerprints(user_id);
+
+-- Access to a pregnancy from a device or country not seen before for that user
+CREATE TABLE IF NOT EXISTS clingy_security_events (
+    id BIGSERIAL PRIMARY KEY,
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,                     -- Who accessed, UUID format
+    kind VARCHAR(20) NOT NULL,                 -- 'new_device', 'new_country'
+    user_agent TEXT,
+    country VARCHAR(2),
+    action VARCHAR(20) NOT NULL DEFAULT 'notified', -- 'notified', 'unpaired'
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_security_events_pregnancy ON clingy_security_events(pregnancy_id, created_at DESC);
+
+-- Owner security preferences
+CREATE TABLE IF NOT EXISTS clingy_security_settings (
+    pregnancy_id BIGINT PRIMARY KEY REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    require_repair BOOLEAN NOT NULL DEFAULT FALSE, -- Unpair partners/supporters seen on a new device
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
@@ -0,0 +1,5 @@
+-- Owner-controlled snooze of partner/supporter visibility
+-- Run this migration on the mvchat database
+
+ALTER TABLE clingy_pregnancies ADD COLUMN IF NOT EXISTS sharing_snoozed_at TIMESTAMPTZ;
+ALTER TABLE clingy_pregnancies ADD COLUMN IF NOT EXISTS sharing_snoozed_until TIMESTAMPTZ;
@@ -0,0 +1,35 @@
+-- Append-only entry revision history for timeline exports
+-- Run this migration on the mvchat database
+
+-- Every insert/update of an entry is copied here by trigger; rows are never updated or deleted
+CREATE TABLE IF NOT EXISTS clingy_entry_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    entry_id BIGINT NOT NULL,                  -- clingy_entries.id (no FK, history outlives the entry)
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    client_id VARCHAR(50) NOT NULL,
+    entry_type VARCHAR(50) NOT NULL,
+    data JSONB NOT NULL,
+    deleted_at TIMESTAMPTZ,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_entry_revisions_pregnancy ON clingy_entry_revisions(pregnancy_id, id);
+
+CREATE OR REPLACE FUNCTION clingy_record_entry_revision() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO clingy_entry_revisions (entry_id, pregnancy_id, client_id, entry_type, data, deleted_at)
+    VALUES (NEW.id, NEW.pregnancy_id, NEW.client_id, NEW.entry_type, NEW.data, NEW.deleted_at);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS clingy_entries_revision ON clingy_entries;
+CREATE TRIGGER clingy_entries_revision
+    AFTER INSERT OR UPDATE ON clingy_entries
+    FOR EACH ROW EXECUTE FUNCTION clingy_record_entry_revision();
+
+-- Seed history with the current state of existing entries
+INSERT INTO clingy_entry_revisions (entry_id, pregnancy_id, client_id, entry_type, data, deleted_at, recorded_at)
+SELECT e.id, e.pregnancy_id, e.client_id, e.entry_type, e.data, e.deleted_at, e.updated_at
+FROM clingy_entries e
+WHERE NOT EXISTS (SELECT 1 FROM clingy_entry_revisions r WHERE r.entry_id = e.id);
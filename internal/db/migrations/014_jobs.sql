@@ -0,0 +1,20 @@
+-- Background jobs (memory book generation) with progress for polling
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,                     -- Requester, UUID format
+    kind VARCHAR(30) NOT NULL,                 -- 'memory_book'
+    status VARCHAR(20) NOT NULL DEFAULT 'queued', -- 'queued', 'running', 'completed', 'failed'
+    progress INTEGER NOT NULL DEFAULT 0,       -- 0-100
+    error TEXT,
+    result JSONB,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+
+    CONSTRAINT valid_job_status CHECK (status IN ('queued', 'running', 'completed', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_jobs_user ON clingy_jobs(user_id, created_at DESC);
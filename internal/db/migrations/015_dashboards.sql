@@ -0,0 +1,15 @@
+-- Saved analytics dashboards shared read-only with partners
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_dashboards (
+    id BIGSERIAL PRIMARY KEY,
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    created_by TEXT NOT NULL,                  -- UUID format
+    name VARCHAR(100) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,       -- Order in the dashboard list
+    widgets JSONB NOT NULL DEFAULT '[]',       -- Widget definitions with grid layout
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_dashboards_pregnancy ON clingy_dashboards(pregnancy_id, position);
@@ -0,0 +1,15 @@
+-- Usage counters for deprecated endpoints
+-- Run this migration on the mvchat database
+
+-- One row per deprecated route, app version and user
+CREATE TABLE IF NOT EXISTS clingy_deprecated_usage (
+    route VARCHAR(100) NOT NULL,               -- 'GET /api/pregnancy'
+    app_version VARCHAR(100) NOT NULL DEFAULT '', -- X-App-Version header, '' if not sent
+    user_id TEXT NOT NULL,                     -- UUID format
+    user_agent TEXT,
+    hits BIGINT NOT NULL DEFAULT 1,
+    first_seen_at TIMESTAMPTZ DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ DEFAULT NOW(),
+
+    PRIMARY KEY (route, app_version, user_id)
+);
@@ -0,0 +1,10 @@
+-- Moderation state for shared images
+-- Run this migration on the mvchat database
+
+-- NULL for files that are not shared or were uploaded without a moderator configured
+ALTER TABLE clingy_files ADD COLUMN IF NOT EXISTS moderation_status VARCHAR(20);  -- 'pending', 'approved', 'blocked'
+ALTER TABLE clingy_files ADD COLUMN IF NOT EXISTS moderation_reason TEXT;
+ALTER TABLE clingy_files ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_clingy_files_moderation ON clingy_files(pregnancy_id, moderation_status)
+    WHERE moderation_status IS NOT NULL;
@@ -0,0 +1,13 @@
+-- Link profile photos to uploaded files so they can be served through signed URLs
+-- Run this migration on the mvchat database
+
+ALTER TABLE clingy_pregnancies ADD COLUMN IF NOT EXISTS profile_photo_file_id BIGINT
+    REFERENCES clingy_files(id) ON DELETE SET NULL;
+
+-- Existing photos stored as the upload URL ('/files/<storage_path>')
+UPDATE clingy_pregnancies p SET profile_photo_file_id = f.id
+FROM clingy_files f
+WHERE p.profile_photo_file_id IS NULL
+  AND f.pregnancy_id = p.id
+  AND f.deleted_at IS NULL
+  AND p.profile_photo = '/files/' || f.storage_path;
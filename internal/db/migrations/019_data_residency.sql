@@ -0,0 +1,8 @@
+-- Data residency region per pregnancy and per stored file
+-- Run this migration on the mvchat database
+
+-- '' is the default region (UPLOAD_PATH); other codes map to STORAGE_REGIONS roots
+ALTER TABLE clingy_pregnancies ADD COLUMN IF NOT EXISTS region VARCHAR(16) NOT NULL DEFAULT '';
+
+-- Region whose storage root holds the file, fixed at upload time
+ALTER TABLE clingy_files ADD COLUMN IF NOT EXISTS region VARCHAR(16) NOT NULL DEFAULT '';
@@ -0,0 +1,17 @@
+-- Versioned entry payloads
+-- Run this migration on the mvchat database
+
+-- Payload shape version written by the client; existing entries are version 1
+ALTER TABLE clingy_entries ADD COLUMN IF NOT EXISTS data_version INT NOT NULL DEFAULT 1;
+
+-- Entry data versions clients sent that this server did not know
+CREATE TABLE IF NOT EXISTS clingy_unknown_data_versions (
+    entry_type VARCHAR(50) NOT NULL,
+    data_version INT NOT NULL,
+    app_version VARCHAR(100) NOT NULL DEFAULT '', -- X-App-Version header, '' if not sent
+    hits BIGINT NOT NULL DEFAULT 1,
+    first_seen_at TIMESTAMPTZ DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ DEFAULT NOW(),
+
+    PRIMARY KEY (entry_type, data_version, app_version)
+);
@@ -0,0 +1,17 @@
+-- Personal access tokens for user automation (spreadsheets, scripts)
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_personal_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,                     -- UUID format
+    name VARCHAR(100) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,    -- SHA-256 of the token; the token itself is never stored
+    prefix VARCHAR(16) NOT NULL,               -- First characters, to tell tokens apart
+    scope VARCHAR(10) NOT NULL DEFAULT 'read', -- 'read' or 'write'
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,                    -- NULL = never expires
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_personal_tokens_user ON clingy_personal_tokens(user_id);
@@ -0,0 +1,7 @@
+-- Demo pregnancies for sales demos
+-- Run this migration on the mvchat database
+
+-- Demo pregnancies hold generated data and are left out of aggregate stats and digests
+ALTER TABLE clingy_pregnancies ADD COLUMN IF NOT EXISTS demo BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_clingy_pregnancies_demo ON clingy_pregnancies(demo) WHERE demo;
@@ -0,0 +1,5 @@
+-- Historical backfill of entries
+-- Run this migration on the mvchat database
+
+-- Entries imported after the fact via /api/entries/backfill; created_at is the client's original time
+ALTER TABLE clingy_entries ADD COLUMN IF NOT EXISTS backfilled BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,10 @@
+-- Cold storage tiering for archived pregnancies' files
+-- Run this migration on the mvchat database
+
+-- 'hot' (primary storage) or 'cold' (cheaper, slower storage class)
+ALTER TABLE clingy_files ADD COLUMN IF NOT EXISTS storage_tier VARCHAR(10) NOT NULL DEFAULT 'hot';
+
+-- Last move between tiers; restored files stay hot for a while before tiering again
+ALTER TABLE clingy_files ADD COLUMN IF NOT EXISTS tiered_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_clingy_files_tier ON clingy_files(pregnancy_id, storage_tier);
@@ -0,0 +1,15 @@
+-- Pre-generated sync snapshots for offline bootstrap
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_sync_snapshots (
+    pregnancy_id BIGINT PRIMARY KEY REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    storage_path TEXT NOT NULL,                -- Gzipped JSON, relative to the pregnancy's region root
+    etag VARCHAR(64) NOT NULL,                 -- SHA-256 of the gzipped file
+    size_bytes BIGINT NOT NULL,
+    entries INT NOT NULL,
+    source_time TIMESTAMPTZ NOT NULL,          -- Data as of this time; clients sync incrementally from here
+    generated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Counting changes since a snapshot
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_pregnancy_updated ON clingy_entries(pregnancy_id, updated_at);
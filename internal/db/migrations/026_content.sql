@@ -0,0 +1,19 @@
+-- Weekly facts and baby sizes, versioned with draft/published states
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_content (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,                 -- weekly_fact, baby_size
+    week INT NOT NULL,
+    version INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft', -- draft, published, retired
+    data JSONB NOT NULL,                       -- One element of the public JSON array
+    created_by VARCHAR(255),                   -- NULL for rows seeded from data/*.json
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    published_at TIMESTAMPTZ,
+    UNIQUE (kind, week, version)
+);
+
+-- At most one published version per week
+CREATE UNIQUE INDEX IF NOT EXISTS idx_clingy_content_published ON clingy_content(kind, week) WHERE status = 'published';
@@ -0,0 +1,26 @@
+-- A/B variants of weekly content with per-user exposure counts
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_content_variants (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,                 -- weekly_fact, baby_size
+    week INT NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    weight INT NOT NULL DEFAULT 1,             -- Share of users; 0 pauses the variant
+    data JSONB NOT NULL,                       -- Fields overriding the published version; {} is the control
+    starts_at TIMESTAMPTZ,                     -- NULL: no start/end limit
+    ends_at TIMESTAMPTZ,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (kind, week, name)
+);
+
+CREATE TABLE IF NOT EXISTS clingy_content_exposures (
+    variant_id BIGINT NOT NULL REFERENCES clingy_content_variants(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    views INT NOT NULL DEFAULT 1,
+    first_seen_at TIMESTAMPTZ DEFAULT NOW(),
+    last_seen_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (variant_id, user_id)
+);
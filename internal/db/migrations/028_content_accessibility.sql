@@ -0,0 +1,6 @@
+-- Accessibility metadata and simplified-language text for weekly content
+-- Run this migration on the mvchat database
+
+ALTER TABLE clingy_content ADD COLUMN IF NOT EXISTS accessibility JSONB NOT NULL DEFAULT '{}';            -- Reading level, alt text, narration
+ALTER TABLE clingy_content ADD COLUMN IF NOT EXISTS simplified JSONB NOT NULL DEFAULT '{}';               -- Fields overriding data in plain language
+ALTER TABLE clingy_content ADD COLUMN IF NOT EXISTS simplified_accessibility JSONB NOT NULL DEFAULT '{}';
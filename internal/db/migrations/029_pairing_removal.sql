@@ -0,0 +1,7 @@
+-- Pending pairing removal with a cooling-off period
+-- Run this migration on the mvchat database
+
+ALTER TABLE clingy_pregnancies ADD COLUMN IF NOT EXISTS partner_removal_requested_at TIMESTAMPTZ; -- Set while partner_status = 'removing'
+ALTER TABLE clingy_pregnancies ADD COLUMN IF NOT EXISTS partner_removal_requested_by VARCHAR(255); -- Only this user may undo
+
+CREATE INDEX IF NOT EXISTS idx_clingy_pregnancies_partner_removal ON clingy_pregnancies(partner_removal_requested_at) WHERE partner_status = 'removing';
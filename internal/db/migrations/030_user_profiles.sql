@@ -0,0 +1,10 @@
+-- Cached mvchat2 profiles of partners, supporters and providers
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_user_profiles (
+    user_id VARCHAR(255) PRIMARY KEY,          -- mvchat2 user ID (UUID format)
+    display_name TEXT,
+    avatar_url TEXT,
+    profile_updated_at TIMESTAMPTZ NOT NULL,   -- When mvchat2 changed the profile; older events are ignored
+    synced_at TIMESTAMPTZ DEFAULT NOW()
+);
@@ -0,0 +1,34 @@
+-- Coowner lifecycle history and audit of actions taken as coowner
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_coowner_history (
+    id BIGSERIAL PRIMARY KEY,
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    coowner_id TEXT NOT NULL,                  -- UUID format
+    coowner_name VARCHAR(255),
+    action VARCHAR(20) NOT NULL,               -- 'linked', 'replaced', 'removed', 'left'
+    actor_id TEXT NOT NULL,                    -- Who made the change
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_coowner_history_pregnancy ON clingy_coowner_history(pregnancy_id, created_at DESC);
+
+-- Coowners linked before this migration get a 'linked' entry dated from their last pregnancy update
+INSERT INTO clingy_coowner_history (pregnancy_id, coowner_id, coowner_name, action, actor_id, created_at)
+SELECT p.id, p.coowner_id, p.coowner_name, 'linked', p.coowner_id, p.updated_at
+FROM clingy_pregnancies p
+WHERE p.coowner_id IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM clingy_coowner_history h WHERE h.pregnancy_id = p.id);
+
+-- Every write request a coowner makes against the pregnancy they coown
+CREATE TABLE IF NOT EXISTS clingy_coowner_audit (
+    id BIGSERIAL PRIMARY KEY,
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    coowner_id TEXT NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    status INT NOT NULL,                       -- HTTP response status
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_coowner_audit_pregnancy ON clingy_coowner_audit(pregnancy_id, created_at DESC);
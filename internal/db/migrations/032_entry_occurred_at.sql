@@ -0,0 +1,7 @@
+-- When an entry happened on the client, independent of when it reached the server
+-- Run this migration on the mvchat database
+
+ALTER TABLE clingy_entries ADD COLUMN IF NOT EXISTS occurred_at TIMESTAMPTZ; -- NULL: unknown, created_at is used
+
+-- Matches the COALESCE(occurred_at, created_at) filters and buckets
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_occurred ON clingy_entries(pregnancy_id, (COALESCE(occurred_at, created_at)));
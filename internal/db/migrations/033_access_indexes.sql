@@ -0,0 +1,7 @@
+-- Partial indexes for the single-query access resolution
+-- Run this migration on the mvchat database
+
+-- owner_id and coowner_id are already indexed (001); these cover the partner
+-- and supporter branches without scanning unapproved or removed rows
+CREATE INDEX IF NOT EXISTS idx_clingy_pregnancies_partner_approved ON clingy_pregnancies(partner_id) WHERE partner_status = 'approved';
+CREATE INDEX IF NOT EXISTS idx_clingy_supporters_user_active ON clingy_supporters(user_id, pregnancy_id) WHERE removed_at IS NULL;
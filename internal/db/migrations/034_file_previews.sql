@@ -0,0 +1,16 @@
+-- Server-rendered previews of uploaded PDFs and videos
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_file_previews (
+    file_id BIGINT PRIMARY KEY REFERENCES clingy_files(id) ON DELETE CASCADE,
+    kind VARCHAR(10) NOT NULL,                 -- 'pdf', 'video'
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'running', 'ready', 'failed'
+    preview_dir TEXT,                          -- Relative to the file's region root; set when ready
+    has_stream BOOLEAN NOT NULL DEFAULT FALSE, -- Videos have an HLS playlist
+    error TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_file_previews_pending ON clingy_file_previews(created_at) WHERE status = 'pending';
@@ -0,0 +1,42 @@
+-- Per-entry vector clocks for sync v2
+-- Run this migration on the mvchat database
+
+-- Device ID to edit counter. NULL for entries never written through sync v2,
+-- which count as a single v1 write ({"v1": 1}).
+ALTER TABLE clingy_entries ADD COLUMN IF NOT EXISTS clock JSONB;
+ALTER TABLE clingy_entry_revisions ADD COLUMN IF NOT EXISTS clock JSONB;
+
+-- Revisions keep the clock, so a v2 merge can find the version both sides last saw
+CREATE OR REPLACE FUNCTION clingy_record_entry_revision() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO clingy_entry_revisions (entry_id, pregnancy_id, client_id, entry_type, data, deleted_at, clock)
+    VALUES (NEW.id, NEW.pregnancy_id, NEW.client_id, NEW.entry_type, NEW.data, NEW.deleted_at, NEW.clock);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE INDEX IF NOT EXISTS idx_clingy_entry_revisions_entry ON clingy_entry_revisions(entry_id, id);
+
+-- v1 bridge: any change made without a new clock (every v1 write path) counts
+-- as an edit by the "v1" device, so v2 clients see it as a newer version
+CREATE OR REPLACE FUNCTION clingy_bump_v1_clock() RETURNS TRIGGER AS $$
+DECLARE
+    c JSONB;
+BEGIN
+    IF NEW.clock IS NOT DISTINCT FROM OLD.clock AND (
+        NEW.data IS DISTINCT FROM OLD.data OR
+        NEW.deleted_at IS DISTINCT FROM OLD.deleted_at OR
+        NEW.status IS DISTINCT FROM OLD.status OR
+        NEW.scheduled_for IS DISTINCT FROM OLD.scheduled_for
+    ) THEN
+        c := COALESCE(OLD.clock, '{"v1": 1}');
+        NEW.clock := jsonb_set(c, '{v1}', to_jsonb(COALESCE((c->>'v1')::BIGINT, 0) + 1));
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS clingy_entries_v1_clock ON clingy_entries;
+CREATE TRIGGER clingy_entries_v1_clock
+    BEFORE UPDATE ON clingy_entries
+    FOR EACH ROW EXECUTE FUNCTION clingy_bump_v1_clock();
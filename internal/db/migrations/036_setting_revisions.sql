@@ -0,0 +1,54 @@
+-- Setting history with soft delete and revert
+-- Run this migration on the mvchat database
+
+ALTER TABLE clingy_settings ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Every change of a setting is copied here by trigger; only the last 50 per setting are kept
+CREATE TABLE IF NOT EXISTS clingy_setting_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    setting_type VARCHAR(50) NOT NULL,
+    version BIGINT NOT NULL,                   -- clingy_settings.version after the change
+    data JSONB NOT NULL,
+    deleted_at TIMESTAMPTZ,                    -- Set when the change deleted the setting
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_setting_revisions_setting ON clingy_setting_revisions(pregnancy_id, setting_type, id DESC);
+
+CREATE OR REPLACE FUNCTION clingy_record_setting_revision() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.data IS NOT DISTINCT FROM OLD.data AND NEW.deleted_at IS NOT DISTINCT FROM OLD.deleted_at THEN
+        RETURN NEW;
+    END IF;
+
+    INSERT INTO clingy_setting_revisions (pregnancy_id, setting_type, version, data, deleted_at)
+    VALUES (NEW.pregnancy_id, NEW.setting_type, NEW.version, NEW.data, NEW.deleted_at);
+
+    DELETE FROM clingy_setting_revisions
+    WHERE pregnancy_id = NEW.pregnancy_id AND setting_type = NEW.setting_type
+      AND id < (
+          SELECT MIN(id) FROM (
+              SELECT id FROM clingy_setting_revisions
+              WHERE pregnancy_id = NEW.pregnancy_id AND setting_type = NEW.setting_type
+              ORDER BY id DESC
+              LIMIT 50
+          ) kept
+      );
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS clingy_settings_revision ON clingy_settings;
+CREATE TRIGGER clingy_settings_revision
+    AFTER INSERT OR UPDATE ON clingy_settings
+    FOR EACH ROW EXECUTE FUNCTION clingy_record_setting_revision();
+
+-- Seed history with the current settings
+INSERT INTO clingy_setting_revisions (pregnancy_id, setting_type, version, data, deleted_at, recorded_at)
+SELECT s.pregnancy_id, s.setting_type, s.version, s.data, s.deleted_at, COALESCE(s.updated_at, NOW())
+FROM clingy_settings s
+WHERE NOT EXISTS (
+    SELECT 1 FROM clingy_setting_revisions r
+    WHERE r.pregnancy_id = s.pregnancy_id AND r.setting_type = s.setting_type
+);
@@ -0,0 +1,30 @@
+-- Expression indexes on hot payload fields of known entry types
+-- Run this migration on the mvchat database
+
+-- Casts payload text to a number, NULL when it isn't one, so an odd payload
+-- can't fail a write through the indexes below
+CREATE OR REPLACE FUNCTION clingy_try_number(v TEXT) RETURNS DOUBLE PRECISION AS $$
+    SELECT CASE WHEN v ~ '^-?[0-9]+(\.[0-9]+)?$' THEN v::double precision END
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;
+
+-- Numeric fields; queries must use the same expression and entry type literal (see db/entryfilters.go)
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_weight_weight ON clingy_entries(pregnancy_id, clingy_try_number(data->>'weight'))
+    WHERE entry_type = 'weight';
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_blood_pressure_systolic ON clingy_entries(pregnancy_id, clingy_try_number(data->>'systolic'))
+    WHERE entry_type = 'blood_pressure';
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_blood_pressure_diastolic ON clingy_entries(pregnancy_id, clingy_try_number(data->>'diastolic'))
+    WHERE entry_type = 'blood_pressure';
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_glucose_glucose ON clingy_entries(pregnancy_id, clingy_try_number(data->>'glucose'))
+    WHERE entry_type = 'glucose';
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_symptom_severity ON clingy_entries(pregnancy_id, clingy_try_number(data->>'severity'))
+    WHERE entry_type = 'symptom';
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_water_amount ON clingy_entries(pregnancy_id, clingy_try_number(data->>'amount'))
+    WHERE entry_type = 'water';
+
+-- Text fields, matched case-insensitively
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_symptom_symptom ON clingy_entries(pregnancy_id, LOWER(data->>'symptom'))
+    WHERE entry_type = 'symptom';
+
+-- Tag arrays, matched by containment
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_tags ON clingy_entries USING GIN ((data->'tags') jsonb_path_ops)
+    WHERE entry_type IN ('symptom', 'journal', 'photo', 'milestone');
@@ -0,0 +1,18 @@
+-- Weekly pregnancy progress messages posted into an mvchat2 conversation
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_progress_posts (
+    pregnancy_id BIGINT PRIMARY KEY REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    conversation_id VARCHAR(100) NOT NULL,     -- mvchat2 conversation to post into
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    template TEXT NOT NULL DEFAULT '',         -- '' = built-in message
+    week_templates JSONB NOT NULL DEFAULT '{}', -- {"<week>": template} overriding template for that week
+    last_week_posted INT,                      -- Gestational week of the last post; no post until the week after
+    last_posted_at TIMESTAMPTZ,
+    last_error TEXT,                           -- Why the last post failed; cleared on success
+    updated_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_progress_posts_enabled ON clingy_progress_posts(pregnancy_id) WHERE enabled;
@@ -0,0 +1,4 @@
+-- Owner-written welcome message shown when an invite code is previewed or redeemed
+-- Run this migration on the mvchat database
+
+ALTER TABLE clingy_invite_codes ADD COLUMN IF NOT EXISTS message TEXT; -- Sanitized, at most 500 characters
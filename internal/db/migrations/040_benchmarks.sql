@@ -0,0 +1,14 @@
+-- Cross-user benchmarks released with differential privacy by the benchmark job
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_benchmarks (
+    metric VARCHAR(50) NOT NULL,               -- e.g. 'weight', 'systolic'
+    week INT NOT NULL,                         -- Gestational week
+    suppressed BOOLEAN NOT NULL,               -- Too few users; users and mean are NULL
+    users INT,                                 -- Noisy count of contributing pregnancies
+    mean DOUBLE PRECISION,                     -- Noisy mean of per-pregnancy means
+    epsilon DOUBLE PRECISION NOT NULL,         -- Privacy parameters the bucket was released with
+    min_cohort INT NOT NULL,
+    generated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (metric, week)
+);
@@ -0,0 +1,8 @@
+-- Supporter engagement from the access log, with a supporter opt-out
+-- Run this migration on the mvchat database
+
+-- Visits per fingerprint: the first request, then each one after 30 minutes idle
+ALTER TABLE clingy_access_fingerprints ADD COLUMN IF NOT EXISTS visits INTEGER NOT NULL DEFAULT 1;
+
+-- Supporters can hide their last-viewed time and visit count from the owner
+ALTER TABLE clingy_supporters ADD COLUMN IF NOT EXISTS share_activity BOOLEAN NOT NULL DEFAULT TRUE;
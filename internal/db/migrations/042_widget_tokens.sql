@@ -0,0 +1,17 @@
+-- Widget tokens: long-lived, read-only access to a pregnancy's supporter view
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_widget_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    created_by TEXT NOT NULL,                  -- Owner who issued it, UUID format
+    name VARCHAR(100) NOT NULL,                -- e.g. "Grandma's kitchen tablet"
+    token_hash VARCHAR(64) NOT NULL UNIQUE,    -- SHA-256 of the token; the token itself is never stored
+    prefix VARCHAR(16) NOT NULL,               -- First characters, to tell tokens apart
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,                    -- NULL = never expires
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_widget_tokens_pregnancy ON clingy_widget_tokens(pregnancy_id);
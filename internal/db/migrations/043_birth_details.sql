@@ -0,0 +1,18 @@
+-- Birth details recorded after outcome=birth, with a scheduled auto-archive
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_birth_details (
+    pregnancy_id BIGINT PRIMARY KEY REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    born_at TIMESTAMPTZ NOT NULL,
+    weight_grams INTEGER,
+    length_cm NUMERIC(4,1),
+    delivery_type VARCHAR(20),                 -- 'vaginal', 'assisted', 'cesarean', 'other'
+    archive_at TIMESTAMPTZ,                    -- NULL = never auto-archive
+    auto_archived_at TIMESTAMPTZ,              -- Set once the follow-up archived the pregnancy
+    updated_by TEXT NOT NULL,                  -- UUID format
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_birth_details_archive ON clingy_birth_details(archive_at)
+    WHERE archive_at IS NOT NULL AND auto_archived_at IS NULL;
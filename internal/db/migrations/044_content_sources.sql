@@ -0,0 +1,9 @@
+-- Source citations and medical review dates for weekly content
+-- Run this migration on the mvchat database
+
+ALTER TABLE clingy_content ADD COLUMN IF NOT EXISTS sources JSONB NOT NULL DEFAULT '[]';  -- [{title, publisher, url}]
+ALTER TABLE clingy_content ADD COLUMN IF NOT EXISTS reviewed_by TEXT;                     -- Reviewer name and credentials
+ALTER TABLE clingy_content ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMPTZ;
+
+-- Published versions are checked for stale reviews
+CREATE INDEX IF NOT EXISTS idx_clingy_content_review ON clingy_content(kind, reviewed_at) WHERE status = 'published';
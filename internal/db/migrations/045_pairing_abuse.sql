@@ -0,0 +1,8 @@
+-- Spam screening of pairing requests
+-- Run this migration on the mvchat database
+
+-- Why a blocked attempt was refused ('pairing_blocked' events)
+ALTER TABLE clingy_security_events ADD COLUMN IF NOT EXISTS reason VARCHAR(30);
+
+-- Per-requester daily counts
+CREATE INDEX IF NOT EXISTS idx_clingy_pairing_requester ON clingy_pairing_requests(requester_id, created_at DESC);
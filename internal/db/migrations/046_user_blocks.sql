@@ -0,0 +1,13 @@
+-- Users blocked from sending pairing requests and redeeming invite codes
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_user_blocks (
+    id BIGSERIAL PRIMARY KEY,
+    blocker_id TEXT NOT NULL,                  -- UUID format; the user who blocked
+    blocked_id TEXT NOT NULL,                  -- UUID format
+    reason VARCHAR(200),                       -- Private note for the blocker
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (blocker_id, blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_user_blocks_blocked ON clingy_user_blocks(blocked_id);
@@ -0,0 +1,61 @@
+-- Monthly partitions and an entity column for the coowner audit log
+-- Run this migration on the mvchat database
+
+-- The audit log becomes partitioned by month so old months can be dropped
+-- whole. Existing rows are copied over; the server creates partitions ahead
+-- of time and drops those past AUDIT_RETENTION_MONTHS.
+DO $$
+DECLARE
+    month DATE;
+BEGIN
+    IF (SELECT relkind FROM pg_class WHERE relname = 'clingy_coowner_audit') = 'p' THEN
+        RETURN;
+    END IF;
+
+    ALTER TABLE clingy_coowner_audit RENAME TO clingy_coowner_audit_unpartitioned;
+    ALTER INDEX idx_clingy_coowner_audit_pregnancy RENAME TO idx_clingy_coowner_audit_unpartitioned;
+
+    CREATE TABLE clingy_coowner_audit (
+        id BIGINT NOT NULL DEFAULT nextval('clingy_coowner_audit_id_seq'),
+        pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+        coowner_id TEXT NOT NULL,
+        method VARCHAR(10) NOT NULL,
+        path TEXT NOT NULL,
+        entity VARCHAR(50) NOT NULL DEFAULT '',    -- Resource written, e.g. 'entries', 'settings'
+        status INT NOT NULL,                       -- HTTP response status
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        PRIMARY KEY (id, created_at)
+    ) PARTITION BY RANGE (created_at);
+    ALTER SEQUENCE clingy_coowner_audit_id_seq OWNED BY clingy_coowner_audit.id;
+
+    -- One partition per month (UTC) from the oldest row to two months ahead
+    FOR month IN
+        SELECT generate_series(
+            date_trunc('month', COALESCE((SELECT MIN(created_at) FROM clingy_coowner_audit_unpartitioned), NOW()) AT TIME ZONE 'UTC'),
+            date_trunc('month', NOW() AT TIME ZONE 'UTC') + INTERVAL '2 months',
+            INTERVAL '1 month')::date
+    LOOP
+        EXECUTE format(
+            'CREATE TABLE IF NOT EXISTS %I PARTITION OF clingy_coowner_audit FOR VALUES FROM (%L) TO (%L)',
+            'clingy_coowner_audit_p' || to_char(month, 'YYYYMM'),
+            month::timestamp AT TIME ZONE 'UTC',
+            (month + INTERVAL '1 month') AT TIME ZONE 'UTC');
+    END LOOP;
+    -- Catches rows if the server hasn't created a month's partition in time
+    CREATE TABLE IF NOT EXISTS clingy_coowner_audit_default PARTITION OF clingy_coowner_audit DEFAULT;
+
+    -- Entity is /api/<entity>/..., or /api/pregnancies/{id}/<entity>/...
+    INSERT INTO clingy_coowner_audit (id, pregnancy_id, coowner_id, method, path, entity, status, created_at)
+    SELECT id, pregnancy_id, coowner_id, method, path,
+        LEFT(CASE
+            WHEN split_part(path, '/', 3) = 'pregnancies' AND split_part(path, '/', 5) <> '' THEN split_part(path, '/', 5)
+            ELSE split_part(path, '/', 3)
+        END, 50),
+        status, COALESCE(created_at, NOW())
+    FROM clingy_coowner_audit_unpartitioned;
+
+    DROP TABLE clingy_coowner_audit_unpartitioned;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_clingy_coowner_audit_pregnancy ON clingy_coowner_audit(pregnancy_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_clingy_coowner_audit_actor ON clingy_coowner_audit(pregnancy_id, coowner_id, created_at DESC);
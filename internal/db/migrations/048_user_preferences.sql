@@ -0,0 +1,10 @@
+-- Per-user locale and formatting overrides
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_user_preferences (
+    user_id TEXT PRIMARY KEY,                  -- UUID format
+    locale VARCHAR(35),                        -- BCP 47, e.g. en-GB; NULL uses Accept-Language
+    measurement_system VARCHAR(10),            -- metric, us or uk; NULL derives from the locale
+    first_day_of_week VARCHAR(10),             -- sunday, monday or saturday; NULL derives from the locale
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
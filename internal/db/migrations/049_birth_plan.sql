@@ -0,0 +1,28 @@
+-- Birth plan sections, co-edited by the owner and partner with section locks
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_birth_plan_sections (
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    section VARCHAR(40) NOT NULL,
+    content TEXT NOT NULL DEFAULT '',
+    version INT NOT NULL DEFAULT 0,            -- Bumped on every content edit; 0 is the empty section
+    updated_by TEXT,                           -- UUID format
+    updated_at TIMESTAMPTZ,                    -- Last content edit
+    locked_by TEXT,                            -- UUID format; the user editing the section
+    lock_expires_at TIMESTAMPTZ,
+    changed_at TIMESTAMPTZ DEFAULT NOW(),      -- Last edit or lock change, for the event feed
+    PRIMARY KEY (pregnancy_id, section)
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_birth_plan_sections_changed ON clingy_birth_plan_sections(pregnancy_id, changed_at);
+
+-- Past versions, the merge base for edits made against an older version
+CREATE TABLE IF NOT EXISTS clingy_birth_plan_revisions (
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    section VARCHAR(40) NOT NULL,
+    version INT NOT NULL,
+    content TEXT NOT NULL,
+    updated_by TEXT NOT NULL,                  -- UUID format
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (pregnancy_id, section, version)
+);
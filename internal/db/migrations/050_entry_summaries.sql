@@ -0,0 +1,20 @@
+-- Opt-in summaries and suggested tags for long journal posts
+-- Run this migration on the mvchat database
+
+-- Owners who agreed to send their journal posts to the summarization service
+CREATE TABLE IF NOT EXISTS clingy_summary_consents (
+    pregnancy_id BIGINT PRIMARY KEY REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    consented_by TEXT NOT NULL,                -- UUID format; the owner
+    consented_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS clingy_entry_summaries (
+    entry_id BIGINT PRIMARY KEY REFERENCES clingy_entries(id) ON DELETE CASCADE,
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    summary TEXT NOT NULL,
+    tags JSONB NOT NULL DEFAULT '[]',
+    source_updated_at TIMESTAMPTZ NOT NULL,    -- updated_at of the entry version summarized
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_entry_summaries_pregnancy ON clingy_entry_summaries(pregnancy_id);
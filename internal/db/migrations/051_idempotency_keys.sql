@@ -0,0 +1,16 @@
+-- Idempotency-Key records, so retried sync and entry writes replay the first response
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_idempotency_keys (
+    user_id TEXT NOT NULL,                     -- UUID format
+    idempotency_key VARCHAR(255) NOT NULL,
+    route VARCHAR(100) NOT NULL,               -- e.g. "POST /api/sync"
+    fingerprint CHAR(64) NOT NULL,             -- SHA-256 of method, path and body
+    status INT,                                -- NULL while the first request runs
+    content_type VARCHAR(100),
+    response BYTEA,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_idempotency_keys_created ON clingy_idempotency_keys(created_at);
@@ -0,0 +1,5 @@
+-- Content hashes of uploaded files, so content URLs can be cached as immutable
+-- Run this migration on the mvchat database
+
+-- Hex SHA-256 of the file's bytes; null for files uploaded before this migration
+ALTER TABLE clingy_files ADD COLUMN IF NOT EXISTS content_hash CHAR(64);
@@ -0,0 +1,9 @@
+-- Hard deletion of old entry tombstones
+-- Run this migration on the mvchat database
+
+-- Entries deleted before this time may be gone for good; clients that last
+-- synced earlier must resync in full. NULL until a tombstone was compacted.
+ALTER TABLE clingy_pregnancies ADD COLUMN IF NOT EXISTS tombstones_compacted_before TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_tombstones ON clingy_entries(deleted_at)
+    WHERE deleted_at IS NOT NULL;
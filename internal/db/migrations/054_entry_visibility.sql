@@ -0,0 +1,14 @@
+-- Entry visibility: shared (everyone with access), partner (owner, coowner and
+-- partner) or private (owner and coowner only)
+-- Run this migration on the mvchat database
+
+ALTER TABLE clingy_entries ADD COLUMN IF NOT EXISTS visibility VARCHAR(10) NOT NULL DEFAULT 'shared';
+
+ALTER TABLE clingy_entries DROP CONSTRAINT IF EXISTS valid_entry_visibility;
+ALTER TABLE clingy_entries ADD CONSTRAINT valid_entry_visibility CHECK (visibility IN ('shared', 'partner', 'private'));
+
+-- When an existing entry's visibility last changed. Incremental syncs send
+-- entries hidden since then as tombstones to viewers who lost access, so their
+-- devices drop the copy. NULL for entries created hidden, which nobody else
+-- ever received.
+ALTER TABLE clingy_entries ADD COLUMN IF NOT EXISTS visibility_changed_at TIMESTAMPTZ;
@@ -0,0 +1,63 @@
+-- Monotonic per-pregnancy sync versions
+-- Run this migration on the mvchat database
+
+-- The pregnancy's counter is bumped by trigger on every entry and setting
+-- write. Writers hold the pregnancy row until they commit, so versions become
+-- visible in order and a client that synced up to N never misses a change <= N.
+ALTER TABLE clingy_pregnancies ADD COLUMN IF NOT EXISTS sync_version BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE clingy_entries ADD COLUMN IF NOT EXISTS sync_version BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE clingy_entry_revisions ADD COLUMN IF NOT EXISTS sync_version BIGINT; -- NULL for older revisions
+
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_sync_version ON clingy_entries(pregnancy_id, sync_version);
+
+-- Number existing entries in order of their last change, without writing
+-- revisions or bumping clocks: no entry changes
+ALTER TABLE clingy_entries DISABLE TRIGGER USER;
+UPDATE clingy_entries e SET sync_version = v.n
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY pregnancy_id ORDER BY updated_at, id) AS n
+    FROM clingy_entries
+) v
+WHERE e.id = v.id AND e.sync_version = 0;
+ALTER TABLE clingy_entries ENABLE TRIGGER USER;
+
+UPDATE clingy_pregnancies p SET sync_version = v.n
+FROM (SELECT pregnancy_id, MAX(sync_version) AS n FROM clingy_entries GROUP BY pregnancy_id) v
+WHERE p.id = v.pregnancy_id AND p.sync_version < v.n;
+
+CREATE OR REPLACE FUNCTION clingy_bump_entry_sync_version() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE clingy_pregnancies SET sync_version = sync_version + 1
+    WHERE id = NEW.pregnancy_id
+    RETURNING sync_version INTO NEW.sync_version;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS clingy_entries_sync_version ON clingy_entries;
+CREATE TRIGGER clingy_entries_sync_version
+    BEFORE INSERT OR UPDATE ON clingy_entries
+    FOR EACH ROW EXECUTE FUNCTION clingy_bump_entry_sync_version();
+
+CREATE OR REPLACE FUNCTION clingy_bump_setting_sync_version() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE clingy_pregnancies SET sync_version = sync_version + 1 WHERE id = NEW.pregnancy_id;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS clingy_settings_sync_version ON clingy_settings;
+CREATE TRIGGER clingy_settings_sync_version
+    AFTER INSERT OR UPDATE ON clingy_settings
+    FOR EACH ROW EXECUTE FUNCTION clingy_bump_setting_sync_version();
+
+-- Revisions keep the sync version, so a field merge can find the version a
+-- push based on lastSyncVersion started from
+CREATE OR REPLACE FUNCTION clingy_record_entry_revision() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO clingy_entry_revisions (entry_id, pregnancy_id, client_id, entry_type, data, deleted_at, clock, sync_version)
+    VALUES (NEW.id, NEW.pregnancy_id, NEW.client_id, NEW.entry_type, NEW.data, NEW.deleted_at, NEW.clock, NEW.sync_version);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,58 @@
+-- Entry triggers: webhooks (smart home, IFTTT-style) called when an entry of
+-- a type is created and its data matches a condition
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_entry_triggers (
+    id BIGSERIAL PRIMARY KEY,
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    created_by TEXT NOT NULL,                  -- UUID format; owner or coowner
+    name VARCHAR(100) NOT NULL,
+    entry_type VARCHAR(50) NOT NULL,
+    condition JSONB,                           -- {"field", "op", "value"}; NULL fires on every entry of the type
+    url TEXT NOT NULL,                         -- https only
+    secret TEXT NOT NULL,                      -- Signs deliveries (HMAC-SHA256)
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_entry_triggers_type ON clingy_entry_triggers(pregnancy_id, entry_type) WHERE enabled;
+
+-- One row per call: queued by the trigger below, then sent (or dropped when
+-- the condition doesn't match) by the delivery worker. Test fires have no entry.
+CREATE TABLE IF NOT EXISTS clingy_trigger_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    trigger_id BIGINT NOT NULL REFERENCES clingy_entry_triggers(id) ON DELETE CASCADE,
+    entry_id BIGINT REFERENCES clingy_entries(id) ON DELETE CASCADE,
+    test BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_code INTEGER,
+    error TEXT,
+    next_attempt_at TIMESTAMPTZ DEFAULT NOW(),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ,
+    CONSTRAINT valid_trigger_delivery_status CHECK (status IN ('pending', 'delivered', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_trigger_deliveries_trigger ON clingy_trigger_deliveries(trigger_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_clingy_trigger_deliveries_pending ON clingy_trigger_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- Queue a delivery for every enabled trigger of a new entry's type. Inserts
+-- only, so edits and upserts of existing entries never fire; backfilled past
+-- entries don't either.
+CREATE OR REPLACE FUNCTION clingy_queue_trigger_deliveries() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.deleted_at IS NULL AND NOT NEW.backfilled THEN
+        INSERT INTO clingy_trigger_deliveries (trigger_id, entry_id)
+        SELECT id, NEW.id FROM clingy_entry_triggers
+        WHERE pregnancy_id = NEW.pregnancy_id AND entry_type = NEW.entry_type AND enabled;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS clingy_entries_queue_triggers ON clingy_entries;
+CREATE TRIGGER clingy_entries_queue_triggers
+    AFTER INSERT ON clingy_entries
+    FOR EACH ROW EXECUTE FUNCTION clingy_queue_trigger_deliveries();
@@ -0,0 +1,6 @@
+-- Keyset indexes for paged GET /api/entries, so a page late in pregnancy
+-- (thousands of kick and contraction entries) reads only its own rows
+-- Run this migration on the mvchat database
+
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_page ON clingy_entries(pregnancy_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_type_page ON clingy_entries(pregnancy_id, entry_type, created_at DESC, id DESC);
@@ -0,0 +1,17 @@
+-- Sliding-window rate limit counters shared by every server instance
+-- (internal/ratelimit), replacing the invite code attempt log
+-- Run this migration on the mvchat database
+
+-- Counters are cheap to lose, so the table skips the write-ahead log
+CREATE UNLOGGED TABLE IF NOT EXISTS clingy_rate_limits (
+    key TEXT NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (key, window_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_rate_limits_expires ON clingy_rate_limits(expires_at);
+
+-- Failed invite code attempts are counted under code_attempts:<userId> now
+DROP TABLE IF EXISTS clingy_code_attempts;
@@ -0,0 +1,18 @@
+-- Gap reminders: a user asks to be nudged when no entry of a type was logged
+-- for their cadence ("you haven't logged weight in 2 weeks")
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_entry_reminders (
+    id BIGSERIAL PRIMARY KEY,
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,                     -- UUID format; who is nudged
+    entry_type VARCHAR(50) NOT NULL,
+    cadence_days INTEGER NOT NULL CHECK (cadence_days BETWEEN 1 AND 90),
+    snoozed_until TIMESTAMPTZ,                 -- No nudges for this type until then
+    last_nudged_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),      -- Last cadence change; gaps count from here at the earliest
+    UNIQUE(pregnancy_id, user_id, entry_type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_entry_reminders_user ON clingy_entry_reminders(user_id);
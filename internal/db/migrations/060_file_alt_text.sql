@@ -0,0 +1,10 @@
+-- Alt text on files, read out by screen readers in place of the image.
+-- Empty means none was written yet
+-- Run this migration on the mvchat database
+
+ALTER TABLE clingy_files ADD COLUMN IF NOT EXISTS alt_text TEXT NOT NULL DEFAULT '';
+
+-- The accessibility audit lists a pregnancy's images that still lack it
+CREATE INDEX IF NOT EXISTS idx_clingy_files_missing_alt_text
+    ON clingy_files(pregnancy_id, created_at)
+    WHERE alt_text = '' AND deleted_at IS NULL;
@@ -0,0 +1,54 @@
+-- When an entry happened, for from/to date filters: occurred_at, else the
+-- first date in the payload, else created_at. Kept by trigger so every write
+-- path (create, batch, sync, backfill) fills it in
+-- Run this migration on the mvchat database
+
+-- Casts payload text to a timestamp, NULL when it isn't an ISO date, so an
+-- odd payload can't fail a write
+CREATE OR REPLACE FUNCTION clingy_try_timestamptz(v TEXT) RETURNS TIMESTAMPTZ AS $$
+BEGIN
+    IF v !~ '^\d{4}-\d{2}-\d{2}' THEN
+        RETURN NULL;
+    END IF;
+    RETURN v::timestamptz;
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- Payload keys in the same order as entryTimestampKeys (see api/duplicates.go)
+CREATE OR REPLACE FUNCTION clingy_entry_time(occurred TIMESTAMPTZ, data JSONB, created TIMESTAMPTZ) RETURNS TIMESTAMPTZ AS $$
+    SELECT COALESCE(
+        occurred,
+        clingy_try_timestamptz(data->>'timestamp'),
+        clingy_try_timestamptz(data->>'date'),
+        clingy_try_timestamptz(data->>'dateTime'),
+        clingy_try_timestamptz(data->>'time'),
+        clingy_try_timestamptz(data->>'startTime'),
+        created
+    )
+$$ LANGUAGE sql STABLE;
+
+ALTER TABLE clingy_entries ADD COLUMN IF NOT EXISTS entry_at TIMESTAMPTZ;
+
+-- Fill existing rows without bumping sync versions, clocks or revisions:
+-- nothing a client syncs changes
+ALTER TABLE clingy_entries DISABLE TRIGGER USER;
+UPDATE clingy_entries SET entry_at = clingy_entry_time(occurred_at, data, created_at) WHERE entry_at IS NULL;
+ALTER TABLE clingy_entries ENABLE TRIGGER USER;
+
+ALTER TABLE clingy_entries ALTER COLUMN entry_at SET NOT NULL;
+
+CREATE OR REPLACE FUNCTION clingy_set_entry_at() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.entry_at := clingy_entry_time(NEW.occurred_at, NEW.data, NEW.created_at);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS clingy_entries_entry_at ON clingy_entries;
+CREATE TRIGGER clingy_entries_entry_at
+    BEFORE INSERT OR UPDATE ON clingy_entries
+    FOR EACH ROW EXECUTE FUNCTION clingy_set_entry_at();
+
+CREATE INDEX IF NOT EXISTS idx_clingy_entries_entry_at ON clingy_entries(pregnancy_id, entry_at);
@@ -0,0 +1,13 @@
+-- Ledger of records migrated from the original tracker backend (v1), so
+-- re-running a migration skips what already came over
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_v1_migrations (
+    user_id TEXT NOT NULL,                     -- UUID format; who migrated
+    record_kind VARCHAR(20) NOT NULL,          -- profile, log, preference, photo
+    v1_id VARCHAR(100) NOT NULL,               -- ID in the v1 export (preference key for preferences)
+    pregnancy_id BIGINT NOT NULL REFERENCES clingy_pregnancies(id) ON DELETE CASCADE,
+    target VARCHAR(200) NOT NULL,              -- What it became, e.g. entry:weight/v1-123
+    migrated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (user_id, record_kind, v1_id)
+);
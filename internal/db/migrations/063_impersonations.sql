@@ -0,0 +1,30 @@
+-- Admin impersonation sessions for debugging user-specific issues, and every
+-- request made with them. Owners are notified once a session ends
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_impersonations (
+    id BIGSERIAL PRIMARY KEY,
+    admin_id TEXT NOT NULL,                    -- UUID format; who impersonates
+    user_id TEXT NOT NULL,                     -- UUID format; who is impersonated
+    reason TEXT NOT NULL,
+    scope VARCHAR(10) NOT NULL DEFAULT 'read', -- 'read' or 'write'
+    token_hash VARCHAR(64) NOT NULL UNIQUE,    -- SHA-256 of the token; the token itself is never stored
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ,                      -- Ended early by the admin
+    owner_notified_at TIMESTAMPTZ              -- NULL until the end notice went out
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_impersonations_admin ON clingy_impersonations(admin_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_clingy_impersonations_unnotified ON clingy_impersonations(expires_at) WHERE owner_notified_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS clingy_impersonation_requests (
+    id BIGSERIAL PRIMARY KEY,
+    impersonation_id BIGINT NOT NULL REFERENCES clingy_impersonations(id) ON DELETE CASCADE,
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    status INTEGER NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_impersonation_requests_session ON clingy_impersonation_requests(impersonation_id, created_at);
@@ -0,0 +1,17 @@
+-- Community service topics (e.g. mvchat2 community rooms) linked to a week of
+-- weekly facts, served alongside them so apps can deep-link into discussions
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_community_topics (
+    id BIGSERIAL PRIMARY KEY,
+    week INTEGER NOT NULL,
+    service VARCHAR(50) NOT NULL,  -- Community service the topic belongs to, e.g. 'mvchat2'
+    topic_id TEXT NOT NULL,        -- The service's topic or room ID
+    title TEXT,                    -- Optional label shown in the app
+    position INTEGER NOT NULL,     -- Order within the week
+    updated_by TEXT NOT NULL,      -- Admin user ID (UUID format)
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE(week, service, topic_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_community_topics_week ON clingy_community_topics(week, position);
@@ -0,0 +1,61 @@
+-- Sync versions for settings, birth plan sections and pregnancy profile
+-- changes, so the event feed can resume from one commit-ordered cursor
+-- Run this migration on the mvchat database
+
+-- Like entries, each write takes the next pregnancy sync version while holding
+-- the pregnancy row until it commits. Rows written before this migration keep 0.
+ALTER TABLE clingy_settings ADD COLUMN IF NOT EXISTS sync_version BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE clingy_birth_plan_sections ADD COLUMN IF NOT EXISTS sync_version BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE clingy_pregnancies ADD COLUMN IF NOT EXISTS profile_version BIGINT NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_clingy_settings_sync_version ON clingy_settings(pregnancy_id, sync_version);
+CREATE INDEX IF NOT EXISTS idx_clingy_birth_plan_sections_sync_version ON clingy_birth_plan_sections(pregnancy_id, sync_version);
+
+-- Settings now stamp the version they took, so the trigger runs before the write
+CREATE OR REPLACE FUNCTION clingy_bump_setting_sync_version() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE clingy_pregnancies SET sync_version = sync_version + 1
+    WHERE id = NEW.pregnancy_id
+    RETURNING sync_version INTO NEW.sync_version;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS clingy_settings_sync_version ON clingy_settings;
+CREATE TRIGGER clingy_settings_sync_version
+    BEFORE INSERT OR UPDATE ON clingy_settings
+    FOR EACH ROW EXECUTE FUNCTION clingy_bump_setting_sync_version();
+
+-- Birth plan edits and lock changes (those that move changed_at)
+CREATE OR REPLACE FUNCTION clingy_bump_birth_plan_sync_version() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' OR NEW.changed_at IS DISTINCT FROM OLD.changed_at THEN
+        UPDATE clingy_pregnancies SET sync_version = sync_version + 1
+        WHERE id = NEW.pregnancy_id
+        RETURNING sync_version INTO NEW.sync_version;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS clingy_birth_plan_sections_sync_version ON clingy_birth_plan_sections;
+CREATE TRIGGER clingy_birth_plan_sections_sync_version
+    BEFORE INSERT OR UPDATE ON clingy_birth_plan_sections
+    FOR EACH ROW EXECUTE FUNCTION clingy_bump_birth_plan_sync_version();
+
+-- Profile changes (those that move updated_at); the version bumps of entry
+-- and setting writes leave updated_at alone
+CREATE OR REPLACE FUNCTION clingy_bump_pregnancy_profile_version() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.updated_at IS DISTINCT FROM OLD.updated_at THEN
+        NEW.sync_version := NEW.sync_version + 1;
+        NEW.profile_version := NEW.sync_version;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS clingy_pregnancies_profile_version ON clingy_pregnancies;
+CREATE TRIGGER clingy_pregnancies_profile_version
+    BEFORE UPDATE ON clingy_pregnancies
+    FOR EACH ROW EXECUTE FUNCTION clingy_bump_pregnancy_profile_version();
@@ -0,0 +1,16 @@
+-- Calendar feeds: secret URLs calendar apps subscribe to for a user's
+-- scheduled entries, revocable one by one
+-- Run this migration on the mvchat database
+
+CREATE TABLE IF NOT EXISTS clingy_calendar_feeds (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,                     -- UUID format; the feed shows what this user can see
+    name VARCHAR(100) NOT NULL,                -- e.g. "Work calendar"
+    token_hash VARCHAR(64) NOT NULL UNIQUE,    -- SHA-256 of the token; the token itself is never stored
+    prefix VARCHAR(16) NOT NULL,               -- First characters, to tell feeds apart
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_clingy_calendar_feeds_user ON clingy_calendar_feeds(user_id);
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+)
+
+// ============ Moderation Operations ============
+
+// SetFileModeration records the moderation outcome of a file.
+func (d *DB) SetFileModeration(ctx context.Context, fileID int64, status, reason string) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_files
+		SET moderation_status = $2, moderation_reason = NULLIF($3, ''), moderated_at = NOW()
+		WHERE id = $1
+	`, fileID, status, reason)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetUnapprovedFileIDs returns the pregnancy's files that are awaiting moderation or blocked.
+func (d *DB) GetUnapprovedFileIDs(ctx context.Context, pregnancyID int64) (map[int64]bool, error) {
+	var ids []int64
+	err := d.db.SelectContext(ctx, &ids, `
+		SELECT id FROM clingy_files
+		WHERE pregnancy_id = $1 AND moderation_status IN ('pending', 'blocked')
+	`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+
+	hidden := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		hidden[id] = true
+	}
+	return hidden, nil
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/pagination"
+)
+
+// ============ Notification Operations ============
+
+// CreateNotification queues an in-app notification for a user.
+func (d *DB) CreateNotification(ctx context.Context, userID string, pregnancyID int64, kind string, payload json.RawMessage) error {
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_notifications (user_id, pregnancy_id, kind, payload)
+		VALUES ($1, $2, $3, $4)
+	`, userID, pregnancyID, kind, payload)
+	return err
+}
+
+// CreateTestNotification sends a notification outside any pregnancy, such as
+// an admin's test of a template, and returns it.
+func (d *DB) CreateTestNotification(ctx context.Context, userID, kind string, payload json.RawMessage) (*models.Notification, error) {
+	var n models.Notification
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_notifications (user_id, kind, payload)
+		VALUES ($1, $2, $3)
+		RETURNING *
+	`, userID, kind, payload).StructScan(&n)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// GetNotification gets one of a user's notifications.
+func (d *DB) GetNotification(ctx context.Context, notificationID int64, userID string) (*models.Notification, error) {
+	var n models.Notification
+	err := d.db.GetContext(ctx, &n, `
+		SELECT * FROM clingy_notifications WHERE id = $1 AND user_id = $2
+	`, notificationID, userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// GetNotifications gets a page of a user's notifications, newest first.
+func (d *DB) GetNotifications(ctx context.Context, userID string, unreadOnly bool, page pagination.Params) ([]models.Notification, error) {
+	query := `SELECT * FROM clingy_notifications WHERE user_id = $1`
+	if unreadOnly {
+		query += " AND read_at IS NULL"
+	}
+	clause, args := page.Clause("created_at", "id", []interface{}{userID})
+	query += clause
+
+	var notifications []models.Notification
+	err := d.db.SelectContext(ctx, &notifications, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// MarkNotificationRead marks a notification as read.
+func (d *DB) MarkNotificationRead(ctx context.Context, notificationID int64, userID string) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_notifications SET read_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND read_at IS NULL
+	`, notificationID, userID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Pairing Removal Operations ============
+
+// RequestPairingRemoval starts removing the user's pairing, as owner or partner.
+// An approved pairing goes into the 'removing' state, which suspends partner
+// access until it is undone or completed; pending is true then. Pairings that
+// were never approved are removed at once. Returns ErrConflict when a removal
+// is already pending.
+func (d *DB) RequestPairingRemoval(ctx context.Context, userID string) (p *models.Pregnancy, pending bool, err error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var current models.Pregnancy
+	err = tx.GetContext(ctx, &current, `
+		SELECT * FROM clingy_pregnancies
+		WHERE (owner_id = $1 AND partner_id IS NOT NULL) OR partner_id = $1
+		LIMIT 1
+		FOR UPDATE
+	`, userID)
+	if err == sql.ErrNoRows {
+		return nil, false, ErrNotFound
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var updated models.Pregnancy
+	switch current.PartnerStatus.String {
+	case "removing":
+		return nil, false, ErrConflict
+	case "approved":
+		pending = true
+		err = tx.GetContext(ctx, &updated, `
+			UPDATE clingy_pregnancies SET
+				partner_status = 'removing',
+				partner_removal_requested_at = NOW(),
+				partner_removal_requested_by = $2,
+				updated_at = NOW()
+			WHERE id = $1
+			RETURNING *
+		`, current.ID, userID)
+	default:
+		updated = current
+		_, err = tx.ExecContext(ctx, `
+			UPDATE clingy_pregnancies SET
+				partner_id = NULL,
+				partner_status = NULL,
+				partner_permission = NULL,
+				updated_at = NOW()
+			WHERE id = $1
+		`, current.ID)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return &updated, pending, nil
+}
+
+// GetPairingRemoval returns the pregnancy whose pairing the user, as owner or
+// partner, is in the middle of removing.
+func (d *DB) GetPairingRemoval(ctx context.Context, userID string) (*models.Pregnancy, error) {
+	var p models.Pregnancy
+	err := d.db.GetContext(ctx, &p, `
+		SELECT * FROM clingy_pregnancies
+		WHERE (owner_id = $1 OR partner_id = $1) AND partner_status = 'removing'
+		LIMIT 1
+	`, userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UndoPairingRemoval restores a pairing the user asked to remove after since.
+func (d *DB) UndoPairingRemoval(ctx context.Context, userID string, since time.Time) (*models.Pregnancy, error) {
+	var p models.Pregnancy
+	err := d.db.GetContext(ctx, &p, `
+		UPDATE clingy_pregnancies SET
+			partner_status = 'approved',
+			partner_removal_requested_at = NULL,
+			partner_removal_requested_by = NULL,
+			updated_at = NOW()
+		WHERE partner_status = 'removing'
+		  AND partner_removal_requested_by = $1
+		  AND partner_removal_requested_at > $2
+		RETURNING *
+	`, userID, since)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CompletePairingRemovals removes pairings whose removal was requested before
+// cutoff. It returns how many were removed.
+func (d *DB) CompletePairingRemovals(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_pregnancies SET
+			partner_id = NULL,
+			partner_status = NULL,
+			partner_permission = NULL,
+			partner_removal_requested_at = NULL,
+			partner_removal_requested_by = NULL,
+			updated_at = NOW()
+		WHERE partner_status = 'removing' AND partner_removal_requested_at <= $1
+	`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
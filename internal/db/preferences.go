@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ User Preference Operations ============
+
+// GetUserPreferences returns a user's preferences, or ErrNotFound if they
+// never saved any.
+func (d *DB) GetUserPreferences(ctx context.Context, userID string) (*models.UserPreferences, error) {
+	var p models.UserPreferences
+	err := d.db.GetContext(ctx, &p, `SELECT * FROM clingy_user_preferences WHERE user_id = $1`, userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpsertUserPreferences saves a user's preferences, replacing all fields.
+func (d *DB) UpsertUserPreferences(ctx context.Context, p *models.UserPreferences) (*models.UserPreferences, error) {
+	var saved models.UserPreferences
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_user_preferences (user_id, locale, measurement_system, first_day_of_week)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+			locale = EXCLUDED.locale,
+			measurement_system = EXCLUDED.measurement_system,
+			first_day_of_week = EXCLUDED.first_day_of_week,
+			updated_at = NOW()
+		RETURNING *
+	`, p.UserID, p.Locale, p.MeasurementSystem, p.FirstDayOfWeek).StructScan(&saved)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ File Preview Operations ============
+
+// maxPreviewAttempts caps how often a preview interrupted mid-render is retried.
+const maxPreviewAttempts = 3
+
+// QueueFilePreview queues a file's preview for rendering, replacing any previous
+// result. Returns ErrConflict while a render is running.
+func (d *DB) QueueFilePreview(ctx context.Context, fileID int64, kind string) (*models.FilePreview, error) {
+	var p models.FilePreview
+	err := d.db.GetContext(ctx, &p, `
+		INSERT INTO clingy_file_previews (file_id, kind)
+		VALUES ($1, $2)
+		ON CONFLICT (file_id) DO UPDATE SET
+			kind = EXCLUDED.kind,
+			status = 'pending',
+			preview_dir = NULL,
+			has_stream = FALSE,
+			error = NULL,
+			attempts = 0,
+			updated_at = NOW()
+		WHERE clingy_file_previews.status <> 'running'
+		RETURNING *
+	`, fileID, kind)
+	if err == sql.ErrNoRows {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetFilePreview gets a file's preview.
+func (d *DB) GetFilePreview(ctx context.Context, fileID int64) (*models.FilePreview, error) {
+	var p models.FilePreview
+	err := d.db.GetContext(ctx, &p, `
+		SELECT * FROM clingy_file_previews WHERE file_id = $1
+	`, fileID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ClaimFilePreviews marks up to limit pending previews of live files as running
+// and returns them, oldest first. Concurrent servers claim disjoint previews.
+func (d *DB) ClaimFilePreviews(ctx context.Context, limit int) ([]models.FilePreview, error) {
+	var previews []models.FilePreview
+	err := d.db.SelectContext(ctx, &previews, `
+		UPDATE clingy_file_previews SET status = 'running', attempts = attempts + 1, updated_at = NOW()
+		WHERE file_id IN (
+			SELECT fp.file_id FROM clingy_file_previews fp
+			JOIN clingy_files f ON f.id = fp.file_id
+			WHERE fp.status = 'pending' AND f.deleted_at IS NULL
+			ORDER BY fp.created_at
+			LIMIT $1
+			FOR UPDATE OF fp SKIP LOCKED
+		)
+		RETURNING *
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return previews, nil
+}
+
+// CompleteFilePreview records a rendered preview.
+func (d *DB) CompleteFilePreview(ctx context.Context, fileID int64, previewDir string, hasStream bool) error {
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_file_previews SET
+			status = 'ready', preview_dir = $2, has_stream = $3, error = NULL, updated_at = NOW()
+		WHERE file_id = $1
+	`, fileID, previewDir, hasStream)
+	return err
+}
+
+// FailFilePreview records why a preview could not be rendered.
+func (d *DB) FailFilePreview(ctx context.Context, fileID int64, message string) error {
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_file_previews SET status = 'failed', error = $2, updated_at = NOW()
+		WHERE file_id = $1
+	`, fileID, message)
+	return err
+}
+
+// RequeueStalePreviews returns previews left running before since, by a server
+// that stopped mid-render, to the queue. Those out of attempts fail instead.
+func (d *DB) RequeueStalePreviews(ctx context.Context, since time.Time) (int64, error) {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_file_previews SET
+			status = CASE WHEN attempts < $2 THEN 'pending' ELSE 'failed' END,
+			error = CASE WHEN attempts < $2 THEN NULL ELSE 'Rendering was interrupted' END,
+			updated_at = NOW()
+		WHERE status = 'running' AND updated_at < $1
+	`, since, maxPreviewAttempts)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Profile Operations ============
+
+// linkedUserIDs selects every user whose name is cached on a pregnancy: partners,
+// active supporters and active care providers.
+const linkedUserIDs = `
+	SELECT partner_id FROM clingy_pregnancies WHERE partner_id IS NOT NULL
+	UNION SELECT user_id FROM clingy_supporters WHERE removed_at IS NULL
+	UNION SELECT user_id FROM clingy_care_providers WHERE removed_at IS NULL`
+
+// ApplyProfileEvent stores a profile change from mvchat2 and copies the new
+// display name onto the user's pairings. Events older than the cached profile,
+// and events for users linked to no pregnancy, are ignored; applied is false then.
+func (d *DB) ApplyProfileEvent(ctx context.Context, p *models.UserProfile) (applied bool, err error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO clingy_user_profiles (user_id, display_name, avatar_url, profile_updated_at)
+		SELECT $1::text, $2::text, $3::text, $4::timestamptz
+		WHERE $1 IN (`+linkedUserIDs+`)
+		ON CONFLICT (user_id) DO UPDATE SET
+			display_name = EXCLUDED.display_name,
+			avatar_url = EXCLUDED.avatar_url,
+			profile_updated_at = EXCLUDED.profile_updated_at,
+			synced_at = NOW()
+		WHERE clingy_user_profiles.profile_updated_at < EXCLUDED.profile_updated_at
+	`, p.UserID, p.DisplayName, p.AvatarURL, p.ProfileUpdatedAt)
+	if err != nil {
+		return false, err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return false, nil
+	}
+
+	if _, err := applyProfileNames(ctx, tx, p.UserID); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ReconcileUserProfiles refreshes cached profiles from the mvchat2 users table and
+// fixes any stale names, catching events the webhook missed. It returns how many
+// profiles and cached names changed.
+func (d *DB) ReconcileUserProfiles(ctx context.Context) (profiles, names int64, err error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO clingy_user_profiles (user_id, display_name, avatar_url, profile_updated_at)
+		SELECT u.id::text, NULLIF(u.public->>'fn', ''), NULLIF(u.public->'photo'->>'ref', ''), NOW()
+		FROM users u
+		WHERE u.id::text IN (`+linkedUserIDs+`)
+		ON CONFLICT (user_id) DO UPDATE SET
+			display_name = EXCLUDED.display_name,
+			avatar_url = EXCLUDED.avatar_url,
+			profile_updated_at = EXCLUDED.profile_updated_at,
+			synced_at = NOW()
+		WHERE (clingy_user_profiles.display_name, clingy_user_profiles.avatar_url)
+			IS DISTINCT FROM (EXCLUDED.display_name, EXCLUDED.avatar_url)
+	`)
+	if err != nil {
+		return 0, 0, err
+	}
+	profiles, _ = result.RowsAffected()
+
+	names, err = applyProfileNames(ctx, tx, "")
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return profiles, names, nil
+}
+
+// applyProfileNames copies cached display names onto partner, supporter and
+// provider records that differ. userID "" applies every cached profile.
+func applyProfileNames(ctx context.Context, tx *sqlx.Tx, userID string) (int64, error) {
+	var total int64
+	for _, query := range []string{`
+		UPDATE clingy_pregnancies t SET partner_name = LEFT(u.display_name, 100)
+		FROM clingy_user_profiles u
+		WHERE t.partner_id = u.user_id AND ($1 = '' OR u.user_id = $1)
+		  AND u.display_name IS NOT NULL AND t.partner_name IS DISTINCT FROM LEFT(u.display_name, 100)
+	`, `
+		UPDATE clingy_supporters t SET display_name = u.display_name
+		FROM clingy_user_profiles u
+		WHERE t.user_id = u.user_id AND t.removed_at IS NULL AND ($1 = '' OR u.user_id = $1)
+		  AND u.display_name IS NOT NULL AND t.display_name IS DISTINCT FROM u.display_name
+	`, `
+		UPDATE clingy_care_providers t SET display_name = u.display_name
+		FROM clingy_user_profiles u
+		WHERE t.user_id = u.user_id AND t.removed_at IS NULL AND ($1 = '' OR u.user_id = $1)
+		  AND u.display_name IS NOT NULL AND t.display_name IS DISTINCT FROM u.display_name
+	`} {
+		result, err := tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return 0, err
+		}
+		rows, _ := result.RowsAffected()
+		total += rows
+	}
+	return total, nil
+}
+
+// GetPregnancyProfiles returns the cached profiles of a pregnancy's partner and
+// active supporters, by user ID.
+func (d *DB) GetPregnancyProfiles(ctx context.Context, pregnancyID int64) (map[string]models.UserProfile, error) {
+	var profiles []models.UserProfile
+	err := d.db.SelectContext(ctx, &profiles, `
+		SELECT * FROM clingy_user_profiles
+		WHERE user_id IN (
+			SELECT partner_id FROM clingy_pregnancies WHERE id = $1 AND partner_id IS NOT NULL
+			UNION SELECT user_id FROM clingy_supporters WHERE pregnancy_id = $1 AND removed_at IS NULL
+		)
+	`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	byUser := make(map[string]models.UserProfile, len(profiles))
+	for _, p := range profiles {
+		byUser[p.UserID] = p
+	}
+	return byUser, nil
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Progress Post Operations ============
+
+// GetProgressPost gets a pregnancy's progress post settings.
+func (d *DB) GetProgressPost(ctx context.Context, pregnancyID int64) (*models.ProgressPost, error) {
+	var p models.ProgressPost
+	err := d.db.GetContext(ctx, &p, `SELECT * FROM clingy_progress_posts WHERE pregnancy_id = $1`, pregnancyID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// UpsertProgressPost saves a pregnancy's progress post settings. currentWeek is
+// recorded as already posted when the posts are first set up or moved to
+// another conversation, so the first message goes out at the next week.
+func (d *DB) UpsertProgressPost(ctx context.Context, pregnancyID int64, conversationID string, enabled bool, template string, weekTemplates json.RawMessage, currentWeek *int, userID string) (*models.ProgressPost, error) {
+	var p models.ProgressPost
+	err := d.db.GetContext(ctx, &p, `
+		INSERT INTO clingy_progress_posts (pregnancy_id, conversation_id, enabled, template, week_templates, last_week_posted, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (pregnancy_id) DO UPDATE SET
+			last_week_posted = CASE WHEN clingy_progress_posts.conversation_id = EXCLUDED.conversation_id
+				THEN clingy_progress_posts.last_week_posted ELSE EXCLUDED.last_week_posted END,
+			conversation_id = EXCLUDED.conversation_id,
+			enabled = EXCLUDED.enabled,
+			template = EXCLUDED.template,
+			week_templates = EXCLUDED.week_templates,
+			last_error = NULL,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW()
+		RETURNING *
+	`, pregnancyID, conversationID, enabled, template, weekTemplates, currentWeek, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DeleteProgressPost turns progress posts off for a pregnancy.
+func (d *DB) DeleteProgressPost(ctx context.Context, pregnancyID int64) error {
+	result, err := d.db.ExecContext(ctx, `DELETE FROM clingy_progress_posts WHERE pregnancy_id = $1`, pregnancyID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetEnabledProgressPosts lists the progress posts of pregnancies still in progress.
+func (d *DB) GetEnabledProgressPosts(ctx context.Context) ([]models.ProgressPost, error) {
+	var posts []models.ProgressPost
+	err := d.db.SelectContext(ctx, &posts, `
+		SELECT pp.* FROM clingy_progress_posts pp
+		JOIN clingy_pregnancies p ON p.id = pp.pregnancy_id
+		WHERE pp.enabled AND NOT p.archived AND p.outcome IS NULL AND NOT p.demo
+		ORDER BY pp.pregnancy_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// ClaimProgressPostWeek marks week as posted if no later week was, so only one
+// server process posts it. It reports whether the claim succeeded.
+func (d *DB) ClaimProgressPostWeek(ctx context.Context, pregnancyID int64, week int) (bool, error) {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_progress_posts SET last_week_posted = $2
+		WHERE pregnancy_id = $1 AND enabled AND (last_week_posted IS NULL OR last_week_posted < $2)
+	`, pregnancyID, week)
+	if err != nil {
+		return false, err
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// CompleteProgressPostWeek records that a claimed week was posted.
+func (d *DB) CompleteProgressPostWeek(ctx context.Context, pregnancyID int64) error {
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_progress_posts SET last_posted_at = NOW(), last_error = NULL WHERE pregnancy_id = $1
+	`, pregnancyID)
+	return err
+}
+
+// FailProgressPostWeek releases a claimed week whose post failed, so it is
+// retried, and records why.
+func (d *DB) FailProgressPostWeek(ctx context.Context, pregnancyID int64, week int, previous sql.NullInt64, reason string) error {
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_progress_posts SET last_week_posted = $3, last_error = $4
+		WHERE pregnancy_id = $1 AND last_week_posted = $2
+	`, pregnancyID, week, previous, reason)
+	return err
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// ============ Rate Limit Operations ============
+
+// AddRateLimitEvents adds n events to key's rate limit window that starts at
+// start and returns the count of that window and of the one before it. n is 0
+// to only read.
+func (d *DB) AddRateLimitEvents(ctx context.Context, key string, start time.Time, window time.Duration, n int) (int, int, error) {
+	var counts struct {
+		Current  int `db:"current"`
+		Previous int `db:"previous"`
+	}
+	var err error
+	if n == 0 {
+		err = d.db.GetContext(ctx, &counts, `
+			SELECT
+				COALESCE((SELECT count FROM clingy_rate_limits WHERE key = $1 AND window_start = $2), 0) AS current,
+				COALESCE((SELECT count FROM clingy_rate_limits WHERE key = $1 AND window_start = $3), 0) AS previous
+		`, key, start, start.Add(-window))
+	} else {
+		err = d.db.GetContext(ctx, &counts, `
+			WITH cur AS (
+				INSERT INTO clingy_rate_limits (key, window_start, count, expires_at)
+				VALUES ($1, $2, $4, $5)
+				ON CONFLICT (key, window_start) DO UPDATE SET count = clingy_rate_limits.count + EXCLUDED.count
+				RETURNING count
+			)
+			SELECT
+				(SELECT count FROM cur) AS current,
+				COALESCE((SELECT count FROM clingy_rate_limits WHERE key = $1 AND window_start = $3), 0) AS previous
+		`, key, start, start.Add(-window), n, start.Add(2*window))
+	}
+	return counts.Current, counts.Previous, err
+}
+
+// DeleteExpiredRateLimits deletes rate limit windows that no longer count.
+func (d *DB) DeleteExpiredRateLimits(ctx context.Context) (int64, error) {
+	result, err := d.db.ExecContext(ctx, `DELETE FROM clingy_rate_limits WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"context"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Data Residency Operations ============
+
+// GetRegionCounts counts pregnancies and stored files per residency region.
+// Demo pregnancies are not counted.
+func (d *DB) GetRegionCounts(ctx context.Context) ([]models.RegionDiagnostics, error) {
+	var regions []models.RegionDiagnostics
+	err := d.db.SelectContext(ctx, &regions, `
+		SELECT region, SUM(pregnancies)::int AS pregnancies, SUM(files)::int AS files
+		FROM (
+			SELECT region, COUNT(*) AS pregnancies, 0 AS files FROM clingy_pregnancies WHERE NOT demo GROUP BY region
+			UNION ALL
+			SELECT f.region, 0, COUNT(*) FROM clingy_files f
+			JOIN clingy_pregnancies p ON p.id = f.pregnancy_id
+			WHERE f.deleted_at IS NULL AND NOT p.demo
+			GROUP BY f.region
+		) counts
+		GROUP BY region
+		ORDER BY region
+	`)
+	return regions, err
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Entry Reminder Operations ============
+
+// lastEntryAt selects the newest live entry of a reminder's type.
+const lastEntryAt = `(
+	SELECT MAX(e.created_at) FROM clingy_entries e
+	WHERE e.pregnancy_id = r.pregnancy_id AND e.entry_type = r.entry_type AND e.deleted_at IS NULL
+) AS last_entry_at`
+
+// GetEntryReminders returns the user's reminders for the pregnancy with the
+// newest entry of each type, by entry type.
+func (d *DB) GetEntryReminders(ctx context.Context, pregnancyID int64, userID string) ([]models.EntryReminder, error) {
+	var reminders []models.EntryReminder
+	err := d.db.SelectContext(ctx, &reminders, `
+		SELECT r.*, `+lastEntryAt+`
+		FROM clingy_entry_reminders r
+		WHERE r.pregnancy_id = $1 AND r.user_id = $2
+		ORDER BY r.entry_type
+	`, pregnancyID, userID)
+	return reminders, err
+}
+
+// SetEntryReminder creates the user's reminder for an entry type or changes
+// its cadence. A changed cadence counts from now.
+func (d *DB) SetEntryReminder(ctx context.Context, pregnancyID int64, userID, entryType string, cadenceDays int) error {
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_entry_reminders (pregnancy_id, user_id, entry_type, cadence_days)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (pregnancy_id, user_id, entry_type) DO UPDATE
+		SET cadence_days = EXCLUDED.cadence_days, updated_at = NOW()
+	`, pregnancyID, userID, entryType, cadenceDays)
+	return err
+}
+
+// DeleteEntryReminder deletes the user's reminder for an entry type.
+func (d *DB) DeleteEntryReminder(ctx context.Context, pregnancyID int64, userID, entryType string) error {
+	result, err := d.db.ExecContext(ctx, `
+		DELETE FROM clingy_entry_reminders WHERE pregnancy_id = $1 AND user_id = $2 AND entry_type = $3
+	`, pregnancyID, userID, entryType)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SnoozeEntryReminder pauses the user's reminder for an entry type until
+// until, or lifts the snooze when until is nil.
+func (d *DB) SnoozeEntryReminder(ctx context.Context, pregnancyID int64, userID, entryType string, until *time.Time) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_entry_reminders SET snoozed_until = $4
+		WHERE pregnancy_id = $1 AND user_id = $2 AND entry_type = $3
+	`, pregnancyID, userID, entryType, until)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetDueEntryReminders lists unsnoozed reminders of pregnancies without an
+// outcome whose type has gone a full cadence without an entry, counting from
+// the newest entry, the last change of the reminder and its last nudge.
+func (d *DB) GetDueEntryReminders(ctx context.Context, limit int) ([]models.EntryReminder, error) {
+	var reminders []models.EntryReminder
+	err := d.db.SelectContext(ctx, &reminders, `
+		SELECT * FROM (
+			SELECT r.*, `+lastEntryAt+`
+			FROM clingy_entry_reminders r
+			JOIN clingy_pregnancies p ON p.id = r.pregnancy_id
+			WHERE p.outcome IS NULL AND NOT p.archived
+			  AND (r.snoozed_until IS NULL OR r.snoozed_until <= NOW())
+		) due
+		WHERE GREATEST(due.last_entry_at, due.updated_at, due.last_nudged_at) <= NOW() - make_interval(days => due.cadence_days)
+		ORDER BY due.id
+		LIMIT $1
+	`, limit)
+	return reminders, err
+}
+
+// ClaimEntryReminderNudge records a nudge unless another instance sent one
+// since lastNudgedAt was read. It reports whether the nudge is this caller's.
+func (d *DB) ClaimEntryReminderNudge(ctx context.Context, reminderID int64, lastNudgedAt sql.NullTime) (bool, error) {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_entry_reminders SET last_nudged_at = NOW()
+		WHERE id = $1 AND last_nudged_at IS NOT DISTINCT FROM $2
+	`, reminderID, lastNudgedAt)
+	if err != nil {
+		return false, err
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// DeleteEntryReminderByID deletes a reminder whose user lost write access.
+func (d *DB) DeleteEntryReminderByID(ctx context.Context, reminderID int64) error {
+	_, err := d.db.ExecContext(ctx, `DELETE FROM clingy_entry_reminders WHERE id = $1`, reminderID)
+	return err
+}
@@ -0,0 +1,24 @@
+package db
+
+import (
+	"context"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Entry Revision Operations ============
+
+// GetEntryRevisions gets the full revision history of a pregnancy's entries in
+// the order the revisions were recorded.
+func (d *DB) GetEntryRevisions(ctx context.Context, pregnancyID int64) ([]models.EntryRevision, error) {
+	var revisions []models.EntryRevision
+	err := d.db.SelectContext(ctx, &revisions, `
+		SELECT * FROM clingy_entry_revisions
+		WHERE pregnancy_id = $1
+		ORDER BY id
+	`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
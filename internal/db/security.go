@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/pagination"
+)
+
+// ============ Security Operations ============
+
+// fingerprintSeen touches a fingerprint row. A request after 30 minutes idle
+// counts as a new visit, which is what supporter engagement reports.
+const fingerprintSeen = `
+	visits = clingy_access_fingerprints.visits +
+		CASE WHEN clingy_access_fingerprints.last_seen_at < NOW() - INTERVAL '30 minutes' THEN 1 ELSE 0 END,
+	last_seen_at = NOW()`
+
+// RecordFingerprint stores the request fingerprint and reports which parts are new
+// for the user. Nothing is reported as new for a user's very first fingerprint.
+func (d *DB) RecordFingerprint(ctx context.Context, fp *models.AccessFingerprint) (newDevice, newCountry bool, err error) {
+	// Fast path: same token, device and country as before
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_access_fingerprints SET `+fingerprintSeen+`
+		WHERE user_id = $1 AND token_hash = $2 AND device_hash = $3 AND country = $4
+	`, fp.UserID, fp.TokenHash, fp.DeviceHash, fp.Country)
+	if err != nil {
+		return false, false, err
+	}
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		return false, false, nil
+	}
+
+	var seen struct {
+		Any     bool `db:"any_seen"`
+		Device  bool `db:"device_seen"`
+		Country bool `db:"country_seen"`
+	}
+	err = d.db.GetContext(ctx, &seen, `
+		SELECT
+			COUNT(*) > 0 AS any_seen,
+			COUNT(*) FILTER (WHERE device_hash = $2) > 0 AS device_seen,
+			COUNT(*) FILTER (WHERE country = $3) > 0 AS country_seen
+		FROM clingy_access_fingerprints
+		WHERE user_id = $1
+	`, fp.UserID, fp.DeviceHash, fp.Country)
+	if err != nil {
+		return false, false, err
+	}
+
+	_, err = d.db.ExecContext(ctx, `
+		INSERT INTO clingy_access_fingerprints (user_id, token_hash, device_hash, user_agent, country)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, token_hash, device_hash, country) DO UPDATE SET `+fingerprintSeen+`
+	`, fp.UserID, fp.TokenHash, fp.DeviceHash, fp.UserAgent, fp.Country)
+	if err != nil {
+		return false, false, err
+	}
+
+	if !seen.Any {
+		return false, false, nil
+	}
+	return !seen.Device, fp.Country != "" && !seen.Country, nil
+}
+
+// CreateSecurityEvent records a suspicious access event.
+func (d *DB) CreateSecurityEvent(ctx context.Context, event *models.SecurityEvent) (*models.SecurityEvent, error) {
+	var e models.SecurityEvent
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_security_events (pregnancy_id, user_id, kind, user_agent, country, action, reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING *
+	`, event.PregnancyID, event.UserID, event.Kind, event.UserAgent, event.Country, event.Action, event.Reason).StructScan(&e)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// CreateSecurityEventOnce records an event unless the same user caused one of
+// the same kind and reason for the pregnancy since the given time, so repeated
+// attempts don't flood the feed. It reports whether the event was written.
+func (d *DB) CreateSecurityEventOnce(ctx context.Context, event *models.SecurityEvent, since time.Time) (bool, error) {
+	result, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_security_events (pregnancy_id, user_id, kind, user_agent, country, action, reason)
+		SELECT $1, $2, $3, $4, $5, $6, $7
+		WHERE NOT EXISTS (
+			SELECT 1 FROM clingy_security_events
+			WHERE pregnancy_id = $1 AND user_id = $2 AND kind = $3 AND reason IS NOT DISTINCT FROM $7 AND created_at > $8
+		)
+	`, event.PregnancyID, event.UserID, event.Kind, event.UserAgent, event.Country, event.Action, event.Reason, since)
+	if err != nil {
+		return false, err
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// GetSecurityEvents gets a page of a pregnancy's security events, newest first.
+func (d *DB) GetSecurityEvents(ctx context.Context, pregnancyID int64, page pagination.Params) ([]models.SecurityEvent, error) {
+	clause, args := page.Clause("created_at", "id", []interface{}{pregnancyID})
+	var events []models.SecurityEvent
+	err := d.db.SelectContext(ctx, &events, `SELECT * FROM clingy_security_events WHERE pregnancy_id = $1`+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetRequireRepair reports whether the owner wants new devices to force re-pairing.
+func (d *DB) GetRequireRepair(ctx context.Context, pregnancyID int64) (bool, error) {
+	var required bool
+	err := d.db.GetContext(ctx, &required, `
+		SELECT require_repair FROM clingy_security_settings WHERE pregnancy_id = $1
+	`, pregnancyID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return required, err
+}
+
+// SetRequireRepair updates the owner's re-pairing preference.
+func (d *DB) SetRequireRepair(ctx context.Context, pregnancyID int64, required bool) error {
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_security_settings (pregnancy_id, require_repair)
+		VALUES ($1, $2)
+		ON CONFLICT (pregnancy_id) DO UPDATE SET
+			require_repair = EXCLUDED.require_repair,
+			updated_at = NOW()
+	`, pregnancyID, required)
+	return err
+}
+
+// RevokeViewerAccess unpairs a partner or removes a supporter or provider from a
+// pregnancy so they have to redeem a new invite code.
+func (d *DB) RevokeViewerAccess(ctx context.Context, pregnancyID int64, userID string) error {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE clingy_pregnancies SET
+			partner_id = NULL,
+			partner_status = NULL,
+			partner_permission = NULL,
+			updated_at = NOW()
+		WHERE id = $1 AND partner_id = $2
+	`, pregnancyID, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE clingy_supporters SET removed_at = NOW()
+		WHERE pregnancy_id = $1 AND user_id = $2 AND removed_at IS NULL
+	`, pregnancyID, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE clingy_care_providers SET removed_at = NOW()
+		WHERE pregnancy_id = $1 AND user_id = $2 AND removed_at IS NULL
+	`, pregnancyID, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
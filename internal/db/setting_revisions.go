@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Setting Revision Operations ============
+
+// Revisions are recorded by a trigger on clingy_settings, which keeps the last
+// 50 per setting.
+
+// GetSettingRevisions gets a setting's history, newest first.
+func (d *DB) GetSettingRevisions(ctx context.Context, pregnancyID int64, settingType string) ([]models.SettingRevision, error) {
+	var revisions []models.SettingRevision
+	err := d.db.SelectContext(ctx, &revisions, `
+		SELECT * FROM clingy_setting_revisions
+		WHERE pregnancy_id = $1 AND setting_type = $2
+		ORDER BY id DESC
+	`, pregnancyID, settingType)
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetSettingRevisionIDs gets the current revision ID of each live setting for
+// a pregnancy.
+func (d *DB) GetSettingRevisionIDs(ctx context.Context, pregnancyID int64) (map[string]int64, error) {
+	var rows []struct {
+		SettingType string `db:"setting_type"`
+		ID          int64  `db:"id"`
+	}
+	err := d.db.SelectContext(ctx, &rows, `
+		SELECT DISTINCT ON (r.setting_type) r.setting_type, r.id
+		FROM clingy_setting_revisions r
+		JOIN clingy_settings s ON s.pregnancy_id = r.pregnancy_id AND s.setting_type = r.setting_type
+		WHERE r.pregnancy_id = $1 AND s.deleted_at IS NULL
+		ORDER BY r.setting_type, r.id DESC
+	`, pregnancyID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		result[row.SettingType] = row.ID
+	}
+	return result, nil
+}
+
+// DeleteSetting soft-deletes a setting, keeping its history so it can be
+// reverted. Returns ErrNotFound if the setting doesn't exist or is already deleted.
+func (d *DB) DeleteSetting(ctx context.Context, pregnancyID int64, settingType string) (*models.Setting, error) {
+	var s models.Setting
+	err := d.db.GetContext(ctx, &s, `
+		UPDATE clingy_settings SET
+			deleted_at = NOW(),
+			version = version + 1,
+			updated_at = NOW()
+		WHERE pregnancy_id = $1 AND setting_type = $2 AND deleted_at IS NULL
+		RETURNING *
+	`, pregnancyID, settingType)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// RevertSetting restores a setting to one of its revisions as a new version.
+// Reverting to a revision that deleted the setting deletes it again. Returns
+// ErrNotFound if the revision doesn't belong to the setting.
+func (d *DB) RevertSetting(ctx context.Context, pregnancyID int64, settingType string, revisionID int64) (*models.Setting, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var rev models.SettingRevision
+	err = tx.GetContext(ctx, &rev, `
+		SELECT * FROM clingy_setting_revisions
+		WHERE id = $1 AND pregnancy_id = $2 AND setting_type = $3
+	`, revisionID, pregnancyID, settingType)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s models.Setting
+	err = tx.GetContext(ctx, &s, `
+		UPDATE clingy_settings SET
+			data = $3,
+			deleted_at = CASE WHEN $4::boolean THEN COALESCE(deleted_at, NOW()) END,
+			version = version + 1,
+			updated_at = NOW()
+		WHERE pregnancy_id = $1 AND setting_type = $2
+		RETURNING *
+	`, pregnancyID, settingType, rev.Data, rev.DeletedAt.Valid)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Sync Snapshot Operations ============
+
+// GetSyncSnapshot gets the stored snapshot for a pregnancy.
+func (d *DB) GetSyncSnapshot(ctx context.Context, pregnancyID int64) (*models.SyncSnapshot, error) {
+	var s models.SyncSnapshot
+	err := d.db.GetContext(ctx, &s, `
+		SELECT * FROM clingy_sync_snapshots WHERE pregnancy_id = $1
+	`, pregnancyID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SaveSyncSnapshot records a newly written snapshot, replacing the previous one.
+func (d *DB) SaveSyncSnapshot(ctx context.Context, s *models.SyncSnapshot) error {
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_sync_snapshots (pregnancy_id, storage_path, etag, size_bytes, entries, source_time, generated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (pregnancy_id) DO UPDATE SET
+			storage_path = EXCLUDED.storage_path,
+			etag = EXCLUDED.etag,
+			size_bytes = EXCLUDED.size_bytes,
+			entries = EXCLUDED.entries,
+			source_time = EXCLUDED.source_time,
+			generated_at = NOW()
+	`, s.PregnancyID, s.StoragePath, s.ETag, s.SizeBytes, s.Entries, s.SourceTime)
+	return err
+}
+
+// CountChangesSince counts entries and settings of a pregnancy changed after t.
+func (d *DB) CountChangesSince(ctx context.Context, pregnancyID int64, t time.Time) (int, error) {
+	var count int
+	err := d.db.GetContext(ctx, &count, `
+		SELECT
+			(SELECT COUNT(*) FROM clingy_entries WHERE pregnancy_id = $1 AND updated_at > $2) +
+			(SELECT COUNT(*) FROM clingy_settings WHERE pregnancy_id = $1 AND updated_at > $2)
+	`, pregnancyID, t)
+	return count, err
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Entry Summary Operations ============
+
+// GetSummaryConsent returns the owner's consent to summaries, or ErrNotFound.
+func (d *DB) GetSummaryConsent(ctx context.Context, pregnancyID int64) (*models.SummaryConsent, error) {
+	var c models.SummaryConsent
+	err := d.db.GetContext(ctx, &c, `SELECT * FROM clingy_summary_consents WHERE pregnancy_id = $1`, pregnancyID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SetSummaryConsent records the owner's consent, keeping the original time
+// if they already consented.
+func (d *DB) SetSummaryConsent(ctx context.Context, pregnancyID int64, userID string) (*models.SummaryConsent, error) {
+	var c models.SummaryConsent
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_summary_consents (pregnancy_id, consented_by) VALUES ($1, $2)
+		ON CONFLICT (pregnancy_id) DO UPDATE SET consented_by = clingy_summary_consents.consented_by
+		RETURNING *
+	`, pregnancyID, userID).StructScan(&c)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// RevokeSummaryConsent withdraws consent and deletes every summary made for
+// the pregnancy.
+func (d *DB) RevokeSummaryConsent(ctx context.Context, pregnancyID int64) error {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM clingy_summary_consents WHERE pregnancy_id = $1`, pregnancyID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM clingy_entry_summaries WHERE pregnancy_id = $1`, pregnancyID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetEntriesToSummarize lists journal posts of consenting pregnancies whose
+// content is at least minLen characters and that have no summary of their
+// current version.
+func (d *DB) GetEntriesToSummarize(ctx context.Context, minLen, limit int) ([]models.Entry, error) {
+	var entries []models.Entry
+	err := d.db.SelectContext(ctx, &entries, `
+		SELECT e.* FROM clingy_entries e
+		JOIN clingy_summary_consents c ON c.pregnancy_id = e.pregnancy_id
+		LEFT JOIN clingy_entry_summaries s ON s.entry_id = e.id
+		WHERE e.entry_type = 'journal' AND e.deleted_at IS NULL
+		  AND char_length(e.data->>'content') >= $1
+		  AND (s.entry_id IS NULL OR s.source_updated_at < e.updated_at)
+		ORDER BY e.updated_at
+		LIMIT $2
+	`, minLen, limit)
+	return entries, err
+}
+
+// SaveEntrySummary stores the summary of an entry version, unless consent
+// was withdrawn while it was written. It reports whether it was stored.
+func (d *DB) SaveEntrySummary(ctx context.Context, entry *models.Entry, summary string, tags []string) (bool, error) {
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return false, err
+	}
+	result, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_entry_summaries (entry_id, pregnancy_id, summary, tags, source_updated_at)
+		SELECT $1, $2, $3, $4, $5
+		WHERE EXISTS (SELECT 1 FROM clingy_summary_consents WHERE pregnancy_id = $2)
+		ON CONFLICT (entry_id) DO UPDATE SET
+			summary = EXCLUDED.summary,
+			tags = EXCLUDED.tags,
+			source_updated_at = EXCLUDED.source_updated_at,
+			created_at = NOW()
+	`, entry.ID, entry.PregnancyID, summary, tagsJSON, entry.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// GetEntrySummaries returns the current summaries of a pregnancy's journal
+// posts written after since (all of them if since is nil). Summaries of older
+// versions of a post are left out, and so are posts whose visibility isn't one
+// of visibility (nil for any).
+func (d *DB) GetEntrySummaries(ctx context.Context, pregnancyID int64, visibility []string, since *time.Time) ([]models.EntrySummary, error) {
+	var summaries []models.EntrySummary
+	query := `
+		SELECT s.*, e.client_id FROM clingy_entry_summaries s
+		JOIN clingy_entries e ON e.id = s.entry_id
+		WHERE s.pregnancy_id = $1 AND e.deleted_at IS NULL AND s.source_updated_at = e.updated_at
+	`
+	args := []interface{}{pregnancyID}
+	if since != nil {
+		args = append(args, *since)
+		query += fmt.Sprintf(` AND s.created_at > $%d`, len(args))
+	}
+	if visibility != nil {
+		args = append(args, visibility)
+		query += fmt.Sprintf(` AND e.visibility = ANY($%d)`, len(args))
+	}
+	err := d.db.SelectContext(ctx, &summaries, query, args...)
+	return summaries, err
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/scalecode-solutions/tracker2api/internal/entrydata"
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+	"github.com/scalecode-solutions/tracker2api/internal/vclock"
+)
+
+// ============ Sync v2 Operations ============
+
+// maxBaseRevisions bounds how far back a merge looks for the common version.
+const maxBaseRevisions = 200
+
+// SyncEntryV2 applies a sync v2 entry write, ordering its clock against the
+// stored one under a row lock. A newer write replaces the entry, a stale one is
+// ignored, and concurrent edits of mergeable types are merged against the last
+// version both sides saw. It returns the entry as stored afterwards, the
+// outcome and, when known, the payload fields that conflicted. A stored entry
+// whose visibility isn't one of visibility (nil for any) is not found.
+func (d *DB) SyncEntryV2(ctx context.Context, pregnancyID int64, req *models.SyncV2Entry, visibility []string) (*models.Entry, string, []string, error) {
+	e, outcome, fields, err := d.syncEntryV2(ctx, pregnancyID, req, visibility)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	entries := []models.Entry{*e}
+	upgradeEntries(entries)
+	return &entries[0], outcome, fields, nil
+}
+
+func (d *DB) syncEntryV2(ctx context.Context, pregnancyID int64, req *models.SyncV2Entry, visibility []string) (*models.Entry, string, []string, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer tx.Rollback()
+
+	clock, err := json.Marshal(req.Clock)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	current, err := lockEntry(ctx, tx, pregnancyID, req.EntryType, req.ClientID)
+	if err == sql.ErrNoRows {
+		var e models.Entry
+		err = tx.GetContext(ctx, &e, `
+			INSERT INTO clingy_entries (pregnancy_id, client_id, entry_type, data, scheduled_for, status, data_version, occurred_at, clock, deleted_at, visibility)
+			VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, 1), $8, $9, CASE WHEN $10::boolean THEN NOW() END, COALESCE($11::varchar, 'shared'))
+			ON CONFLICT (pregnancy_id, entry_type, client_id) DO NOTHING
+			RETURNING *
+		`, pregnancyID, req.ClientID, req.EntryType, req.Data, req.ScheduledFor, entryStatus(&req.EntryRequest), req.DataVersion, req.OccurredAt, clock, req.Deleted, req.Visibility)
+		if err == nil {
+			return &e, models.SyncV2Applied, nil, tx.Commit()
+		}
+		if err != sql.ErrNoRows {
+			return nil, "", nil, err
+		}
+		// Created concurrently; order against that write instead
+		current, err = lockEntry(ctx, tx, pregnancyID, req.EntryType, req.ClientID)
+	}
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if !visibleTo(visibility, current.Visibility) {
+		return nil, "", nil, ErrNotFound
+	}
+
+	serverClock, err := vclock.Parse(current.Clock)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	switch vclock.Compare(req.Clock, serverClock) {
+	case vclock.Equal:
+		// A retry of a write the server already has
+		return current, models.SyncV2Applied, nil, nil
+	case vclock.Before:
+		return current, models.SyncV2Stale, nil, nil
+	case vclock.After:
+		var e models.Entry
+		err = tx.GetContext(ctx, &e, `
+			UPDATE clingy_entries SET
+				data = $2,
+				scheduled_for = CASE WHEN $10::boolean THEN NULL ELSE COALESCE($3, scheduled_for) END,
+				status = CASE WHEN $10::boolean THEN NULL ELSE COALESCE($4, status) END,
+				data_version = COALESCE($5, 1),
+				occurred_at = COALESCE($6, occurred_at),
+				clock = $7,
+				deleted_at = CASE WHEN $8::boolean THEN COALESCE(deleted_at, NOW()) END,
+				visibility = COALESCE($9::varchar, visibility),
+				visibility_changed_at = CASE WHEN $9 <> visibility THEN NOW() ELSE visibility_changed_at END,
+				updated_at = NOW()
+			WHERE id = $1
+			RETURNING *
+		`, current.ID, req.Data, req.ScheduledFor, entryStatus(&req.EntryRequest), req.DataVersion, req.OccurredAt, clock, req.Deleted, req.Visibility, req.Unschedule)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		return &e, models.SyncV2Applied, nil, tx.Commit()
+	}
+
+	// Concurrent edits: only live entries of mergeable types are merged
+	if !vclock.Mergeable(req.EntryType) || req.Deleted || current.DeletedAt.Valid {
+		return current, models.SyncV2Conflict, nil, nil
+	}
+	merged, version, fields, err := mergeEntryV2(ctx, tx, current, req)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if merged == nil {
+		return current, models.SyncV2Conflict, fields, nil
+	}
+
+	joined, err := json.Marshal(vclock.Join(req.Clock, serverClock))
+	if err != nil {
+		return nil, "", nil, err
+	}
+	var e models.Entry
+	err = tx.GetContext(ctx, &e, `
+		UPDATE clingy_entries SET data = $2, data_version = $3, clock = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING *
+	`, current.ID, merged, version, joined)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return &e, models.SyncV2Merged, nil, tx.Commit()
+}
+
+// lockEntry selects an entry for update.
+func lockEntry(ctx context.Context, tx *sqlx.Tx, pregnancyID int64, entryType, clientID string) (*models.Entry, error) {
+	var e models.Entry
+	err := tx.GetContext(ctx, &e, `
+		SELECT * FROM clingy_entries
+		WHERE pregnancy_id = $1 AND entry_type = $2 AND client_id = $3
+		FOR UPDATE
+	`, pregnancyID, entryType, clientID)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// mergeEntryV2 three-way merges a concurrent write into the stored entry, at the
+// current payload version. The base is the newest revision the client's clock
+// has seen. merged is nil when the edits can't be merged.
+func mergeEntryV2(ctx context.Context, tx *sqlx.Tx, current *models.Entry, req *models.SyncV2Entry) (merged json.RawMessage, version int, fields []string, err error) {
+	server, version, err := entrydata.Upgrade(current.EntryType, current.DataVersion, current.Data)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	clientVersion := 1
+	if req.DataVersion != nil {
+		clientVersion = *req.DataVersion
+	}
+	if clientVersion != version {
+		// Payload shapes differ; fields can't be compared
+		return nil, 0, nil, nil
+	}
+
+	var revisions []models.EntryRevision
+	err = tx.SelectContext(ctx, &revisions, `
+		SELECT * FROM clingy_entry_revisions
+		WHERE entry_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, current.ID, maxBaseRevisions)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	var base json.RawMessage
+	for _, rev := range revisions {
+		revClock, err := vclock.Parse(rev.Clock)
+		if err != nil || rev.DeletedAt.Valid || !vclock.Descends(req.Clock, revClock) {
+			continue
+		}
+		// Revisions don't record their payload version; assume the entry's
+		base, _, err = entrydata.Upgrade(current.EntryType, current.DataVersion, rev.Data)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		break
+	}
+
+	merged, fields, err = vclock.Merge(current.EntryType, base, server, req.Data)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return merged, version, fields, nil
+}
+
+// entryStatus returns the status to store for an entry write. Scheduled entries
+// default to 'planned'.
+func entryStatus(req *models.EntryRequest) *string {
+	if req.ScheduledFor != nil && req.Status == nil {
+		planned := models.EntryStatusPlanned
+		return &planned
+	}
+	return req.Status
+}
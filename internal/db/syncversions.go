@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Sync Version Operations ============
+
+// SyncBase is the server state a pushed change is based on: the server
+// updatedAt of the entry the client last saw, or the pregnancy sync version it
+// last synced. The zero SyncBase means the change has no base.
+type SyncBase struct {
+	At      time.Time
+	Version int64
+}
+
+// IsZero reports whether the change has no base.
+func (b SyncBase) IsZero() bool {
+	return b.At.IsZero() && b.Version == 0
+}
+
+// changed reports whether the stored entry changed after the base. Every entry
+// changed after the zero base.
+func (b SyncBase) changed(e *models.Entry) bool {
+	if b.Version > 0 {
+		return e.SyncVersion > b.Version
+	}
+	return e.UpdatedAt.After(b.At)
+}
+
+// GetSyncVersion returns the pregnancy's current sync version: every entry or
+// setting change up to it is visible to readers.
+func (d *DB) GetSyncVersion(ctx context.Context, pregnancyID int64) (int64, error) {
+	var version int64
+	err := d.db.GetContext(ctx, &version, `SELECT sync_version FROM clingy_pregnancies WHERE id = $1`, pregnancyID)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	return version, err
+}
+
+// GetEntriesSinceVersion returns the pregnancy's entries changed after a sync
+// version, deleted ones included, oldest change first.
+func (d *DB) GetEntriesSinceVersion(ctx context.Context, pregnancyID, version int64) ([]models.Entry, error) {
+	var entries []models.Entry
+	err := d.db.SelectContext(ctx, &entries, `
+		SELECT * FROM clingy_entries
+		WHERE pregnancy_id = $1 AND sync_version > $2
+		ORDER BY sync_version
+	`, pregnancyID, version)
+	if err != nil {
+		return nil, err
+	}
+	upgradeEntries(entries)
+	return entries, nil
+}
+
+// SyncChanges is what changed in a pregnancy between two sync versions,
+// oldest change first. Pregnancy is set when its profile changed.
+type SyncChanges struct {
+	Entries   []models.Entry
+	Settings  []models.Setting
+	Sections  []models.BirthPlanSection // Only read when asked for
+	Pregnancy *models.Pregnancy
+}
+
+// GetChangesBetweenVersions returns the changes with a sync version after
+// after and at most upTo. Every change up to the pregnancy's current version
+// is committed, so reading up to a version read beforehand misses nothing.
+func (d *DB) GetChangesBetweenVersions(ctx context.Context, pregnancyID, after, upTo int64, birthPlan bool) (*SyncChanges, error) {
+	var c SyncChanges
+	err := d.db.SelectContext(ctx, &c.Entries, `
+		SELECT * FROM clingy_entries
+		WHERE pregnancy_id = $1 AND sync_version > $2 AND sync_version <= $3
+		ORDER BY sync_version
+	`, pregnancyID, after, upTo)
+	if err != nil {
+		return nil, err
+	}
+	upgradeEntries(c.Entries)
+
+	err = d.db.SelectContext(ctx, &c.Settings, `
+		SELECT * FROM clingy_settings
+		WHERE pregnancy_id = $1 AND sync_version > $2 AND sync_version <= $3
+		ORDER BY sync_version
+	`, pregnancyID, after, upTo)
+	if err != nil {
+		return nil, err
+	}
+
+	if birthPlan {
+		err = d.db.SelectContext(ctx, &c.Sections, `
+			SELECT * FROM clingy_birth_plan_sections
+			WHERE pregnancy_id = $1 AND sync_version > $2 AND sync_version <= $3
+			ORDER BY sync_version
+		`, pregnancyID, after, upTo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var p models.Pregnancy
+	err = d.db.GetContext(ctx, &p, `
+		SELECT * FROM clingy_pregnancies
+		WHERE id = $1 AND profile_version > $2 AND profile_version <= $3
+	`, pregnancyID, after, upTo)
+	if err == nil {
+		c.Pregnancy = &p
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SyncVersionAt returns the sync version a client that synced at t is at: just
+// before the first change after t, or the current version without one.
+// Changes from before sync versions were stamped on settings and birth plan
+// sections are left out.
+func (d *DB) SyncVersionAt(ctx context.Context, pregnancyID int64, t time.Time) (int64, error) {
+	var version sql.NullInt64
+	err := d.db.GetContext(ctx, &version, `
+		SELECT COALESCE(
+			(SELECT MIN(v) - 1 FROM (
+				SELECT MIN(sync_version) AS v FROM clingy_entries WHERE pregnancy_id = $1 AND updated_at > $2
+				UNION ALL
+				SELECT MIN(sync_version) FROM clingy_settings WHERE pregnancy_id = $1 AND updated_at > $2 AND sync_version > 0
+				UNION ALL
+				SELECT MIN(sync_version) FROM clingy_birth_plan_sections WHERE pregnancy_id = $1 AND changed_at > $2 AND sync_version > 0
+				UNION ALL
+				SELECT NULLIF(profile_version, 0) FROM clingy_pregnancies WHERE id = $1 AND updated_at > $2
+			) first),
+			(SELECT sync_version FROM clingy_pregnancies WHERE id = $1)
+		)
+	`, pregnancyID, t)
+	if err != nil {
+		return 0, err
+	}
+	if !version.Valid {
+		return 0, ErrNotFound
+	}
+	return version.Int64, nil
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Storage Tier Operations ============
+
+// GetFilesToTier lists hot files of pregnancies archived before the cutoff, in
+// regions that have cold storage. Files moved between tiers after the cutoff
+// (recently restored) are skipped.
+func (d *DB) GetFilesToTier(ctx context.Context, cutoff time.Time, regions []string, limit int) ([]models.File, error) {
+	var files []models.File
+	err := d.db.SelectContext(ctx, &files, `
+		SELECT f.* FROM clingy_files f
+		JOIN clingy_pregnancies p ON p.id = f.pregnancy_id
+		WHERE p.archived AND p.archived_at < $1
+		  AND f.storage_tier = 'hot' AND f.deleted_at IS NULL
+		  AND (f.tiered_at IS NULL OR f.tiered_at < $1)
+		  AND f.region = ANY($2)
+		ORDER BY f.id
+		LIMIT $3
+	`, cutoff, regions, limit)
+	return files, err
+}
+
+// GetColdFiles lists a pregnancy's files in cold storage.
+func (d *DB) GetColdFiles(ctx context.Context, pregnancyID int64) ([]models.File, error) {
+	var files []models.File
+	err := d.db.SelectContext(ctx, &files, `
+		SELECT * FROM clingy_files
+		WHERE pregnancy_id = $1 AND storage_tier = 'cold' AND deleted_at IS NULL
+		ORDER BY id
+	`, pregnancyID)
+	return files, err
+}
+
+// SetFileTier records which tier holds the file.
+func (d *DB) SetFileTier(ctx context.Context, fileID int64, tier string) error {
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_files SET storage_tier = $2, tiered_at = NOW() WHERE id = $1
+	`, fileID, tier)
+	return err
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Personal Access Token Operations ============
+
+// CreatePersonalToken stores a new token by its hash.
+func (d *DB) CreatePersonalToken(ctx context.Context, userID, name, tokenHash, prefix, scope string, expiresAt *time.Time) (*models.PersonalToken, error) {
+	var t models.PersonalToken
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_personal_tokens (user_id, name, token_hash, prefix, scope, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING *
+	`, userID, name, tokenHash, prefix, scope, expiresAt).StructScan(&t)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetPersonalTokens lists a user's tokens that are not revoked, newest first.
+func (d *DB) GetPersonalTokens(ctx context.Context, userID string) ([]models.PersonalToken, error) {
+	var tokens []models.PersonalToken
+	err := d.db.SelectContext(ctx, &tokens, `
+		SELECT * FROM clingy_personal_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`, userID)
+	return tokens, err
+}
+
+// CountActivePersonalTokens counts a user's tokens that are neither revoked nor expired.
+func (d *DB) CountActivePersonalTokens(ctx context.Context, userID string) (int, error) {
+	var count int
+	err := d.db.GetContext(ctx, &count, `
+		SELECT COUNT(*) FROM clingy_personal_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+	`, userID)
+	return count, err
+}
+
+// GetActivePersonalToken finds a usable token by hash and records that it was used.
+func (d *DB) GetActivePersonalToken(ctx context.Context, tokenHash string) (*models.PersonalToken, error) {
+	var t models.PersonalToken
+	err := d.db.GetContext(ctx, &t, `
+		SELECT * FROM clingy_personal_tokens
+		WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+	`, tokenHash)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Last use is tracked to the minute to keep automation from writing on every call
+	_, err = d.db.ExecContext(ctx, `
+		UPDATE clingy_personal_tokens SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RevokePersonalToken revokes one of the user's tokens.
+func (d *DB) RevokePersonalToken(ctx context.Context, tokenID int64, userID string) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_personal_tokens SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, tokenID, userID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// ============ Tombstone Operations ============
+
+// CompactTombstones hard-deletes up to limit entries soft-deleted before the
+// cutoff and moves each affected pregnancy's compaction watermark up to it.
+// Entry revisions are kept. It returns the number of entries deleted.
+func (d *DB) CompactTombstones(ctx context.Context, before time.Time, limit int) (int64, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var pregnancyIDs []int64
+	err = tx.SelectContext(ctx, &pregnancyIDs, `
+		WITH doomed AS (
+			SELECT id FROM clingy_entries
+			WHERE deleted_at IS NOT NULL AND deleted_at < $1
+			ORDER BY deleted_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		DELETE FROM clingy_entries e USING doomed
+		WHERE e.id = doomed.id
+		RETURNING e.pregnancy_id
+	`, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	if len(pregnancyIDs) == 0 {
+		return 0, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE clingy_pregnancies
+		SET tombstones_compacted_before = GREATEST(COALESCE(tombstones_compacted_before, $2), $2)
+		WHERE id = ANY($1)
+	`, pregnancyIDs, before)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(pregnancyIDs)), tx.Commit()
+}
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Entry Trigger Operations ============
+
+// GetEntryTriggers returns the pregnancy's entry triggers, oldest first.
+func (d *DB) GetEntryTriggers(ctx context.Context, pregnancyID int64) ([]models.EntryTrigger, error) {
+	var triggers []models.EntryTrigger
+	err := d.db.SelectContext(ctx, &triggers, `
+		SELECT * FROM clingy_entry_triggers WHERE pregnancy_id = $1 ORDER BY id
+	`, pregnancyID)
+	return triggers, err
+}
+
+// GetEntryTrigger returns one of the pregnancy's triggers, or ErrNotFound.
+func (d *DB) GetEntryTrigger(ctx context.Context, pregnancyID, triggerID int64) (*models.EntryTrigger, error) {
+	var t models.EntryTrigger
+	err := d.db.GetContext(ctx, &t, `
+		SELECT * FROM clingy_entry_triggers WHERE id = $1 AND pregnancy_id = $2
+	`, triggerID, pregnancyID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CountEntryTriggers counts the pregnancy's entry triggers.
+func (d *DB) CountEntryTriggers(ctx context.Context, pregnancyID int64) (int, error) {
+	var count int
+	err := d.db.GetContext(ctx, &count, `SELECT COUNT(*) FROM clingy_entry_triggers WHERE pregnancy_id = $1`, pregnancyID)
+	return count, err
+}
+
+// CreateEntryTrigger stores a new trigger.
+func (d *DB) CreateEntryTrigger(ctx context.Context, t *models.EntryTrigger) (*models.EntryTrigger, error) {
+	var created models.EntryTrigger
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_entry_triggers (pregnancy_id, created_by, name, entry_type, condition, url, secret, enabled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING *
+	`, t.PregnancyID, t.CreatedBy, t.Name, t.EntryType, t.Condition, t.URL, t.Secret, t.Enabled).StructScan(&created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateEntryTrigger replaces a trigger's name, entry type, condition, URL and
+// enabled flag. The secret is kept.
+func (d *DB) UpdateEntryTrigger(ctx context.Context, t *models.EntryTrigger) (*models.EntryTrigger, error) {
+	var updated models.EntryTrigger
+	err := d.db.QueryRowxContext(ctx, `
+		UPDATE clingy_entry_triggers
+		SET name = $3, entry_type = $4, condition = $5, url = $6, enabled = $7, updated_at = NOW()
+		WHERE id = $1 AND pregnancy_id = $2
+		RETURNING *
+	`, t.ID, t.PregnancyID, t.Name, t.EntryType, t.Condition, t.URL, t.Enabled).StructScan(&updated)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteEntryTrigger deletes a trigger and its delivery history.
+func (d *DB) DeleteEntryTrigger(ctx context.Context, pregnancyID, triggerID int64) error {
+	result, err := d.db.ExecContext(ctx, `DELETE FROM clingy_entry_triggers WHERE id = $1 AND pregnancy_id = $2`, triggerID, pregnancyID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetTriggerDeliveries returns a trigger's latest deliveries, newest first,
+// with the clientId of the entry that fired each.
+func (d *DB) GetTriggerDeliveries(ctx context.Context, triggerID int64, limit int) ([]models.TriggerDelivery, error) {
+	var deliveries []models.TriggerDelivery
+	err := d.db.SelectContext(ctx, &deliveries, `
+		SELECT d.*, e.client_id FROM clingy_trigger_deliveries d
+		LEFT JOIN clingy_entries e ON e.id = d.entry_id
+		WHERE d.trigger_id = $1
+		ORDER BY d.created_at DESC, d.id DESC
+		LIMIT $2
+	`, triggerID, limit)
+	return deliveries, err
+}
+
+// RecordTestDelivery stores the outcome of a test fire. code is 0 when no
+// response was received and errMsg "" when it was delivered.
+func (d *DB) RecordTestDelivery(ctx context.Context, triggerID int64, code int, errMsg string) (*models.TriggerDelivery, error) {
+	status := models.TriggerDeliveryDelivered
+	if errMsg != "" {
+		status = models.TriggerDeliveryFailed
+	}
+	var delivery models.TriggerDelivery
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_trigger_deliveries (trigger_id, test, status, attempts, response_code, error, next_attempt_at, delivered_at)
+		VALUES ($1, true, $2, 1, NULLIF($3, 0), NULLIF($4, ''), NULL, CASE WHEN $2 = 'delivered' THEN NOW() END)
+		RETURNING *
+	`, triggerID, status, code, errMsg).StructScan(&delivery)
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// GetDueTriggerDeliveries lists pending deliveries whose next attempt is due,
+// oldest first.
+func (d *DB) GetDueTriggerDeliveries(ctx context.Context, limit int) ([]models.TriggerDelivery, error) {
+	var deliveries []models.TriggerDelivery
+	err := d.db.SelectContext(ctx, &deliveries, `
+		SELECT * FROM clingy_trigger_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= NOW()
+		ORDER BY next_attempt_at
+		LIMIT $1
+	`, limit)
+	return deliveries, err
+}
+
+// GetEntryTriggerByID returns a trigger of any pregnancy, for the delivery
+// worker, or ErrNotFound.
+func (d *DB) GetEntryTriggerByID(ctx context.Context, triggerID int64) (*models.EntryTrigger, error) {
+	var t models.EntryTrigger
+	err := d.db.GetContext(ctx, &t, `SELECT * FROM clingy_entry_triggers WHERE id = $1`, triggerID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetEntryByID returns an entry by its ID, or ErrNotFound.
+func (d *DB) GetEntryByID(ctx context.Context, entryID int64) (*models.Entry, error) {
+	var e models.Entry
+	err := d.db.GetContext(ctx, &e, `SELECT * FROM clingy_entries WHERE id = $1`, entryID)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// FinishTriggerDelivery records an attempt at a delivery. status is pending
+// to retry at retryAt, delivered or failed. code is 0 when no response was
+// received and errMsg "" when it was delivered.
+func (d *DB) FinishTriggerDelivery(ctx context.Context, deliveryID int64, status string, code int, errMsg string, retryAt *time.Time) error {
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_trigger_deliveries SET
+			status = $2,
+			attempts = attempts + 1,
+			response_code = NULLIF($3, 0),
+			error = NULLIF($4, ''),
+			next_attempt_at = $5,
+			delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() END
+		WHERE id = $1
+	`, deliveryID, status, code, errMsg, retryAt)
+	return err
+}
+
+// DropTriggerDelivery deletes a queued delivery that won't be sent: its entry
+// doesn't match the condition, or the trigger was disabled.
+func (d *DB) DropTriggerDelivery(ctx context.Context, deliveryID int64) error {
+	_, err := d.db.ExecContext(ctx, `DELETE FROM clingy_trigger_deliveries WHERE id = $1`, deliveryID)
+	return err
+}
+
+// PruneTriggerDeliveries deletes finished deliveries created before cutoff.
+func (d *DB) PruneTriggerDeliveries(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := d.db.ExecContext(ctx, `
+		DELETE FROM clingy_trigger_deliveries WHERE status <> 'pending' AND created_at < $1
+	`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ V1 Migration Operations ============
+
+// GetV1Ledger gets the v1 records a user has migrated, keyed by kind and v1 ID.
+func (d *DB) GetV1Ledger(ctx context.Context, userID string) (map[string]models.V1LedgerEntry, error) {
+	var rows []models.V1LedgerEntry
+	err := d.db.SelectContext(ctx, &rows, `
+		SELECT * FROM clingy_v1_migrations WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	ledger := make(map[string]models.V1LedgerEntry, len(rows))
+	for _, row := range rows {
+		ledger[V1LedgerKey(row.RecordKind, row.V1ID)] = row
+	}
+	return ledger, nil
+}
+
+// V1LedgerKey is the GetV1Ledger key of a v1 record.
+func V1LedgerKey(kind, v1ID string) string {
+	return kind + "/" + v1ID
+}
+
+// RecordV1Migration adds a migrated record to the user's ledger.
+func (d *DB) RecordV1Migration(ctx context.Context, userID, kind, v1ID string, pregnancyID int64, target string) error {
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_v1_migrations (user_id, record_kind, v1_id, pregnancy_id, target)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, record_kind, v1_id) DO NOTHING
+	`, userID, kind, v1ID, pregnancyID, target)
+	return err
+}
+
+// ImportV1Entries inserts entries mapped from v1 records and their ledger rows
+// in one transaction, as backfilled entries with their original time. An entry
+// whose clientId already exists is left untouched but still recorded, so a run
+// cut short is completed by the next one. inserted reports which rows were new.
+func (d *DB) ImportV1Entries(ctx context.Context, userID string, pregnancyID int64, imports []models.V1EntryImport) ([]bool, error) {
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	inserted := make([]bool, len(imports))
+	for i, im := range imports {
+		e := &im.Entry
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO clingy_entries (pregnancy_id, client_id, entry_type, data, data_version, created_at, occurred_at, backfilled, visibility)
+			VALUES ($1, $2, $3, $4, 1, $5, $5, true, 'shared')
+			ON CONFLICT (pregnancy_id, entry_type, client_id) DO NOTHING
+		`, pregnancyID, e.ClientID, e.EntryType, e.Data, im.At)
+		if err != nil {
+			return nil, err
+		}
+		rows, _ := result.RowsAffected()
+		inserted[i] = rows > 0
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO clingy_v1_migrations (user_id, record_kind, v1_id, pregnancy_id, target)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (user_id, record_kind, v1_id) DO NOTHING
+		`, userID, im.RecordKind, im.V1ID, pregnancyID, fmt.Sprintf("entry:%s/%s", e.EntryType, e.ClientID))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
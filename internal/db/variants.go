@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Content Variant Operations ============
+
+// GetPublishedContentWeek returns a week's published version.
+func (d *DB) GetPublishedContentWeek(ctx context.Context, kind string, week int) (*models.ContentVersion, error) {
+	var c models.ContentVersion
+	err := d.db.GetContext(ctx, &c, `
+		SELECT * FROM clingy_content WHERE kind = $1 AND week = $2 AND status = 'published'
+	`, kind, week)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetContentVariants lists a week's variants with how many users saw each and how often.
+func (d *DB) GetContentVariants(ctx context.Context, kind string, week int) ([]models.ContentVariant, error) {
+	var variants []models.ContentVariant
+	err := d.db.SelectContext(ctx, &variants, `
+		SELECT v.*, COUNT(e.user_id) AS users, COALESCE(SUM(e.views), 0) AS views
+		FROM clingy_content_variants v
+		LEFT JOIN clingy_content_exposures e ON e.variant_id = v.id
+		WHERE v.kind = $1 AND v.week = $2
+		GROUP BY v.id
+		ORDER BY v.id
+	`, kind, week)
+	return variants, err
+}
+
+// GetActiveContentVariants returns a week's variants that are running at the given
+// time, in a stable order for assignment.
+func (d *DB) GetActiveContentVariants(ctx context.Context, kind string, week int, now time.Time) ([]models.ContentVariant, error) {
+	var variants []models.ContentVariant
+	err := d.db.SelectContext(ctx, &variants, `
+		SELECT * FROM clingy_content_variants
+		WHERE kind = $1 AND week = $2 AND weight > 0
+		  AND (starts_at IS NULL OR starts_at <= $3)
+		  AND (ends_at IS NULL OR ends_at > $3)
+		ORDER BY id
+	`, kind, week, now)
+	return variants, err
+}
+
+// CreateContentVariant adds a variant to a week.
+func (d *DB) CreateContentVariant(ctx context.Context, v *models.ContentVariant) (*models.ContentVariant, error) {
+	var created models.ContentVariant
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_content_variants (kind, week, name, weight, data, starts_at, ends_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING *
+	`, v.Kind, v.Week, v.Name, v.Weight, v.Data, v.StartsAt, v.EndsAt, v.CreatedBy).StructScan(&created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateContentVariant replaces a variant's name, weight, data and schedule.
+func (d *DB) UpdateContentVariant(ctx context.Context, v *models.ContentVariant) (*models.ContentVariant, error) {
+	var updated models.ContentVariant
+	err := d.db.QueryRowxContext(ctx, `
+		UPDATE clingy_content_variants SET
+			name = $4, weight = $5, data = $6, starts_at = $7, ends_at = $8, updated_at = NOW()
+		WHERE id = $1 AND kind = $2 AND week = $3
+		RETURNING *
+	`, v.ID, v.Kind, v.Week, v.Name, v.Weight, v.Data, v.StartsAt, v.EndsAt).StructScan(&updated)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteContentVariant deletes a variant along with its exposures.
+func (d *DB) DeleteContentVariant(ctx context.Context, kind string, week int, id int64) error {
+	result, err := d.db.ExecContext(ctx, `
+		DELETE FROM clingy_content_variants WHERE id = $1 AND kind = $2 AND week = $3
+	`, id, kind, week)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordContentExposure counts a user seeing a variant.
+func (d *DB) RecordContentExposure(ctx context.Context, variantID int64, userID string) error {
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO clingy_content_exposures (variant_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (variant_id, user_id) DO UPDATE SET
+			views = clingy_content_exposures.views + 1,
+			last_seen_at = NOW()
+	`, variantID, userID)
+	return err
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+)
+
+// ============ Entry Visibility Operations ============
+
+// visibleTo reports whether an entry of visibility is one of levels (nil for
+// any). Writes through an audience treat entries it can't see as not found.
+func visibleTo(levels []string, visibility string) bool {
+	if levels == nil {
+		return true
+	}
+	for _, l := range levels {
+		if l == visibility {
+			return true
+		}
+	}
+	return false
+}
+
+// SetEntriesVisibility changes the visibility of a pregnancy's live entries,
+// either those with the given clientIds or every entry of entryType. Changed
+// entries get a new updated_at so other devices sync the change. It returns
+// how many entries changed.
+func (d *DB) SetEntriesVisibility(ctx context.Context, pregnancyID int64, visibility string, clientIDs []string, entryType string) (int64, error) {
+	query := `
+		UPDATE clingy_entries SET visibility = $2, visibility_changed_at = NOW(), updated_at = NOW()
+		WHERE pregnancy_id = $1 AND visibility <> $2 AND deleted_at IS NULL`
+	args := []interface{}{pregnancyID, visibility}
+	if entryType != "" {
+		query += " AND entry_type = $3"
+		args = append(args, entryType)
+	} else {
+		query += " AND client_id = ANY($3)"
+		args = append(args, clientIDs)
+	}
+
+	result, err := d.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/models"
+)
+
+// ============ Widget Token Operations ============
+
+// CreateWidgetToken stores a new widget token by its hash.
+func (d *DB) CreateWidgetToken(ctx context.Context, pregnancyID int64, createdBy, name, tokenHash, prefix string, expiresAt *time.Time) (*models.WidgetToken, error) {
+	var t models.WidgetToken
+	err := d.db.QueryRowxContext(ctx, `
+		INSERT INTO clingy_widget_tokens (pregnancy_id, created_by, name, token_hash, prefix, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING *
+	`, pregnancyID, createdBy, name, tokenHash, prefix, expiresAt).StructScan(&t)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetWidgetTokens lists a pregnancy's widget tokens that are not revoked, newest first.
+func (d *DB) GetWidgetTokens(ctx context.Context, pregnancyID int64) ([]models.WidgetToken, error) {
+	var tokens []models.WidgetToken
+	err := d.db.SelectContext(ctx, &tokens, `
+		SELECT * FROM clingy_widget_tokens
+		WHERE pregnancy_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`, pregnancyID)
+	return tokens, err
+}
+
+// CountActiveWidgetTokens counts a pregnancy's widget tokens that are neither revoked nor expired.
+func (d *DB) CountActiveWidgetTokens(ctx context.Context, pregnancyID int64) (int, error) {
+	var count int
+	err := d.db.GetContext(ctx, &count, `
+		SELECT COUNT(*) FROM clingy_widget_tokens
+		WHERE pregnancy_id = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+	`, pregnancyID)
+	return count, err
+}
+
+// GetActiveWidgetToken finds a usable widget token by hash and records that it was used.
+func (d *DB) GetActiveWidgetToken(ctx context.Context, tokenHash string) (*models.WidgetToken, error) {
+	var t models.WidgetToken
+	err := d.db.GetContext(ctx, &t, `
+		SELECT * FROM clingy_widget_tokens
+		WHERE token_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+	`, tokenHash)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Widgets poll, so last use is tracked to the minute like personal tokens
+	_, err = d.db.ExecContext(ctx, `
+		UPDATE clingy_widget_tokens SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+	`, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// RevokeWidgetToken revokes one of a pregnancy's widget tokens.
+func (d *DB) RevokeWidgetToken(ctx context.Context, tokenID, pregnancyID int64) error {
+	result, err := d.db.ExecContext(ctx, `
+		UPDATE clingy_widget_tokens SET revoked_at = NOW()
+		WHERE id = $1 AND pregnancy_id = $2 AND revoked_at IS NULL
+	`, tokenID, pregnancyID)
+	if err != nil {
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
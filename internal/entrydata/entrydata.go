@@ -0,0 +1,74 @@
+// Package entrydata upgrades entry payloads written in older shapes.
+//
+// Every entry carries the dataVersion its client wrote. Each entry type has a
+// chain of upgraders; upgrader N turns version N into version N+1, so the current
+// version of a type is one more than the number of upgraders registered for it.
+// Payloads are upgraded on read and never rewritten in the database.
+package entrydata
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Upgrader rewrites a decoded payload from one version to the next in place.
+type Upgrader func(data map[string]interface{}) error
+
+var registry = map[string][]Upgrader{}
+
+// Register appends the next upgrader for entryType, raising its current version by one.
+// It must only be called from init functions. Add a new call, never edit an existing
+// one, whenever a client release changes the shape of an entry type's data:
+//
+//	func init() {
+//		Register("weight", func(data map[string]interface{}) error {
+//			data["unit"] = "kg" // v1 payloads were always kilograms
+//			return nil
+//		})
+//	}
+func Register(entryType string, fn Upgrader) {
+	registry[entryType] = append(registry[entryType], fn)
+}
+
+// Current returns the newest payload version the server understands for entryType.
+func Current(entryType string) int {
+	return len(registry[entryType]) + 1
+}
+
+// Versions lists the current version of every entry type that has upgraders.
+// Types not listed are at version 1.
+func Versions() map[string]int {
+	versions := make(map[string]int, len(registry))
+	for t := range registry {
+		versions[t] = Current(t)
+	}
+	return versions
+}
+
+// Upgrade brings data from version up to the current version of entryType.
+// Payloads already current, or newer than the server knows, are returned as is.
+func Upgrade(entryType string, version int, data json.RawMessage) (json.RawMessage, int, error) {
+	current := Current(entryType)
+	if version < 1 {
+		version = 1
+	}
+	if version >= current {
+		return data, version, nil
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return data, version, err
+	}
+	for v := version; v < current; v++ {
+		if err := registry[entryType][v-1](payload); err != nil {
+			return data, version, fmt.Errorf("upgrade %s v%d: %w", entryType, v, err)
+		}
+	}
+
+	upgraded, err := json.Marshal(payload)
+	if err != nil {
+		return data, version, err
+	}
+	return upgraded, current, nil
+}
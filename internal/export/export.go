@@ -0,0 +1,187 @@
+// Package export builds downloadable pregnancy archives.
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encryption parameters. Changing these requires bumping EncryptionFormat.
+const (
+	EncryptionFormat = "clingy-aes256gcm-scrypt-v1"
+	encryptedMagic   = "CLGYENC1"
+	saltSize         = 16
+	scryptN          = 1 << 15
+	scryptR          = 8
+	scryptP          = 1
+	keySize          = 32
+)
+
+// MinPasswordLength is the shortest password accepted for encrypted exports.
+const MinPasswordLength = 8
+
+var ErrPasswordTooShort = errors.New("password too short")
+
+// File is a single file placed in the archive.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Manifest describes the archive contents and, when encrypted, how to open it.
+type Manifest struct {
+	GeneratedAt string      `json:"generatedAt"`
+	PregnancyID int64       `json:"pregnancyId"`
+	Files       []string    `json:"files"`
+	Encryption  *Encryption `json:"encryption,omitempty"`
+}
+
+// Encryption documents the encryption applied to the inner archive.
+type Encryption struct {
+	Format        string `json:"format"`
+	File          string `json:"file"`
+	KDF           string `json:"kdf"`
+	Cipher        string `json:"cipher"`
+	Layout        string `json:"layout"`
+	DecryptFailed string `json:"decryptFailed"`
+}
+
+// Build zips the files with a manifest. If password is non-empty the data files are
+// zipped, encrypted with AES-256-GCM under an scrypt-derived key, and wrapped in an
+// outer archive next to a plaintext manifest. The password is never stored.
+func Build(pregnancyID int64, files []File, password string) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+
+	manifest := Manifest{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		PregnancyID: pregnancyID,
+		Files:       names,
+	}
+
+	if password == "" {
+		return zipFiles(append([]File{manifestFile(manifest)}, files...))
+	}
+
+	if len(password) < MinPasswordLength {
+		return nil, ErrPasswordTooShort
+	}
+
+	inner, err := zipFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := Encrypt(inner, password)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest.Encryption = &Encryption{
+		Format: EncryptionFormat,
+		File:   "export.zip.enc",
+		KDF:    fmt.Sprintf("scrypt N=%d r=%d p=%d keyLen=%d", scryptN, scryptR, scryptP, keySize),
+		Cipher: "AES-256-GCM",
+		Layout: fmt.Sprintf("%q magic | %d-byte salt | 12-byte nonce | ciphertext+tag", encryptedMagic, saltSize),
+		DecryptFailed: "A wrong password and a corrupted file both fail GCM authentication and cannot be told apart. " +
+			"The password is not stored on the server, so a forgotten password cannot be recovered; generate a new export instead.",
+	}
+
+	return zipFiles([]File{
+		manifestFile(manifest),
+		{Name: manifest.Encryption.File, Data: sealed},
+	})
+}
+
+// Encrypt seals plaintext with a key derived from password.
+func Encrypt(plaintext []byte, password string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	gcm, err := newGCM(password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := make([]byte, 0, len(encryptedMagic)+saltSize+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, encryptedMagic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, []byte(encryptedMagic)), nil
+}
+
+// Decrypt opens data produced by Encrypt.
+func Decrypt(data []byte, password string) ([]byte, error) {
+	if len(data) < len(encryptedMagic)+saltSize || string(data[:len(encryptedMagic)]) != encryptedMagic {
+		return nil, errors.New("not an encrypted export")
+	}
+	salt := data[len(encryptedMagic) : len(encryptedMagic)+saltSize]
+
+	gcm, err := newGCM(password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := data[len(encryptedMagic)+saltSize:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errors.New("not an encrypted export")
+	}
+	plaintext, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], []byte(encryptedMagic))
+	if err != nil {
+		return nil, errors.New("wrong password or corrupted export")
+	}
+	return plaintext, nil
+}
+
+func newGCM(password string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(password), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func manifestFile(m Manifest) File {
+	data, _ := json.MarshalIndent(m, "", "  ")
+	return File{Name: "manifest.json", Data: data}
+}
+
+func zipFiles(files []File) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
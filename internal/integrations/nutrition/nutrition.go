@@ -0,0 +1,228 @@
+// Package nutrition looks up foods and their nutrients for logged meals.
+//
+// A Provider is pluggable: the HTTP implementation talks to a food database
+// API (or a self-hosted copy) that speaks the same protocol. Cached wraps a
+// Provider so repeated searches don't reach the service.
+package nutrition
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when the provider has no food with the ID.
+var ErrNotFound = errors.New("food not found")
+
+// Nutrients per serving. Fields the provider doesn't know are nil.
+type Nutrients struct {
+	Calories *float64 `json:"calories,omitempty"` // kcal
+	Protein  *float64 `json:"protein,omitempty"`  // g
+	Carbs    *float64 `json:"carbs,omitempty"`    // g
+	Fat      *float64 `json:"fat,omitempty"`      // g
+	Fiber    *float64 `json:"fiber,omitempty"`    // g
+	Sugar    *float64 `json:"sugar,omitempty"`    // g
+	Sodium   *float64 `json:"sodium,omitempty"`   // mg
+	Calcium  *float64 `json:"calcium,omitempty"`  // mg
+	Iron     *float64 `json:"iron,omitempty"`     // mg
+	Folate   *float64 `json:"folate,omitempty"`   // µg DFE
+}
+
+// AddScaled adds n times servings to t. A nutrient stays nil until some food
+// reports it.
+func (t *Nutrients) AddScaled(n *Nutrients, servings float64) {
+	add := func(dst **float64, v *float64) {
+		if v == nil {
+			return
+		}
+		if *dst == nil {
+			*dst = new(float64)
+		}
+		**dst += *v * servings
+	}
+	add(&t.Calories, n.Calories)
+	add(&t.Protein, n.Protein)
+	add(&t.Carbs, n.Carbs)
+	add(&t.Fat, n.Fat)
+	add(&t.Fiber, n.Fiber)
+	add(&t.Sugar, n.Sugar)
+	add(&t.Sodium, n.Sodium)
+	add(&t.Calcium, n.Calcium)
+	add(&t.Iron, n.Iron)
+	add(&t.Folate, n.Folate)
+}
+
+// Food is a food database item with the nutrients of one serving.
+type Food struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Brand     string    `json:"brand,omitempty"`
+	Serving   string    `json:"serving,omitempty"` // e.g. "1 cup (240 ml)"
+	Nutrients Nutrients `json:"nutrients"`
+}
+
+// Provider searches a food database.
+type Provider interface {
+	Search(ctx context.Context, query string, limit int) ([]Food, error)
+	Food(ctx context.Context, id string) (*Food, error)
+}
+
+// HTTPProvider queries a food database API:
+//
+//	GET {URL}/search?q=...&limit=N -> {"foods": [Food]}
+//	GET {URL}/foods/{id}           -> Food (404 when unknown)
+//
+// The API key is sent as X-Api-Key when APIKey is set.
+type HTTPProvider struct {
+	URL    string
+	APIKey string
+	Client *http.Client
+}
+
+// NewHTTP creates an HTTPProvider with a request timeout.
+func NewHTTP(baseURL, apiKey string) *HTTPProvider {
+	return &HTTPProvider{URL: strings.TrimRight(baseURL, "/"), APIKey: apiKey, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Search returns up to limit foods matching query.
+func (p *HTTPProvider) Search(ctx context.Context, query string, limit int) ([]Food, error) {
+	var out struct {
+		Foods []Food `json:"foods"`
+	}
+	q := url.Values{"q": {query}, "limit": {strconv.Itoa(limit)}}
+	if err := p.get(ctx, "/search?"+q.Encode(), &out); err != nil {
+		return nil, err
+	}
+	if len(out.Foods) > limit {
+		out.Foods = out.Foods[:limit]
+	}
+	return out.Foods, nil
+}
+
+// Food returns the food with the ID, or ErrNotFound.
+func (p *HTTPProvider) Food(ctx context.Context, id string) (*Food, error) {
+	var f Food
+	if err := p.get(ctx, "/foods/"+url.PathEscape(id), &f); err != nil {
+		return nil, err
+	}
+	if f.ID == "" {
+		f.ID = id
+	}
+	return &f, nil
+}
+
+func (p *HTTPProvider) get(ctx context.Context, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.APIKey != "" {
+		req.Header.Set("X-Api-Key", p.APIKey)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("nutrition service returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode nutrition response: %w", err)
+	}
+	return nil
+}
+
+// cacheEntry is a cached search result or food.
+type cacheEntry struct {
+	foods   []Food
+	expires time.Time
+}
+
+// Cached keeps provider answers in memory for TTL, up to MaxEntries of them.
+// Unknown foods are not cached so a food added upstream shows up right away.
+type Cached struct {
+	Provider   Provider
+	TTL        time.Duration
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// NewCached wraps p with an in-memory cache.
+func NewCached(p Provider, ttl time.Duration) *Cached {
+	return &Cached{Provider: p, TTL: ttl, MaxEntries: 10000, entries: make(map[string]cacheEntry)}
+}
+
+// Search returns cached results for the normalized query when fresh.
+func (c *Cached) Search(ctx context.Context, query string, limit int) ([]Food, error) {
+	key := "s:" + strconv.Itoa(limit) + ":" + strings.ToLower(strings.Join(strings.Fields(query), " "))
+	if foods, ok := c.get(key); ok {
+		return foods, nil
+	}
+	foods, err := c.Provider.Search(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	c.put(key, foods)
+	for i := range foods {
+		c.put("f:"+foods[i].ID, foods[i:i+1])
+	}
+	return foods, nil
+}
+
+// Food returns a cached food when fresh.
+func (c *Cached) Food(ctx context.Context, id string) (*Food, error) {
+	if foods, ok := c.get("f:" + id); ok {
+		f := foods[0]
+		return &f, nil
+	}
+	f, err := c.Provider.Food(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c.put("f:"+id, []Food{*f})
+	return f, nil
+}
+
+func (c *Cached) get(key string) ([]Food, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.foods, true
+}
+
+func (c *Cached) put(key string, foods []Food) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= c.MaxEntries {
+		// Drop expired entries, then everything if the cache is still full
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.MaxEntries {
+			c.entries = make(map[string]cacheEntry)
+		}
+	}
+	c.entries[key] = cacheEntry{foods: foods, expires: now.Add(c.TTL)}
+}
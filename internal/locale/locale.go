@@ -0,0 +1,175 @@
+// Package locale derives date, number and unit formatting hints from a BCP 47
+// locale, so every client renders dates and measurements the same way.
+//
+// The tables cover the regions' usual conventions (CLDR), not every variant;
+// unknown regions get ISO dates, a Monday week, metric units and 24-hour time.
+package locale
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Default is used when the user has no locale and the request names none.
+const Default = "en-US"
+
+// Measurement systems
+const (
+	MeasurementMetric = "metric"
+	MeasurementUS     = "us" // Pounds, inches, Fahrenheit
+	MeasurementUK     = "uk" // Metric with stones, miles and pints
+)
+
+// Formatting holds the hints clients render with. Date and time patterns use
+// Unicode (CLDR) pattern letters.
+type Formatting struct {
+	DateFormat        string `json:"dateFormat"` // e.g. MM/dd/yyyy
+	TimeFormat        string `json:"timeFormat"` // h:mm a or HH:mm
+	FirstDayOfWeek    string `json:"firstDayOfWeek"`
+	MeasurementSystem string `json:"measurementSystem"` // metric, us or uk
+	TemperatureUnit   string `json:"temperatureUnit"`   // celsius or fahrenheit
+	DecimalSeparator  string `json:"decimalSeparator"`
+	GroupSeparator    string `json:"groupSeparator"`
+}
+
+// Overrides replace derived hints with the user's own choices. Empty fields
+// keep the derived value.
+type Overrides struct {
+	MeasurementSystem string
+	FirstDayOfWeek    string
+}
+
+var dateFormats = map[string]string{
+	"US": "MM/dd/yyyy", "PH": "MM/dd/yyyy", "PR": "MM/dd/yyyy",
+	"CA": "yyyy-MM-dd", "SE": "yyyy-MM-dd", "LT": "yyyy-MM-dd",
+	"JP": "yyyy/MM/dd", "CN": "yyyy/MM/dd", "TW": "yyyy/MM/dd", "ZA": "yyyy/MM/dd",
+	"KR": "yyyy. MM. dd.", "HU": "yyyy. MM. dd.",
+	"NL": "dd-MM-yyyy",
+	"DE": "dd.MM.yyyy", "AT": "dd.MM.yyyy", "CH": "dd.MM.yyyy", "RU": "dd.MM.yyyy", "PL": "dd.MM.yyyy",
+	"NO": "dd.MM.yyyy", "FI": "dd.MM.yyyy", "DK": "dd.MM.yyyy", "CZ": "dd.MM.yyyy", "SK": "dd.MM.yyyy",
+	"TR": "dd.MM.yyyy", "UA": "dd.MM.yyyy", "RO": "dd.MM.yyyy", "BG": "dd.MM.yyyy", "HR": "dd.MM.yyyy",
+	"SI": "dd.MM.yyyy", "RS": "dd.MM.yyyy", "EE": "dd.MM.yyyy", "LV": "dd.MM.yyyy", "IS": "dd.MM.yyyy",
+	"BY": "dd.MM.yyyy", "KZ": "dd.MM.yyyy",
+}
+
+// Everyone else writes day, month, year with slashes
+const defaultDateFormat = "dd/MM/yyyy"
+
+// Regions where 12-hour time is the norm
+var twelveHour = regionSet("US CA AU NZ IN PH PK BD EG SA AE MY KR TW CO")
+
+// Regions whose week starts on Sunday or Saturday; the rest start on Monday
+var (
+	sundayFirst   = regionSet("AG AS BD BR BS BT BW BZ CA CN CO DM DO ET GT GU HK HN ID IL IN JM JP KE KH KR LA MH MM MO MT MX MZ NI NP PA PE PH PK PR PT PY SA SG SV TH TT TW UM US VE VI WS YE ZA ZW")
+	saturdayFirst = regionSet("AE AF BH DJ DZ EG IQ IR JO KW LY OM QA SD SY")
+)
+
+var (
+	usMeasurement = regionSet("US LR MM")
+	ukMeasurement = regionSet("GB")
+	fahrenheit    = regionSet("US BS BZ KY PR PW LR FM MH")
+)
+
+// Regions writing 1.234,5 and 1 234,5 (with a no-break space); the rest
+// write 1,234.5
+var (
+	commaDecimalDotGroup   = regionSet("DE ES IT NL BR AR CO CL ID TR DK GR VN AT BE UY PY EC RS HR SI RO")
+	commaDecimalSpaceGroup = regionSet("FR RU PL SE NO FI CZ SK UA HU PT BG LT LV EE ZA BY KZ")
+)
+
+func regionSet(codes string) map[string]bool {
+	set := make(map[string]bool)
+	for _, code := range strings.Fields(codes) {
+		set[code] = true
+	}
+	return set
+}
+
+// Parse validates and canonicalizes a BCP 47 locale such as en-GB or pt-BR.
+func Parse(s string) (string, error) {
+	tag, err := language.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	return tag.String(), nil
+}
+
+// FromRequest returns the preferred locale of the Accept-Language header, or
+// "" if it names none.
+func FromRequest(r *http.Request) string {
+	tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil || len(tags) == 0 || tags[0] == language.Und {
+		return ""
+	}
+	return tags[0].String()
+}
+
+// For derives the formatting hints of a locale. A locale without a region
+// uses the language's most likely one (de: Germany, en: United States).
+// Invalid locales fall back to Default.
+func For(locale string, o Overrides) Formatting {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		tag = language.MustParse(Default)
+	}
+	base, _ := tag.Base()
+	r, _ := tag.Region()
+	region := r.String()
+
+	f := Formatting{
+		DateFormat:        defaultDateFormat,
+		TimeFormat:        "HH:mm",
+		FirstDayOfWeek:    "monday",
+		MeasurementSystem: MeasurementMetric,
+		TemperatureUnit:   "celsius",
+		DecimalSeparator:  ".",
+		GroupSeparator:    ",",
+	}
+	if df, ok := dateFormats[region]; ok {
+		f.DateFormat = df
+	}
+	if twelveHour[region] {
+		f.TimeFormat = "h:mm a"
+	}
+	switch {
+	case sundayFirst[region]:
+		f.FirstDayOfWeek = "sunday"
+	case saturdayFirst[region]:
+		f.FirstDayOfWeek = "saturday"
+	}
+	switch {
+	case usMeasurement[region]:
+		f.MeasurementSystem = MeasurementUS
+	case ukMeasurement[region]:
+		f.MeasurementSystem = MeasurementUK
+	}
+	if fahrenheit[region] {
+		f.TemperatureUnit = "fahrenheit"
+	}
+	switch {
+	case region == "CH":
+		f.GroupSeparator = "’"
+	case commaDecimalDotGroup[region]:
+		f.DecimalSeparator, f.GroupSeparator = ",", "."
+	case commaDecimalSpaceGroup[region], region == "CA" && base.String() == "fr":
+		f.DecimalSeparator, f.GroupSeparator = ",", "\u00a0"
+	}
+	// French Canada keeps ISO dates but uses 24-hour time
+	if region == "CA" && base.String() == "fr" {
+		f.TimeFormat = "HH:mm"
+	}
+
+	if o.MeasurementSystem != "" {
+		f.MeasurementSystem = o.MeasurementSystem
+		f.TemperatureUnit = "celsius"
+		if o.MeasurementSystem == MeasurementUS {
+			f.TemperatureUnit = "fahrenheit"
+		}
+	}
+	if o.FirstDayOfWeek != "" {
+		f.FirstDayOfWeek = o.FirstDayOfWeek
+	}
+	return f
+}
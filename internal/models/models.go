@@ -5,6 +5,10 @@ import (
 	"database/sql"
 	"encoding/json"
 	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/integrations/nutrition"
+	"github.com/scalecode-solutions/tracker2api/internal/locale"
+	"github.com/scalecode-solutions/tracker2api/internal/pagination"
 )
 
 // Pregnancy represents a pregnancy record.
@@ -34,20 +38,47 @@ type Pregnancy struct {
 	ArchivedAt        sql.NullTime    `db:"archived_at" json:"archivedAt,omitempty"`
 	CreatedAt         time.Time       `db:"created_at" json:"createdAt"`
 	UpdatedAt         time.Time       `db:"updated_at" json:"updatedAt"`
+	SharingSnoozedAt    sql.NullTime    `db:"sharing_snoozed_at" json:"-"`
+	SharingSnoozedUntil sql.NullTime    `db:"sharing_snoozed_until" json:"-"`
+	ProfilePhotoFileID  sql.NullInt64   `db:"profile_photo_file_id" json:"-"` // Served via signed URL
+	Region              string          `db:"region" json:"region"`           // Data residency region, "" = default
+	Demo                bool            `db:"demo" json:"demo"`               // Generated demo data, excluded from stats
+	PartnerRemovalRequestedAt sql.NullTime   `db:"partner_removal_requested_at" json:"-"`
+	PartnerRemovalRequestedBy sql.NullString `db:"partner_removal_requested_by" json:"-"`
+	TombstonesCompactedBefore sql.NullTime   `db:"tombstones_compacted_before" json:"-"` // Entries deleted earlier may be gone
+	SyncVersion               int64          `db:"sync_version" json:"-"`                // Bumped by every entry, setting, birth plan and profile write
+	ProfileVersion            int64          `db:"profile_version" json:"-"`             // Sync version of the last profile change
 }
 
 // Entry represents a generic entry record.
 type Entry struct {
-	ID          int64           `db:"id" json:"id"`
-	PregnancyID int64           `db:"pregnancy_id" json:"-"`
-	ClientID    string          `db:"client_id" json:"clientId"`
-	EntryType   string          `db:"entry_type" json:"entryType"`
-	Data        json.RawMessage `db:"data" json:"data"`
-	CreatedAt   time.Time       `db:"created_at" json:"createdAt"`
-	UpdatedAt   time.Time       `db:"updated_at" json:"updatedAt"`
-	DeletedAt   sql.NullTime    `db:"deleted_at" json:"deletedAt,omitempty"`
+	ID                  int64           `db:"id" json:"id"`
+	PregnancyID         int64           `db:"pregnancy_id" json:"-"`
+	ClientID            string          `db:"client_id" json:"clientId"`
+	EntryType           string          `db:"entry_type" json:"entryType"`
+	Data                json.RawMessage `db:"data" json:"data"`
+	CreatedAt           time.Time       `db:"created_at" json:"createdAt"`
+	UpdatedAt           time.Time       `db:"updated_at" json:"updatedAt"`
+	DeletedAt           sql.NullTime    `db:"deleted_at" json:"deletedAt,omitempty"`
+	ScheduledFor        sql.NullTime    `db:"scheduled_for" json:"scheduledFor,omitempty"`
+	Status              sql.NullString  `db:"status" json:"status,omitempty"` // planned/completed/missed for scheduled entries
+	DataVersion         int             `db:"data_version" json:"dataVersion"`
+	Backfilled          bool            `db:"backfilled" json:"backfilled,omitempty"`  // Imported after the fact
+	OccurredAt          sql.NullTime    `db:"occurred_at" json:"occurredAt,omitempty"` // Client time of the event, if sent
+	Clock               json.RawMessage `db:"clock" json:"-"`                          // Sync v2 vector clock; null until written with one
+	Visibility          string          `db:"visibility" json:"visibility"`            // shared, partner or private
+	VisibilityChangedAt sql.NullTime    `db:"visibility_changed_at" json:"-"`          // Last change of an existing entry's visibility
+	SyncVersion         int64           `db:"sync_version" json:"-"`                   // Pregnancy sync version of the last change
+	EntryAt             time.Time       `db:"entry_at" json:"-"`                       // occurredAt, else the payload date, else createdAt
 }
 
+// Entry visibility levels
+const (
+	VisibilityShared  = "shared"  // Everyone with access
+	VisibilityPartner = "partner" // Owner, coowner and partner
+	VisibilityPrivate = "private" // Owner and coowner only
+)
+
 // Setting represents a user setting.
 type Setting struct {
 	ID          int64           `db:"id" json:"id"`
@@ -55,6 +86,9 @@ type Setting struct {
 	SettingType string          `db:"setting_type" json:"settingType"`
 	Data        json.RawMessage `db:"data" json:"data"`
 	UpdatedAt   time.Time       `db:"updated_at" json:"updatedAt"`
+	Version     int64           `db:"version" json:"version"`
+	DeletedAt   sql.NullTime    `db:"deleted_at" json:"deletedAt,omitempty"`
+	SyncVersion int64           `db:"sync_version" json:"-"`
 }
 
 // PairingRequest represents a partner pairing request.
@@ -82,6 +116,22 @@ type File struct {
 	Metadata    json.RawMessage `db:"metadata" json:"metadata,omitempty"`
 	CreatedAt   time.Time       `db:"created_at" json:"createdAt"`
 	DeletedAt   sql.NullTime    `db:"deleted_at" json:"deletedAt,omitempty"`
+
+	// Moderation of shared images; status is null when the file was not reviewed
+	ModerationStatus sql.NullString `db:"moderation_status" json:"moderationStatus,omitempty"`
+	ModerationReason sql.NullString `db:"moderation_reason" json:"moderationReason,omitempty"`
+	ModeratedAt      sql.NullTime   `db:"moderated_at" json:"moderatedAt,omitempty"`
+
+	Region string `db:"region" json:"region,omitempty"` // Storage region the file was uploaded to
+
+	// Storage tier: hot, or cold for archived pregnancies (slower to read)
+	StorageTier string       `db:"storage_tier" json:"storageTier"`
+	TieredAt    sql.NullTime `db:"tiered_at" json:"tieredAt,omitempty"`
+
+	// Hex SHA-256 of the content; null for files uploaded before hashing
+	ContentHash sql.NullString `db:"content_hash" json:"contentHash,omitempty"`
+
+	AltText string `db:"alt_text" json:"altText"` // Screen reader description; empty if none was written
 }
 
 // SyncState represents sync state per device.
@@ -106,13 +156,15 @@ type PregnancyRequest struct {
 	MomBirthday       *string `json:"momBirthday,omitempty"`
 	Gender            *string `json:"gender,omitempty"`
 	ParentRole        *string `json:"parentRole,omitempty"`
+	Region            *string `json:"region,omitempty"` // Only honoured on create
 }
 
 // PregnancyResponse is the response for pregnancy endpoints.
 type PregnancyResponse struct {
-	Pregnancy  *PregnancyDTO `json:"pregnancy"`
-	Role       string        `json:"role"`
-	Permission string        `json:"permission"`
+	Pregnancy  *PregnancyDTO    `json:"pregnancy"`
+	Role       string           `json:"role"`
+	Permission string           `json:"permission"`
+	FollowUp   *OutcomeFollowUp `json:"followUp,omitempty"` // Details still to record for the outcome
 }
 
 // PregnancyDTO is the data transfer object for pregnancy.
@@ -135,30 +187,71 @@ type PregnancyDTO struct {
 	OutcomeDate       *string `json:"outcomeDate,omitempty"`
 	Archived          bool    `json:"archived"`
 	ArchivedAt        *string `json:"archivedAt,omitempty"`
+	Region            string  `json:"region,omitempty"`
+	Demo              bool    `json:"demo,omitempty"`
+	Version           string  `json:"version"` // Changes with every profile write; see InvalidationHint
 }
 
 // EntryRequest is the request body for creating an entry.
 type EntryRequest struct {
-	ClientID  string          `json:"clientId"`
-	EntryType string          `json:"entryType"`
-	Data      json.RawMessage `json:"data"`
+	ClientID     string          `json:"clientId"`
+	EntryType    string          `json:"entryType"`
+	Data         json.RawMessage `json:"data"`
+	ScheduledFor *string         `json:"scheduledFor,omitempty"` // RFC3339; marks a future/scheduled entry
+	Status       *string         `json:"status,omitempty"`       // planned/completed/missed; omit both to keep the schedule
+	Unschedule   bool            `json:"unschedule,omitempty"`   // Clears scheduledFor and status
+	DataVersion  *int            `json:"dataVersion,omitempty"`  // Payload shape version, default 1
+	OccurredAt   *string         `json:"occurredAt,omitempty"`   // RFC3339 with offset; when it happened on the client
+	UpdatedAt    *string         `json:"updatedAt,omitempty"`    // POST /api/sync: server updatedAt the edit is based on
+	Visibility   *string         `json:"visibility,omitempty"`   // shared, partner or private; omit to keep (new entries: shared)
 }
 
 // BatchEntryRequest is the request body for batch creating entries.
 type BatchEntryRequest struct {
-	Entries []EntryRequest `json:"entries"`
+	Entries         []EntryRequest `json:"entries"`
+	ContinueOnError bool           `json:"continueOnError"` // Best effort: save valid entries, report the rest
+}
+
+// Batch item result statuses
+const (
+	BatchItemCreated = "created"
+	BatchItemUpdated = "updated"
+	BatchItemFailed  = "failed"
+	BatchItemSkipped = "skipped" // Not attempted because another item failed
+
+	BatchItemDuplicate = "duplicate" // Backfill only: the entry already exists and was left as is
+)
+
+// BatchEntryResult reports what happened to one item of a batch.
+type BatchEntryResult struct {
+	Index     int    `json:"index"`
+	ClientID  string `json:"clientId"`
+	EntryType string `json:"entryType"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+	Entry     *Entry `json:"entry,omitempty"`
+}
+
+// BatchEntriesResponse is the response for batch entry creation.
+type BatchEntriesResponse struct {
+	Entries     []Entry            `json:"entries"` // Saved entries, in request order
+	Results     []BatchEntryResult `json:"results"`
+	SyncVersion int64              `json:"syncVersion"`
 }
 
 // EntriesResponse is the response for entries endpoints.
 type EntriesResponse struct {
-	Entries     []Entry `json:"entries"`
-	SyncVersion int64   `json:"syncVersion"`
+	Entries      []Entry `json:"entries"`
+	SyncVersion  int64   `json:"syncVersion"`
+	Snoozed      bool    `json:"snoozed,omitempty"`
+	SnoozedUntil string  `json:"snoozedUntil,omitempty"`
 }
 
 // PairingRequestBody is the request body for creating a pairing request.
 type PairingRequestBody struct {
 	TargetEmail   string `json:"targetEmail"`
 	RequesterName string `json:"requesterName"`
+	CaptchaToken  string `json:"captchaToken,omitempty"` // Required once CAPTCHA_REQUIRED was returned
 }
 
 // ApprovalRequest is the request body for approving a pairing request.
@@ -173,15 +266,19 @@ type PermissionRequest struct {
 
 // PairingStatusResponse is the response for pairing status.
 type PairingStatusResponse struct {
-	Paired  bool         `json:"paired"`
-	Partner *PartnerInfo `json:"partner,omitempty"`
-	Role    string       `json:"role"`
+	Paired         bool         `json:"paired"`
+	Partner        *PartnerInfo `json:"partner,omitempty"`
+	Role           string       `json:"role"`
+	RemovalPending bool         `json:"removalPending,omitempty"` // Removal requested; partner access is suspended
+	RemovalAt      *string      `json:"removalAt,omitempty"`      // When the pairing is removed for good
+	CanUndoRemoval bool         `json:"canUndoRemoval,omitempty"` // Only the user who asked may undo
 }
 
 // PartnerInfo contains partner information.
 type PartnerInfo struct {
 	ID                 string `json:"id"`
 	Name               string `json:"name,omitempty"`
+	AvatarURL          string `json:"avatarUrl,omitempty"`
 	Permission         string `json:"permission"`
 	PairedAt           string `json:"pairedAt"`
 	DisplayPartnerCard bool   `json:"displayPartnerCard"`
@@ -189,22 +286,46 @@ type PartnerInfo struct {
 
 // SyncRequest is the request body for posting sync data.
 type SyncRequest struct {
-	DeviceID        string           `json:"deviceId"`
-	LastSyncVersion int64            `json:"lastSyncVersion"`
-	Pregnancy       *PregnancyRequest `json:"pregnancy,omitempty"`
-	Entries         []EntryRequest   `json:"entries,omitempty"`
+	DeviceID        string                     `json:"deviceId"`
+	LastSyncVersion int64                      `json:"lastSyncVersion"`
+	Pregnancy       *PregnancyRequest          `json:"pregnancy,omitempty"`
+	Entries         []EntryRequest             `json:"entries,omitempty"`
 	Settings        map[string]json.RawMessage `json:"settings,omitempty"`
-	DeletedEntries  []string         `json:"deletedEntries,omitempty"`
+	SettingsPatch   map[string]SettingPatch    `json:"settingsPatch,omitempty"`
+	DeletedEntries  []string                   `json:"deletedEntries,omitempty"`
+	DryRun          bool                       `json:"dryRun,omitempty"` // Report what would change, commit nothing
+}
+
+// SettingPatch is a JSON merge patch (RFC 7386) against a known setting version.
+type SettingPatch struct {
+	BaseVersion int64           `json:"baseVersion"`
+	Patch       json.RawMessage `json:"patch"`
+}
+
+// SyncConflict describes a pushed change the server could not apply as-is.
+type SyncConflict struct {
+	Kind          string          `json:"kind"` // "setting" or "entry"
+	Key           string          `json:"key"`  // Setting type or entry clientId
+	EntryType     string          `json:"entryType,omitempty"`
+	ServerVersion int64           `json:"serverVersion,omitempty"` // For entries, the sync version of their last change
+	ServerData    json.RawMessage `json:"serverData,omitempty"`
+	ServerEntry   *Entry          `json:"serverEntry,omitempty"` // Stored entry, with deletedAt if it was deleted
 }
 
 // SyncResponse is the response for sync endpoints.
 type SyncResponse struct {
-	Pregnancy   *PregnancyDTO                 `json:"pregnancy,omitempty"`
-	Entries     map[string][]Entry            `json:"entries,omitempty"`
-	Settings    map[string]json.RawMessage    `json:"settings,omitempty"`
-	Files       []File                        `json:"files,omitempty"`
-	SyncVersion int64                         `json:"syncVersion"`
-	ServerTime  string                        `json:"serverTime"`
+	Pregnancy        *PregnancyDTO              `json:"pregnancy,omitempty"`
+	Entries          map[string][]Entry         `json:"entries,omitempty"`
+	Settings         map[string]json.RawMessage `json:"settings,omitempty"`
+	SettingVersions  map[string]int64           `json:"settingVersions,omitempty"`
+	SettingRevisions map[string]int64           `json:"settingRevisions,omitempty"` // Current revision ID of each setting
+	EntrySummaries   map[string]EntrySummary    `json:"entrySummaries,omitempty"`   // Journal post summaries by clientId
+	Files            []File                     `json:"files,omitempty"`
+	SyncVersion      int64                      `json:"syncVersion"`
+	ServerTime       string                     `json:"serverTime"`
+	Snoozed          bool                       `json:"snoozed,omitempty"` // Owner paused sharing; entries/settings withheld
+	SnoozedUntil     string                     `json:"snoozedUntil,omitempty"`
+	CompactedBefore  string                     `json:"compactedBefore,omitempty"` // Tombstones older than this are gone; resync in full if the last sync was earlier
 }
 
 // ErrorResponse is the standard error response.
@@ -214,8 +335,9 @@ type ErrorResponse struct {
 
 // ErrorDetail contains error details.
 type ErrorDetail struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Constraint string `json:"constraint,omitempty"` // Violated database constraint, for DUPLICATE and similar codes
 }
 
 // OutcomeRequest is the request body for setting pregnancy outcome.
@@ -247,9 +369,10 @@ type PregnanciesResponse struct {
 type UserRole string
 
 const (
-	UserRoleOwner   UserRole = "owner"
-	UserRoleFather  UserRole = "father"
-	UserRoleSupport UserRole = "support"
+	UserRoleOwner    UserRole = "owner"
+	UserRoleFather   UserRole = "father"
+	UserRoleSupport  UserRole = "support"
+	UserRoleProvider UserRole = "provider"
 )
 
 // InviteCode represents a sharing invite code.
@@ -265,6 +388,7 @@ type InviteCode struct {
 	RedeemedAt  sql.NullTime   `db:"redeemed_at" json:"redeemedAt,omitempty"`
 	RedeemedBy  sql.NullString `db:"redeemed_by" json:"redeemedBy,omitempty"`
 	RevokedAt   sql.NullTime   `db:"revoked_at" json:"revokedAt,omitempty"`
+	Message     sql.NullString `db:"message" json:"message,omitempty"` // Owner's welcome for whoever redeems it
 }
 
 // Supporter represents a support user with limited access.
@@ -278,21 +402,14 @@ type Supporter struct {
 	InvitedViaCodeID   sql.NullInt64  `db:"invited_via_code_id" json:"-"`
 	RemovedAt          sql.NullTime   `db:"removed_at" json:"removedAt,omitempty"`
 	DisplayPartnerCard sql.NullBool   `db:"display_partner_card" json:"displayPartnerCard,omitempty"`
-}
-
-// CodeAttempt represents a code redemption attempt for rate limiting.
-type CodeAttempt struct {
-	ID          int64          `db:"id"`
-	UserID      string         `db:"user_id"`
-	AttemptedAt time.Time      `db:"attempted_at"`
-	Success     bool           `db:"success"`
-	IPAddress   sql.NullString `db:"ip_address"`
+	ShareActivity      bool           `db:"share_activity" json:"-"`
 }
 
 // GenerateCodeRequest is the request body for generating an invite code.
 type GenerateCodeRequest struct {
-	Role       string `json:"role"`                 // "father" or "support"
+	Role       string `json:"role"`                 // "father", "support" or "provider"
 	Permission string `json:"permission,omitempty"` // "read" or "write" (default: read)
+	Message    string `json:"message,omitempty"`    // Welcome shown on preview and redemption
 }
 
 // GenerateCodeResponse is the response after generating a code.
@@ -300,6 +417,50 @@ type GenerateCodeResponse struct {
 	Code      string    `json:"code"`      // Full code: XXXX-XXXX-XX
 	ExpiresAt time.Time `json:"expiresAt"`
 	Role      string    `json:"role"`
+	Message   string    `json:"message,omitempty"` // As stored, after sanitizing
+}
+
+// Invite batch statuses, per invitee
+const (
+	InviteSent    = "sent"     // Code created and handed to the mail or SMS relay
+	InviteNotSent = "not_sent" // Code created, delivery not configured; share it yourself
+	InviteFailed  = "failed"   // Code created, delivery failed; share it yourself or revoke it
+	InviteInvalid = "invalid"  // Nothing created, see error
+)
+
+// Invitee is one person in an invite batch.
+type Invitee struct {
+	Name       string `json:"name"`                 // Used to greet them
+	Role       string `json:"role"`                 // "father", "support" or "provider"
+	Permission string `json:"permission,omitempty"` // "read" or "write" (default: read)
+	Channel    string `json:"channel"`              // "email" or "sms"
+	To         string `json:"to"`                   // Email address or phone number in international format
+}
+
+// InviteBatchRequest invites several people at once. Message is the welcome
+// every code carries.
+type InviteBatchRequest struct {
+	Invitees []Invitee `json:"invitees"`
+	Message  string    `json:"message,omitempty"`
+}
+
+// InviteBatchResult is the outcome for one invitee, in request order.
+type InviteBatchResult struct {
+	Name      string     `json:"name"`
+	Channel   string     `json:"channel"`
+	To        string     `json:"to"`
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	CodeID    int64      `json:"codeId,omitempty"` // For /sharing/codes/{codeId}/revoke
+	Code      string     `json:"code,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// InviteBatchResponse is the response to an invite batch.
+type InviteBatchResponse struct {
+	Results []InviteBatchResult `json:"results"`
+	Created int                 `json:"created"` // Codes created
+	Sent    int                 `json:"sent"`    // Codes delivered
 }
 
 // RedeemCodeRequest is the request body for redeeming a code.
@@ -318,6 +479,22 @@ type RedeemCodeResponse struct {
 	MomName    string        `json:"momName"`
 	BabyName   string        `json:"babyName"`
 	DueDate    string        `json:"dueDate,omitempty"`
+	Message    string        `json:"message,omitempty"` // Owner's welcome message
+}
+
+// PreviewCodeRequest is the request body for previewing a code before redeeming it.
+type PreviewCodeRequest struct {
+	Code string `json:"code"` // Full code: XXXX-XXXX-XX
+}
+
+// PreviewCodeResponse describes what redeeming a code would connect to.
+type PreviewCodeResponse struct {
+	Role       string    `json:"role"`
+	Permission string    `json:"permission"`
+	MomName    string    `json:"momName"`
+	BabyName   string    `json:"babyName"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	Message    string    `json:"message,omitempty"` // Owner's welcome message
 }
 
 // SupporterInfo contains supporter information for display.
@@ -325,8 +502,13 @@ type SupporterInfo struct {
 	ID                 int64  `json:"id"`
 	UserID             string `json:"userId"`
 	DisplayName        string `json:"displayName"`
+	AvatarURL          string `json:"avatarUrl,omitempty"`
 	JoinedAt           string `json:"joinedAt"`
 	DisplayPartnerCard bool   `json:"displayPartnerCard"`
+	// Engagement from the access log; omitted when the supporter opted out
+	ActivityShared bool    `json:"activityShared"`
+	LastViewedAt   *string `json:"lastViewedAt,omitempty"`
+	ViewCount      *int64  `json:"viewCount,omitempty"`
 }
 
 // ActiveCodeInfo contains active invite code information for display.
@@ -340,9 +522,17 @@ type ActiveCodeInfo struct {
 
 // SharingStatus is the response for sharing status endpoint.
 type SharingStatus struct {
-	Partner     *PartnerInfo     `json:"partner,omitempty"`
-	Supporters  []SupporterInfo  `json:"supporters"`
-	ActiveCodes []ActiveCodeInfo `json:"activeCodes"`
+	Partner      *PartnerInfo     `json:"partner,omitempty"`
+	Supporters   []SupporterInfo  `json:"supporters"`
+	Providers    []ProviderInfo   `json:"providers"`
+	ActiveCodes  []ActiveCodeInfo `json:"activeCodes"`
+	SnoozedUntil *string          `json:"snoozedUntil,omitempty"`
+	ActivityNote string           `json:"activityNote"` // How supporter engagement is measured and who can hide it
+}
+
+// SharingSnoozeRequest pauses partner/supporter visibility.
+type SharingSnoozeRequest struct {
+	Hours int `json:"hours"` // 1 to 720 (30 days)
 }
 
 // MyRoleResponse is the response for the /api/me/role endpoint.
@@ -351,3 +541,1990 @@ type MyRoleResponse struct {
 	Permission string        `json:"permission"` // "read" or "write"
 	Pregnancy  *PregnancyDTO `json:"pregnancy,omitempty"`
 }
+
+// CapabilitiesResponse is the response for GET /api/me/capabilities.
+type CapabilitiesResponse struct {
+	Role         string          `json:"role"`       // owner, coowner, father, support, provider or ""
+	Permission   string          `json:"permission"` // read or write on the pregnancy; "" without access
+	PregnancyID  *int64          `json:"pregnancyId,omitempty"`
+	Capabilities map[string]bool `json:"capabilities"` // canEditPregnancy, canUploadFiles, ...
+	EntryTypes   map[string]bool `json:"entryTypes"`   // Write access per entry type
+	Routes       []string        `json:"routes"`       // "METHOD /api/path" the credentials may call; pregnancy roles still apply
+}
+
+// ============ Duplicate Entry Models ============
+
+// DuplicateGroup is a set of entries that look like the same logical entry.
+type DuplicateGroup struct {
+	EntryType string  `json:"entryType"`
+	Reason    string  `json:"reason"` // "identical_payload" or "near_identical_payload", both with the same timestamp
+	Entries   []Entry `json:"entries"`
+}
+
+// DuplicatesResponse is the response for the duplicate review endpoint.
+type DuplicatesResponse struct {
+	Groups []DuplicateGroup `json:"groups"`
+}
+
+// MergeDuplicatesRequest is the request body for merging duplicate entries.
+type MergeDuplicatesRequest struct {
+	EntryType      string   `json:"entryType"`
+	KeepClientID   string   `json:"keepClientId"`
+	MergeClientIDs []string `json:"mergeClientIds"`
+}
+
+// ============ Sync Diff Models ============
+
+// SyncDiffRequest is the request body for manifest-based sync reconciliation.
+type SyncDiffRequest struct {
+	DeviceID string            `json:"deviceId"`
+	Manifest map[string]string `json:"manifest"` // clientId -> updatedAt (RFC3339)
+}
+
+// SyncDiffResponse lists what the client needs to pull and push.
+type SyncDiffResponse struct {
+	Entries     map[string][]Entry `json:"entries"` // Server entries newer than (or absent from) the manifest
+	Missing     []string           `json:"missing"` // Manifest clientIds the server doesn't have
+	SyncVersion int64              `json:"syncVersion"`
+	ServerTime  string             `json:"serverTime"`
+}
+
+// ============ Export Models ============
+
+// ExportRequest is the request body for generating a pregnancy export.
+type ExportRequest struct {
+	Password         string `json:"password,omitempty"`         // Optional; encrypts the archive, never stored
+	IncludeCareNotes bool   `json:"includeCareNotes,omitempty"` // Owner consent to include care team notes
+	AllowCrossRegion bool   `json:"allowCrossRegion,omitempty"` // Export data outside its residency region
+}
+
+// ============ Supporter Lite Sync Models ============
+
+// WeekProgress is the gestational progress of a pregnancy.
+type WeekProgress struct {
+	Week          int `json:"week"`
+	Day           int `json:"day"`
+	DaysRemaining int `json:"daysRemaining"`
+	Trimester     int `json:"trimester"`
+}
+
+// LiteEntry is a redacted entry for the supporter app.
+type LiteEntry struct {
+	ClientID  string                 `json:"clientId"`
+	Data      map[string]interface{} `json:"data"`
+	AltText   string                 `json:"altText,omitempty"` // Photos: alt text of the referenced file
+	UpdatedAt string                 `json:"updatedAt"`
+}
+
+// LiteSyncResponse is the compact, supporter-safe sync payload.
+type LiteSyncResponse struct {
+	BabyName      string        `json:"babyName,omitempty"`
+	MomName       string        `json:"momName,omitempty"`
+	DueDate       string        `json:"dueDate,omitempty"`
+	Outcome       string        `json:"outcome,omitempty"`
+	Progress      *WeekProgress `json:"progress,omitempty"`
+	Photos        []LiteEntry   `json:"photos"`
+	Milestones    []LiteEntry   `json:"milestones"`
+	Announcements []LiteEntry   `json:"announcements"`
+	ServerTime    string        `json:"serverTime"`
+	Snoozed       bool          `json:"snoozed,omitempty"`
+	SnoozedUntil  string        `json:"snoozedUntil,omitempty"`
+}
+
+// ============ Scheduled Entry Models ============
+
+// Scheduled entry statuses.
+const (
+	EntryStatusPlanned   = "planned"
+	EntryStatusCompleted = "completed"
+	EntryStatusMissed    = "missed"
+)
+
+// EntryStatusRequest is the request body for transitioning a scheduled entry.
+type EntryStatusRequest struct {
+	EntryType string `json:"entryType"`
+	Status    string `json:"status"`
+}
+
+// ============ Care Team Models ============
+
+// CareProvider is a linked care provider (midwife, OB, doula).
+type CareProvider struct {
+	ID               int64          `db:"id" json:"id"`
+	PregnancyID      int64          `db:"pregnancy_id" json:"-"`
+	UserID           string         `db:"user_id" json:"userId"`
+	DisplayName      sql.NullString `db:"display_name" json:"displayName,omitempty"`
+	JoinedAt         time.Time      `db:"joined_at" json:"joinedAt"`
+	InvitedViaCodeID sql.NullInt64  `db:"invited_via_code_id" json:"-"`
+	RemovedAt        sql.NullTime   `db:"removed_at" json:"removedAt,omitempty"`
+}
+
+// CareNote is a note shared only between the owner and linked providers.
+type CareNote struct {
+	ID          int64        `db:"id" json:"id"`
+	PregnancyID int64        `db:"pregnancy_id" json:"-"`
+	AuthorID    string       `db:"author_id" json:"authorId"`
+	AuthorRole  string       `db:"author_role" json:"authorRole"`
+	Body        string       `db:"body" json:"body"`
+	CreatedAt   time.Time    `db:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time    `db:"updated_at" json:"updatedAt"`
+	DeletedAt   sql.NullTime `db:"deleted_at" json:"-"`
+}
+
+// CareNoteRequest is the request body for creating or updating a care note.
+type CareNoteRequest struct {
+	Body string `json:"body"`
+}
+
+// ProviderInfo contains care provider information for display.
+type ProviderInfo struct {
+	ID          int64  `json:"id"`
+	UserID      string `json:"userId"`
+	DisplayName string `json:"displayName"`
+	JoinedAt    string `json:"joinedAt"`
+}
+
+// ============ Notification Models ============
+
+// Notification is an in-app notification for a user.
+type Notification struct {
+	ID          int64           `db:"id" json:"id"`
+	UserID      string          `db:"user_id" json:"-"`
+	PregnancyID sql.NullInt64   `db:"pregnancy_id" json:"-"`
+	Kind        string          `db:"kind" json:"kind"`
+	Payload     json.RawMessage `db:"payload" json:"payload,omitempty"`
+	CreatedAt   time.Time       `db:"created_at" json:"createdAt"`
+	ReadAt      sql.NullTime    `db:"read_at" json:"readAt,omitempty"`
+}
+
+// NotificationTemplate is the push and email copy for a notification kind.
+// {field} placeholders are filled from the notification payload.
+type NotificationTemplate struct {
+	Kind   string                 `json:"kind"`
+	Title  string                 `json:"title"`
+	Body   string                 `json:"body"`
+	Sample map[string]interface{} `json:"sample"` // Payload used when previewing without data
+}
+
+// NotificationPreviewRequest is the request body for previewing a template.
+// Payload and notificationId are optional; without either the sample is used.
+type NotificationPreviewRequest struct {
+	Kind           string          `json:"kind"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	NotificationID *int64          `json:"notificationId,omitempty"` // One of the admin's own notifications
+	Send           bool            `json:"send,omitempty"`           // Also send it to the admin as a test notification
+}
+
+// NotificationPreview is a rendered notification template.
+type NotificationPreview struct {
+	Kind               string          `json:"kind"`
+	Title              string          `json:"title"`
+	Body               string          `json:"body"`
+	Payload            json.RawMessage `json:"payload"`
+	Source             string          `json:"source"`            // sample, payload or notification
+	Missing            []string        `json:"missing,omitempty"` // Placeholders the payload has no value for
+	SentNotificationID int64           `json:"sentNotificationId,omitempty"`
+}
+
+// InvalidationHint tells a client that its cached copy of a resource is older
+// than Version, so it can refetch just that resource instead of syncing.
+type InvalidationHint struct {
+	Resource string `json:"resource"` // pregnancy
+	ID       int64  `json:"id"`
+	Version  string `json:"version"`
+}
+
+// ============ Vitals Import Models ============
+
+// VitalsImportError describes a row that was not imported.
+type VitalsImportError struct {
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
+}
+
+// VitalsImportResponse summarizes a device export import.
+type VitalsImportResponse struct {
+	Vendor     string              `json:"vendor"`
+	Imported   int                 `json:"imported"`
+	Duplicates int                 `json:"duplicates"` // Already imported (same device serial + timestamp)
+	Failed     []VitalsImportError `json:"failed"`
+}
+
+// ============ Security Models ============
+
+// Security event kinds
+const (
+	SecurityEventNewDevice      = "new_device"
+	SecurityEventNewCountry     = "new_country"
+	SecurityEventPairingBlocked = "pairing_blocked" // A pairing request to the owner was screened out as spam
+)
+
+// AccessFingerprint identifies the token, device and location of a request.
+type AccessFingerprint struct {
+	UserID     string
+	TokenHash  string
+	DeviceHash string
+	UserAgent  string
+	Country    string
+}
+
+// SecurityEvent records access to a pregnancy from a new device or country.
+type SecurityEvent struct {
+	ID          int64          `db:"id" json:"id"`
+	PregnancyID int64          `db:"pregnancy_id" json:"-"`
+	UserID      string         `db:"user_id" json:"userId"`
+	Kind        string         `db:"kind" json:"kind"`
+	UserAgent   sql.NullString `db:"user_agent" json:"userAgent,omitempty"`
+	Country     sql.NullString `db:"country" json:"country,omitempty"`
+	Action      string         `db:"action" json:"action"`           // notified, unpaired, blocked
+	Reason      sql.NullString `db:"reason" json:"reason,omitempty"` // Why a pairing request was blocked
+	CreatedAt   time.Time      `db:"created_at" json:"createdAt"`
+}
+
+// SecuritySettingsRequest updates the owner's security preferences.
+type SecuritySettingsRequest struct {
+	RequireRepair bool `json:"requireRepair"` // Unpair partners/supporters seen on a new device
+}
+
+// ============ Timeline Export Models ============
+
+// EntryRevision is an append-only snapshot of an entry taken on every write.
+type EntryRevision struct {
+	ID          int64           `db:"id" json:"revisionId"`
+	EntryID     int64           `db:"entry_id" json:"entryId"`
+	PregnancyID int64           `db:"pregnancy_id" json:"-"`
+	ClientID    string          `db:"client_id" json:"clientId"`
+	EntryType   string          `db:"entry_type" json:"entryType"`
+	Data        json.RawMessage `db:"data" json:"data"`
+	DeletedAt   sql.NullTime    `db:"deleted_at" json:"deletedAt,omitempty"`
+	RecordedAt  time.Time       `db:"recorded_at" json:"recordedAt"`
+	Clock       json.RawMessage `db:"clock" json:"-"`
+	SyncVersion sql.NullInt64   `db:"sync_version" json:"-"` // NULL for revisions recorded before sync versions
+}
+
+// ============ Job Models ============
+
+// Job statuses
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+)
+
+// Job is a background task whose progress clients poll.
+type Job struct {
+	ID          int64           `db:"id" json:"id"`
+	PregnancyID int64           `db:"pregnancy_id" json:"-"`
+	UserID      string          `db:"user_id" json:"-"`
+	Kind        string          `db:"kind" json:"kind"`
+	Status      string          `db:"status" json:"status"`
+	Progress    int             `db:"progress" json:"progress"`
+	Error       sql.NullString  `db:"error" json:"-"`
+	Result      json.RawMessage `db:"result" json:"-"`
+	CreatedAt   time.Time       `db:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time       `db:"updated_at" json:"updatedAt"`
+	CompletedAt sql.NullTime    `db:"completed_at" json:"-"`
+}
+
+// ============ Memory Book Models ============
+
+// MemoryBookRequest selects what goes into a memory book.
+type MemoryBookRequest struct {
+	Title              string   `json:"title"`
+	EntryClientIDs     []string `json:"entryClientIds"` // Journal/photo/milestone entries; empty = all of them
+	IncludeWeeklyFacts bool     `json:"includeWeeklyFacts"`
+	Formats            []string `json:"formats"` // "pdf", "epub"; JSON is always available
+}
+
+// WeeklyFact is one week of data/WeeklyFacts.json.
+type WeeklyFact struct {
+	Week            int      `json:"week"`
+	BabyDevelopment string   `json:"babyDevelopment"`
+	MotherChanges   string   `json:"motherChanges"`
+	Milestones      []string `json:"milestones,omitempty"`
+
+	Sources    []ContentSource `json:"sources,omitempty"`
+	ReviewedBy string          `json:"reviewedBy,omitempty"`
+	ReviewedAt string          `json:"reviewedAt,omitempty"`
+	Disclaimer string          `json:"disclaimer,omitempty"`
+}
+
+// MemoryBookItem is a journal post, photo or milestone in a chapter.
+type MemoryBookItem struct {
+	ClientID string `json:"clientId"`
+	Kind     string `json:"kind"` // journal, photo, milestone
+	Date     string `json:"date,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Text     string `json:"text,omitempty"`
+	FileID   *int64 `json:"fileId,omitempty"`
+}
+
+// MemoryBookChapter groups a week's items.
+type MemoryBookChapter struct {
+	Week  int              `json:"week"` // 0 when the week is unknown
+	Fact  *WeeklyFact      `json:"fact,omitempty"`
+	Items []MemoryBookItem `json:"items"`
+}
+
+// MemoryBook is the compiled, ordered book.
+type MemoryBook struct {
+	Title       string              `json:"title"`
+	BabyName    string              `json:"babyName,omitempty"`
+	GeneratedAt string              `json:"generatedAt"`
+	Chapters    []MemoryBookChapter `json:"chapters"`
+}
+
+// MemoryBookResult is stored on the job when generation finishes.
+type MemoryBookResult struct {
+	Book    *MemoryBook `json:"book"`
+	Formats []string    `json:"formats"`
+}
+
+// MemoryBookJobResponse is returned when polling a memory book job.
+type MemoryBookJobResponse struct {
+	JobID         int64       `json:"jobId"`
+	Status        string      `json:"status"`
+	Progress      int         `json:"progress"`
+	QueuePosition int         `json:"queuePosition,omitempty"` // Place in the user's line while queued
+	Error         string      `json:"error,omitempty"`
+	Formats       []string    `json:"formats,omitempty"` // Downloadable once completed
+	Book          *MemoryBook `json:"book,omitempty"`
+}
+
+// ============ Dashboard Models ============
+
+// Widget kinds the apps know how to render.
+const (
+	WidgetWeightChart      = "weight_chart"
+	WidgetKickHeatmap      = "kick_heatmap"
+	WidgetSymptomFrequency = "symptom_frequency"
+	WidgetWaterIntake      = "water_intake"
+	WidgetContractionChart = "contraction_chart"
+)
+
+// Dashboard is a saved set of widgets shared by everyone with access to the pregnancy.
+type Dashboard struct {
+	ID          int64           `db:"id" json:"id"`
+	PregnancyID int64           `db:"pregnancy_id" json:"-"`
+	CreatedBy   string          `db:"created_by" json:"createdBy"`
+	Name        string          `db:"name" json:"name"`
+	Position    int             `db:"position" json:"position"`
+	Widgets     json.RawMessage `db:"widgets" json:"widgets"`
+	CreatedAt   time.Time       `db:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time       `db:"updated_at" json:"updatedAt"`
+}
+
+// DashboardWidget is one widget and its place on the grid.
+type DashboardWidget struct {
+	ID      string          `json:"id"`
+	Kind    string          `json:"kind"`
+	Title   string          `json:"title,omitempty"`
+	X       int             `json:"x"`
+	Y       int             `json:"y"`
+	W       int             `json:"w"`
+	H       int             `json:"h"`
+	Options json.RawMessage `json:"options,omitempty"` // Kind-specific (range, entry type, ...)
+}
+
+// DashboardRequest creates or replaces a dashboard.
+type DashboardRequest struct {
+	Name     string            `json:"name"`
+	Position *int              `json:"position,omitempty"`
+	Widgets  []DashboardWidget `json:"widgets"`
+}
+
+// ============ Analytics Models ============
+
+// AggregateBucket is one group of an aggregation query. Avg/Min/Max cover the
+// requested numeric field and are null when no entry in the bucket has it.
+type AggregateBucket struct {
+	Bucket     int      `db:"bucket" json:"bucket"` // Hour 0-23, weekday 0-6 (Sunday = 0) or pregnancy week
+	Count      int      `db:"count" json:"count"`
+	Backfilled int      `db:"backfilled" json:"backfilled"` // Of Count, entries imported via backfill
+	Avg        *float64 `db:"avg" json:"avg"`
+	Min        *float64 `db:"min" json:"min"`
+	Max        *float64 `db:"max" json:"max"`
+}
+
+// AggregateResponse is the response for GET /api/analytics/aggregate.
+type AggregateResponse struct {
+	Type     string            `json:"type"`
+	GroupBy  string            `json:"groupBy"`
+	Field    string            `json:"field,omitempty"`
+	Timezone string            `json:"timezone"`
+	Buckets  []AggregateBucket `json:"buckets"`
+}
+
+// WeightReading is one weight entry as stored, before unit conversion.
+type WeightReading struct {
+	At     time.Time `db:"at"`
+	Weight float64   `db:"weight"`
+	Unit   string    `db:"unit"` // As logged; "" counts as kg
+}
+
+// WeightWeek is the weight readings of one pregnancy week.
+type WeightWeek struct {
+	Week  int     `json:"week"`
+	Count int     `json:"count"`
+	Avg   float64 `json:"avg"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+}
+
+// WeightTrend is the least-squares slope of weight over the pregnancy.
+type WeightTrend struct {
+	SlopePerWeek float64 `json:"slopePerWeek"`
+	Direction    string  `json:"direction"` // gaining, stable or losing
+	Readings     int     `json:"readings"`
+}
+
+// WeightPoint is a single weight reading.
+type WeightPoint struct {
+	At     time.Time `json:"at"`
+	Weight float64   `json:"weight"`
+}
+
+// WeightAnalyticsResponse is the response for GET /api/analytics/weight. All
+// weights are in Unit.
+type WeightAnalyticsResponse struct {
+	Unit               string       `json:"unit"` // kg or lb
+	StartDate          string       `json:"startDate"`
+	Weeks              []WeightWeek `json:"weeks"`
+	Trend              *WeightTrend `json:"trend,omitempty"` // Needs two readings at different times
+	Latest             *WeightPoint `json:"latest,omitempty"`
+	PrePregnancyWeight *float64     `json:"prePregnancyWeight,omitempty"`
+	PrePregnancySource string       `json:"prePregnancySource,omitempty"` // query or entry
+	TotalGain          *float64     `json:"totalGain,omitempty"`
+	Skipped            int          `json:"skipped"` // Readings in a unit that isn't kg or lb
+}
+
+// TimedEntry is an entry's type and payload at its logical time.
+type TimedEntry struct {
+	EntryType string          `db:"entry_type"`
+	At        time.Time       `db:"at"`
+	Data      json.RawMessage `db:"data"`
+}
+
+// KickSession is kick entries close enough in time to count as one session.
+type KickSession struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	Entries         int       `json:"entries"`
+	Kicks           int       `json:"kicks"`
+	DurationMinutes float64   `json:"durationMinutes"`
+	KicksPerHour    *float64  `json:"kicksPerHour,omitempty"` // Sessions of a minute or more
+	MinutesToTen    *float64  `json:"minutesToTen,omitempty"` // Sessions with ten kicks or more
+	// ToTenEstimated is set when kick times weren't logged and time to ten
+	// assumes a steady pace over the session
+	ToTenEstimated bool `json:"toTenEstimated,omitempty"`
+}
+
+// KickDay sums the kick sessions that started on one local day.
+type KickDay struct {
+	Date                 string   `json:"date"`
+	Sessions             int      `json:"sessions"`
+	Kicks                int      `json:"kicks"`
+	KicksPerHour         *float64 `json:"kicksPerHour,omitempty"`
+	MinutesToTen         *float64 `json:"minutesToTen,omitempty"` // Mean over the day's sessions
+	BaselineKicksPerHour *float64 `json:"baselineKicksPerHour,omitempty"`
+	BaselineMinutesToTen *float64 `json:"baselineMinutesToTen,omitempty"`
+	Decreased            bool     `json:"decreased"`
+	DecreaseReasons      []string `json:"decreaseReasons,omitempty"` // kicks_per_hour, time_to_ten
+}
+
+// KickAnalyticsResponse is the response for GET /api/analytics/kicks.
+type KickAnalyticsResponse struct {
+	From            string        `json:"from"`
+	To              string        `json:"to"`
+	Timezone        string        `json:"timezone"`
+	Sessions        []KickSession `json:"sessions"`
+	Days            []KickDay     `json:"days"`
+	KicksPerHour    *float64      `json:"kicksPerHour,omitempty"`
+	AvgMinutesToTen *float64      `json:"avgMinutesToTen,omitempty"`
+	DecreasedDays   []string      `json:"decreasedDays"`
+}
+
+// ContractionRule is when to head to the hospital: contractions at most
+// MaxIntervalMinutes apart, start to start, lasting MinDurationSeconds or
+// longer, for SustainMinutes.
+type ContractionRule struct {
+	Name               string  `json:"name"` // e.g. 5-1-1
+	MaxIntervalMinutes float64 `json:"maxIntervalMinutes"`
+	MinDurationSeconds float64 `json:"minDurationSeconds"`
+	SustainMinutes     float64 `json:"sustainMinutes"`
+}
+
+// ContractionRuleChecks is how contractions over the rule's period measure up.
+type ContractionRuleChecks struct {
+	Interval           bool     `json:"interval"`  // Average interval short enough
+	Duration           bool     `json:"duration"`  // Average duration long enough
+	Sustained          bool     `json:"sustained"` // Spanning the whole period, up to now
+	AvgDurationSeconds *float64 `json:"avgDurationSeconds,omitempty"`
+	AvgIntervalMinutes *float64 `json:"avgIntervalMinutes,omitempty"`
+}
+
+// ContractionPoint is one contraction in the window.
+type ContractionPoint struct {
+	Start           time.Time `json:"start"`
+	DurationSeconds *float64  `json:"durationSeconds,omitempty"` // Unset when only the start was logged
+	IntervalMinutes *float64  `json:"intervalMinutes,omitempty"` // Since the previous contraction's start
+}
+
+// ContractionAnalyticsResponse is the response for GET /api/analytics/contractions.
+type ContractionAnalyticsResponse struct {
+	WindowMinutes      int                   `json:"windowMinutes"`
+	WindowStart        time.Time             `json:"windowStart"`
+	Count              int                   `json:"count"`
+	AvgDurationSeconds *float64              `json:"avgDurationSeconds,omitempty"`
+	AvgIntervalMinutes *float64              `json:"avgIntervalMinutes,omitempty"`
+	Contractions       []ContractionPoint    `json:"contractions"`
+	LastContractionAt  *time.Time            `json:"lastContractionAt,omitempty"`
+	Rule               ContractionRule       `json:"rule"`
+	Checks             ContractionRuleChecks `json:"checks"`
+	RuleMet            bool                  `json:"ruleMet"`
+}
+
+// MealServings is the servings of one food logged as meals on one day.
+type MealServings struct {
+	Day      string  `db:"day"`
+	FoodID   string  `db:"food_id"` // Empty for meals without a foodId
+	Meals    int     `db:"meals"`
+	Servings float64 `db:"servings"`
+}
+
+// NutritionDay is the rough nutrient total of one day's meals.
+type NutritionDay struct {
+	Date         string              `json:"date"`
+	Meals        int                 `json:"meals"`
+	UnknownMeals int                 `json:"unknownMeals"` // Meals whose food has no nutrients
+	Nutrients    nutrition.Nutrients `json:"nutrients"`
+}
+
+// NutritionTotalsResponse is the response for GET /api/analytics/nutrition.
+type NutritionTotalsResponse struct {
+	From         string              `json:"from"`
+	To           string              `json:"to"`
+	Timezone     string              `json:"timezone"`
+	Days         []NutritionDay      `json:"days"`
+	Totals       nutrition.Nutrients `json:"totals"`
+	UnknownFoods []string            `json:"unknownFoods"` // Food IDs the provider could not resolve
+}
+
+// NutritionSearchResponse is the response for GET /api/nutrition/search.
+type NutritionSearchResponse struct {
+	Query string           `json:"query"`
+	Foods []nutrition.Food `json:"foods"`
+}
+
+// ============ Rate Limit Models ============
+
+// RateLimitBudget describes one request budget and the caller's usage of it.
+type RateLimitBudget struct {
+	Name          string   `json:"name"`
+	Limit         int      `json:"limit"`
+	WindowSeconds int      `json:"windowSeconds"`
+	Routes        []string `json:"routes,omitempty"` // "METHOD /path"; empty for the default budget
+	Remaining     int      `json:"remaining"`
+	Reset         int64    `json:"reset"` // Unix seconds when the current window ends
+}
+
+// LimitsResponse is the response for GET /api/limits.
+type LimitsResponse struct {
+	Enforced bool              `json:"enforced"` // False while limits are advisory
+	Budgets  []RateLimitBudget `json:"budgets"`
+}
+
+// ============ Deprecation Models ============
+
+// DeprecatedUsage is how often one app version called a deprecated route.
+type DeprecatedUsage struct {
+	Route       string    `db:"route" json:"route"`            // "GET /api/pregnancy"
+	AppVersion  string    `db:"app_version" json:"appVersion"` // X-App-Version, "" if not sent
+	Hits        int64     `db:"hits" json:"hits"`
+	Users       int       `db:"users" json:"users"`
+	FirstSeenAt time.Time `db:"first_seen_at" json:"firstSeenAt"`
+	LastSeenAt  time.Time `db:"last_seen_at" json:"lastSeenAt"`
+}
+
+// DeprecationsResponse is the response for GET /api/admin/deprecations.
+type DeprecationsResponse struct {
+	Routes []DeprecatedRoute `json:"routes"`
+	Usage  []DeprecatedUsage `json:"usage"`
+}
+
+// DeprecatedRoute describes a deprecated route and its replacement.
+type DeprecatedRoute struct {
+	Route     string  `json:"route"`
+	Successor string  `json:"successor"`
+	Sunset    *string `json:"sunset,omitempty"` // RFC 3339 date the route will be removed
+}
+
+// ============ Diagnostics Models ============
+
+// RegionDiagnostics summarizes one data residency region.
+type RegionDiagnostics struct {
+	Region      string `db:"region" json:"region"` // "" is the default region
+	Configured  bool   `json:"configured"`         // Has a storage root on this server
+	Pregnancies int    `db:"pregnancies" json:"pregnancies"`
+	Files       int    `db:"files" json:"files"`
+}
+
+// DiagnosticsResponse is the response for GET /api/admin/diagnostics.
+type DiagnosticsResponse struct {
+	ServerRegion   string              `json:"serverRegion"`
+	StorageRegions []string            `json:"storageRegions"`
+	Regions        []RegionDiagnostics `json:"regions"`
+	ServerTime     string              `json:"serverTime"`
+	Database       BreakerStatus       `json:"database"`
+	Backpressure   BackpressureStatus  `json:"backpressure"`
+}
+
+// BreakerStatus reports the database circuit breaker. Calls and Failures cover
+// the last 10 seconds; Opens and Rejected count since the server started.
+type BreakerStatus struct {
+	State       string     `json:"state"` // closed, open or half_open
+	Calls       int        `json:"calls"`
+	Failures    int        `json:"failures"`
+	Opens       int64      `json:"opens"`
+	Rejected    int64      `json:"rejected"` // Requests answered 503 without touching the database
+	OpenedAt    *time.Time `json:"openedAt,omitempty"`
+	RetryAfter  int        `json:"retryAfter,omitempty"` // Seconds until probing starts
+	LastFailure string     `json:"lastFailure,omitempty"`
+	// Failover is only reported when a standby is configured
+	Failover *FailoverStatus `json:"failover,omitempty"`
+}
+
+// FailoverStatus reports which database server is in use. Failovers counts
+// switches since the server started.
+type FailoverStatus struct {
+	Active         string     `json:"active"`        // primary or standby
+	Switching      bool       `json:"switching"`     // Trying to reach the other server
+	ProbeFailures  int        `json:"probeFailures"` // Failed health probes in a row
+	Failovers      int64      `json:"failovers"`
+	LastFailoverAt *time.Time `json:"lastFailoverAt,omitempty"`
+	LastProbeError string     `json:"lastProbeError,omitempty"`
+}
+
+// ReadinessResponse is the response for GET /readyz.
+type ReadinessResponse struct {
+	Status   string        `json:"status"` // ready or unavailable
+	Database BreakerStatus `json:"database"`
+}
+
+// ============ Entry Data Version Models ============
+
+// UnknownDataVersion counts writes of an entry data version the server did not know.
+type UnknownDataVersion struct {
+	EntryType   string    `db:"entry_type" json:"entryType"`
+	DataVersion int       `db:"data_version" json:"dataVersion"`
+	AppVersion  string    `db:"app_version" json:"appVersion"`
+	Hits        int64     `db:"hits" json:"hits"`
+	FirstSeenAt time.Time `db:"first_seen_at" json:"firstSeenAt"`
+	LastSeenAt  time.Time `db:"last_seen_at" json:"lastSeenAt"`
+}
+
+// DataVersionsResponse is the response for GET /api/admin/data-versions.
+type DataVersionsResponse struct {
+	Current map[string]int       `json:"current"` // Entry types above version 1
+	Unknown []UnknownDataVersion `json:"unknown"`
+}
+
+// ============ Personal Access Token Models ============
+
+// Personal access token scopes
+const (
+	TokenScopeRead  = "read"
+	TokenScopeWrite = "write"
+)
+
+// PersonalToken is a user-created API token. The secret is only returned on creation.
+type PersonalToken struct {
+	ID         int64        `db:"id" json:"id"`
+	UserID     string       `db:"user_id" json:"-"`
+	Name       string       `db:"name" json:"name"`
+	TokenHash  string       `db:"token_hash" json:"-"`
+	Prefix     string       `db:"prefix" json:"prefix"`
+	Scope      string       `db:"scope" json:"scope"`
+	CreatedAt  time.Time    `db:"created_at" json:"createdAt"`
+	ExpiresAt  sql.NullTime `db:"expires_at" json:"expiresAt,omitempty"`
+	LastUsedAt sql.NullTime `db:"last_used_at" json:"lastUsedAt,omitempty"`
+	RevokedAt  sql.NullTime `db:"revoked_at" json:"revokedAt,omitempty"`
+}
+
+// PersonalTokenRequest creates a personal access token.
+type PersonalTokenRequest struct {
+	Name          string `json:"name"`
+	Scope         string `json:"scope,omitempty"`         // read (default) or write
+	ExpiresInDays *int   `json:"expiresInDays,omitempty"` // Omit for a token that never expires
+}
+
+// PersonalTokenResponse is returned once when a token is created.
+type PersonalTokenResponse struct {
+	PersonalToken
+	Token string `json:"token"` // Shown only now; store it safely
+}
+
+// ============ Calendar Feed Models ============
+
+// CalendarFeed is a secret URL a calendar app subscribes to for the scheduled
+// entries its user can see.
+type CalendarFeed struct {
+	ID         int64        `db:"id" json:"id"`
+	UserID     string       `db:"user_id" json:"-"`
+	Name       string       `db:"name" json:"name"`
+	TokenHash  string       `db:"token_hash" json:"-"`
+	Prefix     string       `db:"prefix" json:"prefix"`
+	CreatedAt  time.Time    `db:"created_at" json:"createdAt"`
+	LastUsedAt sql.NullTime `db:"last_used_at" json:"lastUsedAt,omitempty"`
+	RevokedAt  sql.NullTime `db:"revoked_at" json:"revokedAt,omitempty"`
+}
+
+// CalendarFeedRequest creates a calendar feed.
+type CalendarFeedRequest struct {
+	Name string `json:"name"`
+}
+
+// CalendarFeedResponse is returned once when a calendar feed is created.
+type CalendarFeedResponse struct {
+	CalendarFeed
+	URL string `json:"url"` // Shown only now; the token in it is the credential
+}
+
+// ============ Impersonation Models ============
+
+// Impersonation is an admin's session acting as a user.
+type Impersonation struct {
+	ID              int64        `db:"id" json:"id"`
+	AdminID         string       `db:"admin_id" json:"adminId"`
+	UserID          string       `db:"user_id" json:"userId"`
+	Reason          string       `db:"reason" json:"reason"`
+	Scope           string       `db:"scope" json:"scope"`
+	TokenHash       string       `db:"token_hash" json:"-"`
+	CreatedAt       time.Time    `db:"created_at" json:"createdAt"`
+	ExpiresAt       time.Time    `db:"expires_at" json:"expiresAt"`
+	EndedAt         sql.NullTime `db:"ended_at" json:"endedAt,omitempty"`
+	OwnerNotifiedAt sql.NullTime `db:"owner_notified_at" json:"ownerNotifiedAt,omitempty"`
+
+	Requests int `db:"requests" json:"requests"` // Requests made in the session, when joined
+}
+
+// ImpersonationRequest starts an impersonation session.
+type ImpersonationRequest struct {
+	UserID  string `json:"userId"`
+	Reason  string `json:"reason"`
+	Scope   string `json:"scope,omitempty"`   // read (default) or write
+	Minutes *int   `json:"minutes,omitempty"` // Session length, default 30, at most 120
+}
+
+// ImpersonationResponse is returned once when a session starts.
+type ImpersonationResponse struct {
+	Impersonation
+	Token string `json:"token"` // Shown only now; expires with the session
+}
+
+// ImpersonatedRequest is one request made during an impersonation session.
+type ImpersonatedRequest struct {
+	ID              int64     `db:"id" json:"id"`
+	ImpersonationID int64     `db:"impersonation_id" json:"-"`
+	Method          string    `db:"method" json:"method"`
+	Path            string    `db:"path" json:"path"`
+	Status          int       `db:"status" json:"status"`
+	CreatedAt       time.Time `db:"created_at" json:"createdAt"`
+}
+
+// ============ Demo Models ============
+
+// DemoRequest is the request body for POST /api/demo/start.
+type DemoRequest struct {
+	Week *int `json:"week,omitempty"` // Gestational week to simulate, default 24
+}
+
+// DemoResponse is the response for POST /api/demo/start.
+type DemoResponse struct {
+	Pregnancy *PregnancyDTO `json:"pregnancy"`
+	Week      int           `json:"week"`
+	Entries   int           `json:"entries"` // Generated entries, including scheduled ones
+}
+
+// ============ Backfill Models ============
+
+// BackfillEntry is one historical entry. CreatedAt is when it originally happened.
+type BackfillEntry struct {
+	EntryRequest
+	CreatedAt string `json:"createdAt"` // RFC3339 or YYYY-MM-DD, must be in the past
+}
+
+// BackfillRequest is the request body for POST /api/entries/backfill.
+type BackfillRequest struct {
+	Entries []BackfillEntry `json:"entries"`
+}
+
+// BackfillSummary is the response for POST /api/entries/backfill. Results list
+// only the items that were not created.
+type BackfillSummary struct {
+	Received   int                `json:"received"`
+	Created    int                `json:"created"`
+	Duplicates int                `json:"duplicates"` // Already present, left untouched
+	Failed     int                `json:"failed"`
+	ByType     map[string]int     `json:"byType"` // Created entries per entry type
+	Earliest   *time.Time         `json:"earliest,omitempty"`
+	Latest     *time.Time         `json:"latest,omitempty"`
+	Results    []BatchEntryResult `json:"results"`
+}
+
+// ============ Storage Tier Models ============
+
+// RestoreFilesResponse reports a job moving a pregnancy's cold files back to hot storage.
+type RestoreFilesResponse struct {
+	JobID         int64  `json:"jobId"`
+	Status        string `json:"status"`
+	Progress      int    `json:"progress"`
+	QueuePosition int    `json:"queuePosition,omitempty"` // Place in the user's line while queued
+	Error         string `json:"error,omitempty"`
+	Restored      int    `json:"restored"` // Set once completed
+	Failed        int    `json:"failed"`
+}
+
+// ============ Sync Snapshot Models ============
+
+// SyncSnapshot is a stored full-dataset sync response for a pregnancy.
+type SyncSnapshot struct {
+	PregnancyID int64     `db:"pregnancy_id" json:"-"`
+	StoragePath string    `db:"storage_path" json:"-"`
+	ETag        string    `db:"etag" json:"etag"`
+	SizeBytes   int64     `db:"size_bytes" json:"sizeBytes"`
+	Entries     int       `db:"entries" json:"entries"`
+	SourceTime  time.Time `db:"source_time" json:"sourceTime"`
+	GeneratedAt time.Time `db:"generated_at" json:"generatedAt"`
+}
+
+// ============ Content Models ============
+
+// Content kinds
+const (
+	ContentWeeklyFact = "weekly_fact"
+	ContentBabySize   = "baby_size"
+)
+
+// Content statuses
+const (
+	ContentDraft     = "draft"
+	ContentPublished = "published"
+	ContentRetired   = "retired" // Previously published, kept as history
+)
+
+// ContentVersion is one version of a week's weekly fact or baby size.
+type ContentVersion struct {
+	ID                      int64           `db:"id" json:"id"`
+	Kind                    string          `db:"kind" json:"kind"`
+	Week                    int             `db:"week" json:"week"`
+	Version                 int             `db:"version" json:"version"`
+	Status                  string          `db:"status" json:"status"`
+	Data                    json.RawMessage `db:"data" json:"data"`
+	Accessibility           json.RawMessage `db:"accessibility" json:"accessibility"` // ContentAccessibility
+	Simplified              json.RawMessage `db:"simplified" json:"simplified"`       // Plain-language overrides of data
+	SimplifiedAccessibility json.RawMessage `db:"simplified_accessibility" json:"simplifiedAccessibility"`
+	Sources                 json.RawMessage `db:"sources" json:"sources"` // []ContentSource
+	ReviewedBy              sql.NullString  `db:"reviewed_by" json:"reviewedBy,omitempty"`
+	ReviewedAt              sql.NullTime    `db:"reviewed_at" json:"reviewedAt,omitempty"`
+	CreatedBy               sql.NullString  `db:"created_by" json:"createdBy,omitempty"`
+	CreatedAt               time.Time       `db:"created_at" json:"createdAt"`
+	UpdatedAt               time.Time       `db:"updated_at" json:"updatedAt"`
+	PublishedAt             sql.NullTime    `db:"published_at" json:"publishedAt,omitempty"`
+}
+
+// ContentRequest creates or edits a draft.
+type ContentRequest struct {
+	Week                    *int                  `json:"week,omitempty"` // Required on create
+	Data                    json.RawMessage       `json:"data"`           // JSON object in the public file's shape
+	Accessibility           *ContentAccessibility `json:"accessibility,omitempty"`
+	Simplified              json.RawMessage       `json:"simplified,omitempty"` // Fields of data rewritten in plain language
+	SimplifiedAccessibility *ContentAccessibility `json:"simplifiedAccessibility,omitempty"`
+	Sources                 []ContentSource       `json:"sources,omitempty"`
+	ReviewedBy              string                `json:"reviewedBy,omitempty"`
+	ReviewedAt              *string               `json:"reviewedAt,omitempty"` // RFC3339; defaults to now when reviewedBy is set
+}
+
+// ContentSource cites where a content item's facts come from.
+type ContentSource struct {
+	Title     string `json:"title"`
+	Publisher string `json:"publisher,omitempty"`
+	URL       string `json:"url,omitempty"` // https only
+}
+
+// ContentReviewRequest records a medical review of a content version. Sources,
+// when given, replace the version's citations.
+type ContentReviewRequest struct {
+	ReviewedBy string           `json:"reviewedBy"`
+	ReviewedAt *string          `json:"reviewedAt,omitempty"` // RFC3339; default now
+	Sources    *[]ContentSource `json:"sources,omitempty"`
+}
+
+// StaleContentReport is the response for GET /api/admin/content/{kind}/stale.
+type StaleContentReport struct {
+	ReviewIntervalDays int              `json:"reviewIntervalDays"`
+	Stale              []ContentVersion `json:"stale"` // Published versions never reviewed or reviewed before the interval, oldest first
+}
+
+// ContentAccessibility describes content for assistive clients. Reading levels
+// are computed when left out.
+type ContentAccessibility struct {
+	ReadingLevel float64 `json:"readingLevel,omitempty"` // US school grade (Flesch-Kincaid)
+	AltText      string  `json:"altText,omitempty"`      // Describes the item's imagery
+	AudioURL     string  `json:"audioUrl,omitempty"`     // Narration of the text
+	Simplified   bool    `json:"simplified,omitempty"`   // Set in responses serving the simplified text
+}
+
+// ContentVariant is an alternative to part of a week's published content, shown
+// to a weighted share of users.
+type ContentVariant struct {
+	ID        int64           `db:"id" json:"id"`
+	Kind      string          `db:"kind" json:"kind"`
+	Week      int             `db:"week" json:"week"`
+	Name      string          `db:"name" json:"name"`
+	Weight    int             `db:"weight" json:"weight"`
+	Data      json.RawMessage `db:"data" json:"data"` // Overrides; {} is the control
+	StartsAt  sql.NullTime    `db:"starts_at" json:"startsAt,omitempty"`
+	EndsAt    sql.NullTime    `db:"ends_at" json:"endsAt,omitempty"`
+	CreatedBy string          `db:"created_by" json:"createdBy"`
+	CreatedAt time.Time       `db:"created_at" json:"createdAt"`
+	UpdatedAt time.Time       `db:"updated_at" json:"updatedAt"`
+	Users     int64           `db:"users" json:"users"` // Exposure counts, filled when listing
+	Views     int64           `db:"views" json:"views"`
+}
+
+// ContentVariantRequest creates or replaces a variant.
+type ContentVariantRequest struct {
+	Name     string          `json:"name"`
+	Weight   *int            `json:"weight,omitempty"` // Default 1
+	Data     json.RawMessage `json:"data"`
+	StartsAt *string         `json:"startsAt,omitempty"` // RFC3339
+	EndsAt   *string         `json:"endsAt,omitempty"`
+}
+
+// ContentItem is one week's content as shown to a user.
+type ContentItem struct {
+	Week      int              `json:"week"`
+	Variant   string           `json:"variant,omitempty"` // Assigned variant, if the week has any running
+	Data      json.RawMessage  `json:"data"`
+	Community []CommunityTopic `json:"community,omitempty"` // Weekly facts only, with a community service configured
+}
+
+// CommunityTopic is a community service topic linked to a week of weekly facts.
+type CommunityTopic struct {
+	ID        int64     `db:"id" json:"-"`
+	Week      int       `db:"week" json:"week"`
+	Service   string    `db:"service" json:"service"`
+	TopicID   string    `db:"topic_id" json:"topicId"`
+	Title     *string   `db:"title" json:"title,omitempty"`
+	Position  int       `db:"position" json:"-"`
+	URL       string    `db:"-" json:"url,omitempty"`                // Deep link, when the service is configured
+	UpdatedBy string    `db:"updated_by" json:"updatedBy,omitempty"` // Admin responses only
+	UpdatedAt time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+// CommunityTopicInput is one topic of a CommunityTopicsRequest.
+type CommunityTopicInput struct {
+	Service string `json:"service"` // Default: the configured service
+	TopicID string `json:"topicId"`
+	Title   string `json:"title"`
+}
+
+// CommunityTopicsRequest replaces the topics of a week.
+type CommunityTopicsRequest struct {
+	Topics []CommunityTopicInput `json:"topics"`
+}
+
+// ============ Profile Models ============
+
+// UserProfile is a cached mvchat2 profile of a partner, supporter or provider.
+type UserProfile struct {
+	UserID           string         `db:"user_id" json:"userId"`
+	DisplayName      sql.NullString `db:"display_name" json:"displayName,omitempty"`
+	AvatarURL        sql.NullString `db:"avatar_url" json:"avatarUrl,omitempty"`
+	ProfileUpdatedAt time.Time      `db:"profile_updated_at" json:"profileUpdatedAt"`
+	SyncedAt         time.Time      `db:"synced_at" json:"syncedAt"`
+}
+
+// ProfileEvent is the body of a profile change webhook from mvchat2. It carries
+// the user's whole public profile; empty fields were cleared.
+type ProfileEvent struct {
+	Type        string `json:"type"` // profile.updated
+	UserID      string `json:"userId"`
+	DisplayName string `json:"displayName"`
+	AvatarURL   string `json:"avatarUrl"`
+	UpdatedAt   string `json:"updatedAt"` // RFC3339; orders events
+}
+
+// ============ Coowner Models ============
+
+// CoownerEvent is a change to a pregnancy's coowner.
+type CoownerEvent struct {
+	ID          int64          `db:"id" json:"id"`
+	PregnancyID int64          `db:"pregnancy_id" json:"-"`
+	CoownerID   string         `db:"coowner_id" json:"coownerId"`
+	CoownerName sql.NullString `db:"coowner_name" json:"coownerName,omitempty"`
+	Action      string         `db:"action" json:"action"` // linked, replaced, removed, left
+	ActorID     string         `db:"actor_id" json:"actorId"`
+	CreatedAt   time.Time      `db:"created_at" json:"createdAt"`
+}
+
+// CoownerAction is an audited write request made under the coowner role.
+type CoownerAction struct {
+	ID          int64     `db:"id" json:"id"`
+	PregnancyID int64     `db:"pregnancy_id" json:"-"`
+	CoownerID   string    `db:"coowner_id" json:"coownerId"`
+	Method      string    `db:"method" json:"method"`
+	Path        string    `db:"path" json:"path"`
+	Entity      string    `db:"entity" json:"entity"` // Resource written, e.g. entries or settings
+	Status      int       `db:"status" json:"status"`
+	CreatedAt   time.Time `db:"created_at" json:"createdAt"`
+}
+
+// AuditFilter narrows the audit log. Zero fields match everything.
+type AuditFilter struct {
+	Entity string
+	Actor  string     // Coowner user ID
+	From   *time.Time // Inclusive
+	To     *time.Time // Exclusive
+}
+
+// AuditExportRequest is the request body for exporting the audit log as CSV.
+type AuditExportRequest struct {
+	Entity string `json:"entity,omitempty"`
+	Actor  string `json:"actor,omitempty"`
+	From   string `json:"from,omitempty"` // RFC3339 or YYYY-MM-DD
+	To     string `json:"to,omitempty"`   // RFC3339, or YYYY-MM-DD for the end of that day
+}
+
+// AuditExportResponse reports an audit log export job.
+type AuditExportResponse struct {
+	JobID         int64  `json:"jobId"`
+	Status        string `json:"status"`
+	Progress      int    `json:"progress"`
+	QueuePosition int    `json:"queuePosition,omitempty"`
+	Rows          int    `json:"rows,omitempty"` // Once completed
+	Error         string `json:"error,omitempty"`
+}
+
+// CoownerStatusResponse is the response for GET /api/pregnancies/{id}/coowner.
+type CoownerStatusResponse struct {
+	Status  string          `json:"status"` // linked or none
+	Coowner *CoownerInfo    `json:"coowner,omitempty"`
+	History []CoownerEvent  `json:"history"`
+	Actions []CoownerAction `json:"actions"` // Most recent first
+}
+
+// CoownerInfo describes the current coowner.
+type CoownerInfo struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name,omitempty"`
+	LinkedAt *string `json:"linkedAt,omitempty"`
+}
+
+// ============ Warning Models ============
+
+// Warning is a non-fatal issue reported with a successful response.
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"` // Request field the warning is about
+}
+
+// ============ File Preview Models ============
+
+// FilePreview tracks the rendering of a file's preview.
+type FilePreview struct {
+	FileID     int64          `db:"file_id" json:"-"`
+	Kind       string         `db:"kind" json:"kind"`     // pdf or video
+	Status     string         `db:"status" json:"status"` // pending, running, ready, failed
+	PreviewDir sql.NullString `db:"preview_dir" json:"-"`
+	HasStream  bool           `db:"has_stream" json:"-"`
+	Error      sql.NullString `db:"error" json:"-"`
+	Attempts   int            `db:"attempts" json:"-"`
+	CreatedAt  time.Time      `db:"created_at" json:"-"`
+	UpdatedAt  time.Time      `db:"updated_at" json:"-"`
+}
+
+// FilePreviewResponse is the response for /api/files/{fileId}/preview.
+type FilePreviewResponse struct {
+	FileID    int64  `json:"fileId"`
+	Kind      string `json:"kind"`
+	Status    string `json:"status"`              // pending, running, ready, failed
+	PosterURL string `json:"posterUrl,omitempty"` // Set when ready
+	StreamURL string `json:"streamUrl,omitempty"` // HLS playlist, videos only
+	Error     string `json:"error,omitempty"`
+}
+
+// ============ Backpressure Models ============
+
+// DatabaseLoad is recent database latency and connection pool use.
+type DatabaseLoad struct {
+	LatencyMs float64 `json:"latencyMs"` // Moving average over recent calls
+	PoolInUse int     `json:"poolInUse"`
+	PoolMax   int     `json:"poolMax"`
+	Waiting   int64   `json:"waitCount"` // Calls that waited for a connection since start
+}
+
+// BackpressureStatus reports sync load shedding. Shed counts since the server started.
+type BackpressureStatus struct {
+	Pressure   float64          `json:"pressure"` // 1 or more means sync requests are being shed
+	InFlight   int64            `json:"inFlight"` // Sync requests being served
+	Database   DatabaseLoad     `json:"database"`
+	Shed       int64            `json:"shed"`
+	ShedBy     map[string]int64 `json:"shedBy"` // By reason: latency, inflight, pool
+	LastShedAt *time.Time       `json:"lastShedAt,omitempty"`
+}
+
+// ============ Sync v2 Models ============
+
+// Outcomes of a sync v2 entry write.
+const (
+	SyncV2Applied  = "applied"  // The client's version is now the server's
+	SyncV2Merged   = "merged"   // Merged with concurrent edits; take the returned entry
+	SyncV2Stale    = "stale"    // The server already has a newer version; take the returned entry
+	SyncV2Conflict = "conflict" // Concurrent edits that could not be merged; nothing was written
+)
+
+// SyncV2Entry is an entry pushed through sync v2 with its vector clock.
+type SyncV2Entry struct {
+	EntryRequest
+	Clock   map[string]int64 `json:"clock"`             // Device ID to edit count, including this device's new edit
+	Deleted bool             `json:"deleted,omitempty"` // The edit deletes the entry
+}
+
+// SyncV2Request is the request body for POST /api/sync/v2.
+type SyncV2Request struct {
+	DeviceID      string                     `json:"deviceId"`
+	Pregnancy     *PregnancyRequest          `json:"pregnancy,omitempty"`
+	Entries       []SyncV2Entry              `json:"entries,omitempty"`
+	Settings      map[string]json.RawMessage `json:"settings,omitempty"`
+	SettingsPatch map[string]SettingPatch    `json:"settingsPatch,omitempty"`
+}
+
+// SyncV2EntryDTO is an entry with its vector clock. Deleted entries are
+// included as tombstones so their clocks keep ordering later edits.
+type SyncV2EntryDTO struct {
+	Entry
+	Clock   map[string]int64 `json:"clock"`
+	Deleted bool             `json:"deleted"`
+}
+
+// SyncV2EntryResult reports what happened to one pushed entry.
+type SyncV2EntryResult struct {
+	ClientID  string          `json:"clientId"`
+	EntryType string          `json:"entryType"`
+	Outcome   string          `json:"outcome"`          // applied, merged, stale, conflict
+	Fields    []string        `json:"fields,omitempty"` // Conflicting payload fields, when known
+	Entry     *SyncV2EntryDTO `json:"entry,omitempty"`  // The server's version after the write
+}
+
+// SyncV2PushResponse is the response for POST /api/sync/v2.
+type SyncV2PushResponse struct {
+	Results         []SyncV2EntryResult `json:"results"`
+	Conflicts       []SyncConflict      `json:"conflicts"` // Settings patches, as in v1
+	SettingVersions map[string]int64    `json:"settingVersions"`
+	SyncVersion     int64               `json:"syncVersion"`
+}
+
+// SyncV2Response is the response for GET /api/sync/v2.
+type SyncV2Response struct {
+	Pregnancy        *PregnancyDTO              `json:"pregnancy,omitempty"`
+	Entries          []SyncV2EntryDTO           `json:"entries"`
+	Settings         map[string]json.RawMessage `json:"settings,omitempty"`
+	SettingVersions  map[string]int64           `json:"settingVersions,omitempty"`
+	SettingRevisions map[string]int64           `json:"settingRevisions,omitempty"`
+	SyncVersion      int64                      `json:"syncVersion"`
+	ServerTime       string                     `json:"serverTime"`
+	Snoozed          bool                       `json:"snoozed,omitempty"`
+	SnoozedUntil     string                     `json:"snoozedUntil,omitempty"`
+}
+
+// ============ Setting Revision Models ============
+
+// SettingRevision is a past state of a setting. DeletedAt is set on revisions
+// that deleted it.
+type SettingRevision struct {
+	ID          int64           `db:"id" json:"revisionId"`
+	PregnancyID int64           `db:"pregnancy_id" json:"-"`
+	SettingType string          `db:"setting_type" json:"settingType"`
+	Version     int64           `db:"version" json:"version"`
+	Data        json.RawMessage `db:"data" json:"data"`
+	DeletedAt   sql.NullTime    `db:"deleted_at" json:"deletedAt,omitempty"`
+	RecordedAt  time.Time       `db:"recorded_at" json:"recordedAt"`
+}
+
+// RevertSettingRequest is the request body for POST /api/settings/{type}/revert.
+type RevertSettingRequest struct {
+	RevisionID int64 `json:"revisionId"`
+}
+
+// ============ Entry Filter Models ============
+
+// EntryFilter restricts entries to those whose payload field compares to Value
+// under Op (eq, lt, lte, gt, gte, has).
+type EntryFilter struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+// EntryFilterBenchmark compares a filter query with and without its expression
+// index, as measured by EXPLAIN ANALYZE.
+type EntryFilterBenchmark struct {
+	EntryType   string   `json:"entryType"`
+	Field       string   `json:"field"`
+	Kind        string   `json:"kind"`
+	Filter      string   `json:"filter"`  // op:value that was timed
+	Entries     int64    `json:"entries"` // Entries of the type in the sampled pregnancy
+	Matched     int64    `json:"matched"`
+	Indexes     []string `json:"indexes"` // Indexes the indexed query used
+	IndexedMs   float64  `json:"indexedMs"`
+	UnindexedMs float64  `json:"unindexedMs"`
+}
+
+// EntryFilterReport is the response for GET /api/admin/entry-filters.
+type EntryFilterReport struct {
+	Benchmarks []EntryFilterBenchmark `json:"benchmarks"`
+}
+
+// ============ Progress Post Models ============
+
+// ProgressPost configures weekly gestational-age messages posted into a
+// family's mvchat2 conversation.
+type ProgressPost struct {
+	PregnancyID    int64           `db:"pregnancy_id" json:"pregnancyId"`
+	ConversationID string          `db:"conversation_id" json:"conversationId"`
+	Enabled        bool            `db:"enabled" json:"enabled"`
+	Template       string          `db:"template" json:"template"`
+	WeekTemplates  json.RawMessage `db:"week_templates" json:"weekTemplates"`
+	LastWeekPosted sql.NullInt64   `db:"last_week_posted" json:"lastWeekPosted,omitempty"`
+	LastPostedAt   sql.NullTime    `db:"last_posted_at" json:"lastPostedAt,omitempty"`
+	LastError      sql.NullString  `db:"last_error" json:"lastError,omitempty"`
+	UpdatedBy      string          `db:"updated_by" json:"updatedBy"`
+	CreatedAt      time.Time       `db:"created_at" json:"createdAt"`
+	UpdatedAt      time.Time       `db:"updated_at" json:"updatedAt"`
+}
+
+// ProgressPostRequest is the request body for PUT /api/pregnancies/{id}/progress-posts.
+// Templates may use {week}, {day}, {daysRemaining}, {trimester}, {babyName}
+// and {momName}.
+type ProgressPostRequest struct {
+	ConversationID string            `json:"conversationId"`
+	Enabled        *bool             `json:"enabled,omitempty"` // Default true
+	Template       string            `json:"template,omitempty"`
+	WeekTemplates  map[string]string `json:"weekTemplates,omitempty"`
+}
+
+// ProgressPostPreview is the response for POST /api/pregnancies/{id}/progress-posts/test.
+type ProgressPostPreview struct {
+	Week int    `json:"week"`
+	Text string `json:"text"`
+}
+
+// ============ Benchmark Models ============
+
+// BenchmarkBucket is one released gestational week of a benchmark.
+type BenchmarkBucket struct {
+	Metric      string          `db:"metric" json:"-"`
+	Week        int             `db:"week" json:"week"`
+	Suppressed  bool            `db:"suppressed" json:"suppressed"` // Too few users to release
+	Users       sql.NullInt64   `db:"users" json:"users,omitempty"` // Noisy
+	Mean        sql.NullFloat64 `db:"mean" json:"mean,omitempty"`   // Noisy
+	Epsilon     float64         `db:"epsilon" json:"-"`
+	MinCohort   int             `db:"min_cohort" json:"-"`
+	GeneratedAt time.Time       `db:"generated_at" json:"-"`
+}
+
+// CohortAggregate is the exact aggregate of one week of a metric across
+// pregnancies. It is input to the privacy mechanism and never returned.
+type CohortAggregate struct {
+	Week  int     `db:"week"`
+	Users int     `db:"users"`
+	Sum   float64 `db:"sum"`
+}
+
+// BenchmarkPrivacy documents how a benchmark was protected.
+type BenchmarkPrivacy struct {
+	Mechanism         string    `json:"mechanism"`        // "laplace"
+	EpsilonPerBucket  float64   `json:"epsilonPerBucket"` // Split between count and sum
+	MinCohort         int       `json:"minCohort"`        // Buckets with fewer users, true or noisy, are suppressed
+	ClipLow           float64   `json:"clipLow"`          // Each pregnancy's weekly mean is clipped to these bounds
+	ClipHigh          float64   `json:"clipHigh"`
+	MaxBucketsPerUser int       `json:"maxBucketsPerUser"` // Weeks one pregnancy can appear in; its total epsilon is up to this many times the bucket's
+	GeneratedAt       time.Time `json:"generatedAt"`
+}
+
+// BenchmarkResponse is the response for GET /api/analytics/benchmarks.
+type BenchmarkResponse struct {
+	Metric  string            `json:"metric"`
+	Unit    string            `json:"unit,omitempty"`
+	Buckets []BenchmarkBucket `json:"buckets"`
+	Privacy *BenchmarkPrivacy `json:"privacy,omitempty"` // Absent until the job has run
+}
+
+// ============ Supporter Engagement Models ============
+
+// SupporterEngagement is a supporter's activity derived from the access log.
+type SupporterEngagement struct {
+	UserID       string       `db:"user_id"`
+	LastViewedAt sql.NullTime `db:"last_viewed_at"`
+	Visits       int64        `db:"visits"`
+}
+
+// ActivitySharingRequest lets a supporter show or hide their engagement.
+type ActivitySharingRequest struct {
+	ShareActivity *bool `json:"shareActivity"`
+}
+
+// ActivitySharingResponse reports whether the owner sees a supporter's engagement.
+type ActivitySharingResponse struct {
+	ShareActivity bool   `json:"shareActivity"`
+	Note          string `json:"note"`
+}
+
+// ============ Upload Batch Models ============
+
+// UploadBatchItem is one file's metadata in a batch upload manifest.
+type UploadBatchItem struct {
+	FileType string          `json:"fileType"`
+	ClientID string          `json:"clientId"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	Shared   bool            `json:"shared"`
+	AltText  string          `json:"altText,omitempty"`
+}
+
+// UploadBatchResult reports what happened to one file of a batch upload.
+type UploadBatchResult struct {
+	Index            int       `json:"index"`
+	Filename         string    `json:"filename"`
+	ClientID         string    `json:"clientId,omitempty"`
+	Status           string    `json:"status"` // created or failed
+	Reason           string    `json:"reason,omitempty"`
+	FileID           int64     `json:"fileId,omitempty"`
+	URL              string    `json:"url,omitempty"`
+	ModerationStatus string    `json:"moderationStatus,omitempty"`
+	Warnings         []Warning `json:"warnings,omitempty"` // e.g. ALT_TEXT_MISSING
+}
+
+// UploadBatchResponse is the response for POST /api/files/upload-batch.
+type UploadBatchResponse struct {
+	Uploaded int                 `json:"uploaded"`
+	Failed   int                 `json:"failed"`
+	Results  []UploadBatchResult `json:"results"`
+}
+
+// ============ Preview-As Models ============
+
+// PreviewAs marks a response rendered as another role would see it. Nothing
+// in it can be changed through the preview.
+type PreviewAs struct {
+	Role        string `json:"role"` // support or partner
+	ReadOnly    bool   `json:"readOnly"`
+	Notice      string `json:"notice"`
+	GeneratedAt string `json:"generatedAt"`
+	ExpiresAt   string `json:"expiresAt"` // The preview is a snapshot; fetch a new one after this
+}
+
+// PreviewAsResponse is the response for GET /api/preview-as.
+type PreviewAsResponse struct {
+	Preview PreviewAs         `json:"preview"`
+	Sync    *SyncResponse     `json:"sync"`           // What GET /api/sync returns the role
+	Lite    *LiteSyncResponse `json:"lite,omitempty"` // What GET /api/sync/lite returns; supporters only
+}
+
+// ============ Alt Text Models ============
+
+// FileAltTextRequest is the body of PATCH /api/files/{fileId}.
+type FileAltTextRequest struct {
+	AltText *string `json:"altText"` // "" clears it
+}
+
+// AltTextSuggestion is a captioning provider's alt text for an image. It is
+// not saved until the client PATCHes it onto the file.
+type AltTextSuggestion struct {
+	FileID  int64  `json:"fileId"`
+	AltText string `json:"altText"`
+}
+
+// AltTextAuditResponse lists the pregnancy's images that have no alt text.
+type AltTextAuditResponse struct {
+	Images    int    `json:"images"`    // Images on file
+	Described int    `json:"described"` // Of those, with alt text
+	Missing   []File `json:"missing"`   // Without alt text, newest first
+}
+
+// ============ Widget Token Models ============
+
+// WidgetToken lets a supporter web widget read a pregnancy's lite sync and
+// shared photos. The secret is only returned on creation.
+type WidgetToken struct {
+	ID          int64        `db:"id" json:"id"`
+	PregnancyID int64        `db:"pregnancy_id" json:"-"`
+	CreatedBy   string       `db:"created_by" json:"-"`
+	Name        string       `db:"name" json:"name"`
+	TokenHash   string       `db:"token_hash" json:"-"`
+	Prefix      string       `db:"prefix" json:"prefix"`
+	CreatedAt   time.Time    `db:"created_at" json:"createdAt"`
+	ExpiresAt   sql.NullTime `db:"expires_at" json:"expiresAt,omitempty"`
+	LastUsedAt  sql.NullTime `db:"last_used_at" json:"lastUsedAt,omitempty"`
+	RevokedAt   sql.NullTime `db:"revoked_at" json:"revokedAt,omitempty"`
+}
+
+// WidgetTokenRequest creates a widget token.
+type WidgetTokenRequest struct {
+	Name          string `json:"name"`
+	ExpiresInDays *int   `json:"expiresInDays,omitempty"` // Omit for a token that never expires
+}
+
+// WidgetTokenResponse is returned once when a widget token is created.
+type WidgetTokenResponse struct {
+	WidgetToken
+	Token string `json:"token"` // Shown only now; put it in the widget's configuration
+}
+
+// ============ Birth Detail Models ============
+
+// BirthDetails are recorded after a pregnancy's outcome is set to birth.
+type BirthDetails struct {
+	PregnancyID    int64           `db:"pregnancy_id" json:"pregnancyId"`
+	BornAt         time.Time       `db:"born_at" json:"bornAt"`
+	WeightGrams    sql.NullInt64   `db:"weight_grams" json:"weightGrams,omitempty"`
+	LengthCm       sql.NullFloat64 `db:"length_cm" json:"lengthCm,omitempty"`
+	DeliveryType   sql.NullString  `db:"delivery_type" json:"deliveryType,omitempty"`
+	ArchiveAt      sql.NullTime    `db:"archive_at" json:"archiveAt,omitempty"`
+	AutoArchivedAt sql.NullTime    `db:"auto_archived_at" json:"autoArchivedAt,omitempty"`
+	UpdatedBy      string          `db:"updated_by" json:"-"`
+	CreatedAt      time.Time       `db:"created_at" json:"createdAt"`
+	UpdatedAt      time.Time       `db:"updated_at" json:"updatedAt"`
+}
+
+// BirthDetailsRequest is the request body for POST /api/pregnancies/{id}/birth-details.
+type BirthDetailsRequest struct {
+	BornAt           string   `json:"bornAt"` // RFC3339
+	WeightGrams      *int     `json:"weightGrams,omitempty"`
+	LengthCm         *float64 `json:"lengthCm,omitempty"`
+	DeliveryType     string   `json:"deliveryType,omitempty"`
+	ArchiveAfterDays *int     `json:"archiveAfterDays,omitempty"` // Days after birth; 0 never, omitted uses the server default
+}
+
+// OutcomeFollowUp asks the client for more details after an outcome is set.
+type OutcomeFollowUp struct {
+	Kind   string   `json:"kind"` // birth_details
+	Path   string   `json:"path"`
+	Fields []string `json:"fields"`
+}
+
+// ============ Dry Run Models ============
+
+// TableImpact counts the rows an operation deletes, updates or inserts in one table.
+type TableImpact struct {
+	Table    string `db:"table_name" json:"table"`
+	Deleted  int64  `db:"deleted" json:"deleted"`
+	Updated  int64  `db:"updated" json:"updated"`             // Soft deletes, cleared references and trigger bookkeeping
+	Inserted int64  `db:"inserted" json:"inserted,omitempty"` // Counted by sync previews only
+}
+
+// DeletionPreview is returned instead of deleting when a destructive endpoint
+// is called with ?dryRun=true.
+type DeletionPreview struct {
+	DryRun    bool          `json:"dryRun"`
+	Tables    []TableImpact `json:"tables"`
+	Files     int           `json:"files"`     // Stored files removed or hidden
+	FileBytes int64         `json:"fileBytes"` // Their total size
+}
+
+// Sync dry-run outcomes
+const (
+	SyncOutcomeCreated  = "created"
+	SyncOutcomeUpdated  = "updated"
+	SyncOutcomeDeleted  = "deleted"
+	SyncOutcomeConflict = "conflict"
+	SyncOutcomeNotFound = "not_found" // Deletion of an entry the server doesn't have live, or a write to one hidden from the pusher
+)
+
+// SyncChange is what a push would do to one pushed entry, deletion or setting.
+type SyncChange struct {
+	Kind      string   `json:"kind"` // "entry", "deletion" or "setting"
+	Key       string   `json:"key"`  // Entry clientId or setting type
+	EntryType string   `json:"entryType,omitempty"`
+	Outcome   string   `json:"outcome"`
+	Entries   []Entry  `json:"-"` // Stored entries kept on an entry or deletion conflict
+	Setting   *Setting `json:"-"` // Stored setting kept on a settings patch conflict
+}
+
+// SyncPreview is returned instead of applying a push sent with dryRun: true.
+type SyncPreview struct {
+	DryRun           bool           `json:"dryRun"`
+	PregnancyCreated bool           `json:"pregnancyCreated,omitempty"`
+	Changes          []SyncChange   `json:"changes"`
+	Conflicts        []SyncConflict `json:"conflicts"`
+	Tables           []TableImpact  `json:"tables"`
+}
+
+// ============ Pagination Models ============
+
+// EntriesPage is the response for GET /api/entries with limit or cursor.
+type EntriesPage struct {
+	pagination.Page[Entry]
+	SyncVersion  int64  `json:"syncVersion"`
+	Snoozed      bool   `json:"snoozed,omitempty"`
+	SnoozedUntil string `json:"snoozedUntil,omitempty"`
+}
+
+// SecurityEventsPage is the response for GET /api/security/events with limit or cursor.
+type SecurityEventsPage struct {
+	pagination.Page[SecurityEvent]
+	RequireRepair bool `json:"requireRepair"`
+}
+
+// ============ SLO Models ============
+
+// SLOReport is the response for GET /api/admin/slo. It covers requests this
+// server process handled within the window.
+type SLOReport struct {
+	Window     string     `json:"window"`
+	Target     float64    `json:"target"` // Success rate objective, percent
+	Since      string     `json:"since"`  // When this process started recording
+	ServerTime string     `json:"serverTime"`
+	Overall    RouteSLO   `json:"overall"`
+	Routes     []RouteSLO `json:"routes"` // Least error budget left first
+}
+
+// RouteSLO summarizes the requests to one route ("METHOD /path-template").
+type RouteSLO struct {
+	Route                string  `json:"route,omitempty"`
+	Requests             int     `json:"requests"`
+	Failures             int     `json:"failures"`             // 5xx responses
+	SuccessRate          float64 `json:"successRate"`          // Percent
+	ErrorBudgetRemaining float64 `json:"errorBudgetRemaining"` // Percent of the allowed failures left; negative once overspent
+	P50Ms                float64 `json:"p50Ms"`
+	P95Ms                float64 `json:"p95Ms"`
+	P99Ms                float64 `json:"p99Ms"`
+	Partial              bool    `json:"partial,omitempty"` // Only the route's most recent 1024 requests are counted
+}
+
+// ============ User Block Models ============
+
+// UserBlock keeps a user from sending pairing requests to the blocker and from
+// redeeming invite codes for pregnancies the blocker owns or co-owns.
+type UserBlock struct {
+	ID        int64          `db:"id" json:"id"`
+	BlockerID string         `db:"blocker_id" json:"-"`
+	BlockedID string         `db:"blocked_id" json:"userId"`
+	Reason    sql.NullString `db:"reason" json:"reason,omitempty"`
+	CreatedAt time.Time      `db:"created_at" json:"createdAt"`
+}
+
+// UserBlockRequest is the request body for blocking a user.
+type UserBlockRequest struct {
+	UserID string `json:"userId"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ============ User Preference Models ============
+
+// UserPreferences holds a user's locale and formatting overrides. NULL fields
+// are derived from the locale or the request.
+type UserPreferences struct {
+	UserID            string         `db:"user_id"`
+	Locale            sql.NullString `db:"locale"`
+	MeasurementSystem sql.NullString `db:"measurement_system"`
+	FirstDayOfWeek    sql.NullString `db:"first_day_of_week"`
+	UpdatedAt         time.Time      `db:"updated_at"`
+}
+
+// PreferencesRequest is the request body for updating preferences. Omitted
+// fields are kept; an empty string clears the field.
+type PreferencesRequest struct {
+	Locale            *string `json:"locale"`
+	MeasurementSystem *string `json:"measurementSystem"`
+	FirstDayOfWeek    *string `json:"firstDayOfWeek"`
+}
+
+// PreferencesResponse is the caller's locale with the formatting hints derived
+// from it. The override fields are only set when the user chose them.
+type PreferencesResponse struct {
+	Locale            string            `json:"locale"`
+	LocaleSource      string            `json:"localeSource"` // preference, header or default
+	MeasurementSystem string            `json:"measurementSystem,omitempty"`
+	FirstDayOfWeek    string            `json:"firstDayOfWeek,omitempty"`
+	Formatting        locale.Formatting `json:"formatting"`
+}
+
+// ============ Birth Plan Models ============
+
+// BirthPlanSection is one section of a pregnancy's birth plan. A section is
+// locked while someone edits it; others' edits are rejected until the lock
+// is released or expires.
+type BirthPlanSection struct {
+	PregnancyID   int64          `db:"pregnancy_id" json:"-"`
+	Section       string         `db:"section" json:"section"`
+	Content       string         `db:"content" json:"content"`
+	Version       int            `db:"version" json:"version"`
+	UpdatedBy     sql.NullString `db:"updated_by" json:"updatedBy,omitempty"`
+	UpdatedAt     sql.NullTime   `db:"updated_at" json:"updatedAt,omitempty"`
+	LockedBy      sql.NullString `db:"locked_by" json:"lockedBy,omitempty"`
+	LockExpiresAt sql.NullTime   `db:"lock_expires_at" json:"lockExpiresAt,omitempty"`
+	ChangedAt     time.Time      `db:"changed_at" json:"-"`
+	SyncVersion   int64          `db:"sync_version" json:"-"`
+}
+
+// BirthPlanEdit is a new version of one section, made against baseVersion.
+type BirthPlanEdit struct {
+	Content     *string `json:"content"`
+	BaseVersion *int    `json:"baseVersion"`
+}
+
+// BirthPlanPatchRequest is the request body for PATCH /api/birth-plan.
+type BirthPlanPatchRequest struct {
+	Sections map[string]BirthPlanEdit `json:"sections"`
+}
+
+// BirthPlanConflict reports a section edit that was not applied.
+type BirthPlanConflict struct {
+	Section string            `json:"section"`
+	Reason  string            `json:"reason"` // locked or edited
+	Server  *BirthPlanSection `json:"server"`
+}
+
+// BirthPlanResponse is the birth plan, or the sections a PATCH changed.
+type BirthPlanResponse struct {
+	Sections  []BirthPlanSection  `json:"sections"`
+	Merged    []string            `json:"merged,omitempty"` // Sections combined with edits made since baseVersion
+	Conflicts []BirthPlanConflict `json:"conflicts,omitempty"`
+}
+
+// ============ Entry Summary Models ============
+
+// SummaryConsent records the owner's agreement to send journal posts to the
+// summarization service.
+type SummaryConsent struct {
+	PregnancyID int64     `db:"pregnancy_id" json:"-"`
+	ConsentedBy string    `db:"consented_by" json:"-"`
+	ConsentedAt time.Time `db:"consented_at" json:"consentedAt"`
+}
+
+// SummaryConsentRequest is the request body for PUT /api/summaries/consent.
+type SummaryConsentRequest struct {
+	Consent *bool `json:"consent"`
+}
+
+// SummaryConsentResponse reports whether journal posts are summarized.
+type SummaryConsentResponse struct {
+	Available   bool       `json:"available"` // A summarization service is configured
+	Consent     bool       `json:"consent"`
+	ConsentedAt *time.Time `json:"consentedAt,omitempty"`
+	MinLength   int        `json:"minLength,omitempty"` // Posts shorter than this aren't summarized
+}
+
+// EntrySummary is a generated summary of a journal post. It is current while
+// SourceUpdatedAt matches the entry's updatedAt.
+type EntrySummary struct {
+	EntryID         int64           `db:"entry_id" json:"-"`
+	PregnancyID     int64           `db:"pregnancy_id" json:"-"`
+	ClientID        string          `db:"client_id" json:"-"`
+	Summary         string          `db:"summary" json:"summary"`
+	Tags            json.RawMessage `db:"tags" json:"tags"`
+	SourceUpdatedAt time.Time       `db:"source_updated_at" json:"-"`
+	CreatedAt       time.Time       `db:"created_at" json:"createdAt"`
+}
+
+// ============ Idempotency Models ============
+
+// IdempotencyRecord is the first request made with an Idempotency-Key and,
+// once it finished, its response.
+type IdempotencyRecord struct {
+	UserID      string         `db:"user_id"`
+	Key         string         `db:"idempotency_key"`
+	Route       string         `db:"route"`
+	Fingerprint string         `db:"fingerprint"`
+	Status      sql.NullInt32  `db:"status"`
+	ContentType sql.NullString `db:"content_type"`
+	Response    []byte         `db:"response"`
+	CreatedAt   time.Time      `db:"created_at"`
+}
+
+// ============ Failure Injection Models ============
+
+// ChaosConfig is the failures injected into one user's requests on staging.
+type ChaosConfig struct {
+	LatencyMs    int       `json:"latencyMs"`        // Added before every request
+	JitterMs     int       `json:"jitterMs"`         // Up to this much more latency, at random
+	ErrorRate    float64   `json:"errorRate"`        // Share of requests answered 500 as if the database failed
+	SyncFailRate float64   `json:"syncFailRate"`     // Share of POST /api/sync that fail after half the entries were written
+	Routes       []string  `json:"routes,omitempty"` // "METHOD /api/path-template" to limit faults to; all when empty
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// ChaosRequest is the request body for PUT /api/me/chaos.
+type ChaosRequest struct {
+	LatencyMs        int      `json:"latencyMs"`
+	JitterMs         int      `json:"jitterMs"`
+	ErrorRate        float64  `json:"errorRate"`
+	SyncFailRate     float64  `json:"syncFailRate"`
+	Routes           []string `json:"routes,omitempty"`
+	ExpiresInMinutes int      `json:"expiresInMinutes"` // Default 60, max 1440
+}
+
+// ============ Sharing Graph Models ============
+
+// SharingGraph is everyone and everything with access to the owner's
+// pregnancy, for the app's privacy screen.
+type SharingGraph struct {
+	Grants       []SharingGrant `json:"grants"`
+	SnoozedUntil *string        `json:"snoozedUntil,omitempty"`
+	GeneratedAt  string         `json:"generatedAt"`
+}
+
+// SharingGrant is one person, code or token with access to a pregnancy.
+type SharingGrant struct {
+	Kind       string  `json:"kind"`             // coowner, partner, supporter, provider, invite_code, widget_token, personal_token, calendar_feed
+	ID         string  `json:"id"`               // What the kind's remove or revoke route takes
+	UserID     string  `json:"userId,omitempty"` // For people
+	Name       string  `json:"name,omitempty"`
+	AvatarURL  string  `json:"avatarUrl,omitempty"`
+	Role       string  `json:"role,omitempty"` // Role an invite code grants
+	Scope      string  `json:"scope"`          // full, read, write or care_notes
+	GrantedAt  string  `json:"grantedAt,omitempty"`
+	ExpiresAt  *string `json:"expiresAt,omitempty"`
+	LastUsedAt *string `json:"lastUsedAt,omitempty"`
+	Paused     bool    `json:"paused,omitempty"`    // Sharing is snoozed
+	Suspended  bool    `json:"suspended,omitempty"` // Pairing removal pending
+}
+
+// ============ Entry Visibility Models ============
+
+// EntryVisibilityRequest changes the visibility of many entries at once, by
+// clientId or every entry of a type.
+type EntryVisibilityRequest struct {
+	Visibility string   `json:"visibility"`
+	ClientIDs  []string `json:"clientIds,omitempty"`
+	EntryType  string   `json:"entryType,omitempty"` // Instead of clientIds: every entry of the type
+}
+
+// EntryVisibilityResponse reports a bulk visibility change.
+type EntryVisibilityResponse struct {
+	Visibility string `json:"visibility"`
+	Updated    int64  `json:"updated"` // Entries whose visibility changed
+}
+
+// EntryDeleteRequest soft deletes many entries at once, by clientId or every
+// entry of a type.
+type EntryDeleteRequest struct {
+	ClientIDs []string `json:"clientIds,omitempty"`
+	EntryType string   `json:"entryType,omitempty"` // Instead of clientIds: every entry of the type
+}
+
+// EntryDeleteResponse reports a bulk entry delete.
+type EntryDeleteResponse struct {
+	Deleted   int64  `json:"deleted"` // Live entries deleted; unknown and hidden ones are skipped
+	DeletedAt string `json:"deletedAt"`
+}
+
+// ============ Entry Trigger Models ============
+
+// Entry trigger delivery statuses
+const (
+	TriggerDeliveryPending   = "pending"
+	TriggerDeliveryDelivered = "delivered"
+	TriggerDeliveryFailed    = "failed"
+)
+
+// TriggerCondition narrows an entry trigger to entries whose data field
+// compares to a value.
+type TriggerCondition struct {
+	Field string          `json:"field"`           // Key in the entry's data; dots reach into objects
+	Op    string          `json:"op"`              // eq, ne, gt, lt, contains or exists
+	Value json.RawMessage `json:"value,omitempty"` // Not used by exists
+}
+
+// EntryTrigger calls a webhook when an entry of EntryType is created and
+// matches Condition, e.g. to turn a smart light pink or blue at the gender reveal.
+type EntryTrigger struct {
+	ID          int64           `db:"id" json:"id"`
+	PregnancyID int64           `db:"pregnancy_id" json:"-"`
+	CreatedBy   string          `db:"created_by" json:"createdBy"`
+	Name        string          `db:"name" json:"name"`
+	EntryType   string          `db:"entry_type" json:"entryType"`
+	Condition   json.RawMessage `db:"condition" json:"condition,omitempty"` // TriggerCondition; null fires on every entry of the type
+	URL         string          `db:"url" json:"url"`
+	Secret      string          `db:"secret" json:"-"`
+	Enabled     bool            `db:"enabled" json:"enabled"`
+	CreatedAt   time.Time       `db:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time       `db:"updated_at" json:"updatedAt"`
+}
+
+// EntryTriggerRequest creates or replaces an entry trigger.
+type EntryTriggerRequest struct {
+	Name      string            `json:"name"`
+	EntryType string            `json:"entryType"`
+	Condition *TriggerCondition `json:"condition,omitempty"`
+	URL       string            `json:"url"`
+	Enabled   *bool             `json:"enabled,omitempty"` // Default true
+}
+
+// EntryTriggerResponse is a created trigger with its signing secret.
+type EntryTriggerResponse struct {
+	EntryTrigger
+	Secret string `json:"secret"` // Shown only now; verifies X-Tracker-Signature
+}
+
+// TriggerDelivery is one call of an entry trigger's webhook.
+type TriggerDelivery struct {
+	ID            int64          `db:"id" json:"id"`
+	TriggerID     int64          `db:"trigger_id" json:"triggerId"`
+	EntryID       sql.NullInt64  `db:"entry_id" json:"-"`
+	ClientID      sql.NullString `db:"client_id" json:"-"` // Of the entry, when listed
+	Test          bool           `db:"test" json:"test"`
+	Status        string         `db:"status" json:"status"`
+	Attempts      int            `db:"attempts" json:"attempts"`
+	ResponseCode  sql.NullInt32  `db:"response_code" json:"-"`
+	Error         sql.NullString `db:"error" json:"-"`
+	NextAttemptAt sql.NullTime   `db:"next_attempt_at" json:"-"`
+	CreatedAt     time.Time      `db:"created_at" json:"createdAt"`
+	DeliveredAt   sql.NullTime   `db:"delivered_at" json:"-"`
+}
+
+// TriggerDeliveryDTO is a delivery in the trigger's history.
+type TriggerDeliveryDTO struct {
+	ID           int64   `json:"id"`
+	ClientID     string  `json:"clientId,omitempty"` // Entry that fired it; none for test fires
+	Test         bool    `json:"test"`
+	Status       string  `json:"status"`
+	Attempts     int     `json:"attempts"`
+	ResponseCode *int    `json:"responseCode,omitempty"`
+	Error        string  `json:"error,omitempty"`
+	CreatedAt    string  `json:"createdAt"`
+	DeliveredAt  *string `json:"deliveredAt,omitempty"`
+}
+
+// TriggerPayload is the JSON body POSTed to a trigger's webhook.
+type TriggerPayload struct {
+	TriggerID   int64           `json:"triggerId"`
+	TriggerName string          `json:"triggerName"`
+	EntryType   string          `json:"entryType"`
+	ClientID    string          `json:"clientId,omitempty"`
+	Data        json.RawMessage `json:"data,omitempty"`
+	Test        bool            `json:"test,omitempty"`
+	FiredAt     string          `json:"firedAt"`
+}
+
+// TriggerTestRequest is the optional body of a test fire.
+type TriggerTestRequest struct {
+	Data json.RawMessage `json:"data,omitempty"` // Sample entry data sent instead of none, checked against the condition
+}
+
+// TriggerTestResponse reports a test fire.
+type TriggerTestResponse struct {
+	Delivery TriggerDeliveryDTO `json:"delivery"`
+	Matches  *bool              `json:"matches,omitempty"` // Whether the sample data meets the condition
+}
+
+// EntryReminder nudges a user when no entry of a type was logged for
+// CadenceDays.
+type EntryReminder struct {
+	ID           int64        `db:"id" json:"-"`
+	PregnancyID  int64        `db:"pregnancy_id" json:"-"`
+	UserID       string       `db:"user_id" json:"-"`
+	EntryType    string       `db:"entry_type" json:"entryType"`
+	CadenceDays  int          `db:"cadence_days" json:"cadenceDays"`
+	SnoozedUntil sql.NullTime `db:"snoozed_until" json:"-"`
+	LastNudgedAt sql.NullTime `db:"last_nudged_at" json:"-"`
+	CreatedAt    time.Time    `db:"created_at" json:"-"`
+	UpdatedAt    time.Time    `db:"updated_at" json:"-"`
+
+	LastEntryAt sql.NullTime `db:"last_entry_at" json:"-"` // Newest entry of the type, when joined
+}
+
+// EntryReminderDTO is a reminder with the state of its gap.
+type EntryReminderDTO struct {
+	EntryType    string  `json:"entryType"`
+	CadenceDays  int     `json:"cadenceDays"`
+	LastEntryAt  *string `json:"lastEntryAt,omitempty"`  // Newest entry of the type, by anyone
+	DaysSince    *int    `json:"daysSince,omitempty"`    // Days since lastEntryAt
+	Due          bool    `json:"due"`                    // The gap is at least the cadence
+	SnoozedUntil *string `json:"snoozedUntil,omitempty"` // No nudges until then
+	LastNudgedAt *string `json:"lastNudgedAt,omitempty"`
+}
+
+// EntryRemindersResponse lists the user's reminders.
+type EntryRemindersResponse struct {
+	Reminders  []EntryReminderDTO `json:"reminders"`
+	Suppressed bool               `json:"suppressed"` // The pregnancy's outcome is set, so no nudges are sent
+}
+
+// EntryReminderRequest sets a reminder's cadence.
+type EntryReminderRequest struct {
+	CadenceDays int `json:"cadenceDays"` // 1-90
+}
+
+// EntryReminderSnoozeRequest pauses one reminder.
+type EntryReminderSnoozeRequest struct {
+	Days int `json:"days"` // 1-90
+}
+
+// ============ V1 Migration Models ============
+
+// V1 migration record kinds
+const (
+	V1RecordProfile    = "profile"
+	V1RecordLog        = "log"
+	V1RecordPreference = "preference"
+	V1RecordPhoto      = "photo"
+)
+
+// V1 migration result statuses
+const (
+	V1Migrated        = "migrated"
+	V1AlreadyMigrated = "already_migrated" // In the ledger from an earlier run
+	V1Skipped         = "skipped"          // Nothing to bring over, e.g. a deleted log
+	V1Failed          = "failed"           // Not migrated; a later run tries again
+)
+
+// V1MigrationRequest is the request body for POST /api/migrate/v1: either a
+// v1 export, or a v1 token to pull the export from the v1 server with.
+type V1MigrationRequest struct {
+	Export json.RawMessage `json:"export,omitempty"`
+	Token  string          `json:"token,omitempty"`
+}
+
+// V1LedgerEntry records one migrated v1 record.
+type V1LedgerEntry struct {
+	UserID      string    `db:"user_id" json:"-"`
+	RecordKind  string    `db:"record_kind" json:"kind"`
+	V1ID        string    `db:"v1_id" json:"v1Id"`
+	PregnancyID int64     `db:"pregnancy_id" json:"-"`
+	Target      string    `db:"target" json:"target"`
+	MigratedAt  time.Time `db:"migrated_at" json:"migratedAt"`
+}
+
+// V1EntryImport is an entry mapped from a v1 log or photo, with the ledger
+// row written alongside it.
+type V1EntryImport struct {
+	Entry      EntryRequest
+	At         time.Time // Original time, kept as created_at and occurred_at
+	RecordKind string
+	V1ID       string
+}
+
+// V1MigrationResult reports what happened to one v1 record.
+type V1MigrationResult struct {
+	Kind   string `json:"kind"`
+	V1ID   string `json:"v1Id"`
+	Status string `json:"status"`
+	Target string `json:"target,omitempty"` // e.g. pregnancy:12, entry:weight/v1-123, setting:units
+	Reason string `json:"reason,omitempty"`
+}
+
+// V1MigrationReport is the response for POST /api/migrate/v1.
+type V1MigrationReport struct {
+	PregnancyID      int64               `json:"pregnancyId"`
+	PregnancyCreated bool                `json:"pregnancyCreated"`
+	Migrated         int                 `json:"migrated"`
+	AlreadyMigrated  int                 `json:"alreadyMigrated"`
+	Skipped          int                 `json:"skipped"`
+	Failed           int                 `json:"failed"`
+	Results          []V1MigrationResult `json:"results"`
+}
@@ -0,0 +1,74 @@
+// Package moderation checks shared images for abusive content before supporters see them.
+//
+// A Moderator is pluggable: the HTTP implementation posts the image to an external
+// moderation API or a locally hosted model that speaks the same protocol.
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Moderation states stored on files. Files that were never sent for review have none.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusBlocked  = "blocked"
+)
+
+// Verdict is the outcome of a moderation check.
+type Verdict struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"` // Why the image was blocked, e.g. "nudity"
+}
+
+// Moderator reviews an image.
+type Moderator interface {
+	Moderate(ctx context.Context, data []byte, mimeType string) (*Verdict, error)
+}
+
+// HTTPModerator posts the raw image to URL with its MIME type as Content-Type and
+// expects a JSON Verdict back. A bearer token is sent when Token is set.
+type HTTPModerator struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// NewHTTP creates an HTTPModerator with a request timeout.
+func NewHTTP(url, token string) *HTTPModerator {
+	return &HTTPModerator{URL: url, Token: token, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Moderate sends the image to the moderation endpoint.
+func (m *HTTPModerator) Moderate(ctx context.Context, data []byte, mimeType string) (*Verdict, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mimeType)
+	req.Header.Set("Accept", "application/json")
+	if m.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+m.Token)
+	}
+
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("moderation service returned %s", resp.Status)
+	}
+
+	var v Verdict
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return nil, fmt.Errorf("decode moderation verdict: %w", err)
+	}
+	return &v, nil
+}
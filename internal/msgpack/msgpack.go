@@ -0,0 +1,354 @@
+// Package msgpack implements a minimal MessagePack codec for sync payloads.
+//
+// Values are bridged through encoding/json so the existing model structs and
+// their json tags define the wire shape; no generated types are needed.
+package msgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// ContentType is the media type used for MessagePack request and response bodies.
+const ContentType = "application/x-msgpack"
+
+var ErrInvalid = errors.New("invalid msgpack data")
+
+// ErrTooDeep is returned for data nested deeper than maxDepth.
+var ErrTooDeep = errors.New("msgpack: exceeded max depth")
+
+// maxDepth caps array and map nesting, as encoding/json does, so deeply
+// nested data can't exhaust the goroutine stack.
+const maxDepth = 10000
+
+// Marshal encodes v as MessagePack using its JSON representation.
+func Marshal(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, generic); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes MessagePack data into v via its JSON representation.
+func Unmarshal(data []byte, v interface{}) error {
+	r := bytes.NewReader(data)
+	generic, err := decode(r, 0)
+	if err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return ErrInvalid
+	}
+
+	raw, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if val {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			encodeInt(buf, i)
+			return nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+	case string:
+		encodeString(buf, val)
+	case []interface{}:
+		n := len(val)
+		switch {
+		case n < 16:
+			buf.WriteByte(0x90 | byte(n))
+		case n <= math.MaxUint16:
+			buf.WriteByte(0xdc)
+			binary.Write(buf, binary.BigEndian, uint16(n))
+		default:
+			buf.WriteByte(0xdd)
+			binary.Write(buf, binary.BigEndian, uint32(n))
+		}
+		for _, item := range val {
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		n := len(val)
+		switch {
+		case n < 16:
+			buf.WriteByte(0x80 | byte(n))
+		case n <= math.MaxUint16:
+			buf.WriteByte(0xde)
+			binary.Write(buf, binary.BigEndian, uint16(n))
+		default:
+			buf.WriteByte(0xdf)
+			binary.Write(buf, binary.BigEndian, uint32(n))
+		}
+		// Sorted keys keep the output deterministic
+		keys := make([]string, 0, n)
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			encodeString(buf, k)
+			if err := encode(buf, val[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+	return nil
+}
+
+func encodeInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		buf.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+func encodeString(buf *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n < 32:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteString(s)
+}
+
+// decode reads one value nested depth arrays and maps deep.
+func decode(r *bytes.Reader, depth int) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return readString(r, int(b&0x1f))
+	case b&0xf0 == 0x90:
+		return readArray(r, int(b&0x0f), depth)
+	case b&0xf0 == 0x80:
+		return readMap(r, int(b&0x0f), depth)
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		// bin is surfaced as a string; encoding/json turns it into base64 for []byte fields
+		n, err := readLength(r, b-0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return readBytes(r, n)
+	case 0xca:
+		var f uint32
+		if err := binary.Read(r, binary.BigEndian, &f); err != nil {
+			return nil, ErrInvalid
+		}
+		return float64(math.Float32frombits(f)), nil
+	case 0xcb:
+		var f uint64
+		if err := binary.Read(r, binary.BigEndian, &f); err != nil {
+			return nil, ErrInvalid
+		}
+		return math.Float64frombits(f), nil
+	case 0xcc:
+		var n uint8
+		err = binary.Read(r, binary.BigEndian, &n)
+		return int64(n), wrap(err)
+	case 0xcd:
+		var n uint16
+		err = binary.Read(r, binary.BigEndian, &n)
+		return int64(n), wrap(err)
+	case 0xce:
+		var n uint32
+		err = binary.Read(r, binary.BigEndian, &n)
+		return int64(n), wrap(err)
+	case 0xcf:
+		var n uint64
+		err = binary.Read(r, binary.BigEndian, &n)
+		return n, wrap(err)
+	case 0xd0:
+		var n int8
+		err = binary.Read(r, binary.BigEndian, &n)
+		return int64(n), wrap(err)
+	case 0xd1:
+		var n int16
+		err = binary.Read(r, binary.BigEndian, &n)
+		return int64(n), wrap(err)
+	case 0xd2:
+		var n int32
+		err = binary.Read(r, binary.BigEndian, &n)
+		return int64(n), wrap(err)
+	case 0xd3:
+		var n int64
+		err = binary.Read(r, binary.BigEndian, &n)
+		return n, wrap(err)
+	case 0xd9, 0xda, 0xdb:
+		n, err := readLength(r, b-0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return readString(r, n)
+	case 0xdc, 0xdd:
+		n, err := readLength(r, b-0xdc+1)
+		if err != nil {
+			return nil, err
+		}
+		return readArray(r, n, depth)
+	case 0xde, 0xdf:
+		n, err := readLength(r, b-0xde+1)
+		if err != nil {
+			return nil, err
+		}
+		return readMap(r, n, depth)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported format byte 0x%02x", b)
+}
+
+// readLength reads a big-endian length of 1, 2 or 4 bytes (size 0, 1, 2).
+func readLength(r *bytes.Reader, size byte) (int, error) {
+	switch size {
+	case 0:
+		var n uint8
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), wrap(err)
+	case 1:
+		var n uint16
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), wrap(err)
+	default:
+		var n uint32
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), wrap(err)
+	}
+}
+
+func readBytes(r *bytes.Reader, n int) (string, error) {
+	if n > r.Len() {
+		return "", ErrInvalid
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", ErrInvalid
+	}
+	return string(b), nil
+}
+
+func readString(r *bytes.Reader, n int) (interface{}, error) {
+	return readBytes(r, n)
+}
+
+func readArray(r *bytes.Reader, n, depth int) (interface{}, error) {
+	if depth >= maxDepth {
+		return nil, ErrTooDeep
+	}
+	if n > r.Len() {
+		return nil, ErrInvalid
+	}
+	arr := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := decode(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
+
+func readMap(r *bytes.Reader, n, depth int) (interface{}, error) {
+	if depth >= maxDepth {
+		return nil, ErrTooDeep
+	}
+	if n > r.Len() {
+		return nil, ErrInvalid
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := decode(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		v, err := decode(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+func wrap(err error) error {
+	if err != nil {
+		return ErrInvalid
+	}
+	return nil
+}
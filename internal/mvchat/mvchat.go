@@ -0,0 +1,59 @@
+// Package mvchat posts messages into mvchat2 conversations as the tracker bot.
+//
+// The HTTP implementation posts to mvchat2's bot endpoint, authenticated with a
+// bot token issued by mvchat2.
+package mvchat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Poster sends a text message to an mvchat2 conversation.
+type Poster interface {
+	Post(ctx context.Context, conversationID, text string) error
+}
+
+// HTTPPoster posts {"conversationId", "text"} as JSON to URL with the bot
+// token as a bearer token. Any 2xx response counts as delivered.
+type HTTPPoster struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// NewHTTP creates an HTTPPoster with a request timeout.
+func NewHTTP(url, token string) *HTTPPoster {
+	return &HTTPPoster{URL: url, Token: token, Client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+// Post sends the message.
+func (p *HTTPPoster) Post(ctx context.Context, conversationID, text string) error {
+	body, err := json.Marshal(map[string]string{"conversationId": conversationID, "text": text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("mvchat2 returned %s", resp.Status)
+	}
+	return nil
+}
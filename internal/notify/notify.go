@@ -0,0 +1,105 @@
+// Package notify sends short messages to people by email or SMS, such as
+// invite codes for supporters who don't have the app yet.
+//
+// A Sender is pluggable: the HTTP implementation posts each message to a relay
+// that hands it to the deployment's mail and SMS providers.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Channels a message can be sent over
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// Message is one message to one recipient. Subject is only used for email.
+type Message struct {
+	Channel string `json:"channel"`
+	To      string `json:"to"`
+	Subject string `json:"subject,omitempty"`
+	Text    string `json:"text"`
+}
+
+// Sender delivers messages.
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// HTTPSender posts the Message as JSON to URL; any 2xx response means the
+// relay accepted it. A bearer token is sent when Token is set.
+type HTTPSender struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// NewHTTP creates an HTTPSender with a request timeout.
+func NewHTTP(url, token string) *HTTPSender {
+	return &HTTPSender{URL: url, Token: token, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Send hands the message to the relay.
+func (s *HTTPSender) Send(ctx context.Context, msg *Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification relay returned %s", resp.Status)
+	}
+	return nil
+}
+
+// NormalizeAddress checks a recipient address for a channel and returns it in
+// canonical form: a bare lowercase email address, or an E.164 phone number
+// ("+" and 8-15 digits; spaces, dashes, dots and parentheses are dropped).
+func NormalizeAddress(channel, to string) (string, error) {
+	to = strings.TrimSpace(to)
+	switch channel {
+	case ChannelEmail:
+		addr, err := mail.ParseAddress(to)
+		if err != nil || addr.Address != to || !strings.Contains(to[strings.LastIndex(to, "@")+1:], ".") {
+			return "", errors.New("to must be an email address")
+		}
+		return strings.ToLower(to), nil
+	case ChannelSMS:
+		digits := strings.Map(func(r rune) rune {
+			switch r {
+			case ' ', '-', '.', '(', ')':
+				return -1
+			}
+			return r
+		}, to)
+		if !strings.HasPrefix(digits, "+") || len(digits) < 9 || len(digits) > 16 || strings.Trim(digits[1:], "0123456789") != "" || digits[1] == '0' {
+			return "", errors.New("to must be a phone number in international format, like +15551234567")
+		}
+		return digits, nil
+	}
+	return "", errors.New("channel must be 'email' or 'sms'")
+}
@@ -0,0 +1,139 @@
+// Package pagination implements the keyset pagination shared by list endpoints.
+//
+// Lists are ordered newest first by a timestamp and then by id. A cursor is an
+// opaque token holding the timestamp and id of the last item of a page; the
+// next page starts strictly after it, so rows inserted meanwhile never shift
+// or repeat items the way OFFSET paging does.
+//
+// Clients page with ?limit=N&cursor=C and get back the standard envelope:
+//
+//	{"items": [...], "nextCursor": "...", "hasMore": true}
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits on the page size.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 100
+)
+
+// ErrInvalidCursor is returned for cursors this package did not issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is the position of an item in a newest-first list.
+type Cursor struct {
+	Time time.Time
+	ID   int64
+}
+
+// Encode returns the cursor as an opaque URL-safe token.
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.Time.UnixMicro(), 36) + "." + strconv.FormatInt(c.ID, 36)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decode parses a token made by Encode.
+func Decode(token string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	ts, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+	micros, err := strconv.ParseInt(ts, 36, 64)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(id, 36, 64)
+	if err != nil || n < 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{Time: time.UnixMicro(micros).UTC(), ID: n}, nil
+}
+
+// Params selects one page of a list.
+type Params struct {
+	Limit int
+	After *Cursor // nil for the first page
+}
+
+// Requested reports whether the request asks for a page, so endpoints that
+// predate pagination can keep their old response for clients that don't.
+func Requested(r *http.Request) bool {
+	q := r.URL.Query()
+	return q.Has("limit") || q.Has("cursor")
+}
+
+// FromRequest reads the limit and cursor query parameters. The limit defaults
+// to DefaultLimit and is clamped to 1..MaxLimit. The error message is suitable
+// for a 400 response.
+func FromRequest(r *http.Request) (Params, error) {
+	q := r.URL.Query()
+	p := Params{Limit: DefaultLimit}
+
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return Params{}, errors.New("limit must be an integer")
+		}
+		p.Limit = min(max(n, 1), MaxLimit)
+	}
+	if s := q.Get("cursor"); s != "" {
+		c, err := Decode(s)
+		if err != nil {
+			return Params{}, errors.New("cursor is invalid; pass nextCursor from the previous page")
+		}
+		p.After = &c
+	}
+	return p, nil
+}
+
+// Clause returns the SQL ending a list query for this page: the keyset
+// condition (joined with AND, so the query needs a WHERE already), the order
+// and a limit one larger than the page so NewPage can tell whether more rows
+// follow. timeCol and idCol must be trusted column expressions. Placeholders
+// continue after args, which is returned extended with their values.
+func (p Params) Clause(timeCol, idCol string, args []interface{}) (string, []interface{}) {
+	var clause string
+	if p.After != nil {
+		clause = fmt.Sprintf(" AND (%s, %s) < ($%d, $%d)", timeCol, idCol, len(args)+1, len(args)+2)
+		args = append(args, p.After.Time, p.After.ID)
+	}
+	clause += fmt.Sprintf(" ORDER BY %s DESC, %s DESC LIMIT $%d", timeCol, idCol, len(args)+1)
+	args = append(args, p.Limit+1)
+	return clause, args
+}
+
+// Page is the standard envelope of a paginated list response.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	HasMore    bool   `json:"hasMore"`
+}
+
+// NewPage builds the page from rows fetched with Clause, dropping the extra
+// row and pointing the cursor at the last item kept. key gives an item's
+// position in the list.
+func NewPage[T any](rows []T, p Params, key func(T) Cursor) Page[T] {
+	page := Page[T]{Items: rows}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if len(rows) > p.Limit {
+		page.Items = rows[:p.Limit]
+		page.HasMore = true
+		page.NextCursor = key(page.Items[p.Limit-1]).Encode()
+	}
+	return page
+}
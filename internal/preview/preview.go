@@ -0,0 +1,130 @@
+// Package preview renders previews of uploaded PDFs and videos: a first-page
+// thumbnail for PDFs, and a poster frame plus an HLS transcode for videos.
+//
+// Previews are re-encoded by the server, so viewers never receive the uploaded
+// bytes. A Runner is pluggable: the exec implementation shells out to poppler's
+// pdftoppm and ffmpeg, and another implementation can render elsewhere.
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Preview kinds, by the uploaded file's MIME type.
+const (
+	KindPDF   = "pdf"
+	KindVideo = "video"
+)
+
+// Output names inside a file's preview directory.
+const (
+	PosterName   = "poster.jpg"
+	PlaylistName = "index.m3u8"
+)
+
+// ErrUnsupported is returned for kinds the runner can't render.
+var ErrUnsupported = errors.New("preview: unsupported file type")
+
+// Kind returns the preview kind for a MIME type, or "" when none applies.
+func Kind(mimeType string) string {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+	switch {
+	case mimeType == "application/pdf":
+		return KindPDF
+	case strings.HasPrefix(mimeType, "video/"):
+		return KindVideo
+	}
+	return ""
+}
+
+// Runner renders previews. src is the uploaded file and dir an empty directory
+// for the output: a PosterName image for every kind, and for videos a
+// PlaylistName HLS playlist with its segments.
+type Runner interface {
+	Supports(kind string) bool
+	Render(ctx context.Context, kind, src, dir string) error
+}
+
+// ExecRunner renders with local binaries. A kind is supported when its binary
+// is set: PDFToPPM for PDFs and FFmpeg for videos. Each command is killed after
+// Timeout.
+type ExecRunner struct {
+	PDFToPPM string
+	FFmpeg   string
+	Timeout  time.Duration
+}
+
+// NewExec creates an ExecRunner. An empty path disables that kind.
+func NewExec(pdftoppm, ffmpeg string) *ExecRunner {
+	return &ExecRunner{PDFToPPM: pdftoppm, FFmpeg: ffmpeg, Timeout: 10 * time.Minute}
+}
+
+// Supports reports whether the runner has a binary for kind.
+func (e *ExecRunner) Supports(kind string) bool {
+	switch kind {
+	case KindPDF:
+		return e.PDFToPPM != ""
+	case KindVideo:
+		return e.FFmpeg != ""
+	}
+	return false
+}
+
+// Render runs the commands for kind.
+func (e *ExecRunner) Render(ctx context.Context, kind, src, dir string) error {
+	if !e.Supports(kind) {
+		return ErrUnsupported
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+
+	if kind == KindPDF {
+		// pdftoppm appends the extension to the output prefix
+		prefix := filepath.Join(dir, strings.TrimSuffix(PosterName, ".jpg"))
+		return run(ctx, e.PDFToPPM, "-jpeg", "-f", "1", "-l", "1", "-singlefile", "-scale-to", "512", src, prefix)
+	}
+
+	// The thumbnail filter picks a representative frame, so short clips work too
+	if err := run(ctx, e.FFmpeg, "-nostdin", "-y", "-i", src,
+		"-vf", "thumbnail,scale=512:-2", "-frames:v", "1", filepath.Join(dir, PosterName)); err != nil {
+		return err
+	}
+	return run(ctx, e.FFmpeg, "-nostdin", "-y", "-i", src,
+		"-vf", "scale=-2:'min(720,ih)'", "-c:v", "libx264", "-preset", "veryfast", "-crf", "26",
+		"-c:a", "aac", "-b:a", "96k",
+		"-f", "hls", "-hls_time", "6", "-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join(dir, "segment%03d.ts"),
+		filepath.Join(dir, PlaylistName))
+}
+
+// run executes a command, folding the tail of its output into the error.
+func run(ctx context.Context, name string, args ...string) error {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(name), ctx.Err())
+	}
+	msg := strings.TrimSpace(string(out))
+	if len(msg) > 500 {
+		msg = msg[len(msg)-500:]
+	}
+	return fmt.Errorf("%s: %v: %s", filepath.Base(name), err, msg)
+}
+
+// ValidSegmentName reports whether name is a file an HLS preview may contain,
+// so it is safe to join onto the preview directory.
+func ValidSegmentName(name string) bool {
+	if name == PlaylistName {
+		return true
+	}
+	return strings.HasPrefix(name, "segment") && strings.HasSuffix(name, ".ts") &&
+		!strings.ContainsAny(name, `/\`) && name == filepath.Base(name)
+}
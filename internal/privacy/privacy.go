@@ -0,0 +1,104 @@
+// Package privacy releases cross-user aggregate statistics with differential
+// privacy guardrails.
+//
+// Each user contributes at most one value per bucket, clipped to the metric's
+// bounds. A bucket's user count and sum are released with Laplace noise, each
+// spending half of the bucket's epsilon, and buckets with fewer than MinCohort
+// users are suppressed rather than released.
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Mechanism names the noise mechanism in release metadata.
+const Mechanism = "laplace"
+
+// Params are the privacy parameters of a release.
+type Params struct {
+	Epsilon   float64 // Privacy budget per bucket, split between count and sum
+	MinCohort int     // Buckets with fewer users, true or noisy, are suppressed
+}
+
+// Validate reports whether the parameters give any protection.
+func (p Params) Validate() error {
+	if !(p.Epsilon > 0) || math.IsInf(p.Epsilon, 0) {
+		return fmt.Errorf("epsilon must be positive, got %v", p.Epsilon)
+	}
+	if p.MinCohort < 1 {
+		return fmt.Errorf("minimum cohort must be at least 1, got %d", p.MinCohort)
+	}
+	return nil
+}
+
+// Bounds clip each user's value before it is summed. They fix how much one
+// user can move a bucket's sum.
+type Bounds struct {
+	Lo, Hi float64
+}
+
+// Clip limits v to the bounds.
+func (b Bounds) Clip(v float64) float64 {
+	return math.Max(b.Lo, math.Min(b.Hi, v))
+}
+
+// Bucket is the exact aggregate of one bucket: how many users contributed and
+// the sum of their clipped values. It must never leave the server.
+type Bucket struct {
+	Users int
+	Sum   float64
+}
+
+// Release is what may be shown of a bucket.
+type Release struct {
+	Suppressed bool
+	Users      int     // Noisy user count, rounded
+	Mean       float64 // Noisy mean, within the bounds
+}
+
+// Release adds noise to a bucket. rng must be seeded with Seed so that
+// releasing the same data again yields the same noise instead of fresh noise
+// that could be averaged away.
+func (p Params) Release(b Bucket, bounds Bounds, rng *rand.Rand) Release {
+	if b.Users < p.MinCohort {
+		return Release{Suppressed: true}
+	}
+
+	half := p.Epsilon / 2
+	users := float64(b.Users) + Laplace(rng, 1/half)
+	if users < float64(p.MinCohort) {
+		return Release{Suppressed: true}
+	}
+
+	// Centering the values halves the sum's sensitivity: one user moves it by at
+	// most half the width of the bounds
+	mid := (bounds.Lo + bounds.Hi) / 2
+	centered := b.Sum - mid*float64(b.Users) + Laplace(rng, (bounds.Hi-bounds.Lo)/2/half)
+
+	return Release{
+		Users: int(math.Round(users)),
+		Mean:  bounds.Clip(mid + centered/users),
+	}
+}
+
+// Laplace draws from a zero-mean Laplace distribution with the given scale.
+func Laplace(rng *rand.Rand, scale float64) float64 {
+	u := rng.Float64() - 0.5
+	for u == -0.5 {
+		u = rng.Float64() - 0.5 // log(0)
+	}
+	return -scale * math.Copysign(math.Log(1-2*math.Abs(u)), u)
+}
+
+// Seed derives a noise seed from a secret key and a bucket's identity and
+// exact aggregate, so noise only changes when the data does.
+func Seed(key []byte, bucket string, b Bucket) int64 {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s|%d|%x", bucket, b.Users, math.Float64bits(b.Sum))
+	return int64(binary.BigEndian.Uint64(mac.Sum(nil)))
+}
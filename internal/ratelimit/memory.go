@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps counters in process. Counts reset on restart and are not
+// shared between instances.
+type Memory struct {
+	mu      sync.Mutex
+	windows map[memoryKey]memoryWindow
+	lastGC  time.Time
+}
+
+type memoryKey struct {
+	key   string
+	start int64
+}
+
+type memoryWindow struct {
+	count   int
+	expires time.Time
+}
+
+// NewMemory creates an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{windows: make(map[memoryKey]memoryWindow)}
+}
+
+// Add implements Store.
+func (m *Memory) Add(_ context.Context, key string, start time.Time, window time.Duration, n int) (int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cur := memoryKey{key, start.UnixNano()}
+	win := m.windows[cur]
+	if n > 0 {
+		win.count += n
+		win.expires = start.Add(2 * window)
+		m.windows[cur] = win
+	}
+	previous := m.windows[memoryKey{key, start.Add(-window).UnixNano()}].count
+
+	// Drop expired windows once a minute so idle keys don't accumulate
+	if now := time.Now(); now.Sub(m.lastGC) > time.Minute {
+		for k, w := range m.windows {
+			if !now.Before(w.expires) {
+				delete(m.windows, k)
+			}
+		}
+		m.lastGC = now
+	}
+	return win.count, previous, nil
+}
@@ -0,0 +1,19 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/scalecode-solutions/tracker2api/internal/db"
+)
+
+// Postgres keeps counters in the clingy_rate_limits table. Expired windows
+// are deleted by the server's cleanup loop.
+type Postgres struct {
+	DB *db.DB
+}
+
+// Add implements Store.
+func (p Postgres) Add(ctx context.Context, key string, start time.Time, window time.Duration, n int) (int, int, error) {
+	return p.DB.AddRateLimitEvents(ctx, key, start, window, n)
+}
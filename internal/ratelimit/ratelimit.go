@@ -0,0 +1,80 @@
+// Package ratelimit counts events per key in sliding windows, such as
+// requests against a budget or failed invite code attempts.
+//
+// Counts live in a Store shared by every server instance: Postgres or
+// Redis, or memory for a single instance. A window is approximated from
+// two fixed windows: the current one, plus the previous one weighted by how
+// much of it still overlaps. That takes two counters per key instead of a
+// timestamp per event, and unlike fixed windows it doesn't let twice the
+// limit through around a window's end.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps fixed-window counters.
+type Store interface {
+	// Add adds n events to key's window that starts at start and returns the
+	// count of that window and of the one before it. n is 0 to only read.
+	// Windows may be dropped once two windows have passed since start.
+	Add(ctx context.Context, key string, start time.Time, window time.Duration, n int) (current, previous int, err error)
+}
+
+// Limit allows Count events per Window.
+type Limit struct {
+	Count  int
+	Window time.Duration
+}
+
+// Result is the state of a key's window.
+type Result struct {
+	Count     int       // Events in the sliding window
+	Remaining int       // Events left before the limit, 0 once reached
+	Reset     time.Time // End of the current fixed window
+}
+
+// Exceeded reports whether more events than the limit were counted.
+func (r Result) Exceeded(l Limit) bool {
+	return r.Count > l.Count
+}
+
+// Limiter counts events in sliding windows kept in a Store.
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+// New creates a Limiter over store.
+func New(store Store) *Limiter {
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Hit records an event for key and returns the window including it.
+func (l *Limiter) Hit(ctx context.Context, key string, limit Limit) (Result, error) {
+	return l.add(ctx, key, limit, 1)
+}
+
+// Peek returns key's window without recording an event.
+func (l *Limiter) Peek(ctx context.Context, key string, limit Limit) (Result, error) {
+	return l.add(ctx, key, limit, 0)
+}
+
+func (l *Limiter) add(ctx context.Context, key string, limit Limit, n int) (Result, error) {
+	now := l.now()
+	start := now.Truncate(limit.Window)
+	current, previous, err := l.store.Add(ctx, key, start, limit.Window, n)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// The previous window counts for the part still inside the sliding window
+	overlap := limit.Window - now.Sub(start)
+	count := current + int(int64(previous)*int64(overlap)/int64(limit.Window))
+	return Result{
+		Count:     count,
+		Remaining: max(limit.Count-count, 0),
+		Reset:     start.Add(limit.Window),
+	}, nil
+}
@@ -0,0 +1,266 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisTimeout bounds a Redis round trip when the context has no deadline.
+const redisTimeout = 2 * time.Second
+
+// Redis keeps counters in Redis, as keys that expire on their own. It speaks
+// just enough of the protocol (RESP2) for its commands over a small pool of
+// connections.
+type Redis struct {
+	addr     string
+	tls      bool
+	username string
+	password string
+	database int
+	idle     chan *redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// NewRedis creates a Redis store from a URL such as
+// redis://:password@host:6379/0; rediss:// connects over TLS. Connections are
+// made when first needed.
+func NewRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+		return nil, errors.New("redis URL must look like redis://[:password@]host:port[/db]")
+	}
+	r := &Redis{addr: u.Host, tls: u.Scheme == "rediss", idle: make(chan *redisConn, 16)}
+	if u.Port() == "" {
+		r.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if r.database, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("redis database %q is not a number", db)
+		}
+	}
+	return r, nil
+}
+
+// Add implements Store.
+func (r *Redis) Add(ctx context.Context, key string, start time.Time, window time.Duration, n int) (int, int, error) {
+	cur := "ratelimit:" + key + ":" + strconv.FormatInt(start.UnixMilli(), 10)
+	prev := "ratelimit:" + key + ":" + strconv.FormatInt(start.Add(-window).UnixMilli(), 10)
+	if n == 0 {
+		replies, err := r.do(ctx, []string{"MGET", cur, prev})
+		if err != nil {
+			return 0, 0, err
+		}
+		counts, _ := replies[0].([]interface{})
+		if len(counts) != 2 {
+			return 0, 0, errors.New("redis: unexpected MGET reply")
+		}
+		current, err := redisCount(counts[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		previous, err := redisCount(counts[1])
+		return current, previous, err
+	}
+
+	expiry := start.Add(2 * window).Sub(time.Now())
+	replies, err := r.do(ctx,
+		[]string{"INCRBY", cur, strconv.Itoa(n)},
+		[]string{"PEXPIRE", cur, strconv.FormatInt(max(expiry.Milliseconds(), 1), 10)},
+		[]string{"GET", prev},
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	current, err := redisCount(replies[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	previous, err := redisCount(replies[2])
+	return current, previous, err
+}
+
+// redisCount reads a counter from an integer, bulk string or nil reply.
+func redisCount(reply interface{}) (int, error) {
+	switch v := reply.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return int(v), nil
+	case string:
+		return strconv.Atoi(v)
+	}
+	return 0, fmt.Errorf("redis: unexpected reply %v", reply)
+}
+
+// do sends the commands in one pipeline and returns their replies. A command
+// that fails fails the whole call.
+func (r *Redis) do(ctx context.Context, cmds ...[]string) ([]interface{}, error) {
+	c, err := r.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := c.pipeline(ctx, cmds)
+	if err != nil {
+		var redisErr redisError
+		if !errors.As(err, &redisErr) {
+			// The connection may be out of step; don't reuse it
+			c.Close()
+			return nil, err
+		}
+	}
+	r.put(c)
+	return replies, err
+}
+
+func (r *Redis) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-r.idle:
+		return c, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var conn net.Conn
+	var err error
+	if r.tls {
+		host, _, _ := net.SplitHostPort(r.addr)
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", r.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", r.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{Conn: conn, r: bufio.NewReader(conn)}
+
+	var setup [][]string
+	if r.password != "" {
+		if r.username != "" {
+			setup = append(setup, []string{"AUTH", r.username, r.password})
+		} else {
+			setup = append(setup, []string{"AUTH", r.password})
+		}
+	}
+	if r.database != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.database)})
+	}
+	if len(setup) > 0 {
+		if _, err := c.pipeline(ctx, setup); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (r *Redis) put(c *redisConn) {
+	select {
+	case r.idle <- c:
+	default:
+		c.Close()
+	}
+}
+
+// redisError is an error reply from the server.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// pipeline writes the commands and reads one reply for each.
+func (c *redisConn) pipeline(ctx context.Context, cmds [][]string) ([]interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	if err := c.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	for _, cmd := range cmds {
+		fmt.Fprintf(&b, "*%d\r\n", len(cmd))
+		for _, arg := range cmd {
+			fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := io.WriteString(c, b.String()); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(cmds))
+	var firstErr error
+	for i := range cmds {
+		reply, err := c.read()
+		if err != nil {
+			if _, ok := err.(redisError); !ok {
+				return nil, err
+			}
+			// Read the remaining replies so the connection stays usable
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		replies[i] = reply
+	}
+	return replies, firstErr
+}
+
+// read reads one reply: nil, int64, string or []interface{}, or a redisError.
+func (c *redisConn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, errors.New("redis: malformed reply")
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(body)
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		size, err := strconv.Atoi(body)
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, size)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
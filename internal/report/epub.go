@@ -0,0 +1,128 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// RenderEPUB renders the document as an EPUB 3 book with one chapter per section.
+func RenderEPUB(doc *Document) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	// The mimetype entry must come first and be stored uncompressed
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return nil, err
+	}
+	w.Write([]byte("application/epub+zip"))
+
+	files := map[string][]byte{
+		"META-INF/container.xml": []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`),
+	}
+	order := []string{"META-INF/container.xml"}
+
+	var manifest, spine, nav strings.Builder
+	add := func(name, mediaType string, data []byte, inSpine bool) {
+		id := strings.NewReplacer("/", "-", ".", "-").Replace(name)
+		files["OEBPS/"+name] = data
+		order = append(order, "OEBPS/"+name)
+		fmt.Fprintf(&manifest, "    <item id=\"%s\" href=\"%s\" media-type=\"%s\"/>\n", id, name, mediaType)
+		if inSpine {
+			fmt.Fprintf(&spine, "    <itemref idref=\"%s\"/>\n", id)
+		}
+	}
+
+	add("title.xhtml", "application/xhtml+xml", xhtmlPage(doc.Title,
+		fmt.Sprintf("<h1>%s</h1>\n<p>%s</p>", html.EscapeString(doc.Title), html.EscapeString(doc.Subtitle))), true)
+
+	imageCount := 0
+	for i, s := range doc.Sections {
+		var body strings.Builder
+		if s.Heading != "" {
+			fmt.Fprintf(&body, "<h2>%s</h2>\n", html.EscapeString(s.Heading))
+		}
+		for _, b := range s.Blocks {
+			if b.Heading != "" {
+				fmt.Fprintf(&body, "<h3>%s</h3>\n", html.EscapeString(b.Heading))
+			}
+			if b.Image != nil {
+				if data, _, _, err := jpegImage(b.Image); err == nil {
+					imageCount++
+					name := fmt.Sprintf("images/img-%d.jpg", imageCount)
+					add(name, "image/jpeg", data, false)
+					fmt.Fprintf(&body, "<p><img src=\"%s\" alt=\"%s\"/></p>\n", name, html.EscapeString(b.Caption))
+				}
+			}
+			if b.Caption != "" {
+				fmt.Fprintf(&body, "<p><em>%s</em></p>\n", html.EscapeString(b.Caption))
+			}
+			for _, para := range strings.Split(b.Text, "\n") {
+				if strings.TrimSpace(para) != "" {
+					fmt.Fprintf(&body, "<p>%s</p>\n", html.EscapeString(para))
+				}
+			}
+		}
+
+		name := fmt.Sprintf("section-%d.xhtml", i+1)
+		add(name, "application/xhtml+xml", xhtmlPage(s.Heading, body.String()), true)
+		heading := s.Heading
+		if heading == "" {
+			heading = fmt.Sprintf("Section %d", i+1)
+		}
+		fmt.Fprintf(&nav, "      <li><a href=\"%s\">%s</a></li>\n", name, html.EscapeString(heading))
+	}
+
+	add("nav.xhtml", "application/xhtml+xml", xhtmlPage("Contents",
+		"<nav epub:type=\"toc\" id=\"toc\">\n    <h2>Contents</h2>\n    <ol>\n"+nav.String()+"    </ol>\n  </nav>"), false)
+
+	files["OEBPS/content.opf"] = []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="bookid">urn:clingy:%d</dc:identifier>
+    <dc:title>%s</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">%s</meta>
+  </metadata>
+  <manifest>
+%s  </manifest>
+  <spine>
+%s  </spine>
+</package>`, time.Now().UnixNano(), html.EscapeString(doc.Title), time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		strings.Replace(manifest.String(), `id="nav-xhtml"`, `id="nav-xhtml" properties="nav"`, 1), spine.String()))
+	order = append(order, "OEBPS/content.opf")
+
+	for _, name := range order {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func xhtmlPage(title, body string) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><title>%s</title></head>
+<body>
+  %s
+</body>
+</html>`, html.EscapeString(title), body))
+}
@@ -0,0 +1,254 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Page geometry in points (A4).
+const (
+	pageWidth    = 595.0
+	pageHeight   = 842.0
+	pageMargin   = 56.0
+	contentWidth = pageWidth - 2*pageMargin
+	maxImageH    = 360.0
+)
+
+// Font sizes and line heights.
+const (
+	titleSize   = 26.0
+	headingSize = 18.0
+	subheadSize = 13.0
+	bodySize    = 11.0
+	captionSize = 9.0
+	lineSpacing = 1.4
+)
+
+// RenderPDF renders the document to a PDF. Each section starts on a new page.
+func RenderPDF(doc *Document) ([]byte, error) {
+	p := &pdfBuilder{}
+	p.newPage()
+
+	p.y = pageHeight / 3
+	p.text(doc.Title, "F2", titleSize)
+	if doc.Subtitle != "" {
+		p.text(doc.Subtitle, "F1", subheadSize)
+	}
+
+	for _, s := range doc.Sections {
+		p.newPage()
+		if s.Heading != "" {
+			p.text(s.Heading, "F2", headingSize)
+			p.y -= bodySize
+		}
+		for _, b := range s.Blocks {
+			if b.Heading != "" {
+				p.text(b.Heading, "F2", subheadSize)
+			}
+			if b.Image != nil {
+				if err := p.image(b.Image); err != nil && err != ErrUnsupportedImage {
+					return nil, err
+				}
+			}
+			if b.Caption != "" {
+				p.text(b.Caption, "F1", captionSize)
+			}
+			if b.Text != "" {
+				for _, para := range strings.Split(b.Text, "\n") {
+					p.text(para, "F1", bodySize)
+				}
+			}
+			p.y -= bodySize
+		}
+	}
+
+	return p.bytes(), nil
+}
+
+type pdfImage struct {
+	data          []byte
+	width, height int
+}
+
+type pdfPage struct {
+	content bytes.Buffer
+	images  []int // indexes into pdfBuilder.images
+}
+
+type pdfBuilder struct {
+	pages  []*pdfPage
+	images []pdfImage
+	y      float64
+}
+
+func (p *pdfBuilder) page() *pdfPage {
+	return p.pages[len(p.pages)-1]
+}
+
+func (p *pdfBuilder) newPage() {
+	p.pages = append(p.pages, &pdfPage{})
+	p.y = pageHeight - pageMargin
+}
+
+// ensure starts a new page if fewer than h points remain.
+func (p *pdfBuilder) ensure(h float64) {
+	if p.y-h < pageMargin {
+		p.newPage()
+	}
+}
+
+// text writes word-wrapped text at the current position.
+func (p *pdfBuilder) text(s, font string, size float64) {
+	lineHeight := size * lineSpacing
+	lines := wrap(s, size, contentWidth)
+	if len(lines) == 0 {
+		p.y -= lineHeight
+		return
+	}
+	for _, line := range lines {
+		p.ensure(lineHeight)
+		p.y -= lineHeight
+		fmt.Fprintf(&p.page().content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n",
+			font, size, pageMargin, p.y, pdfEscape(line))
+	}
+}
+
+// image places a photo scaled to the content width.
+func (p *pdfBuilder) image(img *Image) error {
+	data, w, h, err := jpegImage(img)
+	if err != nil {
+		return err
+	}
+
+	drawW, drawH := float64(w), float64(h)
+	if drawW > contentWidth {
+		drawH *= contentWidth / drawW
+		drawW = contentWidth
+	}
+	if drawH > maxImageH {
+		drawW *= maxImageH / drawH
+		drawH = maxImageH
+	}
+
+	p.ensure(drawH + bodySize)
+	p.y -= drawH
+	p.images = append(p.images, pdfImage{data: data, width: w, height: h})
+	idx := len(p.images) - 1
+	p.page().images = append(p.page().images, idx)
+	fmt.Fprintf(&p.page().content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n",
+		drawW, drawH, pageMargin, p.y, idx)
+	p.y -= bodySize / 2
+	return nil
+}
+
+// bytes serializes the PDF. Object layout: 1 catalog, 2 pages, 3-4 fonts,
+// then images, then a page and content stream per page.
+func (p *pdfBuilder) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	obj := func(body string, stream []byte) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\n", len(offsets), body)
+		if stream != nil {
+			out.WriteString("stream\n")
+			out.Write(stream)
+			out.WriteString("\nendstream\n")
+		}
+		out.WriteString("endobj\n")
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	firstImage := 5
+	firstPage := firstImage + len(p.images)
+	kids := make([]string, len(p.pages))
+	for i := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+
+	obj("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)), nil)
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>", nil)
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>", nil)
+	for _, img := range p.images {
+		obj(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>",
+			img.width, img.height, len(img.data)), img.data)
+	}
+	for i, page := range p.pages {
+		var xobjects strings.Builder
+		for _, idx := range page.images {
+			fmt.Fprintf(&xobjects, "/Im%d %d 0 R ", idx, firstImage+idx)
+		}
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> /XObject << %s>> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, xobjects.String(), firstPage+2*i+1), nil)
+		obj(fmt.Sprintf("<< /Length %d >>", page.content.Len()), page.content.Bytes())
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// wrap splits s into lines that fit width at the given font size. Widths are
+// estimated from Helvetica's average glyph width.
+func wrap(s string, size, width float64) []string {
+	maxChars := int(width / (size * 0.5))
+	var lines []string
+	var line strings.Builder
+	for _, word := range strings.Fields(s) {
+		for len([]rune(word)) > maxChars {
+			if line.Len() > 0 {
+				lines = append(lines, line.String())
+				line.Reset()
+			}
+			r := []rune(word)
+			lines = append(lines, string(r[:maxChars]))
+			word = string(r[maxChars:])
+		}
+		if line.Len() > 0 && len([]rune(line.String()))+1+len([]rune(word)) > maxChars {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+		if line.Len() > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(word)
+	}
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// pdfEscape converts s to a WinAnsi PDF string body. Characters outside Latin-1
+// become '?' since the standard fonts have no glyphs for them.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '’' || r == '‘':
+			b.WriteByte('\'')
+		case r == '“' || r == '”':
+			b.WriteByte('"')
+		case r == '–' || r == '—':
+			b.WriteByte('-')
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+// Package report renders simple documents (headings, text, photos) to PDF and EPUB.
+//
+// It depends only on the standard library: PDFs use the built-in Helvetica fonts
+// and embed photos as JPEG, EPUBs are EPUB 3 with one XHTML file per section.
+package report
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // Register PNG decoding for photos
+)
+
+// Document is a titled list of sections.
+type Document struct {
+	Title    string
+	Subtitle string
+	Sections []Section
+}
+
+// Section is a chapter with an optional heading.
+type Section struct {
+	Heading string
+	Blocks  []Block
+}
+
+// Block is a sub-heading, a paragraph or a photo. Empty fields are skipped.
+type Block struct {
+	Heading string
+	Text    string
+	Image   *Image
+	Caption string
+}
+
+// Image is an encoded photo (JPEG or PNG).
+type Image struct {
+	Data     []byte
+	MimeType string
+}
+
+var ErrUnsupportedImage = errors.New("unsupported image")
+
+// jpegImage returns three-channel JPEG bytes and dimensions for img. Colour JPEGs
+// pass through; anything else (PNG, grayscale or CMYK JPEG) is re-encoded.
+func jpegImage(img *Image) ([]byte, int, int, error) {
+	if img.MimeType == "image/jpeg" {
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(img.Data))
+		if err == nil && cfg.ColorModel == color.YCbCrModel {
+			return img.Data, cfg.Width, cfg.Height, nil
+		}
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return nil, 0, 0, ErrUnsupportedImage
+	}
+	b := decoded.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), decoded, b.Min, draw.Src)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, rgba, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, 0, 0, err
+	}
+	return buf.Bytes(), b.Dx(), b.Dy(), nil
+}
@@ -0,0 +1,209 @@
+// Package storage resolves where uploaded files live for each data residency region.
+//
+// Every region has its own upload root (a local path or a mounted bucket). Files
+// are stored relative to the root of the region they were uploaded in, so a
+// pregnancy's data never leaves its region's storage.
+//
+// A region may also have a cold root on a cheaper, slower storage class. Files
+// keep the same relative path in both tiers.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Storage tiers. New uploads are hot; files of archived pregnancies move to cold.
+const (
+	TierHot  = "hot"
+	TierCold = "cold"
+)
+
+// ErrUnknownRegion is returned for a region with no configured storage root.
+var ErrUnknownRegion = errors.New("unknown storage region")
+
+// ErrNoColdStorage is returned for a region without a cold storage root.
+var ErrNoColdStorage = errors.New("no cold storage for region")
+
+// Regions maps region codes to upload roots. The empty region is the default
+// and resolves to the default root.
+type Regions struct {
+	defaultRoot string
+	roots       map[string]string
+	coldRoots   map[string]string // Keyed by region code, "" for the default region
+}
+
+// Parse builds Regions from a spec like "eu=/srv/uploads-eu,us=/srv/uploads-us".
+// Region codes are lower-cased; the default root serves pregnancies without a region.
+func Parse(spec, defaultRoot string) (*Regions, error) {
+	roots, err := parseRoots(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Regions{defaultRoot: defaultRoot, roots: roots, coldRoots: make(map[string]string)}, nil
+}
+
+// SetCold configures cold roots from a spec in the Parse format, plus an optional
+// cold root for the default region. Every code must be a configured region.
+func (r *Regions) SetCold(spec, defaultRoot string) error {
+	roots, err := parseRoots(spec)
+	if err != nil {
+		return err
+	}
+	for code := range roots {
+		if !r.Has(code) {
+			return fmt.Errorf("%w: %q has cold storage but no upload root", ErrUnknownRegion, code)
+		}
+	}
+	if defaultRoot != "" {
+		roots[""] = defaultRoot
+	}
+	r.coldRoots = roots
+	return nil
+}
+
+func parseRoots(spec string) (map[string]string, error) {
+	roots := make(map[string]string)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		code, root, ok := strings.Cut(part, "=")
+		code = strings.ToLower(strings.TrimSpace(code))
+		root = strings.TrimSpace(root)
+		if !ok || code == "" || root == "" {
+			return nil, fmt.Errorf("invalid storage region %q, want code=path", part)
+		}
+		roots[code] = root
+	}
+	return roots, nil
+}
+
+// Has reports whether region has storage. The empty region always does.
+func (r *Regions) Has(region string) bool {
+	if region == "" {
+		return true
+	}
+	_, ok := r.roots[region]
+	return ok
+}
+
+// Root returns the upload root for region.
+func (r *Regions) Root(region string) (string, error) {
+	if region == "" {
+		return r.defaultRoot, nil
+	}
+	root, ok := r.roots[region]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownRegion, region)
+	}
+	return root, nil
+}
+
+// Path joins a stored relative path onto the region's root.
+func (r *Regions) Path(region, relPath string) (string, error) {
+	root, err := r.Root(region)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, relPath), nil
+}
+
+// Codes lists the configured region codes in order, without the default.
+func (r *Regions) Codes() []string {
+	codes := make([]string, 0, len(r.roots))
+	for code := range r.roots {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// HasCold reports whether region has a cold storage root.
+func (r *Regions) HasCold(region string) bool {
+	_, ok := r.coldRoots[region]
+	return ok
+}
+
+// ColdRegions lists the regions with cold storage, "" being the default region.
+func (r *Regions) ColdRegions() []string {
+	codes := make([]string, 0, len(r.coldRoots))
+	for code := range r.coldRoots {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// TierPath joins a stored relative path onto the region's root for the tier.
+func (r *Regions) TierPath(region, tier, relPath string) (string, error) {
+	if tier != TierCold {
+		return r.Path(region, relPath)
+	}
+	root, ok := r.coldRoots[region]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrNoColdStorage, region)
+	}
+	return filepath.Join(root, relPath), nil
+}
+
+// Copy copies a file between tiers of the same region. The destination is
+// written under a temporary name and renamed, so readers never see a partial file.
+func (r *Regions) Copy(region, relPath, fromTier, toTier string) error {
+	src, err := r.TierPath(region, fromTier, relPath)
+	if err != nil {
+		return err
+	}
+	dst, err := r.TierPath(region, toTier, relPath)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// Remove deletes a file from one tier. A missing file is not an error.
+func (r *Regions) Remove(region, tier, relPath string) error {
+	path, err := r.TierPath(region, tier, relPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package summarize writes short summaries and tags for long journal posts.
+//
+// A Summarizer is pluggable: the HTTP implementation posts the text to an
+// external LLM service or a locally hosted model that speaks the same protocol.
+// Posts are only sent for pregnancies whose owner consented.
+package summarize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits applied to what a service returns
+const (
+	MaxSummaryLen = 500
+	MaxTags       = 10
+	MaxTagLen     = 40
+)
+
+// Post is the journal post to summarize.
+type Post struct {
+	Title string `json:"title,omitempty"`
+	Text  string `json:"text"`
+}
+
+// Summary is a post's summary with suggested tags.
+type Summary struct {
+	Summary string   `json:"summary"`
+	Tags    []string `json:"tags"`
+}
+
+// Summarizer summarizes a journal post.
+type Summarizer interface {
+	Summarize(ctx context.Context, post *Post) (*Summary, error)
+}
+
+// HTTPSummarizer posts the Post as JSON to URL and expects a JSON Summary back.
+// A bearer token is sent when Token is set.
+type HTTPSummarizer struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// NewHTTP creates an HTTPSummarizer with a request timeout.
+func NewHTTP(url, token string) *HTTPSummarizer {
+	return &HTTPSummarizer{URL: url, Token: token, Client: &http.Client{Timeout: time.Minute}}
+}
+
+// Summarize sends the post to the summarization endpoint.
+func (s *HTTPSummarizer) Summarize(ctx context.Context, post *Post) (*Summary, error) {
+	body, err := json.Marshal(post)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("summarization service returned %s", resp.Status)
+	}
+
+	var sum Summary
+	if err := json.NewDecoder(resp.Body).Decode(&sum); err != nil {
+		return nil, fmt.Errorf("decode summary: %w", err)
+	}
+	return &sum, nil
+}
+
+// Clean trims a service's summary to the limits and normalizes its tags:
+// lowercase, without a leading #, deduplicated.
+func Clean(s *Summary) *Summary {
+	out := &Summary{Summary: truncate(strings.TrimSpace(s.Summary), MaxSummaryLen), Tags: []string{}}
+	seen := make(map[string]bool)
+	for _, t := range s.Tags {
+		t = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(t), "#")))
+		t = truncate(t, MaxTagLen)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out.Tags = append(out.Tags, t)
+		if len(out.Tags) == MaxTags {
+			break
+		}
+	}
+	return out
+}
+
+// truncate cuts s to at most n characters.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,116 @@
+// Package textmerge merges concurrent edits of a text line by line, the way
+// diff3 does: changes to different lines of a common base are combined, and
+// changes to the same lines conflict unless they are identical.
+package textmerge
+
+import "strings"
+
+// Merge combines ours and theirs, two edits of base. It returns false if they
+// changed the same lines differently.
+func Merge(base, ours, theirs string) (string, bool) {
+	if ours == theirs || theirs == base {
+		return ours, true
+	}
+	if ours == base {
+		return theirs, true
+	}
+
+	a, b, c := splitLines(base), splitLines(ours), splitLines(theirs)
+	mb, mc := matchLines(a, b), matchLines(a, c)
+
+	var out strings.Builder
+	// Base lines kept by both sides anchor the merge; the chunks between two
+	// anchors are merged as a unit.
+	pa, pb, pc := -1, -1, -1
+	for i := 0; i <= len(a); i++ {
+		ib, ic := len(b), len(c)
+		if i < len(a) {
+			if mb[i] < 0 || mc[i] < 0 {
+				continue
+			}
+			ib, ic = mb[i], mc[i]
+		}
+
+		ca, cb, cc := a[pa+1:i], b[pb+1:ib], c[pc+1:ic]
+		switch {
+		case equal(cb, ca):
+			writeLines(&out, cc)
+		case equal(cc, ca), equal(cb, cc):
+			writeLines(&out, cb)
+		default:
+			return "", false
+		}
+		if i < len(a) {
+			out.WriteString(a[i])
+		}
+		pa, pb, pc = i, ib, ic
+	}
+	return out.String(), true
+}
+
+// splitLines splits s after each newline, so joining the lines gives s back.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matchLines maps each line of a to the line of b it is kept as in a longest
+// common subsequence, or -1 if it was changed or removed.
+func matchLines(a, b []string) []int {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	m := make([]int, len(a))
+	for i := range m {
+		m[i] = -1
+	}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			m[i] = j
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return m
+}
+
+func equal(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(out *strings.Builder, lines []string) {
+	for _, l := range lines {
+		out.WriteString(l)
+	}
+}
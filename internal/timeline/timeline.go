@@ -0,0 +1,224 @@
+// Package timeline writes and verifies tamper-evident, hash-chained JSON lines exports.
+//
+// Each line is a Record. Its hash covers the previous record's hash, its sequence
+// number, kind and compact data bytes, so removing, reordering or editing any line
+// breaks the chain. Every hash is signed with the server's Ed25519 key.
+package timeline
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Format identifies the export layout. Changing the hashing rules requires a new value.
+const Format = "clingy-timeline-v1"
+
+// Record kinds
+const (
+	KindHeader   = "header"
+	KindRevision = "revision"
+	KindFooter   = "footer"
+)
+
+// Record is one line of a timeline export.
+type Record struct {
+	Seq       int64           `json:"seq"`
+	Kind      string          `json:"kind"`
+	Data      json.RawMessage `json:"data"`
+	PrevHash  string          `json:"prevHash"`
+	Hash      string          `json:"hash"`      // hex SHA-256 of prevHash|seq|kind|data
+	Signature string          `json:"signature"` // base64 Ed25519 signature of the hash bytes
+}
+
+// Header is the data of the first record.
+type Header struct {
+	Format      string `json:"format"`
+	PregnancyID int64  `json:"pregnancyId"`
+	GeneratedAt string `json:"generatedAt"`
+	PublicKey   string `json:"publicKey"` // base64 Ed25519 public key
+}
+
+// Footer is the data of the last record.
+type Footer struct {
+	Revisions int64 `json:"revisions"`
+}
+
+var (
+	ErrBrokenChain = errors.New("hash chain broken")
+	ErrBadSig      = errors.New("signature mismatch")
+	ErrTruncated   = errors.New("export is missing its footer")
+)
+
+// Writer appends signed records to an export.
+type Writer struct {
+	w        *bufio.Writer
+	key      ed25519.PrivateKey
+	seq      int64
+	prevHash string
+}
+
+// NewWriter creates a Writer that signs with key.
+func NewWriter(w io.Writer, key ed25519.PrivateKey) *Writer {
+	return &Writer{w: bufio.NewWriter(w), key: key}
+}
+
+// Write appends a record with data encoded as compact JSON.
+func (tw *Writer) Write(kind string, data interface{}) error {
+	raw, err := marshalCompact(data)
+	if err != nil {
+		return err
+	}
+
+	sum := chainHash(tw.prevHash, tw.seq, kind, raw)
+	rec := Record{
+		Seq:       tw.seq,
+		Kind:      kind,
+		Data:      raw,
+		PrevHash:  tw.prevHash,
+		Hash:      hex.EncodeToString(sum),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(tw.key, sum)),
+	}
+
+	line, err := marshalCompact(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := tw.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	tw.seq++
+	tw.prevHash = rec.Hash
+	return nil
+}
+
+// Flush writes any buffered records.
+func (tw *Writer) Flush() error {
+	return tw.w.Flush()
+}
+
+// Result summarizes a successfully verified export.
+type Result struct {
+	Header    Header
+	Revisions int64
+	LastHash  string
+}
+
+// Verify checks the chain and signatures of an export. If pub is nil the key from
+// the header is used, which proves integrity but not that this server produced it.
+func Verify(r io.Reader, pub ed25519.PublicKey) (*Result, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var res Result
+	var prevHash string
+	var seq int64
+	footer := false
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if footer {
+			return nil, fmt.Errorf("line %d: data after footer", seq+1)
+		}
+
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", seq+1, err)
+		}
+		if rec.Seq != seq || rec.PrevHash != prevHash {
+			return nil, fmt.Errorf("line %d: %w", seq+1, ErrBrokenChain)
+		}
+
+		sum := chainHash(rec.PrevHash, rec.Seq, rec.Kind, rec.Data)
+		if hex.EncodeToString(sum) != rec.Hash {
+			return nil, fmt.Errorf("line %d: %w", seq+1, ErrBrokenChain)
+		}
+
+		switch {
+		case seq == 0:
+			if rec.Kind != KindHeader {
+				return nil, fmt.Errorf("line 1: expected header, got %q", rec.Kind)
+			}
+			if err := json.Unmarshal(rec.Data, &res.Header); err != nil {
+				return nil, fmt.Errorf("line 1: %w", err)
+			}
+			if res.Header.Format != Format {
+				return nil, fmt.Errorf("unsupported format %q", res.Header.Format)
+			}
+			if pub == nil {
+				key, err := base64.StdEncoding.DecodeString(res.Header.PublicKey)
+				if err != nil || len(key) != ed25519.PublicKeySize {
+					return nil, errors.New("header has an invalid public key")
+				}
+				pub = key
+			}
+		case rec.Kind == KindRevision:
+			res.Revisions++
+		case rec.Kind == KindFooter:
+			var f Footer
+			if err := json.Unmarshal(rec.Data, &f); err != nil {
+				return nil, fmt.Errorf("line %d: %w", seq+1, err)
+			}
+			if f.Revisions != res.Revisions {
+				return nil, fmt.Errorf("footer counts %d revisions, found %d: %w", f.Revisions, res.Revisions, ErrBrokenChain)
+			}
+			footer = true
+		default:
+			return nil, fmt.Errorf("line %d: unknown record kind %q", seq+1, rec.Kind)
+		}
+
+		sig, err := base64.StdEncoding.DecodeString(rec.Signature)
+		if err != nil || !ed25519.Verify(pub, sum, sig) {
+			return nil, fmt.Errorf("line %d: %w", seq+1, ErrBadSig)
+		}
+
+		prevHash = rec.Hash
+		seq++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !footer {
+		return nil, ErrTruncated
+	}
+
+	res.LastHash = prevHash
+	return &res, nil
+}
+
+// chainHash computes SHA-256(prevHash | seq | kind | data).
+func chainHash(prevHash string, seq int64, kind string, data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write([]byte{'|'})
+	h.Write([]byte(strconv.FormatInt(seq, 10)))
+	h.Write([]byte{'|'})
+	h.Write([]byte(kind))
+	h.Write([]byte{'|'})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// marshalCompact encodes v without HTML escaping so the bytes survive a
+// decode into json.RawMessage unchanged.
+func marshalCompact(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
@@ -0,0 +1,211 @@
+// Package trackerv1 reads exports of the original tracker backend (v1) and
+// maps their records onto this API's pregnancies, entries and settings.
+//
+// A Source is pluggable: the HTTP implementation pulls a user's export and
+// photos from a v1 server with the user's v1 token. Exports can also be
+// handed over directly, in which case photos must carry their content.
+package trackerv1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FormatVersion is the only v1 export format understood.
+const FormatVersion = 1
+
+// MaxPhotoBytes bounds one photo, the same as an upload.
+const MaxPhotoBytes = 10 << 20
+
+// Export is a v1 account export.
+type Export struct {
+	FormatVersion int                        `json:"formatVersion"`
+	Profile       *Profile                   `json:"profile,omitempty"`
+	Logs          []Log                      `json:"logs"`
+	Preferences   map[string]json.RawMessage `json:"preferences,omitempty"`
+	Photos        []Photo                    `json:"photos,omitempty"`
+}
+
+// Profile is the v1 pregnancy profile. Dates are YYYY-MM-DD.
+type Profile struct {
+	ID          string `json:"id"`
+	DueDate     string `json:"dueDate,omitempty"`
+	LastPeriod  string `json:"lastPeriod,omitempty"`
+	CycleLength int    `json:"cycleLength,omitempty"`
+	BabyName    string `json:"babyName,omitempty"`
+	MomName     string `json:"momName,omitempty"`
+	MomBirthday string `json:"momBirthday,omitempty"`
+	Gender      string `json:"gender,omitempty"`
+}
+
+// Log is one v1 tracker record.
+type Log struct {
+	ID       string                 `json:"id"`
+	Type     string                 `json:"type"`
+	LoggedAt string                 `json:"loggedAt"` // RFC3339
+	Values   map[string]interface{} `json:"values"`
+	Deleted  bool                   `json:"deleted,omitempty"`
+}
+
+// Photo is a v1 photo. Content (base64) is used when set; otherwise the photo
+// is downloaded from URL through a Source.
+type Photo struct {
+	ID       string `json:"id"`
+	URL      string `json:"url,omitempty"`
+	Content  []byte `json:"content,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	Caption  string `json:"caption,omitempty"`
+	TakenAt  string `json:"takenAt,omitempty"` // RFC3339
+}
+
+// logMapping is how a v1 log type becomes an entry: its entry type, renamed
+// value keys and fixed values added when missing.
+type logMapping struct {
+	entryType string
+	rename    map[string]string
+	defaults  map[string]interface{}
+}
+
+// logMappings covers every log type v1 wrote.
+var logMappings = map[string]logMapping{
+	"weight":       {entryType: "weight", rename: map[string]string{"kg": "weight"}, defaults: map[string]interface{}{"unit": "kg"}},
+	"bp":           {entryType: "blood_pressure", rename: map[string]string{"sys": "systolic", "dia": "diastolic", "hr": "pulse"}, defaults: map[string]interface{}{"unit": "mmHg"}},
+	"glucose":      {entryType: "glucose", rename: map[string]string{"value": "glucose"}},
+	"symptom":      {entryType: "symptom", rename: map[string]string{"name": "symptom"}},
+	"water":        {entryType: "water", rename: map[string]string{"ml": "amount"}, defaults: map[string]interface{}{"unit": "ml"}},
+	"note":         {entryType: "journal", rename: map[string]string{"text": "content"}},
+	"kicks":        {entryType: "kick_session"},
+	"contractions": {entryType: "contraction_session"},
+	"appointment":  {entryType: "appointment"},
+	"milestone":    {entryType: "milestone"},
+}
+
+// MapLog returns the entry type, payload and time for a v1 log. The payload
+// gets the log time as "timestamp" unless v1 recorded one.
+func MapLog(l *Log) (string, json.RawMessage, time.Time, error) {
+	m, ok := logMappings[l.Type]
+	if !ok {
+		return "", nil, time.Time{}, fmt.Errorf("unknown v1 log type %q", l.Type)
+	}
+	at, err := time.Parse(time.RFC3339, l.LoggedAt)
+	if err != nil {
+		return "", nil, time.Time{}, errors.New("loggedAt must be an RFC3339 timestamp")
+	}
+
+	data := make(map[string]interface{}, len(l.Values)+len(m.defaults)+1)
+	for k, v := range l.Values {
+		if to, ok := m.rename[k]; ok {
+			k = to
+		}
+		data[k] = v
+	}
+	for k, v := range m.defaults {
+		if _, ok := data[k]; !ok {
+			data[k] = v
+		}
+	}
+	if _, ok := data["timestamp"]; !ok {
+		data["timestamp"] = at.Format(time.RFC3339)
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return "", nil, time.Time{}, err
+	}
+	return m.entryType, payload, at, nil
+}
+
+// ErrUnauthorized is returned when the v1 server refuses the token.
+var ErrUnauthorized = errors.New("v1 server rejected the token")
+
+// Source pulls data from a v1 server on a user's behalf.
+type Source interface {
+	Export(ctx context.Context, token string) (*Export, error)
+	Photo(ctx context.Context, token, photoURL string) ([]byte, string, error)
+}
+
+// HTTPSource reads from a v1 server at URL:
+//
+//	GET {URL}/export -> Export
+//
+// Photos are fetched from their export URL, which must be on the same host,
+// so an export can't point the server at arbitrary addresses.
+type HTTPSource struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewHTTP creates an HTTPSource with a request timeout.
+func NewHTTP(baseURL string) *HTTPSource {
+	return &HTTPSource{URL: strings.TrimRight(baseURL, "/"), Client: &http.Client{Timeout: 60 * time.Second}}
+}
+
+// Export downloads the token holder's export.
+func (s *HTTPSource) Export(ctx context.Context, token string) (*Export, error) {
+	resp, err := s.get(ctx, token, s.URL+"/export")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var export Export
+	if err := json.NewDecoder(resp.Body).Decode(&export); err != nil {
+		return nil, fmt.Errorf("decode v1 export: %w", err)
+	}
+	return &export, nil
+}
+
+// Photo downloads a photo and returns it with its content type.
+func (s *HTTPSource) Photo(ctx context.Context, token, photoURL string) ([]byte, string, error) {
+	base, err := url.Parse(s.URL)
+	if err != nil {
+		return nil, "", err
+	}
+	u, err := base.Parse(photoURL)
+	if err != nil || u.Scheme != base.Scheme || u.Host != base.Host {
+		return nil, "", errors.New("photo URL is not on the v1 server")
+	}
+
+	resp, err := s.get(ctx, token, u.String())
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxPhotoBytes+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > MaxPhotoBytes {
+		return nil, "", fmt.Errorf("photo is larger than %d MB", MaxPhotoBytes>>20)
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+func (s *HTTPSource) get(ctx context.Context, token, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		resp.Body.Close()
+		return nil, ErrUnauthorized
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, fmt.Errorf("v1 server returned %s", resp.Status)
+	}
+	return resp, nil
+}
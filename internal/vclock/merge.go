@@ -0,0 +1,229 @@
+package vclock
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// Field merge kinds.
+const (
+	// Counter fields add up concurrent increments: server + client - base.
+	Counter = "counter"
+	// Set fields keep every element added on either side and drop those
+	// removed on either side.
+	Set = "set"
+)
+
+var mergeable = map[string]map[string]string{}
+
+// Register declares how a field of an entry type merges. Types with at least
+// one registered field are mergeable: concurrent edits to them are merged field
+// by field instead of reported as conflicts. It must only be called from init
+// functions.
+func Register(entryType, field, kind string) {
+	if mergeable[entryType] == nil {
+		mergeable[entryType] = map[string]string{}
+	}
+	mergeable[entryType][field] = kind
+}
+
+func init() {
+	Register("water", "amount", Counter)
+	Register("kick_count", "count", Counter)
+	Register("kick_session", "count", Counter)
+	Register("symptom", "tags", Set)
+	Register("journal", "tags", Set)
+	Register("photo", "tags", Set)
+	Register("milestone", "tags", Set)
+}
+
+// Mergeable reports whether concurrent edits of entryType can be merged.
+func Mergeable(entryType string) bool {
+	return len(mergeable[entryType]) > 0
+}
+
+// Merge three-way merges concurrent edits of a mergeable entry's payload.
+// base is the last version both sides had seen and may be nil when they share
+// none. A field changed on one side only takes that side's value; a field
+// changed on both sides merges by its registered kind. It returns the merged
+// payload, or the fields that changed on both sides and could not be merged.
+func Merge(entryType string, base, server, client json.RawMessage) (json.RawMessage, []string, error) {
+	var b, s, c map[string]interface{}
+	if len(base) > 0 {
+		if err := json.Unmarshal(base, &b); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := json.Unmarshal(server, &s); err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(client, &c); err != nil {
+		return nil, nil, err
+	}
+
+	keys := map[string]bool{}
+	for _, m := range []map[string]interface{}{b, s, c} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+
+	merged := make(map[string]interface{}, len(keys))
+	var conflicts []string
+	for k := range keys {
+		bv, bok := b[k]
+		sv, sok := s[k]
+		cv, cok := c[k]
+
+		var v interface{}
+		var ok bool
+		switch {
+		case sok == cok && reflect.DeepEqual(sv, cv):
+			v, ok = sv, sok
+		case sok == bok && reflect.DeepEqual(sv, bv):
+			v, ok = cv, cok // Only the client changed it
+		case cok == bok && reflect.DeepEqual(cv, bv):
+			v, ok = sv, sok // Only the server changed it
+		default:
+			v, ok = mergeField(mergeable[entryType][k], bv, sv, cv)
+			if !ok {
+				conflicts = append(conflicts, k)
+				continue
+			}
+		}
+		if ok {
+			merged[k] = v
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, conflicts, nil
+	}
+
+	data, err := json.Marshal(merged)
+	return data, nil, err
+}
+
+// MergeFields merges a write into the stored payload field by field, for
+// entry types whose owner chose field-level merges. Unlike Merge it always
+// succeeds: a field changed on both sides merges by its registered kind, else
+// arrays keep the elements either side added and other values take the
+// client's. base may be nil, in which case arrays are unioned.
+func MergeFields(entryType string, base, server, client json.RawMessage) (json.RawMessage, error) {
+	var b, s, c map[string]interface{}
+	if len(base) > 0 {
+		if err := json.Unmarshal(base, &b); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(server, &s); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(client, &c); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]bool{}
+	for _, m := range []map[string]interface{}{b, s, c} {
+		for k := range m {
+			keys[k] = true
+		}
+	}
+
+	merged := make(map[string]interface{}, len(keys))
+	for k := range keys {
+		bv, bok := b[k]
+		sv, sok := s[k]
+		cv, cok := c[k]
+
+		var v interface{}
+		var ok bool
+		switch {
+		case sok == cok && reflect.DeepEqual(sv, cv):
+			v, ok = sv, sok
+		case sok == bok && reflect.DeepEqual(sv, bv):
+			v, ok = cv, cok // Only the client changed it
+		case cok == bok && reflect.DeepEqual(cv, bv):
+			v, ok = sv, sok // Only the server changed it
+		default:
+			kind := mergeable[entryType][k]
+			if kind == "" {
+				kind = Set // Unregistered arrays keep what either side added
+			}
+			if v, ok = mergeField(kind, bv, sv, cv); !ok {
+				v, ok = cv, cok
+			}
+		}
+		if ok {
+			merged[k] = v
+		}
+	}
+	return json.Marshal(merged)
+}
+
+// mergeField merges a value changed on both sides. It reports false when the
+// field's kind doesn't apply to the values.
+func mergeField(kind string, base, server, client interface{}) (interface{}, bool) {
+	switch kind {
+	case Counter:
+		s, sok := server.(float64)
+		c, cok := client.(float64)
+		if !sok || !cok {
+			return nil, false
+		}
+		b, _ := base.(float64)
+		return s + c - b, true
+	case Set:
+		s, sok := server.([]interface{})
+		c, cok := client.([]interface{})
+		if !sok || !cok {
+			return nil, false
+		}
+		b, _ := base.([]interface{})
+		return mergeSet(b, s, c), true
+	}
+	return nil, false
+}
+
+// mergeSet keeps the elements both sides have and those one side added since
+// base, in server order followed by the client's additions.
+func mergeSet(base, server, client []interface{}) []interface{} {
+	inBase, inServer, inClient := setOf(base), setOf(server), setOf(client)
+
+	merged := []interface{}{}
+	seen := map[string]bool{}
+	add := func(key string, v interface{}) {
+		if !seen[key] {
+			seen[key] = true
+			merged = append(merged, v)
+		}
+	}
+	for _, v := range server {
+		key := elementKey(v)
+		if inClient[key] || !inBase[key] {
+			add(key, v)
+		}
+	}
+	for _, v := range client {
+		key := elementKey(v)
+		if !inBase[key] && !inServer[key] {
+			add(key, v)
+		}
+	}
+	return merged
+}
+
+func setOf(values []interface{}) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[elementKey(v)] = true
+	}
+	return set
+}
+
+// elementKey identifies a set element by its JSON encoding.
+func elementKey(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,303 @@
+// Package vitals parses blood pressure and glucose exports from home devices.
+package vitals
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reading kinds. These double as entry types when readings are stored.
+const (
+	KindBloodPressure = "blood_pressure"
+	KindGlucose       = "glucose"
+)
+
+var ErrUnknownVendor = errors.New("unknown vendor")
+
+// Reading is a single validated measurement from a device export.
+type Reading struct {
+	Kind         string    `json:"-"`
+	Timestamp    time.Time `json:"timestamp"`
+	DeviceSerial string    `json:"deviceSerial,omitempty"`
+	Vendor       string    `json:"vendor"`
+	Systolic     *int      `json:"systolic,omitempty"`
+	Diastolic    *int      `json:"diastolic,omitempty"`
+	Pulse        *int      `json:"pulse,omitempty"`
+	Glucose      *float64  `json:"glucose,omitempty"`
+	Unit         string    `json:"unit,omitempty"` // mmHg, mg/dL, mmol/L
+}
+
+// RowError describes a row that could not be imported.
+type RowError struct {
+	Row    int    `json:"row"` // 1-based, header is row 1
+	Reason string `json:"reason"`
+}
+
+// Parser turns a vendor export into readings.
+type Parser interface {
+	Parse(r io.Reader, loc *time.Location) ([]Reading, []RowError, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Parser)
+)
+
+// Register adds a vendor parser. It replaces any parser already registered under the name.
+func Register(vendor string, p Parser) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(vendor)] = p
+}
+
+// Lookup returns the parser for a vendor.
+func Lookup(vendor string) (Parser, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := registry[strings.ToLower(vendor)]
+	if !ok {
+		return nil, ErrUnknownVendor
+	}
+	return p, nil
+}
+
+// Vendors lists the registered vendor names.
+func Vendors() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("omron", &csvFormat{
+		vendor:     "omron",
+		kind:       KindBloodPressure,
+		dateCols:   []string{"date", "measurement date"},
+		timeCols:   []string{"time", "measurement time"},
+		layouts:    []string{"2006/01/02 15:04", "01/02/2006 15:04", "2006-01-02 15:04", "2006/01/02 15:04:05"},
+		serialCols: []string{"device serial", "serial number"},
+		fields: map[string][]string{
+			"systolic":  {"systolic (mmhg)", "sys", "systolic"},
+			"diastolic": {"diastolic (mmhg)", "dia", "diastolic"},
+			"pulse":     {"pulse (bpm)", "pulse"},
+		},
+	})
+	Register("withings", &csvFormat{
+		vendor:   "withings",
+		kind:     KindBloodPressure,
+		dateCols: []string{"date"},
+		layouts:  []string{"2006-01-02 15:04:05", "2006-01-02 15:04"},
+		fields: map[string][]string{
+			"systolic":  {"systolic"},
+			"diastolic": {"diastolic"},
+			"pulse":     {"heart rate"},
+		},
+	})
+	Register("contour", &csvFormat{
+		vendor:     "contour",
+		kind:       KindGlucose,
+		dateCols:   []string{"date"},
+		timeCols:   []string{"time"},
+		layouts:    []string{"01/02/2006 15:04", "2006-01-02 15:04", "01/02/2006 3:04 PM"},
+		serialCols: []string{"meter serial number", "serial number"},
+		unitCols:   []string{"unit", "units"},
+		fields: map[string][]string{
+			"glucose": {"glucose (mg/dl)", "glucose", "reading"},
+		},
+	})
+	Register("accuchek", &csvFormat{
+		vendor:     "accuchek",
+		kind:       KindGlucose,
+		dateCols:   []string{"date"},
+		timeCols:   []string{"time"},
+		layouts:    []string{"02.01.2006 15:04", "2006-01-02 15:04"},
+		serialCols: []string{"serial number", "meter sn"},
+		unitCols:   []string{"unit"},
+		fields: map[string][]string{
+			"glucose": {"result", "glucose", "bg"},
+		},
+	})
+}
+
+// csvFormat is a header-driven parser for vendor CSV exports.
+type csvFormat struct {
+	vendor     string
+	kind       string
+	dateCols   []string // Date or combined date/time column
+	timeCols   []string // Optional separate time column
+	layouts    []string
+	serialCols []string
+	unitCols   []string
+	fields     map[string][]string // field -> accepted header names (lowercase)
+}
+
+// Parse implements Parser. Timestamps without a zone are interpreted in loc.
+func (f *csvFormat) Parse(r io.Reader, loc *time.Location) ([]Reading, []RowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	find := func(candidates []string) int {
+		for _, c := range candidates {
+			if i, ok := index[c]; ok {
+				return i
+			}
+		}
+		return -1
+	}
+
+	dateCol := find(f.dateCols)
+	if dateCol < 0 {
+		return nil, nil, fmt.Errorf("%s export is missing a date column", f.vendor)
+	}
+	timeCol := find(f.timeCols)
+	serialCol := find(f.serialCols)
+	unitCol := find(f.unitCols)
+	fieldCols := make(map[string]int, len(f.fields))
+	for field, candidates := range f.fields {
+		col := find(candidates)
+		if col < 0 {
+			return nil, nil, fmt.Errorf("%s export is missing a %s column", f.vendor, field)
+		}
+		fieldCols[field] = col
+	}
+
+	var readings []Reading
+	var rowErrors []RowError
+	row := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		row++
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: row, Reason: err.Error()})
+			continue
+		}
+
+		cell := func(col int) string {
+			if col < 0 || col >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[col])
+		}
+
+		stamp := cell(dateCol)
+		if timeCol >= 0 {
+			stamp += " " + cell(timeCol)
+		}
+		ts, ok := parseTime(stamp, f.layouts, loc)
+		if !ok {
+			rowErrors = append(rowErrors, RowError{Row: row, Reason: fmt.Sprintf("unrecognized date %q", stamp)})
+			continue
+		}
+
+		reading := Reading{
+			Kind:         f.kind,
+			Timestamp:    ts,
+			DeviceSerial: cell(serialCol),
+			Vendor:       f.vendor,
+		}
+
+		var reason string
+		if f.kind == KindBloodPressure {
+			reason = fillBloodPressure(&reading, cell(fieldCols["systolic"]), cell(fieldCols["diastolic"]), cell(fieldCols["pulse"]))
+		} else {
+			reason = fillGlucose(&reading, cell(fieldCols["glucose"]), cell(unitCol))
+		}
+		if reason != "" {
+			rowErrors = append(rowErrors, RowError{Row: row, Reason: reason})
+			continue
+		}
+		readings = append(readings, reading)
+	}
+
+	return readings, rowErrors, nil
+}
+
+func parseTime(value string, layouts []string, loc *time.Location) (time.Time, bool) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// fillBloodPressure validates and sets BP values. Returns a reason on failure.
+func fillBloodPressure(r *Reading, sys, dia, pulse string) string {
+	s, err := strconv.Atoi(sys)
+	if err != nil {
+		return "invalid systolic value"
+	}
+	d, err := strconv.Atoi(dia)
+	if err != nil {
+		return "invalid diastolic value"
+	}
+	if s < 50 || s > 260 {
+		return "systolic out of range (50-260)"
+	}
+	if d < 30 || d > 160 {
+		return "diastolic out of range (30-160)"
+	}
+	if s <= d {
+		return "systolic must be greater than diastolic"
+	}
+	r.Systolic, r.Diastolic, r.Unit = &s, &d, "mmHg"
+
+	if pulse != "" {
+		p, err := strconv.Atoi(pulse)
+		if err != nil || p < 30 || p > 220 {
+			return "pulse out of range (30-220)"
+		}
+		r.Pulse = &p
+	}
+	return ""
+}
+
+// fillGlucose validates and sets the glucose value, defaulting to mg/dL.
+func fillGlucose(r *Reading, value, unit string) string {
+	g, err := strconv.ParseFloat(strings.ReplaceAll(value, ",", "."), 64)
+	if err != nil {
+		return "invalid glucose value"
+	}
+
+	switch strings.ToLower(strings.ReplaceAll(unit, " ", "")) {
+	case "", "mg/dl":
+		if g < 10 || g > 600 {
+			return "glucose out of range (10-600 mg/dL)"
+		}
+		r.Unit = "mg/dL"
+	case "mmol/l":
+		if g < 0.5 || g > 33 {
+			return "glucose out of range (0.5-33 mmol/L)"
+		}
+		r.Unit = "mmol/L"
+	default:
+		return fmt.Sprintf("unknown glucose unit %q", unit)
+	}
+	r.Glucose = &g
+	return ""
+}
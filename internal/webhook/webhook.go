@@ -0,0 +1,131 @@
+// Package webhook delivers signed JSON webhooks to user-configured URLs, such
+// as smart home services and IFTTT-style applets.
+//
+// Deliveries are signed the way mvchat2 signs its profile webhooks to us. The
+// HTTP implementation refuses to connect to loopback, private and link-local
+// addresses, so a webhook URL can't reach into the server's own network.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for URLs that resolve to non-public addresses.
+var ErrPrivateAddress = errors.New("webhook address is not public")
+
+// Sender posts a JSON body to a webhook URL. status is the response status,
+// or 0 if none was received; any non-2xx response is an error.
+type Sender interface {
+	Send(ctx context.Context, url, secret string, body []byte) (status int, err error)
+}
+
+// HTTPSender posts over HTTPS with X-Tracker-Timestamp (Unix seconds) and
+// X-Tracker-Signature ("sha256=" and the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the webhook's secret). Redirects are not
+// followed.
+type HTTPSender struct {
+	Client *http.Client
+}
+
+// NewHTTP creates an HTTPSender with a request timeout that only dials public
+// addresses.
+func NewHTTP() *HTTPSender {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: dialPublicOnly}
+	return &HTTPSender{Client: &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        20,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}}
+}
+
+// Send delivers the body.
+func (s *HTTPSender) Send(ctx context.Context, webhookURL, secret string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tracker2api-webhooks")
+	req.Header.Set("X-Tracker-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Tracker-Signature", Sign(secret, timestamp, body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the X-Tracker-Signature of a delivery.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateURL checks that a webhook URL is an absolute https URL without
+// credentials whose host isn't obviously internal. Hosts that resolve to
+// internal addresses are refused when dialed.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("url must be an absolute https URL")
+	}
+	if u.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") || strings.HasSuffix(host, ".local") {
+		return ErrPrivateAddress
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublic(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// dialPublicOnly refuses connections to non-public addresses. It runs after
+// DNS resolution, so a name can't be pointed at an internal address later.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598).
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublic reports whether ip is a globally routable unicast address.
+func isPublic(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
@@ -0,0 +1,152 @@
+// Package gestation does pregnancy date math: dating a pregnancy from the last
+// menstrual period, conception, an IVF transfer or a due date, and the
+// gestational week, day and trimester on a given day.
+//
+// It has no dependencies beyond the standard library, so other services can
+// share the server's calculations. The API is stable: new dating methods may
+// be added, existing results won't change.
+//
+// Every pregnancy is dated by its start, the first day of the last menstrual
+// period of a regular 28-day cycle (LMP), whichever way it was dated. The
+// start is day 0 of week 0 and the due date is 280 days (40 weeks) later.
+// Dates are calendar days: the time of day and time zone of a time.Time are
+// ignored, only its year, month and day count, so daylight saving changes and
+// leap days never shift a week.
+package gestation
+
+import "time"
+
+const (
+	// PregnancyDays is the length of a pregnancy from its start to its due date.
+	PregnancyDays = 280
+	// DefaultCycleLength is the menstrual cycle length LMP dating assumes.
+	DefaultCycleLength = 28
+	// ovulationDay is the day of a 28-day cycle on which conception is assumed.
+	ovulationDay = 14
+)
+
+// Cycle lengths accepted by FromLMP; others are treated as DefaultCycleLength.
+const (
+	MinCycleLength = 20
+	MaxCycleLength = 45
+)
+
+// Trimester bounds: the second starts with week 13 and the third with week 28.
+const (
+	SecondTrimesterWeek = 13
+	ThirdTrimesterWeek  = 28
+)
+
+// Method is how a pregnancy was dated.
+type Method string
+
+// Dating methods, as stored in the pregnancy's calculation method.
+const (
+	MethodLMP        Method = "lmp"
+	MethodConception Method = "conception"
+	MethodDueDate    Method = "due_date"
+	MethodIVF        Method = "ivf"
+)
+
+// Day returns the calendar day of t as midnight UTC.
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// DaysBetween returns the calendar days from a to b, negative if b is earlier.
+func DaysBetween(a, b time.Time) int {
+	return int(Day(b).Sub(Day(a)).Hours() / 24)
+}
+
+// FromLMP dates a pregnancy from the first day of the last menstrual period.
+// Ovulation comes later in longer cycles and earlier in shorter ones, so the
+// start moves by the cycle's difference from 28 days. A cycleLength outside
+// MinCycleLength..MaxCycleLength (0 for unknown) counts as 28 days.
+func FromLMP(lmp time.Time, cycleLength int) time.Time {
+	if cycleLength < MinCycleLength || cycleLength > MaxCycleLength {
+		cycleLength = DefaultCycleLength
+	}
+	return Day(lmp).AddDate(0, 0, cycleLength-DefaultCycleLength)
+}
+
+// FromConception dates a pregnancy from the day of conception, two weeks after
+// the start.
+func FromConception(conception time.Time) time.Time {
+	return Day(conception).AddDate(0, 0, -ovulationDay)
+}
+
+// FromIVFTransfer dates an IVF pregnancy from the embryo transfer day and the
+// embryo's age in days at transfer (3 or 5 usually; 0 for a frozen transfer
+// dated like conception). The embryo was fertilized embryoAgeDays before the
+// transfer.
+func FromIVFTransfer(transfer time.Time, embryoAgeDays int) time.Time {
+	return FromConception(Day(transfer).AddDate(0, 0, -embryoAgeDays))
+}
+
+// FromDueDate dates a pregnancy from its due date.
+func FromDueDate(due time.Time) time.Time {
+	return Day(due).AddDate(0, 0, -PregnancyDays)
+}
+
+// DueDate returns the due date of a pregnancy that started on start.
+func DueDate(start time.Time) time.Time {
+	return Day(start).AddDate(0, 0, PregnancyDays)
+}
+
+// WeekStart returns the first day of a gestational week.
+func WeekStart(start time.Time, week int) time.Time {
+	return Day(start).AddDate(0, 0, week*7)
+}
+
+// Trimester returns the trimester (1-3) of a gestational week.
+func Trimester(week int) int {
+	switch {
+	case week >= ThirdTrimesterWeek:
+		return 3
+	case week >= SecondTrimesterWeek:
+		return 2
+	}
+	return 1
+}
+
+// Progress is how far along a pregnancy is on a day.
+type Progress struct {
+	Week          int // Completed weeks since the start
+	Day           int // Days into the week, 0-6
+	DaysElapsed   int // Days since the start
+	DaysRemaining int // Days until the due date, 0 once it passed
+	Trimester     int // 1-3
+}
+
+// ProgressOn returns the progress of a pregnancy that started on start, on the
+// calendar day of now. Days before the start count as day 0. Weeks keep
+// counting past the due date (week 41, 42, ...).
+func ProgressOn(start, now time.Time) Progress {
+	elapsed := max(DaysBetween(start, now), 0)
+	week := elapsed / 7
+	return Progress{
+		Week:          week,
+		Day:           elapsed % 7,
+		DaysElapsed:   elapsed,
+		DaysRemaining: max(PregnancyDays-elapsed, 0),
+		Trimester:     Trimester(week),
+	}
+}
+
+// Start dates a pregnancy from what is recorded about it: the due date if
+// known, else the start date read per method (the LMP, adjusted for
+// cycleLength, the conception day, or an already computed start for
+// due_date, ivf and unknown methods). ok is false if neither date is known.
+func Start(dueDate, startDate *time.Time, method Method, cycleLength int) (start time.Time, ok bool) {
+	switch {
+	case dueDate != nil:
+		return FromDueDate(*dueDate), true
+	case startDate == nil:
+		return time.Time{}, false
+	case method == MethodLMP:
+		return FromLMP(*startDate, cycleLength), true
+	case method == MethodConception:
+		return FromConception(*startDate), true
+	}
+	return Day(*startDate), true
+}
@@ -0,0 +1,188 @@
+package gestation
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestFromLMP(t *testing.T) {
+	tests := []struct {
+		name        string
+		lmp         time.Time
+		cycleLength int
+		want        time.Time
+	}{
+		{"28-day cycle", date(2024, 1, 10), 28, date(2024, 1, 10)},
+		{"unknown cycle", date(2024, 1, 10), 0, date(2024, 1, 10)},
+		{"long cycle", date(2024, 1, 10), 35, date(2024, 1, 17)},
+		{"short cycle", date(2024, 1, 10), 21, date(2024, 1, 3)},
+		{"shortest cycle", date(2024, 1, 10), MinCycleLength, date(2024, 1, 2)},
+		{"longest cycle", date(2024, 1, 10), MaxCycleLength, date(2024, 1, 27)},
+		{"too short", date(2024, 1, 10), MinCycleLength - 1, date(2024, 1, 10)},
+		{"too long", date(2024, 1, 10), MaxCycleLength + 1, date(2024, 1, 10)},
+		{"onto leap day", date(2024, 2, 25), 32, date(2024, 2, 29)},
+		{"across leap day", date(2024, 2, 27), 31, date(2024, 3, 1)},
+		{"across Feb 28", date(2023, 2, 27), 31, date(2023, 3, 2)},
+		{"time of day ignored", time.Date(2024, 1, 10, 23, 59, 0, 0, time.FixedZone("UTC+14", 14*3600)), 28, date(2024, 1, 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromLMP(tt.lmp, tt.cycleLength); !got.Equal(tt.want) {
+				t.Errorf("FromLMP(%s, %d) = %s, want %s", tt.lmp.Format("2006-01-02"), tt.cycleLength, got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestFromConception(t *testing.T) {
+	tests := []struct {
+		name       string
+		conception time.Time
+		want       time.Time
+	}{
+		{"same month", date(2024, 5, 20), date(2024, 5, 6)},
+		{"leap year", date(2024, 3, 10), date(2024, 2, 25)},
+		{"common year", date(2023, 3, 10), date(2023, 2, 24)},
+		{"across new year", date(2024, 1, 5), date(2023, 12, 22)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromConception(tt.conception); !got.Equal(tt.want) {
+				t.Errorf("FromConception(%s) = %s, want %s", tt.conception.Format("2006-01-02"), got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestFromIVFTransfer(t *testing.T) {
+	tests := []struct {
+		name          string
+		transfer      time.Time
+		embryoAgeDays int
+		want          time.Time
+		wantDue       time.Time
+	}{
+		{"day 5 blastocyst", date(2024, 5, 20), 5, date(2024, 5, 1), date(2025, 2, 5)},
+		{"day 3 embryo", date(2024, 5, 20), 3, date(2024, 5, 3), date(2025, 2, 7)},
+		{"frozen transfer", date(2024, 5, 20), 0, date(2024, 5, 6), date(2025, 2, 10)},
+		{"over leap day", date(2024, 3, 14), 5, date(2024, 2, 24), date(2024, 11, 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FromIVFTransfer(tt.transfer, tt.embryoAgeDays)
+			if !got.Equal(tt.want) {
+				t.Errorf("FromIVFTransfer(%s, %d) = %s, want %s", tt.transfer.Format("2006-01-02"), tt.embryoAgeDays, got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+			}
+			if due := DueDate(got); !due.Equal(tt.wantDue) {
+				t.Errorf("DueDate = %s, want %s", due.Format("2006-01-02"), tt.wantDue.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestDueDateRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		start time.Time
+		due   time.Time
+	}{
+		{"leap year", date(2024, 1, 1), date(2024, 10, 7)},
+		{"common year", date(2023, 1, 1), date(2023, 10, 8)},
+		{"start on leap day", date(2024, 2, 29), date(2024, 12, 5)},
+		{"due on leap day", date(2023, 5, 25), date(2024, 2, 29)},
+		{"across new year", date(2023, 6, 1), date(2024, 3, 7)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DueDate(tt.start); !got.Equal(tt.due) {
+				t.Errorf("DueDate(%s) = %s, want %s", tt.start.Format("2006-01-02"), got.Format("2006-01-02"), tt.due.Format("2006-01-02"))
+			}
+			if got := FromDueDate(tt.due); !got.Equal(tt.start) {
+				t.Errorf("FromDueDate(%s) = %s, want %s", tt.due.Format("2006-01-02"), got.Format("2006-01-02"), tt.start.Format("2006-01-02"))
+			}
+			// A due date in any zone and at any time of day is its calendar day
+			local := time.Date(tt.due.Year(), tt.due.Month(), tt.due.Day(), 23, 30, 0, 0, time.FixedZone("UTC-10", -10*3600))
+			if got := DueDate(FromDueDate(local)); !got.Equal(tt.due) {
+				t.Errorf("DueDate(FromDueDate(%s)) = %s, want %s", local, got.Format("2006-01-02"), tt.due.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestProgressOn(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone data")
+	}
+	tests := []struct {
+		name  string
+		start time.Time
+		now   time.Time
+		want  Progress
+	}{
+		{"before start", date(2024, 1, 10), date(2024, 1, 1), Progress{Week: 0, Day: 0, DaysElapsed: 0, DaysRemaining: 280, Trimester: 1}},
+		{"start day", date(2024, 1, 10), date(2024, 1, 10), Progress{Week: 0, Day: 0, DaysElapsed: 0, DaysRemaining: 280, Trimester: 1}},
+		{"February of a leap year", date(2024, 2, 1), date(2024, 3, 1), Progress{Week: 4, Day: 1, DaysElapsed: 29, DaysRemaining: 251, Trimester: 1}},
+		{"February of a common year", date(2023, 2, 1), date(2023, 3, 1), Progress{Week: 4, Day: 0, DaysElapsed: 28, DaysRemaining: 252, Trimester: 1}},
+		{"second trimester", date(2024, 1, 1), date(2024, 1, 1).AddDate(0, 0, 13*7), Progress{Week: 13, Day: 0, DaysElapsed: 91, DaysRemaining: 189, Trimester: 2}},
+		{"third trimester", date(2024, 1, 1), date(2024, 1, 1).AddDate(0, 0, 28*7+3), Progress{Week: 28, Day: 3, DaysElapsed: 199, DaysRemaining: 81, Trimester: 3}},
+		{"due date", date(2024, 1, 1), date(2024, 10, 7), Progress{Week: 40, Day: 0, DaysElapsed: 280, DaysRemaining: 0, Trimester: 3}},
+		{"past due", date(2024, 1, 1), date(2024, 10, 17), Progress{Week: 41, Day: 3, DaysElapsed: 290, DaysRemaining: 0, Trimester: 3}},
+		{"over daylight saving", time.Date(2024, 3, 9, 23, 30, 0, 0, newYork), time.Date(2024, 3, 11, 0, 30, 0, 0, newYork), Progress{Week: 0, Day: 2, DaysElapsed: 2, DaysRemaining: 278, Trimester: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ProgressOn(tt.start, tt.now); got != tt.want {
+				t.Errorf("ProgressOn = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimester(t *testing.T) {
+	tests := []struct {
+		week, want int
+	}{
+		{0, 1}, {12, 1}, {13, 2}, {27, 2}, {28, 3}, {42, 3},
+	}
+	for _, tt := range tests {
+		if got := Trimester(tt.week); got != tt.want {
+			t.Errorf("Trimester(%d) = %d, want %d", tt.week, got, tt.want)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	due := date(2024, 10, 7)
+	startDate := date(2024, 1, 10)
+	tests := []struct {
+		name        string
+		due         *time.Time
+		start       *time.Time
+		method      Method
+		cycleLength int
+		want        time.Time
+		wantOK      bool
+	}{
+		{"due date wins", &due, &startDate, MethodLMP, 35, date(2024, 1, 1), true},
+		{"lmp", nil, &startDate, MethodLMP, 28, date(2024, 1, 10), true},
+		{"lmp with long cycle", nil, &startDate, MethodLMP, 35, date(2024, 1, 17), true},
+		{"conception", nil, &startDate, MethodConception, 28, date(2023, 12, 27), true},
+		{"ivf start as is", nil, &startDate, MethodIVF, 35, date(2024, 1, 10), true},
+		{"due_date start as is", nil, &startDate, MethodDueDate, 35, date(2024, 1, 10), true},
+		{"unknown method", nil, &startDate, "", 35, date(2024, 1, 10), true},
+		{"no dates", nil, nil, MethodLMP, 28, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Start(tt.due, tt.start, tt.method, tt.cycleLength)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("Start = %s, %v, want %s, %v", got.Format("2006-01-02"), ok, tt.want.Format("2006-01-02"), tt.wantOK)
+			}
+		})
+	}
+}